@@ -17,8 +17,11 @@ package main
 import (
 	"github.com/samber/lo"
 
+	"github.com/aws/karpenter/pkg/apis/settings"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/cloudprovider"
 	"github.com/aws/karpenter/pkg/controllers"
+	"github.com/aws/karpenter/pkg/controllers/consolidation"
 	"github.com/aws/karpenter/pkg/operator"
 	"github.com/aws/karpenter/pkg/webhooks"
 
@@ -39,8 +42,13 @@ func main() {
 		op.AMIProvider,
 		op.SecurityGroupProvider,
 		op.SubnetProvider,
+		op.WarmUp,
+		op.Clock,
 	)
 	lo.Must0(op.AddHealthzCheck("cloud-provider", awsCloudProvider.LivenessProbe))
+	if settings.FromContext(ctx).EnableICECacheEndpoint {
+		lo.Must0(op.AddMetricsExtraHandler(awscache.UnavailableOfferingsPath, awscache.NewUnavailableOfferingsHandler(op.UnavailableOfferingsCache)))
+	}
 	cloudProvider := metrics.Decorate(awsCloudProvider)
 
 	op.
@@ -50,7 +58,7 @@ func main() {
 			op.GetClient(),
 			op.KubernetesInterface,
 			state.NewCluster(op.Clock, op.GetClient(), cloudProvider),
-			op.EventRecorder,
+			consolidation.Decorate(ctx, op.EventRecorder, op.GetClient(), op.PricingProvider),
 			cloudProvider,
 		)...).
 		WithWebhooks(ctx, corewebhooks.NewWebhooks()...).
@@ -59,13 +67,23 @@ func main() {
 			op.Session,
 			op.Clock,
 			op.GetClient(),
+			op.KubernetesInterface,
 			op.EventRecorder,
 			op.UnavailableOfferingsCache,
+			op.SpotInterruptionsCache,
 			awsCloudProvider,
 			op.SubnetProvider,
 			op.SecurityGroupProvider,
 			op.PricingProvider,
 			op.AMIProvider,
+			op.InstanceProvider,
+			op.ComputeOptimizerProvider,
+			op.InstanceTypesProvider,
+			op.CapacityReservationProvider,
+			op.SpotPlacementScoreProvider,
+			op.TagPolicyProvider,
+			op.WarmUp,
+			op.BootstrapFailuresCache,
 		)...).
 		WithWebhooks(ctx, webhooks.NewWebhooks()...).
 		Start(ctx)
@@ -0,0 +1,142 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// karpenter-ami prints the AMIs that Karpenter resolves for a NodeClass or AWSNodeTemplate, using the same code path
+// and global settings as the controller, e.g.
+//
+//	karpenter-ami resolve -f nodeclass.yaml
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/imagebuilder"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/providers/amifamily"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "resolve" {
+		log.Fatalf("Usage: %s resolve -f path/to/nodeclass.yaml", os.Args[0])
+	}
+	flags := flag.NewFlagSet("resolve", flag.ExitOnError)
+	file := flags.String("f", "", "path to a NodeClass or AWSNodeTemplate manifest, or - to read it from stdin")
+	namespace := flags.String("namespace", "karpenter", "namespace of the karpenter-global-settings ConfigMap")
+	region := flags.String("region", "", "AWS region to resolve AMIs in, defaults to the region of the AWS config")
+	lo.Must0(flags.Parse(os.Args[2:]))
+	if *file == "" {
+		log.Fatalf("Usage: %s resolve -f path/to/nodeclass.yaml", os.Args[0])
+	}
+
+	nodeClass, err := readNodeClass(*file)
+	if err != nil {
+		log.Fatalf("reading node class, %s", err)
+	}
+	kubernetesInterface := kubernetes.NewForConfigOrDie(config.GetConfigOrDie())
+	// AMI resolution depends on global settings such as the denied AMIs, so they're read from the cluster
+	cm, err := kubernetesInterface.CoreV1().ConfigMaps(*namespace).Get(context.Background(), (&settings.Settings{}).ConfigMap(), metav1.GetOptions{})
+	if err != nil {
+		log.Fatalf("getting settings, %s", err)
+	}
+	ctx, err := (&settings.Settings{}).Inject(context.Background(), cm)
+	if err != nil {
+		log.Fatalf("parsing settings, %s", err)
+	}
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		Config:            aws.Config{Region: lo.EmptyableToPtr(*region)},
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	provider := amifamily.NewProvider(ctx, clock.RealClock{}, aws.StringValue(sess.Config.Region), nil, kubernetesInterface, ssm.New(sess), imagebuilder.New(sess), ec2.New(sess),
+		amifamily.AssumeRole(sess), cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval), cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+	amis, err := provider.Get(ctx, nodeClass, &amifamily.Options{})
+	if err != nil {
+		log.Fatalf("resolving amis, %s", err)
+	}
+	printAMIs(os.Stdout, amis, time.Now())
+}
+
+// readNodeClass decodes a NodeClass, or an AWSNodeTemplate which is converted to a NodeClass in the same way as the
+// controller converts it
+func readNodeClass(path string) (*v1beta1.NodeClass, error) {
+	var raw []byte
+	var err error
+	if path == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	typeMeta := metav1.TypeMeta{}
+	if err = yaml.NewYAMLOrJSONDecoder(bytes.NewReader(raw), len(raw)).Decode(&typeMeta); err != nil {
+		return nil, err
+	}
+	switch typeMeta.Kind {
+	case "NodeClass":
+		nodeClass := &v1beta1.NodeClass{}
+		if err = yaml.NewYAMLOrJSONDecoder(bytes.NewReader(raw), len(raw)).Decode(nodeClass); err != nil {
+			return nil, err
+		}
+		return nodeClass, nil
+	case "AWSNodeTemplate":
+		nodeTemplate := &v1alpha1.AWSNodeTemplate{}
+		if err = yaml.NewYAMLOrJSONDecoder(bytes.NewReader(raw), len(raw)).Decode(nodeTemplate); err != nil {
+			return nil, err
+		}
+		return nodeclassutil.New(nodeTemplate), nil
+	default:
+		return nil, fmt.Errorf("unsupported kind %q, expected NodeClass or AWSNodeTemplate", typeMeta.Kind)
+	}
+}
+
+// printAMIs prints the AMIs in the order that Karpenter prefers them. Instance types are launched with the first AMI
+// whose requirements they're compatible with.
+func printAMIs(out io.Writer, amis amifamily.AMIs, now time.Time) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tCREATED\tDEPRECATED\tROLLOUT\tREQUIREMENTS")
+	for _, ami := range amis {
+		rollout := "current"
+		if ami.Candidate {
+			rollout = fmt.Sprintf("candidate (%d%%)", ami.CandidateWeight)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\t%s\n", ami.AmiID, lo.Ternary(ami.Name != "", ami.Name, "-"),
+			lo.Ternary(ami.CreationDate != "", ami.CreationDate, "-"), ami.Deprecated(now), rollout, ami.Requirements)
+	}
+	lo.Must0(w.Flush())
+}
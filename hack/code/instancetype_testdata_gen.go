@@ -115,9 +115,13 @@ func getInstanceTypeInfo(info *ec2.InstanceTypeInfo) string {
 	fmt.Fprintf(src, "InstanceType: aws.String(\"%s\"),\n", lo.FromPtr(info.InstanceType))
 	fmt.Fprintf(src, "SupportedUsageClasses: aws.StringSlice([]string{%s}),\n", getStringSliceData(info.SupportedUsageClasses))
 	fmt.Fprintf(src, "SupportedVirtualizationTypes: aws.StringSlice([]string{%s}),\n", getStringSliceData(info.SupportedVirtualizationTypes))
+	fmt.Fprintf(src, "SupportedBootModes: aws.StringSlice([]string{%s}),\n", getStringSliceData(info.SupportedBootModes))
+	fmt.Fprintf(src, "SupportedRootDeviceTypes: aws.StringSlice([]string{%s}),\n", getStringSliceData(info.SupportedRootDeviceTypes))
 	fmt.Fprintf(src, "BurstablePerformanceSupported: aws.Bool(%t),\n", lo.FromPtr(info.BurstablePerformanceSupported))
 	fmt.Fprintf(src, "BareMetal: aws.Bool(%t),\n", lo.FromPtr(info.BareMetal))
 	fmt.Fprintf(src, "Hypervisor: aws.String(\"%s\"),\n", lo.FromPtr(info.Hypervisor))
+	fmt.Fprintf(src, "NitroTpmSupport: aws.String(\"%s\"),\n", lo.FromPtr(info.NitroTpmSupport))
+	fmt.Fprintf(src, "DedicatedHostsSupported: aws.Bool(%t),\n", lo.FromPtr(info.DedicatedHostsSupported))
 	fmt.Fprintf(src, "ProcessorInfo: &ec2.ProcessorInfo{\n")
 	fmt.Fprintf(src, "SupportedArchitectures: aws.StringSlice([]string{%s}),\n", getStringSliceData(info.ProcessorInfo.SupportedArchitectures))
 	fmt.Fprintf(src, "},\n")
@@ -151,12 +155,30 @@ func getInstanceTypeInfo(info *ec2.InstanceTypeInfo) string {
 		fmt.Fprintf(src, "InstanceStorageInfo: &ec2.InstanceStorageInfo{")
 		fmt.Fprintf(src, "NvmeSupport: aws.String(\"%s\"),\n", lo.FromPtr(info.InstanceStorageInfo.NvmeSupport))
 		fmt.Fprintf(src, "TotalSizeInGB: aws.Int64(%d),\n", lo.FromPtr(info.InstanceStorageInfo.TotalSizeInGB))
+		fmt.Fprintf(src, "Disks: []*ec2.DiskInfo{\n")
+		for _, disk := range info.InstanceStorageInfo.Disks {
+			fmt.Fprintf(src, getDiskInfo(disk))
+		}
+		fmt.Fprintf(src, "},\n")
+		fmt.Fprintf(src, "},\n")
+	}
+	if info.EbsInfo != nil {
+		fmt.Fprintf(src, "EbsInfo: &ec2.EbsInfo{\n")
+		fmt.Fprintf(src, "EbsOptimizedSupport: aws.String(\"%s\"),\n", lo.FromPtr(info.EbsInfo.EbsOptimizedSupport))
+		fmt.Fprintf(src, "NvmeSupport: aws.String(\"%s\"),\n", lo.FromPtr(info.EbsInfo.NvmeSupport))
+		if info.EbsInfo.EbsOptimizedInfo != nil {
+			fmt.Fprintf(src, "EbsOptimizedInfo: &ec2.EbsOptimizedInfo{\n")
+			fmt.Fprintf(src, "BaselineBandwidthInMbps: aws.Int64(%d),\n", lo.FromPtr(info.EbsInfo.EbsOptimizedInfo.BaselineBandwidthInMbps))
+			fmt.Fprintf(src, "BaselineIops: aws.Int64(%d),\n", lo.FromPtr(info.EbsInfo.EbsOptimizedInfo.BaselineIops))
+			fmt.Fprintf(src, "},\n")
+		}
 		fmt.Fprintf(src, "},\n")
 	}
 	fmt.Fprintf(src, "NetworkInfo: &ec2.NetworkInfo{\n")
 	fmt.Fprintf(src, "MaximumNetworkInterfaces: aws.Int64(%d),\n", lo.FromPtr(info.NetworkInfo.MaximumNetworkInterfaces))
 	fmt.Fprintf(src, "Ipv4AddressesPerInterface: aws.Int64(%d),\n", lo.FromPtr(info.NetworkInfo.Ipv4AddressesPerInterface))
 	fmt.Fprintf(src, "EncryptionInTransitSupported: aws.Bool(%t),\n", lo.FromPtr(info.NetworkInfo.EncryptionInTransitSupported))
+	fmt.Fprintf(src, "EfaSupported: aws.Bool(%t),\n", lo.FromPtr(info.NetworkInfo.EfaSupported))
 	fmt.Fprintf(src, "DefaultNetworkCardIndex: aws.Int64(%d),\n", lo.FromPtr(info.NetworkInfo.DefaultNetworkCardIndex))
 	fmt.Fprintf(src, "NetworkCards: []*ec2.NetworkCardInfo{\n")
 	for _, networkCard := range info.NetworkInfo.NetworkCards {
@@ -172,6 +194,17 @@ func getNetworkCardInfo(info *ec2.NetworkCardInfo) string {
 	fmt.Fprintf(src, "{\n")
 	fmt.Fprintf(src, "NetworkCardIndex: aws.Int64(%d),\n", lo.FromPtr(info.NetworkCardIndex))
 	fmt.Fprintf(src, "MaximumNetworkInterfaces: aws.Int64(%d),\n", lo.FromPtr(info.MaximumNetworkInterfaces))
+	fmt.Fprintf(src, "BaselineBandwidthInGbps: aws.Float64(%g),\n", lo.FromPtr(info.BaselineBandwidthInGbps))
+	fmt.Fprintf(src, "},\n")
+	return src.String()
+}
+
+func getDiskInfo(info *ec2.DiskInfo) string {
+	src := &bytes.Buffer{}
+	fmt.Fprintf(src, "{\n")
+	fmt.Fprintf(src, "SizeInGB: aws.Int64(%d),\n", lo.FromPtr(info.SizeInGB))
+	fmt.Fprintf(src, "Count: aws.Int64(%d),\n", lo.FromPtr(info.Count))
+	fmt.Fprintf(src, "Type: aws.String(\"%s\"),\n", lo.FromPtr(info.Type))
 	fmt.Fprintf(src, "},\n")
 	return src.String()
 }
@@ -182,6 +215,11 @@ func getInferenceAcceleratorDeviceInfo(info *ec2.InferenceDeviceInfo) string {
 	fmt.Fprintf(src, "Name: aws.String(\"%s\"),\n", lo.FromPtr(info.Name))
 	fmt.Fprintf(src, "Manufacturer: aws.String(\"%s\"),\n", lo.FromPtr(info.Manufacturer))
 	fmt.Fprintf(src, "Count: aws.Int64(%d),\n", lo.FromPtr(info.Count))
+	if info.MemoryInfo != nil {
+		fmt.Fprintf(src, "MemoryInfo: &ec2.InferenceDeviceMemoryInfo{\n")
+		fmt.Fprintf(src, "SizeInMiB: aws.Int64(%d),\n", lo.FromPtr(info.MemoryInfo.SizeInMiB))
+		fmt.Fprintf(src, "},\n")
+	}
 	fmt.Fprintf(src, "},\n")
 	return src.String()
 }
@@ -88,7 +88,9 @@ func main() {
 		KubernetesInterface: kubernetes.NewForConfigOrDie(&rest.Config{}),
 	})
 	cp := awscloudprovider.New(op.InstanceTypesProvider, op.InstanceProvider,
-		op.EventRecorder, op.GetClient(), op.AMIProvider, op.SecurityGroupProvider, op.SubnetProvider)
+		op.EventRecorder, op.GetClient(), op.AMIProvider, op.SecurityGroupProvider, op.SubnetProvider, op.WarmUp, op.Clock)
+	// nothing runs the warm-up controller here, so don't wait on it
+	op.WarmUp.MarkDone()
 
 	provider := v1alpha1.AWS{SubnetSelector: map[string]string{
 		"*": "*",
@@ -18,8 +18,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
 	"knative.dev/pkg/configmap"
 )
@@ -29,36 +31,98 @@ type settingsKeyType struct{}
 var ContextKey = settingsKeyType{}
 
 var defaultSettings = &Settings{
-	AssumeRoleARN:              "",
-	AssumeRoleDuration:         time.Minute * 15,
-	ClusterCABundle:            "",
-	ClusterName:                "",
-	ClusterEndpoint:            "",
-	DefaultInstanceProfile:     "",
-	EnablePodENI:               false,
-	EnableENILimitedPodDensity: true,
-	IsolatedVPC:                false,
-	VMMemoryOverheadPercent:    0.075,
-	InterruptionQueueName:      "",
-	Tags:                       map[string]string{},
-	ReservedENIs:               0,
+	AssumeRoleARN:                    "",
+	AssumeRoleDuration:               time.Minute * 15,
+	ClusterCABundle:                  "",
+	ClusterName:                      "",
+	ClusterEndpoint:                  "",
+	DefaultInstanceProfile:           "",
+	EnablePodENI:                     false,
+	EnableENILimitedPodDensity:       true,
+	IsolatedVPC:                      false,
+	VMMemoryOverheadPercent:          0.075,
+	VMMemoryOverheadByInstanceFamily: map[string]float64{},
+	VMMemoryOverheadByAMIFamily:      map[string]float64{},
+	InterruptionQueueName:            "",
+	Tags:                             map[string]string{},
+	ReservedENIs:                     0,
+	APIRecordFile:                    "",
+	APIReplayFile:                    "",
+	EnableComputeOptimizer:           false,
+	ComputeOptimizerPriceBias:        0,
+	EnforceMetadataOptions:           false,
+	RequireEBSEncryption:             false,
+	SpotMinPools:                     0,
+	LifecycleWebhookURL:              "",
+	LifecycleEventBusName:            "",
+	SubnetRebalancingThreshold:       0,
+	TerminationRecordTTL:             0,
+	SpotPlacementScoreTargetCapacity: 0,
+	AMISelectorMaxImages:             10000,
+	DeniedAMIIDs:                     []string{},
+	DeniedAMINames:                   []string{},
+	DeniedAMIOwners:                  []string{},
+	ProvisioningTriggerQueueName:     "",
+	Airgapped:                        false,
+	NodeTerminationHandlerParity:     false,
+	ValidateTagPolicies:              false,
+	VerifySSMAgentRegistration:       false,
+	DisabledManagedTags:              []string{},
+	ExcludedInstanceClasses:          []string{},
+	OfferingScorePriceTolerance:      0,
+	UnavailableOfferingsTTL:          3 * time.Minute,
+	UnavailableOfferingsMaxTTL:       0,
+	EnableICECacheEndpoint:           false,
+	EnableVPCCNIPodDensity:           false,
+	OfferingsRefreshInterval:         5 * time.Minute,
 }
 
 // +k8s:deepcopy-gen=true
 type Settings struct {
-	AssumeRoleARN              string
-	AssumeRoleDuration         time.Duration
-	ClusterCABundle            string
-	ClusterName                string
-	ClusterEndpoint            string
-	DefaultInstanceProfile     string
-	EnablePodENI               bool
-	EnableENILimitedPodDensity bool
-	IsolatedVPC                bool
-	VMMemoryOverheadPercent    float64
-	InterruptionQueueName      string
-	Tags                       map[string]string
-	ReservedENIs               int
+	AssumeRoleARN                    string
+	AssumeRoleDuration               time.Duration
+	ClusterCABundle                  string
+	ClusterName                      string
+	ClusterEndpoint                  string
+	DefaultInstanceProfile           string
+	EnablePodENI                     bool
+	EnableENILimitedPodDensity       bool
+	IsolatedVPC                      bool
+	VMMemoryOverheadPercent          float64
+	VMMemoryOverheadByInstanceFamily map[string]float64
+	VMMemoryOverheadByAMIFamily      map[string]float64
+	InterruptionQueueName            string
+	Tags                             map[string]string
+	ReservedENIs                     int
+	APIRecordFile                    string
+	APIReplayFile                    string
+	EnableComputeOptimizer           bool
+	ComputeOptimizerPriceBias        float64
+	EnforceMetadataOptions           bool
+	RequireEBSEncryption             bool
+	SpotMinPools                     int
+	LifecycleWebhookURL              string
+	LifecycleEventBusName            string
+	SubnetRebalancingThreshold       float64
+	TerminationRecordTTL             time.Duration
+	SpotPlacementScoreTargetCapacity int
+	AMISelectorMaxImages             int
+	DeniedAMIIDs                     []string
+	DeniedAMINames                   []string
+	DeniedAMIOwners                  []string
+	ProvisioningTriggerQueueName     string
+	Airgapped                        bool
+	NodeTerminationHandlerParity     bool
+	ValidateTagPolicies              bool
+	VerifySSMAgentRegistration       bool
+	DisabledManagedTags              []string
+	ExcludedInstanceClasses          []string
+	OfferingScorePriceTolerance      float64
+	UnavailableOfferingsTTL          time.Duration
+	UnavailableOfferingsMaxTTL       time.Duration
+	EnableICECacheEndpoint           bool
+	EnableVPCCNIPodDensity           bool
+	OfferingsRefreshInterval         time.Duration
 }
 
 func (*Settings) ConfigMap() string {
@@ -80,9 +144,40 @@ func (*Settings) Inject(ctx context.Context, cm *v1.ConfigMap) (context.Context,
 		configmap.AsBool("aws.enableENILimitedPodDensity", &s.EnableENILimitedPodDensity),
 		configmap.AsBool("aws.isolatedVPC", &s.IsolatedVPC),
 		configmap.AsFloat64("aws.vmMemoryOverheadPercent", &s.VMMemoryOverheadPercent),
+		AsFloat64Map("aws.vmMemoryOverheadByInstanceFamily", &s.VMMemoryOverheadByInstanceFamily),
+		AsFloat64Map("aws.vmMemoryOverheadByAMIFamily", &s.VMMemoryOverheadByAMIFamily),
 		configmap.AsString("aws.interruptionQueueName", &s.InterruptionQueueName),
 		AsStringMap("aws.tags", &s.Tags),
 		configmap.AsInt("aws.reservedENIs", &s.ReservedENIs),
+		configmap.AsString("aws.apiRecordFile", &s.APIRecordFile),
+		configmap.AsString("aws.apiReplayFile", &s.APIReplayFile),
+		configmap.AsBool("aws.enableComputeOptimizer", &s.EnableComputeOptimizer),
+		configmap.AsFloat64("aws.computeOptimizerPriceBias", &s.ComputeOptimizerPriceBias),
+		configmap.AsBool("aws.enforceMetadataOptions", &s.EnforceMetadataOptions),
+		configmap.AsBool("aws.requireEBSEncryption", &s.RequireEBSEncryption),
+		configmap.AsInt("aws.spotMinPools", &s.SpotMinPools),
+		configmap.AsString("aws.lifecycleWebhookURL", &s.LifecycleWebhookURL),
+		configmap.AsString("aws.lifecycleEventBusName", &s.LifecycleEventBusName),
+		configmap.AsFloat64("aws.subnetRebalancingThreshold", &s.SubnetRebalancingThreshold),
+		configmap.AsDuration("aws.terminationRecordTTL", &s.TerminationRecordTTL),
+		configmap.AsInt("aws.spotPlacementScoreTargetCapacity", &s.SpotPlacementScoreTargetCapacity),
+		configmap.AsInt("aws.amiSelectorMaxImages", &s.AMISelectorMaxImages),
+		AsStringSlice("aws.deniedAMIIDs", &s.DeniedAMIIDs),
+		AsStringSlice("aws.deniedAMINames", &s.DeniedAMINames),
+		AsStringSlice("aws.deniedAMIOwners", &s.DeniedAMIOwners),
+		configmap.AsString("aws.provisioningTriggerQueueName", &s.ProvisioningTriggerQueueName),
+		configmap.AsBool("aws.airgapped", &s.Airgapped),
+		configmap.AsBool("aws.nodeTerminationHandlerParity", &s.NodeTerminationHandlerParity),
+		configmap.AsBool("aws.validateTagPolicies", &s.ValidateTagPolicies),
+		configmap.AsBool("aws.verifySSMAgentRegistration", &s.VerifySSMAgentRegistration),
+		AsStringSlice("aws.disabledManagedTags", &s.DisabledManagedTags),
+		AsStringSlice("aws.excludedInstanceClasses", &s.ExcludedInstanceClasses),
+		configmap.AsFloat64("aws.offeringScorePriceTolerance", &s.OfferingScorePriceTolerance),
+		configmap.AsDuration("aws.unavailableOfferingsTTL", &s.UnavailableOfferingsTTL),
+		configmap.AsDuration("aws.unavailableOfferingsMaxTTL", &s.UnavailableOfferingsMaxTTL),
+		configmap.AsBool("aws.enableICECacheEndpoint", &s.EnableICECacheEndpoint),
+		configmap.AsBool("aws.enableVPCCNIPodDensity", &s.EnableVPCCNIPodDensity),
+		configmap.AsDuration("aws.offeringsRefreshInterval", &s.OfferingsRefreshInterval),
 	); err != nil {
 		return ctx, fmt.Errorf("parsing settings, %w", err)
 	}
@@ -128,3 +223,27 @@ func AsStringMap(key string, target *map[string]string) configmap.ParseFunc {
 		return nil
 	}
 }
+
+// AsFloat64Map parses a value as a JSON map of map[string]float64.
+func AsFloat64Map(key string, target *map[string]float64) configmap.ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			m := map[string]float64{}
+			if err := json.Unmarshal([]byte(raw), &m); err != nil {
+				return err
+			}
+			*target = m
+		}
+		return nil
+	}
+}
+
+// AsStringSlice parses a value as a comma-separated list of strings, omitting any empty elements.
+func AsStringSlice(key string, target *[]string) configmap.ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			*target = lo.Compact(lo.Map(strings.Split(raw, ","), func(s string, _ int) string { return strings.TrimSpace(s) }))
+		}
+		return nil
+	}
+}
@@ -17,13 +17,27 @@ package settings
 import (
 	"fmt"
 	"net/url"
+	"regexp"
 	"time"
 
+	"github.com/samber/lo"
 	"knative.dev/pkg/apis"
 
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 )
 
+var (
+	// DisableableManagedTags are the tags that Karpenter applies by default which can be disabled. The
+	// kubernetes.io/cluster/<cluster-name> and karpenter.sh/provisioner-name tags can't be disabled, since Karpenter
+	// discovers the instances it owns by them, and the karpenter.sh/managed-by tag is always applied to instances.
+	DisableableManagedTags = []string{"Name", v1alpha5.MachineManagedByAnnotationKey}
+
+	amiIDRegex     = regexp.MustCompile(`^ami-[0-9a-z]+$`)
+	accountIDRegex = regexp.MustCompile(`^[0-9]{12}$`)
+)
+
 func (s Settings) Validate() (errs *apis.FieldError) {
 	return errs.Also(
 		s.validateEndpoint(),
@@ -32,6 +46,20 @@ func (s Settings) Validate() (errs *apis.FieldError) {
 		s.validateVMMemoryOverheadPercent(),
 		s.validateReservedENIs(),
 		s.validateAssumeRoleDuration(),
+		s.validateAPIRecording(),
+		s.validateComputeOptimizerPriceBias(),
+		s.validateSpotMinPools(),
+		s.validateLifecycleWebhookURL(),
+		s.validateSubnetRebalancingThreshold(),
+		s.validateTerminationRecordTTL(),
+		s.validateSpotPlacementScoreTargetCapacity(),
+		s.validateAMISelectorMaxImages(),
+		s.validateDeniedAMIs(),
+		s.validateDisabledManagedTags(),
+		s.validateExcludedInstanceClasses(),
+		s.validateOfferingScorePriceTolerance(),
+		s.validateUnavailableOfferingsTTL(),
+		s.validateOfferingsRefreshInterval(),
 	).ViaField("aws")
 }
 
@@ -75,9 +103,22 @@ func (s Settings) validateTags() (errs *apis.FieldError) {
 
 func (s Settings) validateVMMemoryOverheadPercent() (errs *apis.FieldError) {
 	if s.VMMemoryOverheadPercent < 0 {
-		return errs.Also(apis.ErrInvalidValue("cannot be negative", "vmMemoryOverheadPercent"))
+		errs = errs.Also(apis.ErrInvalidValue("cannot be negative", "vmMemoryOverheadPercent"))
 	}
-	return nil
+	for family, percent := range s.VMMemoryOverheadByInstanceFamily {
+		if percent < 0 || percent >= 1 {
+			errs = errs.Also(apis.ErrInvalidValue("must be at least 0 and less than 1", "vmMemoryOverheadByInstanceFamily").ViaKey(family))
+		}
+	}
+	for family, percent := range s.VMMemoryOverheadByAMIFamily {
+		if !lo.Contains(v1alpha1.SupportedAMIFamilies, family) {
+			errs = errs.Also(apis.ErrInvalidKeyName(family, "vmMemoryOverheadByAMIFamily", fmt.Sprintf("not one of %v", v1alpha1.SupportedAMIFamilies)))
+		}
+		if percent < 0 || percent >= 1 {
+			errs = errs.Also(apis.ErrInvalidValue("must be at least 0 and less than 1", "vmMemoryOverheadByAMIFamily").ViaKey(family))
+		}
+	}
+	return errs
 }
 
 func (s Settings) validateReservedENIs() (errs *apis.FieldError) {
@@ -86,3 +127,122 @@ func (s Settings) validateReservedENIs() (errs *apis.FieldError) {
 	}
 	return nil
 }
+
+func (s Settings) validateAPIRecording() (errs *apis.FieldError) {
+	if s.APIRecordFile != "" && s.APIReplayFile != "" {
+		return errs.Also(apis.ErrMultipleOneOf("apiRecordFile", "apiReplayFile"))
+	}
+	return nil
+}
+
+func (s Settings) validateComputeOptimizerPriceBias() (errs *apis.FieldError) {
+	if s.ComputeOptimizerPriceBias < 0 {
+		return errs.Also(apis.ErrInvalidValue("cannot be negative", "computeOptimizerPriceBias"))
+	}
+	return nil
+}
+
+func (s Settings) validateSpotMinPools() (errs *apis.FieldError) {
+	if s.SpotMinPools < 0 {
+		return errs.Also(apis.ErrInvalidValue("cannot be negative", "spotMinPools"))
+	}
+	return nil
+}
+
+func (s Settings) validateLifecycleWebhookURL() (errs *apis.FieldError) {
+	if s.LifecycleWebhookURL == "" {
+		return nil
+	}
+	u, err := url.Parse(s.LifecycleWebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q not a valid http(s) URL", s.LifecycleWebhookURL), "lifecycleWebhookURL"))
+	}
+	return nil
+}
+
+func (s Settings) validateSubnetRebalancingThreshold() (errs *apis.FieldError) {
+	if s.SubnetRebalancingThreshold < 0 || s.SubnetRebalancingThreshold > 1 {
+		return errs.Also(apis.ErrInvalidValue("must be between 0 and 1", "subnetRebalancingThreshold"))
+	}
+	return nil
+}
+
+func (s Settings) validateTerminationRecordTTL() (errs *apis.FieldError) {
+	if s.TerminationRecordTTL < 0 {
+		return errs.Also(apis.ErrInvalidValue("cannot be negative", "terminationRecordTTL"))
+	}
+	return nil
+}
+
+func (s Settings) validateSpotPlacementScoreTargetCapacity() (errs *apis.FieldError) {
+	if s.SpotPlacementScoreTargetCapacity < 0 {
+		return errs.Also(apis.ErrInvalidValue("cannot be negative", "spotPlacementScoreTargetCapacity"))
+	}
+	return nil
+}
+
+func (s Settings) validateAMISelectorMaxImages() (errs *apis.FieldError) {
+	if s.AMISelectorMaxImages <= 0 {
+		return errs.Also(apis.ErrInvalidValue("must be positive", "amiSelectorMaxImages"))
+	}
+	return nil
+}
+
+func (s Settings) validateDeniedAMIs() (errs *apis.FieldError) {
+	for _, id := range s.DeniedAMIIDs {
+		if !amiIDRegex.MatchString(id) {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q is not a valid ami id", id), "deniedAMIIDs"))
+		}
+	}
+	for _, owner := range s.DeniedAMIOwners {
+		if !accountIDRegex.MatchString(owner) {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q is not a valid aws account id", owner), "deniedAMIOwners"))
+		}
+	}
+	return errs
+}
+
+func (s Settings) validateDisabledManagedTags() (errs *apis.FieldError) {
+	for _, tag := range s.DisabledManagedTags {
+		if !lo.Contains(DisableableManagedTags, tag) {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q is not one of %v", tag, DisableableManagedTags), "disabledManagedTags"))
+		}
+	}
+	return errs
+}
+
+func (s Settings) validateExcludedInstanceClasses() (errs *apis.FieldError) {
+	for _, class := range s.ExcludedInstanceClasses {
+		if !lo.Contains(v1alpha1.InstanceClasses, class) {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q is not one of %v", class, v1alpha1.InstanceClasses), "excludedInstanceClasses"))
+		}
+	}
+	return errs
+}
+
+func (s Settings) validateOfferingScorePriceTolerance() (errs *apis.FieldError) {
+	if s.OfferingScorePriceTolerance < 0 || s.OfferingScorePriceTolerance > 1 {
+		return errs.Also(apis.ErrInvalidValue("must be between 0 and 1", "offeringScorePriceTolerance"))
+	}
+	return nil
+}
+
+func (s Settings) validateUnavailableOfferingsTTL() (errs *apis.FieldError) {
+	if s.UnavailableOfferingsTTL < time.Second {
+		errs = errs.Also(apis.ErrInvalidValue("cannot be less than 1 second", "unavailableOfferingsTTL"))
+	}
+	if s.UnavailableOfferingsMaxTTL < 0 {
+		errs = errs.Also(apis.ErrInvalidValue("cannot be negative", "unavailableOfferingsMaxTTL"))
+	}
+	if s.UnavailableOfferingsMaxTTL > 0 && s.UnavailableOfferingsMaxTTL < s.UnavailableOfferingsTTL {
+		errs = errs.Also(apis.ErrInvalidValue("cannot be less than unavailableOfferingsTTL", "unavailableOfferingsMaxTTL"))
+	}
+	return errs
+}
+
+func (s Settings) validateOfferingsRefreshInterval() (errs *apis.FieldError) {
+	if s.OfferingsRefreshInterval < time.Minute {
+		return errs.Also(apis.ErrInvalidValue("cannot be less than 1 minute", "offeringsRefreshInterval"))
+	}
+	return nil
+}
@@ -54,24 +54,85 @@ var _ = Describe("Validation", func() {
 		Expect(s.EnableENILimitedPodDensity).To(BeTrue())
 		Expect(s.IsolatedVPC).To(BeFalse())
 		Expect(s.VMMemoryOverheadPercent).To(Equal(0.075))
+		Expect(s.VMMemoryOverheadByInstanceFamily).To(BeEmpty())
+		Expect(s.VMMemoryOverheadByAMIFamily).To(BeEmpty())
 		Expect(len(s.Tags)).To(BeZero())
 		Expect(s.ReservedENIs).To(Equal(0))
+		Expect(s.APIRecordFile).To(Equal(""))
+		Expect(s.APIReplayFile).To(Equal(""))
+		Expect(s.EnableComputeOptimizer).To(BeFalse())
+		Expect(s.ComputeOptimizerPriceBias).To(BeZero())
+		Expect(s.EnforceMetadataOptions).To(BeFalse())
+		Expect(s.RequireEBSEncryption).To(BeFalse())
+		Expect(s.SpotMinPools).To(BeZero())
+		Expect(s.LifecycleWebhookURL).To(Equal(""))
+		Expect(s.LifecycleEventBusName).To(Equal(""))
+		Expect(s.SubnetRebalancingThreshold).To(BeZero())
+		Expect(s.TerminationRecordTTL).To(BeZero())
+		Expect(s.SpotPlacementScoreTargetCapacity).To(BeZero())
+		Expect(s.AMISelectorMaxImages).To(Equal(10000))
+		Expect(s.DeniedAMIIDs).To(BeEmpty())
+		Expect(s.DeniedAMINames).To(BeEmpty())
+		Expect(s.DeniedAMIOwners).To(BeEmpty())
+		Expect(s.ProvisioningTriggerQueueName).To(BeEmpty())
+		Expect(s.Airgapped).To(BeFalse())
+		Expect(s.NodeTerminationHandlerParity).To(BeFalse())
+		Expect(s.ValidateTagPolicies).To(BeFalse())
+		Expect(s.VerifySSMAgentRegistration).To(BeFalse())
+		Expect(s.DisabledManagedTags).To(BeEmpty())
+		Expect(s.ExcludedInstanceClasses).To(BeEmpty())
+		Expect(s.OfferingScorePriceTolerance).To(BeZero())
+		Expect(s.UnavailableOfferingsTTL).To(Equal(3 * time.Minute))
+		Expect(s.UnavailableOfferingsMaxTTL).To(BeZero())
+		Expect(s.EnableICECacheEndpoint).To(BeFalse())
+		Expect(s.EnableVPCCNIPodDensity).To(BeFalse())
+		Expect(s.OfferingsRefreshInterval).To(Equal(5 * time.Minute))
 	})
 	It("should succeed to set custom values", func() {
 		cm := &v1.ConfigMap{
 			Data: map[string]string{
-				"aws.assumeRoleARN":              "arn:aws:iam::111222333444:role/testrole",
-				"aws.assumeRoleDuration":         "27m",
-				"aws.clusterCABundle":            "ca-bundle",
-				"aws.clusterEndpoint":            "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
-				"aws.clusterName":                "my-cluster",
-				"aws.defaultInstanceProfile":     "karpenter",
-				"aws.enablePodENI":               "true",
-				"aws.enableENILimitedPodDensity": "false",
-				"aws.isolatedVPC":                "true",
-				"aws.vmMemoryOverheadPercent":    "0.1",
-				"aws.tags":                       `{"tag1": "value1", "tag2": "value2", "example.com/tag": "my-value"}`,
-				"aws.reservedENIs":               "1",
+				"aws.assumeRoleARN":                    "arn:aws:iam::111222333444:role/testrole",
+				"aws.assumeRoleDuration":               "27m",
+				"aws.clusterCABundle":                  "ca-bundle",
+				"aws.clusterEndpoint":                  "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                      "my-cluster",
+				"aws.defaultInstanceProfile":           "karpenter",
+				"aws.enablePodENI":                     "true",
+				"aws.enableENILimitedPodDensity":       "false",
+				"aws.isolatedVPC":                      "true",
+				"aws.vmMemoryOverheadPercent":          "0.1",
+				"aws.vmMemoryOverheadByInstanceFamily": `{"m5": 0.05, "m5.metal": 0.01}`,
+				"aws.vmMemoryOverheadByAMIFamily":      `{"Windows2022": 0.12}`,
+				"aws.tags":                             `{"tag1": "value1", "tag2": "value2", "example.com/tag": "my-value"}`,
+				"aws.reservedENIs":                     "1",
+				"aws.apiRecordFile":                    "/tmp/karpenter-api.jsonl",
+				"aws.enableComputeOptimizer":           "true",
+				"aws.computeOptimizerPriceBias":        "0.2",
+				"aws.enforceMetadataOptions":           "true",
+				"aws.requireEBSEncryption":             "true",
+				"aws.spotMinPools":                     "10",
+				"aws.lifecycleWebhookURL":              "https://cmdb.example.com/karpenter",
+				"aws.lifecycleEventBusName":            "karpenter-lifecycle",
+				"aws.subnetRebalancingThreshold":       "0.8",
+				"aws.terminationRecordTTL":             "24h",
+				"aws.spotPlacementScoreTargetCapacity": "50",
+				"aws.amiSelectorMaxImages":             "10000",
+				"aws.deniedAMIIDs":                     "ami-0123456789abcdef0, ami-0fedcba9876543210",
+				"aws.deniedAMINames":                   "*-rc*,",
+				"aws.deniedAMIOwners":                  "111122223333",
+				"aws.provisioningTriggerQueueName":     "karpenter-triggers",
+				"aws.airgapped":                        "true",
+				"aws.nodeTerminationHandlerParity":     "true",
+				"aws.validateTagPolicies":              "true",
+				"aws.verifySSMAgentRegistration":       "true",
+				"aws.disabledManagedTags":              "Name, karpenter.sh/managed-by",
+				"aws.excludedInstanceClasses":          "metal, previous-generation",
+				"aws.offeringScorePriceTolerance":      "0.1",
+				"aws.unavailableOfferingsTTL":          "5m",
+				"aws.unavailableOfferingsMaxTTL":       "1h",
+				"aws.enableICECacheEndpoint":           "true",
+				"aws.enableVPCCNIPodDensity":           "true",
+				"aws.offeringsRefreshInterval":         "15m",
 			},
 		}
 		ctx, err := (&settings.Settings{}).Inject(ctx, cm)
@@ -85,11 +146,41 @@ var _ = Describe("Validation", func() {
 		Expect(s.EnableENILimitedPodDensity).To(BeFalse())
 		Expect(s.IsolatedVPC).To(BeTrue())
 		Expect(s.VMMemoryOverheadPercent).To(Equal(0.1))
+		Expect(s.VMMemoryOverheadByInstanceFamily).To(Equal(map[string]float64{"m5": 0.05, "m5.metal": 0.01}))
+		Expect(s.VMMemoryOverheadByAMIFamily).To(Equal(map[string]float64{"Windows2022": 0.12}))
 		Expect(len(s.Tags)).To(Equal(3))
 		Expect(s.Tags).To(HaveKeyWithValue("tag1", "value1"))
 		Expect(s.Tags).To(HaveKeyWithValue("tag2", "value2"))
 		Expect(s.Tags).To(HaveKeyWithValue("example.com/tag", "my-value"))
 		Expect(s.ReservedENIs).To(Equal(1))
+		Expect(s.APIRecordFile).To(Equal("/tmp/karpenter-api.jsonl"))
+		Expect(s.EnableComputeOptimizer).To(BeTrue())
+		Expect(s.ComputeOptimizerPriceBias).To(Equal(0.2))
+		Expect(s.EnforceMetadataOptions).To(BeTrue())
+		Expect(s.RequireEBSEncryption).To(BeTrue())
+		Expect(s.SpotMinPools).To(Equal(10))
+		Expect(s.LifecycleWebhookURL).To(Equal("https://cmdb.example.com/karpenter"))
+		Expect(s.LifecycleEventBusName).To(Equal("karpenter-lifecycle"))
+		Expect(s.SubnetRebalancingThreshold).To(Equal(0.8))
+		Expect(s.TerminationRecordTTL).To(Equal(24 * time.Hour))
+		Expect(s.SpotPlacementScoreTargetCapacity).To(Equal(50))
+		Expect(s.AMISelectorMaxImages).To(Equal(10000))
+		Expect(s.DeniedAMIIDs).To(ConsistOf("ami-0123456789abcdef0", "ami-0fedcba9876543210"))
+		Expect(s.DeniedAMINames).To(ConsistOf("*-rc*"))
+		Expect(s.DeniedAMIOwners).To(ConsistOf("111122223333"))
+		Expect(s.ProvisioningTriggerQueueName).To(Equal("karpenter-triggers"))
+		Expect(s.Airgapped).To(BeTrue())
+		Expect(s.NodeTerminationHandlerParity).To(BeTrue())
+		Expect(s.ValidateTagPolicies).To(BeTrue())
+		Expect(s.VerifySSMAgentRegistration).To(BeTrue())
+		Expect(s.DisabledManagedTags).To(ConsistOf("Name", "karpenter.sh/managed-by"))
+		Expect(s.ExcludedInstanceClasses).To(ConsistOf("metal", "previous-generation"))
+		Expect(s.OfferingScorePriceTolerance).To(Equal(0.1))
+		Expect(s.UnavailableOfferingsTTL).To(Equal(5 * time.Minute))
+		Expect(s.UnavailableOfferingsMaxTTL).To(Equal(time.Hour))
+		Expect(s.EnableICECacheEndpoint).To(BeTrue())
+		Expect(s.EnableVPCCNIPodDensity).To(BeTrue())
+		Expect(s.OfferingsRefreshInterval).To(Equal(15 * time.Minute))
 	})
 	It("should succeed when setting values that no longer exist (backwards compatibility)", func() {
 		cm := &v1.ConfigMap{
@@ -166,6 +257,47 @@ var _ = Describe("Validation", func() {
 		_, err := (&settings.Settings{}).Inject(ctx, cm)
 		Expect(err).To(HaveOccurred())
 	})
+	It("should fail validation when the vmMemoryOverhead of an instance family is not less than 1", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":                      "my-cluster",
+				"aws.vmMemoryOverheadByInstanceFamily": `{"m5": 1}`,
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when the vmMemoryOverhead of an AMI family is negative", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":                 "my-cluster",
+				"aws.vmMemoryOverheadByAMIFamily": `{"AL2": -0.01}`,
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when the vmMemoryOverhead of an unknown AMI family is set", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":                 "my-cluster",
+				"aws.vmMemoryOverheadByAMIFamily": `{"Windows": 0.1}`,
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when computeOptimizerPriceBias is negative", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":           "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":               "my-cluster",
+				"aws.computeOptimizerPriceBias": "-0.1",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
 	It("should fail validation when tags have keys that are in the restricted set of keys", func() {
 		cm := &v1.ConfigMap{
 			Data: map[string]string{
@@ -207,6 +339,169 @@ var _ = Describe("Validation", func() {
 		_, err := (&settings.Settings{}).Inject(ctx, cm)
 		Expect(err).To(HaveOccurred())
 	})
+	It("should fail validation with spotMinPools is negative", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.spotMinPools": "-1",
+				"aws.clusterName":  "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with lifecycleWebhookURL that isn't an http(s) URL", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.lifecycleWebhookURL": "cmdb.example.com/karpenter",
+				"aws.clusterName":         "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with subnetRebalancingThreshold greater than 1", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.subnetRebalancingThreshold": "1.5",
+				"aws.clusterName":                "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with offeringScorePriceTolerance greater than 1", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.offeringScorePriceTolerance": "1.5",
+				"aws.clusterName":                 "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with an unavailableOfferingsTTL less than 1 second", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.unavailableOfferingsTTL": "0s",
+				"aws.clusterName":             "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with an unavailableOfferingsMaxTTL less than the unavailableOfferingsTTL", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.unavailableOfferingsTTL":    "5m",
+				"aws.unavailableOfferingsMaxTTL": "1m",
+				"aws.clusterName":                "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with an offeringsRefreshInterval less than 1 minute", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.offeringsRefreshInterval": "30s",
+				"aws.clusterName":              "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with a negative terminationRecordTTL", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.terminationRecordTTL": "-1h",
+				"aws.clusterName":          "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with a negative spotPlacementScoreTargetCapacity", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.spotPlacementScoreTargetCapacity": "-1",
+				"aws.clusterName":                      "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with a negative amiSelectorMaxImages", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.amiSelectorMaxImages": "-1",
+				"aws.clusterName":          "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with a zero amiSelectorMaxImages", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.amiSelectorMaxImages": "0",
+				"aws.clusterName":          "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with an invalid deniedAMIIDs entry", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.deniedAMIIDs": "ami-0123456789abcdef0,my-ami",
+				"aws.clusterName":  "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when disabling a tag that can't be disabled", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.disabledManagedTags": "Name,karpenter.k8s.aws/cluster",
+				"aws.clusterName":         "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when disabling the tags that launch templates and instance owners are discovered by", func() {
+		for _, tag := range []string{"kubernetes.io/cluster", "karpenter.sh/provisioner-name"} {
+			cm := &v1.ConfigMap{
+				Data: map[string]string{
+					"aws.disabledManagedTags": tag,
+					"aws.clusterName":         "my-cluster",
+				},
+			}
+			_, err := (&settings.Settings{}).Inject(ctx, cm)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+	It("should fail validation when excluding an unknown instance class", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.excludedInstanceClasses": "metal,gpu",
+				"aws.clusterName":             "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation with an invalid deniedAMIOwners entry", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.deniedAMIOwners": "amazon",
+				"aws.clusterName":     "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
 	It("should fail validation with assumeDurationRole is less then 15m", func() {
 		cm := &v1.ConfigMap{
 			Data: map[string]string{
@@ -217,4 +512,15 @@ var _ = Describe("Validation", func() {
 		_, err := (&settings.Settings{}).Inject(ctx, cm)
 		Expect(err).To(HaveOccurred())
 	})
+	It("should fail validation when both apiRecordFile and apiReplayFile are set", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.apiRecordFile": "/tmp/record.jsonl",
+				"aws.apiReplayFile": "/tmp/replay.jsonl",
+				"aws.clusterName":   "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
 })
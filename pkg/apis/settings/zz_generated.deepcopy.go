@@ -23,6 +23,20 @@ import ()
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Settings) DeepCopyInto(out *Settings) {
 	*out = *in
+	if in.VMMemoryOverheadByInstanceFamily != nil {
+		in, out := &in.VMMemoryOverheadByInstanceFamily, &out.VMMemoryOverheadByInstanceFamily
+		*out = make(map[string]float64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.VMMemoryOverheadByAMIFamily != nil {
+		in, out := &in.VMMemoryOverheadByAMIFamily, &out.VMMemoryOverheadByAMIFamily
+		*out = make(map[string]float64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Tags != nil {
 		in, out := &in.Tags, &out.Tags
 		*out = make(map[string]string, len(*in))
@@ -30,6 +44,31 @@ func (in *Settings) DeepCopyInto(out *Settings) {
 			(*out)[key] = val
 		}
 	}
+	if in.DeniedAMIIDs != nil {
+		in, out := &in.DeniedAMIIDs, &out.DeniedAMIIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedAMINames != nil {
+		in, out := &in.DeniedAMINames, &out.DeniedAMINames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedAMIOwners != nil {
+		in, out := &in.DeniedAMIOwners, &out.DeniedAMIOwners
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DisabledManagedTags != nil {
+		in, out := &in.DisabledManagedTags, &out.DisabledManagedTags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludedInstanceClasses != nil {
+		in, out := &in.ExcludedInstanceClasses, &out.ExcludedInstanceClasses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Settings.
@@ -17,21 +17,55 @@ package v1alpha1
 import (
 	"context"
 	"fmt"
+	"net"
+	"path"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/samber/lo"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/pkg/apis"
 
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 )
 
 const (
-	userDataPath    = "userData"
-	amiSelectorPath = "amiSelector"
+	userDataPath                      = "userData"
+	amiSelectorPath                   = "amiSelector"
+	amiRolloutPath                    = "amiRollout"
+	amiVariantPath                    = "amiVariant"
+	ubuntuStreamPath                  = "ubuntuStream"
+	trustedBootPath                   = "trustedBoot"
+	cloudWatchAgentPath               = "cloudWatchAgent"
+	domainJoinPath                    = "domainJoin"
+	neuronPath                        = "neuron"
+	cpuOptionsPath                    = "cpuOptions"
+	maintenanceWindowsPath            = "maintenanceWindows"
+	registrationTTLPath               = "registrationTTL"
+	minimumNodeLifetimePath           = "minimumNodeLifetime"
+	scheduledCapacityReservationsPath = "scheduledCapacityReservations"
+	instanceTypeOverridesPath         = "instanceTypeOverrides"
+	kubeReservedPath                  = "kubeReserved"
+	excludedInstanceClassesPath       = "excludedInstanceClasses"
+	capacityTypeOverridesPath         = "capacityTypeOverrides"
+
+	maintenanceWindowStartFormat = "15:04"
+	maxMaintenanceWindowDuration = 7 * 24 * time.Hour
+	// maxRegistrationTTL bounds the registration TTL, since nodes that take longer than this to register are stuck
+	maxRegistrationTTL                      = time.Hour
+	maxScheduledCapacityReservationDuration = 24 * time.Hour
 )
 
 var (
 	amiRegex = regexp.MustCompile("ami-[0-9a-z]+")
+	weekdays = lo.Times(7, func(i int) string { return time.Weekday(i).String() })
 )
 
 func (a *AWSNodeTemplate) SupportedVerbs() []admissionregistrationv1.OperationType {
@@ -53,8 +87,24 @@ func (a *AWSNodeTemplateSpec) validate(_ context.Context) (errs *apis.FieldError
 		a.AWS.Validate(),
 		a.validateUserData(),
 		a.validateAMISelector(),
+		a.validateAMIRollout(),
 		a.validateAMIFamily(),
+		a.validateAMIVariant(),
+		a.validateUbuntuStream(),
+		a.validateTrustedBoot(),
 		a.validateTags(),
+		a.validateCloudWatchAgent(),
+		a.validateDomainJoin(),
+		a.validateNeuron(),
+		a.validateCPUOptions(),
+		a.validateMaintenanceWindows(),
+		a.validateRegistrationTTL(),
+		a.validateMinimumNodeLifetime(),
+		a.validateScheduledCapacityReservations(),
+		a.validateInstanceTypeOverrides(),
+		a.validateKubeReserved(),
+		a.validateExcludedInstanceClasses(),
+		a.validateCapacityTypeOverrides(),
 	)
 }
 
@@ -78,7 +128,26 @@ func (a *AWSNodeTemplateSpec) validateAMIFamily() (errs *apis.FieldError) {
 	return errs
 }
 
-//nolint:gocyclo
+func (a *AWSNodeTemplateSpec) validateAMIVariant() (errs *apis.FieldError) {
+	if a.AMIVariant == nil {
+		return nil
+	}
+	if !lo.Contains([]string{AMIFamilyAL2, AMIFamilyBottlerocket}, lo.FromPtrOr(a.AMIFamily, AMIFamilyAL2)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("amiVariant is not supported for amiFamily %s", lo.FromPtrOr(a.AMIFamily, AMIFamilyAL2)), amiVariantPath))
+	}
+	return errs.Also(a.validateStringEnum(*a.AMIVariant, amiVariantPath, []string{AMIVariantFIPS}))
+}
+
+func (a *AWSNodeTemplateSpec) validateUbuntuStream() (errs *apis.FieldError) {
+	if a.UbuntuStream == nil {
+		return nil
+	}
+	if lo.FromPtrOr(a.AMIFamily, AMIFamilyAL2) != AMIFamilyUbuntu {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("ubuntuStream is not supported for amiFamily %s", lo.FromPtrOr(a.AMIFamily, AMIFamilyAL2)), ubuntuStreamPath))
+	}
+	return errs.Also(a.validateStringEnum(*a.UbuntuStream, ubuntuStreamPath, []string{UbuntuStreamStandard, UbuntuStreamPro, UbuntuStreamMinimal}))
+}
+
 func (a *AWSNodeTemplateSpec) validateAMISelector() (errs *apis.FieldError) {
 	if a.AMISelector == nil {
 		return nil
@@ -86,30 +155,95 @@ func (a *AWSNodeTemplateSpec) validateAMISelector() (errs *apis.FieldError) {
 	if a.LaunchTemplateName != nil {
 		errs = errs.Also(apis.ErrMultipleOneOf(amiSelectorPath, launchTemplatePath))
 	}
+	return errs.Also(validateAMISelectorFilters(a.AMISelector, amiSelectorPath))
+}
+
+func (a *AWSNodeTemplateSpec) validateAMIRollout() (errs *apis.FieldError) {
+	if a.AMIRollout == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(amiRolloutPath, launchTemplatePath))
+	}
+	if len(a.AMIRollout.AMISelector) == 0 {
+		errs = errs.Also(apis.ErrMissingField(amiSelectorPath).ViaField(amiRolloutPath))
+	}
+	if a.AMIRollout.Weight < 0 || a.AMIRollout.Weight > 100 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(a.AMIRollout.Weight, 0, 100, "weight").ViaField(amiRolloutPath))
+	}
+	return errs.Also(validateAMISelectorFilters(a.AMIRollout.AMISelector, amiSelectorPath).ViaField(amiRolloutPath))
+}
+
+//nolint:gocyclo
+func validateAMISelectorFilters(amiSelector map[string]string, fieldPath string) (errs *apis.FieldError) {
 	var idFilterKeyUsed string
-	for key, value := range a.AMISelector {
+	filterKeys := 0
+	for key, value := range amiSelector {
 		if key == "" || value == "" {
-			errs = errs.Also(apis.ErrInvalidValue("\"\"", fmt.Sprintf("%s['%s']", amiSelectorPath, key)))
+			errs = errs.Also(apis.ErrInvalidValue("\"\"", fmt.Sprintf("%s['%s']", fieldPath, key)))
+		}
+		// The creation date window narrows the images selected by the other filters, and the role is assumed to describe
+		// them, rather than either being a filter itself
+		switch key {
+		case "aws::minCreationDate", "aws::maxCreationDate":
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q must be an RFC 3339 date", value), fmt.Sprintf("%s['%s']", fieldPath, key)))
+			}
+			continue
+		case "aws::maxAge":
+			if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+				errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q must be a duration greater than 0s", value), fmt.Sprintf("%s['%s']", fieldPath, key)))
+			}
+			continue
+		case "aws::roleArn":
+			if !validRoleARN(value) {
+				errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q must be the arn of an iam role", value), fmt.Sprintf("%s['%s']", fieldPath, key)))
+			}
+			continue
 		}
+		filterKeys++
 		if key == "aws-ids" || key == "aws::ids" {
 			idFilterKeyUsed = key
 			for _, amiID := range functional.SplitCommaSeparatedString(value) {
 				if !amiRegex.MatchString(amiID) {
 					fieldValue := fmt.Sprintf("\"%s\"", amiID)
-					message := fmt.Sprintf("%s['%s'] must be a valid ami-id (regex: %s)", amiSelectorPath, key, amiRegex.String())
+					message := fmt.Sprintf("%s['%s'] must be a valid ami-id (regex: %s)", fieldPath, key, amiRegex.String())
 					errs = errs.Also(apis.ErrInvalidValue(fieldValue, message))
 				}
 			}
 		}
+		if key == "aws::imageBuilderArn" {
+			for _, imageBuilderARN := range functional.SplitCommaSeparatedString(value) {
+				if !validImageBuilderARN(imageBuilderARN) {
+					errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q must be the arn of an image builder image pipeline or image recipe", imageBuilderARN), fmt.Sprintf("%s['%s']", fieldPath, key)))
+				}
+			}
+		}
 	}
-	if idFilterKeyUsed != "" && len(a.AMISelector) > 1 {
-		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%q filter is mutually exclusive, cannot be set with a combination of other filters in", idFilterKeyUsed), amiSelectorPath))
+	_, imageBuilderARNUsed := amiSelector["aws::imageBuilderArn"]
+	if _, ssmUsed := amiSelector["aws::ssm"]; imageBuilderARNUsed && ssmUsed {
+		errs = errs.Also(apis.ErrGeneric(`"aws::imageBuilderArn" filter is mutually exclusive with "aws::ssm" in`, fieldPath))
+	}
+	if idFilterKeyUsed != "" && filterKeys > 1 {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%q filter is mutually exclusive, cannot be set with a combination of other filters in", idFilterKeyUsed), fieldPath))
+	}
+	if filterKeys == 0 && len(amiSelector) > 0 {
+		errs = errs.Also(apis.ErrGeneric("expected at least one filter other than the creation date window and role", fieldPath))
+	}
+	if minCreationDate, err := time.Parse(time.RFC3339, amiSelector["aws::minCreationDate"]); err == nil {
+		if maxCreationDate, err := time.Parse(time.RFC3339, amiSelector["aws::maxCreationDate"]); err == nil && maxCreationDate.Before(minCreationDate) {
+			errs = errs.Also(apis.ErrGeneric("aws::maxCreationDate must not be before aws::minCreationDate", fieldPath))
+		}
 	}
 	return errs
 }
 
 func (a *AWSNodeTemplateSpec) validateTags() (errs *apis.FieldError) {
-	for k := range a.Tags {
+	return validateRestrictedTags(a.Tags)
+}
+
+func validateRestrictedTags(tags map[string]string) (errs *apis.FieldError) {
+	for k := range tags {
 		for _, pattern := range RestrictedTagPatterns {
 			if pattern.MatchString(k) {
 				errs = errs.Also(apis.ErrInvalidKeyName(k, "tags", fmt.Sprintf("tag contains a restricted tag matching %q", pattern.String())))
@@ -118,3 +252,328 @@ func (a *AWSNodeTemplateSpec) validateTags() (errs *apis.FieldError) {
 	}
 	return errs
 }
+
+func (a *AWSNodeTemplateSpec) validateCloudWatchAgent() (errs *apis.FieldError) {
+	if a.CloudWatchAgent == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(cloudWatchAgentPath, launchTemplatePath))
+	}
+	amiFamily := lo.FromPtrOr(a.AMIFamily, AMIFamilyAL2)
+	agentType := lo.FromPtrOr(a.CloudWatchAgent.Type, CloudWatchAgentTypeCloudWatchAgent)
+	if !SupportedCloudWatchAgentTypesByAMIFamily[amiFamily].Has(agentType) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s is not supported for amiFamily %s", agentType, amiFamily), "type").ViaField(cloudWatchAgentPath))
+	}
+	if a.CloudWatchAgent.Config != nil && a.CloudWatchAgent.ConfigSSMParameter != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf("config", "configSSMParameter").ViaField(cloudWatchAgentPath))
+	} else if a.CloudWatchAgent.Config == nil && a.CloudWatchAgent.ConfigSSMParameter == nil {
+		errs = errs.Also(apis.ErrMissingOneOf("config", "configSSMParameter").ViaField(cloudWatchAgentPath))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateDomainJoin() (errs *apis.FieldError) {
+	if a.DomainJoin == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(domainJoinPath, launchTemplatePath))
+	}
+	return errs.Also(a.validateDomainJoinFields().ViaField(domainJoinPath))
+}
+
+func (a *AWSNodeTemplateSpec) validateDomainJoinFields() (errs *apis.FieldError) {
+	if !lo.Contains([]string{AMIFamilyWindows2019, AMIFamilyWindows2022}, lo.FromPtrOr(a.AMIFamily, AMIFamilyAL2)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("domain join is not supported for amiFamily %s", lo.FromPtrOr(a.AMIFamily, AMIFamilyAL2))))
+	}
+	dj := a.DomainJoin
+	if dj.DirectoryID != nil && dj.SSMDocument != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf("directoryID", "ssmDocument"))
+	} else if dj.DirectoryID == nil && dj.SSMDocument == nil {
+		errs = errs.Also(apis.ErrMissingOneOf("directoryID", "ssmDocument"))
+	}
+	if dj.DirectoryID != nil && dj.DirectoryName == nil {
+		errs = errs.Also(apis.ErrMissingField("directoryName"))
+	}
+	if dj.DirectoryID == nil && (dj.DirectoryName != nil || len(dj.DNSIPAddresses) > 0) {
+		errs = errs.Also(apis.ErrGeneric("directoryName and dnsIPAddresses require directoryID", "directoryName", "dnsIPAddresses"))
+	}
+	if dj.SSMDocument == nil && len(dj.SSMDocumentParameters) > 0 {
+		errs = errs.Also(apis.ErrGeneric("ssmDocumentParameters requires ssmDocument", "ssmDocumentParameters"))
+	}
+	for i, ip := range dj.DNSIPAddresses {
+		if net.ParseIP(ip) == nil {
+			errs = errs.Also(apis.ErrInvalidArrayValue(ip, "dnsIPAddresses", i))
+		}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateTrustedBoot() (errs *apis.FieldError) {
+	if a.TrustedBoot == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(trustedBootPath, launchTemplatePath))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateNeuron() (errs *apis.FieldError) {
+	if a.Neuron == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(neuronPath, launchTemplatePath))
+	}
+	if !lo.Contains([]string{AMIFamilyAL2, AMIFamilyUbuntu}, lo.FromPtrOr(a.AMIFamily, AMIFamilyAL2)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("neuron is not supported for amiFamily %s", lo.FromPtrOr(a.AMIFamily, AMIFamilyAL2)), neuronPath))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateCPUOptions() (errs *apis.FieldError) {
+	if a.CPUOptions == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(cpuOptionsPath, launchTemplatePath))
+	}
+	// EC2 Fleet selects instance types by their default vCPUs, rather than by the vCPUs that the CPU options leave them
+	if lo.FromPtr(a.InstanceSelectionMode) == InstanceSelectionModeAttributeBased {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cpuOptions are not supported for instanceSelectionMode %s", InstanceSelectionModeAttributeBased), cpuOptionsPath))
+	}
+	// The CPU options determine the vCPU capacity of instance types, and launch templates derive the number of cores
+	// or threads per core that isn't set from it
+	if _, ok := lo.Find(a.InstanceTypeOverrides, func(o InstanceTypeOverride) bool { _, ok := o.Capacity[v1.ResourceCPU]; return ok }); ok {
+		errs = errs.Also(apis.ErrGeneric("cpu capacity can't be overridden by instanceTypeOverrides when cpuOptions are set", cpuOptionsPath))
+	}
+	return errs.Also(a.CPUOptions.validate().ViaField(cpuOptionsPath))
+}
+
+func (c *CPUOptions) validate() (errs *apis.FieldError) {
+	if c.CoreCount != nil && *c.CoreCount < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*c.CoreCount, "coreCount", "must be at least 1"))
+	}
+	if c.ThreadsPerCore != nil && !lo.Contains([]int64{1, 2}, *c.ThreadsPerCore) {
+		errs = errs.Also(apis.ErrInvalidValue(*c.ThreadsPerCore, "threadsPerCore", "must be 1 or 2"))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateMaintenanceWindows() (errs *apis.FieldError) {
+	for i, window := range a.MaintenanceWindows {
+		errs = errs.Also(window.validate().ViaFieldIndex(maintenanceWindowsPath, i))
+	}
+	return errs
+}
+
+func (w *MaintenanceWindow) validate() (errs *apis.FieldError) {
+	for i, day := range w.Days {
+		if !lo.Contains(weekdays, day) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(day, "days", i))
+		}
+	}
+	if _, err := time.Parse(maintenanceWindowStartFormat, w.Start); err != nil {
+		errs = errs.Also(apis.ErrInvalidValue(w.Start, "start"))
+	}
+	if w.Duration.Duration <= 0 || w.Duration.Duration > maxMaintenanceWindowDuration {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(w.Duration.Duration, "0s", maxMaintenanceWindowDuration, "duration"))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateRegistrationTTL() (errs *apis.FieldError) {
+	if a.RegistrationTTL == nil {
+		return nil
+	}
+	if a.RegistrationTTL.Duration <= 0 || a.RegistrationTTL.Duration > maxRegistrationTTL {
+		return errs.Also(apis.ErrOutOfBoundsValue(a.RegistrationTTL.Duration, "0s", maxRegistrationTTL, registrationTTLPath))
+	}
+	return nil
+}
+
+func (a *AWSNodeTemplateSpec) validateMinimumNodeLifetime() (errs *apis.FieldError) {
+	if a.MinimumNodeLifetime == nil {
+		return nil
+	}
+	if a.MinimumNodeLifetime.Duration <= 0 {
+		return errs.Also(apis.ErrInvalidValue(a.MinimumNodeLifetime.Duration, minimumNodeLifetimePath, "must be greater than 0s"))
+	}
+	return nil
+}
+
+func (a *AWSNodeTemplateSpec) validateScheduledCapacityReservations() (errs *apis.FieldError) {
+	if len(a.ScheduledCapacityReservations) == 0 {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(scheduledCapacityReservationsPath, launchTemplatePath))
+	}
+	for i, reservation := range a.ScheduledCapacityReservations {
+		errs = errs.Also(reservation.validate().ViaFieldIndex(scheduledCapacityReservationsPath, i))
+	}
+	return errs
+}
+
+func (r *ScheduledCapacityReservation) validate() (errs *apis.FieldError) {
+	if r.InstanceType == "" {
+		errs = errs.Also(apis.ErrMissingField("instanceType"))
+	}
+	if r.AvailabilityZone == "" {
+		errs = errs.Also(apis.ErrMissingField("availabilityZone"))
+	}
+	if r.InstanceCount < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(r.InstanceCount, "instanceCount", "must be at least 1"))
+	}
+	for i, day := range r.Days {
+		if !lo.Contains(weekdays, day) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(day, "days", i))
+		}
+	}
+	if _, err := time.Parse(maintenanceWindowStartFormat, r.Start); err != nil {
+		errs = errs.Also(apis.ErrInvalidValue(r.Start, "start"))
+	}
+	if r.Duration.Duration <= 0 || r.Duration.Duration > maxScheduledCapacityReservationDuration {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(r.Duration.Duration, "0s", maxScheduledCapacityReservationDuration, "duration"))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateInstanceTypeOverrides() (errs *apis.FieldError) {
+	if len(a.InstanceTypeOverrides) == 0 {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(instanceTypeOverridesPath, launchTemplatePath))
+	}
+	for i, override := range a.InstanceTypeOverrides {
+		errs = errs.Also(override.validate().ViaFieldIndex(instanceTypeOverridesPath, i))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateKubeReserved() (errs *apis.FieldError) {
+	if a.KubeReserved == nil {
+		return nil
+	}
+	formula := lo.FromPtr(a.KubeReserved.Formula)
+	if a.KubeReserved.Formula != nil {
+		errs = errs.Also(a.validateStringEnum(formula, "formula", []string{KubeReservedFormulaEKS, KubeReservedFormulaGKE, KubeReservedFormulaCustom}).ViaField(kubeReservedPath))
+	}
+	if a.LaunchTemplateName != nil && formula != "" && formula != KubeReservedFormulaEKS {
+		errs = errs.Also(apis.ErrMultipleOneOf(kubeReservedPath, launchTemplatePath))
+	}
+	return errs.Also(a.KubeReserved.validate(formula).ViaField(kubeReservedPath))
+}
+
+func (k *KubeReserved) validate(formula string) (errs *apis.FieldError) {
+	if formula != KubeReservedFormulaCustom {
+		if len(k.CPU) > 0 || len(k.Memory) > 0 || k.MemoryPerPod != nil || k.BaseMemory != nil || k.EphemeralStorage != nil {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("steps and quantities are only supported by the %s formula", KubeReservedFormulaCustom)))
+		}
+		return errs
+	}
+	errs = errs.Also(validateReservedSteps(k.CPU).ViaField("cpu"), validateReservedSteps(k.Memory).ViaField("memory"))
+	for field, quantity := range map[string]*resource.Quantity{"memoryPerPod": k.MemoryPerPod, "baseMemory": k.BaseMemory, "ephemeralStorage": k.EphemeralStorage} {
+		if quantity != nil && quantity.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(quantity.String(), field, "must not be negative"))
+		}
+	}
+	return errs
+}
+
+func validateReservedSteps(steps []ReservedStep) (errs *apis.FieldError) {
+	for i, step := range steps {
+		if ratio, err := strconv.ParseFloat(step.Ratio, 64); err != nil || ratio < 0 || ratio > 1 {
+			errs = errs.Also(apis.ErrInvalidValue(step.Ratio, "ratio", "must be a decimal between 0 and 1").ViaIndex(i))
+		}
+		switch {
+		case step.UpTo == nil && i != len(steps)-1:
+			errs = errs.Also(apis.ErrMissingField("upTo").ViaIndex(i))
+		case step.UpTo != nil && step.UpTo.Sign() <= 0:
+			errs = errs.Also(apis.ErrInvalidValue(step.UpTo.String(), "upTo", "must be positive").ViaIndex(i))
+		case step.UpTo != nil && i > 0 && steps[i-1].UpTo != nil && step.UpTo.Cmp(*steps[i-1].UpTo) <= 0:
+			errs = errs.Also(apis.ErrInvalidValue(step.UpTo.String(), "upTo", "must be greater than the upTo of the previous step").ViaIndex(i))
+		}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateExcludedInstanceClasses() (errs *apis.FieldError) {
+	for i, class := range a.ExcludedInstanceClasses {
+		errs = errs.Also(a.validateStringEnum(class, apis.CurrentField, InstanceClasses).ViaFieldIndex(excludedInstanceClassesPath, i))
+	}
+	return errs
+}
+
+func (o *InstanceTypeOverride) validate() (errs *apis.FieldError) {
+	if len(o.InstanceTypes) == 0 {
+		errs = errs.Also(apis.ErrMissingField("instanceTypes"))
+	}
+	for i, instanceType := range o.InstanceTypes {
+		if _, err := path.Match(instanceType, ""); instanceType == "" || err != nil {
+			errs = errs.Also(apis.ErrInvalidArrayValue(instanceType, "instanceTypes", i))
+		}
+	}
+	for name, quantity := range o.Capacity {
+		if quantity.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(quantity.String(), "capacity", fmt.Sprintf("%s must not be negative", name)))
+		}
+	}
+	for name, quantity := range o.SystemReserved {
+		if quantity.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(quantity.String(), "systemReserved", fmt.Sprintf("%s must not be negative", name)))
+		}
+	}
+	if o.VMMemoryOverheadPercent != nil {
+		if percent, err := strconv.ParseFloat(*o.VMMemoryOverheadPercent, 64); err != nil || percent < 0 || percent >= 1 {
+			errs = errs.Also(apis.ErrInvalidValue(*o.VMMemoryOverheadPercent, "vmMemoryOverheadPercent", "must be a decimal of at least 0 and less than 1"))
+		}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateCapacityTypeOverrides() (errs *apis.FieldError) {
+	if len(a.CapacityTypeOverrides) == 0 {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(capacityTypeOverridesPath, launchTemplatePath))
+	}
+	capacityTypes := sets.New[string]()
+	for i, override := range a.CapacityTypeOverrides {
+		if capacityTypes.Has(override.CapacityType) {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("capacity type %q is overridden more than once", override.CapacityType), "capacityType").ViaFieldIndex(capacityTypeOverridesPath, i))
+		}
+		capacityTypes.Insert(override.CapacityType)
+		errs = errs.Also(override.validate().ViaFieldIndex(capacityTypeOverridesPath, i))
+	}
+	return errs
+}
+
+func (o *CapacityTypeOverride) validate() (errs *apis.FieldError) {
+	if o.CapacityType != v1alpha5.CapacityTypeSpot && o.CapacityType != v1alpha5.CapacityTypeOnDemand {
+		errs = errs.Also(apis.ErrInvalidValue(o.CapacityType, "capacityType", fmt.Sprintf("must be one of %s, %s", v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand)))
+	}
+	for k, v := range o.Tags {
+		if k == "" {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf(
+				"the tag with key : '' and value : '%s' is invalid because empty tag keys aren't supported", v), "tags"))
+		}
+	}
+	return errs.Also(validateRestrictedTags(o.Tags))
+}
+
+// validRoleARN returns whether the arn is the arn of an iam role
+func validRoleARN(s string) bool {
+	a, err := arn.Parse(s)
+	return err == nil && a.Service == "iam" && strings.HasPrefix(a.Resource, "role/")
+}
+
+// validImageBuilderARN returns whether the arn is the arn of an image builder image pipeline or image recipe
+func validImageBuilderARN(s string) bool {
+	a, err := arn.Parse(s)
+	return err == nil && a.Service == "imagebuilder" && (strings.HasPrefix(a.Resource, "image-pipeline/") || strings.HasPrefix(a.Resource, "image-recipe/"))
+}
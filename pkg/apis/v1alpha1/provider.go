@@ -117,6 +117,13 @@ type MetadataOptions struct {
 	// 1.0 credentials are not available.
 	// +optional
 	HTTPTokens *string `json:"httpTokens,omitempty"`
+
+	// InstanceMetadataTags enables or disables access to the instance's tags
+	// from the instance metadata service on provisioned nodes. If metadata
+	// options is non-nil, but this parameter is not specified, the default
+	// state is "disabled".
+	// +optional
+	InstanceMetadataTags *string `json:"instanceMetadataTags,omitempty"`
 }
 
 type BlockDeviceMapping struct {
@@ -54,6 +54,7 @@ var (
 	}
 	AMIFamilyBottlerocket = "Bottlerocket"
 	AMIFamilyAL2          = "AL2"
+	AMIFamilyAL2023       = "AL2023"
 	AMIFamilyUbuntu       = "Ubuntu"
 	AMIFamilyWindows2019  = "Windows2019"
 	AMIFamilyWindows2022  = "Windows2022"
@@ -61,6 +62,7 @@ var (
 	SupportedAMIFamilies  = []string{
 		AMIFamilyBottlerocket,
 		AMIFamilyAL2,
+		AMIFamilyAL2023,
 		AMIFamilyUbuntu,
 		AMIFamilyWindows2019,
 		AMIFamilyWindows2022,
@@ -69,9 +71,48 @@ var (
 	SupportedContainerRuntimesByAMIFamily = map[string]sets.Set[string]{
 		AMIFamilyBottlerocket: sets.New("containerd"),
 		AMIFamilyAL2:          sets.New("dockerd", "containerd"),
+		AMIFamilyAL2023:       sets.New("containerd"),
 		AMIFamilyUbuntu:       sets.New("dockerd", "containerd"),
 		AMIFamilyWindows2019:  sets.New("dockerd", "containerd"),
-		AMIFamilyWindows2022:  sets.New("dockerd", "containerd"),
+		AMIFamilyWindows2022:  sets.New("containerd"),
+	}
+	SecurityGroupDriftRemediationReplace = "Replace"
+	SecurityGroupDriftRemediationInPlace = "InPlace"
+	AMIDeprecationPolicyInclude          = "Include"
+	AMIDeprecationPolicyDeprioritize     = "Deprioritize"
+	AMIDeprecationPolicyExclude          = "Exclude"
+	AMISortStrategyNewest                = "newest"
+	AMISortStrategyNameDescending        = "name-descending"
+	AMISortStrategyPinned                = "pinned"
+	InstanceSelectionModeInstanceTypes   = "InstanceTypes"
+	InstanceSelectionModeAttributeBased  = "AttributeBased"
+	KubeReservedFormulaEKS               = "EKS"
+	KubeReservedFormulaGKE               = "GKE"
+	KubeReservedFormulaCustom            = "Custom"
+	AMIVariantFIPS                       = "fips"
+	UbuntuStreamStandard                 = "standard"
+	UbuntuStreamPro                      = "pro"
+	UbuntuStreamMinimal                  = "minimal"
+	CloudWatchAgentTypeCloudWatchAgent   = "CloudWatchAgent"
+	CloudWatchAgentTypeFluentBit         = "FluentBit"
+	InstanceClassMetal                   = "metal"
+	InstanceClassPreviousGeneration      = "previous-generation"
+	InstanceClassBurstable               = "burstable"
+	InstanceClassOddSized                = "odd-sized"
+	// InstanceClasses are the classes of instance types that can be excluded from an AWSNodeTemplate's instance types
+	InstanceClasses = []string{
+		InstanceClassMetal,
+		InstanceClassPreviousGeneration,
+		InstanceClassBurstable,
+		InstanceClassOddSized,
+	}
+	// SupportedCloudWatchAgentTypesByAMIFamily are the agents that can be installed on each AMI family during bootstrap
+	SupportedCloudWatchAgentTypesByAMIFamily = map[string]sets.Set[string]{
+		AMIFamilyAL2:         sets.New(CloudWatchAgentTypeCloudWatchAgent, CloudWatchAgentTypeFluentBit),
+		AMIFamilyAL2023:      sets.New(CloudWatchAgentTypeCloudWatchAgent, CloudWatchAgentTypeFluentBit),
+		AMIFamilyUbuntu:      sets.New(CloudWatchAgentTypeCloudWatchAgent, CloudWatchAgentTypeFluentBit),
+		AMIFamilyWindows2019: sets.New(CloudWatchAgentTypeCloudWatchAgent),
+		AMIFamilyWindows2022: sets.New(CloudWatchAgentTypeCloudWatchAgent),
 	}
 
 	Windows2019                                           = "2019"
@@ -90,6 +131,15 @@ var (
 
 	LabelInstanceHypervisor                   = LabelDomain + "/instance-hypervisor"
 	LabelInstanceEncryptionInTransitSupported = LabelDomain + "/instance-encryption-in-transit-supported"
+	LabelInstanceBootMode                     = LabelDomain + "/instance-boot-mode"
+	LabelInstanceVirtualizationType           = LabelDomain + "/instance-virtualization-type"
+	LabelInstanceRootDeviceType               = LabelDomain + "/instance-root-device-type"
+	LabelInstanceNitroTPMSupported            = LabelDomain + "/instance-nitro-tpm-supported"
+	LabelInstanceEBSNVMeSupported             = LabelDomain + "/instance-ebs-nvme-supported"
+	LabelInstanceEFASupported                 = LabelDomain + "/instance-efa-supported"
+	LabelInstanceDedicatedHostsSupported      = LabelDomain + "/instance-dedicated-hosts-supported"
+	LabelInstanceStoreVolumeCount             = LabelDomain + "/instance-store-volume-count"
+	LabelInstanceStoreVolumeSize              = LabelDomain + "/instance-store-volume-size"
 	LabelInstanceCategory                     = LabelDomain + "/instance-category"
 	LabelInstanceFamily                       = LabelDomain + "/instance-family"
 	LabelInstanceGeneration                   = LabelDomain + "/instance-generation"
@@ -98,6 +148,9 @@ var (
 	LabelInstanceCPU                          = LabelDomain + "/instance-cpu"
 	LabelInstanceMemory                       = LabelDomain + "/instance-memory"
 	LabelInstanceNetworkBandwidth             = LabelDomain + "/instance-network-bandwidth"
+	LabelInstanceEBSBandwidth                 = LabelDomain + "/instance-ebs-bandwidth"
+	LabelInstanceEBSIOPS                      = LabelDomain + "/instance-ebs-iops"
+	LabelRootVolumeIOPS                       = LabelDomain + "/root-volume-iops"
 	LabelInstancePods                         = LabelDomain + "/instance-pods"
 	LabelInstanceGPUName                      = LabelDomain + "/instance-gpu-name"
 	LabelInstanceGPUManufacturer              = LabelDomain + "/instance-gpu-manufacturer"
@@ -107,7 +160,75 @@ var (
 	LabelInstanceAcceleratorName              = LabelDomain + "/instance-accelerator-name"
 	LabelInstanceAcceleratorManufacturer      = LabelDomain + "/instance-accelerator-manufacturer"
 	LabelInstanceAcceleratorCount             = LabelDomain + "/instance-accelerator-count"
+	LabelInstanceAcceleratorMemory            = LabelDomain + "/instance-accelerator-memory"
+	LabelCapacityReservationID                = LabelDomain + "/capacity-reservation-id"
 	AnnotationNodeTemplateHash                = LabelDomain + "/nodetemplate-hash"
+
+	// AnnotationAMIRollback is set to "true" on an AWSNodeTemplate to launch its previous AMIs instead of the AMIs that
+	// its amiSelector resolves, which drifts the nodes that were launched with the current AMIs
+	AnnotationAMIRollback = LabelDomain + "/ami-rollback"
+
+	// AnnotationInvalidateAMIs is set on an AWSNodeTemplate to resolve its AMIs again instead of using the AMIs that are
+	// cached for it, so that newly published AMIs are selected right away. It's removed once the AMIs are invalidated.
+	AnnotationInvalidateAMIs = LabelDomain + "/invalidate-amis"
+
+	AnnotationComputeOptimizerFinding                 = LabelDomain + "/compute-optimizer-finding"
+	AnnotationComputeOptimizerRecommendedInstanceType = LabelDomain + "/compute-optimizer-recommended-instance-type"
+
+	// AnnotationDetailedMonitoring and AnnotationInstanceMetadataTags are set on a Provisioner's annotations
+	// to override the detailedMonitoring and metadataOptions.instanceMetadataTags of its AWSNodeTemplate
+	AnnotationDetailedMonitoring   = LabelDomain + "/detailed-monitoring"
+	AnnotationInstanceMetadataTags = LabelDomain + "/instance-metadata-tags"
+
+	// AnnotationSpotInterruptionBehavior is set on a Provisioner's annotations to have EC2 stop or hibernate its spot
+	// instances on interruption instead of terminating them
+	AnnotationSpotInterruptionBehavior = LabelDomain + "/spot-interruption-behavior"
+
+	// AnnotationOnDemandFallback, AnnotationOnDemandFallbackAfterSpotFailures, AnnotationOnDemandFallbackAfter and
+	// AnnotationOnDemandFallbackMaxPercent are set on a Provisioner's annotations to control whether and when a
+	// Provisioner that allows both spot and on-demand launches on-demand instances because spot capacity is unavailable
+	AnnotationOnDemandFallback                  = LabelDomain + "/on-demand-fallback"
+	AnnotationOnDemandFallbackAfterSpotFailures = LabelDomain + "/on-demand-fallback-after-spot-failures"
+	AnnotationOnDemandFallbackAfter             = LabelDomain + "/on-demand-fallback-after"
+	AnnotationOnDemandFallbackMaxPercent        = LabelDomain + "/on-demand-fallback-max-percent"
+
+	// AnnotationMaintenanceDeferred is set on a Machine whose replacement for a scheduled maintenance event is deferred
+	// until its NodeClass' next maintenance window. The value is the time that the event is scheduled to start, if known.
+	AnnotationMaintenanceDeferred = LabelDomain + "/maintenance-deferred"
+
+	// AnnotationLaunchPrice is set on a Machine and its node when the instance is launched. The value is the hourly price in
+	// USD of the offering that the instance was launched into, as known at launch time.
+	AnnotationLaunchPrice = LabelDomain + "/launch-price"
+
+	// AnnotationLaunchTimeline is set on a Machine and its node when the instance is launched. The value is a JSON object
+	// with the time that each phase of the launch (resolution, launch_template, create_fleet) completed.
+	AnnotationLaunchTimeline = LabelDomain + "/launch-timeline"
+
+	// AnnotationSubnetRebalancing is set on a Machine whose instance is in a subnet that is running out of IP addresses,
+	// which drifts the Machine so that it's replaced. The value is the id of the subnet.
+	AnnotationSubnetRebalancing = LabelDomain + "/subnet-rebalancing"
+
+	// AnnotationDisruptionProtectedUntil is set on the node of a Machine while it's younger than the minimum node lifetime
+	// of its NodeClass, along with the do-not-disrupt annotation. The value is the time that the protection ends.
+	AnnotationDisruptionProtectedUntil = LabelDomain + "/disruption-protected-until"
+
+	// AnnotationProvisioningTrigger is set on the Machines that Karpenter creates for a provisioning trigger message from
+	// the aws.provisioningTriggerQueueName queue. The value is the id of the message.
+	AnnotationProvisioningTrigger = LabelDomain + "/provisioning-trigger"
+
+	// AnnotationLaunchSubnetID and AnnotationLaunchCapacityReservationID are set on a Machine, usually through its
+	// Provisioner's annotations, to launch its instance into a specific subnet or capacity reservation without editing
+	// the AWSNodeTemplate. The subnet must be one that the AWSNodeTemplate selects.
+	AnnotationLaunchSubnetID              = LabelDomain + "/launch-subnet-id"
+	AnnotationLaunchCapacityReservationID = LabelDomain + "/launch-capacity-reservation-id"
+
+	// TagScheduledCapacityReservation is set on the capacity reservations that Karpenter creates for the scheduled
+	// capacity reservations of an AWSNodeTemplate. The value is the name of the AWSNodeTemplate.
+	TagScheduledCapacityReservation = LabelDomain + "/scheduled-capacity-reservation"
+
+	// TagOnDemandFallbackInstance is set to "true" on the on-demand instances that are launched for a Provisioner that
+	// allows spot because spot capacity was unavailable
+	TagOnDemandFallbackInstance = LabelDomain + "/on-demand-fallback-instance"
 )
 
 var (
@@ -131,6 +252,15 @@ func init() {
 	v1alpha5.WellKnownLabels = v1alpha5.WellKnownLabels.Insert(
 		LabelInstanceHypervisor,
 		LabelInstanceEncryptionInTransitSupported,
+		LabelInstanceBootMode,
+		LabelInstanceVirtualizationType,
+		LabelInstanceRootDeviceType,
+		LabelInstanceNitroTPMSupported,
+		LabelInstanceEBSNVMeSupported,
+		LabelInstanceEFASupported,
+		LabelInstanceDedicatedHostsSupported,
+		LabelInstanceStoreVolumeCount,
+		LabelInstanceStoreVolumeSize,
 		LabelInstanceCategory,
 		LabelInstanceFamily,
 		LabelInstanceGeneration,
@@ -139,6 +269,9 @@ func init() {
 		LabelInstanceCPU,
 		LabelInstanceMemory,
 		LabelInstanceNetworkBandwidth,
+		LabelInstanceEBSBandwidth,
+		LabelInstanceEBSIOPS,
+		LabelRootVolumeIOPS,
 		LabelInstancePods,
 		LabelInstanceGPUName,
 		LabelInstanceGPUManufacturer,
@@ -147,6 +280,8 @@ func init() {
 		LabelInstanceAcceleratorName,
 		LabelInstanceAcceleratorManufacturer,
 		LabelInstanceAcceleratorCount,
+		LabelInstanceAcceleratorMemory,
+		LabelCapacityReservationID,
 		v1.LabelWindowsBuild,
 	)
 }
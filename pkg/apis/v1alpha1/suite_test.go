@@ -19,14 +19,18 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Pallinder/go-randomdata"
 	"github.com/mitchellh/hashstructure/v2"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
 	. "knative.dev/pkg/logging/testing"
 	"knative.dev/pkg/ptr"
 
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -159,6 +163,104 @@ var _ = Describe("Validation", func() {
 			}
 			Expect(ant.Validate(ctx)).ToNot(Succeed())
 		})
+		It("should succeed with an image builder ami selector", func() {
+			ant.Spec.AMISelector = map[string]string{
+				"aws::imageBuilderArn": "arn:aws:imagebuilder:us-west-2:123456789012:image-pipeline/golden,arn:aws:imagebuilder:us-west-2:123456789012:image-recipe/golden/1.0.0",
+				"foo":                  "bar",
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid image builder arn", func() {
+			ant.Spec.AMISelector = map[string]string{
+				"aws::imageBuilderArn": "arn:aws:ec2:us-west-2:123456789012:image/ami-123",
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when an image builder ami selector is used in combination with ssm", func() {
+			ant.Spec.AMISelector = map[string]string{
+				"aws::imageBuilderArn": "arn:aws:imagebuilder:us-west-2:123456789012:image-pipeline/golden",
+				"aws::ssm":             "/golden/al2/x86_64",
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should succeed with an ami selector that's described as a role", func() {
+			ant.Spec.AMISelector = map[string]string{
+				"aws::roleArn": "arn:aws:iam::111122223333:role/golden-images",
+				"foo":          "bar",
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+			ant.Spec.AMISelector = map[string]string{
+				"aws::roleArn": "arn:aws:iam::111122223333:role/golden-images",
+				"aws::ids":     "ami-123",
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid role arn", func() {
+			for _, roleARN := range []string{"golden-images", "arn:aws:iam::111122223333:user/golden-images"} {
+				ant.Spec.AMISelector = map[string]string{
+					"aws::roleArn": roleARN,
+					"foo":          "bar",
+				}
+				Expect(ant.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail when only a role arn is used", func() {
+			ant.Spec.AMISelector = map[string]string{
+				"aws::roleArn": "arn:aws:iam::111122223333:role/golden-images",
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should succeed when a id ami selector is used in combination with a creation date window", func() {
+			ant.Spec.AMISelector = map[string]string{
+				"aws::ids":             "ami-123",
+				"aws::minCreationDate": "2023-01-01T00:00:00Z",
+				"aws::maxCreationDate": "2023-06-01T00:00:00Z",
+				"aws::maxAge":          "720h",
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid creation date window", func() {
+			for _, window := range []map[string]string{
+				{"aws::minCreationDate": "2023-01-01"},
+				{"aws::maxCreationDate": "yesterday"},
+				{"aws::maxAge": "30d"},
+				{"aws::maxAge": "-1h"},
+				{"aws::minCreationDate": "2023-06-01T00:00:00Z", "aws::maxCreationDate": "2023-01-01T00:00:00Z"},
+			} {
+				window["foo"] = "bar"
+				ant.Spec.AMISelector = window
+				Expect(ant.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail when only a creation date window is used", func() {
+			ant.Spec.AMISelector = map[string]string{
+				"aws::maxAge": "720h",
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("AMIRollout", func() {
+		It("should succeed with a candidate ami selector and a weight", func() {
+			ant.Spec.AMIRollout = &v1alpha1.AMIRollout{AMISelector: map[string]string{"aws::ids": "ami-123"}, Weight: 10}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail without a candidate ami selector", func() {
+			ant.Spec.AMIRollout = &v1alpha1.AMIRollout{Weight: 10}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when the candidate ami selector is invalid", func() {
+			ant.Spec.AMIRollout = &v1alpha1.AMIRollout{AMISelector: map[string]string{"aws::ids": "ami-123", "foo": "bar"}, Weight: 10}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when the weight is out of bounds", func() {
+			ant.Spec.AMIRollout = &v1alpha1.AMIRollout{AMISelector: map[string]string{"aws::ids": "ami-123"}, Weight: 101}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.AMIRollout = &v1alpha1.AMIRollout{AMISelector: map[string]string{"aws::ids": "ami-123"}, Weight: 10}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
 	})
 	Context("UserData", func() {
 		It("should succeed if user data is empty", func() {
@@ -170,6 +272,496 @@ var _ = Describe("Validation", func() {
 			Expect(ant.Validate(ctx)).To(Not(Succeed()))
 		})
 	})
+	Context("CloudWatchAgent", func() {
+		It("should succeed with an inline config", func() {
+			ant.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{Config: ptr.String("{}")}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with an SSM parameter config", func() {
+			ant.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{ConfigSSMParameter: ptr.String("AmazonCloudWatch-linux")}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with FluentBit on Ubuntu", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			ant.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{Type: ptr.String(v1alpha1.CloudWatchAgentTypeFluentBit), Config: ptr.String("[INPUT]")}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with FluentBit on AL2023", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2023
+			ant.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{Type: ptr.String(v1alpha1.CloudWatchAgentTypeFluentBit), Config: ptr.String("[INPUT]")}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail if neither config nor configSSMParameter is specified", func() {
+			ant.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if both config and configSSMParameter are specified", func() {
+			ant.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{Config: ptr.String("{}"), ConfigSSMParameter: ptr.String("AmazonCloudWatch-linux")}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for the Bottlerocket AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{Config: ptr.String("{}")}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for FluentBit on Windows", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			ant.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{Type: ptr.String(v1alpha1.CloudWatchAgentTypeFluentBit), Config: ptr.String("[INPUT]")}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{Config: ptr.String("{}")}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("DomainJoin", func() {
+		BeforeEach(func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			ant.Spec.DomainJoin = &v1alpha1.DomainJoin{
+				DirectoryID:    ptr.String("d-1234567890"),
+				DirectoryName:  ptr.String("corp.example.com"),
+				DNSIPAddresses: []string{"10.0.0.10", "10.0.1.10"},
+				GMSA:           ptr.Bool(true),
+			}
+		})
+		It("should succeed with a directory", func() {
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with an SSM document", func() {
+			ant.Spec.DomainJoin = &v1alpha1.DomainJoin{
+				SSMDocument:           ptr.String("custom-domain-join"),
+				SSMDocumentParameters: map[string]string{"ouPath": "OU=Nodes,DC=corp,DC=example,DC=com"},
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a Linux AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if both directoryID and ssmDocument are specified", func() {
+			ant.Spec.DomainJoin.SSMDocument = ptr.String("custom-domain-join")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if neither directoryID nor ssmDocument is specified", func() {
+			ant.Spec.DomainJoin = &v1alpha1.DomainJoin{GMSA: ptr.Bool(true)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if directoryName is missing", func() {
+			ant.Spec.DomainJoin.DirectoryName = nil
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if a DNS IP address is invalid", func() {
+			ant.Spec.DomainJoin.DNSIPAddresses = []string{"10.0.0.300"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if ssmDocumentParameters are specified without ssmDocument", func() {
+			ant.Spec.DomainJoin.SSMDocumentParameters = map[string]string{"ouPath": "OU=Nodes"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("AMIVariant", func() {
+		It("should succeed for the Bottlerocket AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.AMIVariant = ptr.String(v1alpha1.AMIVariantFIPS)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed for the default AMIFamily", func() {
+			ant.Spec.AMIVariant = ptr.String(v1alpha1.AMIVariantFIPS)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for the Ubuntu AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			ant.Spec.AMIVariant = ptr.String(v1alpha1.AMIVariantFIPS)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an unknown variant", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.AMIVariant = ptr.String("nvidia")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("UbuntuStream", func() {
+		It("should succeed for the Ubuntu AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			ant.Spec.UbuntuStream = ptr.String(v1alpha1.UbuntuStreamPro)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for the default AMIFamily", func() {
+			ant.Spec.UbuntuStream = ptr.String(v1alpha1.UbuntuStreamPro)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for the Bottlerocket AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.UbuntuStream = ptr.String(v1alpha1.UbuntuStreamMinimal)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an unknown stream", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			ant.Spec.UbuntuStream = ptr.String("lts")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("TrustedBoot", func() {
+		It("should succeed when NitroTPM and Secure Boot are required", func() {
+			ant.Spec.TrustedBoot = &v1alpha1.TrustedBoot{NitroTPM: true, SecureBoot: true}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.TrustedBoot = &v1alpha1.TrustedBoot{NitroTPM: true}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("Neuron", func() {
+		It("should succeed for the default AMIFamily", func() {
+			ant.Spec.Neuron = &v1alpha1.Neuron{}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a driver version on Ubuntu", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			ant.Spec.Neuron = &v1alpha1.Neuron{DriverVersion: ptr.String("2.14.5.0")}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for the AL2023 AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2023
+			ant.Spec.Neuron = &v1alpha1.Neuron{}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for the Bottlerocket AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.Neuron = &v1alpha1.Neuron{}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a Windows AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			ant.Spec.Neuron = &v1alpha1.Neuron{}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.Neuron = &v1alpha1.Neuron{}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("CPUOptions", func() {
+		It("should succeed with threads per core", func() {
+			ant.Spec.CPUOptions = &v1alpha1.CPUOptions{ThreadsPerCore: ptr.Int64(1)}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a core count and threads per core", func() {
+			ant.Spec.CPUOptions = &v1alpha1.CPUOptions{CoreCount: ptr.Int64(4), ThreadsPerCore: ptr.Int64(2)}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a core count less than 1", func() {
+			ant.Spec.CPUOptions = &v1alpha1.CPUOptions{CoreCount: ptr.Int64(0)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for more than 2 threads per core", func() {
+			ant.Spec.CPUOptions = &v1alpha1.CPUOptions{ThreadsPerCore: ptr.Int64(3)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for attribute based instance selection", func() {
+			ant.Spec.InstanceSelectionMode = ptr.String(v1alpha1.InstanceSelectionModeAttributeBased)
+			ant.Spec.CPUOptions = &v1alpha1.CPUOptions{ThreadsPerCore: ptr.Int64(1)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if an instance type override sets cpu capacity", func() {
+			ant.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{{InstanceTypes: []string{"m5.*"}, Capacity: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")}}}
+			ant.Spec.CPUOptions = &v1alpha1.CPUOptions{ThreadsPerCore: ptr.Int64(1)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.CPUOptions = &v1alpha1.CPUOptions{ThreadsPerCore: ptr.Int64(1)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("MaintenanceWindows", func() {
+		It("should succeed for a window on specific days", func() {
+			ant.Spec.MaintenanceWindows = []v1alpha1.MaintenanceWindow{{Days: []string{"Saturday", "Sunday"}, Start: "22:30", Duration: metav1.Duration{Duration: 6 * time.Hour}}}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed for a daily window", func() {
+			ant.Spec.MaintenanceWindows = []v1alpha1.MaintenanceWindow{{Start: "03:00", Duration: metav1.Duration{Duration: time.Hour}}}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for an invalid day", func() {
+			ant.Spec.MaintenanceWindows = []v1alpha1.MaintenanceWindow{{Days: []string{"saturday"}, Start: "03:00", Duration: metav1.Duration{Duration: time.Hour}}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an invalid start", func() {
+			ant.Spec.MaintenanceWindows = []v1alpha1.MaintenanceWindow{{Start: "25:00", Duration: metav1.Duration{Duration: time.Hour}}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a zero duration", func() {
+			ant.Spec.MaintenanceWindows = []v1alpha1.MaintenanceWindow{{Start: "03:00"}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a duration longer than a week", func() {
+			ant.Spec.MaintenanceWindows = []v1alpha1.MaintenanceWindow{{Start: "03:00", Duration: metav1.Duration{Duration: 8 * 24 * time.Hour}}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("RegistrationTTL", func() {
+		It("should succeed for a registration TTL shorter than the default", func() {
+			ant.Spec.RegistrationTTL = &metav1.Duration{Duration: 5 * time.Minute}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed for a registration TTL equal to the default", func() {
+			ant.Spec.RegistrationTTL = &metav1.Duration{Duration: 15 * time.Minute}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a zero registration TTL", func() {
+			ant.Spec.RegistrationTTL = &metav1.Duration{}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed for a registration TTL longer than the default", func() {
+			ant.Spec.RegistrationTTL = &metav1.Duration{Duration: 30 * time.Minute}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a registration TTL longer than an hour", func() {
+			ant.Spec.RegistrationTTL = &metav1.Duration{Duration: 2 * time.Hour}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("MinimumNodeLifetime", func() {
+		It("should succeed for a positive minimum node lifetime", func() {
+			ant.Spec.MinimumNodeLifetime = &metav1.Duration{Duration: 6 * time.Hour}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a zero minimum node lifetime", func() {
+			ant.Spec.MinimumNodeLifetime = &metav1.Duration{}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a negative minimum node lifetime", func() {
+			ant.Spec.MinimumNodeLifetime = &metav1.Duration{Duration: -time.Hour}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("ScheduledCapacityReservations", func() {
+		var reservation v1alpha1.ScheduledCapacityReservation
+		BeforeEach(func() {
+			reservation = v1alpha1.ScheduledCapacityReservation{
+				InstanceType:     "m5.xlarge",
+				AvailabilityZone: "us-west-2a",
+				InstanceCount:    10,
+				Days:             []string{"Monday", "Tuesday"},
+				Start:            "08:30",
+				Duration:         metav1.Duration{Duration: 2 * time.Hour},
+			}
+		})
+		It("should succeed for a scheduled capacity reservation", func() {
+			ant.Spec.ScheduledCapacityReservations = []v1alpha1.ScheduledCapacityReservation{reservation}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail without an instance type", func() {
+			reservation.InstanceType = ""
+			ant.Spec.ScheduledCapacityReservations = []v1alpha1.ScheduledCapacityReservation{reservation}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail without an availability zone", func() {
+			reservation.AvailabilityZone = ""
+			ant.Spec.ScheduledCapacityReservations = []v1alpha1.ScheduledCapacityReservation{reservation}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a zero instance count", func() {
+			reservation.InstanceCount = 0
+			ant.Spec.ScheduledCapacityReservations = []v1alpha1.ScheduledCapacityReservation{reservation}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an invalid day", func() {
+			reservation.Days = []string{"monday"}
+			ant.Spec.ScheduledCapacityReservations = []v1alpha1.ScheduledCapacityReservation{reservation}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an invalid start", func() {
+			reservation.Start = "8:30am"
+			ant.Spec.ScheduledCapacityReservations = []v1alpha1.ScheduledCapacityReservation{reservation}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a duration longer than a day", func() {
+			reservation.Duration = metav1.Duration{Duration: 25 * time.Hour}
+			ant.Spec.ScheduledCapacityReservations = []v1alpha1.ScheduledCapacityReservation{reservation}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.LaunchTemplateName = aws.String("test-launch-template")
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.ScheduledCapacityReservations = []v1alpha1.ScheduledCapacityReservation{reservation}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("InstanceTypeOverrides", func() {
+		var override v1alpha1.InstanceTypeOverride
+		BeforeEach(func() {
+			override = v1alpha1.InstanceTypeOverride{
+				InstanceTypes:  []string{"p4d.24xlarge", "g5.*"},
+				Capacity:       v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("4")},
+				SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+			}
+		})
+		It("should succeed for an instance type override", func() {
+			ant.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.LaunchTemplateName = aws.String("my-launch-template")
+			ant.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail without instance types", func() {
+			override.InstanceTypes = nil
+			ant.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an invalid instance type pattern", func() {
+			override.InstanceTypes = []string{"g5.[a"}
+			ant.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a negative capacity", func() {
+			override.Capacity = v1.ResourceList{v1.ResourceMemory: resource.MustParse("-1Gi")}
+			ant.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a negative system reserved resource", func() {
+			override.SystemReserved = v1.ResourceList{v1.ResourceMemory: resource.MustParse("-1Gi")}
+			ant.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed for a vm memory overhead percent", func() {
+			override.VMMemoryOverheadPercent = lo.ToPtr("0.01")
+			ant.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a vm memory overhead percent of 1", func() {
+			override.VMMemoryOverheadPercent = lo.ToPtr("1")
+			ant.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a vm memory overhead percent that isn't a decimal", func() {
+			override.VMMemoryOverheadPercent = lo.ToPtr("7.5%")
+			ant.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("KubeReserved", func() {
+		var kubeReserved *v1alpha1.KubeReserved
+		BeforeEach(func() {
+			kubeReserved = &v1alpha1.KubeReserved{
+				Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaCustom),
+				CPU: []v1alpha1.ReservedStep{
+					{UpTo: lo.ToPtr(resource.MustParse("1")), Ratio: "0.06"},
+					{UpTo: lo.ToPtr(resource.MustParse("2")), Ratio: "0.01"},
+					{Ratio: "0.0025"},
+				},
+				Memory:       []v1alpha1.ReservedStep{{UpTo: lo.ToPtr(resource.MustParse("4Gi")), Ratio: "0.25"}, {Ratio: "0.1"}},
+				MemoryPerPod: lo.ToPtr(resource.MustParse("11Mi")),
+				BaseMemory:   lo.ToPtr(resource.MustParse("255Mi")),
+			}
+		})
+		It("should succeed for the GKE formula", func() {
+			ant.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaGKE)}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed for a custom formula", func() {
+			ant.Spec.KubeReserved = kubeReserved
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for an unknown formula", func() {
+			ant.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr("AKS")}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for steps with a formula other than Custom", func() {
+			kubeReserved.Formula = lo.ToPtr(v1alpha1.KubeReservedFormulaGKE)
+			ant.Spec.KubeReserved = kubeReserved
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a ratio above 1", func() {
+			kubeReserved.Memory[1].Ratio = "1.5"
+			ant.Spec.KubeReserved = kubeReserved
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a ratio that isn't a decimal", func() {
+			kubeReserved.CPU[0].Ratio = "6%"
+			ant.Spec.KubeReserved = kubeReserved
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a step without upTo that isn't the last", func() {
+			kubeReserved.CPU[1].UpTo = nil
+			ant.Spec.KubeReserved = kubeReserved
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for steps whose upTo doesn't increase", func() {
+			kubeReserved.CPU[1].UpTo = lo.ToPtr(resource.MustParse("500m"))
+			ant.Spec.KubeReserved = kubeReserved
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a negative memory per pod", func() {
+			kubeReserved.MemoryPerPod = lo.ToPtr(resource.MustParse("-1Mi"))
+			ant.Spec.KubeReserved = kubeReserved
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a formula other than EKS with a launch template", func() {
+			ant.Spec.LaunchTemplateName = aws.String("my-launch-template")
+			ant.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaGKE)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("ExcludedInstanceClasses", func() {
+		It("should succeed for known instance classes", func() {
+			ant.Spec.ExcludedInstanceClasses = []string{v1alpha1.InstanceClassMetal, v1alpha1.InstanceClassOddSized}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for an unknown instance class", func() {
+			ant.Spec.ExcludedInstanceClasses = []string{v1alpha1.InstanceClassMetal, "gpu"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("CapacityTypeOverrides", func() {
+		var override v1alpha1.CapacityTypeOverride
+		BeforeEach(func() {
+			override = v1alpha1.CapacityTypeOverride{
+				CapacityType:       "spot",
+				Tags:               map[string]string{"agent": "spot"},
+				UserData:           aws.String("#!/bin/bash\necho spot"),
+				DetailedMonitoring: aws.Bool(true),
+			}
+		})
+		It("should succeed for a capacity type override", func() {
+			ant.Spec.CapacityTypeOverrides = []v1alpha1.CapacityTypeOverride{override, {CapacityType: "on-demand"}}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.LaunchTemplateName = aws.String("my-launch-template")
+			ant.Spec.CapacityTypeOverrides = []v1alpha1.CapacityTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an unknown capacity type", func() {
+			override.CapacityType = "reserved"
+			ant.Spec.CapacityTypeOverrides = []v1alpha1.CapacityTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when a capacity type is overridden more than once", func() {
+			ant.Spec.CapacityTypeOverrides = []v1alpha1.CapacityTypeOverride{override, {CapacityType: "spot"}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a restricted tag", func() {
+			override.Tags = map[string]string{"karpenter.sh/provisioner-name": "default"}
+			ant.Spec.CapacityTypeOverrides = []v1alpha1.CapacityTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an empty tag key", func() {
+			override.Tags = map[string]string{"": "spot"}
+			ant.Spec.CapacityTypeOverrides = []v1alpha1.CapacityTypeOverride{override}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
 	Context("Tags", func() {
 		It("should succeed when tags are empty", func() {
 			ant.Spec.Tags = map[string]string{}
@@ -287,9 +879,22 @@ var _ = Describe("Validation", func() {
 			Entry("Context Drift", v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{Context: aws.String("context-2")}}),
 			Entry("DetailedMonitoring Drift", v1alpha1.AWSNodeTemplateSpec{DetailedMonitoring: aws.Bool(true)}),
 			Entry("AMIFamily Drift", v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{AMIFamily: aws.String(v1alpha1.AMIFamilyBottlerocket)}}),
+			Entry("TrustedBoot Drift", v1alpha1.AWSNodeTemplateSpec{TrustedBoot: &v1alpha1.TrustedBoot{NitroTPM: true}}),
+			Entry("KubeReserved Drift", v1alpha1.AWSNodeTemplateSpec{KubeReserved: &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaGKE)}}),
 			Entry("Reorder Tags", v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{Tags: map[string]string{"keyTag-2": "valueTag-2", "keyTag-1": "valueTag-1"}}}),
 			Entry("Reorder BlockDeviceMapping", v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{LaunchTemplate: v1alpha1.LaunchTemplate{BlockDeviceMappings: []*v1alpha1.BlockDeviceMapping{{DeviceName: aws.String("map-device-2")}, {DeviceName: aws.String("map-device-1")}}}}}),
 		)
+		It("should change hash when the quantities of a Custom kube reserved formula are updated", func() {
+			awsnodetemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaCustom), BaseMemory: lo.ToPtr(resource.MustParse("1Gi"))}
+			hash := awsnodetemplate.Hash()
+			awsnodetemplate.Spec.KubeReserved.BaseMemory = lo.ToPtr(resource.MustParse("2Gi"))
+			Expect(awsnodetemplate.Hash()).ToNot(Equal(hash))
+		})
+		It("should not change hash when the default kube reserved formula is set", func() {
+			hash := awsnodetemplate.Hash()
+			awsnodetemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaEKS)}
+			Expect(awsnodetemplate.Hash()).To(Equal(hash))
+		})
 		It("should not change hash when behavior/dynamic fields are updated", func() {
 			actualHash := awsnodetemplate.Hash()
 
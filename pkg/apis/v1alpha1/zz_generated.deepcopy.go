@@ -20,7 +20,9 @@ package v1alpha1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -45,6 +47,28 @@ func (in *AMI) DeepCopy() *AMI {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AMIRollout) DeepCopyInto(out *AMIRollout) {
+	*out = *in
+	if in.AMISelector != nil {
+		in, out := &in.AMISelector, &out.AMISelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AMIRollout.
+func (in *AMIRollout) DeepCopy() *AMIRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(AMIRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AWS) DeepCopyInto(out *AWS) {
 	*out = *in
@@ -181,11 +205,119 @@ func (in *AWSNodeTemplateSpec) DeepCopyInto(out *AWSNodeTemplateSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.AMIDeprecationPolicy != nil {
+		in, out := &in.AMIDeprecationPolicy, &out.AMIDeprecationPolicy
+		*out = new(string)
+		**out = **in
+	}
+	if in.AMISortStrategy != nil {
+		in, out := &in.AMISortStrategy, &out.AMISortStrategy
+		*out = new(string)
+		**out = **in
+	}
+	if in.AMIVariant != nil {
+		in, out := &in.AMIVariant, &out.AMIVariant
+		*out = new(string)
+		**out = **in
+	}
+	if in.UbuntuStream != nil {
+		in, out := &in.UbuntuStream, &out.UbuntuStream
+		*out = new(string)
+		**out = **in
+	}
+	if in.TrustedBoot != nil {
+		in, out := &in.TrustedBoot, &out.TrustedBoot
+		*out = new(TrustedBoot)
+		**out = **in
+	}
+	if in.AMIRollout != nil {
+		in, out := &in.AMIRollout, &out.AMIRollout
+		*out = new(AMIRollout)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.DetailedMonitoring != nil {
 		in, out := &in.DetailedMonitoring, &out.DetailedMonitoring
 		*out = new(bool)
 		**out = **in
 	}
+	if in.CloudWatchAgent != nil {
+		in, out := &in.CloudWatchAgent, &out.CloudWatchAgent
+		*out = new(CloudWatchAgent)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DomainJoin != nil {
+		in, out := &in.DomainJoin, &out.DomainJoin
+		*out = new(DomainJoin)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Neuron != nil {
+		in, out := &in.Neuron, &out.Neuron
+		*out = new(Neuron)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CPUOptions != nil {
+		in, out := &in.CPUOptions, &out.CPUOptions
+		*out = new(CPUOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RegistrationTTL != nil {
+		in, out := &in.RegistrationTTL, &out.RegistrationTTL
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.SecurityGroupDriftRemediation != nil {
+		in, out := &in.SecurityGroupDriftRemediation, &out.SecurityGroupDriftRemediation
+		*out = new(string)
+		**out = **in
+	}
+	if in.MinimumNodeLifetime != nil {
+		in, out := &in.MinimumNodeLifetime, &out.MinimumNodeLifetime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ScheduledCapacityReservations != nil {
+		in, out := &in.ScheduledCapacityReservations, &out.ScheduledCapacityReservations
+		*out = make([]ScheduledCapacityReservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InstanceTypeOverrides != nil {
+		in, out := &in.InstanceTypeOverrides, &out.InstanceTypeOverrides
+		*out = make([]InstanceTypeOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KubeReserved != nil {
+		in, out := &in.KubeReserved, &out.KubeReserved
+		*out = new(KubeReserved)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExcludedInstanceClasses != nil {
+		in, out := &in.ExcludedInstanceClasses, &out.ExcludedInstanceClasses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstanceSelectionMode != nil {
+		in, out := &in.InstanceSelectionMode, &out.InstanceSelectionMode
+		*out = new(string)
+		**out = **in
+	}
+	if in.CapacityTypeOverrides != nil {
+		in, out := &in.CapacityTypeOverrides, &out.CapacityTypeOverrides
+		*out = make([]CapacityTypeOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSNodeTemplateSpec.
@@ -218,6 +350,27 @@ func (in *AWSNodeTemplateStatus) DeepCopyInto(out *AWSNodeTemplateStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.PreviousAMIs != nil {
+		in, out := &in.PreviousAMIs, &out.PreviousAMIs
+		*out = make([]AMI, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExcludedInstanceTypes != nil {
+		in, out := &in.ExcludedInstanceTypes, &out.ExcludedInstanceTypes
+		*out = make([]ExcludedInstanceType, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apis.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSNodeTemplateStatus.
@@ -310,6 +463,244 @@ func (in *BlockDeviceMapping) DeepCopy() *BlockDeviceMapping {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CPUOptions) DeepCopyInto(out *CPUOptions) {
+	*out = *in
+	if in.CoreCount != nil {
+		in, out := &in.CoreCount, &out.CoreCount
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ThreadsPerCore != nil {
+		in, out := &in.ThreadsPerCore, &out.ThreadsPerCore
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CPUOptions.
+func (in *CPUOptions) DeepCopy() *CPUOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(CPUOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacityTypeOverride) DeepCopyInto(out *CapacityTypeOverride) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.UserData != nil {
+		in, out := &in.UserData, &out.UserData
+		*out = new(string)
+		**out = **in
+	}
+	if in.DetailedMonitoring != nil {
+		in, out := &in.DetailedMonitoring, &out.DetailedMonitoring
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacityTypeOverride.
+func (in *CapacityTypeOverride) DeepCopy() *CapacityTypeOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacityTypeOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudWatchAgent) DeepCopyInto(out *CloudWatchAgent) {
+	*out = *in
+	if in.Type != nil {
+		in, out := &in.Type, &out.Type
+		*out = new(string)
+		**out = **in
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(string)
+		**out = **in
+	}
+	if in.ConfigSSMParameter != nil {
+		in, out := &in.ConfigSSMParameter, &out.ConfigSSMParameter
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudWatchAgent.
+func (in *CloudWatchAgent) DeepCopy() *CloudWatchAgent {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudWatchAgent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DomainJoin) DeepCopyInto(out *DomainJoin) {
+	*out = *in
+	if in.DirectoryID != nil {
+		in, out := &in.DirectoryID, &out.DirectoryID
+		*out = new(string)
+		**out = **in
+	}
+	if in.DirectoryName != nil {
+		in, out := &in.DirectoryName, &out.DirectoryName
+		*out = new(string)
+		**out = **in
+	}
+	if in.DNSIPAddresses != nil {
+		in, out := &in.DNSIPAddresses, &out.DNSIPAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SSMDocument != nil {
+		in, out := &in.SSMDocument, &out.SSMDocument
+		*out = new(string)
+		**out = **in
+	}
+	if in.SSMDocumentParameters != nil {
+		in, out := &in.SSMDocumentParameters, &out.SSMDocumentParameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.GMSA != nil {
+		in, out := &in.GMSA, &out.GMSA
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DomainJoin.
+func (in *DomainJoin) DeepCopy() *DomainJoin {
+	if in == nil {
+		return nil
+	}
+	out := new(DomainJoin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExcludedInstanceType) DeepCopyInto(out *ExcludedInstanceType) {
+	*out = *in
+	in.ExcludedUntil.DeepCopyInto(&out.ExcludedUntil)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExcludedInstanceType.
+func (in *ExcludedInstanceType) DeepCopy() *ExcludedInstanceType {
+	if in == nil {
+		return nil
+	}
+	out := new(ExcludedInstanceType)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceTypeOverride) DeepCopyInto(out *InstanceTypeOverride) {
+	*out = *in
+	if in.InstanceTypes != nil {
+		in, out := &in.InstanceTypes, &out.InstanceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.SystemReserved != nil {
+		in, out := &in.SystemReserved, &out.SystemReserved
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.VMMemoryOverheadPercent != nil {
+		in, out := &in.VMMemoryOverheadPercent, &out.VMMemoryOverheadPercent
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceTypeOverride.
+func (in *InstanceTypeOverride) DeepCopy() *InstanceTypeOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceTypeOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeReserved) DeepCopyInto(out *KubeReserved) {
+	*out = *in
+	if in.Formula != nil {
+		in, out := &in.Formula, &out.Formula
+		*out = new(string)
+		**out = **in
+	}
+	if in.CPU != nil {
+		in, out := &in.CPU, &out.CPU
+		*out = make([]ReservedStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Memory != nil {
+		in, out := &in.Memory, &out.Memory
+		*out = make([]ReservedStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MemoryPerPod != nil {
+		in, out := &in.MemoryPerPod, &out.MemoryPerPod
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.BaseMemory != nil {
+		in, out := &in.BaseMemory, &out.BaseMemory
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.EphemeralStorage != nil {
+		in, out := &in.EphemeralStorage, &out.EphemeralStorage
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeReserved.
+func (in *KubeReserved) DeepCopy() *KubeReserved {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeReserved)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LaunchTemplate) DeepCopyInto(out *LaunchTemplate) {
 	*out = *in
@@ -346,6 +737,27 @@ func (in *LaunchTemplate) DeepCopy() *LaunchTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetadataOptions) DeepCopyInto(out *MetadataOptions) {
 	*out = *in
@@ -369,6 +781,11 @@ func (in *MetadataOptions) DeepCopyInto(out *MetadataOptions) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.InstanceMetadataTags != nil {
+		in, out := &in.InstanceMetadataTags, &out.InstanceMetadataTags
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataOptions.
@@ -381,6 +798,67 @@ func (in *MetadataOptions) DeepCopy() *MetadataOptions {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Neuron) DeepCopyInto(out *Neuron) {
+	*out = *in
+	if in.DriverVersion != nil {
+		in, out := &in.DriverVersion, &out.DriverVersion
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Neuron.
+func (in *Neuron) DeepCopy() *Neuron {
+	if in == nil {
+		return nil
+	}
+	out := new(Neuron)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReservedStep) DeepCopyInto(out *ReservedStep) {
+	*out = *in
+	if in.UpTo != nil {
+		in, out := &in.UpTo, &out.UpTo
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReservedStep.
+func (in *ReservedStep) DeepCopy() *ReservedStep {
+	if in == nil {
+		return nil
+	}
+	out := new(ReservedStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledCapacityReservation) DeepCopyInto(out *ScheduledCapacityReservation) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledCapacityReservation.
+func (in *ScheduledCapacityReservation) DeepCopy() *ScheduledCapacityReservation {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledCapacityReservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecurityGroup) DeepCopyInto(out *SecurityGroup) {
 	*out = *in
@@ -410,3 +888,18 @@ func (in *Subnet) DeepCopy() *Subnet {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustedBoot) DeepCopyInto(out *TrustedBoot) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustedBoot.
+func (in *TrustedBoot) DeepCopy() *TrustedBoot {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustedBoot)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -16,8 +16,12 @@ package v1alpha5
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	v1 "k8s.io/api/core/v1"
 	"knative.dev/pkg/apis"
@@ -39,14 +43,32 @@ func (p *Provisioner) SupportedVerbs() []admissionregistrationv1.OperationType {
 }
 
 func (p *Provisioner) Validate(_ context.Context) (errs *apis.FieldError) {
+	errs = p.validateAnnotations().ViaField("spec")
 	if p.Spec.Provider == nil {
-		return nil
+		return errs
 	}
 	provider, err := v1alpha1.DeserializeProvider(p.Spec.Provider.Raw)
 	if err != nil {
-		return apis.ErrGeneric(err.Error())
+		return errs.Also(apis.ErrGeneric(err.Error()))
 	}
-	return provider.Validate()
+	return errs.Also(provider.Validate())
+}
+
+// validateAnnotations validates the annotations that override the AWSNodeTemplate of the Provisioner's machines, so
+// that invalid values are rejected up front rather than failing every launch
+func (p *Provisioner) validateAnnotations() (errs *apis.FieldError) {
+	if value, ok := p.Spec.Annotations[v1alpha1.AnnotationDetailedMonitoring]; ok {
+		if _, err := strconv.ParseBool(value); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(value, "").ViaFieldKey("annotations", v1alpha1.AnnotationDetailedMonitoring))
+		}
+	}
+	if value, ok := p.Spec.Annotations[v1alpha1.AnnotationInstanceMetadataTags]; ok {
+		if !lo.Contains(ec2.LaunchTemplateInstanceMetadataTagsState_Values(), value) {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", value, strings.Join(ec2.LaunchTemplateInstanceMetadataTagsState_Values(), ", ")), "").
+				ViaFieldKey("annotations", v1alpha1.AnnotationInstanceMetadataTags))
+		}
+	}
+	return errs
 }
 
 func (p *Provisioner) SetDefaults(_ context.Context) {
@@ -135,6 +135,29 @@ var _ = Describe("Provisioner", func() {
 			}
 			Expect(provisioner.Validate(ctx)).To(Succeed())
 		})
+		Context("Annotations", func() {
+			It("should allow valid overrides", func() {
+				provisioner.Spec.Annotations = map[string]string{
+					v1alpha1.AnnotationDetailedMonitoring:   "true",
+					v1alpha1.AnnotationInstanceMetadataTags: ec2.LaunchTemplateInstanceMetadataTagsStateEnabled,
+				}
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow a detailed monitoring override that isn't a bool", func() {
+				provisioner.Spec.Annotations = map[string]string{v1alpha1.AnnotationDetailedMonitoring: "sometimes"}
+				Expect(Validate(ctx, provisioner)).ToNot(Succeed())
+			})
+			It("should not allow an instance metadata tags override that isn't an enum value", func() {
+				provisioner.Spec.Annotations = map[string]string{v1alpha1.AnnotationInstanceMetadataTags: randomdata.SillyName()}
+				Expect(Validate(ctx, provisioner)).ToNot(Succeed())
+			})
+			It("should validate overrides if provider undefined", func() {
+				provisioner.Spec.Provider = nil
+				provisioner.Spec.ProviderRef = &v1alpha5.MachineTemplateRef{Kind: "AWSNodeTemplate", Name: "default"}
+				provisioner.Spec.Annotations = map[string]string{v1alpha1.AnnotationDetailedMonitoring: "sometimes"}
+				Expect(Validate(ctx, provisioner)).ToNot(Succeed())
+			})
+		})
 
 		Context("SubnetSelector", func() {
 			It("should not allow empty string keys or values", func() {
@@ -310,6 +333,27 @@ var _ = Describe("Provisioner", func() {
 					Expect(Validate(ctx, test.Provisioner(test.ProvisionerOptions{Provider: provider}))).ToNot(Succeed())
 				})
 			})
+			Context("InstanceMetadataTags", func() {
+				It("should allow enum values", func() {
+					provider, err := v1alpha1.DeserializeProvider(provisioner.Spec.Provider.Raw)
+					Expect(err).ToNot(HaveOccurred())
+					for _, value := range ec2.LaunchTemplateInstanceMetadataTagsState_Values() {
+						provider.MetadataOptions = &v1alpha1.MetadataOptions{
+							InstanceMetadataTags: aws.String(value),
+						}
+						provisioner = test.Provisioner(test.ProvisionerOptions{Provider: provider})
+						Expect(provisioner.Validate(ctx)).To(Succeed())
+					}
+				})
+				It("should not allow non-enum values", func() {
+					provider, err := v1alpha1.DeserializeProvider(provisioner.Spec.Provider.Raw)
+					Expect(err).ToNot(HaveOccurred())
+					provider.MetadataOptions = &v1alpha1.MetadataOptions{
+						InstanceMetadataTags: aws.String(randomdata.SillyName()),
+					}
+					Expect(Validate(ctx, test.Provisioner(test.ProvisionerOptions{Provider: provider}))).ToNot(Succeed())
+				})
+			})
 			Context("BlockDeviceMappings", func() {
 				It("should not allow with a custom launch template", func() {
 					provider, err := v1alpha1.DeserializeProvider(provisioner.Spec.Provider.Raw)
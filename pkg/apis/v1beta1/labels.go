@@ -31,6 +31,15 @@ func init() {
 	v1beta1.WellKnownLabels = v1beta1.WellKnownLabels.Insert(
 		LabelInstanceHypervisor,
 		LabelInstanceEncryptionInTransitSupported,
+		LabelInstanceBootMode,
+		LabelInstanceVirtualizationType,
+		LabelInstanceRootDeviceType,
+		LabelInstanceNitroTPMSupported,
+		LabelInstanceEBSNVMeSupported,
+		LabelInstanceEFASupported,
+		LabelInstanceDedicatedHostsSupported,
+		LabelInstanceStoreVolumeCount,
+		LabelInstanceStoreVolumeSize,
 		LabelInstanceCategory,
 		LabelInstanceFamily,
 		LabelInstanceGeneration,
@@ -39,6 +48,9 @@ func init() {
 		LabelInstanceCPU,
 		LabelInstanceMemory,
 		LabelInstanceNetworkBandwidth,
+		LabelInstanceEBSBandwidth,
+		LabelInstanceEBSIOPS,
+		LabelRootVolumeIOPS,
 		LabelInstancePods,
 		LabelInstanceGPUName,
 		LabelInstanceGPUManufacturer,
@@ -47,6 +59,8 @@ func init() {
 		LabelInstanceAcceleratorName,
 		LabelInstanceAcceleratorManufacturer,
 		LabelInstanceAcceleratorCount,
+		LabelInstanceAcceleratorMemory,
+		LabelCapacityReservationID,
 		v1.LabelWindowsBuild,
 	)
 }
@@ -76,6 +90,7 @@ var (
 	}
 	AMIFamilyBottlerocket = "Bottlerocket"
 	AMIFamilyAL2          = "AL2"
+	AMIFamilyAL2023       = "AL2023"
 	AMIFamilyUbuntu       = "Ubuntu"
 	AMIFamilyWindows2019  = "Windows2019"
 	AMIFamilyWindows2022  = "Windows2022"
@@ -83,11 +98,50 @@ var (
 	SupportedAMIFamilies  = []string{
 		AMIFamilyBottlerocket,
 		AMIFamilyAL2,
+		AMIFamilyAL2023,
 		AMIFamilyUbuntu,
 		AMIFamilyWindows2019,
 		AMIFamilyWindows2022,
 		AMIFamilyCustom,
 	}
+	SecurityGroupDriftRemediationReplace = "Replace"
+	SecurityGroupDriftRemediationInPlace = "InPlace"
+	AMIDeprecationPolicyInclude          = "Include"
+	AMIDeprecationPolicyDeprioritize     = "Deprioritize"
+	AMIDeprecationPolicyExclude          = "Exclude"
+	AMISortStrategyNewest                = "newest"
+	AMISortStrategyNameDescending        = "name-descending"
+	AMISortStrategyPinned                = "pinned"
+	InstanceSelectionModeInstanceTypes   = "InstanceTypes"
+	InstanceSelectionModeAttributeBased  = "AttributeBased"
+	KubeReservedFormulaEKS               = "EKS"
+	KubeReservedFormulaGKE               = "GKE"
+	KubeReservedFormulaCustom            = "Custom"
+	AMIVariantFIPS                       = "fips"
+	UbuntuStreamStandard                 = "standard"
+	UbuntuStreamPro                      = "pro"
+	UbuntuStreamMinimal                  = "minimal"
+	CloudWatchAgentTypeCloudWatchAgent   = "CloudWatchAgent"
+	CloudWatchAgentTypeFluentBit         = "FluentBit"
+	InstanceClassMetal                   = "metal"
+	InstanceClassPreviousGeneration      = "previous-generation"
+	InstanceClassBurstable               = "burstable"
+	InstanceClassOddSized                = "odd-sized"
+	// InstanceClasses are the classes of instance types that can be excluded from a NodeClass's instance types
+	InstanceClasses = []string{
+		InstanceClassMetal,
+		InstanceClassPreviousGeneration,
+		InstanceClassBurstable,
+		InstanceClassOddSized,
+	}
+	// SupportedCloudWatchAgentTypesByAMIFamily are the agents that can be installed on each AMI family during bootstrap
+	SupportedCloudWatchAgentTypesByAMIFamily = map[string]sets.Set[string]{
+		AMIFamilyAL2:         sets.New(CloudWatchAgentTypeCloudWatchAgent, CloudWatchAgentTypeFluentBit),
+		AMIFamilyAL2023:      sets.New(CloudWatchAgentTypeCloudWatchAgent, CloudWatchAgentTypeFluentBit),
+		AMIFamilyUbuntu:      sets.New(CloudWatchAgentTypeCloudWatchAgent, CloudWatchAgentTypeFluentBit),
+		AMIFamilyWindows2019: sets.New(CloudWatchAgentTypeCloudWatchAgent),
+		AMIFamilyWindows2022: sets.New(CloudWatchAgentTypeCloudWatchAgent),
+	}
 	Windows2019                                = "2019"
 	Windows2022                                = "2022"
 	WindowsCore                                = "Core"
@@ -102,6 +156,15 @@ var (
 
 	LabelInstanceHypervisor                   = Group + "/instance-hypervisor"
 	LabelInstanceEncryptionInTransitSupported = Group + "/instance-encryption-in-transit-supported"
+	LabelInstanceBootMode                     = Group + "/instance-boot-mode"
+	LabelInstanceVirtualizationType           = Group + "/instance-virtualization-type"
+	LabelInstanceRootDeviceType               = Group + "/instance-root-device-type"
+	LabelInstanceNitroTPMSupported            = Group + "/instance-nitro-tpm-supported"
+	LabelInstanceEBSNVMeSupported             = Group + "/instance-ebs-nvme-supported"
+	LabelInstanceEFASupported                 = Group + "/instance-efa-supported"
+	LabelInstanceDedicatedHostsSupported      = Group + "/instance-dedicated-hosts-supported"
+	LabelInstanceStoreVolumeCount             = Group + "/instance-store-volume-count"
+	LabelInstanceStoreVolumeSize              = Group + "/instance-store-volume-size"
 	LabelInstanceCategory                     = Group + "/instance-category"
 	LabelInstanceFamily                       = Group + "/instance-family"
 	LabelInstanceGeneration                   = Group + "/instance-generation"
@@ -110,6 +173,9 @@ var (
 	LabelInstanceCPU                          = Group + "/instance-cpu"
 	LabelInstanceMemory                       = Group + "/instance-memory"
 	LabelInstanceNetworkBandwidth             = Group + "/instance-network-bandwidth"
+	LabelInstanceEBSBandwidth                 = Group + "/instance-ebs-bandwidth"
+	LabelInstanceEBSIOPS                      = Group + "/instance-ebs-iops"
+	LabelRootVolumeIOPS                       = Group + "/root-volume-iops"
 	LabelInstancePods                         = Group + "/instance-pods"
 	LabelInstanceGPUName                      = Group + "/instance-gpu-name"
 	LabelInstanceGPUManufacturer              = Group + "/instance-gpu-manufacturer"
@@ -118,5 +184,70 @@ var (
 	LabelInstanceAcceleratorName              = Group + "/instance-accelerator-name"
 	LabelInstanceAcceleratorManufacturer      = Group + "/instance-accelerator-manufacturer"
 	LabelInstanceAcceleratorCount             = Group + "/instance-accelerator-count"
+	LabelInstanceAcceleratorMemory            = Group + "/instance-accelerator-memory"
+	LabelCapacityReservationID                = Group + "/capacity-reservation-id"
 	AnnotationNodeClassHash                   = Group + "/nodeclass-hash"
+
+	// AnnotationAMIRollback is set to "true" on a NodeClass to launch its previous AMIs instead of the AMIs that its
+	// amiSelectorTerms resolve, which drifts the nodes that were launched with the current AMIs
+	AnnotationAMIRollback = Group + "/ami-rollback"
+
+	// AnnotationInvalidateAMIs is set on a NodeClass to resolve its AMIs again instead of using the AMIs that are cached
+	// for it, so that newly published AMIs are selected right away. It's removed once the AMIs are invalidated.
+	AnnotationInvalidateAMIs = Group + "/invalidate-amis"
+
+	// AnnotationDetailedMonitoring and AnnotationInstanceMetadataTags are set on a NodePool's template annotations
+	// to override the detailedMonitoring and metadataOptions.instanceMetadataTags of its NodeClass
+	AnnotationDetailedMonitoring   = Group + "/detailed-monitoring"
+	AnnotationInstanceMetadataTags = Group + "/instance-metadata-tags"
+
+	// AnnotationSpotInterruptionBehavior is set on a NodePool's template annotations to have EC2 stop or hibernate its
+	// spot instances on interruption instead of terminating them
+	AnnotationSpotInterruptionBehavior = Group + "/spot-interruption-behavior"
+
+	// AnnotationOnDemandFallback, AnnotationOnDemandFallbackAfterSpotFailures, AnnotationOnDemandFallbackAfter and
+	// AnnotationOnDemandFallbackMaxPercent are set on a NodePool's template annotations to control whether and when a
+	// NodePool that allows both spot and on-demand launches on-demand instances because spot capacity is unavailable
+	AnnotationOnDemandFallback                  = Group + "/on-demand-fallback"
+	AnnotationOnDemandFallbackAfterSpotFailures = Group + "/on-demand-fallback-after-spot-failures"
+	AnnotationOnDemandFallbackAfter             = Group + "/on-demand-fallback-after"
+	AnnotationOnDemandFallbackMaxPercent        = Group + "/on-demand-fallback-max-percent"
+
+	// AnnotationMaintenanceDeferred is set on a NodeClaim whose replacement for a scheduled maintenance event is deferred
+	// until its NodeClass' next maintenance window. The value is the time that the event is scheduled to start, if known.
+	AnnotationMaintenanceDeferred = Group + "/maintenance-deferred"
+
+	// AnnotationLaunchPrice is set on a NodeClaim and its node when the instance is launched. The value is the hourly price in
+	// USD of the offering that the instance was launched into, as known at launch time.
+	AnnotationLaunchPrice = Group + "/launch-price"
+
+	// AnnotationLaunchTimeline is set on a NodeClaim and its node when the instance is launched. The value is a JSON object
+	// with the time that each phase of the launch (resolution, launch_template, create_fleet) completed.
+	AnnotationLaunchTimeline = Group + "/launch-timeline"
+
+	// AnnotationDisruptionProtectedUntil is set on the node of a NodeClaim while it's younger than the minimum node lifetime
+	// of its NodeClass, along with the do-not-disrupt annotation. The value is the time that the protection ends.
+	AnnotationDisruptionProtectedUntil = Group + "/disruption-protected-until"
+
+	// AnnotationProvisioningTrigger is set on the NodeClaims that Karpenter creates for a provisioning trigger message from
+	// the aws.provisioningTriggerQueueName queue. The value is the id of the message.
+	AnnotationProvisioningTrigger = Group + "/provisioning-trigger"
+
+	// AnnotationLaunchSubnetID and AnnotationLaunchCapacityReservationID are set on a NodeClaim, usually through its
+	// NodePool's template annotations, to launch its instance into a specific subnet or capacity reservation without
+	// editing the NodeClass. The subnet must be one that the NodeClass selects.
+	AnnotationLaunchSubnetID              = Group + "/launch-subnet-id"
+	AnnotationLaunchCapacityReservationID = Group + "/launch-capacity-reservation-id"
+
+	// TagScheduledCapacityReservation is set on the capacity reservations that Karpenter creates for the scheduled
+	// capacity reservations of a NodeClass. The value is the name of the NodeClass.
+	TagScheduledCapacityReservation = Group + "/scheduled-capacity-reservation"
+
+	// TagOnDemandFallbackInstance is set to "true" on the on-demand instances that are launched for a NodePool that
+	// allows spot because spot capacity was unavailable
+	TagOnDemandFallbackInstance = Group + "/on-demand-fallback-instance"
+
+	// TagExclude is set on security groups and AMIs to take them out of rotation. Karpenter doesn't select resources
+	// with the tag, whatever its value, even if they match the selector terms of a NodeClass.
+	TagExclude = v1beta1.Group + "/exclude"
 )
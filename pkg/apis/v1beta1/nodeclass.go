@@ -15,10 +15,14 @@ limitations under the License.
 package v1beta1
 
 import (
+	"encoding/json"
 	"fmt"
+	"path"
+	"time"
 
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -35,9 +39,45 @@ type NodeClassSpec struct {
 	// AMISelectorTerms is a list of or ami selector terms. The terms are ORed.
 	// +optional
 	AMISelectorTerms []AMISelectorTerm `json:"amiSelectorTerms,omitempty" hash:"ignore"`
+	// AMIDeprecationPolicy is how Karpenter treats deprecated AMIs when selecting the AMIs to launch. Include, the
+	// default, selects the most recent AMI whether or not it's deprecated. Deprioritize only selects a deprecated AMI
+	// when no other AMI matches the same requirements. Exclude never selects deprecated AMIs.
+	// +kubebuilder:validation:Enum:={Include,Deprioritize,Exclude}
+	// +optional
+	AMIDeprecationPolicy *string `json:"amiDeprecationPolicy,omitempty" hash:"ignore"`
+	// AMISortStrategy is how Karpenter chooses between AMIs that match the same requirements, and orders the AMIs it
+	// selects. newest, the default, prefers the most recently created AMI. name-descending prefers the AMI whose name
+	// has the highest version, comparing the numbers in the names numerically. pinned prefers AMIs in the order of the
+	// AMI selector terms that select them, falling back to the most recently created AMI within a term.
+	// +kubebuilder:validation:Enum:={newest,name-descending,pinned}
+	// +optional
+	AMISortStrategy *string `json:"amiSortStrategy,omitempty" hash:"ignore"`
+	// AMIRollout canaries a candidate set of AMIs on a share of the launches, so that a new image can be tried on a
+	// few nodes before it replaces the AMIs that the NodeClass otherwise selects.
+	// +optional
+	AMIRollout *AMIRollout `json:"amiRollout,omitempty" hash:"ignore"`
 	// AMIFamily is the AMI family that instances use.
 	// +optional
 	AMIFamily *string `json:"amiFamily,omitempty"`
+	// AMIVariant selects a variant of the AMI family's default AMIs. The fips variant resolves the FIPS-enabled AMIs,
+	// e.g. the aws-k8s-1.28-fips and aws-k8s-1.28-nvidia-fips Bottlerocket variants. It's only supported for the AL2
+	// and Bottlerocket AMIFamilies.
+	// +kubebuilder:validation:Enum:={fips}
+	// +optional
+	AMIVariant *string `json:"amiVariant,omitempty" hash:"ignore"`
+	// UbuntuStream selects the stream of Canonical's EKS images that the Ubuntu AMIFamily resolves its default AMIs
+	// from. The pro stream resolves the Ubuntu Pro images, which include expanded security maintenance and
+	// livepatch, and the minimal stream resolves the minimal images, which ship with fewer packages. Defaults
+	// to the standard stream. It's only supported for the Ubuntu AMIFamily.
+	// +kubebuilder:validation:Enum:={standard,pro,minimal}
+	// +optional
+	UbuntuStream *string `json:"ubuntuStream,omitempty" hash:"ignore"`
+	// TrustedBoot requires instances to boot with NitroTPM or UEFI Secure Boot, so that confidential and attested
+	// workloads only run on nodes whose boot can be measured and verified. Only the AMIs that support it are selected,
+	// and they're only launched on instance types that support it. Launch templates have no parameters for either, so
+	// it's enforced through the AMIs and instance types that instances are launched with.
+	// +optional
+	TrustedBoot *TrustedBoot `json:"trustedBoot,omitempty"`
 	// UserData to be applied to the provisioned nodes.
 	// It must be in the appropriate format based on the AMIFamily in use. Karpenter will merge certain fields into
 	// this UserData to ensure nodes are being provisioned with the correct configuration.
@@ -55,6 +95,84 @@ type NodeClassSpec struct {
 	// DetailedMonitoring controls if detailed monitoring is enabled for instances that are launched
 	// +optional
 	DetailedMonitoring *bool `json:"detailedMonitoring,omitempty"`
+	// CloudWatchAgent installs and configures an agent on provisioned nodes during bootstrap that sends the node's
+	// metrics and logs to Amazon CloudWatch.
+	// +optional
+	CloudWatchAgent *CloudWatchAgent `json:"cloudWatchAgent,omitempty"`
+	// DomainJoin joins Windows nodes to an Active Directory domain during bootstrap so that pods can run with a
+	// domain identity. Only supported by the Windows AMI families.
+	// +optional
+	DomainJoin *DomainJoin `json:"domainJoin,omitempty"`
+	// Neuron installs and loads the AWS Neuron driver on inf and trn instance types before the kubelet starts, so
+	// that the Neuron device plugin can advertise devices as soon as the node is ready. Only supported by the AL2
+	// and Ubuntu AMI families.
+	// +optional
+	Neuron *Neuron `json:"neuron,omitempty"`
+	// CPUOptions set the number of CPU cores and threads per core that instances are launched with, e.g. to disable
+	// simultaneous multithreading, or to run software that is licensed per core on fewer cores. Instance types that
+	// don't support the options aren't launched, and the vCPU capacity of the others is their cores times threads.
+	// +optional
+	CPUOptions *CPUOptions `json:"cpuOptions,omitempty"`
+	// MaintenanceWindows restrict when Karpenter replaces nodes in response to changes on the AWS side: drift from
+	// newly resolved AMIs, security groups or subnets, and scheduled maintenance health events. Replacements outside
+	// of a window are queued until the next window opens. Spot interruptions and instance state changes are always
+	// handled immediately. When no windows are specified, replacements can happen at any time.
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty" hash:"ignore"`
+	// RegistrationTTL is how long a launched node has to register with the cluster before it's considered failed, and
+	// its instance is terminated so that its pods can be scheduled elsewhere. Lowering it makes nodes that boot
+	// quickly fail fast, and raising it gives nodes that boot slowly, like Windows, metal and GPU nodes, more time.
+	// It defaults to 15m and can't exceed 1h.
+	// +kubebuilder:validation:Type="string"
+	// +kubebuilder:validation:Pattern:="^([0-9]+(s|m|h))+$"
+	// +optional
+	RegistrationTTL *metav1.Duration `json:"registrationTTL,omitempty" hash:"ignore"`
+	// SecurityGroupDriftRemediation is how Karpenter remediates nodes whose security groups no longer match the
+	// security groups resolved for the NodeClass, when nothing else about them has drifted. Replace, the default,
+	// replaces the nodes. InPlace updates the security groups of the nodes' primary network interfaces instead.
+	// +kubebuilder:validation:Enum:={Replace,InPlace}
+	// +optional
+	SecurityGroupDriftRemediation *string `json:"securityGroupDriftRemediation,omitempty" hash:"ignore"`
+	// MinimumNodeLifetime is how long after a node is launched Karpenter won't voluntarily disrupt it, whether through
+	// drift, expiration or consolidation, so that repeated changes to AMIs or tags in a short time don't replace the
+	// same nodes over and over. Interruptions are still handled immediately.
+	// +kubebuilder:validation:Type="string"
+	// +kubebuilder:validation:Pattern:="^([0-9]+(s|m|h))+$"
+	// +optional
+	MinimumNodeLifetime *metav1.Duration `json:"minimumNodeLifetime,omitempty" hash:"ignore"`
+	// ScheduledCapacityReservations are targeted On-Demand Capacity Reservations that Karpenter creates shortly before
+	// recurring, anticipated scale-ups and cancels once they're over, so that predictable bursts get the capacity they
+	// need. Nodes launched for the NodeClass while a reservation is held are launched into it when it fits them.
+	// +optional
+	ScheduledCapacityReservations []ScheduledCapacityReservation `json:"scheduledCapacityReservations,omitempty" hash:"ignore"`
+	// InstanceTypeOverrides adjust the capacity and reserved resources of specific instance types, e.g. to reserve
+	// extra memory for the driver on GPU instance types. The first override that matches an instance type applies.
+	// +optional
+	InstanceTypeOverrides []InstanceTypeOverride `json:"instanceTypeOverrides,omitempty" hash:"ignore"`
+	// KubeReserved selects how the resources that the kubelet reserves for Kubernetes system daemons are computed for
+	// instance types, e.g. to reserve memory in proportion to the memory of instance types rather than to their pods.
+	// Nodes launched with a formula other than EKS pass the computed reservation to the kubelet, so their allocatable
+	// resources match the ones pods were scheduled against. The kubelet's kubeReserved takes precedence over it.
+	// +optional
+	KubeReserved *KubeReserved `json:"kubeReserved,omitempty" hash:"ignore"`
+	// ExcludedInstanceClasses are classes of instance types that the NodeClass never launches, in addition to the
+	// classes excluded by aws.excludedInstanceClasses. metal excludes bare metal instance types, previous-generation
+	// excludes instance types that aren't of the current generation, burstable excludes instance types with burstable
+	// CPU credits, and odd-sized excludes instance types that are larger than large but not a power of two multiple
+	// of it, e.g. m5.3xlarge or m5.12xlarge.
+	// +optional
+	ExcludedInstanceClasses []string `json:"excludedInstanceClasses,omitempty" hash:"ignore"`
+	// InstanceSelectionMode is how launches tell EC2 Fleet which instance types it may choose from. InstanceTypes, the
+	// default, passes an override for each instance type and zone. AttributeBased passes an override for each zone with
+	// instance requirements, i.e. the vCPU, memory and accelerator count ranges of the instance types that the launch
+	// allows, and lets EC2 Fleet choose the capacity within them.
+	// +kubebuilder:validation:Enum:={InstanceTypes,AttributeBased}
+	// +optional
+	InstanceSelectionMode *string `json:"instanceSelectionMode,omitempty" hash:"ignore"`
+	// CapacityTypeOverrides vary the tags, userData and detailed monitoring of the instances that are launched with a
+	// capacity type, e.g. to configure different agents on spot instances than on on-demand instances.
+	// +optional
+	CapacityTypeOverrides []CapacityTypeOverride `json:"capacityTypeOverrides,omitempty"`
 	// MetadataOptions for the generated launch template of provisioned nodes.
 	//
 	// This specifies the exposure of the Instance Metadata Service to
@@ -150,9 +268,47 @@ type AMISelectorTerm struct {
 	// You can specify a combination of AWS account IDs, "self", "amazon", and "aws-marketplace"
 	// +optional
 	Owner string `json:"owner,omitempty"`
-	// SSM is the ssm alias for an ami.
+	// SSM is the name of an ssm parameter that's set to the id of an ami, such as the output of a golden image
+	// pipeline. The parameter is resolved in the same way as the ssm parameters of the default amis.
 	// +optional
 	SSM string `json:"ssm,omitempty"`
+	// ImageBuilderARN is the arn of an EC2 Image Builder image pipeline or image recipe, such as
+	// arn:aws:imagebuilder:us-west-2:111122223333:image-pipeline/golden-al2. The ami of the newest available image
+	// that the pipeline or recipe built in the region of the arn is selected.
+	// +optional
+	ImageBuilderARN string `json:"imageBuilderArn,omitempty"`
+	// RoleARN is the arn of an IAM role, such as one in a central account that builds golden images, that's assumed to
+	// describe the images that the term selects. The images are described as the role sees them, so the tags that the
+	// owning account adds to its images can be selected on, but they must still be shared with the cluster's account to
+	// be launched.
+	// +optional
+	RoleARN string `json:"roleArn,omitempty"`
+	// MinCreationDate selects only the images that were created at or after this time.
+	// +optional
+	MinCreationDate *metav1.Time `json:"minCreationDate,omitempty"`
+	// MaxCreationDate selects only the images that were created at or before this time.
+	// +optional
+	MaxCreationDate *metav1.Time `json:"maxCreationDate,omitempty"`
+	// MaxAge selects only the images that were created within this duration of the time the images are resolved,
+	// such as "720h" for the images that are at most 30 days old.
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+// AMIRollout defines the candidate AMIs of a rollout and the share of the launches that use them.
+type AMIRollout struct {
+	// AMISelectorTerms select the candidate AMIs. The terms are ORed.
+	// +kubebuilder:validation:MinItems:=1
+	AMISelectorTerms []AMISelectorTerm `json:"amiSelectorTerms"`
+	// Weight is the percentage of launches that use the candidate AMIs. The other launches, and the launches of
+	// instance types that no candidate AMI is compatible with, use the AMIs that the NodeClass otherwise selects.
+	// +kubebuilder:validation:Minimum:=0
+	// +kubebuilder:validation:Maximum:=100
+	Weight int32 `json:"weight"`
+	// OriginalAMISelector is the original ami selector of the rollout that was used by the v1alpha5 representation of this API.
+	// DO NOT USE THIS VALUE when performing business logic in code
+	// +optional
+	OriginalAMISelector map[string]string `json:"-" hash:"ignore"`
 }
 
 // MetadataOptions contains parameters for specifying the exposure of the
@@ -194,6 +350,310 @@ type MetadataOptions struct {
 	// 1.0 credentials are not available.
 	// +optional
 	HTTPTokens *string `json:"httpTokens,omitempty"`
+	// InstanceMetadataTags enables or disables access to the instance's tags
+	// from the instance metadata service on provisioned nodes. If metadata
+	// options is non-nil, but this parameter is not specified, the default
+	// state is "disabled".
+	// +optional
+	InstanceMetadataTags *string `json:"instanceMetadataTags,omitempty"`
+}
+
+// CloudWatchAgent configures an agent that is installed on nodes during bootstrap to send metrics and logs to
+// Amazon CloudWatch. Exactly one of Config or ConfigSSMParameter must be set.
+type CloudWatchAgent struct {
+	// Type of the agent to install. CloudWatchAgent installs the Amazon CloudWatch agent. FluentBit installs Fluent Bit,
+	// which can send container logs to CloudWatch for Container Insights. Defaults to CloudWatchAgent.
+	// +kubebuilder:validation:Enum:={CloudWatchAgent,FluentBit}
+	// +optional
+	Type *string `json:"type,omitempty"`
+	// Config is the configuration of the agent, in JSON for the CloudWatch agent or in the classic configuration
+	// format for Fluent Bit.
+	// +optional
+	Config *string `json:"config,omitempty"`
+	// ConfigSSMParameter is the name of an SSM parameter that contains the configuration of the agent. The node's
+	// role must be allowed to get the parameter.
+	// +optional
+	ConfigSSMParameter *string `json:"configSSMParameter,omitempty"`
+}
+
+// DomainJoin configures Windows nodes to join an Active Directory domain during bootstrap. Exactly one of
+// DirectoryID or SSMDocument must be set.
+type DomainJoin struct {
+	// DirectoryID is the ID of an AWS Directory Service directory that nodes join using the
+	// AWS-JoinDirectoryServiceDomain SSM document.
+	// +kubebuilder:validation:Pattern:="^d-[0-9a-f]{10}$"
+	// +optional
+	DirectoryID *string `json:"directoryID,omitempty"`
+	// DirectoryName is the fully qualified domain name of the directory, such as corp.example.com. Required
+	// when DirectoryID is set.
+	// +optional
+	DirectoryName *string `json:"directoryName,omitempty"`
+	// DNSIPAddresses are the IP addresses of the directory's DNS servers. When unset, the DNS servers
+	// registered with the directory are used.
+	// +optional
+	DNSIPAddresses []string `json:"dnsIPAddresses,omitempty"`
+	// SSMDocument is the name of an SSM document that joins the node to a domain, for directories that are
+	// not managed by AWS Directory Service.
+	// +optional
+	SSMDocument *string `json:"ssmDocument,omitempty"`
+	// SSMDocumentParameters are passed to SSMDocument when it is run.
+	// +optional
+	SSMDocumentParameters map[string]string `json:"ssmDocumentParameters,omitempty"`
+	// GMSA installs the prerequisites for running pods as a group Managed Service Account (gMSA) on the node,
+	// including the Active Directory module for Windows PowerShell.
+	// +optional
+	GMSA *bool `json:"gmsa,omitempty"`
+}
+
+// Neuron configures the AWS Neuron driver on inf and trn instance types during bootstrap.
+type Neuron struct {
+	// DriverVersion pins the version of the aws-neuronx-dkms package that is installed when the AMI doesn't
+	// already include the Neuron driver. Defaults to the latest version in the Neuron repository.
+	// +optional
+	DriverVersion *string `json:"driverVersion,omitempty"`
+}
+
+// CPUOptions configure the processor of launched instances. Options that aren't set default to the instance type's.
+type CPUOptions struct {
+	// CoreCount is the number of CPU cores, which must be one of the valid core counts of the instance types.
+	// +kubebuilder:validation:Minimum:=1
+	// +optional
+	CoreCount *int64 `json:"coreCount,omitempty"`
+	// ThreadsPerCore is the number of threads per CPU core. Setting it to 1 disables simultaneous multithreading.
+	// +kubebuilder:validation:Enum:={1,2}
+	// +optional
+	ThreadsPerCore *int64 `json:"threadsPerCore,omitempty"`
+}
+
+// TrustedBoot configures the boot integrity features that instances are required to launch with.
+type TrustedBoot struct {
+	// NitroTPM requires AMIs that support NitroTPM 2.0, which are only launched on instance types that support
+	// NitroTPM. NitroTPM relies on UEFI, so the AMIs must boot with UEFI as well.
+	// +optional
+	NitroTPM bool `json:"nitroTPM,omitempty"`
+	// SecureBoot requires AMIs that boot with UEFI and were registered with the UEFI variables that hold the Secure Boot
+	// keys. They're only launched on instance types that support UEFI.
+	// +optional
+	SecureBoot bool `json:"secureBoot,omitempty"`
+}
+
+// Required returns whether instances are required to boot with NitroTPM or Secure Boot
+func (in *TrustedBoot) Required() bool {
+	return in != nil && (in.NitroTPM || in.SecureBoot)
+}
+
+// MaintenanceWindow is a recurring period of time, in UTC, during which AWS-driven node replacements are allowed.
+type MaintenanceWindow struct {
+	// Days of the week on which the window opens, e.g. Saturday. Defaults to every day.
+	// +optional
+	Days []string `json:"days,omitempty"`
+	// Start is the time of day at which the window opens, in UTC and in 24-hour HH:MM format.
+	// +kubebuilder:validation:Pattern:="^([01][0-9]|2[0-3]):[0-5][0-9]$"
+	// +required
+	Start string `json:"start"`
+	// Duration is how long the window stays open, up to a week.
+	// +kubebuilder:validation:Type="string"
+	// +kubebuilder:validation:Pattern:="^([0-9]+(s|m|h))+$"
+	// +required
+	Duration metav1.Duration `json:"duration"`
+}
+
+// ScheduledCapacityReservation is a targeted On-Demand Capacity Reservation that is held, in UTC, from shortly before a
+// recurring scale-up starts until it's over.
+type ScheduledCapacityReservation struct {
+	// InstanceType to reserve capacity for.
+	// +required
+	InstanceType string `json:"instanceType"`
+	// AvailabilityZone to reserve capacity in.
+	// +required
+	AvailabilityZone string `json:"availabilityZone"`
+	// InstanceCount is the number of instances to reserve capacity for.
+	// +kubebuilder:validation:Minimum:=1
+	// +required
+	InstanceCount int64 `json:"instanceCount"`
+	// Days of the week on which the scale-up happens, e.g. Monday. Defaults to every day.
+	// +optional
+	Days []string `json:"days,omitempty"`
+	// Start is the time of day at which the scale-up starts, in UTC and in 24-hour HH:MM format.
+	// +kubebuilder:validation:Pattern:="^([01][0-9]|2[0-3]):[0-5][0-9]$"
+	// +required
+	Start string `json:"start"`
+	// Duration is how long the capacity stays reserved after the scale-up starts, up to a day.
+	// +kubebuilder:validation:Type="string"
+	// +kubebuilder:validation:Pattern:="^([0-9]+(s|m|h))+$"
+	// +required
+	Duration metav1.Duration `json:"duration"`
+}
+
+// InstanceTypeOverride adjusts the resources that Karpenter assumes instance types have when it schedules pods to them.
+type InstanceTypeOverride struct {
+	// InstanceTypes that the override applies to, e.g. p4d.24xlarge. Names may contain * wildcards, e.g. g5.*.
+	// +kubebuilder:validation:MinItems:=1
+	// +required
+	InstanceTypes []string `json:"instanceTypes"`
+	// Capacity replaces the capacity that the instance types are assumed to have for each of the resources when pods
+	// are scheduled to them, e.g. to schedule fewer GPUs than the instance types have. Only an overridden pods
+	// capacity is passed to nodes, as the kubelet's max pods. Nodes report their actual capacity of other resources.
+	// +optional
+	Capacity v1.ResourceList `json:"capacity,omitempty"`
+	// SystemReserved is reserved on the instance types in addition to the kubelet's systemReserved, e.g. for the
+	// memory that a GPU driver uses. Nodes launched with the instance types pass the same reservation to the kubelet,
+	// so their allocatable resources match the ones pods were scheduled against.
+	// +optional
+	SystemReserved v1.ResourceList `json:"systemReserved,omitempty"`
+	// VMMemoryOverheadPercent replaces aws.vmMemoryOverheadPercent for the instance types, as a decimal between 0 and
+	// 1, e.g. 0.01 for bare metal instance types, which have no hypervisor.
+	// +kubebuilder:validation:Pattern:="^0(\\.[0-9]+)?$"
+	// +optional
+	VMMemoryOverheadPercent *string `json:"vmMemoryOverheadPercent,omitempty"`
+}
+
+// KubeReserved selects the formula that computes the kube reserved resources of instance types.
+type KubeReserved struct {
+	// Formula that computes the reservation. EKS, the default, reserves 6% of the first core, 1% of the second, 0.5%
+	// of the third and fourth and 0.25% of the other cores, 11Mi of memory per pod plus 255Mi, and 1Gi of ephemeral
+	// storage, like the bootstrap scripts of the EKS optimized AMIs. GKE reserves the same CPU and ephemeral storage,
+	// and 25% of the first 4Gi of memory, 20% of the next 4Gi, 10% of the next 8Gi, 6% of the next 112Gi and 2% of the
+	// rest, or 255Mi on instance types with less than 1Gi. Custom reserves the resources that its steps specify.
+	// +kubebuilder:validation:Enum:={EKS,GKE,Custom}
+	// +optional
+	Formula *string `json:"formula,omitempty"`
+	// CPU are the steps in which the Custom formula reserves CPU.
+	// +optional
+	CPU []ReservedStep `json:"cpu,omitempty"`
+	// Memory are the steps in which the Custom formula reserves memory.
+	// +optional
+	Memory []ReservedStep `json:"memory,omitempty"`
+	// MemoryPerPod is the memory that the Custom formula reserves for each pod that instance types fit, in addition
+	// to the memory steps.
+	// +optional
+	MemoryPerPod *resource.Quantity `json:"memoryPerPod,omitempty"`
+	// BaseMemory is the memory that the Custom formula reserves on every instance type, in addition to the memory
+	// steps.
+	// +optional
+	BaseMemory *resource.Quantity `json:"baseMemory,omitempty"`
+	// EphemeralStorage is the ephemeral storage that the Custom formula reserves. Defaults to 1Gi.
+	// +optional
+	EphemeralStorage *resource.Quantity `json:"ephemeralStorage,omitempty"`
+}
+
+// ReservedStep reserves a ratio of the amount of a resource of instance types between the upTo of the previous step,
+// or zero for the first step, and its own upTo.
+type ReservedStep struct {
+	// UpTo is the amount of the resource that the step ends at. The last step may omit it to apply to the rest of the
+	// resource.
+	// +optional
+	UpTo *resource.Quantity `json:"upTo,omitempty"`
+	// Ratio of the resource in the step that's reserved, as a decimal between 0 and 1, e.g. 0.06.
+	// +kubebuilder:validation:Pattern:="^(0(\\.[0-9]+)?|1(\\.0+)?)$"
+	// +required
+	Ratio string `json:"ratio"`
+}
+
+// CapacityTypeOverride adjusts the launch configuration of the instances that are launched with a capacity type.
+type CapacityTypeOverride struct {
+	// CapacityType that the override applies to.
+	// +kubebuilder:validation:Enum:={spot,on-demand}
+	// +required
+	CapacityType string `json:"capacityType"`
+	// Tags are applied to the instances in addition to the tags of the NodeClass, and take precedence over them.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+	// UserData replaces the userData of the NodeClass for the instances.
+	// +optional
+	UserData *string `json:"userData,omitempty"`
+	// DetailedMonitoring replaces the detailedMonitoring of the NodeClass for the instances.
+	// +optional
+	DetailedMonitoring *bool `json:"detailedMonitoring,omitempty"`
+}
+
+// InMaintenanceWindow returns true if AWS-driven replacements are allowed at the given time, either because no
+// maintenance windows are specified or because one of them is open
+func (in *NodeClassSpec) InMaintenanceWindow(now time.Time) bool {
+	if len(in.MaintenanceWindows) == 0 {
+		return true
+	}
+	return lo.ContainsBy(in.MaintenanceWindows, func(w MaintenanceWindow) bool { return w.IsOpen(now) })
+}
+
+// IsAttributeBasedInstanceSelection returns whether launches let EC2 Fleet choose between instance types by their
+// instance requirements
+func (in *NodeClassSpec) IsAttributeBasedInstanceSelection() bool {
+	return lo.FromPtr(in.InstanceSelectionMode) == InstanceSelectionModeAttributeBased
+}
+
+// KubeReservedFormula returns the formula that computes the kube reserved resources of instance types
+func (in *NodeClassSpec) KubeReservedFormula() string {
+	if in.KubeReserved == nil || in.KubeReserved.Formula == nil {
+		return KubeReservedFormulaEKS
+	}
+	return *in.KubeReserved.Formula
+}
+
+// InstanceTypeOverride returns the first of the instance type overrides that applies to the instance type, if any
+func (in *NodeClassSpec) InstanceTypeOverride(instanceType string) (*InstanceTypeOverride, bool) {
+	for i := range in.InstanceTypeOverrides {
+		if lo.ContainsBy(in.InstanceTypeOverrides[i].InstanceTypes, func(pattern string) bool {
+			matched, _ := path.Match(pattern, instanceType)
+			return matched
+		}) {
+			return &in.InstanceTypeOverrides[i], true
+		}
+	}
+	return nil, false
+}
+
+// CapacityTypeOverride returns the override for the capacity type, if any
+func (in *NodeClassSpec) CapacityTypeOverride(capacityType string) (*CapacityTypeOverride, bool) {
+	for i := range in.CapacityTypeOverrides {
+		if in.CapacityTypeOverrides[i].CapacityType == capacityType {
+			return &in.CapacityTypeOverrides[i], true
+		}
+	}
+	return nil, false
+}
+
+// IsOpen returns true if the maintenance window is open at the given time
+func (in *MaintenanceWindow) IsOpen(now time.Time) bool {
+	start, err := time.Parse(maintenanceWindowStartFormat, in.Start)
+	if err != nil {
+		return false
+	}
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), start.Hour(), start.Minute(), 0, 0, time.UTC)
+	// Windows last up to a week, so a window that opened on any of the past seven days may still be open
+	for i := 0; i <= 7; i++ {
+		opened := today.AddDate(0, 0, -i)
+		if len(in.Days) > 0 && !lo.Contains(in.Days, opened.Weekday().String()) {
+			continue
+		}
+		if !now.Before(opened) && now.Before(opened.Add(in.Duration.Duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// HeldAt returns the time at which the scale-up that the scheduled reservation is held for starts, if the reservation
+// is held at the given time. Reservations are held from leadTime before the scale-up starts until it's over.
+func (in *ScheduledCapacityReservation) HeldAt(now time.Time, leadTime time.Duration) (time.Time, bool) {
+	start, err := time.Parse(maintenanceWindowStartFormat, in.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), start.Hour(), start.Minute(), 0, 0, time.UTC)
+	// Reservations are held for up to a day, so the scale-up may have started yesterday or may start tomorrow
+	for i := -1; i <= 1; i++ {
+		startsAt := today.AddDate(0, 0, i)
+		if len(in.Days) > 0 && !lo.Contains(in.Days, startsAt.Weekday().String()) {
+			continue
+		}
+		if !now.Before(startsAt.Add(-leadTime)) && now.Before(startsAt.Add(in.Duration.Duration)) {
+			return startsAt, true
+		}
+	}
+	return time.Time{}, false
 }
 
 type BlockDeviceMapping struct {
@@ -283,11 +743,17 @@ type NodeClass struct {
 }
 
 func (a *NodeClass) Hash() string {
-	return fmt.Sprint(lo.Must(hashstructure.Hash(a.Spec, hashstructure.FormatV2, &hashstructure.HashOptions{
+	hash := lo.Must(hashstructure.Hash(a.Spec, hashstructure.FormatV2, &hashstructure.HashOptions{
 		SlicesAsSets:    true,
 		IgnoreZeroValue: true,
 		ZeroNil:         true,
-	})))
+	}))
+	// Quantities don't export their values, so a kube reserved formula other than the default is hashed by its JSON
+	// representation, which leaves the hash of NodeClasses with the default formula unchanged
+	if a.Spec.KubeReservedFormula() != KubeReservedFormulaEKS {
+		hash = lo.Must(hashstructure.Hash([]interface{}{hash, string(lo.Must(json.Marshal(a.Spec.KubeReserved)))}, hashstructure.FormatV2, nil))
+	}
+	return fmt.Sprint(hash)
 }
 
 // NodeClassList contains a list of NodeClass
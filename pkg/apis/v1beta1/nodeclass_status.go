@@ -14,7 +14,11 @@ limitations under the License.
 
 package v1beta1
 
-import v1 "k8s.io/api/core/v1"
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+)
 
 // Subnet contains resolved Subnet selector values utilized for node launch
 type Subnet struct {
@@ -44,11 +48,28 @@ type AMI struct {
 	// Name of the AMI
 	// +optional
 	Name string `json:"name,omitempty"`
+	// CreationDate of the AMI, in RFC3339 format
+	// +optional
+	CreationDate string `json:"creationDate,omitempty"`
 	// Requirements of the AMI to be utilized on an instance type
 	// +required
 	Requirements []v1.NodeSelectorRequirement `json:"requirements"`
 }
 
+// ExcludedInstanceType is an instance type that isn't launched for the NodeClass for a while, because its nodes
+// repeatedly failed to launch or register
+type ExcludedInstanceType struct {
+	// Name of the instance type
+	// +required
+	Name string `json:"name"`
+	// ExcludedUntil is when the instance type is launched for the NodeClass again
+	// +required
+	ExcludedUntil metav1.Time `json:"excludedUntil"`
+	// Reason is the failure that caused the instance type to be excluded
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
 // NodeClassStatus contains the resolved state of the NodeClass
 type NodeClassStatus struct {
 	// Subnets contains the current Subnet values that are available to the
@@ -63,4 +84,39 @@ type NodeClassStatus struct {
 	// cluster under the AMI selectors.
 	// +optional
 	AMIs []AMI `json:"amis,omitempty"`
+	// PreviousAMIs contains the AMI values that were available to the cluster before the current AMIs. They're
+	// launched instead of the AMI selectors' AMIs while the NodeClass is annotated to roll back its AMIs.
+	// +optional
+	PreviousAMIs []AMI `json:"previousAMIs,omitempty"`
+	// ExcludedInstanceTypes contains the instance types that aren't launched for the NodeClass for a while, because
+	// their nodes repeatedly failed to register
+	// +optional
+	ExcludedInstanceTypes []ExcludedInstanceType `json:"excludedInstanceTypes,omitempty"`
+	// Conditions contains signals for the health of the NodeClass
+	// +optional
+	Conditions apis.Conditions `json:"conditions,omitempty"`
+}
+
+var (
+	// NodeClassEBSEncryptionCompliant is false when ebs encryption is required and the NodeClass
+	// would launch instances with unencrypted volumes
+	NodeClassEBSEncryptionCompliant apis.ConditionType = "EBSEncryptionCompliant"
+	// NodeClassTagPolicyCompliant is false when tag policies are validated and the tags of the NodeClass
+	// don't comply with the effective tag policy of the account
+	NodeClassTagPolicyCompliant apis.ConditionType = "TagPolicyCompliant"
+	// NodeClassAMIsResolved is false when the AMI selectors of the NodeClass resolve to no images, in which case
+	// nothing is launched from it
+	NodeClassAMIsResolved apis.ConditionType = "AMIsResolved"
+)
+
+func (in *NodeClass) StatusConditions() apis.ConditionManager {
+	return apis.NewLivingConditionSet().Manage(in)
+}
+
+func (in *NodeClass) GetConditions() apis.Conditions {
+	return in.Status.Conditions
+}
+
+func (in *NodeClass) SetConditions(conditions apis.Conditions) {
+	in.Status.Conditions = conditions
 }
@@ -17,29 +17,60 @@ package v1beta1
 import (
 	"context"
 	"fmt"
+	"net"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/samber/lo"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/pkg/apis"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 )
 
 const (
-	userDataPath                   = "userData"
-	subnetSelectorTermsPath        = "subnetSelectorTerms"
-	securityGroupSelectorTermsPath = "securityGroupSelectorTerms"
-	amiSelectorTermsPath           = "amiSelectorTerms"
-	amiFamilyPath                  = "amiFamily"
-	tagsPath                       = "tags"
-	metadataOptionsPath            = "metadataOptions"
-	blockDeviceMappingsPath        = "blockDeviceMappings"
+	userDataPath                      = "userData"
+	subnetSelectorTermsPath           = "subnetSelectorTerms"
+	securityGroupSelectorTermsPath    = "securityGroupSelectorTerms"
+	amiSelectorTermsPath              = "amiSelectorTerms"
+	amiRolloutPath                    = "amiRollout"
+	amiFamilyPath                     = "amiFamily"
+	amiVariantPath                    = "amiVariant"
+	ubuntuStreamPath                  = "ubuntuStream"
+	tagsPath                          = "tags"
+	metadataOptionsPath               = "metadataOptions"
+	blockDeviceMappingsPath           = "blockDeviceMappings"
+	cloudWatchAgentPath               = "cloudWatchAgent"
+	domainJoinPath                    = "domainJoin"
+	neuronPath                        = "neuron"
+	cpuOptionsPath                    = "cpuOptions"
+	maintenanceWindowsPath            = "maintenanceWindows"
+	registrationTTLPath               = "registrationTTL"
+	minimumNodeLifetimePath           = "minimumNodeLifetime"
+	scheduledCapacityReservationsPath = "scheduledCapacityReservations"
+	instanceTypeOverridesPath         = "instanceTypeOverrides"
+	kubeReservedPath                  = "kubeReserved"
+	excludedInstanceClassesPath       = "excludedInstanceClasses"
+	capacityTypeOverridesPath         = "capacityTypeOverrides"
+
+	maintenanceWindowStartFormat = "15:04"
+	maxMaintenanceWindowDuration = 7 * 24 * time.Hour
+	// maxRegistrationTTL bounds the registration TTL, since nodes that take longer than this to register are stuck
+	maxRegistrationTTL                      = time.Hour
+	maxScheduledCapacityReservationDuration = 24 * time.Hour
 )
 
 var (
 	minVolumeSize = *resource.NewScaledQuantity(1, resource.Giga)
 	maxVolumeSize = *resource.NewScaledQuantity(64, resource.Tera)
+	weekdays      = lo.Times(7, func(i int) string { return time.Weekday(i).String() })
 )
 
 func (a *NodeClass) SupportedVerbs() []admissionregistrationv1.OperationType {
@@ -61,11 +92,26 @@ func (in *NodeClassSpec) validate(_ context.Context) (errs *apis.FieldError) {
 		in.validateSubnetSelectorTerms().ViaField(subnetSelectorTermsPath),
 		in.validateSecurityGroupSelectorTerms().ViaField(securityGroupSelectorTermsPath),
 		in.validateAMISelectorTerms().ViaField(amiSelectorTermsPath),
+		in.validateAMIRollout().ViaField(amiRolloutPath),
 		in.validateMetadataOptions().ViaField(metadataOptionsPath),
 		in.validateAMIFamily().ViaField(amiFamilyPath),
+		in.validateAMIVariant().ViaField(amiVariantPath),
+		in.validateUbuntuStream().ViaField(ubuntuStreamPath),
 		in.validateBlockDeviceMappings().ViaField(blockDeviceMappingsPath),
 		in.validateUserData().ViaField(userDataPath),
 		in.validateTags().ViaField(tagsPath),
+		in.validateCloudWatchAgent().ViaField(cloudWatchAgentPath),
+		in.validateDomainJoin().ViaField(domainJoinPath),
+		in.validateNeuron().ViaField(neuronPath),
+		in.validateCPUOptions().ViaField(cpuOptionsPath),
+		in.validateMaintenanceWindows().ViaField(maintenanceWindowsPath),
+		in.validateRegistrationTTL(),
+		in.validateMinimumNodeLifetime(),
+		in.validateScheduledCapacityReservations().ViaField(scheduledCapacityReservationsPath),
+		in.validateInstanceTypeOverrides().ViaField(instanceTypeOverridesPath),
+		in.validateKubeReserved().ViaField(kubeReservedPath),
+		in.validateExcludedInstanceClasses().ViaField(excludedInstanceClassesPath),
+		in.validateCapacityTypeOverrides().ViaField(capacityTypeOverridesPath),
 	)
 }
 
@@ -119,14 +165,45 @@ func (in *NodeClassSpec) validateAMISelectorTerms() (errs *apis.FieldError) {
 	return errs
 }
 
+func (in *NodeClassSpec) validateAMIRollout() (errs *apis.FieldError) {
+	if in.AMIRollout == nil {
+		return nil
+	}
+	if len(in.AMIRollout.AMISelectorTerms) == 0 {
+		errs = errs.Also(apis.ErrMissingField(amiSelectorTermsPath))
+	}
+	for _, term := range in.AMIRollout.AMISelectorTerms {
+		errs = errs.Also(term.validate().ViaField(amiSelectorTermsPath))
+	}
+	if in.AMIRollout.Weight < 0 || in.AMIRollout.Weight > 100 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(in.AMIRollout.Weight, 0, 100, "weight"))
+	}
+	return errs
+}
+
 //nolint:gocyclo
 func (in *AMISelectorTerm) validate() (errs *apis.FieldError) {
 	errs = errs.Also(validateTags(in.Tags).ViaField("tags"))
-	if len(in.Tags) == 0 && in.ID == "" && in.Name == "" && in.SSM == "" {
-		errs = errs.Also(apis.ErrGeneric("expect at least one, got none", "tags", "id", "name", "ssm"))
-	} else if in.ID != "" && (len(in.Tags) > 0 || in.Name != "" || in.SSM != "" || in.Owner != "") {
+	if len(in.Tags) == 0 && in.ID == "" && in.Name == "" && in.SSM == "" && in.ImageBuilderARN == "" {
+		errs = errs.Also(apis.ErrGeneric("expect at least one, got none", "tags", "id", "name", "ssm", "imageBuilderArn"))
+	} else if in.ID != "" && (len(in.Tags) > 0 || in.Name != "" || in.SSM != "" || in.ImageBuilderARN != "" || in.Owner != "") {
 		errs = errs.Also(apis.ErrGeneric(`"id" is mutually exclusive, cannot be set with a combination of other fields in`))
 	}
+	if in.SSM != "" && in.ImageBuilderARN != "" {
+		errs = errs.Also(apis.ErrMultipleOneOf("ssm", "imageBuilderArn"))
+	}
+	if in.ImageBuilderARN != "" && !validImageBuilderARN(in.ImageBuilderARN) {
+		errs = errs.Also(apis.ErrInvalidValue(in.ImageBuilderARN, "imageBuilderArn", "must be the arn of an image builder image pipeline or image recipe"))
+	}
+	if in.RoleARN != "" && !validRoleARN(in.RoleARN) {
+		errs = errs.Also(apis.ErrInvalidValue(in.RoleARN, "roleArn", "must be the arn of an iam role"))
+	}
+	if in.MinCreationDate != nil && in.MaxCreationDate != nil && in.MaxCreationDate.Before(in.MinCreationDate) {
+		errs = errs.Also(apis.ErrGeneric("maxCreationDate must not be before minCreationDate", "minCreationDate", "maxCreationDate"))
+	}
+	if in.MaxAge != nil && in.MaxAge.Duration <= 0 {
+		errs = errs.Also(apis.ErrInvalidValue(in.MaxAge.Duration, "maxAge", "must be greater than 0s"))
+	}
 	return errs
 }
 
@@ -151,6 +228,7 @@ func (in *NodeClassSpec) validateMetadataOptions() (errs *apis.FieldError) {
 		in.validateHTTPProtocolIpv6(),
 		in.validateHTTPPutResponseHopLimit(),
 		in.validateHTTPTokens(),
+		in.validateInstanceMetadataTags(),
 	)
 }
 
@@ -186,6 +264,13 @@ func (in *NodeClassSpec) validateHTTPTokens() *apis.FieldError {
 	return in.validateStringEnum(*in.MetadataOptions.HTTPTokens, "httpTokens", ec2.LaunchTemplateHttpTokensState_Values())
 }
 
+func (in *NodeClassSpec) validateInstanceMetadataTags() *apis.FieldError {
+	if in.MetadataOptions.InstanceMetadataTags == nil {
+		return nil
+	}
+	return in.validateStringEnum(*in.MetadataOptions.InstanceMetadataTags, "instanceMetadataTags", ec2.LaunchTemplateInstanceMetadataTagsState_Values())
+}
+
 func (in *NodeClassSpec) validateStringEnum(value, field string, validValues []string) *apis.FieldError {
 	for _, validValue := range validValues {
 		if value == validValue {
@@ -269,8 +354,32 @@ func (in *NodeClassSpec) validateAMIFamily() (errs *apis.FieldError) {
 	return errs.Also(in.validateStringEnum(*in.AMIFamily, amiFamilyPath, SupportedAMIFamilies))
 }
 
+func (in *NodeClassSpec) validateAMIVariant() (errs *apis.FieldError) {
+	if in.AMIVariant == nil {
+		return nil
+	}
+	if !lo.Contains([]string{AMIFamilyAL2, AMIFamilyBottlerocket}, lo.FromPtrOr(in.AMIFamily, AMIFamilyAL2)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("amiVariant is not supported for amiFamily %s", lo.FromPtrOr(in.AMIFamily, AMIFamilyAL2))))
+	}
+	return errs.Also(in.validateStringEnum(*in.AMIVariant, amiVariantPath, []string{AMIVariantFIPS}))
+}
+
+func (in *NodeClassSpec) validateUbuntuStream() (errs *apis.FieldError) {
+	if in.UbuntuStream == nil {
+		return nil
+	}
+	if lo.FromPtrOr(in.AMIFamily, AMIFamilyAL2) != AMIFamilyUbuntu {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("ubuntuStream is not supported for amiFamily %s", lo.FromPtrOr(in.AMIFamily, AMIFamilyAL2))))
+	}
+	return errs.Also(in.validateStringEnum(*in.UbuntuStream, ubuntuStreamPath, []string{UbuntuStreamStandard, UbuntuStreamPro, UbuntuStreamMinimal}))
+}
+
 func (in *NodeClassSpec) validateTags() (errs *apis.FieldError) {
-	for k, v := range in.Tags {
+	return validateResourceTags(in.Tags)
+}
+
+func validateResourceTags(tags map[string]string) (errs *apis.FieldError) {
+	for k, v := range tags {
 		if k == "" {
 			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf(
 				"the tag with key : '' and value : '%s' is invalid because empty tag keys aren't supported", v), "tags"))
@@ -283,3 +392,277 @@ func (in *NodeClassSpec) validateTags() (errs *apis.FieldError) {
 	}
 	return errs
 }
+
+func (in *NodeClassSpec) validateCloudWatchAgent() (errs *apis.FieldError) {
+	if in.CloudWatchAgent == nil {
+		return nil
+	}
+	amiFamily := lo.FromPtrOr(in.AMIFamily, AMIFamilyAL2)
+	agentType := lo.FromPtrOr(in.CloudWatchAgent.Type, CloudWatchAgentTypeCloudWatchAgent)
+	if !SupportedCloudWatchAgentTypesByAMIFamily[amiFamily].Has(agentType) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s is not supported for amiFamily %s", agentType, amiFamily), "type"))
+	}
+	if in.CloudWatchAgent.Config != nil && in.CloudWatchAgent.ConfigSSMParameter != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf("config", "configSSMParameter"))
+	} else if in.CloudWatchAgent.Config == nil && in.CloudWatchAgent.ConfigSSMParameter == nil {
+		errs = errs.Also(apis.ErrMissingOneOf("config", "configSSMParameter"))
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateDomainJoin() (errs *apis.FieldError) {
+	if in.DomainJoin == nil {
+		return nil
+	}
+	if !lo.Contains([]string{AMIFamilyWindows2019, AMIFamilyWindows2022}, lo.FromPtrOr(in.AMIFamily, AMIFamilyAL2)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("domain join is not supported for amiFamily %s", lo.FromPtrOr(in.AMIFamily, AMIFamilyAL2))))
+	}
+	dj := in.DomainJoin
+	if dj.DirectoryID != nil && dj.SSMDocument != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf("directoryID", "ssmDocument"))
+	} else if dj.DirectoryID == nil && dj.SSMDocument == nil {
+		errs = errs.Also(apis.ErrMissingOneOf("directoryID", "ssmDocument"))
+	}
+	if dj.DirectoryID != nil && dj.DirectoryName == nil {
+		errs = errs.Also(apis.ErrMissingField("directoryName"))
+	}
+	if dj.DirectoryID == nil && (dj.DirectoryName != nil || len(dj.DNSIPAddresses) > 0) {
+		errs = errs.Also(apis.ErrGeneric("directoryName and dnsIPAddresses require directoryID", "directoryName", "dnsIPAddresses"))
+	}
+	if dj.SSMDocument == nil && len(dj.SSMDocumentParameters) > 0 {
+		errs = errs.Also(apis.ErrGeneric("ssmDocumentParameters requires ssmDocument", "ssmDocumentParameters"))
+	}
+	for i, ip := range dj.DNSIPAddresses {
+		if net.ParseIP(ip) == nil {
+			errs = errs.Also(apis.ErrInvalidArrayValue(ip, "dnsIPAddresses", i))
+		}
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateNeuron() (errs *apis.FieldError) {
+	if in.Neuron == nil {
+		return nil
+	}
+	if !lo.Contains([]string{AMIFamilyAL2, AMIFamilyUbuntu}, lo.FromPtrOr(in.AMIFamily, AMIFamilyAL2)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("neuron is not supported for amiFamily %s", lo.FromPtrOr(in.AMIFamily, AMIFamilyAL2))))
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateCPUOptions() (errs *apis.FieldError) {
+	if in.CPUOptions == nil {
+		return nil
+	}
+	// EC2 Fleet selects instance types by their default vCPUs, rather than by the vCPUs that the CPU options leave them
+	if in.IsAttributeBasedInstanceSelection() {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cpuOptions are not supported for instanceSelectionMode %s", InstanceSelectionModeAttributeBased)))
+	}
+	// The CPU options determine the vCPU capacity of instance types, and launch templates derive the number of cores
+	// or threads per core that isn't set from it
+	if _, ok := lo.Find(in.InstanceTypeOverrides, func(o InstanceTypeOverride) bool { _, ok := o.Capacity[v1.ResourceCPU]; return ok }); ok {
+		errs = errs.Also(apis.ErrGeneric("cpu capacity can't be overridden by instanceTypeOverrides when cpuOptions are set"))
+	}
+	return errs.Also(in.CPUOptions.validate())
+}
+
+func (in *CPUOptions) validate() (errs *apis.FieldError) {
+	if in.CoreCount != nil && *in.CoreCount < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*in.CoreCount, "coreCount", "must be at least 1"))
+	}
+	if in.ThreadsPerCore != nil && !lo.Contains([]int64{1, 2}, *in.ThreadsPerCore) {
+		errs = errs.Also(apis.ErrInvalidValue(*in.ThreadsPerCore, "threadsPerCore", "must be 1 or 2"))
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateMaintenanceWindows() (errs *apis.FieldError) {
+	for i, window := range in.MaintenanceWindows {
+		errs = errs.Also(window.validate().ViaIndex(i))
+	}
+	return errs
+}
+
+func (in *MaintenanceWindow) validate() (errs *apis.FieldError) {
+	for i, day := range in.Days {
+		if !lo.Contains(weekdays, day) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(day, "days", i))
+		}
+	}
+	if _, err := time.Parse(maintenanceWindowStartFormat, in.Start); err != nil {
+		errs = errs.Also(apis.ErrInvalidValue(in.Start, "start"))
+	}
+	if in.Duration.Duration <= 0 || in.Duration.Duration > maxMaintenanceWindowDuration {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(in.Duration.Duration, "0s", maxMaintenanceWindowDuration, "duration"))
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateRegistrationTTL() (errs *apis.FieldError) {
+	if in.RegistrationTTL == nil {
+		return nil
+	}
+	if in.RegistrationTTL.Duration <= 0 || in.RegistrationTTL.Duration > maxRegistrationTTL {
+		return errs.Also(apis.ErrOutOfBoundsValue(in.RegistrationTTL.Duration, "0s", maxRegistrationTTL, registrationTTLPath))
+	}
+	return nil
+}
+
+func (in *NodeClassSpec) validateMinimumNodeLifetime() (errs *apis.FieldError) {
+	if in.MinimumNodeLifetime == nil {
+		return nil
+	}
+	if in.MinimumNodeLifetime.Duration <= 0 {
+		return errs.Also(apis.ErrInvalidValue(in.MinimumNodeLifetime.Duration, minimumNodeLifetimePath, "must be greater than 0s"))
+	}
+	return nil
+}
+
+func (in *NodeClassSpec) validateScheduledCapacityReservations() (errs *apis.FieldError) {
+	for i, reservation := range in.ScheduledCapacityReservations {
+		errs = errs.Also(reservation.validate().ViaIndex(i))
+	}
+	return errs
+}
+
+func (in *ScheduledCapacityReservation) validate() (errs *apis.FieldError) {
+	if in.InstanceType == "" {
+		errs = errs.Also(apis.ErrMissingField("instanceType"))
+	}
+	if in.AvailabilityZone == "" {
+		errs = errs.Also(apis.ErrMissingField("availabilityZone"))
+	}
+	if in.InstanceCount < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(in.InstanceCount, "instanceCount", "must be at least 1"))
+	}
+	for i, day := range in.Days {
+		if !lo.Contains(weekdays, day) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(day, "days", i))
+		}
+	}
+	if _, err := time.Parse(maintenanceWindowStartFormat, in.Start); err != nil {
+		errs = errs.Also(apis.ErrInvalidValue(in.Start, "start"))
+	}
+	if in.Duration.Duration <= 0 || in.Duration.Duration > maxScheduledCapacityReservationDuration {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(in.Duration.Duration, "0s", maxScheduledCapacityReservationDuration, "duration"))
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateInstanceTypeOverrides() (errs *apis.FieldError) {
+	for i, override := range in.InstanceTypeOverrides {
+		errs = errs.Also(override.validate().ViaIndex(i))
+	}
+	return errs
+}
+
+func (in *InstanceTypeOverride) validate() (errs *apis.FieldError) {
+	if len(in.InstanceTypes) == 0 {
+		errs = errs.Also(apis.ErrMissingField("instanceTypes"))
+	}
+	for i, instanceType := range in.InstanceTypes {
+		if _, err := path.Match(instanceType, ""); instanceType == "" || err != nil {
+			errs = errs.Also(apis.ErrInvalidArrayValue(instanceType, "instanceTypes", i))
+		}
+	}
+	for name, quantity := range in.Capacity {
+		if quantity.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(quantity.String(), "capacity", fmt.Sprintf("%s must not be negative", name)))
+		}
+	}
+	for name, quantity := range in.SystemReserved {
+		if quantity.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(quantity.String(), "systemReserved", fmt.Sprintf("%s must not be negative", name)))
+		}
+	}
+	if in.VMMemoryOverheadPercent != nil {
+		if percent, err := strconv.ParseFloat(*in.VMMemoryOverheadPercent, 64); err != nil || percent < 0 || percent >= 1 {
+			errs = errs.Also(apis.ErrInvalidValue(*in.VMMemoryOverheadPercent, "vmMemoryOverheadPercent", "must be a decimal of at least 0 and less than 1"))
+		}
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateKubeReserved() (errs *apis.FieldError) {
+	if in.KubeReserved == nil {
+		return nil
+	}
+	if in.KubeReserved.Formula != nil {
+		errs = errs.Also(in.validateStringEnum(*in.KubeReserved.Formula, "formula", []string{KubeReservedFormulaEKS, KubeReservedFormulaGKE, KubeReservedFormulaCustom}))
+	}
+	return errs.Also(in.KubeReserved.validate(in.KubeReservedFormula()))
+}
+
+func (in *KubeReserved) validate(formula string) (errs *apis.FieldError) {
+	if formula != KubeReservedFormulaCustom {
+		if len(in.CPU) > 0 || len(in.Memory) > 0 || in.MemoryPerPod != nil || in.BaseMemory != nil || in.EphemeralStorage != nil {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("steps and quantities are only supported by the %s formula", KubeReservedFormulaCustom)))
+		}
+		return errs
+	}
+	errs = errs.Also(validateReservedSteps(in.CPU).ViaField("cpu"), validateReservedSteps(in.Memory).ViaField("memory"))
+	for field, quantity := range map[string]*resource.Quantity{"memoryPerPod": in.MemoryPerPod, "baseMemory": in.BaseMemory, "ephemeralStorage": in.EphemeralStorage} {
+		if quantity != nil && quantity.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(quantity.String(), field, "must not be negative"))
+		}
+	}
+	return errs
+}
+
+func validateReservedSteps(steps []ReservedStep) (errs *apis.FieldError) {
+	for i, step := range steps {
+		if ratio, err := strconv.ParseFloat(step.Ratio, 64); err != nil || ratio < 0 || ratio > 1 {
+			errs = errs.Also(apis.ErrInvalidValue(step.Ratio, "ratio", "must be a decimal between 0 and 1").ViaIndex(i))
+		}
+		switch {
+		case step.UpTo == nil && i != len(steps)-1:
+			errs = errs.Also(apis.ErrMissingField("upTo").ViaIndex(i))
+		case step.UpTo != nil && step.UpTo.Sign() <= 0:
+			errs = errs.Also(apis.ErrInvalidValue(step.UpTo.String(), "upTo", "must be positive").ViaIndex(i))
+		case step.UpTo != nil && i > 0 && steps[i-1].UpTo != nil && step.UpTo.Cmp(*steps[i-1].UpTo) <= 0:
+			errs = errs.Also(apis.ErrInvalidValue(step.UpTo.String(), "upTo", "must be greater than the upTo of the previous step").ViaIndex(i))
+		}
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateExcludedInstanceClasses() (errs *apis.FieldError) {
+	for i, class := range in.ExcludedInstanceClasses {
+		errs = errs.Also(in.validateStringEnum(class, apis.CurrentField, InstanceClasses).ViaIndex(i))
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateCapacityTypeOverrides() (errs *apis.FieldError) {
+	capacityTypes := sets.New[string]()
+	for i, override := range in.CapacityTypeOverrides {
+		if capacityTypes.Has(override.CapacityType) {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("capacity type %q is overridden more than once", override.CapacityType), "capacityType").ViaIndex(i))
+		}
+		capacityTypes.Insert(override.CapacityType)
+		errs = errs.Also(
+			in.validateStringEnum(override.CapacityType, "capacityType", []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}).ViaIndex(i),
+			override.validate(lo.FromPtr(in.AMIFamily)).ViaIndex(i),
+		)
+	}
+	return errs
+}
+
+func (in *CapacityTypeOverride) validate(amiFamily string) (errs *apis.FieldError) {
+	if in.UserData != nil && (amiFamily == AMIFamilyWindows2019 || amiFamily == AMIFamilyWindows2022) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s AMIFamily is not currently supported with custom userData", amiFamily), userDataPath))
+	}
+	return errs.Also(validateResourceTags(in.Tags))
+}
+
+// validRoleARN returns whether the arn is the arn of an iam role
+func validRoleARN(s string) bool {
+	a, err := arn.Parse(s)
+	return err == nil && a.Service == "iam" && strings.HasPrefix(a.Resource, "role/")
+}
+
+// validImageBuilderARN returns whether the arn is the arn of an image builder image pipeline or image recipe
+func validImageBuilderARN(s string) bool {
+	a, err := arn.Parse(s)
+	return err == nil && a.Service == "imagebuilder" && (strings.HasPrefix(a.Resource, "image-pipeline/") || strings.HasPrefix(a.Resource, "image-recipe/"))
+}
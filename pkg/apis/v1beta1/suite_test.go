@@ -18,13 +18,17 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Pallinder/go-randomdata"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
 	. "knative.dev/pkg/logging/testing"
 	"knative.dev/pkg/ptr"
 
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -82,6 +86,450 @@ var _ = Describe("Validation", func() {
 			Expect(nc.Validate(ctx)).To(Not(Succeed()))
 		})
 	})
+	Context("CloudWatchAgent", func() {
+		It("should succeed with an inline config", func() {
+			nc.Spec.CloudWatchAgent = &v1beta1.CloudWatchAgent{Config: ptr.String("{}")}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with an SSM parameter config", func() {
+			nc.Spec.CloudWatchAgent = &v1beta1.CloudWatchAgent{ConfigSSMParameter: ptr.String("AmazonCloudWatch-linux")}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with FluentBit on Ubuntu", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyUbuntu
+			nc.Spec.CloudWatchAgent = &v1beta1.CloudWatchAgent{Type: ptr.String(v1beta1.CloudWatchAgentTypeFluentBit), Config: ptr.String("[INPUT]")}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with FluentBit on AL2023", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyAL2023
+			nc.Spec.CloudWatchAgent = &v1beta1.CloudWatchAgent{Type: ptr.String(v1beta1.CloudWatchAgentTypeFluentBit), Config: ptr.String("[INPUT]")}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail if neither config nor configSSMParameter is specified", func() {
+			nc.Spec.CloudWatchAgent = &v1beta1.CloudWatchAgent{}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if both config and configSSMParameter are specified", func() {
+			nc.Spec.CloudWatchAgent = &v1beta1.CloudWatchAgent{Config: ptr.String("{}"), ConfigSSMParameter: ptr.String("AmazonCloudWatch-linux")}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for the Bottlerocket AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nc.Spec.CloudWatchAgent = &v1beta1.CloudWatchAgent{Config: ptr.String("{}")}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for FluentBit on Windows", func() {
+			nc.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			nc.Spec.CloudWatchAgent = &v1beta1.CloudWatchAgent{Type: ptr.String(v1beta1.CloudWatchAgentTypeFluentBit), Config: ptr.String("[INPUT]")}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("DomainJoin", func() {
+		BeforeEach(func() {
+			nc.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			nc.Spec.DomainJoin = &v1beta1.DomainJoin{
+				DirectoryID:    ptr.String("d-1234567890"),
+				DirectoryName:  ptr.String("corp.example.com"),
+				DNSIPAddresses: []string{"10.0.0.10", "10.0.1.10"},
+				GMSA:           ptr.Bool(true),
+			}
+		})
+		It("should succeed with a directory", func() {
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with an SSM document", func() {
+			nc.Spec.DomainJoin = &v1beta1.DomainJoin{
+				SSMDocument:           ptr.String("custom-domain-join"),
+				SSMDocumentParameters: map[string]string{"ouPath": "OU=Nodes,DC=corp,DC=example,DC=com"},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a Linux AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if both directoryID and ssmDocument are specified", func() {
+			nc.Spec.DomainJoin.SSMDocument = ptr.String("custom-domain-join")
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if neither directoryID nor ssmDocument is specified", func() {
+			nc.Spec.DomainJoin = &v1beta1.DomainJoin{GMSA: ptr.Bool(true)}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if directoryName is missing", func() {
+			nc.Spec.DomainJoin.DirectoryName = nil
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if a DNS IP address is invalid", func() {
+			nc.Spec.DomainJoin.DNSIPAddresses = []string{"10.0.0.300"}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if ssmDocumentParameters are specified without ssmDocument", func() {
+			nc.Spec.DomainJoin.SSMDocumentParameters = map[string]string{"ouPath": "OU=Nodes"}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("AMIVariant", func() {
+		It("should succeed for the Bottlerocket AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nc.Spec.AMIVariant = ptr.String(v1beta1.AMIVariantFIPS)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed for the default AMIFamily", func() {
+			nc.Spec.AMIVariant = ptr.String(v1beta1.AMIVariantFIPS)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for the Ubuntu AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyUbuntu
+			nc.Spec.AMIVariant = ptr.String(v1beta1.AMIVariantFIPS)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an unknown variant", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nc.Spec.AMIVariant = ptr.String("nvidia")
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("UbuntuStream", func() {
+		It("should succeed for the Ubuntu AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyUbuntu
+			nc.Spec.UbuntuStream = ptr.String(v1beta1.UbuntuStreamPro)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for the default AMIFamily", func() {
+			nc.Spec.UbuntuStream = ptr.String(v1beta1.UbuntuStreamPro)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for the Bottlerocket AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nc.Spec.UbuntuStream = ptr.String(v1beta1.UbuntuStreamMinimal)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an unknown stream", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyUbuntu
+			nc.Spec.UbuntuStream = ptr.String("lts")
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("Neuron", func() {
+		It("should succeed for the default AMIFamily", func() {
+			nc.Spec.Neuron = &v1beta1.Neuron{}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a driver version on Ubuntu", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyUbuntu
+			nc.Spec.Neuron = &v1beta1.Neuron{DriverVersion: ptr.String("2.14.5.0")}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for the AL2023 AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyAL2023
+			nc.Spec.Neuron = &v1beta1.Neuron{}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for the Bottlerocket AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nc.Spec.Neuron = &v1beta1.Neuron{}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a Windows AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			nc.Spec.Neuron = &v1beta1.Neuron{}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("CPUOptions", func() {
+		It("should succeed with threads per core", func() {
+			nc.Spec.CPUOptions = &v1beta1.CPUOptions{ThreadsPerCore: ptr.Int64(1)}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a core count and threads per core", func() {
+			nc.Spec.CPUOptions = &v1beta1.CPUOptions{CoreCount: ptr.Int64(4), ThreadsPerCore: ptr.Int64(2)}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a core count less than 1", func() {
+			nc.Spec.CPUOptions = &v1beta1.CPUOptions{CoreCount: ptr.Int64(0)}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for more than 2 threads per core", func() {
+			nc.Spec.CPUOptions = &v1beta1.CPUOptions{ThreadsPerCore: ptr.Int64(3)}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for attribute based instance selection", func() {
+			nc.Spec.InstanceSelectionMode = ptr.String(v1beta1.InstanceSelectionModeAttributeBased)
+			nc.Spec.CPUOptions = &v1beta1.CPUOptions{ThreadsPerCore: ptr.Int64(1)}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if an instance type override sets cpu capacity", func() {
+			nc.Spec.InstanceTypeOverrides = []v1beta1.InstanceTypeOverride{{InstanceTypes: []string{"m5.*"}, Capacity: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")}}}
+			nc.Spec.CPUOptions = &v1beta1.CPUOptions{ThreadsPerCore: ptr.Int64(1)}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("MaintenanceWindows", func() {
+		It("should succeed for a window on specific days", func() {
+			nc.Spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{{Days: []string{"Saturday", "Sunday"}, Start: "22:30", Duration: metav1.Duration{Duration: 6 * time.Hour}}}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed for a daily window", func() {
+			nc.Spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{{Start: "03:00", Duration: metav1.Duration{Duration: time.Hour}}}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for an invalid day", func() {
+			nc.Spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{{Days: []string{"saturday"}, Start: "03:00", Duration: metav1.Duration{Duration: time.Hour}}}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an invalid start", func() {
+			nc.Spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{{Start: "25:00", Duration: metav1.Duration{Duration: time.Hour}}}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a zero duration", func() {
+			nc.Spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{{Start: "03:00"}}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a duration longer than a week", func() {
+			nc.Spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{{Start: "03:00", Duration: metav1.Duration{Duration: 8 * 24 * time.Hour}}}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("RegistrationTTL", func() {
+		It("should succeed for a registration TTL shorter than the default", func() {
+			nc.Spec.RegistrationTTL = &metav1.Duration{Duration: 5 * time.Minute}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed for a registration TTL equal to the default", func() {
+			nc.Spec.RegistrationTTL = &metav1.Duration{Duration: 15 * time.Minute}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a zero registration TTL", func() {
+			nc.Spec.RegistrationTTL = &metav1.Duration{}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed for a registration TTL longer than the default", func() {
+			nc.Spec.RegistrationTTL = &metav1.Duration{Duration: 30 * time.Minute}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a registration TTL longer than an hour", func() {
+			nc.Spec.RegistrationTTL = &metav1.Duration{Duration: 2 * time.Hour}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("MinimumNodeLifetime", func() {
+		It("should succeed for a positive minimum node lifetime", func() {
+			nc.Spec.MinimumNodeLifetime = &metav1.Duration{Duration: 6 * time.Hour}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a zero minimum node lifetime", func() {
+			nc.Spec.MinimumNodeLifetime = &metav1.Duration{}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a negative minimum node lifetime", func() {
+			nc.Spec.MinimumNodeLifetime = &metav1.Duration{Duration: -time.Hour}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("ScheduledCapacityReservations", func() {
+		var reservation v1beta1.ScheduledCapacityReservation
+		BeforeEach(func() {
+			reservation = v1beta1.ScheduledCapacityReservation{
+				InstanceType:     "m5.xlarge",
+				AvailabilityZone: "us-west-2a",
+				InstanceCount:    10,
+				Days:             []string{"Monday", "Tuesday"},
+				Start:            "08:30",
+				Duration:         metav1.Duration{Duration: 2 * time.Hour},
+			}
+		})
+		It("should succeed for a scheduled capacity reservation", func() {
+			nc.Spec.ScheduledCapacityReservations = []v1beta1.ScheduledCapacityReservation{reservation}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail without an instance type", func() {
+			reservation.InstanceType = ""
+			nc.Spec.ScheduledCapacityReservations = []v1beta1.ScheduledCapacityReservation{reservation}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail without an availability zone", func() {
+			reservation.AvailabilityZone = ""
+			nc.Spec.ScheduledCapacityReservations = []v1beta1.ScheduledCapacityReservation{reservation}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a zero instance count", func() {
+			reservation.InstanceCount = 0
+			nc.Spec.ScheduledCapacityReservations = []v1beta1.ScheduledCapacityReservation{reservation}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an invalid day", func() {
+			reservation.Days = []string{"monday"}
+			nc.Spec.ScheduledCapacityReservations = []v1beta1.ScheduledCapacityReservation{reservation}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an invalid start", func() {
+			reservation.Start = "8:30am"
+			nc.Spec.ScheduledCapacityReservations = []v1beta1.ScheduledCapacityReservation{reservation}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a duration longer than a day", func() {
+			reservation.Duration = metav1.Duration{Duration: 25 * time.Hour}
+			nc.Spec.ScheduledCapacityReservations = []v1beta1.ScheduledCapacityReservation{reservation}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("InstanceTypeOverrides", func() {
+		var override v1beta1.InstanceTypeOverride
+		BeforeEach(func() {
+			override = v1beta1.InstanceTypeOverride{
+				InstanceTypes:  []string{"p4d.24xlarge", "g5.*"},
+				Capacity:       v1.ResourceList{v1beta1.ResourceNVIDIAGPU: resource.MustParse("4")},
+				SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+			}
+		})
+		It("should succeed for an instance type override", func() {
+			nc.Spec.InstanceTypeOverrides = []v1beta1.InstanceTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail without instance types", func() {
+			override.InstanceTypes = nil
+			nc.Spec.InstanceTypeOverrides = []v1beta1.InstanceTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an invalid instance type pattern", func() {
+			override.InstanceTypes = []string{"g5.[a"}
+			nc.Spec.InstanceTypeOverrides = []v1beta1.InstanceTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a negative capacity", func() {
+			override.Capacity = v1.ResourceList{v1.ResourceMemory: resource.MustParse("-1Gi")}
+			nc.Spec.InstanceTypeOverrides = []v1beta1.InstanceTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a negative system reserved resource", func() {
+			override.SystemReserved = v1.ResourceList{v1.ResourceMemory: resource.MustParse("-1Gi")}
+			nc.Spec.InstanceTypeOverrides = []v1beta1.InstanceTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed for a vm memory overhead percent", func() {
+			override.VMMemoryOverheadPercent = lo.ToPtr("0.01")
+			nc.Spec.InstanceTypeOverrides = []v1beta1.InstanceTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for a vm memory overhead percent of 1", func() {
+			override.VMMemoryOverheadPercent = lo.ToPtr("1")
+			nc.Spec.InstanceTypeOverrides = []v1beta1.InstanceTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a vm memory overhead percent that isn't a decimal", func() {
+			override.VMMemoryOverheadPercent = lo.ToPtr("7.5%")
+			nc.Spec.InstanceTypeOverrides = []v1beta1.InstanceTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("KubeReserved", func() {
+		var kubeReserved *v1beta1.KubeReserved
+		BeforeEach(func() {
+			kubeReserved = &v1beta1.KubeReserved{
+				Formula: lo.ToPtr(v1beta1.KubeReservedFormulaCustom),
+				CPU: []v1beta1.ReservedStep{
+					{UpTo: lo.ToPtr(resource.MustParse("1")), Ratio: "0.06"},
+					{UpTo: lo.ToPtr(resource.MustParse("2")), Ratio: "0.01"},
+					{Ratio: "0.0025"},
+				},
+				Memory:       []v1beta1.ReservedStep{{UpTo: lo.ToPtr(resource.MustParse("4Gi")), Ratio: "0.25"}, {Ratio: "0.1"}},
+				MemoryPerPod: lo.ToPtr(resource.MustParse("11Mi")),
+				BaseMemory:   lo.ToPtr(resource.MustParse("255Mi")),
+			}
+		})
+		It("should succeed for the GKE formula", func() {
+			nc.Spec.KubeReserved = &v1beta1.KubeReserved{Formula: lo.ToPtr(v1beta1.KubeReservedFormulaGKE)}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed for a custom formula", func() {
+			nc.Spec.KubeReserved = kubeReserved
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for an unknown formula", func() {
+			nc.Spec.KubeReserved = &v1beta1.KubeReserved{Formula: lo.ToPtr("AKS")}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for steps with a formula other than Custom", func() {
+			kubeReserved.Formula = lo.ToPtr(v1beta1.KubeReservedFormulaGKE)
+			nc.Spec.KubeReserved = kubeReserved
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a ratio above 1", func() {
+			kubeReserved.Memory[1].Ratio = "1.5"
+			nc.Spec.KubeReserved = kubeReserved
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a ratio that isn't a decimal", func() {
+			kubeReserved.CPU[0].Ratio = "6%"
+			nc.Spec.KubeReserved = kubeReserved
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a step without upTo that isn't the last", func() {
+			kubeReserved.CPU[1].UpTo = nil
+			nc.Spec.KubeReserved = kubeReserved
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for steps whose upTo doesn't increase", func() {
+			kubeReserved.CPU[1].UpTo = lo.ToPtr(resource.MustParse("500m"))
+			nc.Spec.KubeReserved = kubeReserved
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a negative memory per pod", func() {
+			kubeReserved.MemoryPerPod = lo.ToPtr(resource.MustParse("-1Mi"))
+			nc.Spec.KubeReserved = kubeReserved
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("ExcludedInstanceClasses", func() {
+		It("should succeed for known instance classes", func() {
+			nc.Spec.ExcludedInstanceClasses = []string{v1beta1.InstanceClassMetal, v1beta1.InstanceClassOddSized}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for an unknown instance class", func() {
+			nc.Spec.ExcludedInstanceClasses = []string{v1beta1.InstanceClassMetal, "gpu"}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("CapacityTypeOverrides", func() {
+		var override v1beta1.CapacityTypeOverride
+		BeforeEach(func() {
+			override = v1beta1.CapacityTypeOverride{
+				CapacityType:       "spot",
+				Tags:               map[string]string{"agent": "spot"},
+				UserData:           aws.String("#!/bin/bash\necho spot"),
+				DetailedMonitoring: aws.Bool(true),
+			}
+		})
+		It("should succeed for a capacity type override", func() {
+			nc.Spec.CapacityTypeOverrides = []v1beta1.CapacityTypeOverride{override, {CapacityType: "on-demand"}}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail for an unknown capacity type", func() {
+			override.CapacityType = "reserved"
+			nc.Spec.CapacityTypeOverrides = []v1beta1.CapacityTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when a capacity type is overridden more than once", func() {
+			nc.Spec.CapacityTypeOverrides = []v1beta1.CapacityTypeOverride{override, {CapacityType: "spot"}}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for a restricted tag", func() {
+			override.Tags = map[string]string{"karpenter.sh/provisioner-name": "default"}
+			nc.Spec.CapacityTypeOverrides = []v1beta1.CapacityTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for an empty tag key", func() {
+			override.Tags = map[string]string{"": "spot"}
+			nc.Spec.CapacityTypeOverrides = []v1beta1.CapacityTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail for userData with a Windows AMI family", func() {
+			nc.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			nc.Spec.CapacityTypeOverrides = []v1beta1.CapacityTypeOverride{override}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
 	Context("Tags", func() {
 		It("should succeed when tags are empty", func() {
 			nc.Spec.Tags = map[string]string{}
@@ -383,6 +831,44 @@ var _ = Describe("Validation", func() {
 			}
 			Expect(nc.Validate(ctx)).To(Succeed())
 		})
+		It("should succeed with a valid ami selector on an image builder pipeline or recipe", func() {
+			for _, imageBuilderARN := range []string{
+				"arn:aws:imagebuilder:us-west-2:123456789012:image-pipeline/golden",
+				"arn:aws:imagebuilder:us-west-2:123456789012:image-recipe/golden/1.0.0",
+			} {
+				nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ImageBuilderARN: imageBuilderARN}}
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should succeed with a valid ami selector that's described as a role", func() {
+			for _, term := range []v1beta1.AMISelectorTerm{
+				{Tags: map[string]string{"golden": "true"}, RoleARN: "arn:aws:iam::111122223333:role/golden-images"},
+				{ID: "ami-123", RoleARN: "arn:aws:iam::111122223333:role/golden-images"},
+			} {
+				nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{term}
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an invalid role arn", func() {
+			for _, roleARN := range []string{"golden-images", "arn:aws:iam::111122223333:user/golden-images"} {
+				nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"golden": "true"}, RoleARN: roleARN}}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail when only a role arn is set", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{RoleARN: "arn:aws:iam::111122223333:role/golden-images"}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an invalid image builder arn", func() {
+			for _, imageBuilderARN := range []string{
+				"golden",
+				"arn:aws:ec2:us-west-2:123456789012:image/ami-123",
+				"arn:aws:imagebuilder:us-west-2:123456789012:component/golden/1.0.0/1",
+			} {
+				nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ImageBuilderARN: imageBuilderARN}}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
 		It("should fail when a ami selector term has no values", func() {
 			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
 				{},
@@ -480,6 +966,99 @@ var _ = Describe("Validation", func() {
 			}
 			Expect(nc.Validate(ctx)).ToNot(Succeed())
 		})
+		It("should fail when specifying id with an image builder arn", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					ID:              "ami-12345749",
+					ImageBuilderARN: "arn:aws:imagebuilder:us-west-2:123456789012:image-pipeline/golden",
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when specifying ssm with an image builder arn", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					SSM:             "/test/ssm/path",
+					ImageBuilderARN: "arn:aws:imagebuilder:us-west-2:123456789012:image-pipeline/golden",
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should succeed when specifying a creation date window", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					Name:            "my-custom-ami",
+					MinCreationDate: &metav1.Time{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+					MaxCreationDate: &metav1.Time{Time: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+				},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed when specifying id with a max age", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					ID:     "ami-12345749",
+					MaxAge: &metav1.Duration{Duration: 30 * 24 * time.Hour},
+				},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when the max creation date is before the min creation date", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					Name:            "my-custom-ami",
+					MinCreationDate: &metav1.Time{Time: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+					MaxCreationDate: &metav1.Time{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when the max age isn't positive", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					Name:   "my-custom-ami",
+					MaxAge: &metav1.Duration{},
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when specifying only a creation date window", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					MaxAge: &metav1.Duration{Duration: time.Hour},
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("AMIRollout", func() {
+		It("should succeed with candidate ami selector terms and a weight", func() {
+			nc.Spec.AMIRollout = &v1beta1.AMIRollout{
+				AMISelectorTerms: []v1beta1.AMISelectorTerm{{Tags: map[string]string{"pipeline": "candidate"}}},
+				Weight:           10,
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail without candidate ami selector terms", func() {
+			nc.Spec.AMIRollout = &v1beta1.AMIRollout{Weight: 10}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when a candidate ami selector term is invalid", func() {
+			nc.Spec.AMIRollout = &v1beta1.AMIRollout{
+				AMISelectorTerms: []v1beta1.AMISelectorTerm{{ID: "ami-12345749", Name: "my-custom-ami"}},
+				Weight:           10,
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when the weight is out of bounds", func() {
+			for _, weight := range []int32{-1, 101} {
+				nc.Spec.AMIRollout = &v1beta1.AMIRollout{
+					AMISelectorTerms: []v1beta1.AMISelectorTerm{{Tags: map[string]string{"pipeline": "candidate"}}},
+					Weight:           weight,
+				}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
 	})
 	Context("NodeClass Hash", func() {
 		var nodeClass *v1beta1.NodeClass
@@ -556,3 +1135,87 @@ var _ = Describe("Validation", func() {
 		})
 	})
 })
+
+var _ = Describe("MaintenanceWindows", func() {
+	var spec *v1beta1.NodeClassSpec
+
+	BeforeEach(func() {
+		spec = &v1beta1.NodeClassSpec{}
+	})
+	It("should always be in a maintenance window when no windows are specified", func() {
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 6, 12, 0, 0, 0, time.UTC))).To(BeTrue())
+	})
+	It("should be in a daily window only while it is open", func() {
+		spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{{Start: "03:00", Duration: metav1.Duration{Duration: 2 * time.Hour}}}
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 6, 2, 59, 0, 0, time.UTC))).To(BeFalse())
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 6, 3, 0, 0, 0, time.UTC))).To(BeTrue())
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 6, 4, 59, 0, 0, time.UTC))).To(BeTrue())
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 6, 5, 0, 0, 0, time.UTC))).To(BeFalse())
+	})
+	It("should only open a window on the specified days", func() {
+		// September 9th, 2023 is a Saturday
+		spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{{Days: []string{"Saturday"}, Start: "03:00", Duration: metav1.Duration{Duration: 2 * time.Hour}}}
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 9, 4, 0, 0, 0, time.UTC))).To(BeTrue())
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 10, 4, 0, 0, 0, time.UTC))).To(BeFalse())
+	})
+	It("should keep a window open past midnight", func() {
+		spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{{Days: []string{"Saturday"}, Start: "22:00", Duration: metav1.Duration{Duration: 30 * time.Hour}}}
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 10, 3, 59, 0, 0, time.UTC))).To(BeTrue())
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 11, 3, 59, 0, 0, time.UTC))).To(BeTrue())
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 11, 4, 0, 0, 0, time.UTC))).To(BeFalse())
+	})
+	It("should evaluate windows in UTC", func() {
+		spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{{Start: "03:00", Duration: metav1.Duration{Duration: time.Hour}}}
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 6, 3, 30, 0, 0, time.FixedZone("UTC-7", -7*60*60)))).To(BeFalse())
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 5, 20, 30, 0, 0, time.FixedZone("UTC-7", -7*60*60)))).To(BeTrue())
+	})
+	It("should be in a maintenance window if any of the windows are open", func() {
+		spec.MaintenanceWindows = []v1beta1.MaintenanceWindow{
+			{Start: "03:00", Duration: metav1.Duration{Duration: time.Hour}},
+			{Start: "15:00", Duration: metav1.Duration{Duration: time.Hour}},
+		}
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 6, 15, 30, 0, 0, time.UTC))).To(BeTrue())
+		Expect(spec.InMaintenanceWindow(time.Date(2023, time.September, 6, 12, 0, 0, 0, time.UTC))).To(BeFalse())
+	})
+})
+
+var _ = Describe("ScheduledCapacityReservations", func() {
+	var reservation *v1beta1.ScheduledCapacityReservation
+
+	BeforeEach(func() {
+		reservation = &v1beta1.ScheduledCapacityReservation{Start: "08:00", Duration: metav1.Duration{Duration: 2 * time.Hour}}
+	})
+	It("should be held from the lead time before the scale-up starts until it's over", func() {
+		startsAt := time.Date(2023, time.September, 6, 8, 0, 0, 0, time.UTC)
+		_, ok := reservation.HeldAt(time.Date(2023, time.September, 6, 7, 44, 0, 0, time.UTC), 15*time.Minute)
+		Expect(ok).To(BeFalse())
+		heldFor, ok := reservation.HeldAt(time.Date(2023, time.September, 6, 7, 45, 0, 0, time.UTC), 15*time.Minute)
+		Expect(ok).To(BeTrue())
+		Expect(heldFor).To(Equal(startsAt))
+		heldFor, ok = reservation.HeldAt(time.Date(2023, time.September, 6, 9, 59, 0, 0, time.UTC), 15*time.Minute)
+		Expect(ok).To(BeTrue())
+		Expect(heldFor).To(Equal(startsAt))
+		_, ok = reservation.HeldAt(time.Date(2023, time.September, 6, 10, 0, 0, 0, time.UTC), 15*time.Minute)
+		Expect(ok).To(BeFalse())
+	})
+	It("should only be held on the specified days", func() {
+		// September 9th, 2023 is a Saturday
+		reservation.Days = []string{"Saturday"}
+		_, ok := reservation.HeldAt(time.Date(2023, time.September, 9, 9, 0, 0, 0, time.UTC), 15*time.Minute)
+		Expect(ok).To(BeTrue())
+		_, ok = reservation.HeldAt(time.Date(2023, time.September, 10, 9, 0, 0, 0, time.UTC), 15*time.Minute)
+		Expect(ok).To(BeFalse())
+	})
+	It("should be held ahead of a scale-up that starts after midnight", func() {
+		reservation.Start = "00:05"
+		heldFor, ok := reservation.HeldAt(time.Date(2023, time.September, 6, 23, 55, 0, 0, time.UTC), 15*time.Minute)
+		Expect(ok).To(BeTrue())
+		Expect(heldFor).To(Equal(time.Date(2023, time.September, 7, 0, 5, 0, 0, time.UTC)))
+	})
+	It("should stay held past midnight", func() {
+		reservation.Start = "23:00"
+		heldFor, ok := reservation.HeldAt(time.Date(2023, time.September, 7, 0, 30, 0, 0, time.UTC), 15*time.Minute)
+		Expect(ok).To(BeTrue())
+		Expect(heldFor).To(Equal(time.Date(2023, time.September, 6, 23, 0, 0, 0, time.UTC)))
+	})
+})
@@ -24,20 +24,32 @@ import (
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/aws/karpenter-core/pkg/metrics"
+
+	"github.com/aws/karpenter/pkg/utils/attribution"
+	"github.com/aws/karpenter/pkg/utils/tracing"
 )
 
 // Options allows for configuration of the Batcher
 type Options[T input, U output] struct {
-	Name              string
-	IdleTimeout       time.Duration
-	MaxTimeout        time.Duration
-	MaxItems          int
+	// Name identifies the batcher in its metrics
+	Name string
+	// IdleTimeout and MaxTimeout bound the batching window. The window closes once no request has been
+	// added for IdleTimeout, or once MaxTimeout has passed since it opened, whichever comes first
+	IdleTimeout time.Duration
+	MaxTimeout  time.Duration
+	// MaxItems closes the batching window early once this many requests have been added to it
+	MaxItems int
+	// MaxBatchSize splits a batch into multiple executions of at most this many requests, for APIs that limit
+	// the number of resources in a single call. A zero value leaves batches unsplit
+	MaxBatchSize      int
 	MaxRequestWorkers int
-	RequestHasher     RequestHasher[T]
-	BatchExecutor     BatchExecutor[T, U]
+	// RequestHasher shards requests into batches, so that only requests with the same key are executed together
+	RequestHasher RequestHasher[T]
+	BatchExecutor BatchExecutor[T, U]
 }
 
 // Result is a container for the output and error of an execution
@@ -98,7 +110,9 @@ func NewBatcher[T input, U output](ctx context.Context, options Options[T, U]) *
 }
 
 // Add will add an input to the batcher using the batcher's hashing function
-func (b *Batcher[T, U]) Add(ctx context.Context, input *T) Result[U] {
+func (b *Batcher[T, U]) Add(ctx context.Context, input *T) (result Result[U]) {
+	ctx, span := tracing.Start(ctx, fmt.Sprintf("batcher.%s.Add", b.options.Name))
+	defer func() { tracing.End(span, result.Err) }()
 	request := &request[T, U]{
 		ctx:   ctx,
 		hash:  b.options.RequestHasher(ctx, input),
@@ -151,11 +165,13 @@ func (b *Batcher[T, U]) run() {
 		b.mu.Unlock()
 
 		for _, v := range requests {
-			req := v // create a local closure for the requests value
-			b.requestWorkers.Go(func() error {
-				b.runCalls(req)
-				return nil
-			})
+			for _, batch := range b.split(v) {
+				req := batch // create a local closure for the requests value
+				b.requestWorkers.Go(func() error {
+					b.runCalls(req)
+					return nil
+				})
+			}
 		}
 	}
 }
@@ -182,16 +198,42 @@ func (b *Batcher[T, U]) waitForIdle() {
 	}
 }
 
+// split breaks the requests for a single shard into batches of at most MaxBatchSize requests
+func (b *Batcher[T, U]) split(requests []*request[T, U]) [][]*request[T, U] {
+	if b.options.MaxBatchSize <= 0 {
+		return [][]*request[T, U]{requests}
+	}
+	return lo.Chunk(requests, b.options.MaxBatchSize)
+}
+
 func (b *Batcher[T, U]) runCalls(requests []*request[T, U]) {
 	// Measure the size of the request batch
 	batchSize.With(prometheus.Labels{batcherNameLabel: b.options.Name}).Observe(float64(len(requests)))
+	measureDuration := metrics.Measure(batchExecutionDuration.WithLabelValues(b.options.Name))
+	// the execution is made with the batcher's context rather than any caller's, so that one caller going away doesn't
+	// fail the call for the others, and it's linked to the span and the attribution of every request in the batch,
+	// since it's made on behalf of all of them
+	linked := lo.Map(requests, func(req *request[T, U], _ int) context.Context { return req.ctx })
+	ctx, span := tracing.StartLinked(attribution.WithLinked(b.ctx, linked), fmt.Sprintf("batcher.%s.Execute", b.options.Name),
+		linked, attribute.Int("batch-size", len(requests)))
+	results := b.options.BatchExecutor(ctx, lo.Map(requests, func(req *request[T, U], _ int) *T { return req.input }))
+	span.End()
+	measureDuration()
+
 	requestIdx := 0
-	for _, result := range b.options.BatchExecutor(requests[0].ctx, lo.Map(requests, func(req *request[T, U], _ int) *T { return req.input })) {
+	for _, result := range results {
+		if requestIdx >= len(requests) {
+			break
+		}
+		if result.Err != nil {
+			batchErrors.WithLabelValues(b.options.Name).Inc()
+		}
 		requests[requestIdx].requestor <- result
 		requestIdx++
 	}
 	// any unmapped outputs should return an error to the caller
 	for ; requestIdx < len(requests); requestIdx++ {
+		batchErrors.WithLabelValues(b.options.Name).Inc()
 		requests[requestIdx].requestor <- Result[U]{Err: fmt.Errorf("error making call")}
 	}
 }
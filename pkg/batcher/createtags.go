@@ -0,0 +1,103 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/mitchellh/hashstructure/v2"
+	"knative.dev/pkg/logging"
+)
+
+type CreateTagsBatcher struct {
+	batcher *Batcher[ec2.CreateTagsInput, ec2.CreateTagsOutput]
+}
+
+func NewCreateTagsBatcher(ctx context.Context, ec2api ec2iface.EC2API) *CreateTagsBatcher {
+	options := Options[ec2.CreateTagsInput, ec2.CreateTagsOutput]{
+		Name:          "create_tags",
+		IdleTimeout:   100 * time.Millisecond,
+		MaxTimeout:    1 * time.Second,
+		MaxItems:      500,
+		MaxBatchSize:  500,
+		RequestHasher: TagsHasher,
+		BatchExecutor: execCreateTagsBatch(ec2api),
+	}
+	return &CreateTagsBatcher{batcher: NewBatcher(ctx, options)}
+}
+
+func (b *CreateTagsBatcher) CreateTags(ctx context.Context, createTagsInput *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	if len(createTagsInput.Resources) != 1 {
+		return nil, fmt.Errorf("expected to receive a single resource only, found %d", len(createTagsInput.Resources))
+	}
+	result := b.batcher.Add(ctx, createTagsInput)
+	return result.Output, result.Err
+}
+
+// TagsHasher shards requests by the tags they apply, since a single CreateTags call applies the same tags to every resource
+func TagsHasher(ctx context.Context, input *ec2.CreateTagsInput) uint64 {
+	hash, err := hashstructure.Hash(input.Tags, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+	if err != nil {
+		logging.FromContext(ctx).Errorf("error hashing")
+	}
+	return hash
+}
+
+func execCreateTagsBatch(ec2api ec2iface.EC2API) BatchExecutor[ec2.CreateTagsInput, ec2.CreateTagsOutput] {
+	return func(ctx context.Context, inputs []*ec2.CreateTagsInput) []Result[ec2.CreateTagsOutput] {
+		results := make([]Result[ec2.CreateTagsOutput], len(inputs))
+		firstInput := inputs[0]
+
+		// aggregate resources into 1 input
+		for _, input := range inputs[1:] {
+			firstInput.Resources = append(firstInput.Resources, input.Resources...)
+		}
+
+		// Execute fully aggregated request
+		// CreateTags either tags every resource or none of them, so there's nothing else to split out on success
+		if _, err := ec2api.CreateTagsWithContext(ctx, firstInput); err == nil {
+			for reqID := range inputs {
+				results[reqID] = Result[ec2.CreateTagsOutput]{Output: &ec2.CreateTagsOutput{}}
+			}
+			return results
+		}
+
+		// A single resource that doesn't exist fails the whole call, so we try to tag them individually now.
+		// This should be rare and only results in a handful of extra calls per batch than without batching.
+		var wg sync.WaitGroup
+		for reqID := range inputs {
+			wg.Add(1)
+			go func(reqID int) {
+				defer wg.Done()
+				// try to execute separately
+				out, err := ec2api.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+					Resources: []*string{firstInput.Resources[reqID]},
+					Tags:      firstInput.Tags,
+				})
+				if err == nil && out == nil {
+					out = &ec2.CreateTagsOutput{}
+				}
+				results[reqID] = Result[ec2.CreateTagsOutput]{Output: out, Err: err}
+			}(reqID)
+		}
+		wg.Wait()
+		return results
+	}
+}
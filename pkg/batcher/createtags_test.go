@@ -0,0 +1,122 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher_test
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aws/karpenter/pkg/batcher"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CreateTags Batcher", func() {
+	var ctb *batcher.CreateTagsBatcher
+
+	BeforeEach(func() {
+		fakeEC2API.Reset()
+		ctb = batcher.NewCreateTagsBatcher(ctx, fakeEC2API)
+	})
+
+	It("should batch input with the same tags into a single call", func() {
+		instanceIDs := []string{"i-1", "i-2", "i-3", "i-4", "i-5"}
+		for _, id := range instanceIDs {
+			fakeEC2API.Instances.Store(id, &ec2.Instance{})
+		}
+
+		var wg sync.WaitGroup
+		var receivedInstance int64
+		for _, instanceID := range instanceIDs {
+			wg.Add(1)
+			go func(instanceID string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				rsp, err := ctb.CreateTags(ctx, &ec2.CreateTagsInput{
+					Resources: []*string{aws.String(instanceID)},
+					Tags:      []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				})
+				Expect(err).To(BeNil())
+				Expect(rsp).ToNot(BeNil())
+				atomic.AddInt64(&receivedInstance, 1)
+			}(instanceID)
+		}
+		wg.Wait()
+
+		Expect(receivedInstance).To(BeNumerically("==", len(instanceIDs)))
+		Expect(fakeEC2API.CreateTagsBehavior.CalledWithInput.Len()).To(BeNumerically("==", 1))
+		call := fakeEC2API.CreateTagsBehavior.CalledWithInput.Pop()
+		Expect(len(call.Resources)).To(BeNumerically("==", len(instanceIDs)))
+	})
+	It("should batch input with different tags into separate calls", func() {
+		instanceIDs := []string{"i-1", "i-2", "i-3", "i-4"}
+		for _, id := range instanceIDs {
+			fakeEC2API.Instances.Store(id, &ec2.Instance{})
+		}
+
+		var wg sync.WaitGroup
+		for i, instanceID := range instanceIDs {
+			wg.Add(1)
+			go func(instanceID string, value string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := ctb.CreateTags(ctx, &ec2.CreateTagsInput{
+					Resources: []*string{aws.String(instanceID)},
+					Tags:      []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String(value)}},
+				})
+				Expect(err).To(BeNil())
+			}(instanceID, []string{"bar", "baz"}[i%2])
+		}
+		wg.Wait()
+
+		Expect(fakeEC2API.CreateTagsBehavior.CalledWithInput.Len()).To(BeNumerically("==", 2))
+		for fakeEC2API.CreateTagsBehavior.CalledWithInput.Len() > 0 {
+			call := fakeEC2API.CreateTagsBehavior.CalledWithInput.Pop()
+			Expect(len(call.Resources)).To(BeNumerically("==", 2))
+		}
+	})
+	It("should recover with individual requests when a resource in the batched call doesn't exist", func() {
+		instanceIDs := []string{"i-1", "i-2", "i-3"}
+		for _, id := range instanceIDs[:2] {
+			fakeEC2API.Instances.Store(id, &ec2.Instance{})
+		}
+
+		var wg sync.WaitGroup
+		var numErrors int64
+		for _, instanceID := range instanceIDs {
+			wg.Add(1)
+			go func(instanceID string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := ctb.CreateTags(ctx, &ec2.CreateTagsInput{
+					Resources: []*string{aws.String(instanceID)},
+					Tags:      []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				})
+				if err != nil {
+					atomic.AddInt64(&numErrors, 1)
+				}
+			}(instanceID)
+		}
+		wg.Wait()
+
+		// should execute the batched call and then one for each resource in the failed batch
+		Expect(fakeEC2API.CreateTagsBehavior.Calls()).To(BeNumerically("==", 4))
+		Expect(numErrors).To(BeNumerically("==", 1))
+	})
+})
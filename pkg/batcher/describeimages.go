@@ -0,0 +1,114 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/mitchellh/hashstructure/v2"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+)
+
+const imageIDFilterName = "image-id"
+
+type DescribeImagesBatcher struct {
+	batcher *Batcher[ec2.DescribeImagesInput, ec2.DescribeImagesOutput]
+}
+
+func NewDescribeImagesBatcher(ctx context.Context, ec2api ec2iface.EC2API) *DescribeImagesBatcher {
+	options := Options[ec2.DescribeImagesInput, ec2.DescribeImagesOutput]{
+		Name:          "describe_images",
+		IdleTimeout:   100 * time.Millisecond,
+		MaxTimeout:    1 * time.Second,
+		MaxItems:      500,
+		MaxBatchSize:  100,
+		RequestHasher: ImagesHasher,
+		BatchExecutor: execDescribeImagesBatch(ec2api),
+	}
+	return &DescribeImagesBatcher{batcher: NewBatcher(ctx, options)}
+}
+
+// DescribeImages returns every page of images that the input describes in a single output. When the images aren't
+// selected by id, paging stops once more than the aws.amiSelectorMaxImages of the batcher's settings have been described.
+func (b *DescribeImagesBatcher) DescribeImages(ctx context.Context, describeImagesInput *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	result := b.batcher.Add(ctx, describeImagesInput)
+	return result.Output, result.Err
+}
+
+// ImagesHasher shards requests by everything but the ids that they select, so that identical requests are only executed
+// once and the ids selected by requests that are otherwise identical are described together
+func ImagesHasher(ctx context.Context, input *ec2.DescribeImagesInput) uint64 {
+	shard := *input
+	shard.Filters = lo.Map(input.Filters, func(f *ec2.Filter, _ int) *ec2.Filter {
+		return lo.Ternary(aws.StringValue(f.Name) == imageIDFilterName, &ec2.Filter{Name: f.Name}, f)
+	})
+	hash, err := hashstructure.Hash(shard, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+	if err != nil {
+		logging.FromContext(ctx).Errorf("error hashing")
+	}
+	return hash
+}
+
+func execDescribeImagesBatch(ec2api ec2iface.EC2API) BatchExecutor[ec2.DescribeImagesInput, ec2.DescribeImagesOutput] {
+	return func(ctx context.Context, inputs []*ec2.DescribeImagesInput) []Result[ec2.DescribeImagesOutput] {
+		results := make([]Result[ec2.DescribeImagesOutput], len(inputs))
+		firstInput := inputs[0]
+		// aggregate the selected ids into 1 input, remembering the ids that each input selects
+		imageIDs := make([]sets.Set[string], len(inputs))
+		for reqID, input := range inputs {
+			if filter, ok := imageIDFilter(input); ok {
+				imageIDs[reqID] = sets.New(aws.StringValueSlice(filter.Values)...)
+			}
+		}
+		if filter, ok := imageIDFilter(firstInput); ok {
+			filter.Values = aws.StringSlice(sets.List(lo.Reduce(imageIDs, func(ids sets.Set[string], i sets.Set[string], _ int) sets.Set[string] {
+				return ids.Union(i)
+			}, sets.New[string]())))
+		}
+		maxImages := settings.FromContext(ctx).AMISelectorMaxImages
+
+		// Execute fully aggregated request
+		var images []*ec2.Image
+		err := ec2api.DescribeImagesPagesWithContext(ctx, firstInput, func(page *ec2.DescribeImagesOutput, _ bool) bool {
+			images = append(images, page.Images...)
+			return imageIDs[0] != nil || len(images) <= maxImages
+		})
+		for reqID := range inputs {
+			if err != nil {
+				results[reqID] = Result[ec2.DescribeImagesOutput]{Err: err}
+				continue
+			}
+			// Requests that are otherwise identical select the same images, aside from the ids that they select
+			selected := images
+			if imageIDs[reqID] != nil {
+				selected = lo.Filter(images, func(image *ec2.Image, _ int) bool { return imageIDs[reqID].Has(aws.StringValue(image.ImageId)) })
+			}
+			results[reqID] = Result[ec2.DescribeImagesOutput]{Output: &ec2.DescribeImagesOutput{Images: selected}}
+		}
+		return results
+	}
+}
+
+func imageIDFilter(input *ec2.DescribeImagesInput) (*ec2.Filter, bool) {
+	return lo.Find(input.Filters, func(f *ec2.Filter) bool { return aws.StringValue(f.Name) == imageIDFilterName })
+}
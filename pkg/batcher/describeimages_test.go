@@ -0,0 +1,132 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/batcher"
+	"github.com/aws/karpenter/pkg/test"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DescribeImages Batcher", func() {
+	var dib *batcher.DescribeImagesBatcher
+	var settingsCtx context.Context
+
+	BeforeEach(func() {
+		fakeEC2API.Reset()
+		settingsCtx = settings.ToContext(ctx, test.Settings())
+		dib = batcher.NewDescribeImagesBatcher(settingsCtx, fakeEC2API)
+		fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: lo.Times(5, func(i int) *ec2.Image {
+			return &ec2.Image{
+				Name:    aws.String(fmt.Sprintf("image-%d", i)),
+				ImageId: aws.String(fmt.Sprintf("ami-%d", i)),
+				Tags:    []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String(lo.Ternary(i%2 == 0, "even", "odd"))}},
+			}
+		})})
+	})
+
+	It("should describe identical inputs with a single call", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				rsp, err := dib.DescribeImages(settingsCtx, &ec2.DescribeImagesInput{
+					Filters: []*ec2.Filter{{Name: aws.String("tag:foo"), Values: aws.StringSlice([]string{"even"})}},
+					Owners:  aws.StringSlice([]string{"self", "amazon"}),
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lo.Map(rsp.Images, func(i *ec2.Image, _ int) string { return *i.ImageId })).To(ConsistOf("ami-0", "ami-2", "ami-4"))
+			}()
+		}
+		wg.Wait()
+		Expect(fakeEC2API.CalledWithDescribeImagesInput.Len()).To(Equal(1))
+	})
+	It("should batch the ids of inputs into a single call and return the images of each input", func() {
+		imageIDs := [][]string{{"ami-0"}, {"ami-1"}, {"ami-1", "ami-3"}, {"ami-9"}}
+		var wg sync.WaitGroup
+		for _, ids := range imageIDs {
+			wg.Add(1)
+			go func(ids []string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				rsp, err := dib.DescribeImages(settingsCtx, &ec2.DescribeImagesInput{
+					Filters: []*ec2.Filter{{Name: aws.String("image-id"), Values: aws.StringSlice(ids)}},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lo.Map(rsp.Images, func(i *ec2.Image, _ int) string { return *i.ImageId })).To(ConsistOf(lo.Without(ids, "ami-9")))
+			}(ids)
+		}
+		wg.Wait()
+		Expect(fakeEC2API.CalledWithDescribeImagesInput.Len()).To(Equal(1))
+		call := fakeEC2API.CalledWithDescribeImagesInput.Pop()
+		Expect(aws.StringValueSlice(call.Filters[0].Values)).To(ConsistOf("ami-0", "ami-1", "ami-3", "ami-9"))
+	})
+	It("should describe inputs with different filters separately", func() {
+		var wg sync.WaitGroup
+		for _, value := range []string{"even", "odd"} {
+			wg.Add(1)
+			go func(value string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				rsp, err := dib.DescribeImages(settingsCtx, &ec2.DescribeImagesInput{
+					Filters: []*ec2.Filter{{Name: aws.String("tag:foo"), Values: aws.StringSlice([]string{value})}},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.Images).To(HaveLen(lo.Ternary(value == "even", 3, 2)))
+			}(value)
+		}
+		wg.Wait()
+		Expect(fakeEC2API.CalledWithDescribeImagesInput.Len()).To(Equal(2))
+	})
+	It("should stop paging once more images than allowed are described", func() {
+		limitedCtx := settings.ToContext(ctx, test.Settings(test.SettingOptions{AMISelectorMaxImages: lo.ToPtr(2)}))
+		rsp, err := batcher.NewDescribeImagesBatcher(limitedCtx, fakeEC2API).DescribeImages(settingsCtx, &ec2.DescribeImagesInput{
+			Filters:    []*ec2.Filter{{Name: aws.String("name"), Values: aws.StringSlice([]string{"image-0", "image-1", "image-2", "image-3", "image-4"})}},
+			MaxResults: aws.Int64(1),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rsp.Images).To(HaveLen(3))
+	})
+	It("should return errors to all callers when erroring on the batched call", func() {
+		fakeEC2API.NextError.Set(fmt.Errorf("error"))
+		var wg sync.WaitGroup
+		for _, id := range []string{"ami-0", "ami-1", "ami-2"} {
+			wg.Add(1)
+			go func(id string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := dib.DescribeImages(settingsCtx, &ec2.DescribeImagesInput{
+					Filters: []*ec2.Filter{{Name: aws.String("image-id"), Values: aws.StringSlice([]string{id})}},
+				})
+				Expect(err).To(HaveOccurred())
+			}(id)
+		}
+		wg.Wait()
+		Expect(fakeEC2API.Calls.Get("DescribeImages")).To(Equal(1))
+	})
+})
@@ -39,22 +39,29 @@ func NewDescribeInstancesBatcher(ctx context.Context, ec2api ec2iface.EC2API) *D
 		IdleTimeout:   100 * time.Millisecond,
 		MaxTimeout:    1 * time.Second,
 		MaxItems:      500,
+		MaxBatchSize:  500,
 		RequestHasher: FilterHasher,
 		BatchExecutor: execDescribeInstancesBatch(ec2api),
 	}
 	return &DescribeInstancesBatcher{batcher: NewBatcher(ctx, options)}
 }
 
+// DescribeInstances describes a single instance, or lists the instances that match the filters when no instance is
+// given. Concurrent lists with the same filters share a single listing.
 func (b *DescribeInstancesBatcher) DescribeInstances(ctx context.Context, describeInstancesInput *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
-	if len(describeInstancesInput.InstanceIds) != 1 {
+	if len(describeInstancesInput.InstanceIds) > 1 {
 		return nil, fmt.Errorf("expected to receive a single instance only, found %d", len(describeInstancesInput.InstanceIds))
 	}
+	if len(describeInstancesInput.InstanceIds) == 0 && len(describeInstancesInput.Filters) == 0 {
+		return nil, fmt.Errorf("expected to receive a single instance or filters")
+	}
 	result := b.batcher.Add(ctx, describeInstancesInput)
 	return result.Output, result.Err
 }
 
 func FilterHasher(ctx context.Context, input *ec2.DescribeInstancesInput) uint64 {
-	hash, err := hashstructure.Hash(input.Filters, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+	// Lists aren't batched with describes of single instances that have the same filters
+	hash, err := hashstructure.Hash([]interface{}{input.Filters, len(input.InstanceIds) == 0}, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
 	if err != nil {
 		logging.FromContext(ctx).Errorf("error hashing")
 	}
@@ -65,6 +72,9 @@ func execDescribeInstancesBatch(ec2api ec2iface.EC2API) BatchExecutor[ec2.Descri
 	return func(ctx context.Context, inputs []*ec2.DescribeInstancesInput) []Result[ec2.DescribeInstancesOutput] {
 		results := make([]Result[ec2.DescribeInstancesOutput], len(inputs))
 		firstInput := inputs[0]
+		if len(firstInput.InstanceIds) == 0 {
+			return execListInstancesBatch(ctx, ec2api, firstInput, results)
+		}
 		// aggregate instanceIDs into 1 input
 		for _, input := range inputs[1:] {
 			firstInput.InstanceIds = append(firstInput.InstanceIds, input.InstanceIds...)
@@ -122,3 +132,16 @@ func execDescribeInstancesBatch(ec2api ec2iface.EC2API) BatchExecutor[ec2.Descri
 		return results
 	}
 }
+
+// execListInstancesBatch lists the instances that match the filters once, and returns the listing to every request
+func execListInstancesBatch(ctx context.Context, ec2api ec2iface.EC2API, input *ec2.DescribeInstancesInput, results []Result[ec2.DescribeInstancesOutput]) []Result[ec2.DescribeInstancesOutput] {
+	out := &ec2.DescribeInstancesOutput{}
+	err := ec2api.DescribeInstancesPagesWithContext(ctx, input, func(page *ec2.DescribeInstancesOutput, _ bool) bool {
+		out.Reservations = append(out.Reservations, page.Reservations...)
+		return true
+	})
+	for i := range results {
+		results[i] = Result[ec2.DescribeInstancesOutput]{Output: out, Err: err}
+	}
+	return results
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
 
 	"github.com/aws/karpenter/pkg/batcher"
 	"github.com/aws/karpenter/pkg/fake"
@@ -178,4 +179,34 @@ var _ = Describe("DescribeInstances Batcher", func() {
 		// We expect 6 calls since we do one full batched call and 5 individual since the batched call returns an error
 		Expect(fakeEC2API.DescribeInstancesBehavior.Calls()).To(BeNumerically("==", 6))
 	})
+	It("should share a single listing between concurrent lists with the same filters", func() {
+		for _, id := range []string{"i-1", "i-2", "i-3"} {
+			fakeEC2API.Instances.Store(id, &ec2.Instance{
+				InstanceId: aws.String(id),
+				Tags:       []*ec2.Tag{{Key: aws.String("karpenter.sh/provisioner-name"), Value: aws.String(lo.Ternary(id == "i-3", "other", "default"))}},
+			})
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				rsp, err := cfb.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+					Filters: []*ec2.Filter{{Name: aws.String("tag:karpenter.sh/provisioner-name"), Values: aws.StringSlice([]string{"default"})}},
+				})
+				Expect(err).To(BeNil())
+				Expect(rsp.Reservations).To(HaveLen(1))
+				Expect(rsp.Reservations[0].Instances).To(HaveLen(2))
+			}()
+		}
+		wg.Wait()
+		Expect(fakeEC2API.DescribeInstancesBehavior.CalledWithInput.Len()).To(BeNumerically("==", 1))
+		Expect(fakeEC2API.DescribeInstancesBehavior.CalledWithInput.Pop().InstanceIds).To(BeEmpty())
+	})
+	It("should not list instances without filters", func() {
+		_, err := cfb.DescribeInstances(ctx, &ec2.DescribeInstancesInput{})
+		Expect(err).ToNot(BeNil())
+		Expect(fakeEC2API.DescribeInstancesBehavior.Calls()).To(BeNumerically("==", 0))
+	})
 })
@@ -22,6 +22,7 @@ import (
 
 type EC2API struct {
 	*CreateFleetBatcher
+	*CreateTagsBatcher
 	*DescribeInstancesBatcher
 	*TerminateInstancesBatcher
 }
@@ -29,6 +30,7 @@ type EC2API struct {
 func EC2(ctx context.Context, ec2api ec2iface.EC2API) *EC2API {
 	return &EC2API{
 		CreateFleetBatcher:        NewCreateFleetBatcher(ctx, ec2api),
+		CreateTagsBatcher:         NewCreateTagsBatcher(ctx, ec2api),
 		DescribeInstancesBatcher:  NewDescribeInstancesBatcher(ctx, ec2api),
 		TerminateInstancesBatcher: NewTerminateInstancesBatcher(ctx, ec2api),
 	}
@@ -48,8 +48,21 @@ var (
 		Help:      "Size of the request batch per batcher",
 		Buckets:   SizeBuckets(),
 	}, []string{batcherNameLabel})
+	batchExecutionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: batcherSubsystem,
+		Name:      "batch_execution_time_seconds",
+		Help:      "Duration of executing a request batch against the batched API per batcher",
+		Buckets:   metrics.DurationBuckets(),
+	}, []string{batcherNameLabel})
+	batchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: batcherSubsystem,
+		Name:      "request_errors_total",
+		Help:      "Number of batched requests that returned an error to their caller per batcher",
+	}, []string{batcherNameLabel})
 )
 
 func init() {
-	crmetrics.Registry.MustRegister(batchWindowDuration, batchSize)
+	crmetrics.Registry.MustRegister(batchWindowDuration, batchSize, batchExecutionDuration, batchErrors)
 }
@@ -16,6 +16,8 @@ package batcher_test
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -26,6 +28,7 @@ import (
 	"github.com/aws/karpenter-core/pkg/test/expectations"
 	"github.com/aws/karpenter/pkg/batcher"
 	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/utils/attribution"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -88,6 +91,75 @@ var _ = Describe("Batcher", func() {
 			Eventually(fakeBatcher.completedBatches.Load, time.Second*3).Should(BeNumerically("==", 300))
 		})
 	})
+	Context("Batch Size", func() {
+		It("should split a batch into executions of at most the max batch size", func() {
+			var executions []int
+			var mu sync.Mutex
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "fake_split",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    1 * time.Second,
+				MaxBatchSize:  10,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					mu.Lock()
+					executions = append(executions, len(items))
+					mu.Unlock()
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] { return batcher.Result[string]{Output: i} })
+				},
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 25; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					name := test.RandomName()
+					result := b.Add(cancelCtx, lo.ToPtr(name))
+					Expect(result.Err).ToNot(HaveOccurred())
+					Expect(*result.Output).To(Equal(name))
+				}()
+			}
+			wg.Wait()
+
+			Expect(lo.Sum(executions)).To(Equal(25))
+			Expect(lo.Max(executions)).To(BeNumerically("<=", 10))
+		})
+	})
+	Context("Context", func() {
+		It("should execute with the batcher's context and the attribution of every caller", func() {
+			var executionCtx context.Context
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "fake_context",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    1 * time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executionCtx = ctx
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] { return batcher.Result[string]{Output: i} })
+				},
+			})
+			// The first caller goes away before the batch is executed, which shouldn't fail the call for the others
+			canceledCtx, cancelCaller := context.WithCancel(attribution.WithNodePool(ctx, "canceled"))
+			cancelCaller()
+			var wg sync.WaitGroup
+			for _, callerCtx := range []context.Context{canceledCtx, attribution.WithNodePool(ctx, "default"), attribution.WithNodePool(ctx, "default")} {
+				wg.Add(1)
+				go func(callerCtx context.Context) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Expect(b.Add(callerCtx, lo.ToPtr(test.RandomName())).Err).ToNot(HaveOccurred())
+				}(callerCtx)
+			}
+			wg.Wait()
+			Expect(executionCtx.Err()).ToNot(HaveOccurred())
+			Expect(attribution.AllFromContext(executionCtx)).To(ConsistOf(
+				attribution.Attribution{NodePool: "canceled"},
+				attribution.Attribution{NodePool: "default"},
+			))
+		})
+	})
 	Context("Metrics", func() {
 		It("should create a batch_size metric when a batch is run", func() {
 			// This batcher will get canceled at the end of the test run
@@ -124,6 +196,53 @@ var _ = Describe("Batcher", func() {
 			})
 			Expect(ok).To(BeTrue())
 		})
+		It("should create a batch_execution_time_seconds metric when a batch is run", func() {
+			// This batcher will get canceled at the end of the test run
+			fakeBatcher = NewFakeBatcher(cancelCtx, 0, 100)
+
+			for i := 0; i < 10; i++ {
+				go func() {
+					fakeBatcher.batcher.Add(cancelCtx, lo.ToPtr(test.RandomName()))
+				}()
+			}
+			Eventually(fakeBatcher.completedBatches.Load).Should(BeNumerically("==", 10))
+
+			Eventually(func() bool {
+				_, ok := expectations.FindMetricWithLabelValues("karpenter_cloudprovider_batcher_batch_execution_time_seconds", map[string]string{
+					"batcher": "fake",
+				})
+				return ok
+			}).Should(BeTrue())
+		})
+		It("should count the requests that return an error to their caller", func() {
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "fake_errors",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    1 * time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					// Only return a result for the first item, the rest should receive an error
+					return []batcher.Result[string]{{Err: fmt.Errorf("failed")}}
+				},
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Expect(b.Add(cancelCtx, lo.ToPtr(test.RandomName())).Err).To(HaveOccurred())
+				}()
+			}
+			wg.Wait()
+
+			metric, ok := expectations.FindMetricWithLabelValues("karpenter_cloudprovider_batcher_request_errors_total", map[string]string{
+				"batcher": "fake_errors",
+			})
+			Expect(ok).To(BeTrue())
+			Expect(metric.GetCounter().GetValue()).To(BeNumerically("==", 3))
+		})
 	})
 })
 
@@ -38,6 +38,7 @@ func NewTerminateInstancesBatcher(ctx context.Context, ec2api ec2iface.EC2API) *
 		IdleTimeout:   100 * time.Millisecond,
 		MaxTimeout:    1 * time.Second,
 		MaxItems:      500,
+		MaxBatchSize:  500,
 		RequestHasher: OneBucketHasher[ec2.TerminateInstancesInput],
 		BatchExecutor: execTerminateInstancesBatch(ec2api),
 	}
@@ -0,0 +1,110 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// BootstrapFailureThreshold is the number of nodes of an instance type that have to fail to launch or register,
+// without one registering in between, before the instance type is excluded from their NodeClass
+const BootstrapFailureThreshold = 3
+
+// BootstrapFailures stores the nodes of each NodeClass that failed to launch or register by instance type, whatever
+// their zone, so that instance types that consistently fail with the NodeClass, such as older generations that its AMI
+// doesn't support, are excluded from it for a while
+type BootstrapFailures struct {
+	mu sync.Mutex
+	// key: <is-node-template>:<nodeclass>:<instance-type>, value: sets.Set[string] of the nodes that failed
+	failures *cache.Cache
+	// key: <is-node-template>:<nodeclass>:<instance-type>, value: string reason of the last failure
+	excluded *cache.Cache
+}
+
+// Exclusion is why an instance type is excluded from a NodeClass, and when it's launched for the NodeClass again
+type Exclusion struct {
+	// Reason is how the last node of the instance type failed before it was excluded
+	Reason string
+	Until  time.Time
+}
+
+func NewBootstrapFailures() *BootstrapFailures {
+	return &BootstrapFailures{
+		failures: cache.New(BootstrapFailuresTTL, DefaultCleanupInterval),
+		excluded: cache.New(BootstrapFailureExclusionTTL, DefaultCleanupInterval),
+	}
+}
+
+// MarkFailed records that the node, identified by the name of its Machine, failed to launch or register for the
+// reason, and returns true if its instance type is excluded from the NodeClass as a result. Recording the same node
+// more than once is a no-op.
+func (b *BootstrapFailures) MarkFailed(nodeClass nodeclassutil.Key, instanceType string, node string, reason string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := b.key(nodeClass, instanceType)
+	failures := sets.New[string]()
+	if existing, ok := b.failures.Get(key); ok {
+		failures = existing.(sets.Set[string])
+	}
+	failures.Insert(node)
+	if failures.Len() < BootstrapFailureThreshold {
+		b.failures.SetDefault(key, failures)
+		return false
+	}
+	b.failures.Delete(key)
+	b.excluded.SetDefault(key, reason)
+	return true
+}
+
+// MarkRegistered forgets the registration failures of the instance type, since its nodes can register with the
+// NodeClass. An instance type that is already excluded stays excluded until its exclusion expires.
+func (b *BootstrapFailures) MarkRegistered(nodeClass nodeclassutil.Key, instanceType string) {
+	b.failures.Delete(b.key(nodeClass, instanceType))
+}
+
+// IsExcluded returns true if the instance type is excluded from the NodeClass
+func (b *BootstrapFailures) IsExcluded(nodeClass nodeclassutil.Key, instanceType string) bool {
+	_, ok := b.excluded.Get(b.key(nodeClass, instanceType))
+	return ok
+}
+
+// Excluded returns the instance types that are excluded from the NodeClass, why, and when their exclusions expire
+func (b *BootstrapFailures) Excluded(nodeClass nodeclassutil.Key) map[string]Exclusion {
+	prefix := b.key(nodeClass, "")
+	res := map[string]Exclusion{}
+	for key, item := range b.excluded.Items() {
+		if strings.HasPrefix(key, prefix) {
+			res[strings.TrimPrefix(key, prefix)] = Exclusion{Reason: item.Object.(string), Until: time.Unix(0, item.Expiration)}
+		}
+	}
+	return res
+}
+
+func (b *BootstrapFailures) Flush() {
+	b.failures.Flush()
+	b.excluded.Flush()
+}
+
+func (b *BootstrapFailures) key(nodeClass nodeclassutil.Key, instanceType string) string {
+	return fmt.Sprintf("%t:%s:%s", nodeClass.IsNodeTemplate, nodeClass.Name, instanceType)
+}
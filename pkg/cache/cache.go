@@ -24,11 +24,51 @@ const (
 	// AWS APIs, which can have a serious impact on performance and scalability.
 	// DO NOT CHANGE THIS VALUE WITHOUT DUE CONSIDERATION
 	DefaultTTL = time.Minute
-	// UnavailableOfferingsTTL is the time before offerings that were marked as unavailable
+	// UnavailableOfferingsTTL is the default time before offerings that were marked as unavailable
 	// are removed from the cache and are available for launch again
 	UnavailableOfferingsTTL = 3 * time.Minute
+	// UnavailableOfferingsHistoryTTL is how long an insufficient capacity error lowers the score of its offering, which
+	// outlasts the UnavailableOfferingsTTL so that offerings that were recently unavailable are launched less eagerly
+	UnavailableOfferingsHistoryTTL = time.Hour
+	// SpotPlacementScoresTTL is how long the spot placement score of an offering is used to score it. Scores are
+	// refreshed hourly, so they're kept until shortly after the next refresh is due.
+	SpotPlacementScoresTTL = 90 * time.Minute
+	// SpotPlacementScoreRequestsTTL is how long the scores of a spot placement score request are reused for identical
+	// requests. It's shorter than the hourly refresh so that every refresh gets new scores, while NodePools whose spot
+	// launches would request the same instance types share a single call within a refresh.
+	SpotPlacementScoreRequestsTTL = 50 * time.Minute
 	// InstanceTypesAndZonesTTL is the time before we refresh instance types and zones at EC2
 	InstanceTypesAndZonesTTL = 5 * time.Minute
+	// ReadOnlyTTL is the time that Karpenter stops attempting mutating calls against AWS after being denied
+	// access to them. Once this expires, the next launch will probe whether permissions have been restored.
+	ReadOnlyTTL = 5 * time.Minute
+	// WarmUpTimeout is the longest that provisioning waits for the provider caches to be warmed up after the
+	// controller starts or becomes the leader, so that a slow or failing warm-up never blocks launches for long
+	WarmUpTimeout = time.Minute
+	// SpotInterruptionsTTL is how long a spot interruption counts towards a NodePool's recent interruptions when
+	// deciding whether to spread its spot launches across more capacity pools
+	SpotInterruptionsTTL = 30 * time.Minute
+	// SpotLaunchFailuresTTL is how long the spot launch failures of a NodePool are remembered after its last failure.
+	// A NodePool that keeps failing to launch spot is retried whenever its unavailable offerings expire, which
+	// refreshes its failures well within this TTL.
+	SpotLaunchFailuresTTL = 30 * time.Minute
+	// BootstrapFailuresTTL is how long a node that failed to register counts towards excluding its instance type from
+	// its NodeClass
+	BootstrapFailuresTTL = time.Hour
+	// BootstrapFailureExclusionTTL is how long an instance type whose nodes repeatedly failed to register isn't launched
+	// for their NodeClass
+	BootstrapFailureExclusionTTL = time.Hour
+	// SSMFailureBackoff is how long an SSM parameter that failed to resolve isn't queried again after its first
+	// failure. The backoff doubles with every consecutive failure, up to SSMFailureMaxBackoff.
+	SSMFailureBackoff = 30 * time.Second
+	// SSMFailureMaxBackoff is the longest that an SSM parameter that keeps failing to resolve isn't queried again
+	SSMFailureMaxBackoff = 10 * time.Minute
+	// ConsoleOutputTTL is how long the console output of an instance whose node didn't register is kept, so that it's
+	// fetched at most once while its Machine waits to be deleted
+	ConsoleOutputTTL = 15 * time.Minute
+	// TagPolicyTTL is the time before the effective tag policy of the account is retrieved again. Tag policies change
+	// rarely and the Organizations API has low rate limits, so it's cached longer than other setup resources.
+	TagPolicyTTL = 15 * time.Minute
 )
 
 const (
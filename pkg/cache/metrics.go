@@ -0,0 +1,170 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	cacheLabel             = "cache"
+	instanceTypeLabel      = "instance_type"
+	zoneLabel              = "zone"
+	capacityTypeLabel      = "capacity_type"
+	reasonLabel            = "reason"
+)
+
+// Names of the provider caches, used as the value of the cache label
+const (
+	AMICacheName                        = "amis"
+	KubernetesVersionCacheName          = "kubernetes_version"
+	InstanceTypesCacheName              = "instance_types"
+	LaunchTemplatesCacheName            = "launch_templates"
+	SubnetsCacheName                    = "subnets"
+	SecurityGroupsCacheName             = "security_groups"
+	PricingCacheName                    = "pricing"
+	UnavailableOfferingsCacheName       = "unavailable_offerings"
+	CapacityReservationsCacheName       = "capacity_reservations"
+	TagPoliciesCacheName                = "tag_policies"
+	VPCCNICacheName                     = "vpc_cni"
+	SpotPlacementScoresCacheName        = "spot_placement_scores"
+	SpotPlacementScoreRequestsCacheName = "spot_placement_score_requests"
+)
+
+var (
+	CacheHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "cache_hits_total",
+			Help:      "Number of lookups that were served from a provider cache. Labeled by cache.",
+		},
+		[]string{cacheLabel},
+	)
+	CacheMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "cache_misses_total",
+			Help:      "Number of lookups that weren't found in a provider cache and required calling AWS. Labeled by cache.",
+		},
+		[]string{cacheLabel},
+	)
+	CacheEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "cache_evictions_total",
+			Help:      "Number of entries that expired or were deleted from a provider cache. Labeled by cache.",
+		},
+		[]string{cacheLabel},
+	)
+	CacheSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "cache_size",
+			Help:      "Number of entries currently held in a provider cache. Labeled by cache.",
+		},
+		[]string{cacheLabel},
+	)
+	UnavailableOfferingExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "unavailable_offering_expiry_time_seconds",
+			Help:      "Unix time at which an offering that is currently marked as unavailable will be considered for launches again. Labeled by instance type, zone, and capacity type.",
+		},
+		[]string{instanceTypeLabel, zoneLabel, capacityTypeLabel},
+	)
+	UnavailableOfferingTTL = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "unavailable_offering_ttl_seconds",
+			Help:      "Number of seconds that an offering that is currently marked as unavailable was last marked as unavailable for, which grows when the offering repeatedly returns insufficient capacity errors. Labeled by instance type, zone, and capacity type.",
+		},
+		[]string{instanceTypeLabel, zoneLabel, capacityTypeLabel},
+	)
+	UnavailableOfferingsMarked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "unavailable_offerings_marked_total",
+			Help:      "Number of times an offering was marked as unavailable. Labeled by instance type, zone, capacity type, and the reason the offering was unavailable.",
+		},
+		[]string{instanceTypeLabel, zoneLabel, capacityTypeLabel, reasonLabel},
+	)
+	ReadOnlyMode = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "read_only_mode",
+			Help:      "Whether Karpenter has been denied access to CreateFleet, CreateLaunchTemplate, or CreateTags and has stopped launching instances. 1 if read-only, 0 otherwise.",
+		},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(ReadOnlyMode, CacheHits, CacheMisses, CacheEvictions, CacheSize,
+		UnavailableOfferingExpiry, UnavailableOfferingTTL, UnavailableOfferingsMarked)
+}
+
+// Get returns the item for the key from the named cache, recording whether the lookup was a hit or a miss
+func Get(c *cache.Cache, name, key string) (interface{}, bool) {
+	item, ok := c.Get(key)
+	if ok {
+		CacheHits.With(prometheus.Labels{cacheLabel: name}).Inc()
+	} else {
+		CacheMisses.With(prometheus.Labels{cacheLabel: name}).Inc()
+	}
+	return item, ok
+}
+
+// SetDefault adds the item to the named cache with the default expiration, recording the size of the cache
+func SetDefault(c *cache.Cache, name, key string, item interface{}) {
+	c.SetDefault(key, item)
+	RecordSize(name, c.ItemCount())
+}
+
+// Set adds the item to the named cache with the expiration, recording the size of the cache
+func Set(c *cache.Cache, name, key string, item interface{}, expiration time.Duration) {
+	c.Set(key, item, expiration)
+	RecordSize(name, c.ItemCount())
+}
+
+// OnEvicted sets the function that is called when an item is evicted from the named cache, recording the eviction
+// and the resulting size of the cache. onEvicted may be nil if the caller doesn't need to be notified.
+func OnEvicted(c *cache.Cache, name string, onEvicted func(string, interface{})) {
+	c.OnEvicted(func(key string, item interface{}) {
+		CacheEvictions.With(prometheus.Labels{cacheLabel: name}).Inc()
+		RecordSize(name, c.ItemCount())
+		if onEvicted != nil {
+			onEvicted(key, item)
+		}
+	})
+}
+
+// RecordSize records the number of entries currently held by the named cache
+func RecordSize(name string, size int) {
+	CacheSize.With(prometheus.Labels{cacheLabel: name}).Set(float64(size))
+}
@@ -0,0 +1,67 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+
+	"github.com/patrickmn/go-cache"
+	"knative.dev/pkg/logging"
+)
+
+const readOnlyKey = "read-only"
+
+// ReadOnly tracks whether Karpenter has recently been denied access to the mutating AWS APIs that launches depend on
+// (CreateFleet, CreateLaunchTemplate, and CreateTags). While the denial is cached, callers should avoid issuing launches
+// that are known to fail, while continuing to serve read-only calls so that status and metrics are still reported.
+// Denials of other mutating calls, such as TerminateInstances, don't stop launches.
+type ReadOnly struct {
+	// key: read-only, value: operation that was denied
+	cache *cache.Cache
+}
+
+func NewReadOnly() *ReadOnly {
+	r := &ReadOnly{
+		cache: cache.New(ReadOnlyTTL, DefaultCleanupInterval),
+	}
+	r.cache.OnEvicted(func(_ string, _ interface{}) {
+		ReadOnlyMode.Set(0)
+	})
+	return r
+}
+
+// IsReadOnly returns the operation that was denied and true if Karpenter is currently in read-only mode
+func (r *ReadOnly) IsReadOnly() (string, bool) {
+	operation, found := r.cache.Get(readOnlyKey)
+	if !found {
+		return "", false
+	}
+	return operation.(string), true
+}
+
+// MarkDenied communicates that a mutating call was rejected due to missing IAM permissions
+func (r *ReadOnly) MarkDenied(ctx context.Context, operation string, err error) {
+	// even if the key is already in the cache, we still need to call Set to extend the cached entry's TTL
+	if _, found := r.cache.Get(readOnlyKey); !found {
+		logging.FromContext(ctx).With("operation", operation, "ttl", ReadOnlyTTL).Errorf("entering read-only mode, access denied to mutating AWS APIs, %s", err)
+	}
+	r.cache.SetDefault(readOnlyKey, operation)
+	ReadOnlyMode.Set(1)
+}
+
+func (r *ReadOnly) Flush() {
+	r.cache.Flush()
+	ReadOnlyMode.Set(0)
+}
@@ -0,0 +1,63 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/patrickmn/go-cache"
+
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+)
+
+// SpotInterruptions stores the spot interruptions that each NodePool recently received, so that the spot launches of
+// NodePools whose capacity keeps being reclaimed can be spread across more capacity pools
+type SpotInterruptions struct {
+	// key: <is-provisioner>:<nodepool>:<nodeclaim>, value: struct{}{}
+	cache *cache.Cache
+}
+
+func NewSpotInterruptions() *SpotInterruptions {
+	return &SpotInterruptions{
+		cache: cache.New(SpotInterruptionsTTL, DefaultCleanupInterval),
+	}
+}
+
+// MarkInterrupted records that the NodePool's NodeClaim received a spot interruption warning. Repeated warnings for the
+// same NodeClaim are only counted once.
+func (s *SpotInterruptions) MarkInterrupted(nodePool nodepoolutil.Key, nodeClaimName string) {
+	s.cache.SetDefault(s.key(nodePool, nodeClaimName), struct{}{})
+}
+
+// Count returns the number of spot interruptions that the NodePool received within the SpotInterruptionsTTL
+func (s *SpotInterruptions) Count(nodePool nodepoolutil.Key) int {
+	prefix := s.key(nodePool, "")
+	count := 0
+	for key := range s.cache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *SpotInterruptions) Flush() {
+	s.cache.Flush()
+}
+
+func (s *SpotInterruptions) key(nodePool nodepoolutil.Key, nodeClaimName string) string {
+	return fmt.Sprintf("%t:%s:%s", nodePool.IsProvisioner, nodePool.Name, nodeClaimName)
+}
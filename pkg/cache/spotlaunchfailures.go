@@ -0,0 +1,94 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"k8s.io/utils/clock"
+
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+)
+
+// SpotLaunchFailures stores the spot launches of each NodePool that failed for lack of capacity since its last
+// successful spot launch, so that NodePools can require spot to be unavailable for a while before falling back to
+// on-demand
+type SpotLaunchFailures struct {
+	mu  sync.Mutex
+	clk clock.Clock
+	// key: <is-provisioner>:<nodepool>, value: SpotLaunchFailure
+	cache *cache.Cache
+}
+
+// SpotLaunchFailure is the spot launch failures of a NodePool
+type SpotLaunchFailure struct {
+	// Count is the number of spot launches that failed since the last successful spot launch
+	Count int
+	// Since is when the first of these launches failed
+	Since time.Time
+}
+
+func NewSpotLaunchFailures(clk clock.Clock) *SpotLaunchFailures {
+	return &SpotLaunchFailures{
+		clk:   clk,
+		cache: cache.New(SpotLaunchFailuresTTL, DefaultCleanupInterval),
+	}
+}
+
+// MarkFailed records that a spot launch of the NodePool failed for lack of capacity
+func (s *SpotLaunchFailures) MarkFailed(nodePool nodepoolutil.Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failure, ok := s.Get(nodePool)
+	if !ok {
+		failure.Since = s.clk.Now()
+	}
+	failure.Count++
+	s.cache.SetDefault(s.key(nodePool), failure)
+}
+
+// MarkSucceeded forgets the spot launch failures of the NodePool, since spot capacity is available again
+func (s *SpotLaunchFailures) MarkSucceeded(nodePool nodepoolutil.Key) {
+	s.cache.Delete(s.key(nodePool))
+}
+
+// Get returns the spot launch failures of the NodePool since its last successful spot launch
+func (s *SpotLaunchFailures) Get(nodePool nodepoolutil.Key) (SpotLaunchFailure, bool) {
+	failure, ok := s.cache.Get(s.key(nodePool))
+	if !ok {
+		return SpotLaunchFailure{}, false
+	}
+	return failure.(SpotLaunchFailure), true
+}
+
+// FailingFor returns how long the spot launches of the NodePool have been failing since its last successful spot launch
+func (s *SpotLaunchFailures) FailingFor(nodePool nodepoolutil.Key) time.Duration {
+	failure, ok := s.Get(nodePool)
+	if !ok {
+		return 0
+	}
+	return s.clk.Since(failure.Since)
+}
+
+func (s *SpotLaunchFailures) Flush() {
+	s.cache.Flush()
+}
+
+func (s *SpotLaunchFailures) key(nodePool nodepoolutil.Key) string {
+	return fmt.Sprintf("%t:%s", nodePool.IsProvisioner, nodePool.Name)
+}
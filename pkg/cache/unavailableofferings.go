@@ -17,28 +17,49 @@ package cache
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
 	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
 )
 
 // UnavailableOfferings stores any offerings that return ICE (insufficient capacity errors) when
 // attempting to launch the capacity. These offerings are ignored as long as they are in the cache on
-// GetInstanceTypes responses
+// GetInstanceTypes responses. Offerings are unavailable for the aws.unavailableOfferingsTTL setting, which doubles with
+// every insufficient capacity error that the offering returned within the UnavailableOfferingsHistoryTTL, up to the
+// aws.unavailableOfferingsMaxTTL setting.
 type UnavailableOfferings struct {
 	// key: <capacityType>:<instanceType>:<zone>, value: struct{}{}
-	cache  *cache.Cache
-	SeqNum uint64
+	cache *cache.Cache
+	// history holds the times of the insufficient capacity errors of each offering within the
+	// UnavailableOfferingsHistoryTTL
+	// key: <capacityType>:<instanceType>:<zone>, value: []time.Time
+	history   *cache.Cache
+	historyMu sync.Mutex
+	SeqNum    uint64
 }
 
 func NewUnavailableOfferings() *UnavailableOfferings {
-	return &UnavailableOfferings{
-		cache:  cache.New(UnavailableOfferingsTTL, DefaultCleanupInterval),
-		SeqNum: 0,
+	u := &UnavailableOfferings{
+		cache:   cache.New(UnavailableOfferingsTTL, DefaultCleanupInterval),
+		history: cache.New(UnavailableOfferingsHistoryTTL, DefaultCleanupInterval),
+		SeqNum:  0,
 	}
+	OnEvicted(u.cache, UnavailableOfferingsCacheName, func(key string, _ interface{}) {
+		UnavailableOfferingExpiry.Delete(offeringLabels(key))
+		UnavailableOfferingTTL.Delete(offeringLabels(key))
+	})
+	return u
 }
 
 // IsUnavailable returns true if the offering appears in the cache
@@ -50,31 +71,141 @@ func (u *UnavailableOfferings) IsUnavailable(instanceType, zone, capacityType st
 // MarkUnavailable communicates recently observed temporary capacity shortages in the provided offerings
 func (u *UnavailableOfferings) MarkUnavailable(ctx context.Context, unavailableReason, instanceType, zone, capacityType string) {
 	// even if the key is already in the cache, we still need to call Set to extend the cached entry's TTL
+	ttl := u.ttl(ctx, instanceType, zone, capacityType)
 	logging.FromContext(ctx).With(
 		"reason", unavailableReason,
 		"instance-type", instanceType,
 		"zone", zone,
 		"capacity-type", capacityType,
-		"ttl", UnavailableOfferingsTTL).Debugf("removing offering from offerings")
-	u.cache.SetDefault(u.key(instanceType, zone, capacityType), struct{}{})
+		"ttl", ttl).Debugf("removing offering from offerings")
+	key := u.key(instanceType, zone, capacityType)
+	Set(u.cache, UnavailableOfferingsCacheName, key, struct{}{}, ttl)
+	u.recordUnavailable(key)
 	atomic.AddUint64(&u.SeqNum, 1)
+	if _, expiration, ok := u.cache.GetWithExpiration(key); ok {
+		UnavailableOfferingExpiry.With(offeringLabels(key)).Set(float64(expiration.Unix()))
+	}
+	UnavailableOfferingTTL.With(offeringLabels(key)).Set(ttl.Seconds())
+	UnavailableOfferingsMarked.With(lo.Assign(offeringLabels(key), prometheus.Labels{reasonLabel: unavailableReason})).Inc()
+}
+
+// ttl returns how long the offering is unavailable for when it's marked as unavailable again
+func (u *UnavailableOfferings) ttl(ctx context.Context, instanceType, zone, capacityType string) time.Duration {
+	ttl, maxTTL := settings.FromContext(ctx).UnavailableOfferingsTTL, settings.FromContext(ctx).UnavailableOfferingsMaxTTL
+	for i := u.RecentlyUnavailable(instanceType, zone, capacityType); i > 0 && ttl < maxTTL; i-- {
+		ttl *= 2
+	}
+	return lo.Ternary(maxTTL > 0 && ttl > maxTTL, maxTTL, ttl)
+}
+
+// RecentlyUnavailable returns the number of times the offering was marked as unavailable within the
+// UnavailableOfferingsHistoryTTL, including while it's still unavailable
+func (u *UnavailableOfferings) RecentlyUnavailable(instanceType, zone, capacityType string) int {
+	u.historyMu.Lock()
+	defer u.historyMu.Unlock()
+	return len(u.recentHistory(u.key(instanceType, zone, capacityType)))
 }
 
+// recordUnavailable adds an insufficient capacity error to the history of the offering, which is kept for the
+// UnavailableOfferingsHistoryTTL after its latest error
+func (u *UnavailableOfferings) recordUnavailable(key string) {
+	u.historyMu.Lock()
+	defer u.historyMu.Unlock()
+	u.history.SetDefault(key, append(u.recentHistory(key), time.Now()))
+}
+
+// recentHistory returns the times of the offering's insufficient capacity errors within the
+// UnavailableOfferingsHistoryTTL
+func (u *UnavailableOfferings) recentHistory(key string) []time.Time {
+	history, ok := u.history.Get(key)
+	if !ok {
+		return nil
+	}
+	return lo.Filter(history.([]time.Time), func(t time.Time, _ int) bool { return time.Since(t) < UnavailableOfferingsHistoryTTL })
+}
+
+// MarkUnavailableForFleetErr marks the offering of the override that the fleet error is for as unavailable. Errors that
+// don't name an instance type, like those of overrides with instance requirements, are ignored.
 func (u *UnavailableOfferings) MarkUnavailableForFleetErr(ctx context.Context, fleetErr *ec2.CreateFleetError, capacityType string) {
+	if fleetErr.LaunchTemplateAndOverrides == nil || fleetErr.LaunchTemplateAndOverrides.Overrides == nil || fleetErr.LaunchTemplateAndOverrides.Overrides.InstanceType == nil {
+		return
+	}
 	instanceType := aws.StringValue(fleetErr.LaunchTemplateAndOverrides.Overrides.InstanceType)
 	zone := aws.StringValue(fleetErr.LaunchTemplateAndOverrides.Overrides.AvailabilityZone)
 	u.MarkUnavailable(ctx, aws.StringValue(fleetErr.ErrorCode), instanceType, zone, capacityType)
 }
 
+// UnavailableOffering is an offering that is currently marked as unavailable
+type UnavailableOffering struct {
+	InstanceType string    `json:"instanceType"`
+	Zone         string    `json:"zone"`
+	CapacityType string    `json:"capacityType"`
+	Expiration   time.Time `json:"expiration"`
+}
+
+// List returns the offerings that are currently marked as unavailable, ordered by capacity type, instance type and zone
+func (u *UnavailableOfferings) List() []UnavailableOffering {
+	var offerings []UnavailableOffering
+	for key, item := range u.cache.Items() {
+		labels := offeringLabels(key)
+		offerings = append(offerings, UnavailableOffering{
+			InstanceType: labels[instanceTypeLabel],
+			Zone:         labels[zoneLabel],
+			CapacityType: labels[capacityTypeLabel],
+			Expiration:   time.Unix(0, item.Expiration),
+		})
+	}
+	sort.Slice(offerings, func(i, j int) bool {
+		return u.key(offerings[i].InstanceType, offerings[i].Zone, offerings[i].CapacityType) <
+			u.key(offerings[j].InstanceType, offerings[j].Zone, offerings[j].CapacityType)
+	})
+	return offerings
+}
+
+// ListMatching returns the offerings that are currently marked as unavailable and match the instance type, zone and
+// capacity type that aren't empty
+func (u *UnavailableOfferings) ListMatching(instanceType, zone, capacityType string) []UnavailableOffering {
+	return lo.Filter(u.List(), func(o UnavailableOffering, _ int) bool {
+		return lo.Contains([]string{"", o.InstanceType}, instanceType) &&
+			lo.Contains([]string{"", o.Zone}, zone) &&
+			lo.Contains([]string{"", o.CapacityType}, capacityType)
+	})
+}
+
+// DeleteMatching makes the offerings that ListMatching returns available again, and returns how many there were
+func (u *UnavailableOfferings) DeleteMatching(instanceType, zone, capacityType string) int {
+	offerings := u.ListMatching(instanceType, zone, capacityType)
+	for _, o := range offerings {
+		u.Delete(o.InstanceType, o.Zone, o.CapacityType)
+	}
+	return len(offerings)
+}
+
+// Delete makes the offering available again. Insufficient capacity errors that it returned still extend the TTL
+// when it's marked as unavailable again.
 func (u *UnavailableOfferings) Delete(instanceType string, zone string, capacityType string) {
 	u.cache.Delete(u.key(instanceType, zone, capacityType))
+	atomic.AddUint64(&u.SeqNum, 1)
 }
 
 func (u *UnavailableOfferings) Flush() {
 	u.cache.Flush()
+	u.history.Flush()
+	atomic.AddUint64(&u.SeqNum, 1)
+	UnavailableOfferingExpiry.Reset()
+	UnavailableOfferingTTL.Reset()
 }
 
 // key returns the cache key for all offerings in the cache
 func (u *UnavailableOfferings) key(instanceType string, zone string, capacityType string) string {
 	return fmt.Sprintf("%s:%s:%s", capacityType, instanceType, zone)
 }
+
+// offeringLabels returns the metric labels for the offering with the cache key
+func offeringLabels(key string) prometheus.Labels {
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) != 3 {
+		return prometheus.Labels{instanceTypeLabel: "", zoneLabel: "", capacityTypeLabel: ""}
+	}
+	return prometheus.Labels{capacityTypeLabel: parts[0], instanceTypeLabel: parts[1], zoneLabel: parts[2]}
+}
@@ -0,0 +1,44 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"knative.dev/pkg/logging"
+)
+
+// UnavailableOfferingsPath is the path on the metrics server that unavailable offerings are inspected on
+const UnavailableOfferingsPath = "/unavailableofferings"
+
+// NewUnavailableOfferingsHandler returns a handler that lists the offerings that are currently marked as unavailable on
+// GET requests. Only the offerings that match the instanceType, zone and capacityType query parameters that are set are
+// listed. The metrics server isn't authenticated, so the handler is read-only, and offerings are made available again
+// through the interruption queue instead.
+func NewUnavailableOfferingsHandler(unavailableOfferings *UnavailableOfferings) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "only GET requests are supported", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(unavailableOfferings.ListMatching(query.Get("instanceType"), query.Get("zone"), query.Get("capacityType"))); err != nil {
+			logging.FromContext(r.Context()).Errorf("listing unavailable offerings, %s", err)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WarmUp tracks whether the provider caches have been warmed up since the controller started. Provisioning waits on
+// it so that NodeClasses aren't all resolved against the EC2 APIs at the same moment, which trips API throttling
+type WarmUp struct {
+	done chan struct{}
+	once sync.Once
+}
+
+func NewWarmUp() *WarmUp {
+	return &WarmUp{done: make(chan struct{})}
+}
+
+// MarkDone releases everything waiting on the warm-up. It is safe to call more than once
+func (w *WarmUp) MarkDone() {
+	w.once.Do(func() { close(w.done) })
+}
+
+// IsDone returns true if the warm-up has completed
+func (w *WarmUp) IsDone() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until the warm-up has completed, WarmUpTimeout has passed, or the context is done
+func (w *WarmUp) Wait(ctx context.Context) error {
+	timeout := time.NewTimer(WarmUpTimeout)
+	defer timeout.Stop()
+	select {
+	case <-w.done:
+		return nil
+	case <-timeout.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
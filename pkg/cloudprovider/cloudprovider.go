@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
@@ -33,16 +34,21 @@ import (
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	awserrors "github.com/aws/karpenter/pkg/errors"
 	"github.com/aws/karpenter/pkg/utils"
+	"github.com/aws/karpenter/pkg/utils/attribution"
 	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 
 	"github.com/aws/karpenter-core/pkg/scheduling"
 	"github.com/aws/karpenter-core/pkg/utils/resources"
 
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
 	"knative.dev/pkg/logging"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -53,6 +59,7 @@ import (
 	"github.com/aws/karpenter/pkg/providers/instancetype"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
 	"github.com/aws/karpenter/pkg/providers/subnet"
+	"github.com/aws/karpenter/pkg/utils/tracing"
 
 	coreapis "github.com/aws/karpenter-core/pkg/apis"
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
@@ -74,10 +81,13 @@ type CloudProvider struct {
 	securityGroupProvider *securitygroup.Provider
 	subnetProvider        *subnet.Provider
 	recorder              events.Recorder
+	warmUp                *awscache.WarmUp
+	clk                   clock.Clock
 }
 
 func New(instanceTypeProvider *instancetype.Provider, instanceProvider *instance.Provider, recorder events.Recorder,
-	kubeClient client.Client, amiProvider *amifamily.Provider, securityGroupProvider *securitygroup.Provider, subnetProvider *subnet.Provider) *CloudProvider {
+	kubeClient client.Client, amiProvider *amifamily.Provider, securityGroupProvider *securitygroup.Provider, subnetProvider *subnet.Provider,
+	warmUp *awscache.WarmUp, clk clock.Clock) *CloudProvider {
 	return &CloudProvider{
 		instanceTypeProvider:  instanceTypeProvider,
 		instanceProvider:      instanceProvider,
@@ -86,11 +96,24 @@ func New(instanceTypeProvider *instancetype.Provider, instanceProvider *instance
 		securityGroupProvider: securityGroupProvider,
 		subnetProvider:        subnetProvider,
 		recorder:              recorder,
+		warmUp:                warmUp,
+		clk:                   clk,
 	}
 }
 
 // Create a machine given the constraints.
-func (c *CloudProvider) Create(ctx context.Context, machine *v1alpha5.Machine) (*v1alpha5.Machine, error) {
+func (c *CloudProvider) Create(ctx context.Context, machine *v1alpha5.Machine) (_ *v1alpha5.Machine, err error) {
+	ctx, span := tracing.Start(ctx, "cloudprovider.Create",
+		attribute.String("machine", machine.Name),
+		attribute.String("provisioner", machine.Labels[v1alpha5.ProvisionerNameLabelKey]),
+	)
+	defer func() { tracing.End(span, err) }()
+	if err := c.warmUp.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("waiting for cache warm-up, %w", err)
+	}
+	start := c.clk.Now()
+	ctx, timeline := instance.WithTimeline(ctx)
+	ctx = attribution.WithNodePool(ctx, machine.Labels[v1alpha5.ProvisionerNameLabelKey])
 	nodeClaim := nodeclaimutil.New(machine)
 	nodeClass, err := c.resolveNodeClassFromNodeClaim(ctx, nodeClaim)
 	if err != nil {
@@ -99,25 +122,70 @@ func (c *CloudProvider) Create(ctx context.Context, machine *v1alpha5.Machine) (
 		}
 		return nil, fmt.Errorf("resolving node class, %w", err)
 	}
+	ctx = attribution.WithNodeClass(ctx, nodeClass.Name)
+	// A NodeClass whose AMIs don't resolve can't launch anything, so it's refused here rather than failing while its
+	// launch templates are created
+	if condition := nodeClass.StatusConditions().GetCondition(v1beta1.NodeClassAMIsResolved); condition.IsFalse() {
+		c.recorder.Publish(cloudproviderevents.NodeClaimNodeClassAMIsNotResolved(nodeClaim, nodeClass.Name, condition.Message))
+		return nil, fmt.Errorf("resolving amis of node class %s, %s", nodeClass.Name, condition.Message)
+	}
 	instanceTypes, err := c.resolveInstanceTypes(ctx, nodeClaim, nodeClass)
 	if err != nil {
 		return nil, fmt.Errorf("resolving instance types, %w", err)
 	}
 	if len(instanceTypes) == 0 {
+		c.recommendSpotCapacity(ctx, nodeClaim, nodeClass)
 		return nil, cloudprovider.NewInsufficientCapacityError(fmt.Errorf("all requested instance types were unavailable during launch"))
 	}
-	instance, err := c.instanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+	instance.ObservePhase(ctx, instance.PhaseResolution, nodeclaimutil.OwnerKey(nodeClaim).Name, c.clk.Since(start))
+	launched, err := c.instanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
 	if err != nil {
+		if cloudprovider.IsInsufficientCapacityError(err) {
+			c.recommendSpotCapacity(ctx, nodeClaim, nodeClass)
+		}
+		if operation, ok := awserrors.ReadOnlyOperation(err); ok {
+			c.recorder.Publish(cloudproviderevents.NodeClaimReadOnly(nodeClaim, operation))
+		}
 		return nil, fmt.Errorf("creating instance, %w", err)
 	}
-	instanceType, _ := lo.Find(instanceTypes, func(i *cloudprovider.InstanceType) bool {
-		return i.Name == instance.Type
+	instanceType, ok := lo.Find(instanceTypes, func(i *cloudprovider.InstanceType) bool {
+		return i.Name == launched.Type
 	})
-	m := c.instanceToMachine(instance, instanceType)
+	// EC2 Fleet may choose an instance type that wasn't scheduled against when it selects instance types by their
+	// attributes, which is still one of the NodeClass's instance types that the NodeClaim's requirements allow. The instance is already running, so failing to
+	// resolve it only leaves the machine without the instance type's capacity.
+	if !ok && nodeClass.Spec.IsAttributeBasedInstanceSelection() {
+		if instanceType, err = c.resolveLaunchedInstanceType(ctx, nodeClaim, nodeClass, launched.Type); err != nil {
+			logging.FromContext(ctx).With("instance-type", launched.Type).Errorf("resolving launched instance type, %s", err)
+		}
+	}
+	m := c.instanceToMachine(launched, instanceType)
 	m.Annotations = lo.Assign(m.Annotations, nodeclassutil.HashAnnotation(nodeClass))
+	// Record the price that the launch decision was based on so that it can be compared with the billed price later.
+	// Core copies the annotations of the returned claim onto its node when it registers.
+	if price, ok := c.instanceTypeProvider.Price(m.Labels); ok {
+		m.Annotations[launchPriceAnnotationKey(machine)] = strconv.FormatFloat(price, 'f', -1, 64)
+	}
+	m.Annotations[launchTimelineAnnotationKey(machine)] = timeline.String()
 	return m, nil
 }
 
+// launchPriceAnnotationKey returns the launch price annotation for the API version of the claim; core converts
+// NodeClaims to Machines before launching them, so NodeClaims are told apart by their NodePool label
+func launchPriceAnnotationKey(machine *v1alpha5.Machine) string {
+	if _, ok := machine.Labels[corev1beta1.NodePoolLabelKey]; ok {
+		return v1beta1.AnnotationLaunchPrice
+	}
+	return v1alpha1.AnnotationLaunchPrice
+}
+
+func launchTimelineAnnotationKey(machine *v1alpha5.Machine) string {
+	if _, ok := machine.Labels[corev1beta1.NodePoolLabelKey]; ok {
+		return v1beta1.AnnotationLaunchTimeline
+	}
+	return v1alpha1.AnnotationLaunchTimeline
+}
+
 // Link adds a tag to the cloudprovider machine to tell the cloudprovider that it's now owned by a Machine
 func (c *CloudProvider) Link(ctx context.Context, machine *v1alpha5.Machine) error {
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("machine", machine.Name))
@@ -126,10 +194,12 @@ func (c *CloudProvider) Link(ctx context.Context, machine *v1alpha5.Machine) err
 		return fmt.Errorf("getting instance ID, %w", err)
 	}
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("id", id))
-	return c.instanceProvider.Link(ctx, id, machine.Labels[v1alpha5.ProvisionerNameLabelKey])
+	return c.instanceProvider.Link(withMachineAttribution(ctx, machine), id, machine.Labels[v1alpha5.ProvisionerNameLabelKey])
 }
 
-func (c *CloudProvider) List(ctx context.Context) ([]*v1alpha5.Machine, error) {
+func (c *CloudProvider) List(ctx context.Context) (_ []*v1alpha5.Machine, err error) {
+	ctx, span := tracing.Start(ctx, "cloudprovider.List")
+	defer func() { tracing.End(span, err) }()
 	instances, err := c.instanceProvider.List(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("listing instances, %w", err)
@@ -145,7 +215,9 @@ func (c *CloudProvider) List(ctx context.Context) ([]*v1alpha5.Machine, error) {
 	return machines, nil
 }
 
-func (c *CloudProvider) Get(ctx context.Context, providerID string) (*v1alpha5.Machine, error) {
+func (c *CloudProvider) Get(ctx context.Context, providerID string) (_ *v1alpha5.Machine, err error) {
+	ctx, span := tracing.Start(ctx, "cloudprovider.Get", attribute.String("provider-id", providerID))
+	defer func() { tracing.End(span, err) }()
 	id, err := utils.ParseInstanceID(providerID)
 	if err != nil {
 		return nil, fmt.Errorf("getting instance ID, %w", err)
@@ -171,6 +243,9 @@ func (c *CloudProvider) GetInstanceTypes(ctx context.Context, provisioner *v1alp
 	if provisioner == nil {
 		return c.instanceTypeProvider.List(ctx, &corev1beta1.KubeletConfiguration{}, &v1beta1.NodeClass{})
 	}
+	if err := c.warmUp.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("waiting for cache warm-up, %w", err)
+	}
 	nodePool := nodepoolutil.New(provisioner)
 	nodeClass, err := c.resolveNodeClassFromNodePool(ctx, nodePool)
 	if err != nil {
@@ -179,6 +254,7 @@ func (c *CloudProvider) GetInstanceTypes(ctx context.Context, provisioner *v1alp
 		}
 		return nil, client.IgnoreNotFound(fmt.Errorf("resolving node class, %w", err))
 	}
+	ctx = attribution.WithNodeClass(attribution.WithNodePool(ctx, nodePool.Name), nodeClass.Name)
 	// TODO, break this coupling
 	instanceTypes, err := c.instanceTypeProvider.List(ctx, nodePool.Spec.Template.Spec.KubeletConfiguration, nodeClass)
 	if err != nil {
@@ -187,7 +263,12 @@ func (c *CloudProvider) GetInstanceTypes(ctx context.Context, provisioner *v1alp
 	return instanceTypes, nil
 }
 
-func (c *CloudProvider) Delete(ctx context.Context, machine *v1alpha5.Machine) error {
+func (c *CloudProvider) Delete(ctx context.Context, machine *v1alpha5.Machine) (err error) {
+	ctx, span := tracing.Start(ctx, "cloudprovider.Delete",
+		attribute.String("machine", machine.Name),
+		attribute.String("provisioner", machine.Labels[v1alpha5.ProvisionerNameLabelKey]),
+	)
+	defer func() { tracing.End(span, err) }()
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("machine", machine.Name))
 
 	providerID := lo.Ternary(machine.Status.ProviderID != "", machine.Status.ProviderID, machine.Annotations[v1alpha5.MachineLinkedAnnotationKey])
@@ -196,6 +277,15 @@ func (c *CloudProvider) Delete(ctx context.Context, machine *v1alpha5.Machine) e
 		return fmt.Errorf("getting instance ID, %w", err)
 	}
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("id", id))
+	ctx = withMachineAttribution(ctx, machine)
+	// EC2 launches another instance for a persistent spot request once its instance is terminated, so the request of a
+	// spot instance that EC2 stops or hibernates on interruption is cancelled first
+	if behavior, err := instance.SpotInterruptionBehavior(nodeclaimutil.New(machine)); err == nil && behavior != ec2.SpotInstanceInterruptionBehaviorTerminate &&
+		machine.Labels[v1alpha5.LabelCapacityType] == v1alpha5.CapacityTypeSpot {
+		if err := c.instanceProvider.CancelSpotInstanceRequest(ctx, id); err != nil && !cloudprovider.IsMachineNotFoundError(err) {
+			return err
+		}
+	}
 	return c.instanceProvider.Delete(ctx, id)
 }
 
@@ -216,6 +306,7 @@ func (c *CloudProvider) IsMachineDrifted(ctx context.Context, machine *v1alpha5.
 		}
 		return "", client.IgnoreNotFound(fmt.Errorf("resolving node class, %w", err))
 	}
+	ctx = attribution.WithNodeClass(attribution.WithNodePool(ctx, nodePool.Name), nodeClass.Name)
 	driftReason, err := c.isNodeClassDrifted(ctx, nodeClaim, nodePool, nodeClass)
 	if err != nil {
 		return "", err
@@ -228,6 +319,15 @@ func (c *CloudProvider) Name() string {
 	return "aws"
 }
 
+// withMachineAttribution attributes the AWS API calls made for the machine to its provisioner and node template
+func withMachineAttribution(ctx context.Context, machine *v1alpha5.Machine) context.Context {
+	ctx = attribution.WithNodePool(ctx, machine.Labels[v1alpha5.ProvisionerNameLabelKey])
+	if machine.Spec.MachineTemplateRef != nil {
+		ctx = attribution.WithNodeClass(ctx, machine.Spec.MachineTemplateRef.Name)
+	}
+	return ctx
+}
+
 func (c *CloudProvider) resolveNodeClassFromNodeClaim(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) (*v1beta1.NodeClass, error) {
 	// TODO @joinnis: Remove this handling for Machine resolution when we remove v1alpha5
 	if nodeClaim.IsMachine {
@@ -296,6 +396,21 @@ func (c *CloudProvider) resolveInstanceTypes(ctx context.Context, nodeClaim *cor
 	}), nil
 }
 
+func (c *CloudProvider) resolveLaunchedInstanceType(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, nodeClass *v1beta1.NodeClass, name string) (*cloudprovider.InstanceType, error) {
+	instanceTypes, err := c.instanceTypeProvider.List(ctx, nodeClaim.Spec.KubeletConfiguration, nodeClass)
+	if err != nil {
+		return nil, fmt.Errorf("getting instance types, %w", err)
+	}
+	reqs := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)
+	instanceType, ok := lo.Find(instanceTypes, func(i *cloudprovider.InstanceType) bool {
+		return i.Name == name
+	})
+	if !ok || reqs.Compatible(instanceType.Requirements) != nil {
+		return nil, fmt.Errorf("instance type %s isn't allowed by the node claim's requirements", name)
+	}
+	return instanceType, nil
+}
+
 func (c *CloudProvider) resolveInstanceTypeFromInstance(ctx context.Context, instance *instance.Instance) (*cloudprovider.InstanceType, error) {
 	provisioner, err := c.resolveProvisionerFromInstance(ctx, instance)
 	if err != nil {
@@ -341,6 +456,9 @@ func (c *CloudProvider) instanceToMachine(i *instance.Instance, instanceType *cl
 	}
 	labels[v1.LabelTopologyZone] = i.Zone
 	labels[v1alpha5.LabelCapacityType] = i.CapacityType
+	if i.CapacityReservationID != "" {
+		labels[v1alpha1.LabelCapacityReservationID] = i.CapacityReservationID
+	}
 	if v, ok := i.Tags[v1alpha5.ProvisionerNameLabelKey]; ok {
 		labels[v1alpha5.ProvisionerNameLabelKey] = v
 	}
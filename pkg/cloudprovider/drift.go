@@ -37,9 +37,20 @@ const (
 	SubnetDrift        cloudprovider.DriftReason = "SubnetDrift"
 	SecurityGroupDrift cloudprovider.DriftReason = "SecurityGroupDrift"
 	NodeTemplateDrift  cloudprovider.DriftReason = "NodeTemplateDrift"
+	// SubnetRebalancingDrift is reported for nodes that the machine.subnetrebalancing controller selected to move out
+	// of a subnet that is running out of IP addresses, so that core's drift replaces them before they're deleted
+	SubnetRebalancingDrift cloudprovider.DriftReason = "SubnetRebalancingDrift"
 )
 
 func (c *CloudProvider) isNodeClassDrifted(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, nodePool *corev1beta1.NodePool, nodeClass *v1beta1.NodeClass) (cloudprovider.DriftReason, error) {
+	if _, ok := nodeClaim.Annotations[v1alpha1.AnnotationSubnetRebalancing]; ok {
+		return SubnetRebalancingDrift, nil
+	}
+	// Drift from changes on the AWS side, such as a newly released AMI, is only surfaced while one of the NodeClass'
+	// maintenance windows is open so that those replacements happen on the user's schedule
+	if !nodeClass.Spec.InMaintenanceWindow(c.clk.Now()) {
+		return c.areStaticFieldsDrifted(nodeClaim, nodeClass), nil
+	}
 	instance, err := c.getInstance(ctx, nodeClaim.Status.ProviderID)
 	if err != nil {
 		return "", err
@@ -56,7 +67,14 @@ func (c *CloudProvider) isNodeClassDrifted(ctx context.Context, nodeClaim *corev
 	if err != nil {
 		return "", fmt.Errorf("calculating subnet drift, %w", err)
 	}
-	drifted := lo.FindOrElse([]cloudprovider.DriftReason{amiDrifted, securitygroupDrifted, subnetDrifted, c.areStaticFieldsDrifted(nodeClaim, nodeClass)}, "", func(i cloudprovider.DriftReason) bool {
+	staticFieldsDrifted := c.areStaticFieldsDrifted(nodeClaim, nodeClass)
+	// When nothing but the security groups have drifted, the machine.securitygroup controller updates them in place
+	// instead of the node being replaced
+	if securitygroupDrifted != "" && amiDrifted == "" && subnetDrifted == "" && staticFieldsDrifted == "" &&
+		lo.FromPtr(nodeClass.Spec.SecurityGroupDriftRemediation) == v1beta1.SecurityGroupDriftRemediationInPlace {
+		securitygroupDrifted = ""
+	}
+	drifted := lo.FindOrElse([]cloudprovider.DriftReason{amiDrifted, securitygroupDrifted, subnetDrifted, staticFieldsDrifted}, "", func(i cloudprovider.DriftReason) bool {
 		return string(i) != ""
 	})
 	return drifted, nil
@@ -84,11 +102,12 @@ func (c *CloudProvider) isAMIDrifted(ctx context.Context, nodeClaim *corev1beta1
 	if len(amis) == 0 {
 		return "", fmt.Errorf("no amis exist given constraints")
 	}
-	mappedAMIs := amis.MapToInstanceTypes([]*cloudprovider.InstanceType{nodeInstanceType})
-	if len(mappedAMIs) == 0 {
+	// Nodes launched with either the current or the candidate AMIs of a rollout haven't drifted
+	amiIDs := amis.LaunchableIDs(nodeInstanceType)
+	if len(amiIDs) == 0 {
 		return "", fmt.Errorf("no instance types satisfy requirements of amis %v", amis)
 	}
-	if !lo.Contains(lo.Keys(mappedAMIs), instance.ImageID) {
+	if !lo.Contains(amiIDs, instance.ImageID) {
 		return AMIDrift, nil
 	}
 	return "", nil
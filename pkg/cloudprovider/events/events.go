@@ -15,6 +15,9 @@ limitations under the License.
 package events
 
 import (
+	"fmt"
+	"strings"
+
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
@@ -58,3 +61,68 @@ func NodeClaimFailedToResolveNodeClass(nodeClaim *v1beta1.NodeClaim) events.Even
 		DedupeValues:   []string{string(nodeClaim.UID)},
 	}
 }
+
+func NodeClaimNodeClassAMIsNotResolved(nodeClaim *v1beta1.NodeClaim, nodeClassName, reason string) events.Event {
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeWarning,
+			Reason:         "AMIsNotResolved",
+			Message:        fmt.Sprintf("Refusing to launch from AWSNodeTemplate %s since its amis didn't resolve, %s", nodeClassName, reason),
+			DedupeValues:   []string{string(machine.UID)},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeWarning,
+		Reason:         "AMIsNotResolved",
+		Message:        fmt.Sprintf("Refusing to launch from NodeClass %s since its amis didn't resolve, %s", nodeClassName, reason),
+		DedupeValues:   []string{string(nodeClaim.UID)},
+	}
+}
+
+func NodeClaimReadOnly(nodeClaim *v1beta1.NodeClaim, operation string) events.Event {
+	message := fmt.Sprintf("Launching is paused in read-only mode since access was denied to %s, check the controller's IAM permissions", operation)
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeWarning,
+			Reason:         "ReadOnly",
+			Message:        message,
+			DedupeValues:   []string{string(machine.UID), operation},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeWarning,
+		Reason:         "ReadOnly",
+		Message:        message,
+		DedupeValues:   []string{string(nodeClaim.UID), operation},
+	}
+}
+
+func NodePoolSpotCapacityUnavailable(nodePool *v1beta1.NodePool, recommendations []string) events.Event {
+	message := "Spot capacity was unavailable and no other spot pools with capacity are cheaper than on-demand"
+	if len(recommendations) > 0 {
+		message = fmt.Sprintf("Spot capacity was unavailable, spot pools with capacity cheaper than on-demand are %s", strings.Join(recommendations, ", "))
+	}
+	if nodePool.IsProvisioner {
+		provisioner := provisionerutil.New(nodePool)
+		return events.Event{
+			InvolvedObject: provisioner,
+			Type:           v1.EventTypeWarning,
+			Reason:         "SpotCapacityUnavailable",
+			Message:        message,
+			DedupeValues:   append([]string{string(provisioner.UID)}, recommendations...),
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodePool,
+		Type:           v1.EventTypeWarning,
+		Reason:         "SpotCapacityUnavailable",
+		Message:        message,
+		DedupeValues:   append([]string{string(nodePool.UID)}, recommendations...),
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter-core/pkg/utils/resources"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	cloudproviderevents "github.com/aws/karpenter/pkg/cloudprovider/events"
+)
+
+// maxSpotRecommendations is the number of spot pools that are recommended when spot capacity is unavailable, so that
+// the event stays readable
+const maxSpotRecommendations = 5
+
+// spotRecommendation is a spot pool, an instance type in a zone, that has capacity
+type spotRecommendation struct {
+	InstanceType string
+	Zone         string
+	Price        float64
+}
+
+func (r spotRecommendation) String() string {
+	return fmt.Sprintf("%s in %s ($%.4f)", r.InstanceType, r.Zone, r.Price)
+}
+
+// recommendSpotCapacity publishes an event on the NodePool of a NodeClaim whose spot instance couldn't be launched for
+// lack of capacity, with the cheapest spot pools that still have capacity. The prices are the spot prices of the
+// pools' spot price history, so operators can tell which requirements to relax rather than only that capacity was
+// insufficient.
+func (c *CloudProvider) recommendSpotCapacity(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, nodeClass *v1beta1.NodeClass) {
+	reqs := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)
+	if !reqs.Get(v1alpha5.LabelCapacityType).Has(v1alpha5.CapacityTypeSpot) {
+		return
+	}
+	nodePool, err := nodeclaimutil.Owner(ctx, c.kubeClient, nodeClaim)
+	if err != nil {
+		logging.FromContext(ctx).Debugf("resolving owner to recommend spot capacity, %s", err)
+		return
+	}
+	instanceTypes, err := c.instanceTypeProvider.List(ctx, nodeClaim.Spec.KubeletConfiguration, nodeClass)
+	if err != nil {
+		logging.FromContext(ctx).Debugf("listing instance types to recommend spot capacity, %s", err)
+		return
+	}
+	c.recorder.Publish(cloudproviderevents.NodePoolSpotCapacityUnavailable(nodePool, lo.Map(spotRecommendations(nodeClaim, instanceTypes), func(r spotRecommendation, _ int) string {
+		return r.String()
+	})))
+}
+
+// spotRecommendations returns the cheapest spot pools that have capacity, other than the pools that the NodeClaim
+// requested, and that are priced below the on-demand price of the cheapest instance type that it requested, so that
+// launching into them is cheaper than falling back to on-demand. The instance types of the pools have the
+// architecture and operating system that the NodeClaim requires and fit its requests, but they may be excluded by its
+// other requirements, such as its zones or instance families.
+func spotRecommendations(nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) []spotRecommendation {
+	reqs := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)
+	platform := scheduling.NewRequirements()
+	for _, key := range []string{v1.LabelArchStable, v1.LabelOSStable} {
+		if reqs.Has(key) {
+			platform.Add(reqs.Get(key))
+		}
+	}
+	ceiling := math.MaxFloat64
+	requestedNames := sets.New[string]()
+	for _, instanceType := range instanceTypes {
+		if reqs.Compatible(instanceType.Requirements) != nil || !resources.Fits(nodeClaim.Spec.Resources.Requests, instanceType.Allocatable()) {
+			continue
+		}
+		requestedNames.Insert(instanceType.Name)
+		for _, offering := range instanceType.Offerings {
+			if offering.CapacityType == v1alpha5.CapacityTypeOnDemand {
+				ceiling = math.Min(ceiling, offering.Price)
+			}
+		}
+	}
+	var recommendations []spotRecommendation
+	for _, instanceType := range instanceTypes {
+		if platform.Compatible(instanceType.Requirements) != nil || !resources.Fits(nodeClaim.Spec.Resources.Requests, instanceType.Allocatable()) {
+			continue
+		}
+		for _, offering := range instanceType.Offerings.Available() {
+			if offering.CapacityType != v1alpha5.CapacityTypeSpot || offering.Price >= ceiling {
+				continue
+			}
+			// The pools that the NodeClaim was launched into just failed, whatever the cache says
+			if requestedNames.Has(instanceType.Name) && reqs.Get(v1.LabelTopologyZone).Has(offering.Zone) {
+				continue
+			}
+			recommendations = append(recommendations, spotRecommendation{InstanceType: instanceType.Name, Zone: offering.Zone, Price: offering.Price})
+		}
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Price != recommendations[j].Price {
+			return recommendations[i].Price < recommendations[j].Price
+		}
+		if recommendations[i].InstanceType != recommendations[j].InstanceType {
+			return recommendations[i].InstanceType < recommendations[j].InstanceType
+		}
+		return recommendations[i].Zone < recommendations[j].Zone
+	})
+	if len(recommendations) > maxSpotRecommendations {
+		recommendations = recommendations[:maxSpotRecommendations]
+	}
+	return recommendations
+}
@@ -16,20 +16,25 @@ package cloudprovider_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/samber/lo"
 	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	v1 "k8s.io/api/core/v1"
 	clock "k8s.io/utils/clock/testing"
 
+	"github.com/aws/aws-sdk-go/service/computeoptimizer"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ssm"
 
@@ -40,15 +45,20 @@ import (
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/test"
 
 	"github.com/aws/karpenter/pkg/cloudprovider"
 
 	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/providers/instance"
 
 	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	corecloudproivder "github.com/aws/karpenter-core/pkg/cloudprovider"
+	machinelifecycle "github.com/aws/karpenter-core/pkg/controllers/machine/lifecycle"
 	"github.com/aws/karpenter-core/pkg/controllers/provisioning"
 	"github.com/aws/karpenter-core/pkg/controllers/state"
 	"github.com/aws/karpenter-core/pkg/events"
@@ -88,7 +98,7 @@ var _ = BeforeSuite(func() {
 	fakeClock = clock.NewFakeClock(time.Now())
 	recorder = events.NewRecorder(&record.FakeRecorder{})
 	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, recorder,
-		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider)
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.WarmUp, fakeClock)
 	cluster = state.NewCluster(fakeClock, env.Client, cloudProvider)
 	prov = provisioning.NewProvisioner(env.Client, env.KubernetesInterface.CoreV1(), recorder, cloudProvider, cluster)
 })
@@ -172,6 +182,233 @@ var _ = Describe("CloudProvider", func() {
 		_, ok := cloudProviderMachine.ObjectMeta.Annotations[v1alpha1.AnnotationNodeTemplateHash]
 		Expect(ok).To(BeTrue())
 	})
+	It("should annotate the machine with the price of the offering that it was launched into", func() {
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		cloudProviderMachine, err := cloudProvider.Create(ctx, machine)
+		Expect(err).To(BeNil())
+		price, ok := awsEnv.PricingProvider.Price(cloudProviderMachine.Labels)
+		Expect(ok).To(BeTrue())
+		Expect(cloudProviderMachine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationLaunchPrice, strconv.FormatFloat(price, 'f', -1, 64)))
+	})
+	It("should annotate the machine with the spot price when launching spot capacity", func() {
+		machine.Spec.Requirements = append(machine.Spec.Requirements, v1.NodeSelectorRequirement{
+			Key:      v1alpha5.LabelCapacityType,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{v1alpha5.CapacityTypeSpot},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		cloudProviderMachine, err := cloudProvider.Create(ctx, machine)
+		Expect(err).To(BeNil())
+		Expect(cloudProviderMachine.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeSpot))
+		price, ok := awsEnv.PricingProvider.SpotPrice(cloudProviderMachine.Labels[v1.LabelInstanceTypeStable], cloudProviderMachine.Labels[v1.LabelTopologyZone])
+		Expect(ok).To(BeTrue())
+		Expect(cloudProviderMachine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationLaunchPrice, strconv.FormatFloat(price, 'f', -1, 64)))
+	})
+	It("should annotate the machine with the time that each phase of its launch completed", func() {
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		cloudProviderMachine, err := cloudProvider.Create(ctx, machine)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cloudProviderMachine.Annotations).To(HaveKey(v1alpha1.AnnotationLaunchTimeline))
+		timeline := map[string]time.Time{}
+		Expect(json.Unmarshal([]byte(cloudProviderMachine.Annotations[v1alpha1.AnnotationLaunchTimeline]), &timeline)).To(Succeed())
+		Expect(timeline).To(HaveKey(instance.PhaseResolution))
+		Expect(timeline).To(HaveKey(instance.PhaseLaunchTemplate))
+		Expect(timeline).To(HaveKey(instance.PhaseCreateFleet))
+		Expect(timeline[instance.PhaseResolution]).ToNot(BeTemporally(">", timeline[instance.PhaseLaunchTemplate]))
+		Expect(timeline[instance.PhaseLaunchTemplate]).ToNot(BeTemporally(">", timeline[instance.PhaseCreateFleet]))
+	})
+	It("should cancel the persistent spot request of a machine before terminating its instance", func() {
+		machine.Annotations = lo.Assign(machine.Annotations, map[string]string{v1alpha1.AnnotationSpotInterruptionBehavior: "stop"})
+		machine.Spec.Requirements = append(machine.Spec.Requirements, v1.NodeSelectorRequirement{
+			Key:      v1alpha5.LabelCapacityType,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{v1alpha5.CapacityTypeSpot},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		cloudProviderMachine, err := cloudProvider.Create(ctx, machine)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(awsEnv.EC2API.RunInstancesBehavior.Calls()).To(Equal(1))
+		machine.Labels = lo.Assign(machine.Labels, cloudProviderMachine.Labels)
+		machine.Status.ProviderID = cloudProviderMachine.Status.ProviderID
+		Expect(cloudProvider.Delete(ctx, machine)).To(Succeed())
+		Expect(awsEnv.EC2API.CancelSpotInstanceRequestsBehavior.Calls()).To(Equal(1))
+		Expect(awsEnv.EC2API.TerminateInstancesBehavior.Calls()).To(Equal(1))
+	})
+	It("should not cancel the spot request of a machine whose instance terminates on interruption", func() {
+		machine.Spec.Requirements = append(machine.Spec.Requirements, v1.NodeSelectorRequirement{
+			Key:      v1alpha5.LabelCapacityType,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{v1alpha5.CapacityTypeSpot},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		cloudProviderMachine, err := cloudProvider.Create(ctx, machine)
+		Expect(err).ToNot(HaveOccurred())
+		machine.Labels = lo.Assign(machine.Labels, cloudProviderMachine.Labels)
+		machine.Status.ProviderID = cloudProviderMachine.Status.ProviderID
+		Expect(cloudProvider.Delete(ctx, machine)).To(Succeed())
+		Expect(awsEnv.EC2API.CancelSpotInstanceRequestsBehavior.Calls()).To(BeZero())
+	})
+	It("should annotate NodeClaims with the v1beta1 launch price annotation", func() {
+		machine.Labels[corev1beta1.NodePoolLabelKey] = provisioner.Name
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		cloudProviderMachine, err := cloudProvider.Create(ctx, machine)
+		Expect(err).To(BeNil())
+		price, ok := awsEnv.PricingProvider.Price(cloudProviderMachine.Labels)
+		Expect(ok).To(BeTrue())
+		Expect(cloudProviderMachine.Annotations).To(HaveKeyWithValue(v1beta1.AnnotationLaunchPrice, strconv.FormatFloat(price, 'f', -1, 64)))
+		Expect(cloudProviderMachine.Annotations).ToNot(HaveKey(v1alpha1.AnnotationLaunchPrice))
+	})
+	It("should propagate the launch price to the node when it registers", func() {
+		machineController := machinelifecycle.NewMachineController(fakeClock, env.Client, cloudProvider, recorder)
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		ExpectReconcileSucceeded(ctx, machineController, client.ObjectKeyFromObject(machine))
+		machine = ExpectExists(ctx, env.Client, machine)
+		Expect(machine.Annotations).To(HaveKey(v1alpha1.AnnotationLaunchPrice))
+
+		node := coretest.Node(coretest.NodeOptions{ProviderID: machine.Status.ProviderID})
+		ExpectApplied(ctx, env.Client, node)
+		ExpectReconcileSucceeded(ctx, machineController, client.ObjectKeyFromObject(machine))
+		node = ExpectExists(ctx, env.Client, node)
+		Expect(node.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationLaunchPrice, machine.Annotations[v1alpha1.AnnotationLaunchPrice]))
+	})
+	It("should not bias the launch price with compute optimizer findings", func() {
+		awsEnv.ComputeOptimizerAPI.GetEC2InstanceRecommendationsOutput.Set(&computeoptimizer.GetEC2InstanceRecommendationsOutput{
+			InstanceRecommendations: []*computeoptimizer.InstanceRecommendation{
+				{
+					InstanceArn:         aws.String("arn:aws:ec2:us-west-2:111122223333:instance/i-0123456789abcdef0"),
+					CurrentInstanceType: aws.String("m5.xlarge"),
+					Finding:             aws.String(computeoptimizer.FindingOverprovisioned),
+				},
+			},
+		})
+		Expect(awsEnv.ComputeOptimizerProvider.Update(ctx)).To(Succeed())
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ComputeOptimizerPriceBias: lo.ToPtr(0.5)}))
+		machine.Spec.Requirements = append(machine.Spec.Requirements, v1.NodeSelectorRequirement{
+			Key:      v1.LabelInstanceTypeStable,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{"m5.xlarge"},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		cloudProviderMachine, err := cloudProvider.Create(ctx, machine)
+		Expect(err).To(BeNil())
+		price, ok := awsEnv.PricingProvider.Price(cloudProviderMachine.Labels)
+		Expect(ok).To(BeTrue())
+		Expect(cloudProviderMachine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationLaunchPrice, strconv.FormatFloat(price, 'f', -1, 64)))
+	})
+	It("should wait for the cache warm-up before launching", func() {
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		warmingCloudProvider := cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, recorder,
+			env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awscache.NewWarmUp(), fakeClock)
+		cancelCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := warmingCloudProvider.Create(cancelCtx, machine)
+		Expect(err).To(HaveOccurred())
+		_, err = warmingCloudProvider.GetInstanceTypes(cancelCtx, provisioner)
+		Expect(err).To(HaveOccurred())
+		Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(BeZero())
+	})
+	Context("AMI Resolution", func() {
+		var eventRecorder *coretest.EventRecorder
+		var failClosedCloudProvider *cloudprovider.CloudProvider
+		BeforeEach(func() {
+			eventRecorder = coretest.NewEventRecorder()
+			failClosedCloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, eventRecorder,
+				env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.WarmUp, fakeClock)
+		})
+		It("should refuse to launch from a node template whose AMIs didn't resolve", func() {
+			nodeTemplate.StatusConditions().MarkFalse(v1beta1.NodeClassAMIsResolved, "NoAMIsResolved", "no amis exist given constraints")
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			_, err := failClosedCloudProvider.Create(ctx, machine)
+			Expect(err).To(HaveOccurred())
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeZero())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(BeZero())
+			Expect(eventRecorder.Calls("AMIsNotResolved")).To(Equal(1))
+			evt := eventRecorder.Events()[0]
+			Expect(evt.InvolvedObject.(*v1alpha5.Machine).Name).To(Equal(machine.Name))
+			Expect(evt.Message).To(Equal(fmt.Sprintf("Refusing to launch from AWSNodeTemplate %s since its amis didn't resolve, no amis exist given constraints", nodeTemplate.Name)))
+		})
+		It("should launch from a node template whose AMIs resolved", func() {
+			nodeTemplate.StatusConditions().MarkTrue(v1beta1.NodeClassAMIsResolved)
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			_, err := failClosedCloudProvider.Create(ctx, machine)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(eventRecorder.Calls("AMIsNotResolved")).To(BeZero())
+		})
+	})
+	Context("Read-Only Mode", func() {
+		It("should publish an event on the machine when access to CreateFleet is denied", func() {
+			eventRecorder := coretest.NewEventRecorder()
+			readOnlyCloudProvider := cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, eventRecorder,
+				env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.WarmUp, fakeClock)
+			awsEnv.EC2API.CreateFleetBehavior.Error.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			_, err := readOnlyCloudProvider.Create(ctx, machine)
+			Expect(err).To(HaveOccurred())
+			Expect(eventRecorder.Calls("ReadOnly")).To(Equal(1))
+			evt := eventRecorder.Events()[0]
+			Expect(evt.InvolvedObject.(*v1alpha5.Machine).Name).To(Equal(machine.Name))
+			Expect(evt.Message).To(ContainSubstring("CreateFleet"))
+		})
+	})
+	Context("Spot Capacity Recommendations", func() {
+		var eventRecorder *coretest.EventRecorder
+		var recommendingCloudProvider *cloudprovider.CloudProvider
+		BeforeEach(func() {
+			eventRecorder = coretest.NewEventRecorder()
+			recommendingCloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, eventRecorder,
+				env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.WarmUp, fakeClock)
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}},
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.xlarge"}},
+				{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
+			}
+		})
+		It("should recommend other spot pools when spot capacity is unavailable", func() {
+			awsEnv.EC2API.InsufficientCapacityPools.Set([]fake.CapacityPool{
+				{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
+			})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			_, err := recommendingCloudProvider.Create(ctx, machine)
+			Expect(corecloudproivder.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(eventRecorder.Calls("SpotCapacityUnavailable")).To(Equal(1))
+			evt := eventRecorder.Events()[0]
+			Expect(evt.InvolvedObject.(*v1alpha5.Provisioner).Name).To(Equal(provisioner.Name))
+			Expect(evt.Message).To(ContainSubstring("spot pools with capacity cheaper than on-demand are"))
+			Expect(evt.Message).ToNot(ContainSubstring("m5.xlarge in test-zone-1a"))
+		})
+		It("should not recommend spot pools priced above the on-demand price of the requested instance types", func() {
+			awsEnv.EC2API.InsufficientCapacityPools.Set([]fake.CapacityPool{
+				{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
+			})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			instanceTypes, err := recommendingCloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			onDemandPrice, ok := awsEnv.PricingProvider.OnDemandPrice("m5.xlarge")
+			Expect(ok).To(BeTrue())
+			_, err = recommendingCloudProvider.Create(ctx, machine)
+			Expect(corecloudproivder.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(eventRecorder.Calls("SpotCapacityUnavailable")).To(Equal(1))
+			message := eventRecorder.Events()[0].Message
+			for _, it := range instanceTypes {
+				for _, o := range it.Offerings {
+					if o.CapacityType == v1alpha5.CapacityTypeSpot && o.Price >= onDemandPrice {
+						Expect(message).ToNot(ContainSubstring(fmt.Sprintf("%s in %s (", it.Name, o.Zone)))
+					}
+				}
+			}
+		})
+		It("should not recommend spot pools when on-demand capacity is unavailable", func() {
+			machine.Spec.Requirements[0].Values = []string{v1alpha5.CapacityTypeOnDemand}
+			awsEnv.EC2API.InsufficientCapacityPools.Set([]fake.CapacityPool{
+				{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
+			})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			_, err := recommendingCloudProvider.Create(ctx, machine)
+			Expect(corecloudproivder.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(eventRecorder.Calls("SpotCapacityUnavailable")).To(BeZero())
+		})
+	})
 	Context("Defaulting", func() {
 		// Intent here is that if updates occur on the provisioningController, the Provisioner doesn't need to be recreated
 		It("should not set the InstanceProfile with the default if none provided in Provisioner", func() {
@@ -222,6 +459,70 @@ var _ = Describe("CloudProvider", func() {
 			Expect(createFleetInput.Context).To(BeNil())
 		})
 	})
+	Context("Launch Overrides", func() {
+		BeforeEach(func() {
+			awsEnv.EC2API.DescribeCapacityReservationsOutput.Set(&ec2.DescribeCapacityReservationsOutput{
+				CapacityReservations: []*ec2.CapacityReservation{
+					{
+						CapacityReservationId:  aws.String("cr-m5large"),
+						InstanceType:           aws.String("m5.large"),
+						AvailabilityZone:       aws.String("test-zone-1b"),
+						AvailableInstanceCount: aws.Int64(1),
+						State:                  aws.String(ec2.CapacityReservationStateActive),
+					},
+					{
+						CapacityReservationId:  aws.String("cr-m5xlarge"),
+						InstanceType:           aws.String("m5.xlarge"),
+						AvailabilityZone:       aws.String("test-zone-1a"),
+						AvailableInstanceCount: aws.Int64(1),
+						State:                  aws.String(ec2.CapacityReservationStateActive),
+					},
+				},
+			})
+		})
+		It("should launch into the subnet of the launch override annotation", func() {
+			provisioner.Spec.Annotations = map[string]string{v1alpha1.AnnotationLaunchSubnetID: "subnet-test2"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1.LabelTopologyZone, "test-zone-1b"))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf("subnet-test2"))
+		})
+		It("should not launch into a subnet that isn't selected by the node template", func() {
+			nodeTemplate.Spec.SubnetSelector = map[string]string{"Name": "test-subnet-1"}
+			machine.Annotations = map[string]string{v1alpha1.AnnotationLaunchSubnetID: "subnet-test2"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			_, err := cloudProvider.Create(ctx, machine)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("subnet-test2 is not selected"))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(BeZero())
+		})
+		It("should launch into the capacity reservation of the launch override annotation", func() {
+			machine.Annotations = map[string]string{v1alpha1.AnnotationLaunchCapacityReservationID: "cr-m5large"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			cloudProviderMachine, err := cloudProvider.Create(ctx, machine)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cloudProviderMachine.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "m5.large"))
+			Expect(cloudProviderMachine.Labels).To(HaveKeyWithValue(v1.LabelTopologyZone, "test-zone-1b"))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(createFleetInput.TargetCapacitySpecification.DefaultTargetCapacityType)).To(Equal(v1alpha5.CapacityTypeOnDemand))
+		})
+		It("should fail if the launch override annotation conflicts with a required capacity reservation", func() {
+			machine.Annotations = map[string]string{v1alpha1.AnnotationLaunchCapacityReservationID: "cr-m5large"}
+			machine.Spec.Requirements = append(machine.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key:      v1alpha1.LabelCapacityReservationID,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{"cr-m5xlarge"},
+			})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			_, err := cloudProvider.Create(ctx, machine)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("conflicts with required capacity reservation"))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(BeZero())
+		})
+	})
 	Context("Machine Drift", func() {
 		var validAMI string
 		var validSecurityGroup string
@@ -332,6 +633,28 @@ var _ = Describe("CloudProvider", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(isDrifted).To(Equal(cloudprovider.AMIDrift))
 		})
+		It("should return drifted if the AMI isn't one of the previous AMIs during a rollback", func() {
+			nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{v1alpha1.AnnotationAMIRollback: "true"})
+			nodeTemplate.Status.PreviousAMIs = []v1alpha1.AMI{{
+				ID:           fake.ImageID(),
+				Requirements: []v1.NodeSelectorRequirement{{Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.ArchitectureArm64}}},
+			}}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isDrifted).To(Equal(cloudprovider.AMIDrift))
+		})
+		It("should not return drifted if the AMI is one of the previous AMIs during a rollback", func() {
+			nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{v1alpha1.AnnotationAMIRollback: "true"})
+			nodeTemplate.Status.PreviousAMIs = []v1alpha1.AMI{{
+				ID:           validAMI,
+				Requirements: []v1.NodeSelectorRequirement{{Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.ArchitectureArm64}}},
+			}}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isDrifted).To(BeEmpty())
+		})
 		It("should return drifted if the subnet is not valid", func() {
 			instance.SubnetId = aws.String(fake.SubnetID())
 			isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
@@ -400,6 +723,42 @@ var _ = Describe("CloudProvider", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(isDrifted).To(BeEmpty())
 		})
+		It("should return drifted if the machine was selected for subnet rebalancing", func() {
+			machine.Annotations = lo.Assign(machine.Annotations, map[string]string{v1alpha1.AnnotationSubnetRebalancing: validSubnet1})
+			isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isDrifted).To(Equal(cloudprovider.SubnetRebalancingDrift))
+		})
+		Context("In-Place Security Group Remediation", func() {
+			var driftedSecurityGroup string
+			BeforeEach(func() {
+				driftedSecurityGroup = fake.SecurityGroupID()
+				instance.SecurityGroups = []*ec2.GroupIdentifier{{GroupId: aws.String(driftedSecurityGroup)}}
+				nodeTemplate.Spec.SecurityGroupDriftRemediation = aws.String(v1alpha1.SecurityGroupDriftRemediationInPlace)
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+			})
+			It("should leave the security groups to be updated in place instead of returning drifted", func() {
+				isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(BeEmpty())
+				Expect(awsEnv.EC2API.ModifyNetworkInterfaceAttributeBehavior.Calls()).To(BeZero())
+			})
+			It("should replace the node if anything other than its security groups has drifted", func() {
+				instance.SubnetId = aws.String(fake.SubnetID())
+				isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(Equal(cloudprovider.SecurityGroupDrift))
+				Expect(awsEnv.EC2API.ModifyNetworkInterfaceAttributeBehavior.Calls()).To(BeZero())
+			})
+			It("should not update the security groups in place by default", func() {
+				nodeTemplate.Spec.SecurityGroupDriftRemediation = nil
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(Equal(cloudprovider.SecurityGroupDrift))
+				Expect(awsEnv.EC2API.ModifyNetworkInterfaceAttributeBehavior.Calls()).To(BeZero())
+			})
+		})
 		It("should error if the machine doesn't have the instance-type label", func() {
 			machine.Labels = map[string]string{
 				v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
@@ -420,6 +779,46 @@ var _ = Describe("CloudProvider", func() {
 			_, err := cloudProvider.IsMachineDrifted(ctx, machine)
 			Expect(err).To(HaveOccurred())
 		})
+		Context("Maintenance Windows", func() {
+			BeforeEach(func() {
+				// September 6th, 2023 is a Wednesday
+				fakeClock.SetTime(time.Date(2023, time.September, 6, 12, 0, 0, 0, time.UTC))
+				nodeTemplate.Spec.MaintenanceWindows = []v1alpha1.MaintenanceWindow{
+					{Days: []string{"Saturday"}, Start: "02:00", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+				}
+			})
+			It("should not return drifted for a new AMI outside of a maintenance window", func() {
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				instance.ImageId = aws.String(fake.ImageID())
+				isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(BeEmpty())
+			})
+			It("should not return drifted for a security group change outside of a maintenance window", func() {
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				instance.SecurityGroups = []*ec2.GroupIdentifier{{GroupId: aws.String(fake.SecurityGroupID())}}
+				isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(BeEmpty())
+			})
+			It("should return drifted for a new AMI once the maintenance window opens", func() {
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				instance.ImageId = aws.String(fake.ImageID())
+				fakeClock.SetTime(time.Date(2023, time.September, 9, 3, 0, 0, 0, time.UTC))
+				isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(Equal(cloudprovider.AMIDrift))
+			})
+			It("should return drifted for AWSNodeTemplate changes outside of a maintenance window", func() {
+				nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{
+					v1alpha1.AnnotationNodeTemplateHash: "updated-hash",
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				isDrifted, err := cloudProvider.IsMachineDrifted(ctx, machine)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(Equal(cloudprovider.NodeTemplateDrift))
+			})
+		})
 		Context("Static Drift Detection", func() {
 			BeforeEach(func() {
 				provisioner = test.Provisioner(coretest.ProvisionerOptions{
@@ -454,6 +853,7 @@ var _ = Describe("CloudProvider", func() {
 				Entry("Context Drift", v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{Context: aws.String("context-2")}}),
 				Entry("DetailedMonitoring Drift", v1alpha1.AWSNodeTemplateSpec{DetailedMonitoring: aws.Bool(true)}),
 				Entry("AMIFamily Drift", v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{AMIFamily: aws.String(v1alpha1.AMIFamilyBottlerocket)}}),
+				Entry("CapacityTypeOverrides Drift", v1alpha1.AWSNodeTemplateSpec{CapacityTypeOverrides: []v1alpha1.CapacityTypeOverride{{CapacityType: v1alpha5.CapacityTypeSpot, UserData: aws.String("userdata-test-3")}}}),
 			)
 			DescribeTable("should not return drifted if dynamic fields are updated",
 				func(awsnodetemplatespec v1alpha1.AWSNodeTemplateSpec) {
@@ -0,0 +1,135 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consolidation
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/metrics"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	consolidationevents "github.com/aws/karpenter/pkg/controllers/consolidation/events"
+	"github.com/aws/karpenter/pkg/providers/pricing"
+)
+
+const (
+	launchingReason   = "DeprovisioningLaunching"
+	terminatingReason = "DeprovisioningTerminating"
+)
+
+// Recorder decorates the event recorder that is used by deprovisioning so that the estimated savings of consolidation
+// can be recorded. Consolidation publishes a launching event for each replacement once it is created, followed by a
+// terminating event for each of the candidates once all of the replacements are initialized.
+type Recorder struct {
+	events.Recorder
+
+	ctx             context.Context
+	kubeClient      client.Client
+	pricingProvider *pricing.Provider
+
+	mu           sync.Mutex
+	replacements []nodeclaimutil.Key // replacements launched by consolidation whose cost hasn't been accounted for
+}
+
+func Decorate(ctx context.Context, recorder events.Recorder, kubeClient client.Client, pricingProvider *pricing.Provider) *Recorder {
+	return &Recorder{
+		Recorder:        recorder,
+		ctx:             ctx,
+		kubeClient:      kubeClient,
+		pricingProvider: pricingProvider,
+	}
+}
+
+func (r *Recorder) Publish(evts ...events.Event) {
+	r.Recorder.Publish(evts...)
+	for _, evt := range evts {
+		nodeClaim, ok := asNodeClaim(evt.InvolvedObject)
+		if !ok || deprovisioner(evt) != metrics.ConsolidationReason {
+			continue
+		}
+		switch evt.Reason {
+		case launchingReason:
+			r.mu.Lock()
+			r.replacements = append(r.replacements, nodeclaimutil.Key{Name: nodeClaim.Name, IsMachine: nodeClaim.IsMachine})
+			r.mu.Unlock()
+		case terminatingReason:
+			r.recordSavings(nodeClaim)
+		}
+	}
+}
+
+// recordSavings compares the cost of the consolidated NodeClaim with any replacements that haven't been accounted
+// for yet. The replacements are attributed to the first candidate that is terminated after they are launched, so
+// the savings summed across all of the candidates of a consolidation action are the savings of the action.
+func (r *Recorder) recordSavings(nodeClaim *v1beta1.NodeClaim) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.pricingProvider.Price(nodeClaim.Labels)
+	if !ok {
+		return
+	}
+	after := 0.0
+	for _, key := range r.replacements {
+		replacement, err := nodeclaimutil.Get(r.ctx, r.kubeClient, key)
+		if err != nil {
+			// replacements that failed to launch are deleted by consolidation, so they don't cost anything
+			if client.IgnoreNotFound(err) != nil {
+				logging.FromContext(r.ctx).Errorf("getting consolidation replacement, %s", err)
+			}
+			continue
+		}
+		if price, ok := r.pricingProvider.Price(replacement.Labels); ok {
+			after += price
+		}
+	}
+	r.replacements = nil
+	r.Recorder.Publish(consolidationevents.Savings(nodeClaim, before, after))
+	if before > after {
+		Savings.WithLabelValues(nodeclaimutil.OwnerKey(nodeClaim).Name).Add(before - after)
+	}
+}
+
+// deprovisioner returns the deprovisioner that published a launching or terminating event. Deprovisioning dedupes
+// these events by the UID of the involved object and the reason for the action, which is formatted as
+// <deprovisioner>/<action>, so the deprovisioner is read from the reason rather than parsed from the message that's
+// displayed to users.
+func deprovisioner(evt events.Event) string {
+	if (evt.Reason != launchingReason && evt.Reason != terminatingReason) || len(evt.DedupeValues) != 2 {
+		return ""
+	}
+	deprovisioner, _, _ := strings.Cut(evt.DedupeValues[1], "/")
+	return deprovisioner
+}
+
+// asNodeClaim returns the NodeClaim for an event that's published for either a Machine or a NodeClaim
+func asNodeClaim(obj runtime.Object) (*v1beta1.NodeClaim, bool) {
+	switch o := obj.(type) {
+	case *v1alpha5.Machine:
+		return nodeclaimutil.New(o), true
+	case *v1beta1.NodeClaim:
+		return o, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consolidation_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	deprovisioningevents "github.com/aws/karpenter-core/pkg/controllers/deprovisioning/events"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/controllers/consolidation"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var eventRecorder *coretest.EventRecorder
+var recorder *consolidation.Recorder
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Consolidation")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	eventRecorder = coretest.NewEventRecorder()
+	recorder = consolidation.Decorate(ctx, eventRecorder, env.Client, awsEnv.PricingProvider)
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("Consolidation", func() {
+	var provisioner *v1alpha5.Provisioner
+
+	BeforeEach(func() {
+		provisioner = coretest.Provisioner()
+	})
+	machine := func(instanceType string) *v1alpha5.Machine {
+		return coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+					v1.LabelInstanceTypeStable:       instanceType,
+					v1.LabelTopologyZone:             "test-zone-1a",
+					v1alpha5.LabelCapacityType:       v1alpha5.CapacityTypeOnDemand,
+				},
+			},
+		})
+	}
+	savings := func() float64 {
+		metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_consolidation_savings_per_hour_total", map[string]string{
+			"provisioner": provisioner.Name,
+		})
+		Expect(ok).To(BeTrue())
+		return metric.GetCounter().GetValue()
+	}
+	price := func(instanceType string) float64 {
+		p, ok := awsEnv.PricingProvider.OnDemandPrice(instanceType)
+		Expect(ok).To(BeTrue())
+		return p
+	}
+
+	It("should record the difference in cost when replacing a machine", func() {
+		candidate := machine("m5.xlarge")
+		replacement := machine("m5.large")
+		ExpectApplied(ctx, env.Client, provisioner, candidate, replacement)
+
+		recorder.Publish(deprovisioningevents.Launching(nodeclaimutil.New(replacement), "consolidation/replace"))
+		recorder.Publish(deprovisioningevents.Terminating(coretest.Node(), nodeclaimutil.New(candidate), "consolidation/replace")...)
+
+		Expect(eventRecorder.Calls("ConsolidationSavings")).To(Equal(1))
+		Expect(savings()).To(BeNumerically("~", price("m5.xlarge")-price("m5.large")))
+	})
+	It("should record the full cost when deleting a machine", func() {
+		candidate := machine("m5.large")
+		ExpectApplied(ctx, env.Client, provisioner, candidate)
+
+		recorder.Publish(deprovisioningevents.Terminating(coretest.Node(), nodeclaimutil.New(candidate), "consolidation/delete")...)
+
+		Expect(eventRecorder.Calls("ConsolidationSavings")).To(Equal(1))
+		Expect(savings()).To(BeNumerically("~", price("m5.large")))
+	})
+	It("should not record savings for other deprovisioning reasons", func() {
+		candidate := machine("m5.large")
+		ExpectApplied(ctx, env.Client, provisioner, candidate)
+
+		recorder.Publish(deprovisioningevents.Terminating(coretest.Node(), nodeclaimutil.New(candidate), "drift/replace")...)
+
+		Expect(eventRecorder.Calls("ConsolidationSavings")).To(Equal(0))
+	})
+})
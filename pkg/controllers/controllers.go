@@ -16,9 +16,14 @@ package controllers
 
 import (
 	"context"
+	"net/http"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/utils/clock"
 	"knative.dev/pkg/logging"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -28,34 +33,115 @@ import (
 	"github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/cloudprovider"
 	"github.com/aws/karpenter/pkg/controllers/interruption"
+	machineamiage "github.com/aws/karpenter/pkg/controllers/machine/amiage"
+	machinecost "github.com/aws/karpenter/pkg/controllers/machine/cost"
+	machinedisruptionprotection "github.com/aws/karpenter/pkg/controllers/machine/disruptionprotection"
 	machinegarbagecollection "github.com/aws/karpenter/pkg/controllers/machine/garbagecollection"
+	machinelatency "github.com/aws/karpenter/pkg/controllers/machine/latency"
 	machinelink "github.com/aws/karpenter/pkg/controllers/machine/link"
+	machineliveness "github.com/aws/karpenter/pkg/controllers/machine/liveness"
+	machinemetadataoptions "github.com/aws/karpenter/pkg/controllers/machine/metadataoptions"
+	machinenotification "github.com/aws/karpenter/pkg/controllers/machine/notification"
+	machinerightsizing "github.com/aws/karpenter/pkg/controllers/machine/rightsizing"
+	machinesecuritygroup "github.com/aws/karpenter/pkg/controllers/machine/securitygroup"
+	machinessmagent "github.com/aws/karpenter/pkg/controllers/machine/ssmagent"
+	machinesubnetrebalancing "github.com/aws/karpenter/pkg/controllers/machine/subnetrebalancing"
+	machineterminationrecord "github.com/aws/karpenter/pkg/controllers/machine/terminationrecord"
 	"github.com/aws/karpenter/pkg/controllers/nodetemplate"
+	"github.com/aws/karpenter/pkg/controllers/offerings"
+	"github.com/aws/karpenter/pkg/controllers/provisioningtrigger"
+	"github.com/aws/karpenter/pkg/controllers/scheduledcapacityreservation"
+	spotplacementscorecontroller "github.com/aws/karpenter/pkg/controllers/spotplacementscore"
+	"github.com/aws/karpenter/pkg/controllers/upgrade"
+	"github.com/aws/karpenter/pkg/controllers/warmup"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/capacityreservation"
+	"github.com/aws/karpenter/pkg/providers/computeoptimizer"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/providers/instancetype"
 	"github.com/aws/karpenter/pkg/providers/pricing"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
+	"github.com/aws/karpenter/pkg/providers/spotplacementscore"
 	"github.com/aws/karpenter/pkg/providers/subnet"
+	"github.com/aws/karpenter/pkg/providers/tagpolicy"
 	"github.com/aws/karpenter/pkg/utils/project"
 
 	"github.com/aws/karpenter-core/pkg/operator/controller"
 )
 
-func NewControllers(ctx context.Context, sess *session.Session, clk clock.Clock, kubeClient client.Client, recorder events.Recorder,
-	unavailableOfferings *cache.UnavailableOfferings, cloudProvider *cloudprovider.CloudProvider, subnetProvider *subnet.Provider,
-	securityGroupProvider *securitygroup.Provider, pricingProvider *pricing.Provider, amiProvider *amifamily.Provider) []controller.Controller {
+func NewControllers(ctx context.Context, sess *session.Session, clk clock.Clock, kubeClient client.Client, kubernetesInterface kubernetes.Interface, recorder events.Recorder,
+	unavailableOfferings *cache.UnavailableOfferings, spotInterruptions *cache.SpotInterruptions, cloudProvider *cloudprovider.CloudProvider, subnetProvider *subnet.Provider,
+	securityGroupProvider *securitygroup.Provider, pricingProvider *pricing.Provider, amiProvider *amifamily.Provider,
+	instanceProvider *instance.Provider, computeOptimizerProvider *computeoptimizer.Provider, instanceTypeProvider *instancetype.Provider,
+	capacityReservationProvider *capacityreservation.Provider, spotPlacementScoreProvider *spotplacementscore.Provider, tagPolicyProvider *tagpolicy.Provider,
+	warmUp *cache.WarmUp, bootstrapFailures *cache.BootstrapFailures) []controller.Controller {
 
 	logging.FromContext(ctx).With("version", project.Version).Debugf("discovered version")
 
 	linkController := machinelink.NewController(kubeClient, cloudProvider)
 	controllers := []controller.Controller{
-		nodetemplate.NewNodeTemplateController(kubeClient, subnetProvider, securityGroupProvider, amiProvider),
+		nodetemplate.NewNodeTemplateController(kubeClient, recorder, subnetProvider, securityGroupProvider, amiProvider, tagPolicyProvider, bootstrapFailures),
+		scheduledcapacityreservation.NewNodeTemplateController(kubeClient, clk, recorder, capacityReservationProvider),
 		linkController,
 		machinegarbagecollection.NewController(kubeClient, cloudProvider, linkController),
+		machinelatency.NewMachineController(kubeClient, clk),
+		machinelatency.NewNodeClaimController(kubeClient, clk),
+		machineliveness.NewMachineController(kubeClient, clk, recorder, instanceProvider, bootstrapFailures),
+		machineliveness.NewNodeClaimController(kubeClient, clk, recorder, instanceProvider, bootstrapFailures),
+		machinedisruptionprotection.NewController(kubeClient, clk),
+		machinecost.NewController(kubeClient, pricingProvider),
+		machineamiage.NewController(kubeClient, clk, instanceProvider, amiProvider),
+		machinemetadataoptions.NewController(kubeClient, instanceProvider),
+		machinesecuritygroup.NewController(kubeClient, clk, recorder, instanceProvider),
+		warmup.NewController(kubeClient, subnetProvider, securityGroupProvider, amiProvider, instanceTypeProvider, pricingProvider, warmUp),
+		offerings.NewController(instanceTypeProvider),
+	}
+	// The upgrade readiness of nodes launched with the default AMIs is checked against public SSM parameters
+	if !settings.FromContext(ctx).Airgapped {
+		controllers = append(controllers, upgrade.NewController(kubeClient, recorder, amiProvider, instanceTypeProvider))
 	}
 	if settings.FromContext(ctx).InterruptionQueueName != "" {
-		controllers = append(controllers, interruption.NewController(kubeClient, clk, recorder, interruption.NewSQSProvider(sqs.New(sess)), unavailableOfferings))
+		controllers = append(controllers, interruption.NewController(kubeClient, clk, recorder, interruption.NewSQSProvider(sqs.New(sess)), unavailableOfferings, spotInterruptions,
+			subnetProvider, securityGroupProvider, amiProvider))
+	}
+	if settings.FromContext(ctx).NodeTerminationHandlerParity {
+		interruption.ReportFeatures(ctx)
+	}
+	if settings.FromContext(ctx).ProvisioningTriggerQueueName != "" {
+		controllers = append(controllers, provisioningtrigger.NewController(kubeClient, clk, cloudProvider, provisioningtrigger.NewSQSProvider(sqs.New(sess))))
+	}
+	var notifiers []machinenotification.Notifier
+	if settings.FromContext(ctx).LifecycleWebhookURL != "" {
+		notifiers = append(notifiers, machinenotification.NewHTTPNotifier(&http.Client{Timeout: 10 * time.Second}))
+	}
+	if settings.FromContext(ctx).LifecycleEventBusName != "" {
+		notifiers = append(notifiers, machinenotification.NewEventBridgeNotifier(eventbridge.New(sess)))
+	}
+	if len(notifiers) > 0 {
+		controllers = append(controllers, machinenotification.NewController(kubeClient, clk, notifiers...))
+	}
+	if settings.FromContext(ctx).EnableComputeOptimizer {
+		controllers = append(controllers, machinerightsizing.NewController(kubeClient, computeOptimizerProvider))
+	}
+	if settings.FromContext(ctx).SubnetRebalancingThreshold > 0 {
+		controllers = append(controllers, machinesubnetrebalancing.NewController(kubeClient, clk, recorder, subnetProvider, instanceProvider))
+	}
+	if settings.FromContext(ctx).TerminationRecordTTL > 0 {
+		controllers = append(controllers, machineterminationrecord.NewController(kubeClient, kubernetesInterface, clk, instanceProvider))
+	}
+	if settings.FromContext(ctx).VerifySSMAgentRegistration {
+		ssmapi := ssm.New(sess)
+		controllers = append(controllers,
+			machinessmagent.NewMachineController(kubeClient, clk, ssmapi),
+			machinessmagent.NewNodeClaimController(kubeClient, clk, ssmapi),
+		)
+	}
+	if settings.FromContext(ctx).SpotPlacementScoreTargetCapacity > 0 {
+		controllers = append(controllers, spotplacementscorecontroller.NewController(kubeClient, recorder, instanceTypeProvider, spotPlacementScoreProvider))
 	}
-	if settings.FromContext(ctx).IsolatedVPC {
+	if settings.FromContext(ctx).Airgapped {
+		logging.FromContext(ctx).Infof("airgapped mode is enabled, pricing information will not be updated and default amis will not be discovered from public ssm parameters")
+	} else if settings.FromContext(ctx).IsolatedVPC {
 		logging.FromContext(ctx).Infof("assuming isolated VPC, pricing information will not be updated")
 	} else {
 		controllers = append(controllers, pricing.NewController(pricingProvider))
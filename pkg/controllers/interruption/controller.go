@@ -19,10 +19,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go/service/ec2"
 	sqsapi "github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/utils/clock"
 	"knative.dev/pkg/logging"
@@ -37,8 +39,18 @@ import (
 	"github.com/aws/karpenter/pkg/cache"
 	interruptionevents "github.com/aws/karpenter/pkg/controllers/interruption/events"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/amiinvalidation"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/resourcechange"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/scheduledchange"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/spotinterruption"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/statechange"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/unavailableofferingsflush"
+	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/providers/securitygroup"
+	"github.com/aws/karpenter/pkg/providers/subnet"
 	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 
 	"github.com/aws/karpenter-core/pkg/events"
 	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
@@ -49,24 +61,35 @@ type Action string
 
 const (
 	CordonAndDrain Action = "CordonAndDrain"
-	NoAction       Action = "NoAction"
+	// DeferUntilMaintenanceWindow postpones a CordonAndDrain until the next maintenance window of the NodeClass
+	DeferUntilMaintenanceWindow Action = "DeferUntilMaintenanceWindow"
+	NoAction                    Action = "NoAction"
 )
 
 // Controller is an AWS interruption controller.
 // It continually polls an SQS queue for events from aws.ec2 and aws.health that
-// trigger node health events or node spot interruption/rebalance events.
+// trigger node health events or node spot interruption/rebalance events. Changes to subnets, security groups and
+// AMIs that are sent to the same queue invalidate the resolution of those resources that Karpenter has cached, as do
+// requests to invalidate the AMIs cached for a node class. Requests to flush unavailable offerings make them available
+// again.
 type Controller struct {
 	kubeClient                client.Client
 	clk                       clock.Clock
 	recorder                  events.Recorder
 	sqsProvider               *SQSProvider
 	unavailableOfferingsCache *cache.UnavailableOfferings
+	spotInterruptionsCache    *cache.SpotInterruptions
 	parser                    *EventParser
 	cm                        *pretty.ChangeMonitor
+	replacements              *replacementTracker
+	subnetProvider            *subnet.Provider
+	securityGroupProvider     *securitygroup.Provider
+	amiProvider               *amifamily.Provider
 }
 
 func NewController(kubeClient client.Client, clk clock.Clock, recorder events.Recorder,
-	sqsProvider *SQSProvider, unavailableOfferingsCache *cache.UnavailableOfferings) *Controller {
+	sqsProvider *SQSProvider, unavailableOfferingsCache *cache.UnavailableOfferings, spotInterruptionsCache *cache.SpotInterruptions,
+	subnetProvider *subnet.Provider, securityGroupProvider *securitygroup.Provider, amiProvider *amifamily.Provider) *Controller {
 
 	return &Controller{
 		kubeClient:                kubeClient,
@@ -74,8 +97,13 @@ func NewController(kubeClient client.Client, clk clock.Clock, recorder events.Re
 		recorder:                  recorder,
 		sqsProvider:               sqsProvider,
 		unavailableOfferingsCache: unavailableOfferingsCache,
+		spotInterruptionsCache:    spotInterruptionsCache,
 		parser:                    NewEventParser(DefaultParsers...),
 		cm:                        pretty.NewChangeMonitor(),
+		replacements:              newReplacementTracker(clk),
+		subnetProvider:            subnetProvider,
+		securityGroupProvider:     securityGroupProvider,
+		amiProvider:               amiProvider,
 	}
 }
 
@@ -84,6 +112,12 @@ func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconc
 	if c.cm.HasChanged(settings.FromContext(ctx).InterruptionQueueName, nil) {
 		logging.FromContext(ctx).Debugf("watching interruption queue")
 	}
+	if err := c.replacements.Observe(ctx, c.kubeClient); err != nil {
+		logging.FromContext(ctx).Errorf("observing replacements for interrupted nodes, %s", err)
+	}
+	if err := c.releaseDeferred(ctx); err != nil {
+		logging.FromContext(ctx).Errorf("replacing nodes deferred until a maintenance window, %s", err)
+	}
 	sqsMessages, err := c.sqsProvider.GetSQSMessages(ctx)
 	if err != nil {
 		return reconcile.Result{}, fmt.Errorf("getting messages from queue, %w", err)
@@ -148,6 +182,25 @@ func (c *Controller) handleMessage(ctx context.Context, nodeClaimInstanceIDMap m
 	if msg.Kind() == messages.NoOpKind {
 		return nil
 	}
+	if msg.Kind() == messages.ResourceChangeKind {
+		c.invalidateCaches(ctx, msg.(resourcechange.Message))
+		messageLatency.Observe(time.Since(msg.StartTime()).Seconds())
+		return nil
+	}
+	if msg.Kind() == messages.AMIInvalidationKind {
+		if err = c.invalidateAMIs(ctx, msg.(amiinvalidation.Message)); err != nil {
+			return fmt.Errorf("invalidating amis, %w", err)
+		}
+		messageLatency.Observe(time.Since(msg.StartTime()).Seconds())
+		return nil
+	}
+	if msg.Kind() == messages.UnavailableOfferingsFlushKind {
+		detail := msg.(unavailableofferingsflush.Message).Detail
+		count := c.unavailableOfferingsCache.DeleteMatching(detail.InstanceType, detail.Zone, detail.CapacityType)
+		logging.FromContext(ctx).With("count", count).Infof("made unavailable offerings available")
+		messageLatency.Observe(time.Since(msg.StartTime()).Seconds())
+		return nil
+	}
 	for _, instanceID := range msg.EC2InstanceIDs() {
 		nodeClaim, ok := nodeClaimInstanceIDMap[instanceID]
 		if !ok {
@@ -165,6 +218,39 @@ func (c *Controller) handleMessage(ctx context.Context, nodeClaimInstanceIDMap m
 	return nil
 }
 
+// invalidateCaches deletes the cached resolution of the resources that changed, so that launches don't use subnets,
+// security groups or AMIs that no longer match the selectors of a NodeClass, or miss ones that newly do, until the
+// cache expires
+func (c *Controller) invalidateCaches(ctx context.Context, msg resourcechange.Message) {
+	for _, change := range msg.Changes {
+		switch change.ResourceType {
+		case resourcechange.Subnet:
+			c.subnetProvider.Invalidate(ctx, change.ID, change.TagKeys)
+		case resourcechange.SecurityGroup:
+			c.securityGroupProvider.Invalidate(ctx, change.ID, change.Name, change.TagKeys)
+		case resourcechange.Image:
+			c.amiProvider.InvalidateImage(ctx, change.ID, change.Name, change.TagKeys)
+		}
+	}
+}
+
+// invalidateAMIs deletes the cached AMIs of the node class that the message names, or of every node class if it names
+// none. Messages for node classes that don't exist are dropped.
+func (c *Controller) invalidateAMIs(ctx context.Context, msg amiinvalidation.Message) error {
+	key := nodeclassutil.Key{Name: msg.Detail.NodeClass}
+	if msg.Detail.NodeTemplate != "" {
+		key = nodeclassutil.Key{Name: msg.Detail.NodeTemplate, IsNodeTemplate: true}
+	}
+	if err := c.amiProvider.InvalidateNodeClass(ctx, key); err != nil {
+		if errors.IsNotFound(err) {
+			logging.FromContext(ctx).Debugf("ignoring ami invalidation, %s", err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
 // deleteMessage removes the passed SQS message from the queue and fires a metric for the deletion
 func (c *Controller) deleteMessage(ctx context.Context, msg *sqsapi.Message) error {
 	if err := c.sqsProvider.DeleteSQSMessage(ctx, msg); err != nil {
@@ -176,7 +262,11 @@ func (c *Controller) deleteMessage(ctx context.Context, msg *sqsapi.Message) err
 
 // handleNodeClaim retrieves the action for the message and then performs the appropriate action against the node
 func (c *Controller) handleNodeClaim(ctx context.Context, msg messages.Message, nodeClaim *v1beta1.NodeClaim, node *v1.Node) error {
-	action := actionForMessage(msg)
+	action := actionForMessage(ctx, msg, nodeClaim)
+	// Scheduled changes are planned by AWS ahead of time, so we hold off on them until the NodeClass allows it
+	if action == CordonAndDrain && msg.Kind() == messages.ScheduledChangeKind && !c.inMaintenanceWindow(ctx, nodeClaim) {
+		action = DeferUntilMaintenanceWindow
+	}
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With(lo.Ternary(nodeClaim.IsMachine, "machine", "nodeclaim"), nodeClaim.Name))
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("action", string(action)))
 	if node != nil {
@@ -186,23 +276,30 @@ func (c *Controller) handleNodeClaim(ctx context.Context, msg messages.Message,
 	// Record metric and event for this action
 	c.notifyForMessage(msg, nodeClaim, node)
 	actionsPerformed.WithLabelValues(string(action)).Inc()
+	nodePoolInterruptions.WithLabelValues(nodeclaimutil.OwnerKey(nodeClaim).Name, string(msg.Kind())).Inc()
 
-	// Mark the offering as unavailable in the ICE cache since we got a spot interruption warning
+	// Mark the offering as unavailable in the ICE cache since we got a spot interruption warning, and count the
+	// interruption towards the NodePool's recent interruptions so its future spot launches are diversified
 	if msg.Kind() == messages.SpotInterruptionKind {
+		c.spotInterruptionsCache.MarkInterrupted(nodeclaimutil.OwnerKey(nodeClaim), nodeClaim.Name)
 		zone := nodeClaim.Labels[v1.LabelTopologyZone]
 		instanceType := nodeClaim.Labels[v1.LabelInstanceTypeStable]
 		if zone != "" && instanceType != "" {
 			c.unavailableOfferingsCache.MarkUnavailable(ctx, string(msg.Kind()), instanceType, zone, v1alpha1.CapacityTypeSpot)
 		}
 	}
-	if action != NoAction {
-		return c.deleteNodeClaim(ctx, nodeClaim, node)
+	switch action {
+	case CordonAndDrain:
+		return c.deleteNodeClaim(ctx, msg.Kind(), nodeClaim, node)
+	case DeferUntilMaintenanceWindow:
+		return c.deferNodeClaim(ctx, msg.(scheduledchange.Message), nodeClaim)
+	default:
+		return nil
 	}
-	return nil
 }
 
 // deleteNodeClaim removes the NodeClaim from the api-server
-func (c *Controller) deleteNodeClaim(ctx context.Context, nodeClaim *v1beta1.NodeClaim, node *v1.Node) error {
+func (c *Controller) deleteNodeClaim(ctx context.Context, kind messages.Kind, nodeClaim *v1beta1.NodeClaim, node *v1.Node) error {
 	if !nodeClaim.DeletionTimestamp.IsZero() {
 		return nil
 	}
@@ -212,6 +309,8 @@ func (c *Controller) deleteNodeClaim(ctx context.Context, nodeClaim *v1beta1.Nod
 	logging.FromContext(ctx).Infof("initiating delete from interruption message")
 	c.recorder.Publish(interruptionevents.TerminatingOnInterruption(node, nodeClaim)...)
 	nodeclaimutil.TerminatedCounter(nodeClaim, terminationReasonLabel).Inc()
+	nodePoolDrainedNodes.WithLabelValues(nodeclaimutil.OwnerKey(nodeClaim).Name, string(kind)).Inc()
+	c.replacements.Track(nodeClaim)
 	return nil
 }
 
@@ -281,11 +380,39 @@ func (c *Controller) makeNodeInstanceIDMap(ctx context.Context) (map[string]*v1.
 	return m, nil
 }
 
-func actionForMessage(msg messages.Message) Action {
+func actionForMessage(ctx context.Context, msg messages.Message, nodeClaim *v1beta1.NodeClaim) Action {
 	switch msg.Kind() {
-	case messages.ScheduledChangeKind, messages.SpotInterruptionKind, messages.StateChangeKind:
+	case messages.RebalanceRecommendationKind:
+		// aws-node-termination-handler drains nodes on rebalance recommendations when rebalance draining is enabled
+		if settings.FromContext(ctx).NodeTerminationHandlerParity {
+			return CordonAndDrain
+		}
+		return NoAction
+	case messages.ScheduledChangeKind:
+		return CordonAndDrain
+	case messages.SpotInterruptionKind:
+		// Spot instances that EC2 stops or hibernates on interruption resume once capacity is available again,
+		// so we keep the NodeClaim around rather than replacing it
+		if lo.Contains([]string{ec2.InstanceInterruptionBehaviorStop, ec2.InstanceInterruptionBehaviorHibernate}, msg.(spotinterruption.Message).Detail.InstanceAction) {
+			return NoAction
+		}
+		return CordonAndDrain
+	case messages.StateChangeKind:
+		if lo.Contains([]string{"stopping", "stopped"}, msg.(statechange.Message).Detail.State) && resumesAfterInterruption(nodeClaim) {
+			return NoAction
+		}
 		return CordonAndDrain
 	default:
 		return NoAction
 	}
 }
+
+// resumesAfterInterruption returns true if the NodeClaim is for a spot instance that EC2 stops or hibernates
+// on interruption instead of terminating it
+func resumesAfterInterruption(nodeClaim *v1beta1.NodeClaim) bool {
+	if nodeClaim.Labels[v1beta1.CapacityTypeLabelKey] != v1beta1.CapacityTypeSpot {
+		return false
+	}
+	behavior, err := instance.SpotInterruptionBehavior(nodeClaim)
+	return err == nil && behavior != ec2.SpotInstanceInterruptionBehaviorTerminate
+}
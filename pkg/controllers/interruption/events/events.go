@@ -207,3 +207,23 @@ func TerminatingOnInterruption(node *v1.Node, nodeClaim *v1beta1.NodeClaim) (evt
 	}
 	return evts
 }
+
+func MaintenanceDeferred(nodeClaim *v1beta1.NodeClaim) events.Event {
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeNormal,
+			Reason:         "MaintenanceDeferred",
+			Message:        "Replacement for a scheduled maintenance event was deferred until the next maintenance window",
+			DedupeValues:   []string{string(machine.UID)},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeNormal,
+		Reason:         "MaintenanceDeferred",
+		Message:        "Replacement for a scheduled maintenance event was deferred until the next maintenance window",
+		DedupeValues:   []string{string(nodeClaim.UID)},
+	}
+}
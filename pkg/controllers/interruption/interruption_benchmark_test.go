@@ -111,7 +111,9 @@ func benchmarkNotificationController(b *testing.B, messageCount int) {
 	unavailableOfferingsCache = awscache.NewUnavailableOfferings()
 
 	// Set-up the controllers
-	interruptionController := interruption.NewController(env.Client, fakeClock, recorder, providers.sqsProvider, unavailableOfferingsCache)
+	awsEnv := test.NewEnvironment(ctx, env)
+	interruptionController := interruption.NewController(env.Client, fakeClock, recorder, providers.sqsProvider, unavailableOfferingsCache, awscache.NewSpotInterruptions(),
+		awsEnv.SubnetProvider, awsEnv.SecurityGroupProvider, awsEnv.AMIProvider)
 
 	messages, nodes := makeDiverseMessagesAndNodes(messageCount)
 	logging.FromContext(ctx).Infof("provisioning nodes")
@@ -0,0 +1,103 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package interruption
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	interruptionevents "github.com/aws/karpenter/pkg/controllers/interruption/events"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/scheduledchange"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// MaintenanceLeadTime is how long before a scheduled maintenance event starts that a deferred NodeClaim is replaced,
+// even if its NodeClass' next maintenance window hasn't opened yet
+var MaintenanceLeadTime = time.Hour
+
+// inMaintenanceWindow returns true if the NodeClaim's NodeClass allows AWS-driven replacements right now. NodeClaims
+// whose NodeClass can't be resolved are never deferred.
+func (c *Controller) inMaintenanceWindow(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) bool {
+	if nodeClaim.Spec.NodeClass == nil {
+		return true
+	}
+	nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: nodeClaim.Spec.NodeClass.Name, IsNodeTemplate: nodeClaim.IsMachine})
+	if err != nil {
+		return true
+	}
+	return nodeClass.Spec.InMaintenanceWindow(c.clk.Now())
+}
+
+// deferNodeClaim annotates the NodeClaim so that it is replaced once its NodeClass' next maintenance window opens, or
+// shortly before the scheduled change starts, whichever comes first
+func (c *Controller) deferNodeClaim(ctx context.Context, msg scheduledchange.Message, nodeClaim *corev1beta1.NodeClaim) error {
+	key := lo.Ternary(nodeClaim.IsMachine, v1alpha1.AnnotationMaintenanceDeferred, v1beta1.AnnotationMaintenanceDeferred)
+	if _, ok := nodeClaim.Annotations[key]; ok || !nodeClaim.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	var deadline string
+	if startTime, err := time.Parse(time.RFC1123, msg.Detail.StartTime); err == nil {
+		deadline = startTime.UTC().Format(time.RFC3339)
+	}
+	stored := nodeClaim.DeepCopy()
+	nodeClaim.Annotations = lo.Assign(nodeClaim.Annotations, map[string]string{key: deadline})
+	if err := nodeclaimutil.Patch(ctx, c.kubeClient, stored, nodeClaim); err != nil {
+		return client.IgnoreNotFound(fmt.Errorf("deferring the node until the next maintenance window, %w", err))
+	}
+	logging.FromContext(ctx).With("deadline", deadline).Infof("deferring replacement until the next maintenance window")
+	c.recorder.Publish(interruptionevents.MaintenanceDeferred(nodeClaim))
+	return nil
+}
+
+// releaseDeferred replaces the NodeClaims that were deferred for a scheduled change once their NodeClass' maintenance
+// window opens, or once the scheduled change is about to start
+func (c *Controller) releaseDeferred(ctx context.Context) error {
+	nodeClaimList, err := nodeclaimutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for i := range nodeClaimList.Items {
+		nodeClaim := &nodeClaimList.Items[i]
+		deadline, ok := nodeClaim.Annotations[lo.Ternary(nodeClaim.IsMachine, v1alpha1.AnnotationMaintenanceDeferred, v1beta1.AnnotationMaintenanceDeferred)]
+		if !ok || !c.isDue(ctx, nodeClaim, deadline) {
+			continue
+		}
+		// The node may have already gone away, in which case we only publish events for the NodeClaim
+		node, _ := nodeclaimutil.NodeForNodeClaim(ctx, c.kubeClient, nodeClaim)
+		nodeCtx := logging.WithLogger(ctx, logging.FromContext(ctx).With(lo.Ternary(nodeClaim.IsMachine, "machine", "nodeclaim"), nodeClaim.Name))
+		errs = multierr.Append(errs, c.deleteNodeClaim(nodeCtx, messages.ScheduledChangeKind, nodeClaim, node))
+	}
+	return errs
+}
+
+// isDue returns true if a deferred NodeClaim should be replaced now
+func (c *Controller) isDue(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, deadline string) bool {
+	if t, err := time.Parse(time.RFC3339, deadline); err == nil && !c.clk.Now().Before(t.Add(-MaintenanceLeadTime)) {
+		return true
+	}
+	return c.inMaintenanceWindow(ctx, nodeClaim)
+}
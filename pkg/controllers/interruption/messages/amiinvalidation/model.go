@@ -0,0 +1,40 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package amiinvalidation
+
+import (
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+)
+
+// Message asks for the AMIs cached for an AWSNodeTemplate or a NodeClass to be invalidated, e.g. by the pipeline that
+// published a new AMI. Messages that name neither invalidate the AMIs cached for every node class.
+type Message struct {
+	messages.Metadata
+
+	Detail Detail `json:"detail"`
+}
+
+type Detail struct {
+	NodeTemplate string `json:"nodeTemplate,omitempty"`
+	NodeClass    string `json:"nodeClass,omitempty"`
+}
+
+func (Message) EC2InstanceIDs() []string {
+	return []string{}
+}
+
+func (Message) Kind() messages.Kind {
+	return messages.AMIInvalidationKind
+}
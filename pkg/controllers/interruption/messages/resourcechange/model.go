@@ -0,0 +1,56 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcechange
+
+import (
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+)
+
+// ResourceType is the type of an EC2 resource that Karpenter resolves from the selectors of a NodeClass
+type ResourceType string
+
+const (
+	Subnet        ResourceType = "subnet"
+	SecurityGroup ResourceType = "security-group"
+	Image         ResourceType = "image"
+)
+
+// Change is a change to an EC2 resource that Karpenter may have cached the resolution of
+type Change struct {
+	ResourceType ResourceType
+	ID           string
+	// Name is the name that a security group or image was created with, which is only set for the calls that create
+	// them, since resources that didn't exist before may match selectors by their name
+	Name string
+	// TagKeys are the keys of the tags that were created, changed or deleted on the resource
+	TagKeys []string
+}
+
+// Message is a change to EC2 resources that Karpenter may have cached the resolution of. It's parsed from
+// aws.tag@TagChangeOnResource v1 events, and from aws.ec2@AWSAPICallViaCloudTrail events for the EC2 API calls
+// that create, modify or delete resources.
+type Message struct {
+	messages.Metadata
+
+	Changes []Change `json:"-"`
+}
+
+func (Message) EC2InstanceIDs() []string {
+	return []string{}
+}
+
+func (Message) Kind() messages.Kind {
+	return messages.ResourceChangeKind
+}
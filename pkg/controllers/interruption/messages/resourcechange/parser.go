@@ -0,0 +1,206 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourcechange
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+)
+
+var (
+	// resourceTypesForAPICall are the resources that are created, modified or deleted by the EC2 API calls
+	resourceTypesForAPICall = map[string]ResourceType{
+		"CreateSubnet":            Subnet,
+		"DeleteSubnet":            Subnet,
+		"ModifySubnetAttribute":   Subnet,
+		"CreateSecurityGroup":     SecurityGroup,
+		"DeleteSecurityGroup":     SecurityGroup,
+		"CreateImage":             Image,
+		"CopyImage":               Image,
+		"RegisterImage":           Image,
+		"DeregisterImage":         Image,
+		"ModifyImageAttribute":    Image,
+		"EnableImage":             Image,
+		"DisableImage":            Image,
+		"EnableImageDeprecation":  Image,
+		"DisableImageDeprecation": Image,
+	}
+	// resourceTypesForIDPrefix are the resources that tags are created or deleted on, by the prefix of their ids
+	resourceTypesForIDPrefix = map[string]ResourceType{
+		"subnet-": Subnet,
+		"sg-":     SecurityGroup,
+		"ami-":    Image,
+	}
+)
+
+type tagChangeMessage struct {
+	messages.Metadata
+
+	Detail struct {
+		ChangedTagKeys []string `json:"changed-tag-keys"`
+		Service        string   `json:"service"`
+		ResourceType   string   `json:"resource-type"`
+	} `json:"detail"`
+}
+
+// TagChangeParser parses the events that EventBridge sends when the tags of a resource change
+type TagChangeParser struct{}
+
+func (p TagChangeParser) Parse(raw string) (messages.Message, error) {
+	msg := tagChangeMessage{}
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		return nil, fmt.Errorf("unmarhsalling the message as TagChangeOnResource, %w", err)
+	}
+	resourceType := ResourceType(msg.Detail.ResourceType)
+	// We ignore the resources that Karpenter doesn't select by their tags
+	if msg.Detail.Service != "ec2" || !lo.Contains([]ResourceType{Subnet, SecurityGroup, Image}, resourceType) {
+		return nil, nil
+	}
+	// The resources are the arns of the resources whose tags changed, which end with their ids
+	changes := lo.Map(msg.Resources, func(arn string, _ int) Change {
+		return Change{ResourceType: resourceType, ID: arn[strings.LastIndex(arn, "/")+1:], TagKeys: msg.Detail.ChangedTagKeys}
+	})
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return Message{Metadata: msg.Metadata, Changes: changes}, nil
+}
+
+func (p TagChangeParser) Version() string {
+	return "0"
+}
+
+func (p TagChangeParser) Source() string {
+	return "aws.tag"
+}
+
+func (p TagChangeParser) DetailType() string {
+	return "Tag Change on Resource"
+}
+
+type tag struct {
+	Key string `json:"key"`
+}
+
+type apiCallMessage struct {
+	messages.Metadata
+
+	Detail struct {
+		EventName         string `json:"eventName"`
+		ErrorCode         string `json:"errorCode"`
+		RequestParameters struct {
+			ResourcesSet struct {
+				Items []struct {
+					ResourceID string `json:"resourceId"`
+				} `json:"items"`
+			} `json:"resourcesSet"`
+			TagSet struct {
+				Items []tag `json:"items"`
+			} `json:"tagSet"`
+			TagSpecificationSet struct {
+				Items []struct {
+					Tags []tag `json:"tags"`
+				} `json:"items"`
+			} `json:"tagSpecificationSet"`
+			SubnetID  string `json:"subnetId"`
+			GroupID   string `json:"groupId"`
+			GroupName string `json:"groupName"`
+			ImageID   string `json:"imageId"`
+			Name      string `json:"name"`
+		} `json:"requestParameters"`
+		ResponseElements struct {
+			Subnet struct {
+				SubnetID string `json:"subnetId"`
+			} `json:"subnet"`
+			GroupID string `json:"groupId"`
+			ImageID string `json:"imageId"`
+		} `json:"responseElements"`
+	} `json:"detail"`
+}
+
+// APICallParser parses the events that EventBridge sends for EC2 API calls that CloudTrail records
+type APICallParser struct{}
+
+func (p APICallParser) Parse(raw string) (messages.Message, error) {
+	msg := apiCallMessage{}
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		return nil, fmt.Errorf("unmarhsalling the message as AWSAPICallViaCloudTrail, %w", err)
+	}
+	// Calls that failed didn't change any resources
+	if msg.Detail.ErrorCode != "" {
+		return nil, nil
+	}
+	var changes []Change
+	switch msg.Detail.EventName {
+	case "CreateTags", "DeleteTags":
+		tagKeys := lo.Map(msg.Detail.RequestParameters.TagSet.Items, func(t tag, _ int) string { return t.Key })
+		for _, item := range msg.Detail.RequestParameters.ResourcesSet.Items {
+			for prefix, resourceType := range resourceTypesForIDPrefix {
+				if strings.HasPrefix(item.ResourceID, prefix) {
+					changes = append(changes, Change{ResourceType: resourceType, ID: item.ResourceID, TagKeys: tagKeys})
+				}
+			}
+		}
+	default:
+		if resourceType, ok := resourceTypesForAPICall[msg.Detail.EventName]; ok {
+			changes = append(changes, msg.change(resourceType))
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return Message{Metadata: msg.Metadata, Changes: changes}, nil
+}
+
+func (p APICallParser) Version() string {
+	return "0"
+}
+
+func (p APICallParser) Source() string {
+	return "aws.ec2"
+}
+
+func (p APICallParser) DetailType() string {
+	return "AWS API Call via CloudTrail"
+}
+
+// change returns the change to the resource that the call created, modified or deleted. The ids of resources that are
+// created are only known from the response, and they may match selectors by the name and tags they're created with.
+func (m apiCallMessage) change(resourceType ResourceType) Change {
+	request, response := m.Detail.RequestParameters, m.Detail.ResponseElements
+	created := strings.HasPrefix(m.Detail.EventName, "Create") || m.Detail.EventName == "CopyImage" || m.Detail.EventName == "RegisterImage"
+	change := Change{ResourceType: resourceType}
+	switch resourceType {
+	case Subnet:
+		change.ID = lo.Ternary(created, response.Subnet.SubnetID, request.SubnetID)
+	case SecurityGroup:
+		change.ID = lo.Ternary(created, response.GroupID, request.GroupID)
+		change.Name = lo.Ternary(created, request.GroupName, "")
+	case Image:
+		change.ID = lo.Ternary(created, response.ImageID, request.ImageID)
+		change.Name = lo.Ternary(created, request.Name, "")
+	}
+	if created {
+		for _, item := range request.TagSpecificationSet.Items {
+			change.TagKeys = append(change.TagKeys, lo.Map(item.Tags, func(t tag, _ int) string { return t.Key })...)
+		}
+	}
+	return change
+}
@@ -39,7 +39,11 @@ const (
 	ScheduledChangeKind         Kind = "ScheduledChangeKind"
 	SpotInterruptionKind        Kind = "SpotInterruptionKind"
 	StateChangeKind             Kind = "StateChangeKind"
-	NoOpKind                    Kind = "NoOpKind"
+	ResourceChangeKind          Kind = "ResourceChangeKind"
+	AMIInvalidationKind         Kind = "AMIInvalidationKind"
+	// UnavailableOfferingsFlushKind makes offerings that are marked as unavailable available again
+	UnavailableOfferingsFlushKind Kind = "UnavailableOfferingsFlushKind"
+	NoOpKind                      Kind = "NoOpKind"
 )
 
 type Metadata struct {
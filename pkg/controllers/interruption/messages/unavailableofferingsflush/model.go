@@ -0,0 +1,42 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package unavailableofferingsflush
+
+import (
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+)
+
+// Message asks for the offerings that are marked as unavailable after insufficient capacity errors to be made available
+// again, e.g. by an operator who knows that capacity was added. Only the offerings that match the instance type, zone
+// and capacity type that are set are made available, so messages that set none flush every offering.
+type Message struct {
+	messages.Metadata
+
+	Detail Detail `json:"detail"`
+}
+
+type Detail struct {
+	InstanceType string `json:"instanceType,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	CapacityType string `json:"capacityType,omitempty"`
+}
+
+func (Message) EC2InstanceIDs() []string {
+	return []string{}
+}
+
+func (Message) Kind() messages.Kind {
+	return messages.UnavailableOfferingsFlushKind
+}
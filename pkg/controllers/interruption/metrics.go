@@ -64,8 +64,37 @@ var (
 		},
 		[]string{actionTypeLabel},
 	)
+	nodePoolInterruptions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "nodepool_received_messages",
+			Help:      "Count of interruption messages received for nodes owned by a nodepool. Labeled by nodepool and message type.",
+		},
+		[]string{metrics.NodePoolLabel, messageTypeLabel},
+	)
+	nodePoolDrainedNodes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "nodepool_drained_nodes",
+			Help:      "Count of nodes owned by a nodepool that were cordoned and drained due to an interruption message. Labeled by nodepool and message type.",
+		},
+		[]string{metrics.NodePoolLabel, messageTypeLabel},
+	)
+	nodePoolReplacementDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "nodepool_replacement_duration_seconds",
+			Help:      "Length of time between a node being drained due to an interruption message and a replacement node in the same nodepool being initialized. Labeled by nodepool.",
+			Buckets:   metrics.DurationBuckets(),
+		},
+		[]string{metrics.NodePoolLabel},
+	)
 )
 
 func init() {
-	crmetrics.Registry.MustRegister(receivedMessages, deletedMessages, messageLatency, actionsPerformed)
+	crmetrics.Registry.MustRegister(receivedMessages, deletedMessages, messageLatency, actionsPerformed,
+		nodePoolInterruptions, nodePoolDrainedNodes, nodePoolReplacementDuration)
 }
@@ -0,0 +1,65 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package interruption
+
+import (
+	"context"
+
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+)
+
+// Feature is an interruption handling feature of aws-node-termination-handler, along with whether Karpenter provides
+// it with the current settings
+type Feature struct {
+	Name        string
+	Active      bool
+	Description string
+}
+
+// Features returns the features of aws-node-termination-handler's queue processor and whether Karpenter provides them,
+// so that clusters that replace aws-node-termination-handler with the interruption controller can verify that nothing
+// they relied on is lost
+func Features(ctx context.Context) []Feature {
+	queue := settings.FromContext(ctx).InterruptionQueueName != ""
+	parity := settings.FromContext(ctx).NodeTerminationHandlerParity
+	rebalance := "rebalance recommendations only publish an event for the node"
+	if parity {
+		rebalance = "rebalance recommendations cordon and drain the node"
+	}
+	return []Feature{
+		{Name: "spot-interruption", Active: queue,
+			Description: "spot interruption warnings cordon and drain the node, unless EC2 stops or hibernates the instance"},
+		{Name: "rebalance-recommendation", Active: queue, Description: rebalance},
+		{Name: "scheduled-change", Active: queue,
+			Description: "scheduled changes from AWS Health cordon and drain the node, deferred until the maintenance window of its node class if it has one"},
+		{Name: "state-change", Active: queue,
+			Description: "instances that are stopping, stopped, shutting down or terminated cordon and drain the node, unless EC2 stopped a spot instance that resumes after its interruption"},
+		{Name: "webhook", Active: settings.FromContext(ctx).LifecycleWebhookURL != "" || settings.FromContext(ctx).LifecycleEventBusName != "",
+			Description: "node lifecycle events, including drains, are sent to the lifecycle webhook or event bus"},
+		{Name: "imds", Active: false,
+			Description: "interruptions are only received from the interruption queue, not from the instance metadata service of each node"},
+		{Name: "asg-lifecycle", Active: false,
+			Description: "auto scaling group lifecycle hooks aren't handled as Karpenter doesn't launch nodes in auto scaling groups"},
+	}
+}
+
+// ReportFeatures logs which of aws-node-termination-handler's features Karpenter provides with the current settings
+func ReportFeatures(ctx context.Context) {
+	for _, feature := range Features(ctx) {
+		logging.FromContext(ctx).With("feature", feature.Name, "active", feature.Active).Infof("aws-node-termination-handler parity, %s", feature.Description)
+	}
+}
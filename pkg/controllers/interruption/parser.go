@@ -21,11 +21,14 @@ import (
 	"github.com/samber/lo"
 
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/amiinvalidation"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/noop"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/rebalancerecommendation"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/resourcechange"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/scheduledchange"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/spotinterruption"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/statechange"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/unavailableofferingsflush"
 )
 
 type parserKey struct {
@@ -56,6 +59,10 @@ var (
 		spotinterruption.Parser{},
 		scheduledchange.Parser{},
 		rebalancerecommendation.Parser{},
+		resourcechange.TagChangeParser{},
+		resourcechange.APICallParser{},
+		amiinvalidation.Parser{},
+		unavailableofferingsflush.Parser{},
 	}
 )
 
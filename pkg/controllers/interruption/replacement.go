@@ -0,0 +1,100 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package interruption
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+)
+
+// replacementTimeout is how long we wait for a replacement after an interrupted node is drained. Nodepools
+// that don't need a replacement (e.g. because their workloads scaled down) shouldn't be tracked forever
+const replacementTimeout = time.Hour
+
+// replacementTracker measures the time between an interrupted node being drained and a replacement node
+// in the same nodepool being initialized
+type replacementTracker struct {
+	mu      sync.Mutex
+	clk     clock.Clock
+	pending map[string][]time.Time // key: nodepool name, value: times that interrupted nodes were drained
+	matched *cache.Cache           // key: nodeclaim UID, ensures that each replacement is only observed once
+}
+
+func newReplacementTracker(clk clock.Clock) *replacementTracker {
+	return &replacementTracker{
+		clk:     clk,
+		pending: map[string][]time.Time{},
+		matched: cache.New(replacementTimeout, time.Minute),
+	}
+}
+
+// Track records that an interrupted NodeClaim was drained and needs a replacement
+func (r *replacementTracker) Track(nodeClaim *v1beta1.NodeClaim) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nodePool := nodeclaimutil.OwnerKey(nodeClaim).Name
+	r.pending[nodePool] = append(r.pending[nodePool], r.clk.Now())
+}
+
+// Observe matches initialized NodeClaims that were created after an interrupted node was drained against the
+// pending replacements for their nodepool, oldest first, and records the replacement duration
+func (r *replacementTracker) Observe(ctx context.Context, kubeClient client.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for nodePool, times := range r.pending {
+		r.pending[nodePool] = lo.Filter(times, func(t time.Time, _ int) bool { return r.clk.Since(t) < replacementTimeout })
+		if len(r.pending[nodePool]) == 0 {
+			delete(r.pending, nodePool)
+		}
+	}
+	if len(r.pending) == 0 {
+		return nil
+	}
+	nodeClaimList, err := nodeclaimutil.List(ctx, kubeClient)
+	if err != nil {
+		return err
+	}
+	candidates := lo.Filter(nodeClaimList.Items, func(n v1beta1.NodeClaim, _ int) bool {
+		_, matched := r.matched.Get(string(n.UID))
+		return !matched && n.StatusConditions().GetCondition(v1beta1.NodeInitialized).IsTrue()
+	})
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].CreationTimestamp.Before(&candidates[j].CreationTimestamp)
+	})
+	for i := range candidates {
+		nodePool := nodeclaimutil.OwnerKey(&candidates[i]).Name
+		times := r.pending[nodePool]
+		if len(times) == 0 || candidates[i].CreationTimestamp.Time.Before(times[0]) {
+			continue
+		}
+		initialized := candidates[i].StatusConditions().GetCondition(v1beta1.NodeInitialized).LastTransitionTime.Inner.Time
+		nodePoolReplacementDuration.WithLabelValues(nodePool).Observe(initialized.Sub(times[0]).Seconds())
+		r.matched.SetDefault(string(candidates[i].UID), nil)
+		r.pending[nodePool] = times[1:]
+	}
+	return nil
+}
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	syncatomic "sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -31,19 +32,29 @@ import (
 )
 
 type SQSProvider struct {
-	client sqsiface.SQSAPI
+	client          sqsiface.SQSAPI
+	queueNameSource func(context.Context) string
 
 	queueURL  atomic.Lazy[string]
 	queueName syncatomic.Pointer[string]
 }
 
 func NewSQSProvider(client sqsiface.SQSAPI) *SQSProvider {
+	return NewSQSProviderForQueue(client, func(ctx context.Context) string {
+		return settings.FromContext(ctx).InterruptionQueueName
+	})
+}
+
+// NewSQSProviderForQueue returns a provider for the queue named by queueNameSource, which is called with the context
+// of every request so that the queue can be changed through the settings
+func NewSQSProviderForQueue(client sqsiface.SQSAPI, queueNameSource func(context.Context) string) *SQSProvider {
 	provider := &SQSProvider{
-		client: client,
+		client:          client,
+		queueNameSource: queueNameSource,
 	}
 	provider.queueURL.Resolve = func(ctx context.Context) (string, error) {
 		input := &sqs.GetQueueUrlInput{
-			QueueName: aws.String(settings.FromContext(ctx).InterruptionQueueName),
+			QueueName: aws.String(provider.queueNameSource(ctx)),
 		}
 		ret, err := provider.client.GetQueueUrlWithContext(ctx, input)
 		if err != nil {
@@ -66,12 +77,12 @@ func (s *SQSProvider) QueueExists(ctx context.Context) (bool, error) {
 }
 
 func (s *SQSProvider) DiscoverQueueURL(ctx context.Context) (string, error) {
-	if settings.FromContext(ctx).InterruptionQueueName != lo.FromPtr(s.queueName.Load()) {
+	if s.queueNameSource(ctx) != lo.FromPtr(s.queueName.Load()) {
 		res, err := s.queueURL.TryGet(ctx, atomic.IgnoreCacheOption)
 		if err != nil {
 			return res, err
 		}
-		s.queueName.Store(lo.ToPtr(settings.FromContext(ctx).InterruptionQueueName))
+		s.queueName.Store(lo.ToPtr(s.queueNameSource(ctx)))
 		return res, nil
 	}
 	return s.queueURL.TryGet(ctx)
@@ -142,6 +153,26 @@ func (s *SQSProvider) DeleteSQSMessage(ctx context.Context, msg *sqs.Message) er
 	return nil
 }
 
+// ChangeMessageVisibility hides the passed SQS message from receivers for the timeout, after which it's received again
+func (s *SQSProvider) ChangeMessageVisibility(ctx context.Context, msg *sqs.Message, timeout time.Duration) error {
+	queueURL, err := s.DiscoverQueueURL(ctx)
+	if err != nil {
+		return fmt.Errorf("failed fetching queue url, %w", err)
+	}
+
+	input := &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(queueURL),
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: aws.Int64(int64(timeout.Seconds())),
+	}
+
+	_, err = s.client.ChangeMessageVisibilityWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("changing visibility of sqs message, %w", err)
+	}
+	return nil
+}
+
 func (s *SQSProvider) Reset() {
 	s.queueURL.Set("")
 	s.queueName.Store(nil)
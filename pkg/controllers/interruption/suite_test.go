@@ -23,6 +23,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -43,18 +44,22 @@ import (
 	"github.com/aws/karpenter-core/pkg/operator/scheme"
 	coretest "github.com/aws/karpenter-core/pkg/test"
 	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/controllers/interruption"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/rebalancerecommendation"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/scheduledchange"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/spotinterruption"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/statechange"
 	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/providers/amifamily"
 	"github.com/aws/karpenter/pkg/test"
 	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 )
 
 const (
@@ -62,6 +67,7 @@ const (
 	defaultRegion    = "us-west-2"
 	ec2Source        = "aws.ec2"
 	healthSource     = "aws.health"
+	tagSource        = "aws.tag"
 )
 
 var ctx context.Context
@@ -69,8 +75,10 @@ var env *coretest.Environment
 var sqsapi *fake.SQSAPI
 var sqsProvider *interruption.SQSProvider
 var unavailableOfferingsCache *awscache.UnavailableOfferings
+var spotInterruptionsCache *awscache.SpotInterruptions
 var fakeClock *clock.FakeClock
 var controller *interruption.Controller
+var awsEnv *test.Environment
 
 func TestAPIs(t *testing.T) {
 	ctx = TestContextWithLogger(t)
@@ -82,9 +90,12 @@ var _ = BeforeSuite(func() {
 	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
 	fakeClock = &clock.FakeClock{}
 	unavailableOfferingsCache = awscache.NewUnavailableOfferings()
+	spotInterruptionsCache = awscache.NewSpotInterruptions()
 	sqsapi = &fake.SQSAPI{}
 	sqsProvider = interruption.NewSQSProvider(sqsapi)
-	controller = interruption.NewController(env.Client, fakeClock, events.NewRecorder(&record.FakeRecorder{}), sqsProvider, unavailableOfferingsCache)
+	awsEnv = test.NewEnvironment(ctx, env)
+	controller = interruption.NewController(env.Client, fakeClock, events.NewRecorder(&record.FakeRecorder{}), sqsProvider, unavailableOfferingsCache, spotInterruptionsCache,
+		awsEnv.SubnetProvider, awsEnv.SecurityGroupProvider, awsEnv.AMIProvider)
 })
 
 var _ = AfterSuite(func() {
@@ -93,14 +104,17 @@ var _ = AfterSuite(func() {
 
 var _ = BeforeEach(func() {
 	sqsProvider = interruption.NewSQSProvider(sqsapi)
-	controller = interruption.NewController(env.Client, fakeClock, events.NewRecorder(&record.FakeRecorder{}), sqsProvider, unavailableOfferingsCache)
+	controller = interruption.NewController(env.Client, fakeClock, events.NewRecorder(&record.FakeRecorder{}), sqsProvider, unavailableOfferingsCache, spotInterruptionsCache,
+		awsEnv.SubnetProvider, awsEnv.SecurityGroupProvider, awsEnv.AMIProvider)
 	ctx = coresettings.ToContext(ctx, coretest.Settings())
 	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
 		InterruptionQueueName: lo.ToPtr("test-cluster"),
 	}))
 	unavailableOfferingsCache.Flush()
+	spotInterruptionsCache.Flush()
 	sqsapi.Reset()
 	sqsProvider.Reset()
+	awsEnv.Reset()
 })
 
 var _ = AfterEach(func() {
@@ -269,6 +283,477 @@ var _ = Describe("AWSInterruption", func() {
 			// Expect a t3.large in coretest-zone-1a to be added to the ICE cache
 			Expect(unavailableOfferingsCache.IsUnavailable("t3.large", "coretest-zone-1a", v1alpha1.CapacityTypeSpot)).To(BeTrue())
 		})
+		It("should count the spot interruption towards the provisioner's recent interruptions", func() {
+			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+						v1.LabelTopologyZone:             "coretest-zone-1a",
+						v1.LabelInstanceTypeStable:       "t3.large",
+						v1alpha5.LabelCapacityType:       v1alpha1.CapacityTypeSpot,
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+			ExpectMessagesCreated(spotInterruptionMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, machine, node)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(spotInterruptionsCache.Count(nodepoolutil.Key{Name: "default", IsProvisioner: true})).To(Equal(1))
+			Expect(spotInterruptionsCache.Count(nodepoolutil.Key{Name: "default"})).To(BeZero())
+		})
+		It("should not delete the machine when the spot instance is stopped on interruption", func() {
+			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+						v1.LabelTopologyZone:             "coretest-zone-1a",
+						v1.LabelInstanceTypeStable:       "t3.large",
+						v1alpha5.LabelCapacityType:       v1alpha1.CapacityTypeSpot,
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+			msg := spotInterruptionMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID)))
+			msg.Detail.InstanceAction = "stop"
+			ExpectMessagesCreated(msg)
+			ExpectApplied(ctx, env.Client, machine, node)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.ReceiveMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			ExpectExists(ctx, env.Client, machine)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			Expect(unavailableOfferingsCache.IsUnavailable("t3.large", "coretest-zone-1a", v1alpha1.CapacityTypeSpot)).To(BeTrue())
+		})
+		It("should only delete the machine on a stop when the spot instance isn't set to resume", func() {
+			var machines []*v1alpha5.Machine
+			var nodes []*v1.Node
+			var messages []interface{}
+			for _, behavior := range []string{"stop", "hibernate", "terminate"} {
+				instanceID := fake.InstanceID()
+				machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							v1alpha5.ProvisionerNameLabelKey: "default",
+							v1alpha5.LabelCapacityType:       v1alpha1.CapacityTypeSpot,
+						},
+						Annotations: map[string]string{
+							v1alpha1.AnnotationSpotInterruptionBehavior: behavior,
+						},
+					},
+					Status: v1alpha5.MachineStatus{
+						ProviderID: fake.ProviderID(instanceID),
+					},
+				})
+				machines = append(machines, machine)
+				nodes = append(nodes, node)
+				messages = append(messages, stateChangeMessage(instanceID, "stopped"))
+			}
+			ExpectMessagesCreated(messages...)
+			ExpectApplied(ctx, env.Client, lo.Map(machines, func(m *v1alpha5.Machine, _ int) client.Object { return m })...)
+			ExpectApplied(ctx, env.Client, lo.Map(nodes, func(n *v1.Node, _ int) client.Object { return n })...)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.ReceiveMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			ExpectExists(ctx, env.Client, machines[0])
+			ExpectExists(ctx, env.Client, machines[1])
+			ExpectNotFound(ctx, env.Client, machines[2])
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(3))
+		})
+		It("should delete the machine when a spot instance set to resume is terminated", func() {
+			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+						v1alpha5.LabelCapacityType:       v1alpha1.CapacityTypeSpot,
+					},
+					Annotations: map[string]string{
+						v1alpha1.AnnotationSpotInterruptionBehavior: "stop",
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+			ExpectMessagesCreated(stateChangeMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID)), "terminated"))
+			ExpectApplied(ctx, env.Client, machine, node)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.ReceiveMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			ExpectNotFound(ctx, env.Client, machine)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+	})
+	Context("Node Termination Handler Parity", func() {
+		var machine *v1alpha5.Machine
+		var node *v1.Node
+		BeforeEach(func() {
+			machine, node = coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+		})
+		It("should only notify on rebalance recommendations by default", func() {
+			ExpectMessagesCreated(rebalanceRecommendationMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, machine, node)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectExists(ctx, env.Client, machine)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should delete the machine on rebalance recommendations", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				InterruptionQueueName:        lo.ToPtr("test-cluster"),
+				NodeTerminationHandlerParity: lo.ToPtr(true),
+			}))
+			ExpectMessagesCreated(rebalanceRecommendationMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, machine, node)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, machine)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should report the features that are active", func() {
+			features := lo.SliceToMap(interruption.Features(ctx), func(f interruption.Feature) (string, bool) { return f.Name, f.Active })
+			Expect(features).To(HaveKeyWithValue("spot-interruption", true))
+			Expect(features).To(HaveKeyWithValue("rebalance-recommendation", true))
+			Expect(features).To(HaveKeyWithValue("scheduled-change", true))
+			Expect(features).To(HaveKeyWithValue("state-change", true))
+			Expect(features).To(HaveKeyWithValue("webhook", false))
+			Expect(features).To(HaveKeyWithValue("asg-lifecycle", false))
+		})
+		It("should report the queue features as inactive without an interruption queue", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				NodeTerminationHandlerParity: lo.ToPtr(true),
+				LifecycleWebhookURL:          lo.ToPtr("https://cmdb.example.com/karpenter"),
+			}))
+			features := lo.SliceToMap(interruption.Features(ctx), func(f interruption.Feature) (string, bool) { return f.Name, f.Active })
+			Expect(features).To(HaveKeyWithValue("spot-interruption", false))
+			Expect(features).To(HaveKeyWithValue("rebalance-recommendation", false))
+			Expect(features).To(HaveKeyWithValue("scheduled-change", false))
+			Expect(features).To(HaveKeyWithValue("state-change", false))
+			Expect(features).To(HaveKeyWithValue("webhook", true))
+		})
+	})
+	Context("Maintenance Windows", func() {
+		var nodeTemplate *v1alpha1.AWSNodeTemplate
+		var machine *v1alpha5.Machine
+		var node *v1.Node
+		BeforeEach(func() {
+			// September 6th, 2023 is a Wednesday
+			fakeClock.SetTime(time.Date(2023, time.September, 6, 11, 0, 0, 0, time.UTC))
+			nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				MaintenanceWindows: []v1alpha1.MaintenanceWindow{
+					{Days: []string{"Saturday"}, Start: "02:00", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+				},
+			})
+			machine, node = coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+						v1alpha5.LabelCapacityType:       v1alpha1.CapacityTypeSpot,
+					},
+				},
+				Spec: v1alpha5.MachineSpec{
+					MachineTemplateRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+		})
+		It("should defer a scheduled change outside of a maintenance window", func() {
+			ExpectMessagesCreated(scheduledChangeMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, nodeTemplate, machine, node)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			machine = ExpectExists(ctx, env.Client, machine)
+			Expect(machine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationMaintenanceDeferred, ""))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should delete a deferred machine once the maintenance window opens", func() {
+			ExpectMessagesCreated(scheduledChangeMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, nodeTemplate, machine, node)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectExists(ctx, env.Client, machine)
+
+			fakeClock.SetTime(time.Date(2023, time.September, 9, 3, 0, 0, 0, time.UTC))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, machine)
+		})
+		It("should delete a deferred machine before the scheduled change starts", func() {
+			msg := scheduledChangeMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID)))
+			msg.Detail.StartTime = "Wed, 06 Sep 2023 13:00:00 GMT"
+			ExpectMessagesCreated(msg)
+			ExpectApplied(ctx, env.Client, nodeTemplate, machine, node)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			machine = ExpectExists(ctx, env.Client, machine)
+			Expect(machine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationMaintenanceDeferred, "2023-09-06T13:00:00Z"))
+
+			fakeClock.SetTime(time.Date(2023, time.September, 6, 12, 0, 0, 0, time.UTC))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, machine)
+		})
+		It("should delete the machine for a scheduled change during a maintenance window", func() {
+			fakeClock.SetTime(time.Date(2023, time.September, 9, 3, 0, 0, 0, time.UTC))
+			ExpectMessagesCreated(scheduledChangeMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, nodeTemplate, machine, node)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, machine)
+		})
+		It("should delete the machine for a spot interruption outside of a maintenance window", func() {
+			ExpectMessagesCreated(spotInterruptionMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, nodeTemplate, machine, node)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, machine)
+		})
+	})
+	Context("Resource Changes", func() {
+		BeforeEach(func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{{
+				Name:         aws.String("test-ami"),
+				ImageId:      aws.String("ami-123"),
+				CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+				Architecture: aws.String("x86_64"),
+			}}})
+			nodeClass := nodeclassutil.New(test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				AWS: v1alpha1.AWS{
+					SubnetSelector:        map[string]string{"foo": "bar"},
+					SecurityGroupSelector: map[string]string{"foo": "bar"},
+				},
+				AMISelector: map[string]string{"aws::name": "test-ami"},
+			}))
+			Expect(awsEnv.SubnetProvider.List(ctx, nodeClass)).To(HaveLen(3))
+			Expect(awsEnv.SecurityGroupProvider.List(ctx, nodeClass)).To(HaveLen(3))
+			Expect(awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})).To(HaveLen(1))
+		})
+		It("should invalidate the cached subnets that a subnet whose tags changed was resolved with", func() {
+			ExpectMessagesCreated(tagChangeMessage("subnet", "subnet-test1", "Name"))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(BeZero())
+			Expect(awsEnv.SecurityGroupCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(1))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should invalidate the cached subnets whose selectors a subnet may have started matching", func() {
+			ExpectMessagesCreated(tagChangeMessage("subnet", "subnet-other", "foo"))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(BeZero())
+		})
+		It("should not invalidate the cached subnets when the tags of an unrelated subnet change", func() {
+			ExpectMessagesCreated(tagChangeMessage("subnet", "subnet-other", "Name"))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(Equal(1))
+		})
+		It("should invalidate the cached security groups that a security group whose tags changed was resolved with", func() {
+			ExpectMessagesCreated(tagChangeMessage("security-group", "sg-test2", "Name"))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.SecurityGroupCache.ItemCount()).To(BeZero())
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(1))
+		})
+		It("should invalidate the cached amis that an image whose tags changed was resolved with", func() {
+			ExpectMessagesCreated(tagChangeMessage("image", "ami-123", "Name"))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.SecurityGroupCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.EC2Cache.ItemCount()).To(BeZero())
+		})
+		It("should not invalidate any cache when the tags of other resources change", func() {
+			ExpectMessagesCreated(tagChangeMessage("instance", "i-test1", "foo"))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.SecurityGroupCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(1))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should invalidate the cached resources that api calls change", func() {
+			ExpectMessagesCreated(
+				apiCallMessage("DeleteSubnet", "", map[string]interface{}{"subnetId": "subnet-test3"}, nil),
+				apiCallMessage("DeregisterImage", "", map[string]interface{}{"imageId": "ami-123"}, nil),
+			)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(BeZero())
+			Expect(awsEnv.SecurityGroupCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.EC2Cache.ItemCount()).To(BeZero())
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(2))
+		})
+		It("should not invalidate the cached resources when api calls change unrelated resources", func() {
+			ExpectMessagesCreated(
+				apiCallMessage("DeleteSubnet", "", map[string]interface{}{"subnetId": "subnet-other"}, nil),
+				apiCallMessage("DeregisterImage", "", map[string]interface{}{"imageId": "ami-other"}, nil),
+			)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(1))
+		})
+		It("should invalidate the cached amis whose selectors a created image matches by name", func() {
+			ExpectMessagesCreated(apiCallMessage("CreateImage", "", map[string]interface{}{"name": "test-ami"}, map[string]interface{}{"imageId": "ami-456"}))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.EC2Cache.ItemCount()).To(BeZero())
+		})
+		It("should invalidate the cached security groups whose selectors a created security group is tagged for", func() {
+			ExpectMessagesCreated(apiCallMessage("CreateSecurityGroup", "", map[string]interface{}{
+				"groupName": "other",
+				"tagSpecificationSet": map[string]interface{}{
+					"items": []map[string]interface{}{{"resourceType": "security-group", "tags": []map[string]string{{"key": "foo", "value": "bar"}}}},
+				},
+			}, map[string]interface{}{"groupId": "sg-other"}))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.SecurityGroupCache.ItemCount()).To(BeZero())
+		})
+		It("should invalidate the cached resources whose tags api calls change", func() {
+			ExpectMessagesCreated(apiCallMessage("CreateTags", "", map[string]interface{}{
+				"resourcesSet": map[string]interface{}{"items": []map[string]string{{"resourceId": "sg-test1"}, {"resourceId": "i-test1"}}},
+				"tagSet":       map[string]interface{}{"items": []map[string]string{{"key": "Name", "value": "test"}}},
+			}, nil))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.SecurityGroupCache.ItemCount()).To(BeZero())
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(1))
+		})
+		It("should not invalidate any cache when api calls fail", func() {
+			ExpectMessagesCreated(
+				apiCallMessage("DeleteSecurityGroup", "DependencyViolation", map[string]interface{}{"groupId": "sg-test1"}, nil),
+				apiCallMessage("RunInstances", "", nil, nil),
+			)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.SubnetCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.SecurityGroupCache.ItemCount()).To(Equal(1))
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(1))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(2))
+		})
+	})
+	Context("AMI Invalidations", func() {
+		var nodeTemplate *v1alpha1.AWSNodeTemplate
+		BeforeEach(func() {
+			nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AMISelector: map[string]string{"aws-ids": "ami-123"}})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeclassutil.New(nodeTemplate), &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.EC2Cache.SetDefault("amis", struct{}{})
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(2))
+		})
+		It("should invalidate the cached amis of the node template", func() {
+			ExpectMessagesCreated(amiInvalidationMessage(map[string]interface{}{"nodeTemplate": nodeTemplate.Name}))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(1))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should invalidate the cached amis of every node class when the message names none", func() {
+			ExpectMessagesCreated(amiInvalidationMessage(map[string]interface{}{}))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.EC2Cache.ItemCount()).To(BeZero())
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should drop messages for node classes that don't exist", func() {
+			ExpectMessagesCreated(amiInvalidationMessage(map[string]interface{}{"nodeClass": "missing"}))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(2))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should drop messages that name both a node template and a node class", func() {
+			ExpectMessagesCreated(amiInvalidationMessage(map[string]interface{}{"nodeTemplate": nodeTemplate.Name, "nodeClass": nodeTemplate.Name}))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(2))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+	})
+	Context("Unavailable Offerings Flushes", func() {
+		BeforeEach(func() {
+			unavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)
+			unavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1b", v1alpha5.CapacityTypeSpot)
+			unavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.xlarge", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)
+		})
+		It("should make the offerings that the message matches available", func() {
+			ExpectMessagesCreated(unavailableOfferingsFlushMessage(map[string]interface{}{"zone": "test-zone-1a"}))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(unavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)).To(BeFalse())
+			Expect(unavailableOfferingsCache.IsUnavailable("m5.xlarge", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)).To(BeFalse())
+			Expect(unavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1b", v1alpha5.CapacityTypeSpot)).To(BeTrue())
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should make every offering available when the message matches all of them", func() {
+			ExpectMessagesCreated(unavailableOfferingsFlushMessage(map[string]interface{}{}))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(unavailableOfferingsCache.List()).To(BeEmpty())
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+	})
+	Context("Metrics", func() {
+		var provisionerName string
+		BeforeEach(func() {
+			provisionerName = coretest.RandomName()
+			fakeClock.SetTime(time.Now().Add(-time.Minute))
+		})
+		It("should count interruption messages and drained nodes by nodepool", func() {
+			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: provisionerName,
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+			ExpectMessagesCreated(spotInterruptionMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, machine, node)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+			labels := map[string]string{"nodepool": provisionerName, "message_type": string(messages.SpotInterruptionKind)}
+			received, ok := FindMetricWithLabelValues("karpenter_interruption_nodepool_received_messages", labels)
+			Expect(ok).To(BeTrue())
+			Expect(received.GetCounter().GetValue()).To(BeNumerically("==", 1))
+			drained, ok := FindMetricWithLabelValues("karpenter_interruption_nodepool_drained_nodes", labels)
+			Expect(ok).To(BeTrue())
+			Expect(drained.GetCounter().GetValue()).To(BeNumerically("==", 1))
+		})
+		It("should record the time until a replacement node is initialized in the same nodepool", func() {
+			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: provisionerName,
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+			ExpectMessagesCreated(spotInterruptionMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, machine, node)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, machine)
+
+			replacement := coretest.Machine(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: provisionerName,
+					},
+				},
+			})
+			replacement.StatusConditions().MarkTrue(v1alpha5.MachineInitialized)
+			ExpectApplied(ctx, env.Client, replacement)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+			metric, ok := FindMetricWithLabelValues("karpenter_interruption_nodepool_replacement_duration_seconds", map[string]string{"nodepool": provisionerName})
+			Expect(ok).To(BeTrue())
+			Expect(metric.GetHistogram().GetSampleCount()).To(BeNumerically("==", 1))
+		})
 	})
 	Context("Error Handling", func() {
 		It("should send an error on polling when QueueNotExists", func() {
@@ -325,6 +810,26 @@ func spotInterruptionMessage(involvedInstanceID string) spotinterruption.Message
 	}
 }
 
+func rebalanceRecommendationMessage(involvedInstanceID string) rebalancerecommendation.Message {
+	return rebalancerecommendation.Message{
+		Metadata: messages.Metadata{
+			Version:    "0",
+			Account:    defaultAccountID,
+			DetailType: "EC2 Instance Rebalance Recommendation",
+			ID:         string(uuid.NewUUID()),
+			Region:     defaultRegion,
+			Resources: []string{
+				fmt.Sprintf("arn:aws:ec2:%s:instance/%s", defaultRegion, involvedInstanceID),
+			},
+			Source: ec2Source,
+			Time:   time.Now(),
+		},
+		Detail: rebalancerecommendation.Detail{
+			InstanceID: involvedInstanceID,
+		},
+	}
+}
+
 func stateChangeMessage(involvedInstanceID, state string) statechange.Message {
 	return statechange.Message{
 		Metadata: messages.Metadata{
@@ -371,3 +876,70 @@ func scheduledChangeMessage(involvedInstanceID string) scheduledchange.Message {
 		},
 	}
 }
+
+func tagChangeMessage(resourceType, id string, changedTagKeys ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"version":     "0",
+		"account":     defaultAccountID,
+		"detail-type": "Tag Change on Resource",
+		"id":          string(uuid.NewUUID()),
+		"region":      defaultRegion,
+		"resources":   []string{fmt.Sprintf("arn:aws:ec2:%s:%s:%s/%s", defaultRegion, defaultAccountID, resourceType, id)},
+		"source":      tagSource,
+		"time":        time.Now(),
+		"detail": map[string]interface{}{
+			"changed-tag-keys": changedTagKeys,
+			"service":          "ec2",
+			"resource-type":    resourceType,
+		},
+	}
+}
+
+func apiCallMessage(eventName, errorCode string, requestParameters, responseElements map[string]interface{}) map[string]interface{} {
+	detail := map[string]interface{}{
+		"eventSource":       "ec2.amazonaws.com",
+		"eventName":         eventName,
+		"requestParameters": requestParameters,
+		"responseElements":  responseElements,
+	}
+	if errorCode != "" {
+		detail["errorCode"] = errorCode
+	}
+	return map[string]interface{}{
+		"version":     "0",
+		"account":     defaultAccountID,
+		"detail-type": "AWS API Call via CloudTrail",
+		"id":          string(uuid.NewUUID()),
+		"region":      defaultRegion,
+		"resources":   []string{},
+		"source":      ec2Source,
+		"time":        time.Now(),
+		"detail":      detail,
+	}
+}
+
+func unavailableOfferingsFlushMessage(detail map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"version":     "0",
+		"account":     defaultAccountID,
+		"detail-type": "Unavailable Offerings Flush",
+		"id":          string(uuid.NewUUID()),
+		"region":      defaultRegion,
+		"source":      "karpenter.k8s.aws",
+		"time":        time.Now(),
+		"detail":      detail,
+	}
+}
+
+func amiInvalidationMessage(detail map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"version":     "0",
+		"account":     defaultAccountID,
+		"detail-type": "AMI Cache Invalidation",
+		"id":          string(uuid.NewUUID()),
+		"region":      defaultRegion,
+		"source":      "karpenter.k8s.aws",
+		"time":        time.Now(),
+		"detail":      detail,
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package amiage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/metrics"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// Controller reports the age of the AMI that each Machine was launched with, and how long it has been since a
+// newer AMI was discovered for the Machine's node template while the Machine still runs an older one
+type Controller struct {
+	kubeClient       client.Client
+	clk              clock.Clock
+	instanceProvider *instance.Provider
+	amiProvider      *amifamily.Provider
+	discovered       map[string]time.Time // key: AMI ID, value: when the AMI was first resolved by any node template
+}
+
+func NewController(kubeClient client.Client, clk clock.Clock, instanceProvider *instance.Provider, amiProvider *amifamily.Provider) *Controller {
+	return &Controller{
+		kubeClient:       kubeClient,
+		clk:              clk,
+		instanceProvider: instanceProvider,
+		amiProvider:      amiProvider,
+		discovered:       map[string]time.Time{},
+	}
+}
+
+func (c *Controller) Name() string {
+	return "machine.amiage"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	machineList := &v1alpha5.MachineList{}
+	if err := c.kubeClient.List(ctx, machineList); err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing machines, %w", err)
+	}
+	instances, err := c.instanceProvider.List(ctx)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing instances, %w", err)
+	}
+	imageIDs := lo.SliceToMap(instances, func(i *instance.Instance) (string, string) { return i.ID, i.ImageID })
+	creationDates, err := c.amiProvider.CreationDates(ctx, lo.Uniq(lo.Values(imageIDs)))
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("getting ami creation dates, %w", err)
+	}
+	resolved := c.resolveAMIs(ctx, machineList.Items)
+
+	AMIAge.Reset()
+	AMIDriftLag.Reset()
+	for i := range machineList.Items {
+		machine := &machineList.Items[i]
+		id, err := utils.ParseInstanceID(machine.Status.ProviderID)
+		if err != nil {
+			continue
+		}
+		imageID, ok := imageIDs[id]
+		if !ok {
+			continue
+		}
+		// nodes are labeled by provisioner and image, rather than by node, so that the number of series doesn't grow
+		// with the number of nodes
+		labels := prometheus.Labels{
+			metrics.ProvisionerLabel: machine.Labels[v1alpha5.ProvisionerNameLabelKey],
+			imageIDLabel:             imageID,
+		}
+		if creationDate, ok := creationDates[imageID]; ok {
+			AMIAge.With(labels).Set(c.clk.Since(creationDate).Seconds())
+		}
+		if machine.Spec.MachineTemplateRef == nil {
+			continue
+		}
+		if amiIDs, ok := resolved[machine.Spec.MachineTemplateRef.Name]; ok {
+			AMIDriftLag.With(labels).Set(c.driftLag(imageID, amiIDs).Seconds())
+		}
+	}
+	return reconcile.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// resolveAMIs returns the AMIs currently resolved for each node template referenced by the machines, and records
+// when each AMI was first discovered
+func (c *Controller) resolveAMIs(ctx context.Context, machines []v1alpha5.Machine) map[string][]string {
+	resolved := map[string][]string{}
+	for _, machine := range machines {
+		if machine.Spec.MachineTemplateRef == nil {
+			continue
+		}
+		name := machine.Spec.MachineTemplateRef.Name
+		if _, ok := resolved[name]; ok {
+			continue
+		}
+		nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: name, IsNodeTemplate: true})
+		if err != nil || nodeClass.Spec.LaunchTemplateName != nil {
+			continue
+		}
+		amis, err := c.amiProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		if err != nil {
+			logging.FromContext(ctx).With("node-template", name).Errorf("resolving amis, %s", err)
+			continue
+		}
+		resolved[name] = lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })
+	}
+	// Only keep track of AMIs that are still resolved so that this doesn't grow unbounded
+	current := lo.Uniq(lo.Flatten(lo.Values(resolved)))
+	c.discovered = lo.SliceToMap(current, func(id string) (string, time.Time) {
+		return id, lo.Ternary(!c.discovered[id].IsZero(), c.discovered[id], c.clk.Now())
+	})
+	return resolved
+}
+
+// driftLag returns how long it has been since the earliest discovered of the currently resolved AMIs was first
+// discovered, or zero if the image is one of the currently resolved AMIs
+func (c *Controller) driftLag(imageID string, amiIDs []string) time.Duration {
+	if len(amiIDs) == 0 || lo.Contains(amiIDs, imageID) {
+		return 0
+	}
+	earliest := lo.MinBy(amiIDs, func(a, b string) bool { return c.discovered[a].Before(c.discovered[b]) })
+	return c.clk.Since(c.discovered[earliest])
+}
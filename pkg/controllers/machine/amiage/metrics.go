@@ -0,0 +1,58 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package amiage
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	imageIDLabel           = "image_id"
+)
+
+var (
+	AMIAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "node_ami_age_seconds",
+			Help:      "Time since the AMI that nodes were launched with was created. Labeled by provisioner and image ID.",
+		},
+		[]string{
+			metrics.ProvisionerLabel,
+			imageIDLabel,
+		},
+	)
+	AMIDriftLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "node_ami_drift_lag_seconds",
+			Help:      "Time since a newer AMI was discovered for the node template of nodes that are still running an older AMI. 0 if the nodes run a currently resolved AMI. Labeled by provisioner and image ID.",
+		},
+		[]string{
+			metrics.ProvisionerLabel,
+			imageIDLabel,
+		},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(AMIAge, AMIDriftLag)
+}
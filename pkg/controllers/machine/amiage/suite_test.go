@@ -0,0 +1,171 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package amiage_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/machine/amiage"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var fakeClock *clock.FakeClock
+var amiAgeController *amiage.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineAMIAge")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	fakeClock = clock.NewFakeClock(time.Now())
+	amiAgeController = amiage.NewController(env.Client, fakeClock, awsEnv.InstanceProvider, awsEnv.AMIProvider)
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineAMIAge", func() {
+	var provisioner *v1alpha5.Provisioner
+	var nodeTemplate *v1alpha1.AWSNodeTemplate
+
+	BeforeEach(func() {
+		awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{
+			Images: []*ec2.Image{
+				{
+					Name:         aws.String("test-ami-old"),
+					ImageId:      aws.String("ami-old"),
+					CreationDate: aws.String(fakeClock.Now().Add(-30 * 24 * time.Hour).Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+				},
+				{
+					Name:         aws.String("test-ami-new"),
+					ImageId:      aws.String("ami-new"),
+					CreationDate: aws.String(fakeClock.Now().Add(-24 * time.Hour).Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+				},
+			},
+		})
+		nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+			AMISelector: map[string]string{"aws-ids": "ami-new"},
+		})
+		provisioner = coretest.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+		})
+	})
+
+	// launch creates a machine and a backing instance that runs the image
+	launch := func(imageID string) *v1alpha5.Machine {
+		instanceID := fake.InstanceID()
+		awsEnv.EC2API.Instances.Store(instanceID, &ec2.Instance{
+			State:   &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			ImageId: aws.String(imageID),
+			Tags: []*ec2.Tag{
+				{Key: aws.String(fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName)), Value: aws.String("owned")},
+				{Key: aws.String(v1alpha5.ProvisionerNameLabelKey), Value: aws.String(provisioner.Name)},
+			},
+			Placement:    &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+			InstanceId:   aws.String(instanceID),
+			InstanceType: aws.String("m5.large"),
+		})
+		machine := coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{v1alpha5.ProvisionerNameLabelKey: provisioner.Name},
+			},
+			Spec: v1alpha5.MachineSpec{
+				MachineTemplateRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+			},
+		})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		machine.Status.ProviderID = fake.ProviderID(instanceID)
+		machine.Status.NodeName = coretest.RandomName()
+		ExpectApplied(ctx, env.Client, machine)
+		return machine
+	}
+	metricLabels := func(imageID string) map[string]string {
+		return map[string]string{
+			"provisioner": provisioner.Name,
+			"image_id":    imageID,
+		}
+	}
+
+	It("should report the age of the node's AMI", func() {
+		launch("ami-old")
+		ExpectReconcileSucceeded(ctx, amiAgeController, client.ObjectKey{})
+
+		metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_node_ami_age_seconds", metricLabels("ami-old"))
+		Expect(ok).To(BeTrue())
+		Expect(metric.GetGauge().GetValue()).To(BeNumerically("~", (30 * 24 * time.Hour).Seconds(), 1))
+	})
+	It("should report the time since a newer AMI was discovered for nodes running an older AMI", func() {
+		launch("ami-old")
+		ExpectReconcileSucceeded(ctx, amiAgeController, client.ObjectKey{})
+		fakeClock.Step(time.Hour)
+		ExpectReconcileSucceeded(ctx, amiAgeController, client.ObjectKey{})
+
+		metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_node_ami_drift_lag_seconds", metricLabels("ami-old"))
+		Expect(ok).To(BeTrue())
+		Expect(metric.GetGauge().GetValue()).To(BeNumerically("~", time.Hour.Seconds(), 1))
+	})
+	It("should report no drift lag for nodes running a currently resolved AMI", func() {
+		launch("ami-new")
+		ExpectReconcileSucceeded(ctx, amiAgeController, client.ObjectKey{})
+		fakeClock.Step(time.Hour)
+		ExpectReconcileSucceeded(ctx, amiAgeController, client.ObjectKey{})
+
+		metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_node_ami_drift_lag_seconds", metricLabels("ami-new"))
+		Expect(ok).To(BeTrue())
+		Expect(metric.GetGauge().GetValue()).To(BeZero())
+	})
+})
@@ -0,0 +1,93 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cost
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/metrics"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/providers/pricing"
+)
+
+// Controller periodically estimates the hourly cost of all launched Machines from the prices known to the
+// pricing provider, so that spend can be tracked without waiting for billing data
+type Controller struct {
+	kubeClient      client.Client
+	pricingProvider *pricing.Provider
+}
+
+func NewController(kubeClient client.Client, pricingProvider *pricing.Provider) *Controller {
+	return &Controller{
+		kubeClient:      kubeClient,
+		pricingProvider: pricingProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "machine.cost"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	machineList := &v1alpha5.MachineList{}
+	if err := c.kubeClient.List(ctx, machineList); err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing machines, %w", err)
+	}
+	costs := map[costKey]float64{}
+	for i := range machineList.Items {
+		machine := &machineList.Items[i]
+		if machine.Status.ProviderID == "" {
+			continue
+		}
+		price, ok := c.pricingProvider.Price(machine.Labels)
+		if !ok {
+			continue
+		}
+		costs[costKey{
+			provisioner:    machine.Labels[v1alpha5.ProvisionerNameLabelKey],
+			capacityType:   machine.Labels[v1alpha5.LabelCapacityType],
+			instanceFamily: machine.Labels[v1alpha1.LabelInstanceFamily],
+		}] += price
+	}
+	// Reset so that series for provisioners, capacity types, or families that no longer have machines are removed
+	EstimatedCost.Reset()
+	for k, cost := range costs {
+		EstimatedCost.With(prometheus.Labels{
+			metrics.ProvisionerLabel: k.provisioner,
+			capacityTypeLabel:        k.capacityType,
+			instanceFamilyLabel:      k.instanceFamily,
+		}).Set(cost)
+	}
+	return reconcile.Result{RequeueAfter: time.Minute}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+type costKey struct {
+	provisioner    string
+	capacityType   string
+	instanceFamily string
+}
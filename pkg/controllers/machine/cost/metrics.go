@@ -0,0 +1,48 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cost
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	capacityTypeLabel      = "capacity_type"
+	instanceFamilyLabel    = "instance_family"
+)
+
+var (
+	EstimatedCost = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "estimated_cost_per_hour",
+			Help:      "Estimated hourly cost in USD of the machines launched by Karpenter, based on the prices known to the pricing provider. Labeled by provisioner, capacity type, and instance family.",
+		},
+		[]string{
+			metrics.ProvisionerLabel,
+			capacityTypeLabel,
+			instanceFamilyLabel,
+		},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(EstimatedCost)
+}
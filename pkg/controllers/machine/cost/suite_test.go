@@ -0,0 +1,122 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cost_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/machine/cost"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var costController *cost.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineCost")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+	costController = cost.NewController(env.Client, awsEnv.PricingProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineCost", func() {
+	var provisioner *v1alpha5.Provisioner
+
+	BeforeEach(func() {
+		provisioner = coretest.Provisioner()
+	})
+	It("should sum the cost of machines by provisioner, capacity type, and instance family", func() {
+		for i := 0; i < 2; i++ {
+			ExpectApplied(ctx, env.Client, coretest.Machine(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+						v1.LabelInstanceTypeStable:       "m5.large",
+						v1.LabelTopologyZone:             "test-zone-1a",
+						v1alpha5.LabelCapacityType:       v1alpha5.CapacityTypeOnDemand,
+						v1alpha1.LabelInstanceFamily:     "m5",
+					},
+				},
+			}))
+		}
+		ExpectReconcileSucceeded(ctx, costController, client.ObjectKey{})
+
+		price, ok := awsEnv.PricingProvider.OnDemandPrice("m5.large")
+		Expect(ok).To(BeTrue())
+		metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_estimated_cost_per_hour", map[string]string{
+			"provisioner":     provisioner.Name,
+			"capacity_type":   v1alpha5.CapacityTypeOnDemand,
+			"instance_family": "m5",
+		})
+		Expect(ok).To(BeTrue())
+		Expect(metric.GetGauge().GetValue()).To(BeNumerically("~", price*2))
+	})
+	It("should not include machines without a known price", func() {
+		ExpectApplied(ctx, env.Client, coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+					v1.LabelInstanceTypeStable:       "unknown.large",
+					v1alpha5.LabelCapacityType:       v1alpha5.CapacityTypeOnDemand,
+					v1alpha1.LabelInstanceFamily:     "unknown",
+				},
+			},
+		}))
+		ExpectReconcileSucceeded(ctx, costController, client.ObjectKey{})
+
+		_, ok := FindMetricWithLabelValues("karpenter_cloudprovider_estimated_cost_per_hour", map[string]string{
+			"provisioner": provisioner.Name,
+		})
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,114 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package disruptionprotection
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/logging"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// Controller protects the nodes of Machines that are younger than the minimum node lifetime of their node template
+// from voluntary disruption by annotating them with do-not-disrupt, and removes the annotation once they're old enough.
+// Interruptions are handled regardless, since the instance is going away either way.
+type Controller struct {
+	kubeClient client.Client
+	clk        clock.Clock
+}
+
+func NewController(kubeClient client.Client, clk clock.Clock) corecontroller.Controller {
+	return corecontroller.Typed[*v1alpha5.Machine](kubeClient, &Controller{
+		kubeClient: kubeClient,
+		clk:        clk,
+	})
+}
+
+func (c *Controller) Name() string {
+	return "machine.disruptionprotection"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, machine *v1alpha5.Machine) (reconcile.Result, error) {
+	launched := machine.StatusConditions().GetCondition(v1alpha5.MachineLaunched)
+	if launched == nil || !launched.IsTrue() || machine.Status.NodeName == "" || machine.Spec.MachineTemplateRef == nil || !machine.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	node := &v1.Node{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: machine.Status.NodeName}, node); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(fmt.Errorf("getting node, %w", err))
+	}
+	// Nodes whose node template is gone aren't protected, so that they can be cleaned up
+	var lifetime time.Duration
+	nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: machine.Spec.MachineTemplateRef.Name, IsNodeTemplate: true})
+	if client.IgnoreNotFound(err) != nil {
+		return reconcile.Result{}, err
+	}
+	if err == nil && nodeClass.Spec.MinimumNodeLifetime != nil {
+		lifetime = nodeClass.Spec.MinimumNodeLifetime.Duration
+	}
+	stored := node.DeepCopy()
+	until := launched.LastTransitionTime.Inner.Time.Add(lifetime)
+	protected := c.clk.Now().Before(until)
+	_, managed := node.Annotations[v1alpha1.AnnotationDisruptionProtectedUntil]
+	switch {
+	case protected && !managed && node.Annotations[corev1beta1.DoNotDisruptAnnotationKey] == "true":
+		// The annotation was set by someone else, so we leave it alone
+		return reconcile.Result{}, nil
+	case protected:
+		node.Annotations = lo.Assign(node.Annotations, map[string]string{
+			corev1beta1.DoNotDisruptAnnotationKey:       "true",
+			v1alpha1.AnnotationDisruptionProtectedUntil: until.UTC().Format(time.RFC3339),
+		})
+	case managed:
+		delete(node.Annotations, corev1beta1.DoNotDisruptAnnotationKey)
+		delete(node.Annotations, v1alpha1.AnnotationDisruptionProtectedUntil)
+	}
+	if !equality.Semantic.DeepEqual(stored, node) {
+		if err := c.kubeClient.Patch(ctx, node, client.MergeFrom(stored)); err != nil {
+			return reconcile.Result{}, client.IgnoreNotFound(fmt.Errorf("patching node, %w", err))
+		}
+		if protected {
+			logging.FromContext(ctx).With("node", node.Name, "until", until.UTC().Format(time.RFC3339)).Debugf("protecting node from disruption")
+		} else {
+			logging.FromContext(ctx).With("node", node.Name).Debugf("removing disruption protection from node")
+		}
+	}
+	if protected {
+		return reconcile.Result{RequeueAfter: until.Sub(c.clk.Now())}, nil
+	}
+	return reconcile.Result{}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(&v1alpha5.Machine{}))
+}
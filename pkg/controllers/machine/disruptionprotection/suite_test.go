@@ -0,0 +1,146 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package disruptionprotection_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/machine/disruptionprotection"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var fakeClock *clock.FakeClock
+var disruptionProtectionController controller.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineDisruptionProtection")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	fakeClock = clock.NewFakeClock(time.Now())
+	disruptionProtectionController = disruptionprotection.NewController(env.Client, fakeClock)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	fakeClock.SetTime(time.Now())
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineDisruptionProtection", func() {
+	var provisioner *v1alpha5.Provisioner
+	var nodeTemplate *v1alpha1.AWSNodeTemplate
+	var machine *v1alpha5.Machine
+	var node *v1.Node
+
+	BeforeEach(func() {
+		nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+			MinimumNodeLifetime: &metav1.Duration{Duration: time.Hour},
+		})
+		provisioner = coretest.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+		})
+		node = coretest.Node()
+		machine = coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+				},
+			},
+			Spec: v1alpha5.MachineSpec{
+				MachineTemplateRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+			},
+		})
+		machine.Status.NodeName = node.Name
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+	})
+	It("should protect nodes younger than the minimum node lifetime of their node template", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine, node)
+		fakeClock.Step(20 * time.Minute)
+		result := ExpectReconcileSucceeded(ctx, disruptionProtectionController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeNumerically("~", 40*time.Minute, 5*time.Second))
+		node = ExpectExists(ctx, env.Client, node)
+		Expect(node.Annotations).To(HaveKeyWithValue(corev1beta1.DoNotDisruptAnnotationKey, "true"))
+		Expect(node.Annotations).To(HaveKey(v1alpha1.AnnotationDisruptionProtectedUntil))
+	})
+	It("should remove the protection once nodes are older than the minimum node lifetime", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine, node)
+		ExpectReconcileSucceeded(ctx, disruptionProtectionController, client.ObjectKeyFromObject(machine))
+		fakeClock.Step(2 * time.Hour)
+		result := ExpectReconcileSucceeded(ctx, disruptionProtectionController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeZero())
+		node = ExpectExists(ctx, env.Client, node)
+		Expect(node.Annotations).ToNot(HaveKey(corev1beta1.DoNotDisruptAnnotationKey))
+		Expect(node.Annotations).ToNot(HaveKey(v1alpha1.AnnotationDisruptionProtectedUntil))
+	})
+	It("should not remove a do-not-disrupt annotation that it didn't set", func() {
+		node.Annotations = map[string]string{corev1beta1.DoNotDisruptAnnotationKey: "true"}
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine, node)
+		ExpectReconcileSucceeded(ctx, disruptionProtectionController, client.ObjectKeyFromObject(machine))
+		fakeClock.Step(2 * time.Hour)
+		ExpectReconcileSucceeded(ctx, disruptionProtectionController, client.ObjectKeyFromObject(machine))
+		node = ExpectExists(ctx, env.Client, node)
+		Expect(node.Annotations).To(HaveKeyWithValue(corev1beta1.DoNotDisruptAnnotationKey, "true"))
+		Expect(node.Annotations).ToNot(HaveKey(v1alpha1.AnnotationDisruptionProtectedUntil))
+	})
+	It("should not protect nodes whose node template doesn't set a minimum node lifetime", func() {
+		nodeTemplate.Spec.MinimumNodeLifetime = nil
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine, node)
+		result := ExpectReconcileSucceeded(ctx, disruptionProtectionController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeZero())
+		node = ExpectExists(ctx, env.Client, node)
+		Expect(node.Annotations).ToNot(HaveKey(corev1beta1.DoNotDisruptAnnotationKey))
+	})
+	It("should not protect nodes whose node template doesn't exist", func() {
+		ExpectApplied(ctx, env.Client, provisioner, machine, node)
+		ExpectReconcileSucceeded(ctx, disruptionProtectionController, client.ObjectKeyFromObject(machine))
+		node = ExpectExists(ctx, env.Client, node)
+		Expect(node.Annotations).ToNot(HaveKey(corev1beta1.DoNotDisruptAnnotationKey))
+	})
+})
@@ -0,0 +1,119 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"context"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"k8s.io/utils/clock"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter/pkg/providers/instance"
+)
+
+// Controller records how long launched NodeClaims took to register and initialize once they become initialized.
+// The phases up to and including CreateFleet are recorded by the instance provider when the NodeClaim is launched.
+type Controller struct {
+	clk       clock.Clock
+	startTime time.Time
+	observed  *cache.Cache // key: nodeclaim UID, ensures that each nodeclaim is only observed once
+}
+
+func NewController(clk clock.Clock) *Controller {
+	return &Controller{
+		clk:       clk,
+		startTime: clk.Now(),
+		observed:  cache.New(time.Hour, time.Minute),
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) (reconcile.Result, error) {
+	launched := nodeClaim.StatusConditions().GetCondition(corev1beta1.NodeLaunched)
+	registered := nodeClaim.StatusConditions().GetCondition(corev1beta1.NodeRegistered)
+	initialized := nodeClaim.StatusConditions().GetCondition(corev1beta1.NodeInitialized)
+	if launched == nil || registered == nil || initialized == nil || !initialized.IsTrue() {
+		return reconcile.Result{}, nil
+	}
+	// NodeClaims that were initialized before this controller started were already observed, or can't be
+	// distinguished from ones that were, so they're skipped to avoid double counting across restarts
+	if initialized.LastTransitionTime.Inner.Time.Before(c.startTime) {
+		return reconcile.Result{}, nil
+	}
+	if _, ok := c.observed.Get(string(nodeClaim.UID)); ok {
+		return reconcile.Result{}, nil
+	}
+	ownerName := nodeclaimutil.OwnerKey(nodeClaim).Name
+	instance.ObservePhase(ctx, instance.PhaseRegistration, ownerName, registered.LastTransitionTime.Inner.Sub(launched.LastTransitionTime.Inner.Time))
+	instance.ObservePhase(ctx, instance.PhaseInitialization, ownerName, initialized.LastTransitionTime.Inner.Sub(registered.LastTransitionTime.Inner.Time))
+	c.observed.SetDefault(string(nodeClaim.UID), nil)
+	return reconcile.Result{}, nil
+}
+
+var _ corecontroller.TypedController[*v1alpha5.Machine] = (*MachineController)(nil)
+
+type MachineController struct {
+	*Controller
+}
+
+func NewMachineController(kubeClient client.Client, clk clock.Clock) corecontroller.Controller {
+	return corecontroller.Typed[*v1alpha5.Machine](kubeClient, &MachineController{
+		Controller: NewController(clk),
+	})
+}
+
+func (c *MachineController) Name() string {
+	return "machine.latency"
+}
+
+func (c *MachineController) Reconcile(ctx context.Context, machine *v1alpha5.Machine) (reconcile.Result, error) {
+	return c.Controller.Reconcile(ctx, nodeclaimutil.New(machine))
+}
+
+func (c *MachineController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(&v1alpha5.Machine{}))
+}
+
+var _ corecontroller.TypedController[*corev1beta1.NodeClaim] = (*NodeClaimController)(nil)
+
+type NodeClaimController struct {
+	*Controller
+}
+
+func NewNodeClaimController(kubeClient client.Client, clk clock.Clock) corecontroller.Controller {
+	return corecontroller.Typed[*corev1beta1.NodeClaim](kubeClient, &NodeClaimController{
+		Controller: NewController(clk),
+	})
+}
+
+func (c *NodeClaimController) Name() string {
+	return "nodeclaim.latency"
+}
+
+func (c *NodeClaimController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(&corev1beta1.NodeClaim{}))
+}
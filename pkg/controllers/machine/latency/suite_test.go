@@ -0,0 +1,141 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/controllers/machine/latency"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var fakeClock *clock.FakeClock
+var latencyController controller.Controller
+var nodeClaimLatencyController controller.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineLatency")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	fakeClock = clock.NewFakeClock(time.Now().Add(-time.Hour))
+	latencyController = latency.NewMachineController(env.Client, fakeClock)
+	nodeClaimLatencyController = latency.NewNodeClaimController(env.Client, fakeClock)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineLatency", func() {
+	var provisioner *v1alpha5.Provisioner
+	var machine *v1alpha5.Machine
+
+	BeforeEach(func() {
+		provisioner = coretest.Provisioner()
+		machine = coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+				},
+			},
+		})
+	})
+	It("should observe the registration and initialization phases once a machine is initialized", func() {
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineInitialized)
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileSucceeded(ctx, latencyController, client.ObjectKeyFromObject(machine))
+
+		Expect(phaseSampleCount("registration", provisioner.Name)).To(BeNumerically("==", 1))
+		Expect(phaseSampleCount("initialization", provisioner.Name)).To(BeNumerically("==", 1))
+	})
+	It("should only observe a machine once", func() {
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineInitialized)
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileSucceeded(ctx, latencyController, client.ObjectKeyFromObject(machine))
+		ExpectReconcileSucceeded(ctx, latencyController, client.ObjectKeyFromObject(machine))
+
+		Expect(phaseSampleCount("registration", provisioner.Name)).To(BeNumerically("==", 1))
+	})
+	It("should not observe a machine that isn't initialized", func() {
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileSucceeded(ctx, latencyController, client.ObjectKeyFromObject(machine))
+
+		Expect(phaseSampleCount("registration", provisioner.Name)).To(BeNumerically("==", 0))
+	})
+	It("should observe the registration and initialization phases once a nodeclaim is initialized", func() {
+		nodePool := coretest.NodePool()
+		nodeClaim := coretest.NodeClaim(corev1beta1.NodeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					corev1beta1.NodePoolLabelKey: nodePool.Name,
+				},
+			},
+		})
+		nodeClaim.StatusConditions().MarkTrue(corev1beta1.NodeLaunched)
+		nodeClaim.StatusConditions().MarkTrue(corev1beta1.NodeRegistered)
+		nodeClaim.StatusConditions().MarkTrue(corev1beta1.NodeInitialized)
+		ExpectApplied(ctx, env.Client, nodePool, nodeClaim)
+		ExpectReconcileSucceeded(ctx, nodeClaimLatencyController, client.ObjectKeyFromObject(nodeClaim))
+
+		Expect(phaseSampleCount("registration", nodePool.Name)).To(BeNumerically("==", 1))
+		Expect(phaseSampleCount("initialization", nodePool.Name)).To(BeNumerically("==", 1))
+	})
+})
+
+func phaseSampleCount(phase, provisionerName string) uint64 {
+	metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_launch_phase_duration_seconds", map[string]string{
+		"phase":       phase,
+		"provisioner": provisionerName,
+	})
+	return lo.Ternary(ok, metric.GetHistogram().GetSampleCount(), 0)
+}
@@ -0,0 +1,296 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package liveness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"golang.org/x/time/rate"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/logging"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/events"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	machineutil "github.com/aws/karpenter-core/pkg/utils/machine"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+const (
+	// coreRegistrationTTL is how long karpenter-core gives nodes to register before it deletes their Machine or NodeClaim
+	coreRegistrationTTL = 15 * time.Minute
+	// diagnosisLeadTime is how long before karpenter-core deletes a NodeClaim whose node didn't register that we look
+	// into why, since the instance is terminated right after
+	diagnosisLeadTime = time.Minute
+	// coreLivenessRefreshInterval is how often karpenter-core's registration TTL is deferred for NodeClaims whose
+	// registration TTL is longer, which leaves plenty of slack before karpenter-core's TTL would run out
+	coreLivenessRefreshInterval = 5 * time.Minute
+
+	// Events can't be too large, so only the last lines of the console output are included
+	maxConsoleOutputLines  = 20
+	maxConsoleOutputLength = 2048
+
+	// GetConsoleOutput is rate limited per account, so a wave of nodes failing to register is diagnosed gradually
+	consoleOutputQPS   = 1
+	consoleOutputBurst = 5
+)
+
+// Controller deletes Machines and NodeClaims whose node doesn't register within the registration TTL of their
+// NodeClass, and keeps karpenter-core from deleting them before then when the TTL is longer than karpenter-core's 15
+// minutes. Those whose NodeClass doesn't set one are left to karpenter-core, which deletes them after 15 minutes.
+// Either way, the end of the instance's console output is published in an event on the object before it's deleted,
+// since it usually shows why the node didn't register. Instance types whose nodes repeatedly fail to register are
+// excluded from the NodeClass for a while, counting each node once its Machine or NodeClaim is actually deleted.
+type Controller struct {
+	kubeClient        client.Client
+	clk               clock.Clock
+	recorder          events.Recorder
+	instanceProvider  *instance.Provider
+	bootstrapFailures *awscache.BootstrapFailures
+	// consoleOutputs holds the console output of the instances that have been diagnosed, keyed by instance id
+	consoleOutputs       *cache.Cache
+	consoleOutputLimiter *rate.Limiter
+}
+
+func NewController(kubeClient client.Client, clk clock.Clock, recorder events.Recorder, instanceProvider *instance.Provider,
+	bootstrapFailures *awscache.BootstrapFailures) *Controller {
+	return &Controller{
+		kubeClient:           kubeClient,
+		clk:                  clk,
+		recorder:             recorder,
+		instanceProvider:     instanceProvider,
+		bootstrapFailures:    bootstrapFailures,
+		consoleOutputs:       cache.New(awscache.ConsoleOutputTTL, awscache.DefaultCleanupInterval),
+		consoleOutputLimiter: rate.NewLimiter(rate.Limit(consoleOutputQPS), consoleOutputBurst),
+	}
+}
+
+// Reconcile enforces the registration TTL of the NodeClaim, which may have been converted from a Machine
+func (c *Controller) Reconcile(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) (reconcile.Result, error) {
+	registered := nodeClaim.StatusConditions().GetCondition(corev1beta1.NodeRegistered)
+	if registered == nil || nodeClaim.Spec.NodeClass == nil {
+		return reconcile.Result{}, nil
+	}
+	key := nodeclassutil.Key{Name: nodeClaim.Spec.NodeClass.Name, IsNodeTemplate: nodeClaim.Spec.NodeClass.IsNodeTemplate}
+	if registered.IsTrue() {
+		c.bootstrapFailures.MarkRegistered(key, nodeClaim.Labels[v1.LabelInstanceTypeStable])
+		return reconcile.Result{}, nil
+	}
+	nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, key)
+	if err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	if !nodeClaim.DeletionTimestamp.IsZero() {
+		// Only nodes that are deleted because they didn't register count against their instance type, rather than
+		// those that are deleted for other reasons while they're still coming up
+		if start, ttl, ok := registrationStart(nodeClaim, nodeClass); ok && nodeClaim.DeletionTimestamp.Sub(start) >= ttl {
+			c.markFailed(ctx, nodeClaim, key, ttl)
+		}
+		return reconcile.Result{}, nil
+	}
+	if nodeClass.Spec.RegistrationTTL == nil {
+		// The registered condition is initialized when the NodeClaim is launched, and karpenter-core measures its
+		// registration TTL from it
+		since := c.clk.Since(registered.LastTransitionTime.Inner.Time)
+		if since < coreRegistrationTTL-diagnosisLeadTime {
+			return reconcile.Result{RequeueAfter: coreRegistrationTTL - diagnosisLeadTime - since}, nil
+		}
+		if since < coreRegistrationTTL {
+			return reconcile.Result{RequeueAfter: c.diagnose(ctx, nodeClaim, coreRegistrationTTL)}, nil
+		}
+		return reconcile.Result{}, nil
+	}
+	launched := nodeClaim.StatusConditions().GetCondition(corev1beta1.NodeLaunched)
+	if launched == nil || !launched.IsTrue() {
+		return reconcile.Result{}, nil
+	}
+	ttl := nodeClass.Spec.RegistrationTTL.Duration
+	if since := c.clk.Since(launched.LastTransitionTime.Inner.Time); since < ttl {
+		if ttl > coreRegistrationTTL {
+			return c.deferCoreLiveness(ctx, nodeClaim, registered, ttl-since)
+		}
+		return reconcile.Result{RequeueAfter: ttl - since}, nil
+	}
+	if delay := c.diagnose(ctx, nodeClaim, ttl); delay > 0 {
+		return reconcile.Result{RequeueAfter: delay}, nil
+	}
+	if err := nodeclaimutil.Delete(ctx, c.kubeClient, nodeClaim); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	logging.FromContext(ctx).With("ttl", ttl).Debugf("terminating due to registration ttl")
+	nodeclaimutil.TerminatedCounter(nodeClaim, "liveness").Inc()
+	// The object may be gone before it's reconciled again, so the failure is counted as soon as it's deleted
+	c.markFailed(ctx, nodeClaim, key, ttl)
+	return reconcile.Result{}, nil
+}
+
+// registrationStart returns when the registration TTL of the NodeClaim started, and how long it is. karpenter-core
+// measures its TTL from the last transition of the registered condition, and the NodeClass's TTL is measured from
+// the launch.
+func registrationStart(nodeClaim *corev1beta1.NodeClaim, nodeClass *v1beta1.NodeClass) (time.Time, time.Duration, bool) {
+	if nodeClass.Spec.RegistrationTTL == nil {
+		return nodeClaim.StatusConditions().GetCondition(corev1beta1.NodeRegistered).LastTransitionTime.Inner.Time, coreRegistrationTTL, true
+	}
+	launched := nodeClaim.StatusConditions().GetCondition(corev1beta1.NodeLaunched)
+	if launched == nil || !launched.IsTrue() {
+		return time.Time{}, 0, false
+	}
+	return launched.LastTransitionTime.Inner.Time, nodeClass.Spec.RegistrationTTL.Duration, true
+}
+
+// deferCoreLiveness keeps karpenter-core from deleting a NodeClaim whose registration TTL is longer than
+// karpenter-core's own. karpenter-core measures its TTL from the last transition of the registered condition, so the
+// transition is moved forward every coreLivenessRefreshInterval until the NodeClaim's registration TTL runs out.
+func (c *Controller) deferCoreLiveness(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, registered *apis.Condition, remaining time.Duration) (reconcile.Result, error) {
+	if since := c.clk.Since(registered.LastTransitionTime.Inner.Time); since < coreLivenessRefreshInterval {
+		return reconcile.Result{RequeueAfter: lo.Min([]time.Duration{remaining, coreLivenessRefreshInterval - since})}, nil
+	}
+	stored := nodeClaim.DeepCopy()
+	for i := range nodeClaim.Status.Conditions {
+		if nodeClaim.Status.Conditions[i].Type == corev1beta1.NodeRegistered {
+			nodeClaim.Status.Conditions[i].LastTransitionTime = apis.VolatileTime{Inner: metav1.NewTime(c.clk.Now())}
+		}
+	}
+	// Optimistic locking keeps us from reverting conditions that karpenter-core updated in the meantime
+	var err error
+	if nodeClaim.IsMachine {
+		err = c.kubeClient.Status().Patch(ctx, machineutil.NewFromNodeClaim(nodeClaim), client.MergeFromWithOptions(machineutil.NewFromNodeClaim(stored), client.MergeFromWithOptimisticLock{}))
+	} else {
+		err = c.kubeClient.Status().Patch(ctx, nodeClaim, client.MergeFromWithOptions(stored, client.MergeFromWithOptimisticLock{}))
+	}
+	if err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	return reconcile.Result{RequeueAfter: lo.Min([]time.Duration{remaining, coreLivenessRefreshInterval})}, nil
+}
+
+// diagnose publishes an event on the Machine or NodeClaim with the end of its instance's console output. Each
+// instance is diagnosed once. If GetConsoleOutput is being rate limited, nothing is done and diagnose returns how long
+// to wait before trying again.
+func (c *Controller) diagnose(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, ttl time.Duration) time.Duration {
+	id, err := utils.ParseInstanceID(nodeClaim.Status.ProviderID)
+	if err != nil {
+		id = string(nodeClaim.UID)
+	}
+	if _, ok := c.consoleOutputs.Get(id); ok {
+		return 0
+	}
+	var output string
+	if err == nil {
+		reservation := c.consoleOutputLimiter.ReserveN(c.clk.Now(), 1)
+		if delay := reservation.DelayFrom(c.clk.Now()); delay > 0 {
+			reservation.CancelAt(c.clk.Now())
+			return delay
+		}
+		if output, err = c.instanceProvider.ConsoleOutput(ctx, id); err != nil {
+			logging.FromContext(ctx).With("id", id).Debugf("getting console output, %s", err)
+		}
+	}
+	c.consoleOutputs.SetDefault(id, output)
+	var involvedObject client.Object = nodeClaim
+	if nodeClaim.IsMachine {
+		involvedObject = machineutil.NewFromNodeClaim(nodeClaim)
+	}
+	c.recorder.Publish(RegistrationFailed(involvedObject, ttl, summarize(output)))
+	return 0
+}
+
+// markFailed records the registration failure of the NodeClaim against its instance type. Recording the same
+// NodeClaim more than once is a no-op.
+func (c *Controller) markFailed(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, key nodeclassutil.Key, ttl time.Duration) {
+	instanceType := nodeClaim.Labels[v1.LabelInstanceTypeStable]
+	if c.bootstrapFailures.MarkFailed(key, instanceType, nodeClaim.Name, fmt.Sprintf("node didn't register within %s", ttl)) {
+		logging.FromContext(ctx).With("instance-type", instanceType, "ttl", awscache.BootstrapFailureExclusionTTL).
+			Infof("excluding instance type from node class after %d nodes failed to register", awscache.BootstrapFailureThreshold)
+	}
+}
+
+// summarize returns the last non-empty lines of the console output, where boot failures usually show up
+func summarize(output string) string {
+	lines := lo.Filter(strings.Split(strings.ReplaceAll(output, "\r", ""), "\n"), func(line string, _ int) bool {
+		return strings.TrimSpace(line) != ""
+	})
+	summary := strings.Join(lines[lo.Max([]int{0, len(lines) - maxConsoleOutputLines}):], "\n")
+	return summary[lo.Max([]int{0, len(summary) - maxConsoleOutputLength}):]
+}
+
+var _ corecontroller.TypedController[*v1alpha5.Machine] = (*MachineController)(nil)
+
+type MachineController struct {
+	*Controller
+}
+
+func NewMachineController(kubeClient client.Client, clk clock.Clock, recorder events.Recorder, instanceProvider *instance.Provider,
+	bootstrapFailures *awscache.BootstrapFailures) corecontroller.Controller {
+	return corecontroller.Typed[*v1alpha5.Machine](kubeClient, &MachineController{
+		Controller: NewController(kubeClient, clk, recorder, instanceProvider, bootstrapFailures),
+	})
+}
+
+func (c *MachineController) Name() string {
+	return "machine.liveness"
+}
+
+func (c *MachineController) Reconcile(ctx context.Context, machine *v1alpha5.Machine) (reconcile.Result, error) {
+	return c.Controller.Reconcile(ctx, nodeclaimutil.New(machine))
+}
+
+func (c *MachineController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(&v1alpha5.Machine{}))
+}
+
+var _ corecontroller.TypedController[*corev1beta1.NodeClaim] = (*NodeClaimController)(nil)
+
+type NodeClaimController struct {
+	*Controller
+}
+
+func NewNodeClaimController(kubeClient client.Client, clk clock.Clock, recorder events.Recorder, instanceProvider *instance.Provider,
+	bootstrapFailures *awscache.BootstrapFailures) corecontroller.Controller {
+	return corecontroller.Typed[*corev1beta1.NodeClaim](kubeClient, &NodeClaimController{
+		Controller: NewController(kubeClient, clk, recorder, instanceProvider, bootstrapFailures),
+	})
+}
+
+func (c *NodeClaimController) Name() string {
+	return "nodeclaim.liveness"
+}
+
+func (c *NodeClaimController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(&corev1beta1.NodeClaim{}))
+}
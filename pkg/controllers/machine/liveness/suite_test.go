@@ -0,0 +1,389 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package liveness_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/controllers/machine/liveness"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var recorder *coretest.EventRecorder
+var fakeClock *clock.FakeClock
+var livenessController controller.Controller
+var nodeClaimLivenessController controller.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineLiveness")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+	fakeClock = clock.NewFakeClock(time.Now())
+	recorder = coretest.NewEventRecorder()
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	recorder.Reset()
+	fakeClock.SetTime(time.Now())
+	livenessController = liveness.NewMachineController(env.Client, fakeClock, recorder, awsEnv.InstanceProvider, awsEnv.BootstrapFailuresCache)
+	nodeClaimLivenessController = liveness.NewNodeClaimController(env.Client, fakeClock, recorder, awsEnv.InstanceProvider, awsEnv.BootstrapFailuresCache)
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineLiveness", func() {
+	var provisioner *v1alpha5.Provisioner
+	var nodeTemplate *v1alpha1.AWSNodeTemplate
+	var machine *v1alpha5.Machine
+
+	BeforeEach(func() {
+		nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+			RegistrationTTL: &metav1.Duration{Duration: 5 * time.Minute},
+		})
+		provisioner = coretest.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+		})
+		machine = coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+				},
+			},
+			Spec: v1alpha5.MachineSpec{
+				MachineTemplateRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+			},
+		})
+		machine.Status.ProviderID = fake.ProviderID(fake.InstanceID())
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		machine.StatusConditions().MarkUnknown(v1alpha5.MachineRegistered, "", "")
+	})
+	setConsoleOutput := func(output string) {
+		awsEnv.EC2API.GetConsoleOutputBehavior.Output.Set(&ec2.GetConsoleOutputOutput{
+			Output: aws.String(base64.StdEncoding.EncodeToString([]byte(output))),
+		})
+	}
+	registrationFailedMessage := func() string {
+		events := recorder.Events()
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Reason).To(Equal("RegistrationFailed"))
+		return events[0].Message
+	}
+	It("should delete machines that don't register within the registration TTL of their node template", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(6 * time.Minute)
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		ExpectNotFound(ctx, env.Client, machine)
+	})
+	It("should requeue machines until the registration TTL of their node template expires", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(2 * time.Minute)
+		result := ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeNumerically("~", 3*time.Minute, 5*time.Second))
+		ExpectExists(ctx, env.Client, machine)
+	})
+	It("should keep karpenter-core from deleting machines whose registration TTL is longer than its own", func() {
+		nodeTemplate.Spec.RegistrationTTL = &metav1.Duration{Duration: 30 * time.Minute}
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(6 * time.Minute)
+		result := ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeNumerically("~", 5*time.Minute, 5*time.Second))
+		machine = ExpectExists(ctx, env.Client, machine)
+		registered := machine.StatusConditions().GetCondition(v1alpha5.MachineRegistered)
+		Expect(registered.LastTransitionTime.Inner.Time).To(BeTemporally("~", fakeClock.Now(), time.Second))
+		Expect(recorder.Events()).To(BeEmpty())
+	})
+	It("should delete machines once a registration TTL longer than karpenter-core's expires", func() {
+		nodeTemplate.Spec.RegistrationTTL = &metav1.Duration{Duration: 30 * time.Minute}
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(31 * time.Minute)
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		ExpectNotFound(ctx, env.Client, machine)
+	})
+	It("should not delete machines that registered", func() {
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(6 * time.Minute)
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		ExpectExists(ctx, env.Client, machine)
+	})
+	It("should leave machines whose node template doesn't set a registration TTL to the default", func() {
+		nodeTemplate.Spec.RegistrationTTL = nil
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(14 * time.Minute)
+		result := ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeZero())
+		ExpectExists(ctx, env.Client, machine)
+	})
+	It("should not delete machines whose node template doesn't exist", func() {
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		fakeClock.Step(14 * time.Minute)
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		ExpectExists(ctx, env.Client, machine)
+	})
+	It("should publish the end of the console output of machines that don't register within the registration TTL", func() {
+		setConsoleOutput("Booting kernel\r\n\r\nStarting kubelet\r\nFailed to start kubelet: bad bootstrap config\r\n")
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(6 * time.Minute)
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		ExpectNotFound(ctx, env.Client, machine)
+		Expect(registrationFailedMessage()).To(HaveSuffix("Booting kernel\nStarting kubelet\nFailed to start kubelet: bad bootstrap config"))
+	})
+	It("should only publish the last lines of the console output", func() {
+		var lines []string
+		for i := 0; i < 100; i++ {
+			lines = append(lines, fmt.Sprintf("line %d", i))
+		}
+		setConsoleOutput(strings.Join(lines, "\n"))
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(6 * time.Minute)
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		message := registrationFailedMessage()
+		Expect(message).To(HaveSuffix("line 99"))
+		Expect(message).To(ContainSubstring("line 80"))
+		Expect(message).ToNot(ContainSubstring("line 79"))
+	})
+	It("should publish an event when no console output is available", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(6 * time.Minute)
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		Expect(registrationFailedMessage()).To(ContainSubstring("no console output is available"))
+	})
+	It("should publish the console output shortly before karpenter-core deletes machines whose node template doesn't set a registration TTL", func() {
+		setConsoleOutput("Failed to start kubelet")
+		nodeTemplate.Spec.RegistrationTTL = nil
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(10 * time.Minute)
+		result := ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeNumerically("~", 4*time.Minute, 5*time.Second))
+		Expect(recorder.Events()).To(BeEmpty())
+		fakeClock.Step(4 * time.Minute)
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		ExpectExists(ctx, env.Client, machine)
+		Expect(registrationFailedMessage()).To(HaveSuffix("Failed to start kubelet"))
+	})
+	It("should only get the console output of an instance once", func() {
+		nodeTemplate.Spec.RegistrationTTL = nil
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		fakeClock.Step(14 * time.Minute)
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+		Expect(awsEnv.EC2API.GetConsoleOutputBehavior.Calls()).To(Equal(1))
+		Expect(recorder.Calls("RegistrationFailed")).To(Equal(1))
+	})
+	It("should rate limit getting console output and delete machines once their instance is diagnosed", func() {
+		var machines []*v1alpha5.Machine
+		for i := 0; i < 6; i++ {
+			m := machine.DeepCopy()
+			m.Name = coretest.RandomName()
+			m.Status.ProviderID = fake.ProviderID(fake.InstanceID())
+			ExpectApplied(ctx, env.Client, m)
+			machines = append(machines, m)
+		}
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		fakeClock.Step(6 * time.Minute)
+		for _, m := range machines[:5] {
+			ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(m))
+			ExpectNotFound(ctx, env.Client, m)
+		}
+		result := ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machines[5]))
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		ExpectExists(ctx, env.Client, machines[5])
+		Expect(awsEnv.EC2API.GetConsoleOutputBehavior.Calls()).To(Equal(5))
+
+		fakeClock.Step(result.RequeueAfter)
+		ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machines[5]))
+		ExpectNotFound(ctx, env.Client, machines[5])
+		Expect(awsEnv.EC2API.GetConsoleOutputBehavior.Calls()).To(Equal(6))
+	})
+	Context("Bootstrap Failures", func() {
+		var key nodeclassutil.Key
+		BeforeEach(func() {
+			key = nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true}
+			machine.Labels[v1.LabelInstanceTypeStable] = "m5.large"
+		})
+		failToRegister := func(m *v1alpha5.Machine) {
+			ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, m)
+			fakeClock.Step(6 * time.Minute)
+			ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(m))
+			ExpectNotFound(ctx, env.Client, m)
+		}
+		machineOf := func(instanceType string) *v1alpha5.Machine {
+			m := machine.DeepCopy()
+			m.Name = coretest.RandomName()
+			m.ResourceVersion = ""
+			m.Status.ProviderID = fake.ProviderID(fake.InstanceID())
+			m.Labels[v1.LabelInstanceTypeStable] = instanceType
+			return m
+		}
+		It("should exclude an instance type from the node template after its nodes repeatedly fail to register", func() {
+			for i := 0; i < awscache.BootstrapFailureThreshold-1; i++ {
+				failToRegister(machineOf("m5.large"))
+				Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.large")).To(BeFalse())
+			}
+			failToRegister(machineOf("m5.large"))
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.large")).To(BeTrue())
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.xlarge")).To(BeFalse())
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(nodeclassutil.Key{Name: "other", IsNodeTemplate: true}, "m5.large")).To(BeFalse())
+			Expect(awsEnv.BootstrapFailuresCache.Excluded(key)["m5.large"].Reason).To(HavePrefix("node didn't register within "))
+		})
+		// deletedByCore deletes the machine the way karpenter-core does once its registration TTL expires, while the
+		// termination finalizer keeps it around to be reconciled
+		deletedByCore := func(m *v1alpha5.Machine, age time.Duration) {
+			m.Finalizers = []string{v1alpha5.TerminationFinalizer}
+			m.StatusConditions().MarkUnknown(v1alpha5.MachineRegistered, "", "")
+			for i := range m.Status.Conditions {
+				m.Status.Conditions[i].LastTransitionTime.Inner = metav1.NewTime(time.Now().Add(-age))
+			}
+			ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, m)
+			Expect(env.Client.Delete(ctx, m)).To(Succeed())
+		}
+		It("should count machines that karpenter-core deletes for not registering once they're deleted", func() {
+			nodeTemplate.Spec.RegistrationTTL = nil
+			ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+			fakeClock.Step(14 * time.Minute)
+			ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+			Expect(recorder.Calls("RegistrationFailed")).To(Equal(1))
+			for i := 0; i < awscache.BootstrapFailureThreshold-1; i++ {
+				m := machineOf("m5.large")
+				deletedByCore(m, 16*time.Minute)
+				ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(m))
+			}
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.large")).To(BeFalse())
+			m := machineOf("m5.large")
+			deletedByCore(m, 16*time.Minute)
+			ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(m))
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.large")).To(BeTrue())
+		})
+		It("should only count each machine that fails to register once", func() {
+			nodeTemplate.Spec.RegistrationTTL = nil
+			deletedByCore(machine, 16*time.Minute)
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(machine))
+			}
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.large")).To(BeFalse())
+		})
+		It("should not count machines that are deleted before their registration TTL expires", func() {
+			nodeTemplate.Spec.RegistrationTTL = nil
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				m := machineOf("m5.large")
+				deletedByCore(m, 10*time.Minute)
+				ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(m))
+			}
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.large")).To(BeFalse())
+		})
+		It("should forget the registration failures of an instance type once one of its nodes registers", func() {
+			for i := 0; i < awscache.BootstrapFailureThreshold-1; i++ {
+				failToRegister(machineOf("m5.large"))
+			}
+			registered := machineOf("m5.large")
+			registered.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+			ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, registered)
+			ExpectReconcileSucceeded(ctx, livenessController, client.ObjectKeyFromObject(registered))
+			failToRegister(machineOf("m5.large"))
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.large")).To(BeFalse())
+		})
+	})
+	Context("NodeClaims", func() {
+		var nodeClass *v1beta1.NodeClass
+		var nodeClaim *corev1beta1.NodeClaim
+		BeforeEach(func() {
+			nodeClass = test.NodeClass(v1beta1.NodeClass{
+				Spec: v1beta1.NodeClassSpec{RegistrationTTL: &metav1.Duration{Duration: 5 * time.Minute}},
+			})
+			nodeClaim = coretest.NodeClaim(corev1beta1.NodeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						corev1beta1.NodePoolLabelKey: "default",
+						v1.LabelInstanceTypeStable:   "m5.large",
+					},
+				},
+				Spec: corev1beta1.NodeClaimSpec{
+					NodeClass: &corev1beta1.NodeClassReference{Name: nodeClass.Name},
+				},
+			})
+			nodeClaim.Status.ProviderID = fake.ProviderID(fake.InstanceID())
+			nodeClaim.StatusConditions().MarkTrue(corev1beta1.NodeLaunched)
+			nodeClaim.StatusConditions().MarkUnknown(corev1beta1.NodeRegistered, "", "")
+		})
+		It("should delete nodeclaims that don't register within the registration TTL of their node class", func() {
+			ExpectApplied(ctx, env.Client, nodeClass, nodeClaim)
+			fakeClock.Step(6 * time.Minute)
+			ExpectReconcileSucceeded(ctx, nodeClaimLivenessController, client.ObjectKeyFromObject(nodeClaim))
+			ExpectNotFound(ctx, env.Client, nodeClaim)
+			Expect(registrationFailedMessage()).To(ContainSubstring("no console output is available"))
+		})
+		It("should count nodeclaims that fail to register against their instance type", func() {
+			ExpectApplied(ctx, env.Client, nodeClass)
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				nc := nodeClaim.DeepCopy()
+				nc.Name = coretest.RandomName()
+				nc.Status.ProviderID = fake.ProviderID(fake.InstanceID())
+				ExpectApplied(ctx, env.Client, nc)
+				fakeClock.Step(6 * time.Minute)
+				ExpectReconcileSucceeded(ctx, nodeClaimLivenessController, client.ObjectKeyFromObject(nc))
+				ExpectNotFound(ctx, env.Client, nc)
+			}
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(nodeclassutil.Key{Name: nodeClass.Name}, "m5.large")).To(BeTrue())
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(nodeclassutil.Key{Name: nodeClass.Name, IsNodeTemplate: true}, "m5.large")).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,181 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadataoptions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/metrics"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+const (
+	statusCompliant             = "compliant"
+	statusIMDSv1Accessible      = "imdsv1_accessible"
+	statusHopLimitMisconfigured = "hop_limit_misconfigured"
+)
+
+// Controller audits the instance metadata service (IMDS) settings of the instances launched by Karpenter against the
+// metadataOptions of their node template, and optionally changes the settings of running instances to match
+type Controller struct {
+	kubeClient       client.Client
+	instanceProvider *instance.Provider
+}
+
+func NewController(kubeClient client.Client, instanceProvider *instance.Provider) *Controller {
+	return &Controller{
+		kubeClient:       kubeClient,
+		instanceProvider: instanceProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "machine.metadataoptions"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	machineList := &v1alpha5.MachineList{}
+	if err := c.kubeClient.List(ctx, machineList); err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing machines, %w", err)
+	}
+	instances, err := c.instanceProvider.List(ctx)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing instances, %w", err)
+	}
+	instancesByID := lo.SliceToMap(instances, func(i *instance.Instance) (string, *instance.Instance) { return i.ID, i })
+	policies := map[string]*v1beta1.MetadataOptions{}
+	compliance := map[complianceKey]int{}
+	var errs []error
+	for i := range machineList.Items {
+		machine := &machineList.Items[i]
+		id, err := utils.ParseInstanceID(machine.Status.ProviderID)
+		if err != nil {
+			continue
+		}
+		inst, ok := instancesByID[id]
+		if !ok || inst.MetadataOptions == nil || machine.Spec.MachineTemplateRef == nil {
+			continue
+		}
+		name := machine.Spec.MachineTemplateRef.Name
+		if _, ok := policies[name]; !ok {
+			policies[name] = c.resolvePolicy(ctx, name)
+		}
+		p := policies[name]
+		if p == nil {
+			continue
+		}
+		provisioner := machine.Labels[v1alpha5.ProvisionerNameLabelKey]
+		for _, status := range audit(inst.MetadataOptions, p) {
+			compliance[complianceKey{provisioner: provisioner, status: status}]++
+		}
+		if !settings.FromContext(ctx).EnforceMetadataOptions || matches(inst.MetadataOptions, p) {
+			continue
+		}
+		if err := c.instanceProvider.ModifyMetadataOptions(ctx, id, p); cloudprovider.IgnoreMachineNotFoundError(err) != nil {
+			errs = append(errs, fmt.Errorf("enforcing metadata options for instance %s, %w", id, err))
+			continue
+		}
+		logging.FromContext(ctx).With("machine", machine.Name, "id", id).Infof("enforced metadata options from node template")
+	}
+	// Reset so that series for provisioners that no longer have any machines are removed
+	Compliance.Reset()
+	for k, count := range compliance {
+		Compliance.With(prometheus.Labels{
+			metrics.ProvisionerLabel: k.provisioner,
+			statusLabel:              k.status,
+		}).Set(float64(count))
+	}
+	return reconcile.Result{RequeueAfter: 10 * time.Minute}, multierr.Combine(errs...)
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// resolvePolicy returns the metadata options that instances launched from the node template should have, or nil if
+// the node template doesn't exist or its instances are launched from a user-managed launch template
+func (c *Controller) resolvePolicy(ctx context.Context, name string) *v1beta1.MetadataOptions {
+	nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: name, IsNodeTemplate: true})
+	if err != nil || nodeClass.Spec.LaunchTemplateName != nil {
+		return nil
+	}
+	return policy(nodeClass)
+}
+
+// policy returns the metadata options for a node class, falling back to the defaults of the node class's AMI family
+// when metadataOptions isn't set, and to the defaults of EC2 for fields that aren't set. HTTPProtocolIPv6 is only
+// part of the policy when it is set explicitly, since its default depends on the IP family of the cluster.
+func policy(nodeClass *v1beta1.NodeClass) *v1beta1.MetadataOptions {
+	if nodeClass.Spec.MetadataOptions == nil {
+		defaults := amifamily.GetAMIFamily(nodeClass.Spec.AMIFamily, &amifamily.Options{}).DefaultMetadataOptions()
+		defaults.HTTPProtocolIPv6 = nil
+		return defaults
+	}
+	return &v1beta1.MetadataOptions{
+		HTTPEndpoint:            aws.String(lo.FromPtrOr(nodeClass.Spec.MetadataOptions.HTTPEndpoint, ec2.InstanceMetadataEndpointStateEnabled)),
+		HTTPProtocolIPv6:        nodeClass.Spec.MetadataOptions.HTTPProtocolIPv6,
+		HTTPPutResponseHopLimit: aws.Int64(lo.FromPtrOr(nodeClass.Spec.MetadataOptions.HTTPPutResponseHopLimit, 1)),
+		HTTPTokens:              aws.String(lo.FromPtrOr(nodeClass.Spec.MetadataOptions.HTTPTokens, ec2.HttpTokensStateOptional)),
+	}
+}
+
+// audit returns the compliance statuses of an instance's metadata options. An instance is either compliant, or has
+// one or more issues.
+func audit(actual, policy *v1beta1.MetadataOptions) []string {
+	var statuses []string
+	if aws.StringValue(actual.HTTPEndpoint) != ec2.InstanceMetadataEndpointStateDisabled && aws.StringValue(actual.HTTPTokens) != ec2.HttpTokensStateRequired {
+		statuses = append(statuses, statusIMDSv1Accessible)
+	}
+	if aws.Int64Value(actual.HTTPPutResponseHopLimit) != aws.Int64Value(policy.HTTPPutResponseHopLimit) {
+		statuses = append(statuses, statusHopLimitMisconfigured)
+	}
+	if len(statuses) == 0 {
+		return []string{statusCompliant}
+	}
+	return statuses
+}
+
+// matches returns whether an instance's metadata options match every field that the policy sets
+func matches(actual, policy *v1beta1.MetadataOptions) bool {
+	return aws.StringValue(actual.HTTPEndpoint) == aws.StringValue(policy.HTTPEndpoint) &&
+		(policy.HTTPProtocolIPv6 == nil || aws.StringValue(actual.HTTPProtocolIPv6) == aws.StringValue(policy.HTTPProtocolIPv6)) &&
+		aws.Int64Value(actual.HTTPPutResponseHopLimit) == aws.Int64Value(policy.HTTPPutResponseHopLimit) &&
+		aws.StringValue(actual.HTTPTokens) == aws.StringValue(policy.HTTPTokens)
+}
+
+type complianceKey struct {
+	provisioner string
+	status      string
+}
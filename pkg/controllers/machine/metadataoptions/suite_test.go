@@ -0,0 +1,199 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadataoptions_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/machine/metadataoptions"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var metadataOptionsController *metadataoptions.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineMetadataOptions")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+	metadataOptionsController = metadataoptions.NewController(env.Client, awsEnv.InstanceProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv.Reset()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineMetadataOptions", func() {
+	var provisioner *v1alpha5.Provisioner
+	var nodeTemplate *v1alpha1.AWSNodeTemplate
+
+	BeforeEach(func() {
+		nodeTemplate = test.AWSNodeTemplate()
+		provisioner = coretest.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+		})
+	})
+
+	// launch creates a machine and a backing instance with the given metadata options
+	launch := func(metadataOptions *ec2.InstanceMetadataOptionsResponse) (*v1alpha5.Machine, string) {
+		instanceID := fake.InstanceID()
+		awsEnv.EC2API.Instances.Store(instanceID, &ec2.Instance{
+			State: &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			Tags: []*ec2.Tag{
+				{Key: aws.String(fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName)), Value: aws.String("owned")},
+				{Key: aws.String(v1alpha5.ProvisionerNameLabelKey), Value: aws.String(provisioner.Name)},
+			},
+			Placement:       &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+			InstanceId:      aws.String(instanceID),
+			InstanceType:    aws.String("m5.large"),
+			MetadataOptions: metadataOptions,
+		})
+		machine := coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{v1alpha5.ProvisionerNameLabelKey: provisioner.Name},
+			},
+			Spec: v1alpha5.MachineSpec{
+				MachineTemplateRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+			},
+		})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		machine.Status.ProviderID = fake.ProviderID(instanceID)
+		ExpectApplied(ctx, env.Client, machine)
+		return machine, instanceID
+	}
+	expectCompliance := func(status string, count int) {
+		metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_instance_metadata_compliance", map[string]string{
+			"provisioner": provisioner.Name,
+			"status":      status,
+		})
+		Expect(ok).To(BeTrue())
+		Expect(metric.GetGauge().GetValue()).To(BeNumerically("==", count))
+	}
+	metadataOptionsOf := func(instanceID string) *ec2.InstanceMetadataOptionsResponse {
+		raw, ok := awsEnv.EC2API.Instances.Load(instanceID)
+		Expect(ok).To(BeTrue())
+		return raw.(*ec2.Instance).MetadataOptions
+	}
+
+	It("should report instances that match the default policy as compliant", func() {
+		launch(&ec2.InstanceMetadataOptionsResponse{
+			HttpEndpoint:            aws.String(ec2.InstanceMetadataEndpointStateEnabled),
+			HttpPutResponseHopLimit: aws.Int64(2),
+			HttpTokens:              aws.String(ec2.HttpTokensStateRequired),
+		})
+		ExpectReconcileSucceeded(ctx, metadataOptionsController, client.ObjectKey{})
+		expectCompliance("compliant", 1)
+	})
+	It("should report instances that accept IMDSv1 and have a misconfigured hop limit", func() {
+		launch(&ec2.InstanceMetadataOptionsResponse{
+			HttpEndpoint:            aws.String(ec2.InstanceMetadataEndpointStateEnabled),
+			HttpPutResponseHopLimit: aws.Int64(1),
+			HttpTokens:              aws.String(ec2.HttpTokensStateOptional),
+		})
+		launch(&ec2.InstanceMetadataOptionsResponse{
+			HttpEndpoint:            aws.String(ec2.InstanceMetadataEndpointStateEnabled),
+			HttpPutResponseHopLimit: aws.Int64(2),
+			HttpTokens:              aws.String(ec2.HttpTokensStateOptional),
+		})
+		ExpectReconcileSucceeded(ctx, metadataOptionsController, client.ObjectKey{})
+		expectCompliance("imdsv1_accessible", 2)
+		expectCompliance("hop_limit_misconfigured", 1)
+		Expect(awsEnv.EC2API.Calls.Get("ModifyInstanceMetadataOptions")).To(BeZero())
+	})
+	It("should audit hop limits against the node template's metadata options", func() {
+		nodeTemplate.Spec.MetadataOptions = &v1alpha1.MetadataOptions{
+			HTTPPutResponseHopLimit: aws.Int64(1),
+			HTTPTokens:              aws.String(ec2.HttpTokensStateRequired),
+		}
+		launch(&ec2.InstanceMetadataOptionsResponse{
+			HttpEndpoint:            aws.String(ec2.InstanceMetadataEndpointStateEnabled),
+			HttpPutResponseHopLimit: aws.Int64(1),
+			HttpTokens:              aws.String(ec2.HttpTokensStateRequired),
+		})
+		ExpectReconcileSucceeded(ctx, metadataOptionsController, client.ObjectKey{})
+		expectCompliance("compliant", 1)
+	})
+	It("should enforce the node template's metadata options when enabled", func() {
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnforceMetadataOptions: lo.ToPtr(true)}))
+		_, instanceID := launch(&ec2.InstanceMetadataOptionsResponse{
+			HttpEndpoint:            aws.String(ec2.InstanceMetadataEndpointStateEnabled),
+			HttpPutResponseHopLimit: aws.Int64(1),
+			HttpTokens:              aws.String(ec2.HttpTokensStateOptional),
+		})
+		ExpectReconcileSucceeded(ctx, metadataOptionsController, client.ObjectKey{})
+		Expect(awsEnv.EC2API.Calls.Get("ModifyInstanceMetadataOptions")).To(Equal(1))
+		Expect(aws.StringValue(metadataOptionsOf(instanceID).HttpTokens)).To(Equal(ec2.HttpTokensStateRequired))
+		Expect(aws.Int64Value(metadataOptionsOf(instanceID).HttpPutResponseHopLimit)).To(BeNumerically("==", 2))
+
+		// Compliant instances aren't modified again
+		ExpectReconcileSucceeded(ctx, metadataOptionsController, client.ObjectKey{})
+		Expect(awsEnv.EC2API.Calls.Get("ModifyInstanceMetadataOptions")).To(Equal(1))
+		expectCompliance("compliant", 1)
+	})
+	It("should not audit instances launched from a user-managed launch template", func() {
+		nodeTemplate.Spec.LaunchTemplateName = aws.String("my-launch-template")
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnforceMetadataOptions: lo.ToPtr(true)}))
+		launch(&ec2.InstanceMetadataOptionsResponse{
+			HttpEndpoint:            aws.String(ec2.InstanceMetadataEndpointStateEnabled),
+			HttpPutResponseHopLimit: aws.Int64(1),
+			HttpTokens:              aws.String(ec2.HttpTokensStateOptional),
+		})
+		ExpectReconcileSucceeded(ctx, metadataOptionsController, client.ObjectKey{})
+		Expect(awsEnv.EC2API.Calls.Get("ModifyInstanceMetadataOptions")).To(BeZero())
+		_, ok := FindMetricWithLabelValues("karpenter_cloudprovider_instance_metadata_compliance", map[string]string{
+			"provisioner": provisioner.Name,
+		})
+		Expect(ok).To(BeFalse())
+	})
+})
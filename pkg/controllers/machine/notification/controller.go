@@ -0,0 +1,210 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/logging"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	machineutil "github.com/aws/karpenter-core/pkg/utils/machine"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/utils"
+)
+
+// Controller notifies external systems when the instances of Machines are launched, registered, drained, and
+// terminated. Delivery is at least once, so receivers should deduplicate on the event id, which is the same for every
+// delivery of an event type for a Machine.
+type Controller struct {
+	kubeClient client.Client
+	clk        clock.Clock
+	notifiers  []Notifier
+	startTime  time.Time
+
+	mu       sync.Mutex
+	machines map[string]*tracked // key: machine name
+}
+
+// tracked is the last seen state of a Machine and the events that were delivered for it. The Machine is needed to
+// describe its instance once the Machine is gone.
+type tracked struct {
+	machine  *v1alpha5.Machine
+	notified sets.Set[Type]
+}
+
+func NewController(kubeClient client.Client, clk clock.Clock, notifiers ...Notifier) *Controller {
+	return &Controller{
+		kubeClient: kubeClient,
+		clk:        clk,
+		notifiers:  notifiers,
+		startTime:  clk.Now(),
+		machines:   map[string]*tracked{},
+	}
+}
+
+func (c *Controller) Name() string {
+	return "machine.notification"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	machine := &v1alpha5.Machine{}
+	if err := c.kubeClient.Get(ctx, req.NamespacedName, machine); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, c.finalize(ctx, req.Name)
+		}
+		return reconcile.Result{}, err
+	}
+	t := c.track(machine)
+	for _, transition := range []struct {
+		eventType     Type
+		conditionType apis.ConditionType
+	}{
+		{eventType: Launched, conditionType: v1alpha5.MachineLaunched},
+		{eventType: Registered, conditionType: v1alpha5.MachineRegistered},
+	} {
+		condition := machine.StatusConditions().GetCondition(transition.conditionType)
+		if condition == nil || !condition.IsTrue() {
+			return reconcile.Result{}, nil
+		}
+		// Transitions that happened before this controller started were already notified, or can't be distinguished
+		// from ones that were, so they're skipped to avoid notifying every Machine again across restarts
+		if condition.LastTransitionTime.Inner.Time.Before(c.startTime) {
+			t.notified.Insert(transition.eventType)
+			continue
+		}
+		if err := c.notify(ctx, t, transition.eventType, condition.LastTransitionTime.Inner.Time); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	// The node is deleted once it's drained, which happens before the instance is terminated
+	if !machine.DeletionTimestamp.IsZero() {
+		nodes, err := machineutil.AllNodesForMachine(ctx, c.kubeClient, machine)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if len(nodes) == 0 {
+			return reconcile.Result{}, c.notify(ctx, t, Drained, c.clk.Now())
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// finalize notifies that the instance of a Machine that is gone was terminated, which happens right before the
+// Machine's finalizer is removed
+func (c *Controller) finalize(ctx context.Context, name string) error {
+	c.mu.Lock()
+	t, ok := c.machines[name]
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if t.notified.Has(Registered) {
+		if err := c.notify(ctx, t, Drained, c.clk.Now()); err != nil {
+			return err
+		}
+	}
+	if t.notified.Has(Launched) {
+		if err := c.notify(ctx, t, Terminated, c.clk.Now()); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	delete(c.machines, name)
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Controller) track(machine *v1alpha5.Machine) *tracked {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.machines[machine.Name]
+	// A Machine with the same name may have been created again before the deletion of the previous one was observed
+	if !ok || t.machine.UID != machine.UID {
+		t = &tracked{notified: sets.New[Type]()}
+		c.machines[machine.Name] = t
+	}
+	t.machine = machine
+	return t
+}
+
+// notify delivers the event to every notifier, unless it was already delivered for the Machine
+func (c *Controller) notify(ctx context.Context, t *tracked, eventType Type, at time.Time) error {
+	if t.notified.Has(eventType) {
+		return nil
+	}
+	event := NewEvent(ctx, t.machine, eventType, at)
+	var errs error
+	for _, notifier := range c.notifiers {
+		errs = multierr.Append(errs, notifier.Notify(ctx, event))
+	}
+	if errs != nil {
+		return fmt.Errorf("notifying %s, %w", eventType, errs)
+	}
+	logging.FromContext(ctx).With("id", event.ID).Debugf("notified %s", eventType)
+	t.notified.Insert(eventType)
+	return nil
+}
+
+func (c *Controller) Builder(ctx context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(&v1alpha5.Machine{}).
+		Watches(
+			&source.Kind{Type: &v1.Node{}},
+			machineutil.NodeEventHandler(ctx, c.kubeClient),
+		))
+}
+
+// NewEvent returns the lifecycle event of the given type for a Machine
+func NewEvent(ctx context.Context, machine *v1alpha5.Machine, eventType Type, at time.Time) Event {
+	instanceID, _ := utils.ParseInstanceID(machine.Status.ProviderID)
+	return Event{
+		SpecVersion:     "1.0",
+		ID:              fmt.Sprintf("%s-%s", machine.UID, eventType),
+		Source:          Source,
+		Type:            EventType(eventType),
+		Subject:         machine.Name,
+		Time:            at.UTC(),
+		DataContentType: "application/json",
+		Data: Data{
+			ClusterName:  settings.FromContext(ctx).ClusterName,
+			Machine:      machine.Name,
+			Provisioner:  machine.Labels[v1alpha5.ProvisionerNameLabelKey],
+			NodeName:     machine.Status.NodeName,
+			ProviderID:   machine.Status.ProviderID,
+			InstanceID:   instanceID,
+			InstanceType: machine.Labels[v1.LabelInstanceTypeStable],
+			Zone:         machine.Labels[v1.LabelTopologyZone],
+			CapacityType: machine.Labels[v1alpha5.LabelCapacityType],
+			Labels:       machine.Labels,
+		},
+	}
+}
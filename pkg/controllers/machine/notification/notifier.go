@@ -0,0 +1,151 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+)
+
+const (
+	// Source is the CloudEvents source of lifecycle events, and the source of the events put on EventBridge
+	Source = "karpenter.k8s.aws"
+	// ContentType is the content type of the requests sent to the lifecycle webhook, i.e. a structured CloudEvent
+	ContentType = "application/cloudevents+json"
+)
+
+type Type string
+
+const (
+	Launched   Type = "launched"
+	Registered Type = "registered"
+	Drained    Type = "drained"
+	Terminated Type = "terminated"
+)
+
+// EventType is the CloudEvents type of lifecycle events of the given type, e.g. aws.k8s.karpenter.instance.launched
+func EventType(t Type) string {
+	return fmt.Sprintf("aws.k8s.karpenter.instance.%s", t)
+}
+
+// Event is a CloudEvent (https://github.com/cloudevents/spec) describing a lifecycle transition of an instance
+// launched by Karpenter
+type Event struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            Data      `json:"data"`
+}
+
+// Data describes the machine and the instance that an Event is about
+type Data struct {
+	ClusterName  string            `json:"clusterName"`
+	Machine      string            `json:"machine"`
+	Provisioner  string            `json:"provisioner,omitempty"`
+	NodeName     string            `json:"nodeName,omitempty"`
+	ProviderID   string            `json:"providerID,omitempty"`
+	InstanceID   string            `json:"instanceID,omitempty"`
+	InstanceType string            `json:"instanceType,omitempty"`
+	Zone         string            `json:"zone,omitempty"`
+	CapacityType string            `json:"capacityType,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// Notifier delivers lifecycle events to an external system
+type Notifier interface {
+	Notify(context.Context, Event) error
+}
+
+// HTTPNotifier POSTs lifecycle events as structured CloudEvents to aws.lifecycleWebhookURL
+type HTTPNotifier struct {
+	client *http.Client
+}
+
+func NewHTTPNotifier(client *http.Client) *HTTPNotifier {
+	return &HTTPNotifier{client: client}
+}
+
+func (h *HTTPNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshaling event, %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, settings.FromContext(ctx).LifecycleWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request, %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting event, %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("posting event, unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// EventBridgeNotifier puts lifecycle events on the aws.lifecycleEventBusName event bus. The detail of each entry is
+// the CloudEvent, so that rules and targets see the same payload as the webhook.
+type EventBridgeNotifier struct {
+	client eventbridgeiface.EventBridgeAPI
+}
+
+func NewEventBridgeNotifier(client eventbridgeiface.EventBridgeAPI) *EventBridgeNotifier {
+	return &EventBridgeNotifier{client: client}
+}
+
+func (e *EventBridgeNotifier) Notify(ctx context.Context, event Event) error {
+	detail, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshaling event, %w", err)
+	}
+	out, err := e.client.PutEventsWithContext(ctx, &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{{
+			EventBusName: aws.String(settings.FromContext(ctx).LifecycleEventBusName),
+			Source:       aws.String(Source),
+			DetailType:   aws.String(event.Type),
+			Detail:       aws.String(string(detail)),
+			Time:         aws.Time(event.Time),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("putting event, %w", err)
+	}
+	if aws.Int64Value(out.FailedEntryCount) > 0 {
+		if len(out.Entries) > 0 {
+			return fmt.Errorf("putting event, %s: %s", aws.StringValue(out.Entries[0].ErrorCode), aws.StringValue(out.Entries[0].ErrorMessage))
+		}
+		return fmt.Errorf("putting event, entry failed")
+	}
+	return nil
+}
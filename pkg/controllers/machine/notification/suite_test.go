@@ -0,0 +1,286 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/controllers/machine/notification"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var fakeClock *clock.FakeClock
+var eventBridgeAPI *fake.EventBridgeAPI
+var server *httptest.Server
+var webhook *recorder
+var notificationController *notification.Controller
+
+// recorder is a lifecycle webhook that records the events it receives
+type recorder struct {
+	mu         sync.Mutex
+	events     []notification.Event
+	statusCode int
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.statusCode != http.StatusOK {
+		w.WriteHeader(r.statusCode)
+		return
+	}
+	Expect(req.Header.Get("Content-Type")).To(Equal(notification.ContentType))
+	event := notification.Event{}
+	Expect(json.NewDecoder(req.Body).Decode(&event)).To(Succeed())
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+	r.statusCode = http.StatusOK
+}
+
+func (r *recorder) SetStatusCode(statusCode int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statusCode = statusCode
+}
+
+func (r *recorder) Events() []notification.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]notification.Event{}, r.events...)
+}
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineNotification")
+}
+
+var _ = BeforeSuite(func() {
+	webhook = &recorder{}
+	server = httptest.NewServer(webhook)
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+		LifecycleWebhookURL:   lo.ToPtr(server.URL),
+		LifecycleEventBusName: lo.ToPtr("karpenter-lifecycle"),
+	}))
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	eventBridgeAPI = &fake.EventBridgeAPI{}
+})
+
+var _ = AfterSuite(func() {
+	server.Close()
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	webhook.Reset()
+	eventBridgeAPI.Reset()
+	fakeClock = clock.NewFakeClock(time.Now().Add(-time.Hour))
+	notificationController = notification.NewController(env.Client, fakeClock, notification.NewHTTPNotifier(server.Client()), notification.NewEventBridgeNotifier(eventBridgeAPI))
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineNotification", func() {
+	var provisioner *v1alpha5.Provisioner
+	var machine *v1alpha5.Machine
+	var node *v1.Node
+	var instanceID string
+
+	BeforeEach(func() {
+		provisioner = coretest.Provisioner()
+		instanceID = fake.InstanceID()
+		machine, node = coretest.MachineAndNode(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+					v1.LabelInstanceTypeStable:       "m5.large",
+					v1.LabelTopologyZone:             "test-zone-1a",
+					v1alpha5.LabelCapacityType:       v1alpha5.CapacityTypeSpot,
+				},
+			},
+			Status: v1alpha5.MachineStatus{
+				ProviderID: fake.ProviderID(instanceID),
+			},
+		})
+		machine.Status.NodeName = node.Name
+	})
+	eventTypes := func() []string {
+		return lo.Map(webhook.Events(), func(e notification.Event, _ int) string { return e.Type })
+	}
+	It("should notify when a machine is launched", func() {
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+
+		events := webhook.Events()
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].SpecVersion).To(Equal("1.0"))
+		Expect(events[0].ID).To(Equal(string(machine.UID) + "-launched"))
+		Expect(events[0].Source).To(Equal(notification.Source))
+		Expect(events[0].Type).To(Equal("aws.k8s.karpenter.instance.launched"))
+		Expect(events[0].Subject).To(Equal(machine.Name))
+		Expect(events[0].Data.ClusterName).To(Equal("test-cluster"))
+		Expect(events[0].Data.Machine).To(Equal(machine.Name))
+		Expect(events[0].Data.Provisioner).To(Equal(provisioner.Name))
+		Expect(events[0].Data.NodeName).To(Equal(node.Name))
+		Expect(events[0].Data.ProviderID).To(Equal(fake.ProviderID(instanceID)))
+		Expect(events[0].Data.InstanceID).To(Equal(instanceID))
+		Expect(events[0].Data.InstanceType).To(Equal("m5.large"))
+		Expect(events[0].Data.Zone).To(Equal("test-zone-1a"))
+		Expect(events[0].Data.CapacityType).To(Equal(v1alpha5.CapacityTypeSpot))
+
+		Expect(eventBridgeAPI.PutEventsBehavior.Calls()).To(Equal(1))
+		input := eventBridgeAPI.PutEventsBehavior.CalledWithInput.Pop()
+		Expect(input.Entries).To(HaveLen(1))
+		Expect(aws.StringValue(input.Entries[0].EventBusName)).To(Equal("karpenter-lifecycle"))
+		Expect(aws.StringValue(input.Entries[0].Source)).To(Equal(notification.Source))
+		Expect(aws.StringValue(input.Entries[0].DetailType)).To(Equal("aws.k8s.karpenter.instance.launched"))
+		detail := notification.Event{}
+		Expect(json.Unmarshal([]byte(aws.StringValue(input.Entries[0].Detail)), &detail)).To(Succeed())
+		Expect(detail.ID).To(Equal(events[0].ID))
+		Expect(detail.Data).To(Equal(events[0].Data))
+	})
+	It("should notify when a machine is registered without notifying its launch again", func() {
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		Expect(eventTypes()).To(Equal([]string{"aws.k8s.karpenter.instance.launched", "aws.k8s.karpenter.instance.registered"}))
+		Expect(eventBridgeAPI.PutEventsBehavior.Calls()).To(Equal(2))
+	})
+	It("should not notify until a machine is launched", func() {
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		Expect(webhook.Events()).To(BeEmpty())
+		Expect(eventBridgeAPI.PutEventsBehavior.Calls()).To(BeZero())
+	})
+	It("should not notify transitions that happened before the controller started", func() {
+		notificationController = notification.NewController(env.Client, clock.NewFakeClock(time.Now().Add(time.Hour)), notification.NewHTTPNotifier(server.Client()))
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		Expect(webhook.Events()).To(BeEmpty())
+	})
+	It("should notify when the node of a deleting machine is drained", func() {
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		ExpectApplied(ctx, env.Client, provisioner, machine, node)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		ExpectDeletionTimestampSet(ctx, env.Client, machine)
+
+		// The node is still draining
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		Expect(eventTypes()).To(Equal([]string{"aws.k8s.karpenter.instance.launched", "aws.k8s.karpenter.instance.registered"}))
+
+		ExpectDeleted(ctx, env.Client, node)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		Expect(eventTypes()).To(Equal([]string{"aws.k8s.karpenter.instance.launched", "aws.k8s.karpenter.instance.registered", "aws.k8s.karpenter.instance.drained"}))
+	})
+	It("should notify when the instance of a machine is terminated", func() {
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		ExpectDeleted(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+
+		events := webhook.Events()
+		Expect(eventTypes()).To(Equal([]string{
+			"aws.k8s.karpenter.instance.launched",
+			"aws.k8s.karpenter.instance.registered",
+			"aws.k8s.karpenter.instance.drained",
+			"aws.k8s.karpenter.instance.terminated",
+		}))
+		Expect(events[3].ID).To(Equal(string(machine.UID) + "-terminated"))
+		Expect(events[3].Data.InstanceID).To(Equal(instanceID))
+		Expect(events[3].Time).To(BeTemporally("~", fakeClock.Now(), time.Second))
+
+		// The machine is forgotten once its termination is notified
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		Expect(webhook.Events()).To(HaveLen(4))
+	})
+	It("should not notify the termination of machines that were never launched", func() {
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		ExpectDeleted(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		Expect(webhook.Events()).To(BeEmpty())
+	})
+	It("should retry events that the webhook rejects", func() {
+		webhook.SetStatusCode(http.StatusServiceUnavailable)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileFailed(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		Expect(webhook.Events()).To(BeEmpty())
+
+		webhook.SetStatusCode(http.StatusOK)
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		Expect(eventTypes()).To(Equal([]string{"aws.k8s.karpenter.instance.launched"}))
+	})
+	It("should retry events that EventBridge fails to put", func() {
+		eventBridgeAPI.PutEventsBehavior.Output.Set(&eventbridge.PutEventsOutput{
+			FailedEntryCount: aws.Int64(1),
+			Entries:          []*eventbridge.PutEventsResultEntry{{ErrorCode: aws.String("InternalFailure"), ErrorMessage: aws.String("internal failure")}},
+		})
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileFailed(ctx, notificationController, client.ObjectKeyFromObject(machine))
+
+		eventBridgeAPI.PutEventsBehavior.Output.Reset()
+		ExpectReconcileSucceeded(ctx, notificationController, client.ObjectKeyFromObject(machine))
+		Expect(eventBridgeAPI.PutEventsBehavior.Calls()).To(Equal(2))
+	})
+})
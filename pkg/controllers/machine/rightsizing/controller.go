@@ -0,0 +1,124 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rightsizing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/metrics"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/providers/computeoptimizer"
+	"github.com/aws/karpenter/pkg/utils"
+)
+
+// Controller periodically refreshes the AWS Compute Optimizer recommendations for the instances launched by Karpenter
+// and surfaces them as Machine annotations and metrics, so that instance types that are consistently overprovisioned
+// for their workloads can be identified
+type Controller struct {
+	kubeClient               client.Client
+	computeOptimizerProvider *computeoptimizer.Provider
+}
+
+func NewController(kubeClient client.Client, computeOptimizerProvider *computeoptimizer.Provider) *Controller {
+	return &Controller{
+		kubeClient:               kubeClient,
+		computeOptimizerProvider: computeOptimizerProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "machine.rightsizing"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	if err := c.computeOptimizerProvider.Update(ctx); err != nil {
+		return reconcile.Result{}, fmt.Errorf("updating compute optimizer recommendations, %w", err)
+	}
+	machineList := &v1alpha5.MachineList{}
+	if err := c.kubeClient.List(ctx, machineList); err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing machines, %w", err)
+	}
+	findings := map[findingKey]int{}
+	var errs []error
+	for i := range machineList.Items {
+		machine := &machineList.Items[i]
+		id, err := utils.ParseInstanceID(machine.Status.ProviderID)
+		if err != nil {
+			continue
+		}
+		recommendation, ok := c.computeOptimizerProvider.Get(id)
+		if !ok {
+			continue
+		}
+		findings[findingKey{
+			provisioner:  machine.Labels[v1alpha5.ProvisionerNameLabelKey],
+			instanceType: recommendation.CurrentInstanceType,
+			finding:      recommendation.Finding,
+		}]++
+		if err := c.annotate(ctx, machine, recommendation); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// Reset so that series for findings that no longer apply to any machine are removed
+	Findings.Reset()
+	for k, count := range findings {
+		Findings.With(prometheus.Labels{
+			metrics.ProvisionerLabel: k.provisioner,
+			instanceTypeLabel:        k.instanceType,
+			findingLabel:             k.finding,
+		}).Set(float64(count))
+	}
+	return reconcile.Result{RequeueAfter: 6 * time.Hour}, multierr.Combine(errs...)
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+func (c *Controller) annotate(ctx context.Context, machine *v1alpha5.Machine, recommendation computeoptimizer.Recommendation) error {
+	stored := machine.DeepCopy()
+	machine.Annotations = lo.Assign(machine.Annotations, map[string]string{
+		v1alpha1.AnnotationComputeOptimizerFinding:                 recommendation.Finding,
+		v1alpha1.AnnotationComputeOptimizerRecommendedInstanceType: recommendation.RecommendedInstanceType,
+	})
+	if recommendation.RecommendedInstanceType == "" {
+		delete(machine.Annotations, v1alpha1.AnnotationComputeOptimizerRecommendedInstanceType)
+	}
+	if equality.Semantic.DeepEqual(stored, machine) {
+		return nil
+	}
+	if err := c.kubeClient.Patch(ctx, machine, client.MergeFrom(stored)); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("patching machine %s, %w", machine.Name, err)
+	}
+	return nil
+}
+
+type findingKey struct {
+	provisioner  string
+	instanceType string
+	finding      string
+}
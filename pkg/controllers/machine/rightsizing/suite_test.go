@@ -0,0 +1,128 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rightsizing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/computeoptimizer"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/machine/rightsizing"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var rightsizingController *rightsizing.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineRightsizing")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+	rightsizingController = rightsizing.NewController(env.Client, awsEnv.ComputeOptimizerProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineRightsizing", func() {
+	var provisioner *v1alpha5.Provisioner
+	var machine *v1alpha5.Machine
+
+	BeforeEach(func() {
+		provisioner = coretest.Provisioner()
+		machine = coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+					v1.LabelInstanceTypeStable:       "m5.2xlarge",
+				},
+			},
+			Status: v1alpha5.MachineStatus{
+				ProviderID: fake.ProviderID("i-0123456789abcdef0"),
+			},
+		})
+	})
+	It("should annotate machines with their findings and recommended instance types", func() {
+		awsEnv.ComputeOptimizerAPI.GetEC2InstanceRecommendationsOutput.Set(&computeoptimizer.GetEC2InstanceRecommendationsOutput{
+			InstanceRecommendations: []*computeoptimizer.InstanceRecommendation{
+				{
+					InstanceArn:         aws.String("arn:aws:ec2:us-west-2:111122223333:instance/i-0123456789abcdef0"),
+					CurrentInstanceType: aws.String("m5.2xlarge"),
+					Finding:             aws.String(computeoptimizer.FindingOverprovisioned),
+					RecommendationOptions: []*computeoptimizer.InstanceRecommendationOption{
+						{InstanceType: aws.String("m5.xlarge"), Rank: aws.Int64(1)},
+					},
+				},
+			},
+		})
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, rightsizingController, client.ObjectKey{})
+
+		machine = ExpectExists(ctx, env.Client, machine)
+		Expect(machine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationComputeOptimizerFinding, computeoptimizer.FindingOverprovisioned))
+		Expect(machine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationComputeOptimizerRecommendedInstanceType, "m5.xlarge"))
+
+		metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_compute_optimizer_findings", map[string]string{
+			"provisioner":   provisioner.Name,
+			"instance_type": "m5.2xlarge",
+			"finding":       computeoptimizer.FindingOverprovisioned,
+		})
+		Expect(ok).To(BeTrue())
+		Expect(metric.GetGauge().GetValue()).To(BeNumerically("==", 1))
+	})
+	It("should not annotate machines without a recommendation", func() {
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, rightsizingController, client.ObjectKey{})
+
+		machine = ExpectExists(ctx, env.Client, machine)
+		Expect(machine.Annotations).ToNot(HaveKey(v1alpha1.AnnotationComputeOptimizerFinding))
+	})
+})
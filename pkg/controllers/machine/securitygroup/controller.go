@@ -0,0 +1,148 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package securitygroup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/events"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// Controller updates the security groups of nodes whose node template has securityGroupDriftRemediation set to
+// InPlace, so that they match the node template's status instead of the nodes being replaced as drifted. Only the
+// primary network interface is updated.
+type Controller struct {
+	kubeClient       client.Client
+	clk              clock.Clock
+	recorder         events.Recorder
+	instanceProvider *instance.Provider
+}
+
+func NewController(kubeClient client.Client, clk clock.Clock, recorder events.Recorder, instanceProvider *instance.Provider) *Controller {
+	return &Controller{
+		kubeClient:       kubeClient,
+		clk:              clk,
+		recorder:         recorder,
+		instanceProvider: instanceProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "machine.securitygroup"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	// Security groups are only remediated instead of drifted nodes being replaced
+	if !coresettings.FromContext(ctx).DriftEnabled {
+		return reconcile.Result{RequeueAfter: 5 * time.Minute}, nil
+	}
+	machineList := &v1alpha5.MachineList{}
+	if err := c.kubeClient.List(ctx, machineList); err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing machines, %w", err)
+	}
+	nodeClasses := map[string]*v1beta1.NodeClass{}
+	for i := range machineList.Items {
+		machine := &machineList.Items[i]
+		if machine.Spec.MachineTemplateRef == nil {
+			continue
+		}
+		name := machine.Spec.MachineTemplateRef.Name
+		if _, ok := nodeClasses[name]; !ok {
+			nodeClasses[name] = c.resolveNodeClass(ctx, name)
+		}
+	}
+	// Skip listing instances when no node template remediates in place
+	if lo.EveryBy(lo.Values(nodeClasses), func(nodeClass *v1beta1.NodeClass) bool { return nodeClass == nil }) {
+		return reconcile.Result{RequeueAfter: 5 * time.Minute}, nil
+	}
+	instances, err := c.instanceProvider.List(ctx)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing instances, %w", err)
+	}
+	instancesByID := lo.SliceToMap(instances, func(i *instance.Instance) (string, *instance.Instance) { return i.ID, i })
+	var errs []error
+	for i := range machineList.Items {
+		machine := &machineList.Items[i]
+		if machine.Spec.MachineTemplateRef == nil || nodeClasses[machine.Spec.MachineTemplateRef.Name] == nil {
+			continue
+		}
+		id, err := utils.ParseInstanceID(machine.Status.ProviderID)
+		if err != nil {
+			continue
+		}
+		inst, ok := instancesByID[id]
+		if !ok {
+			continue
+		}
+		if err := c.remediate(ctx, machine, inst, nodeClasses[machine.Spec.MachineTemplateRef.Name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return reconcile.Result{RequeueAfter: 5 * time.Minute}, multierr.Combine(errs...)
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// remediate updates the security groups of the instance's primary network interface if they don't match the
+// node class' status
+func (c *Controller) remediate(ctx context.Context, machine *v1alpha5.Machine, inst *instance.Instance, nodeClass *v1beta1.NodeClass) error {
+	securityGroupIDs := lo.Map(nodeClass.Status.SecurityGroups, func(sg v1beta1.SecurityGroup, _ int) string { return sg.ID })
+	if len(securityGroupIDs) == 0 || sets.New(securityGroupIDs...).Equal(sets.New(inst.SecurityGroupIDs...)) {
+		return nil
+	}
+	if err := c.instanceProvider.ModifySecurityGroups(ctx, inst, securityGroupIDs); err != nil {
+		if cloudprovider.IsMachineNotFoundError(err) {
+			return nil
+		}
+		c.recorder.Publish(SecurityGroupsUpdateFailed(machine, err))
+		return fmt.Errorf("updating security groups of instance %s, %w", inst.ID, err)
+	}
+	logging.FromContext(ctx).With("machine", machine.Name, "id", inst.ID, "security-groups", securityGroupIDs).Infof("updated security groups in place")
+	c.recorder.Publish(SecurityGroupsUpdated(machine, securityGroupIDs))
+	return nil
+}
+
+// resolveNodeClass returns the node template if its drifted security groups are remediated in place and one of its
+// maintenance windows is open, or nil otherwise
+func (c *Controller) resolveNodeClass(ctx context.Context, name string) *v1beta1.NodeClass {
+	nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: name, IsNodeTemplate: true})
+	if err != nil || nodeClass.Spec.LaunchTemplateName != nil ||
+		lo.FromPtr(nodeClass.Spec.SecurityGroupDriftRemediation) != v1beta1.SecurityGroupDriftRemediationInPlace ||
+		!nodeClass.Spec.InMaintenanceWindow(c.clk.Now()) {
+		return nil
+	}
+	return nodeClass
+}
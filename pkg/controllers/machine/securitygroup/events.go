@@ -0,0 +1,45 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package securitygroup
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/events"
+)
+
+func SecurityGroupsUpdated(machine *v1alpha5.Machine, securityGroupIDs []string) events.Event {
+	return events.Event{
+		InvolvedObject: machine,
+		Type:           v1.EventTypeNormal,
+		Reason:         "SecurityGroupsUpdated",
+		Message:        fmt.Sprintf("Updated security groups in place to %s", strings.Join(securityGroupIDs, ", ")),
+		DedupeValues:   []string{string(machine.UID), strings.Join(securityGroupIDs, ",")},
+	}
+}
+
+func SecurityGroupsUpdateFailed(machine *v1alpha5.Machine, err error) events.Event {
+	return events.Event{
+		InvolvedObject: machine,
+		Type:           v1.EventTypeWarning,
+		Reason:         "SecurityGroupsUpdateFailed",
+		Message:        fmt.Sprintf("Failed to update security groups in place, %s", err),
+		DedupeValues:   []string{string(machine.UID)},
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package securitygroup_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/machine/securitygroup"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var recorder *coretest.EventRecorder
+var fakeClock *clock.FakeClock
+var securityGroupController *securitygroup.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineSecurityGroup")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings(coresettings.Settings{DriftEnabled: true}))
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+	fakeClock = clock.NewFakeClock(time.Now())
+	recorder = coretest.NewEventRecorder()
+	securityGroupController = securitygroup.NewController(env.Client, fakeClock, recorder, awsEnv.InstanceProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	recorder.Reset()
+	fakeClock.SetTime(time.Now())
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineSecurityGroup", func() {
+	var provisioner *v1alpha5.Provisioner
+	var nodeTemplate *v1alpha1.AWSNodeTemplate
+	var validSecurityGroup string
+
+	BeforeEach(func() {
+		validSecurityGroup = fake.SecurityGroupID()
+		nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+			SecurityGroupDriftRemediation: aws.String(v1alpha1.SecurityGroupDriftRemediationInPlace),
+		})
+		nodeTemplate.Status.SecurityGroups = []v1alpha1.SecurityGroup{{ID: validSecurityGroup, Name: "test-securitygroup"}}
+		provisioner = coretest.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+		})
+	})
+
+	// launch creates a machine and a backing instance with a primary and a secondary network interface in the given
+	// security group
+	launch := func(securityGroupID string) {
+		instanceID := fake.InstanceID()
+		awsEnv.EC2API.Instances.Store(instanceID, &ec2.Instance{
+			State: &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			Tags: []*ec2.Tag{
+				{Key: aws.String(fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName)), Value: aws.String("owned")},
+				{Key: aws.String(v1alpha5.ProvisionerNameLabelKey), Value: aws.String(provisioner.Name)},
+			},
+			Placement:      &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+			InstanceId:     aws.String(instanceID),
+			InstanceType:   aws.String("m5.large"),
+			SecurityGroups: []*ec2.GroupIdentifier{{GroupId: aws.String(securityGroupID)}},
+			NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+				{NetworkInterfaceId: aws.String("eni-secondary"), Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(1)}},
+				{NetworkInterfaceId: aws.String("eni-primary"), Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)}},
+			},
+		})
+		machine := coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{v1alpha5.ProvisionerNameLabelKey: provisioner.Name},
+			},
+			Spec: v1alpha5.MachineSpec{
+				MachineTemplateRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+			},
+		})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine)
+		machine.Status.ProviderID = fake.ProviderID(instanceID)
+		ExpectApplied(ctx, env.Client, machine)
+	}
+
+	It("should update the security groups of the primary network interface only", func() {
+		launch(fake.SecurityGroupID())
+		ExpectReconcileSucceeded(ctx, securityGroupController, client.ObjectKey{})
+		Expect(awsEnv.EC2API.ModifyNetworkInterfaceAttributeBehavior.CalledWithInput.Len()).To(Equal(1))
+		input := awsEnv.EC2API.ModifyNetworkInterfaceAttributeBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValue(input.NetworkInterfaceId)).To(Equal("eni-primary"))
+		Expect(aws.StringValueSlice(input.Groups)).To(ConsistOf(validSecurityGroup))
+		Expect(recorder.Calls("SecurityGroupsUpdated")).To(Equal(1))
+	})
+	It("should not update security groups that match the node template", func() {
+		launch(validSecurityGroup)
+		ExpectReconcileSucceeded(ctx, securityGroupController, client.ObjectKey{})
+		Expect(awsEnv.EC2API.ModifyNetworkInterfaceAttributeBehavior.Calls()).To(BeZero())
+	})
+	It("should not update security groups unless the node template remediates them in place", func() {
+		nodeTemplate.Spec.SecurityGroupDriftRemediation = nil
+		launch(fake.SecurityGroupID())
+		ExpectReconcileSucceeded(ctx, securityGroupController, client.ObjectKey{})
+		Expect(awsEnv.EC2API.ModifyNetworkInterfaceAttributeBehavior.Calls()).To(BeZero())
+	})
+	It("should not update security groups outside of the node template's maintenance windows", func() {
+		nodeTemplate.Spec.MaintenanceWindows = []v1alpha1.MaintenanceWindow{{
+			Start:    fakeClock.Now().UTC().Add(12 * time.Hour).Format("15:04"),
+			Duration: metav1.Duration{Duration: time.Hour},
+		}}
+		launch(fake.SecurityGroupID())
+		ExpectReconcileSucceeded(ctx, securityGroupController, client.ObjectKey{})
+		Expect(awsEnv.EC2API.ModifyNetworkInterfaceAttributeBehavior.Calls()).To(BeZero())
+	})
+	It("should not update security groups when drift is disabled", func() {
+		ctx = coresettings.ToContext(ctx, coretest.Settings())
+		DeferCleanup(func() {
+			ctx = coresettings.ToContext(ctx, coretest.Settings(coresettings.Settings{DriftEnabled: true}))
+		})
+		launch(fake.SecurityGroupID())
+		ExpectReconcileSucceeded(ctx, securityGroupController, client.ObjectKey{})
+		Expect(awsEnv.EC2API.ModifyNetworkInterfaceAttributeBehavior.Calls()).To(BeZero())
+	})
+	It("should publish an event and return an error when the security groups can't be updated", func() {
+		awsEnv.EC2API.ModifyNetworkInterfaceAttributeBehavior.Error.Set(fmt.Errorf("failed"))
+		launch(fake.SecurityGroupID())
+		ExpectReconcileFailed(ctx, securityGroupController, client.ObjectKey{})
+		Expect(recorder.Calls("SecurityGroupsUpdateFailed")).To(Equal(1))
+	})
+})
@@ -0,0 +1,163 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssmagent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/logging"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/utils"
+)
+
+// ConditionTypeSSMAgentRegistered is true once the SSM agent of a Machine's or NodeClaim's instance has registered
+// with Systems Manager and come online, so that a Session Manager session can be started on the node
+const ConditionTypeSSMAgentRegistered apis.ConditionType = "SSMAgentRegistered"
+
+const (
+	// unregisteredRequeueInterval is how often instances whose SSM agent isn't online are checked, since the agent
+	// usually registers within a few minutes of the instance launching
+	unregisteredRequeueInterval = time.Minute
+	// registrationTimeout is how long after launch instances are polled for, after which an agent that still isn't
+	// online is assumed to be misconfigured and is only checked again when the object changes
+	registrationTimeout = 30 * time.Minute
+)
+
+// Controller checks that the SSM agent of each launched instance has registered with Systems Manager, and reports it
+// in the SSMAgentRegistered condition of its Machine or NodeClaim. Break-glass access through Session Manager then
+// shows up as missing on the object rather than during an incident.
+type Controller struct {
+	kubeClient client.Client
+	clk        clock.Clock
+	ssmapi     ssmiface.SSMAPI
+}
+
+func NewController(kubeClient client.Client, clk clock.Clock, ssmapi ssmiface.SSMAPI) *Controller {
+	return &Controller{
+		kubeClient: kubeClient,
+		clk:        clk,
+		ssmapi:     ssmapi,
+	}
+}
+
+// Reconcile updates the SSMAgentRegistered condition of obj, whose conditions are managed by conditions. Registered
+// agents aren't checked again, so the instances of a large cluster don't keep polling Systems Manager.
+func (c *Controller) Reconcile(ctx context.Context, obj client.Object, conditions apis.ConditionManager, launched *apis.Condition, providerID string) (reconcile.Result, error) {
+	if launched == nil || !launched.IsTrue() || !obj.GetDeletionTimestamp().IsZero() {
+		return reconcile.Result{}, nil
+	}
+	if conditions.GetCondition(ConditionTypeSSMAgentRegistered).IsTrue() {
+		return reconcile.Result{}, nil
+	}
+	id, err := utils.ParseInstanceID(providerID)
+	if err != nil {
+		return reconcile.Result{}, nil
+	}
+	out, err := c.ssmapi.DescribeInstanceInformationWithContext(ctx, &ssm.DescribeInstanceInformationInput{
+		Filters: []*ssm.InstanceInformationStringFilter{{Key: aws.String("InstanceIds"), Values: aws.StringSlice([]string{id})}},
+	})
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("describing ssm instance information, %w", err)
+	}
+	stored := obj.DeepCopyObject().(client.Object)
+	info, ok := lo.Find(out.InstanceInformationList, func(i *ssm.InstanceInformation) bool { return aws.StringValue(i.InstanceId) == id })
+	switch {
+	case !ok:
+		conditions.MarkFalse(ConditionTypeSSMAgentRegistered, "NotRegistered", "SSM agent hasn't registered the instance with Systems Manager")
+	case aws.StringValue(info.PingStatus) != ssm.PingStatusOnline:
+		conditions.MarkFalse(ConditionTypeSSMAgentRegistered, aws.StringValue(info.PingStatus), "SSM agent is registered but its ping status is %s", aws.StringValue(info.PingStatus))
+	default:
+		conditions.MarkTrue(ConditionTypeSSMAgentRegistered)
+	}
+	registered := conditions.GetCondition(ConditionTypeSSMAgentRegistered).IsTrue()
+	if !equality.Semantic.DeepEqual(stored, obj) {
+		if err = c.kubeClient.Status().Patch(ctx, obj, client.MergeFrom(stored)); err != nil {
+			return reconcile.Result{}, client.IgnoreNotFound(fmt.Errorf("patching status, %w", err))
+		}
+		logging.FromContext(ctx).With("id", id, "registered", registered).Debugf("updated ssm agent registration")
+	}
+	if registered || c.clk.Since(launched.LastTransitionTime.Inner.Time) >= registrationTimeout {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: unregisteredRequeueInterval}, nil
+}
+
+var _ corecontroller.TypedController[*v1alpha5.Machine] = (*MachineController)(nil)
+
+type MachineController struct {
+	*Controller
+}
+
+func NewMachineController(kubeClient client.Client, clk clock.Clock, ssmapi ssmiface.SSMAPI) corecontroller.Controller {
+	return corecontroller.Typed[*v1alpha5.Machine](kubeClient, &MachineController{
+		Controller: NewController(kubeClient, clk, ssmapi),
+	})
+}
+
+func (c *MachineController) Name() string {
+	return "machine.ssmagent"
+}
+
+func (c *MachineController) Reconcile(ctx context.Context, machine *v1alpha5.Machine) (reconcile.Result, error) {
+	return c.Controller.Reconcile(ctx, machine, machine.StatusConditions(), machine.StatusConditions().GetCondition(v1alpha5.MachineLaunched), machine.Status.ProviderID)
+}
+
+func (c *MachineController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(&v1alpha5.Machine{}))
+}
+
+var _ corecontroller.TypedController[*corev1beta1.NodeClaim] = (*NodeClaimController)(nil)
+
+type NodeClaimController struct {
+	*Controller
+}
+
+func NewNodeClaimController(kubeClient client.Client, clk clock.Clock, ssmapi ssmiface.SSMAPI) corecontroller.Controller {
+	return corecontroller.Typed[*corev1beta1.NodeClaim](kubeClient, &NodeClaimController{
+		Controller: NewController(kubeClient, clk, ssmapi),
+	})
+}
+
+func (c *NodeClaimController) Name() string {
+	return "nodeclaim.ssmagent"
+}
+
+func (c *NodeClaimController) Reconcile(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) (reconcile.Result, error) {
+	return c.Controller.Reconcile(ctx, nodeClaim, nodeClaim.StatusConditions(), nodeClaim.StatusConditions().GetCondition(corev1beta1.NodeLaunched), nodeClaim.Status.ProviderID)
+}
+
+func (c *NodeClaimController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(&corev1beta1.NodeClaim{}))
+}
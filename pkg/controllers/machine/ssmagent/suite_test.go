@@ -0,0 +1,182 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssmagent_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/controllers/machine/ssmagent"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var ssmapi *fake.SSMAPI
+var fakeClock *clock.FakeClock
+var ssmAgentController controller.Controller
+var nodeClaimSSMAgentController controller.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineSSMAgent")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ssmapi = &fake.SSMAPI{}
+	fakeClock = clock.NewFakeClock(time.Now())
+	ssmAgentController = ssmagent.NewMachineController(env.Client, fakeClock, ssmapi)
+	nodeClaimSSMAgentController = ssmagent.NewNodeClaimController(env.Client, fakeClock, ssmapi)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	ssmapi.Reset()
+	fakeClock.SetTime(time.Now())
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineSSMAgent", func() {
+	var machine *v1alpha5.Machine
+
+	BeforeEach(func() {
+		machine = coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{v1alpha5.ProvisionerNameLabelKey: "default"},
+			},
+		})
+		machine.Status.ProviderID = fake.ProviderID("i-0123456789abcdef0")
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+	})
+	It("should mark the machine as registered when its agent is online", func() {
+		ssmapi.DescribeInstanceInformationOutput.Set(&ssm.DescribeInstanceInformationOutput{
+			InstanceInformationList: []*ssm.InstanceInformation{{InstanceId: aws.String("i-0123456789abcdef0"), PingStatus: aws.String(ssm.PingStatusOnline)}},
+		})
+		ExpectApplied(ctx, env.Client, machine)
+		result := ExpectReconcileSucceeded(ctx, ssmAgentController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeZero())
+		machine = ExpectExists(ctx, env.Client, machine)
+		Expect(machine.StatusConditions().GetCondition(ssmagent.ConditionTypeSSMAgentRegistered).IsTrue()).To(BeTrue())
+	})
+	It("should not check the agent again once it's registered", func() {
+		// the check would fail if the instance information was described again
+		ssmapi.DescribeInstanceInformationError.Set(fmt.Errorf("access denied"))
+		machine.StatusConditions().MarkTrue(ssmagent.ConditionTypeSSMAgentRegistered)
+		ExpectApplied(ctx, env.Client, machine)
+		result := ExpectReconcileSucceeded(ctx, ssmAgentController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeZero())
+	})
+	It("should mark the machine as not registered when its agent hasn't registered", func() {
+		ssmapi.DescribeInstanceInformationOutput.Set(&ssm.DescribeInstanceInformationOutput{
+			InstanceInformationList: []*ssm.InstanceInformation{{InstanceId: aws.String("i-111122223333aaaa0"), PingStatus: aws.String(ssm.PingStatusOnline)}},
+		})
+		ExpectApplied(ctx, env.Client, machine)
+		result := ExpectReconcileSucceeded(ctx, ssmAgentController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).ToNot(BeZero())
+		machine = ExpectExists(ctx, env.Client, machine)
+		condition := machine.StatusConditions().GetCondition(ssmagent.ConditionTypeSSMAgentRegistered)
+		Expect(condition.IsFalse()).To(BeTrue())
+		Expect(condition.Reason).To(Equal("NotRegistered"))
+	})
+	It("should stop checking the agent once the registration times out", func() {
+		ExpectApplied(ctx, env.Client, machine)
+		fakeClock.Step(time.Hour)
+		result := ExpectReconcileSucceeded(ctx, ssmAgentController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeZero())
+		machine = ExpectExists(ctx, env.Client, machine)
+		Expect(machine.StatusConditions().GetCondition(ssmagent.ConditionTypeSSMAgentRegistered).IsFalse()).To(BeTrue())
+	})
+	It("should mark the machine as not registered when its agent lost its connection", func() {
+		ssmapi.DescribeInstanceInformationOutput.Set(&ssm.DescribeInstanceInformationOutput{
+			InstanceInformationList: []*ssm.InstanceInformation{{InstanceId: aws.String("i-0123456789abcdef0"), PingStatus: aws.String(ssm.PingStatusConnectionLost)}},
+		})
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, ssmAgentController, client.ObjectKeyFromObject(machine))
+		machine = ExpectExists(ctx, env.Client, machine)
+		condition := machine.StatusConditions().GetCondition(ssmagent.ConditionTypeSSMAgentRegistered)
+		Expect(condition.IsFalse()).To(BeTrue())
+		Expect(condition.Reason).To(Equal(ssm.PingStatusConnectionLost))
+	})
+	It("should not affect the readiness of the machine", func() {
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineInitialized)
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, ssmAgentController, client.ObjectKeyFromObject(machine))
+		machine = ExpectExists(ctx, env.Client, machine)
+		Expect(machine.StatusConditions().GetCondition(ssmagent.ConditionTypeSSMAgentRegistered).IsFalse()).To(BeTrue())
+		Expect(machine.StatusConditions().IsHappy()).To(BeTrue())
+	})
+	It("should ignore machines that haven't launched", func() {
+		machine.Status.Conditions = nil
+		ExpectApplied(ctx, env.Client, machine)
+		result := ExpectReconcileSucceeded(ctx, ssmAgentController, client.ObjectKeyFromObject(machine))
+		Expect(result.RequeueAfter).To(BeZero())
+		machine = ExpectExists(ctx, env.Client, machine)
+		Expect(machine.StatusConditions().GetCondition(ssmagent.ConditionTypeSSMAgentRegistered)).To(BeNil())
+	})
+	It("should return an error when the instance information can't be described", func() {
+		ssmapi.DescribeInstanceInformationError.Set(fmt.Errorf("access denied"))
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileFailed(ctx, ssmAgentController, client.ObjectKeyFromObject(machine))
+	})
+	It("should mark the nodeclaim as registered when its agent is online", func() {
+		ssmapi.DescribeInstanceInformationOutput.Set(&ssm.DescribeInstanceInformationOutput{
+			InstanceInformationList: []*ssm.InstanceInformation{{InstanceId: aws.String("i-0123456789abcdef0"), PingStatus: aws.String(ssm.PingStatusOnline)}},
+		})
+		nodeClaim := coretest.NodeClaim(corev1beta1.NodeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{corev1beta1.NodePoolLabelKey: "default"},
+			},
+		})
+		nodeClaim.Status.ProviderID = fake.ProviderID("i-0123456789abcdef0")
+		nodeClaim.StatusConditions().MarkTrue(corev1beta1.NodeLaunched)
+		ExpectApplied(ctx, env.Client, nodeClaim)
+		ExpectReconcileSucceeded(ctx, nodeClaimSSMAgentController, client.ObjectKeyFromObject(nodeClaim))
+		nodeClaim = ExpectExists(ctx, env.Client, nodeClaim)
+		Expect(nodeClaim.StatusConditions().GetCondition(ssmagent.ConditionTypeSSMAgentRegistered).IsTrue()).To(BeTrue())
+	})
+})
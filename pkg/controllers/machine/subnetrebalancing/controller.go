@@ -0,0 +1,239 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subnetrebalancing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/events"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/providers/subnet"
+	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// reservedIPAddresses is the number of IP addresses that AWS reserves in every subnet
+const reservedIPAddresses = 5
+
+// Controller gradually replaces nodes in subnets that are running out of IP addresses. Karpenter launches into the
+// subnet of a zone with the most available IP addresses, so the replacement of a node in an exhausted subnet uses the
+// IP addresses of a less used subnet in the same zone. Nodes are replaced by annotating their machine, which the cloud
+// provider reports as drifted, so that core's drift deprovisioning cordons the node and launches its replacement before
+// deleting it. At most one node per node template is replaced at a time, only once every other machine of the node
+// template is initialized, and only while its maintenance windows are open.
+type Controller struct {
+	kubeClient       client.Client
+	clk              clock.Clock
+	recorder         events.Recorder
+	subnetProvider   *subnet.Provider
+	instanceProvider *instance.Provider
+}
+
+func NewController(kubeClient client.Client, clk clock.Clock, recorder events.Recorder, subnetProvider *subnet.Provider, instanceProvider *instance.Provider) *Controller {
+	return &Controller{
+		kubeClient:       kubeClient,
+		clk:              clk,
+		recorder:         recorder,
+		subnetProvider:   subnetProvider,
+		instanceProvider: instanceProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "machine.subnetrebalancing"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	// Nodes are replaced through drift, so nothing would replace the machines that are annotated while it's disabled
+	if !coresettings.FromContext(ctx).DriftEnabled {
+		return reconcile.Result{RequeueAfter: 5 * time.Minute}, nil
+	}
+	machineList := &v1alpha5.MachineList{}
+	if err := c.kubeClient.List(ctx, machineList); err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing machines, %w", err)
+	}
+	instances, err := c.instanceProvider.List(ctx)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing instances, %w", err)
+	}
+	instancesByID := lo.SliceToMap(instances, func(i *instance.Instance) (string, *instance.Instance) { return i.ID, i })
+	machinesByNodeTemplate := lo.GroupBy(lo.Filter(lo.ToSlicePtr(machineList.Items), func(m *v1alpha5.Machine, _ int) bool {
+		return m.Spec.MachineTemplateRef != nil
+	}), func(m *v1alpha5.Machine) string { return m.Spec.MachineTemplateRef.Name })
+	var errs []error
+	for name, machines := range machinesByNodeTemplate {
+		if err := c.rebalance(logging.WithLogger(ctx, logging.FromContext(ctx).With("awsnodetemplate", name)), name, machines, instancesByID); err != nil {
+			errs = append(errs, fmt.Errorf("rebalancing subnets of node template %s, %w", name, err))
+		}
+	}
+	return reconcile.Result{RequeueAfter: 5 * time.Minute}, multierr.Combine(errs...)
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// rebalance replaces the node of at most one of the node template's machines that is in an exhausted subnet
+func (c *Controller) rebalance(ctx context.Context, name string, machines []*v1alpha5.Machine, instancesByID map[string]*instance.Instance) error {
+	// Wait for earlier replacements, and any other disruption, to settle before replacing another node
+	if lo.ContainsBy(machines, func(m *v1alpha5.Machine) bool {
+		_, rebalancing := m.Annotations[v1alpha1.AnnotationSubnetRebalancing]
+		return rebalancing || !m.DeletionTimestamp.IsZero() || !m.StatusConditions().IsHappy()
+	}) {
+		return nil
+	}
+	nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: name, IsNodeTemplate: true})
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !nodeClass.Spec.InMaintenanceWindow(c.clk.Now()) {
+		return nil
+	}
+	subnets, err := c.subnetProvider.List(ctx, nodeClass)
+	if err != nil {
+		return fmt.Errorf("listing subnets, %w", err)
+	}
+	exhausted := ExhaustedSubnets(subnets, settings.FromContext(ctx).SubnetRebalancingThreshold)
+	if len(exhausted) == 0 {
+		return nil
+	}
+	type candidate struct {
+		machine     *v1alpha5.Machine
+		instance    *instance.Instance
+		utilization float64
+	}
+	var candidates []candidate
+	for _, m := range machines {
+		id, err := utils.ParseInstanceID(m.Status.ProviderID)
+		if err != nil {
+			continue
+		}
+		if inst, ok := instancesByID[id]; ok {
+			if utilization, ok := exhausted[inst.SubnetID]; ok {
+				candidates = append(candidates, candidate{machine: m, instance: inst, utilization: utilization})
+			}
+		}
+	}
+	// Start with the most exhausted subnets, and the oldest nodes within a subnet
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].utilization != candidates[j].utilization {
+			return candidates[i].utilization > candidates[j].utilization
+		}
+		return candidates[i].instance.LaunchTime.Before(candidates[j].instance.LaunchTime)
+	})
+	for _, cand := range candidates {
+		evictable, err := c.isEvictable(ctx, cand.machine)
+		if err != nil {
+			return err
+		}
+		if !evictable {
+			continue
+		}
+		stored := cand.machine.DeepCopy()
+		cand.machine.Annotations = lo.Assign(cand.machine.Annotations, map[string]string{v1alpha1.AnnotationSubnetRebalancing: cand.instance.SubnetID})
+		if err := c.kubeClient.Patch(ctx, cand.machine, client.MergeFrom(stored)); err != nil {
+			return client.IgnoreNotFound(fmt.Errorf("annotating machine, %w", err))
+		}
+		logging.FromContext(ctx).With("machine", cand.machine.Name, "subnet", cand.instance.SubnetID, "utilization", cand.utilization).Infof("replacing node to rebalance subnets")
+		c.recorder.Publish(RebalancingSubnet(cand.machine, cand.instance.SubnetID, cand.utilization))
+		return nil
+	}
+	return nil
+}
+
+// isEvictable returns false if the machine's node, or any of the pods on it, opted out of voluntary disruption
+func (c *Controller) isEvictable(ctx context.Context, machine *v1alpha5.Machine) (bool, error) {
+	if machine.Status.NodeName == "" {
+		return false, nil
+	}
+	node := &v1.Node{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: machine.Status.NodeName}, node); err != nil {
+		return false, client.IgnoreNotFound(fmt.Errorf("getting node, %w", err))
+	}
+	if node.Annotations[corev1beta1.DoNotDisruptAnnotationKey] == "true" {
+		return false, nil
+	}
+	podList := &v1.PodList{}
+	if err := c.kubeClient.List(ctx, podList, client.MatchingFields{"spec.nodeName": machine.Status.NodeName}); err != nil {
+		return false, fmt.Errorf("listing pods on node, %w", err)
+	}
+	return !lo.ContainsBy(podList.Items, func(p v1.Pod) bool {
+		return p.Annotations[v1alpha5.DoNotEvictPodAnnotationKey] == "true"
+	}), nil
+}
+
+// ExhaustedSubnets returns the fraction of usable IP addresses that are in use in each subnet whose utilization is at or
+// above the threshold, and where another subnet in the same zone is below the threshold and has more available IP
+// addresses, so that nodes launched into the zone use the other subnet
+func ExhaustedSubnets(subnets []*ec2.Subnet, threshold float64) map[string]float64 {
+	utilizations := map[string]float64{}
+	for _, s := range subnets {
+		if u, ok := utilization(s); ok {
+			utilizations[aws.StringValue(s.SubnetId)] = u
+		}
+	}
+	exhausted := map[string]float64{}
+	for _, s := range subnets {
+		u, ok := utilizations[aws.StringValue(s.SubnetId)]
+		if !ok || u < threshold {
+			continue
+		}
+		if lo.ContainsBy(subnets, func(other *ec2.Subnet) bool {
+			o, ok := utilizations[aws.StringValue(other.SubnetId)]
+			return ok && o < threshold &&
+				aws.StringValue(other.AvailabilityZone) == aws.StringValue(s.AvailabilityZone) &&
+				aws.Int64Value(other.AvailableIpAddressCount) > aws.Int64Value(s.AvailableIpAddressCount)
+		}) {
+			exhausted[aws.StringValue(s.SubnetId)] = u
+		}
+	}
+	return exhausted
+}
+
+// utilization returns the fraction of a subnet's usable IPv4 addresses that are in use
+func utilization(s *ec2.Subnet) (float64, bool) {
+	_, cidr, err := net.ParseCIDR(aws.StringValue(s.CidrBlock))
+	if err != nil {
+		return 0, false
+	}
+	ones, bits := cidr.Mask.Size()
+	usable := (1 << (bits - ones)) - reservedIPAddresses
+	if usable <= 0 {
+		return 0, false
+	}
+	return 1 - float64(aws.Int64Value(s.AvailableIpAddressCount))/float64(usable), true
+}
@@ -0,0 +1,34 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subnetrebalancing
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/events"
+)
+
+func RebalancingSubnet(machine *v1alpha5.Machine, subnetID string, utilization float64) events.Event {
+	return events.Event{
+		InvolvedObject: machine,
+		Type:           v1.EventTypeNormal,
+		Reason:         "SubnetRebalancing",
+		Message:        fmt.Sprintf("Replacing node to free IP addresses in subnet %s, which is %.0f%% used", subnetID, utilization*100),
+		DedupeValues:   []string{string(machine.UID)},
+	}
+}
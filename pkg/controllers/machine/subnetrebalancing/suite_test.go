@@ -0,0 +1,270 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subnetrebalancing_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/machine/subnetrebalancing"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var fakeClock *clock.FakeClock
+var recorder *coretest.EventRecorder
+var subnetRebalancingController *subnetrebalancing.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineSubnetRebalancing")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings(coresettings.Settings{DriftEnabled: true}))
+	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{SubnetRebalancingThreshold: lo.ToPtr(0.8)}))
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+	fakeClock = clock.NewFakeClock(time.Date(2023, time.August, 5, 3, 0, 0, 0, time.UTC)) // a Saturday
+	recorder = coretest.NewEventRecorder()
+	subnetRebalancingController = subnetrebalancing.NewController(env.Client, fakeClock, recorder, awsEnv.SubnetProvider, awsEnv.InstanceProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	recorder.Reset()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineSubnetRebalancing", func() {
+	var provisioner *v1alpha5.Provisioner
+	var nodeTemplate *v1alpha1.AWSNodeTemplate
+
+	BeforeEach(func() {
+		nodeTemplate = test.AWSNodeTemplate()
+		provisioner = coretest.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+		})
+		// subnet-exhausted has 10 of its 251 usable IP addresses available, and subnet-spare has 200
+		awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+			{
+				SubnetId:                aws.String("subnet-exhausted"),
+				AvailabilityZone:        aws.String("test-zone-1a"),
+				CidrBlock:               aws.String("10.0.0.0/24"),
+				AvailableIpAddressCount: aws.Int64(10),
+				Tags:                    []*ec2.Tag{{Key: aws.String("*"), Value: aws.String("*")}},
+			},
+			{
+				SubnetId:                aws.String("subnet-spare"),
+				AvailabilityZone:        aws.String("test-zone-1a"),
+				CidrBlock:               aws.String("10.0.1.0/24"),
+				AvailableIpAddressCount: aws.Int64(200),
+				Tags:                    []*ec2.Tag{{Key: aws.String("*"), Value: aws.String("*")}},
+			},
+		}})
+	})
+
+	// launch creates an initialized machine with a node and a backing instance that was launched into the given subnet
+	// some time ago
+	launch := func(subnetID string, age time.Duration) *v1alpha5.Machine {
+		instanceID := fake.InstanceID()
+		awsEnv.EC2API.Instances.Store(instanceID, &ec2.Instance{
+			State: &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			Tags: []*ec2.Tag{
+				{Key: aws.String(fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName)), Value: aws.String("owned")},
+				{Key: aws.String(v1alpha5.ProvisionerNameLabelKey), Value: aws.String(provisioner.Name)},
+			},
+			Placement:    &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+			InstanceId:   aws.String(instanceID),
+			InstanceType: aws.String("m5.large"),
+			SubnetId:     aws.String(subnetID),
+			LaunchTime:   aws.Time(fakeClock.Now().Add(-age)),
+		})
+		node := coretest.Node(coretest.NodeOptions{ProviderID: fake.ProviderID(instanceID)})
+		machine := coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{v1alpha5.ProvisionerNameLabelKey: provisioner.Name},
+			},
+			Spec: v1alpha5.MachineSpec{
+				MachineTemplateRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+			},
+		})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, machine, node)
+		machine.Status.ProviderID = fake.ProviderID(instanceID)
+		machine.Status.NodeName = node.Name
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineInitialized)
+		ExpectApplied(ctx, env.Client, machine)
+		return machine
+	}
+
+	It("should replace a node in a subnet above the threshold", func() {
+		machine := launch("subnet-exhausted", time.Hour)
+		spare := launch("subnet-spare", time.Hour)
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		ExpectRebalancing(machine)
+		ExpectNotRebalancing(spare)
+		Expect(recorder.Calls("SubnetRebalancing")).To(Equal(1))
+	})
+	It("should replace at most one node at a time, starting with the oldest", func() {
+		newer := launch("subnet-exhausted", time.Hour)
+		older := launch("subnet-exhausted", 2*time.Hour)
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		ExpectRebalancing(older)
+		ExpectNotRebalancing(newer)
+
+		// The next node is only replaced once the earlier replacement finished
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		ExpectNotRebalancing(newer)
+		ExpectDeleted(ctx, env.Client, older)
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		ExpectRebalancing(newer)
+	})
+	It("should not delete nodes, which are replaced through drift", func() {
+		machine := launch("subnet-exhausted", time.Hour)
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		machine = ExpectExists(ctx, env.Client, machine)
+		Expect(machine.DeletionTimestamp.IsZero()).To(BeTrue())
+	})
+	It("should not replace nodes when drift is disabled", func() {
+		ctx = coresettings.ToContext(ctx, coretest.Settings())
+		DeferCleanup(func() {
+			ctx = coresettings.ToContext(ctx, coretest.Settings(coresettings.Settings{DriftEnabled: true}))
+		})
+		machine := launch("subnet-exhausted", time.Hour)
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		ExpectNotRebalancing(machine)
+	})
+	It("should not replace nodes while another machine of the node template isn't initialized", func() {
+		machine := launch("subnet-exhausted", time.Hour)
+		launching := launch("subnet-spare", time.Minute)
+		launching.StatusConditions().MarkFalse(v1alpha5.MachineInitialized, "", "")
+		ExpectApplied(ctx, env.Client, launching)
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		ExpectNotRebalancing(machine)
+	})
+	It("should not replace nodes when no other subnet in the zone is below the threshold", func() {
+		awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+			{
+				SubnetId:                aws.String("subnet-exhausted"),
+				AvailabilityZone:        aws.String("test-zone-1a"),
+				CidrBlock:               aws.String("10.0.0.0/24"),
+				AvailableIpAddressCount: aws.Int64(10),
+				Tags:                    []*ec2.Tag{{Key: aws.String("*"), Value: aws.String("*")}},
+			},
+			{
+				SubnetId:                aws.String("subnet-other-zone"),
+				AvailabilityZone:        aws.String("test-zone-1b"),
+				CidrBlock:               aws.String("10.0.1.0/24"),
+				AvailableIpAddressCount: aws.Int64(200),
+				Tags:                    []*ec2.Tag{{Key: aws.String("*"), Value: aws.String("*")}},
+			},
+		}})
+		machine := launch("subnet-exhausted", time.Hour)
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		ExpectNotRebalancing(machine)
+	})
+	It("should not replace nodes with pods that can't be evicted", func() {
+		machine := launch("subnet-exhausted", time.Hour)
+		pod := coretest.Pod(coretest.PodOptions{
+			NodeName:   machine.Status.NodeName,
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{v1alpha5.DoNotEvictPodAnnotationKey: "true"}},
+		})
+		ExpectApplied(ctx, env.Client, pod)
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		ExpectNotRebalancing(machine)
+	})
+	It("should not replace nodes that can't be disrupted", func() {
+		machine := launch("subnet-exhausted", time.Hour)
+		node := ExpectNodeExists(ctx, env.Client, machine.Status.NodeName)
+		node.Annotations = lo.Assign(node.Annotations, map[string]string{corev1beta1.DoNotDisruptAnnotationKey: "true"})
+		ExpectApplied(ctx, env.Client, node)
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		ExpectNotRebalancing(machine)
+	})
+	It("should not replace nodes outside of the node template's maintenance windows", func() {
+		nodeTemplate.Spec.MaintenanceWindows = []v1alpha1.MaintenanceWindow{
+			{Days: []string{"Sunday"}, Start: "02:00", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+		}
+		machine := launch("subnet-exhausted", time.Hour)
+		ExpectReconcileSucceeded(ctx, subnetRebalancingController, client.ObjectKey{})
+		ExpectNotRebalancing(machine)
+	})
+	It("should only consider subnets with more available IP addresses", func() {
+		// A small subnet below the threshold doesn't have room for the nodes of a large subnet above it
+		subnets := []*ec2.Subnet{
+			{
+				SubnetId:                aws.String("subnet-large"),
+				AvailabilityZone:        aws.String("test-zone-1a"),
+				CidrBlock:               aws.String("10.0.0.0/20"),
+				AvailableIpAddressCount: aws.Int64(400),
+			},
+			{
+				SubnetId:                aws.String("subnet-small"),
+				AvailabilityZone:        aws.String("test-zone-1a"),
+				CidrBlock:               aws.String("10.0.16.0/28"),
+				AvailableIpAddressCount: aws.Int64(10),
+			},
+		}
+		Expect(subnetrebalancing.ExhaustedSubnets(subnets, 0.8)).To(BeEmpty())
+		subnets[1].CidrBlock = aws.String("10.0.16.0/22")
+		subnets[1].AvailableIpAddressCount = aws.Int64(1000)
+		Expect(subnetrebalancing.ExhaustedSubnets(subnets, 0.8)).To(HaveKey("subnet-large"))
+	})
+})
+
+func ExpectRebalancing(machine *v1alpha5.Machine) {
+	GinkgoHelper()
+	Expect(ExpectExists(ctx, env.Client, machine).Annotations).To(HaveKey(v1alpha1.AnnotationSubnetRebalancing))
+}
+
+func ExpectNotRebalancing(machine *v1alpha5.Machine) {
+	GinkgoHelper()
+	Expect(ExpectExists(ctx, env.Client, machine).Annotations).ToNot(HaveKey(v1alpha1.AnnotationSubnetRebalancing))
+}
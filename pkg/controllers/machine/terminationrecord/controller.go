@@ -0,0 +1,204 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terminationrecord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/system"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/utils"
+)
+
+const (
+	// ConfigMapName is the ConfigMap in Karpenter's namespace that terminated instances are recorded in, keyed by
+	// instance ID
+	ConfigMapName = "karpenter-terminated-instances"
+
+	// ConfigMaps are limited to 1MiB, so only the end of the console output is kept, and only for the most recently
+	// terminated instances
+	maxConsoleOutputBytes = 4 * 1024
+	maxRecords            = 100
+)
+
+// Record is what's kept about a terminated instance, so that it can be looked into after EC2 forgets about it
+type Record struct {
+	InstanceID      string     `json:"instanceID"`
+	Machine         string     `json:"machine"`
+	Node            string     `json:"node,omitempty"`
+	Provisioner     string     `json:"provisioner,omitempty"`
+	ImageID         string     `json:"imageID,omitempty"`
+	InstanceType    string     `json:"instanceType,omitempty"`
+	Zone            string     `json:"zone,omitempty"`
+	CapacityType    string     `json:"capacityType,omitempty"`
+	LaunchTime      *time.Time `json:"launchTime,omitempty"`
+	TerminationTime time.Time  `json:"terminationTime"`
+	// Reason is why Karpenter terminated the instance, as far as it can be told from the Machine's status
+	Reason string `json:"reason,omitempty"`
+	// StateReason is EC2's reason for the instance's last state change, e.g. a spot interruption
+	StateReason   string `json:"stateReason,omitempty"`
+	ConsoleOutput string `json:"consoleOutput,omitempty"`
+}
+
+// Controller records the details of the instances of Machines that are being deleted in a ConfigMap, which keeps them
+// for the termination record TTL
+type Controller struct {
+	kubeClient          client.Client
+	kubernetesInterface kubernetes.Interface
+	clk                 clock.Clock
+	instanceProvider    *instance.Provider
+}
+
+func NewController(kubeClient client.Client, kubernetesInterface kubernetes.Interface, clk clock.Clock, instanceProvider *instance.Provider) corecontroller.Controller {
+	return corecontroller.Typed[*v1alpha5.Machine](kubeClient, &Controller{
+		kubeClient:          kubeClient,
+		kubernetesInterface: kubernetesInterface,
+		clk:                 clk,
+		instanceProvider:    instanceProvider,
+	})
+}
+
+func (c *Controller) Name() string {
+	return "machine.terminationrecord"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, machine *v1alpha5.Machine) (reconcile.Result, error) {
+	if machine.DeletionTimestamp.IsZero() || machine.Status.ProviderID == "" {
+		return reconcile.Result{}, nil
+	}
+	id, err := utils.ParseInstanceID(machine.Status.ProviderID)
+	if err != nil {
+		return reconcile.Result{}, nil
+	}
+	cm, err := c.kubernetesInterface.CoreV1().ConfigMaps(system.Namespace()).Get(ctx, ConfigMapName, metav1.GetOptions{})
+	exists := !errors.IsNotFound(err)
+	if !exists {
+		cm = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: system.Namespace()}}
+	} else if err != nil {
+		return reconcile.Result{}, fmt.Errorf("getting termination records, %w", err)
+	}
+	if _, ok := cm.Data[id]; ok {
+		return reconcile.Result{}, nil
+	}
+	data, err := json.Marshal(c.newRecord(ctx, machine, id))
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("marshaling termination record, %w", err)
+	}
+	cm.Data = c.prune(ctx, lo.Assign(cm.Data, map[string]string{id: string(data)}))
+	if exists {
+		_, err = c.kubernetesInterface.CoreV1().ConfigMaps(system.Namespace()).Update(ctx, cm, metav1.UpdateOptions{})
+	} else {
+		_, err = c.kubernetesInterface.CoreV1().ConfigMaps(system.Namespace()).Create(ctx, cm, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("recording terminated instance, %w", err)
+	}
+	logging.FromContext(ctx).With("id", id).Debugf("recorded terminated instance")
+	return reconcile.Result{}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(&v1alpha5.Machine{}))
+}
+
+// newRecord collects what's known about the Machine's instance. The instance may already be gone, e.g. after an
+// interruption, in which case the details come from the Machine's labels.
+func (c *Controller) newRecord(ctx context.Context, machine *v1alpha5.Machine, id string) Record {
+	record := Record{
+		InstanceID:      id,
+		Machine:         machine.Name,
+		Node:            machine.Status.NodeName,
+		Provisioner:     machine.Labels[v1alpha5.ProvisionerNameLabelKey],
+		InstanceType:    machine.Labels[v1.LabelInstanceTypeStable],
+		Zone:            machine.Labels[v1.LabelTopologyZone],
+		CapacityType:    machine.Labels[v1alpha5.LabelCapacityType],
+		TerminationTime: c.clk.Now().UTC(),
+		Reason:          reason(machine),
+	}
+	if i, err := c.instanceProvider.Get(ctx, id); err == nil {
+		record.ImageID = i.ImageID
+		record.InstanceType = i.Type
+		record.Zone = i.Zone
+		record.CapacityType = i.CapacityType
+		record.LaunchTime = lo.ToPtr(i.LaunchTime.UTC())
+		record.StateReason = i.StateReason
+	} else {
+		logging.FromContext(ctx).With("id", id).Debugf("getting terminated instance, %s", err)
+	}
+	if output, err := c.instanceProvider.ConsoleOutput(ctx, id); err == nil {
+		record.ConsoleOutput = output[lo.Max([]int{0, len(output) - maxConsoleOutputBytes}):]
+	} else {
+		logging.FromContext(ctx).With("id", id).Debugf("getting console output of terminated instance, %s", err)
+	}
+	return record
+}
+
+// prune drops the records that are older than the termination record TTL, and then the oldest records beyond the
+// maximum number of records
+func (c *Controller) prune(ctx context.Context, data map[string]string) map[string]string {
+	records := map[string]Record{}
+	for id, raw := range data {
+		record := Record{}
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			continue
+		}
+		if c.clk.Since(record.TerminationTime) > settings.FromContext(ctx).TerminationRecordTTL {
+			continue
+		}
+		records[id] = record
+	}
+	ids := lo.Keys(records)
+	sort.Slice(ids, func(i, j int) bool {
+		return records[ids[i]].TerminationTime.After(records[ids[j]].TerminationTime)
+	})
+	return lo.PickByKeys(data, lo.Slice(ids, 0, maxRecords))
+}
+
+// reason returns why the Machine is being deleted, as far as can be told from its status conditions. Machines that are
+// deleted for other reasons, e.g. consolidation or interruption, don't have one.
+func reason(machine *v1alpha5.Machine) string {
+	for _, t := range []apis.ConditionType{v1alpha5.MachineDrifted, v1alpha5.MachineExpired, v1alpha5.MachineEmpty} {
+		if cond := machine.StatusConditions().GetCondition(t); cond != nil && cond.IsTrue() {
+			return strings.TrimPrefix(string(t), "Machine")
+		}
+	}
+	if registered := machine.StatusConditions().GetCondition(v1alpha5.MachineRegistered); registered == nil || !registered.IsTrue() {
+		return "NotRegistered"
+	}
+	return ""
+}
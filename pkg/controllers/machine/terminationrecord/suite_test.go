@@ -0,0 +1,199 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package terminationrecord_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/system"
+	_ "knative.dev/pkg/system/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/controllers/machine/terminationrecord"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var fakeClock *clock.FakeClock
+var terminationRecordController controller.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MachineTerminationRecord")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{TerminationRecordTTL: lo.ToPtr(24 * time.Hour)}))
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+	ExpectApplied(ctx, env.Client, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: system.Namespace()}})
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	fakeClock = clock.NewFakeClock(time.Now())
+	terminationRecordController = terminationrecord.NewController(env.Client, env.KubernetesInterface, fakeClock, awsEnv.InstanceProvider)
+})
+
+var _ = AfterEach(func() {
+	_ = env.KubernetesInterface.CoreV1().ConfigMaps(system.Namespace()).Delete(ctx, terminationrecord.ConfigMapName, metav1.DeleteOptions{})
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MachineTerminationRecord", func() {
+	var provisioner *v1alpha5.Provisioner
+
+	BeforeEach(func() {
+		provisioner = coretest.Provisioner()
+	})
+
+	// launch creates a machine with a backing instance, and returns it with the instance's ID
+	launch := func() (*v1alpha5.Machine, string) {
+		instanceID := fake.InstanceID()
+		awsEnv.EC2API.Instances.Store(instanceID, &ec2.Instance{
+			State:        &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameShuttingDown)},
+			StateReason:  &ec2.StateReason{Message: aws.String("Server.SpotInstanceTermination: Spot instance termination")},
+			ImageId:      aws.String("ami-123"),
+			Placement:    &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+			InstanceId:   aws.String(instanceID),
+			InstanceType: aws.String("m5.large"),
+			LaunchTime:   aws.Time(fakeClock.Now().Add(-time.Hour)),
+		})
+		machine := coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels:     map[string]string{v1alpha5.ProvisionerNameLabelKey: provisioner.Name},
+				Finalizers: []string{v1alpha5.TerminationFinalizer},
+			},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		machine.Status.ProviderID = fake.ProviderID(instanceID)
+		machine.Status.NodeName = coretest.RandomName()
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		ExpectApplied(ctx, env.Client, machine)
+		return machine, instanceID
+	}
+	records := func() map[string]terminationrecord.Record {
+		cm, err := env.KubernetesInterface.CoreV1().ConfigMaps(system.Namespace()).Get(ctx, terminationrecord.ConfigMapName, metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		return lo.MapValues(cm.Data, func(raw string, _ string) terminationrecord.Record {
+			record := terminationrecord.Record{}
+			Expect(json.Unmarshal([]byte(raw), &record)).To(Succeed())
+			return record
+		})
+	}
+
+	It("should record the instances of deleting machines", func() {
+		awsEnv.EC2API.GetConsoleOutputBehavior.Output.Set(&ec2.GetConsoleOutputOutput{
+			Output: aws.String(base64.StdEncoding.EncodeToString([]byte("kernel panic"))),
+		})
+		machine, instanceID := launch()
+		Expect(env.Client.Delete(ctx, machine)).To(Succeed())
+		ExpectReconcileSucceeded(ctx, terminationRecordController, client.ObjectKeyFromObject(machine))
+
+		record, ok := records()[instanceID]
+		Expect(ok).To(BeTrue())
+		Expect(record.Machine).To(Equal(machine.Name))
+		Expect(record.Node).To(Equal(machine.Status.NodeName))
+		Expect(record.Provisioner).To(Equal(provisioner.Name))
+		Expect(record.ImageID).To(Equal("ami-123"))
+		Expect(record.InstanceType).To(Equal("m5.large"))
+		Expect(record.Zone).To(Equal("test-zone-1a"))
+		Expect(record.StateReason).To(ContainSubstring("Server.SpotInstanceTermination"))
+		Expect(record.ConsoleOutput).To(Equal("kernel panic"))
+	})
+	It("should record why the machine is being deleted", func() {
+		machine, instanceID := launch()
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineDrifted)
+		ExpectApplied(ctx, env.Client, machine)
+		Expect(env.Client.Delete(ctx, machine)).To(Succeed())
+		ExpectReconcileSucceeded(ctx, terminationRecordController, client.ObjectKeyFromObject(machine))
+		Expect(records()[instanceID].Reason).To(Equal("Drifted"))
+	})
+	It("should only keep the end of the console output", func() {
+		output := strings.Repeat("a", 8*1024) + "login:"
+		awsEnv.EC2API.GetConsoleOutputBehavior.Output.Set(&ec2.GetConsoleOutputOutput{
+			Output: aws.String(base64.StdEncoding.EncodeToString([]byte(output))),
+		})
+		machine, instanceID := launch()
+		Expect(env.Client.Delete(ctx, machine)).To(Succeed())
+		ExpectReconcileSucceeded(ctx, terminationRecordController, client.ObjectKeyFromObject(machine))
+		consoleOutput := records()[instanceID].ConsoleOutput
+		Expect(consoleOutput).To(HaveLen(4 * 1024))
+		Expect(consoleOutput).To(HaveSuffix("login:"))
+	})
+	It("should record the instance from the machine's labels when it's already gone", func() {
+		machine, instanceID := launch()
+		awsEnv.EC2API.Instances.Delete(instanceID)
+		machine.Labels = lo.Assign(machine.Labels, map[string]string{
+			v1.LabelInstanceTypeStable: "c5.large",
+			v1.LabelTopologyZone:       "test-zone-1b",
+		})
+		ExpectApplied(ctx, env.Client, machine)
+		Expect(env.Client.Delete(ctx, machine)).To(Succeed())
+		ExpectReconcileSucceeded(ctx, terminationRecordController, client.ObjectKeyFromObject(machine))
+		record := records()[instanceID]
+		Expect(record.InstanceType).To(Equal("c5.large"))
+		Expect(record.Zone).To(Equal("test-zone-1b"))
+	})
+	It("should not record machines that aren't being deleted", func() {
+		machine, _ := launch()
+		ExpectReconcileSucceeded(ctx, terminationRecordController, client.ObjectKeyFromObject(machine))
+		_, err := env.KubernetesInterface.CoreV1().ConfigMaps(system.Namespace()).Get(ctx, terminationrecord.ConfigMapName, metav1.GetOptions{})
+		Expect(err).To(HaveOccurred())
+	})
+	It("should drop records older than the termination record TTL", func() {
+		old, oldID := launch()
+		Expect(env.Client.Delete(ctx, old)).To(Succeed())
+		ExpectReconcileSucceeded(ctx, terminationRecordController, client.ObjectKeyFromObject(old))
+		fakeClock.Step(25 * time.Hour)
+		machine, instanceID := launch()
+		Expect(env.Client.Delete(ctx, machine)).To(Succeed())
+		ExpectReconcileSucceeded(ctx, terminationRecordController, client.ObjectKeyFromObject(machine))
+		Expect(records()).To(HaveKey(instanceID))
+		Expect(records()).ToNot(HaveKey(oldID))
+	})
+})
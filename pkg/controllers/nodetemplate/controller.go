@@ -18,12 +18,19 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/multierr"
 	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/util/workqueue"
+	"knative.dev/pkg/apis"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -32,42 +39,65 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/mitchellh/hashstructure/v2"
 	"github.com/samber/lo"
 
+	"github.com/aws/karpenter-core/pkg/events"
 	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
 	"github.com/aws/karpenter/pkg/providers/subnet"
+	"github.com/aws/karpenter/pkg/providers/tagpolicy"
+	"github.com/aws/karpenter/pkg/utils/attribution"
 	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 )
 
 type Controller struct {
 	kubeClient            client.Client
+	recorder              events.Recorder
 	subnetProvider        *subnet.Provider
 	securityGroupProvider *securitygroup.Provider
 	amiProvider           *amifamily.Provider
+	tagPolicyProvider     *tagpolicy.Provider
+	bootstrapFailures     *awscache.BootstrapFailures
+
+	// The hash of the part of the spec of each NodeClass that selects its AMIs, as of its last reconcile
+	mu            sync.Mutex
+	amiSelections map[nodeclassutil.Key]uint64
 }
 
-func NewController(kubeClient client.Client, subnetProvider *subnet.Provider,
-	securityGroupProvider *securitygroup.Provider, amiProvider *amifamily.Provider) *Controller {
+func NewController(kubeClient client.Client, recorder events.Recorder, subnetProvider *subnet.Provider, securityGroupProvider *securitygroup.Provider,
+	amiProvider *amifamily.Provider, tagPolicyProvider *tagpolicy.Provider, bootstrapFailures *awscache.BootstrapFailures) *Controller {
 	return &Controller{
 		kubeClient:            kubeClient,
+		recorder:              recorder,
 		subnetProvider:        subnetProvider,
 		securityGroupProvider: securityGroupProvider,
 		amiProvider:           amiProvider,
+		tagPolicyProvider:     tagPolicyProvider,
+		bootstrapFailures:     bootstrapFailures,
+		amiSelections:         map[nodeclassutil.Key]uint64{},
 	}
 }
 
 func (c *Controller) Reconcile(ctx context.Context, nodeClass *v1beta1.NodeClass) (reconcile.Result, error) {
+	ctx = attribution.WithNodeClass(ctx, nodeClass.Name)
 	stored := nodeClass.DeepCopy()
 	nodeClass.Annotations = lo.Assign(nodeClass.Annotations, nodeclassutil.HashAnnotation(nodeClass))
+	if err := c.invalidateAMIs(ctx, nodeClass); err != nil {
+		return reconcile.Result{}, err
+	}
 	err := multierr.Combine(
 		c.resolveSubnets(ctx, nodeClass),
 		c.resolveSecurityGroups(ctx, nodeClass),
 		c.resolveAMIs(ctx, nodeClass),
+		c.resolveTagPolicy(ctx, nodeClass),
 	)
+	requeueAfter := c.resolveExcludedInstanceTypes(nodeClass)
 	if !equality.Semantic.DeepEqual(stored, nodeClass) {
 		statusCopy := nodeClass.DeepCopy()
 		if patchErr := nodeclassutil.Patch(ctx, c.kubeClient, stored, nodeClass); patchErr != nil {
@@ -77,7 +107,7 @@ func (c *Controller) Reconcile(ctx context.Context, nodeClass *v1beta1.NodeClass
 			err = multierr.Append(err, client.IgnoreNotFound(patchErr))
 		}
 	}
-	return reconcile.Result{RequeueAfter: 5 * time.Minute}, err
+	return reconcile.Result{RequeueAfter: requeueAfter}, err
 }
 
 func (c *Controller) resolveSubnets(ctx context.Context, nodeClass *v1beta1.NodeClass) error {
@@ -119,6 +149,20 @@ func (c *Controller) resolveSecurityGroups(ctx context.Context, nodeClass *v1bet
 	return nil
 }
 
+// invalidateAMIs deletes the AMIs cached for the NodeClass when it's annotated to request it, so that they're resolved
+// again below, and removes the annotation
+func (c *Controller) invalidateAMIs(ctx context.Context, nodeClass *v1beta1.NodeClass) error {
+	annotation := lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.AnnotationInvalidateAMIs, v1beta1.AnnotationInvalidateAMIs)
+	if _, ok := nodeClass.Annotations[annotation]; !ok {
+		return nil
+	}
+	if err := c.amiProvider.InvalidateNodeClass(ctx, nodeclassutil.Key{Name: nodeClass.Name, IsNodeTemplate: nodeClass.IsNodeTemplate}); err != nil {
+		return fmt.Errorf("invalidating amis, %w", err)
+	}
+	delete(nodeClass.Annotations, annotation)
+	return nil
+}
+
 func (c *Controller) resolveAMIs(ctx context.Context, nodeClass *v1beta1.NodeClass) error {
 	amis, err := c.amiProvider.Get(ctx, nodeClass, &amifamily.Options{})
 	if err != nil {
@@ -126,16 +170,185 @@ func (c *Controller) resolveAMIs(ctx context.Context, nodeClass *v1beta1.NodeCla
 	}
 	if len(amis) == 0 {
 		nodeClass.Status.AMIs = nil
+		nodeClass.StatusConditions().MarkFalse(v1beta1.NodeClassAMIsResolved, "NoAMIsResolved", "no amis exist given constraints")
 		return fmt.Errorf("no amis exist given constraints")
 	}
-	nodeClass.Status.AMIs = lo.Map(amis, func(ami amifamily.AMI, _ int) v1beta1.AMI {
+	nodeClass.StatusConditions().SetCondition(apis.Condition{Type: v1beta1.NodeClassAMIsResolved, Status: v1.ConditionTrue})
+	resolved := lo.Map(amis, func(ami amifamily.AMI, _ int) v1beta1.AMI {
+		requirements := ami.Requirements.NodeSelectorRequirements()
+		// AMIs that can only be launched in some zones, such as those on an Outpost, report the zones as a requirement
+		if len(ami.Zones) > 0 {
+			requirements = append(requirements, v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: ami.Zones})
+		}
 		return v1beta1.AMI{
 			Name:         ami.Name,
 			ID:           ami.AmiID,
-			Requirements: ami.Requirements.NodeSelectorRequirements(),
+			CreationDate: ami.CreationDate,
+			Requirements: requirements,
+		}
+	})
+	// The AMIs that were replaced are kept so that they can be rolled back to. They aren't replaced during a rollback,
+	// which resolves the previous AMIs themselves.
+	selectionChanged := c.observeAMISelection(nodeClass)
+	if len(nodeClass.Status.AMIs) > 0 && !sameAMIs(nodeClass.Status.AMIs, resolved) {
+		c.recorder.Publish(AMIsChanged(nodeClass, nodeClass.Status.AMIs, resolved, amiChangeReason(nodeClass, selectionChanged)))
+		if !amifamily.RollbackRequested(nodeClass) {
+			nodeClass.Status.PreviousAMIs = nodeClass.Status.AMIs
 		}
+	}
+	nodeClass.Status.AMIs = resolved
+	c.resolveEBSEncryption(ctx, nodeClass, amis)
+	return nil
+}
+
+// resolveExcludedInstanceTypes reports the instance types that are excluded from the NodeClass because their nodes
+// repeatedly failed to launch or register, and when they're considered again. It returns how long until the next
+// exclusion expires, so that the expiry is reported when it happens.
+func (c *Controller) resolveExcludedInstanceTypes(nodeClass *v1beta1.NodeClass) time.Duration {
+	excluded := c.bootstrapFailures.Excluded(nodeclassutil.Key{Name: nodeClass.Name, IsNodeTemplate: nodeClass.IsNodeTemplate})
+	previous := lo.SliceToMap(nodeClass.Status.ExcludedInstanceTypes, func(e v1beta1.ExcludedInstanceType) (string, v1beta1.ExcludedInstanceType) {
+		return e.Name, e
 	})
+	nodeClass.Status.ExcludedInstanceTypes = lo.Map(lo.Keys(excluded), func(name string, _ int) v1beta1.ExcludedInstanceType {
+		// The status is serialized with second precision, so the time is truncated to avoid patching it on every reconcile
+		return v1beta1.ExcludedInstanceType{
+			Name:          name,
+			ExcludedUntil: metav1.NewTime(excluded[name].Until.Truncate(time.Second)),
+			Reason:        excluded[name].Reason,
+		}
+	})
+	sort.Slice(nodeClass.Status.ExcludedInstanceTypes, func(i, j int) bool {
+		return nodeClass.Status.ExcludedInstanceTypes[i].Name < nodeClass.Status.ExcludedInstanceTypes[j].Name
+	})
+	for _, e := range nodeClass.Status.ExcludedInstanceTypes {
+		if p, ok := previous[e.Name]; !ok || !p.ExcludedUntil.Equal(&e.ExcludedUntil) {
+			c.recorder.Publish(InstanceTypeExcluded(nodeClass, e))
+		}
+	}
+	for name := range previous {
+		if _, ok := excluded[name]; !ok {
+			c.recorder.Publish(InstanceTypeExclusionExpired(nodeClass, name))
+		}
+	}
+	next := 5 * time.Minute
+	for _, e := range excluded {
+		next = lo.Min([]time.Duration{next, time.Until(e.Until)})
+	}
+	// An exclusion that expired since it was listed would otherwise not requeue at all
+	return lo.Max([]time.Duration{next, time.Second})
+}
+
+// observeAMISelection records the part of the spec of the NodeClass that selects its AMIs, and returns whether it
+// changed since the NodeClass was last reconciled. It's only known to have changed if this controller reconciled the
+// NodeClass before.
+func (c *Controller) observeAMISelection(nodeClass *v1beta1.NodeClass) bool {
+	hash := lo.Must(hashstructure.Hash([]interface{}{
+		nodeClass.Spec.AMIFamily,
+		nodeClass.Spec.AMIVariant,
+		nodeClass.Spec.UbuntuStream,
+		nodeClass.Spec.AMISelectorTerms,
+		nodeClass.Spec.AMIDeprecationPolicy,
+		nodeClass.Spec.AMISortStrategy,
+		nodeClass.Spec.AMIRollout,
+	}, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true}))
+	key := nodeclassutil.Key{Name: nodeClass.Name, IsNodeTemplate: nodeClass.IsNodeTemplate}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous, ok := c.amiSelections[key]
+	c.amiSelections[key] = hash
+	return ok && previous != hash
+}
+
+// forget deletes what the controller recorded of the NodeClass once it's deleted
+func (c *Controller) forget(key nodeclassutil.Key) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.amiSelections, key)
+	amifamily.DeleteNodeClassMetrics(key)
+}
 
+// deletionDecorator forgets the NodeClasses that are deleted. The typed controller that it decorates doesn't reconcile
+// NodeClasses that aren't found anymore.
+type deletionDecorator struct {
+	corecontroller.Controller
+	kubeClient     client.Client
+	controller     *Controller
+	newObject      func() client.Object
+	isNodeTemplate bool
+}
+
+func (d *deletionDecorator) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	if err := d.kubeClient.Get(ctx, req.NamespacedName, d.newObject()); errors.IsNotFound(err) {
+		d.controller.forget(nodeclassutil.Key{Name: req.Name, IsNodeTemplate: d.isNodeTemplate})
+		return reconcile.Result{}, nil
+	}
+	return d.Controller.Reconcile(ctx, req)
+}
+
+// amiChangeReason returns why the AMIs of the NodeClass changed: a rollback to its previous AMIs, a change to the part
+// of its spec that selects them, or a change to the images that its unchanged spec selects, such as a newly published AMI
+func amiChangeReason(nodeClass *v1beta1.NodeClass, selectionChanged bool) string {
+	switch {
+	case amifamily.RollbackRequested(nodeClass):
+		return AMIChangeReasonRollback
+	case selectionChanged:
+		return AMIChangeReasonSpecChanged
+	default:
+		return AMIChangeReasonImagesChanged
+	}
+}
+
+func sameAMIs(a, b []v1beta1.AMI) bool {
+	id := func(ami v1beta1.AMI, _ int) string { return ami.ID }
+	return sets.New(lo.Map(a, id)...).Equal(sets.New(lo.Map(b, id)...))
+}
+
+// resolveEBSEncryption reports whether instances launched from the NodeClass would only attach encrypted volumes
+// when EBS encryption is required, so that a violation is surfaced before a launch is attempted.
+func (c *Controller) resolveEBSEncryption(ctx context.Context, nodeClass *v1beta1.NodeClass, amis amifamily.AMIs) {
+	if !settings.FromContext(ctx).RequireEBSEncryption || nodeClass.Spec.LaunchTemplateName != nil {
+		_ = nodeClass.StatusConditions().ClearCondition(v1beta1.NodeClassEBSEncryptionCompliant)
+		return
+	}
+	blockDeviceMappings := nodeClass.Spec.BlockDeviceMappings
+	if len(blockDeviceMappings) == 0 {
+		blockDeviceMappings = amifamily.GetAMIFamilyForNodeClass(nodeClass, &amifamily.Options{}).DefaultBlockDeviceMappings()
+	}
+	var violations []string
+	for _, ami := range amis {
+		if devices := ami.UnencryptedVolumes(blockDeviceMappings); len(devices) > 0 {
+			violations = append(violations, fmt.Sprintf("%s (%s)", ami.AmiID, strings.Join(devices, ", ")))
+		}
+	}
+	if len(violations) > 0 {
+		nodeClass.StatusConditions().MarkFalse(v1beta1.NodeClassEBSEncryptionCompliant, "UnencryptedVolumes", "amis would launch unencrypted volumes, %s", strings.Join(violations, "; "))
+		return
+	}
+	nodeClass.StatusConditions().SetCondition(apis.Condition{Type: v1beta1.NodeClassEBSEncryptionCompliant, Status: v1.ConditionTrue})
+}
+
+// resolveTagPolicy reports whether the tags that instances launched from the NodeClass are created with comply with the
+// effective tag policy of the account when tag policies are validated, so that a violation is surfaced before EC2
+// rejects a launch for it.
+func (c *Controller) resolveTagPolicy(ctx context.Context, nodeClass *v1beta1.NodeClass) error {
+	if !settings.FromContext(ctx).ValidateTagPolicies {
+		_ = nodeClass.StatusConditions().ClearCondition(v1beta1.NodeClassTagPolicyCompliant)
+		return nil
+	}
+	policy, err := c.tagPolicyProvider.Get(ctx)
+	if err != nil {
+		return err
+	}
+	tags := lo.Assign(settings.FromContext(ctx).Tags, nodeClass.Spec.Tags)
+	violations := policy.Violations(tags)
+	for _, override := range nodeClass.Spec.CapacityTypeOverrides {
+		violations = append(violations, policy.Violations(lo.Assign(tags, override.Tags))...)
+	}
+	if violations = lo.Uniq(violations); len(violations) > 0 {
+		nodeClass.StatusConditions().MarkFalse(v1beta1.NodeClassTagPolicyCompliant, "NonCompliantTags", "tags don't comply with the tag policy, %s", strings.Join(violations, "; "))
+		return nil
+	}
+	nodeClass.StatusConditions().SetCondition(apis.Condition{Type: v1beta1.NodeClassTagPolicyCompliant, Status: v1.ConditionTrue})
 	return nil
 }
 
@@ -143,11 +356,15 @@ type NodeClassController struct {
 	*Controller
 }
 
-func NewNodeClassController(kubeClient client.Client, subnetProvider *subnet.Provider,
-	securityGroupProvider *securitygroup.Provider, amiProvider *amifamily.Provider) corecontroller.Controller {
-	return corecontroller.Typed[*v1beta1.NodeClass](kubeClient, &NodeClassController{
-		Controller: NewController(kubeClient, subnetProvider, securityGroupProvider, amiProvider),
-	})
+func NewNodeClassController(kubeClient client.Client, recorder events.Recorder, subnetProvider *subnet.Provider, securityGroupProvider *securitygroup.Provider,
+	amiProvider *amifamily.Provider, tagPolicyProvider *tagpolicy.Provider, bootstrapFailures *awscache.BootstrapFailures) corecontroller.Controller {
+	c := NewController(kubeClient, recorder, subnetProvider, securityGroupProvider, amiProvider, tagPolicyProvider, bootstrapFailures)
+	return &deletionDecorator{
+		Controller: corecontroller.Typed[*v1beta1.NodeClass](kubeClient, &NodeClassController{Controller: c}),
+		kubeClient: kubeClient,
+		controller: c,
+		newObject:  func() client.Object { return &v1beta1.NodeClass{} },
+	}
 }
 
 func (c *NodeClassController) Name() string {
@@ -158,7 +375,8 @@ func (c *NodeClassController) Builder(_ context.Context, m manager.Manager) core
 	return corecontroller.Adapt(controllerruntime.
 		NewControllerManagedBy(m).
 		For(&v1beta1.NodeClass{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		// AMI rollbacks are requested with an annotation, which doesn't change the generation
+		WithEventFilter(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})).
 		WithOptions(controller.Options{
 			RateLimiter: workqueue.NewMaxOfRateLimiter(
 				workqueue.NewItemExponentialFailureRateLimiter(100*time.Millisecond, 1*time.Minute),
@@ -174,11 +392,16 @@ type NodeTemplateController struct {
 	*Controller
 }
 
-func NewNodeTemplateController(kubeClient client.Client, subnetProvider *subnet.Provider,
-	securityGroupProvider *securitygroup.Provider, amiProvider *amifamily.Provider) corecontroller.Controller {
-	return corecontroller.Typed[*v1alpha1.AWSNodeTemplate](kubeClient, &NodeTemplateController{
-		Controller: NewController(kubeClient, subnetProvider, securityGroupProvider, amiProvider),
-	})
+func NewNodeTemplateController(kubeClient client.Client, recorder events.Recorder, subnetProvider *subnet.Provider, securityGroupProvider *securitygroup.Provider,
+	amiProvider *amifamily.Provider, tagPolicyProvider *tagpolicy.Provider, bootstrapFailures *awscache.BootstrapFailures) corecontroller.Controller {
+	c := NewController(kubeClient, recorder, subnetProvider, securityGroupProvider, amiProvider, tagPolicyProvider, bootstrapFailures)
+	return &deletionDecorator{
+		Controller:     corecontroller.Typed[*v1alpha1.AWSNodeTemplate](kubeClient, &NodeTemplateController{Controller: c}),
+		kubeClient:     kubeClient,
+		controller:     c,
+		newObject:      func() client.Object { return &v1alpha1.AWSNodeTemplate{} },
+		isNodeTemplate: true,
+	}
 }
 
 func (c *NodeTemplateController) Reconcile(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (reconcile.Result, error) {
@@ -193,7 +416,8 @@ func (c *NodeTemplateController) Builder(_ context.Context, m manager.Manager) c
 	return corecontroller.Adapt(controllerruntime.
 		NewControllerManagedBy(m).
 		For(&v1alpha1.AWSNodeTemplate{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		// AMI rollbacks are requested with an annotation, which doesn't change the generation
+		WithEventFilter(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})).
 		WithOptions(controller.Options{
 			RateLimiter: workqueue.NewMaxOfRateLimiter(
 				workqueue.NewItemExponentialFailureRateLimiter(100*time.Millisecond, 1*time.Minute),
@@ -0,0 +1,83 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodetemplate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	nodetemplateutil "github.com/aws/karpenter/pkg/utils/nodetemplate"
+)
+
+const (
+	AMIChangeReasonRollback      = "rollback"
+	AMIChangeReasonSpecChanged   = "ami selection changed"
+	AMIChangeReasonImagesChanged = "selected images changed"
+)
+
+func AMIsChanged(nodeClass *v1beta1.NodeClass, previous, current []v1beta1.AMI, reason string) events.Event {
+	previousIDs, currentIDs := amiIDs(previous), amiIDs(current)
+	return events.Event{
+		InvolvedObject: involvedObject(nodeClass),
+		Type:           v1.EventTypeNormal,
+		Reason:         "AMIsChanged",
+		Message:        fmt.Sprintf("Changed amis from %s to %s, %s", strings.Join(previousIDs, ", "), strings.Join(currentIDs, ", "), reason),
+		DedupeValues:   []string{string(nodeClass.UID), strings.Join(currentIDs, ",")},
+	}
+}
+
+func amiIDs(amis []v1beta1.AMI) []string {
+	ids := lo.Uniq(lo.Map(amis, func(a v1beta1.AMI, _ int) string { return a.ID }))
+	sort.Strings(ids)
+	return ids
+}
+
+func InstanceTypeExcluded(nodeClass *v1beta1.NodeClass, excluded v1beta1.ExcludedInstanceType) events.Event {
+	return events.Event{
+		InvolvedObject: involvedObject(nodeClass),
+		Type:           v1.EventTypeWarning,
+		Reason:         "InstanceTypeExcluded",
+		Message: fmt.Sprintf("Excluded instance type %s until %s after its nodes repeatedly failed to launch or register, %s",
+			excluded.Name, excluded.ExcludedUntil.UTC().Format(time.RFC3339), excluded.Reason),
+		DedupeValues: []string{string(nodeClass.UID), excluded.Name, excluded.ExcludedUntil.String()},
+	}
+}
+
+func InstanceTypeExclusionExpired(nodeClass *v1beta1.NodeClass, instanceType string) events.Event {
+	return events.Event{
+		InvolvedObject: involvedObject(nodeClass),
+		Type:           v1.EventTypeNormal,
+		Reason:         "InstanceTypeExclusionExpired",
+		Message:        fmt.Sprintf("Instance type %s is no longer excluded and may be launched again", instanceType),
+		DedupeValues:   []string{string(nodeClass.UID), instanceType},
+	}
+}
+
+// involvedObject is the object that events about the NodeClass are recorded on, which is the AWSNodeTemplate it was
+// converted from if there's one
+func involvedObject(nodeClass *v1beta1.NodeClass) runtime.Object {
+	if nodeClass.IsNodeTemplate {
+		return nodetemplateutil.New(nodeClass)
+	}
+	return nodeClass
+}
@@ -22,7 +22,9 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/organizations"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/samber/lo"
@@ -45,6 +47,8 @@ import (
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/controllers/nodetemplate"
 	"github.com/aws/karpenter/pkg/test"
 )
@@ -55,6 +59,7 @@ var awsEnv *test.Environment
 var opts options.Options
 var nodeTemplate *v1alpha1.AWSNodeTemplate
 var controller corecontroller.Controller
+var recorder *coretest.EventRecorder
 
 func TestAPIs(t *testing.T) {
 	ctx = TestContextWithLogger(t)
@@ -68,7 +73,8 @@ var _ = BeforeSuite(func() {
 	ctx = settings.ToContext(ctx, test.Settings())
 	awsEnv = test.NewEnvironment(ctx, env)
 
-	controller = nodetemplate.NewNodeTemplateController(env.Client, awsEnv.SubnetProvider, awsEnv.SecurityGroupProvider, awsEnv.AMIProvider)
+	recorder = coretest.NewEventRecorder()
+	controller = nodetemplate.NewNodeTemplateController(env.Client, recorder, awsEnv.SubnetProvider, awsEnv.SecurityGroupProvider, awsEnv.AMIProvider, awsEnv.TagPolicyProvider, awsEnv.BootstrapFailuresCache)
 })
 
 var _ = AfterSuite(func() {
@@ -92,6 +98,7 @@ var _ = BeforeEach(func() {
 	}
 
 	awsEnv.Reset()
+	recorder.Reset()
 })
 
 var _ = AfterEach(func() {
@@ -427,7 +434,7 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					{
 						Name:         aws.String("test-ami-1"),
 						ImageId:      aws.String("ami-test1"),
-						CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+						CreationDate: aws.String("2023-01-01T12:00:00Z"),
 						Architecture: aws.String("x86_64"),
 						Tags: []*ec2.Tag{
 							{Key: aws.String("Name"), Value: aws.String("test-ami-1")},
@@ -437,7 +444,7 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					{
 						Name:         aws.String("test-ami-2"),
 						ImageId:      aws.String("ami-test2"),
-						CreationDate: aws.String(time.Now().Add(time.Minute).Format(time.RFC3339)),
+						CreationDate: aws.String("2023-01-01T12:01:00Z"),
 						Architecture: aws.String("x86_64"),
 						Tags: []*ec2.Tag{
 							{Key: aws.String("Name"), Value: aws.String("test-ami-2")},
@@ -447,7 +454,7 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					{
 						Name:         aws.String("test-ami-3"),
 						ImageId:      aws.String("ami-test3"),
-						CreationDate: aws.String(time.Now().Add(2 * time.Minute).Format(time.RFC3339)),
+						CreationDate: aws.String("2023-01-01T12:02:00Z"),
 						Architecture: aws.String("x86_64"),
 						Tags: []*ec2.Tag{
 							{Key: aws.String("Name"), Value: aws.String("test-ami-3")},
@@ -471,7 +478,7 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					{
 						Name:         aws.String("test-ami-1"),
 						ImageId:      aws.String("ami-id-123"),
-						CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+						CreationDate: aws.String("2023-01-01T12:00:00Z"),
 						Architecture: aws.String("x86_64"),
 						Tags: []*ec2.Tag{
 							{Key: aws.String("Name"), Value: aws.String("test-ami-1")},
@@ -481,7 +488,7 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					{
 						Name:         aws.String("test-ami-2"),
 						ImageId:      aws.String("ami-id-456"),
-						CreationDate: aws.String(time.Now().Add(time.Minute).Format(time.RFC3339)),
+						CreationDate: aws.String("2023-01-01T12:01:00Z"),
 						Architecture: aws.String("x86_64"),
 						Tags: []*ec2.Tag{
 							{Key: aws.String("Name"), Value: aws.String("test-ami-2")},
@@ -491,7 +498,7 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					{
 						Name:         aws.String("test-ami-3"),
 						ImageId:      aws.String("ami-id-789"),
-						CreationDate: aws.String(time.Now().Add(2 * time.Minute).Format(time.RFC3339)),
+						CreationDate: aws.String("2023-01-01T12:02:00Z"),
 						Architecture: aws.String("x86_64"),
 						Tags: []*ec2.Tag{
 							{Key: aws.String("Name"), Value: aws.String("test-ami-3")},
@@ -506,8 +513,9 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			ExpectConsistOfAMIs([]v1alpha1.AMI{
 				{
-					Name: "test-ami-1",
-					ID:   "ami-id-123",
+					Name:         "test-ami-1",
+					ID:           "ami-id-123",
+					CreationDate: "2023-01-01T12:00:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      v1.LabelArchStable,
@@ -525,8 +533,9 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					},
 				},
 				{
-					Name: "test-ami-3",
-					ID:   "ami-id-789",
+					Name:         "test-ami-3",
+					ID:           "ami-id-789",
+					CreationDate: "2023-01-01T12:02:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      v1.LabelArchStable,
@@ -544,8 +553,9 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					},
 				},
 				{
-					Name: "test-ami-2",
-					ID:   "ami-id-456",
+					Name:         "test-ami-2",
+					ID:           "ami-id-456",
+					CreationDate: "2023-01-01T12:01:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      v1.LabelArchStable,
@@ -559,8 +569,9 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					},
 				},
 				{
-					Name: "test-ami-2",
-					ID:   "ami-id-456",
+					Name:         "test-ami-2",
+					ID:           "ami-id-456",
+					CreationDate: "2023-01-01T12:01:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      v1.LabelArchStable,
@@ -589,7 +600,7 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					{
 						Name:         aws.String("test-ami-1"),
 						ImageId:      aws.String("ami-id-123"),
-						CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+						CreationDate: aws.String("2023-01-01T12:00:00Z"),
 						Architecture: aws.String("x86_64"),
 						Tags: []*ec2.Tag{
 							{Key: aws.String("Name"), Value: aws.String("test-ami-1")},
@@ -599,7 +610,7 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					{
 						Name:         aws.String("test-ami-2"),
 						ImageId:      aws.String("ami-id-456"),
-						CreationDate: aws.String(time.Now().Add(time.Minute).Format(time.RFC3339)),
+						CreationDate: aws.String("2023-01-01T12:01:00Z"),
 						Architecture: aws.String("arm64"),
 						Tags: []*ec2.Tag{
 							{Key: aws.String("Name"), Value: aws.String("test-ami-2")},
@@ -614,8 +625,9 @@ var _ = Describe("AWSNodeTemplateController", func() {
 
 			ExpectConsistOfAMIs([]v1alpha1.AMI{
 				{
-					Name: "test-ami-1",
-					ID:   "ami-id-123",
+					Name:         "test-ami-1",
+					ID:           "ami-id-123",
+					CreationDate: "2023-01-01T12:00:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      v1.LabelArchStable,
@@ -633,8 +645,9 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					},
 				},
 				{
-					Name: "test-ami-2",
-					ID:   "ami-id-456",
+					Name:         "test-ami-2",
+					ID:           "ami-id-456",
+					CreationDate: "2023-01-01T12:01:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      v1.LabelArchStable,
@@ -660,8 +673,9 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			Expect(nodeTemplate.Status.AMIs).To(ContainElements(
 				[]v1alpha1.AMI{
 					{
-						Name: "test-ami-3",
-						ID:   "ami-test3",
+						Name:         "test-ami-3",
+						ID:           "ami-test3",
+						CreationDate: "2023-01-01T12:02:00Z",
 						Requirements: []v1.NodeSelectorRequirement{
 							{
 								Key:      "kubernetes.io/arch",
@@ -681,7 +695,7 @@ var _ = Describe("AWSNodeTemplateController", func() {
 					{
 						Name:         aws.String("test-ami-4"),
 						ImageId:      aws.String("ami-test4"),
-						CreationDate: aws.String(time.Now().Add(2 * time.Minute).Format(time.RFC3339)),
+						CreationDate: aws.String("2023-01-01T12:02:00Z"),
 						Architecture: aws.String("x86_64"),
 						Tags: []*ec2.Tag{
 							{Key: aws.String("Name"), Value: aws.String("test-ami-3")},
@@ -697,8 +711,9 @@ var _ = Describe("AWSNodeTemplateController", func() {
 
 			ExpectConsistOfAMIs([]v1alpha1.AMI{
 				{
-					Name: "test-ami-4",
-					ID:   "ami-test4",
+					Name:         "test-ami-4",
+					ID:           "ami-test4",
+					CreationDate: "2023-01-01T12:02:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      "kubernetes.io/os",
@@ -718,6 +733,368 @@ var _ = Describe("AWSNodeTemplateController", func() {
 				},
 			}, nodeTemplate.Status.AMIs)
 		})
+		Context("Rollback", func() {
+			amiIDs := func(amis []v1alpha1.AMI) []string {
+				return lo.Map(amis, func(a v1alpha1.AMI, _ int) string { return a.ID })
+			}
+			BeforeEach(func() {
+				nodeTemplate.Spec.AMISelector = map[string]string{"aws-ids": "ami-test1"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+				nodeTemplate.Spec.AMISelector = map[string]string{"aws-ids": "ami-test2"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			})
+			It("should keep the replaced AMIs as the previous AMIs", func() {
+				Expect(amiIDs(nodeTemplate.Status.AMIs)).To(ConsistOf("ami-test2"))
+				Expect(amiIDs(nodeTemplate.Status.PreviousAMIs)).To(ConsistOf("ami-test1"))
+			})
+			It("should not replace the previous AMIs when the AMIs haven't changed", func() {
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+				Expect(amiIDs(nodeTemplate.Status.PreviousAMIs)).To(ConsistOf("ami-test1"))
+			})
+			It("should resolve the previous AMIs while rolled back", func() {
+				nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{v1alpha1.AnnotationAMIRollback: "true"})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+				Expect(amiIDs(nodeTemplate.Status.AMIs)).To(ConsistOf("ami-test1"))
+				Expect(amiIDs(nodeTemplate.Status.PreviousAMIs)).To(ConsistOf("ami-test1"))
+				Expect(nodeTemplate.Status.AMIs[0].Requirements).To(ConsistOf(v1.NodeSelectorRequirement{Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{"amd64"}}))
+			})
+			It("should resolve the AMI selector's AMIs once the rollback is removed", func() {
+				nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{v1alpha1.AnnotationAMIRollback: "true"})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+				delete(nodeTemplate.Annotations, v1alpha1.AnnotationAMIRollback)
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+				Expect(amiIDs(nodeTemplate.Status.AMIs)).To(ConsistOf("ami-test2"))
+				Expect(amiIDs(nodeTemplate.Status.PreviousAMIs)).To(ConsistOf("ami-test1"))
+			})
+		})
+		Context("Invalidation", func() {
+			BeforeEach(func() {
+				nodeTemplate.Spec.AMISelector = map[string]string{"aws-ids": "ami-test1"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			})
+			It("should use the cached AMIs without the annotation", func() {
+				calls := awsEnv.EC2API.CalledWithDescribeImagesInput.Len()
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(Equal(calls))
+			})
+			It("should resolve the AMIs again and remove the annotation", func() {
+				calls := awsEnv.EC2API.CalledWithDescribeImagesInput.Len()
+				nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{v1alpha1.AnnotationInvalidateAMIs: "true"})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(BeNumerically(">", calls))
+				nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+				Expect(nodeTemplate.Annotations).ToNot(HaveKey(v1alpha1.AnnotationInvalidateAMIs))
+				Expect(nodeTemplate.Status.AMIs).To(HaveLen(1))
+			})
+		})
+		It("should delete the metrics of the node template once it's deleted", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			labels := map[string]string{"nodeclass": nodeTemplate.Name, "nodeclass_kind": "AWSNodeTemplate"}
+			_, found := FindMetricWithLabelValues("karpenter_cloudprovider_amis_resolved", labels)
+			Expect(found).To(BeTrue())
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			_, found = FindMetricWithLabelValues("karpenter_cloudprovider_amis_resolved", labels)
+			Expect(found).To(BeFalse())
+		})
+		Context("Change Events", func() {
+			BeforeEach(func() {
+				nodeTemplate.Spec.AMISelector = map[string]string{"aws-ids": "ami-test1,ami-test5"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			})
+			It("should not publish an event when the AMIs are first resolved", func() {
+				Expect(recorder.Calls("AMIsChanged")).To(BeZero())
+			})
+			It("should not publish an event when the AMIs haven't changed", func() {
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				Expect(recorder.Calls("AMIsChanged")).To(BeZero())
+			})
+			It("should publish an event when a change to the spec changes the AMIs", func() {
+				nodeTemplate.Spec.AMISelector = map[string]string{"aws-ids": "ami-test2"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				Expect(recorder.Calls("AMIsChanged")).To(Equal(1))
+				Expect(recorder.Events()[0].Message).To(Equal("Changed amis from ami-test1 to ami-test2, ami selection changed"))
+			})
+			It("should publish an event when the images that the spec selects change", func() {
+				awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						Name:         aws.String("test-ami-1"),
+						ImageId:      aws.String("ami-test1"),
+						CreationDate: aws.String("2021-08-31T00:12:42.000Z"),
+						Architecture: aws.String("x86_64"),
+					},
+					{
+						Name:         aws.String("test-ami-1-arm64"),
+						ImageId:      aws.String("ami-test5"),
+						CreationDate: aws.String("2021-08-31T00:12:42.000Z"),
+						Architecture: aws.String("arm64"),
+					},
+				}})
+				awsEnv.EC2Cache.Flush()
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				Expect(recorder.Calls("AMIsChanged")).To(Equal(1))
+				Expect(recorder.Events()[0].Message).To(Equal("Changed amis from ami-test1 to ami-test1, ami-test5, selected images changed"))
+			})
+			It("should publish an event when the AMIs are rolled back", func() {
+				nodeTemplate.Spec.AMISelector = map[string]string{"aws-ids": "ami-test2"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+				recorder.Reset()
+				nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{v1alpha1.AnnotationAMIRollback: "true"})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				Expect(recorder.Calls("AMIsChanged")).To(Equal(1))
+				Expect(recorder.Events()[0].Message).To(Equal("Changed amis from ami-test2 to ami-test1, rollback"))
+			})
+		})
+		It("should mark the AMIs as resolved when the AMI selector matches images", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassAMIsResolved).IsTrue()).To(BeTrue())
+		})
+		It("should mark the AMIs as unresolved when the AMI selector matches no images", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{}})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Status.AMIs).To(BeEmpty())
+			condition := nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassAMIsResolved)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("NoAMIsResolved"))
+		})
+		It("should mark the AMIs as resolved once the AMI selector matches images again", func() {
+			images := awsEnv.EC2API.DescribeImagesOutput.Clone()
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{}})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			awsEnv.EC2API.DescribeImagesOutput.Set(images)
+			awsEnv.EC2Cache.Flush()
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassAMIsResolved).IsTrue()).To(BeTrue())
+		})
+		It("should fail to roll back when there are no previous AMIs", func() {
+			nodeTemplate.Annotations = map[string]string{v1alpha1.AnnotationAMIRollback: "true"}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		})
+	})
+	Context("EBS Encryption Status", func() {
+		BeforeEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{RequireEBSEncryption: lo.ToPtr(true)}))
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{
+				Images: []*ec2.Image{
+					{
+						Name:         aws.String("test-ami-1"),
+						ImageId:      aws.String("ami-test1"),
+						CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+						Architecture: aws.String("x86_64"),
+						BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+							{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{Encrypted: aws.Bool(false)}},
+						},
+					},
+				},
+			})
+		})
+		AfterEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings())
+		})
+		It("should mark the node template as compliant when every volume is encrypted", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassEBSEncryptionCompliant).IsTrue()).To(BeTrue())
+		})
+		It("should mark the node template as non-compliant when a block device mapping is not encrypted", func() {
+			nodeTemplate.Spec.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+				{DeviceName: aws.String("/dev/xvda"), EBS: &v1alpha1.BlockDevice{Encrypted: aws.Bool(false)}},
+			}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			condition := nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassEBSEncryptionCompliant)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring("ami-test1 (/dev/xvda)"))
+		})
+		It("should mark the node template as non-compliant when the AMI maps an unencrypted volume", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{
+				Images: []*ec2.Image{
+					{
+						Name:         aws.String("test-ami-1"),
+						ImageId:      aws.String("ami-test1"),
+						CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+						Architecture: aws.String("x86_64"),
+						BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+							{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{Encrypted: aws.Bool(false)}},
+							{DeviceName: aws.String("/dev/xvdb"), Ebs: &ec2.EbsBlockDevice{Encrypted: aws.Bool(false)}},
+							{DeviceName: aws.String("/dev/sdc"), VirtualName: aws.String("ephemeral0")},
+						},
+					},
+				},
+			})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			condition := nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassEBSEncryptionCompliant)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring("ami-test1 (/dev/xvdb)"))
+		})
+		It("should not report the condition when EBS encryption is not required", func() {
+			ctx = settings.ToContext(ctx, test.Settings())
+			nodeTemplate.Spec.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+				{DeviceName: aws.String("/dev/xvda"), EBS: &v1alpha1.BlockDevice{Encrypted: aws.Bool(false)}},
+			}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassEBSEncryptionCompliant)).To(BeNil())
+		})
+	})
+	Context("Tag Policy Status", func() {
+		BeforeEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ValidateTagPolicies: lo.ToPtr(true)}))
+			awsEnv.OrganizationsAPI.DescribeEffectivePolicyOutput.Set(&organizations.DescribeEffectivePolicyOutput{
+				EffectivePolicy: &organizations.EffectivePolicy{
+					PolicyContent: aws.String(`{"tags":{"costcenter":{"tag_key":{"@@assign":"CostCenter"},"tag_value":{"@@assign":["100","200*"]}},"team":{"tag_key":"Team"}}}`),
+				},
+			})
+		})
+		AfterEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings())
+		})
+		It("should mark the node template as compliant when its tags comply with the tag policy", func() {
+			nodeTemplate.Spec.Tags = map[string]string{"CostCenter": "200-batch", "Team": "platform", "other": "value"}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassTagPolicyCompliant).IsTrue()).To(BeTrue())
+		})
+		It("should mark the node template as non-compliant when a tag value isn't allowed", func() {
+			nodeTemplate.Spec.Tags = map[string]string{"CostCenter": "300"}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			condition := nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassTagPolicyCompliant)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring(`tag CostCenter value "300" is not one of [100 200*]`))
+		})
+		It("should mark the node template as non-compliant when a tag key is capitalized differently", func() {
+			nodeTemplate.Spec.Tags = map[string]string{"team": "platform"}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			condition := nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassTagPolicyCompliant)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring(`tag key "team" must be capitalized as "Team"`))
+		})
+		It("should validate the global tags along with the node template's tags", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ValidateTagPolicies: lo.ToPtr(true), Tags: map[string]string{"costcenter": "100"}}))
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			condition := nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassTagPolicyCompliant)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring(`tag key "costcenter" must be capitalized as "CostCenter"`))
+		})
+		It("should mark the node template as compliant when the account isn't in an organization", func() {
+			awsEnv.OrganizationsAPI.NextError.Set(awserr.New(organizations.ErrCodeAWSOrganizationsNotInUseException, "not in use", nil))
+			nodeTemplate.Spec.Tags = map[string]string{"CostCenter": "300"}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassTagPolicyCompliant).IsTrue()).To(BeTrue())
+		})
+		It("should not report the condition when tag policies are not validated", func() {
+			ctx = settings.ToContext(ctx, test.Settings())
+			nodeTemplate.Spec.Tags = map[string]string{"CostCenter": "300"}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.StatusConditions().GetCondition(v1beta1.NodeClassTagPolicyCompliant)).To(BeNil())
+		})
+	})
+	Context("Excluded Instance Types Status", func() {
+		exclude := func(instanceType string) {
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				awsEnv.BootstrapFailuresCache.MarkFailed(nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true}, instanceType, fmt.Sprintf("machine-%d", i), "node didn't register within 15m0s")
+			}
+		}
+		It("should report the instance types that are excluded from the node template", func() {
+			exclude("m5.xlarge")
+			exclude("m5.large")
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(lo.Map(nodeTemplate.Status.ExcludedInstanceTypes, func(e v1alpha1.ExcludedInstanceType, _ int) string { return e.Name })).To(Equal([]string{"m5.large", "m5.xlarge"}))
+			for _, excluded := range nodeTemplate.Status.ExcludedInstanceTypes {
+				Expect(excluded.ExcludedUntil.Time).To(BeTemporally("~", time.Now().Add(awscache.BootstrapFailureExclusionTTL), time.Minute))
+				Expect(excluded.Reason).To(Equal("node didn't register within 15m0s"))
+			}
+		})
+		It("should publish an event when an instance type is excluded", func() {
+			exclude("m5.large")
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(recorder.Calls("InstanceTypeExcluded")).To(Equal(1))
+			Expect(recorder.Events()[0].Type).To(Equal(v1.EventTypeWarning))
+			Expect(recorder.Events()[0].Message).To(HavePrefix("Excluded instance type m5.large until "))
+			Expect(recorder.Events()[0].Message).To(HaveSuffix("after its nodes repeatedly failed to launch or register, node didn't register within 15m0s"))
+
+			// The exclusion is only reported once
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(recorder.Calls("InstanceTypeExcluded")).To(Equal(1))
+		})
+		It("should publish an event when the exclusion of an instance type expires", func() {
+			exclude("m5.large")
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(recorder.Calls("InstanceTypeExclusionExpired")).To(BeZero())
+			awsEnv.BootstrapFailuresCache.Flush()
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(recorder.Calls("InstanceTypeExclusionExpired")).To(Equal(1))
+			Expect(recorder.Events()[1].Message).To(Equal("Instance type m5.large is no longer excluded and may be launched again"))
+		})
+		It("should clear the excluded instance types once their exclusions expire", func() {
+			exclude("m5.large")
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Status.ExcludedInstanceTypes).To(HaveLen(1))
+			awsEnv.BootstrapFailuresCache.Flush()
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Status.ExcludedInstanceTypes).To(BeEmpty())
+		})
+		It("should not report the instance types that are excluded from other node templates", func() {
+			awsEnv.BootstrapFailuresCache.MarkFailed(nodeclassutil.Key{Name: nodeTemplate.Name}, "m5.large", "machine", "node didn't register within 15m0s")
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				awsEnv.BootstrapFailuresCache.MarkFailed(nodeclassutil.Key{Name: "other", IsNodeTemplate: true}, "m5.large", fmt.Sprintf("machine-%d", i), "node didn't register within 15m0s")
+			}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Status.ExcludedInstanceTypes).To(BeEmpty())
+		})
 	})
 	Context("AWSNodeTemplate Static Drift Hash", func() {
 		DescribeTable("should update the static drift hash when nodeTemplate static field is updated", func(awsnodetemplatespec v1alpha1.AWSNodeTemplateSpec) {
@@ -748,6 +1125,7 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			Entry("Context Drift", v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{Context: aws.String("context-2")}}),
 			Entry("DetailedMonitoring Drift", v1alpha1.AWSNodeTemplateSpec{DetailedMonitoring: aws.Bool(true)}),
 			Entry("AMIFamily Drift", v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{AMIFamily: aws.String(v1alpha1.AMIFamilyBottlerocket)}}),
+			Entry("TrustedBoot Drift", v1alpha1.AWSNodeTemplateSpec{TrustedBoot: &v1alpha1.TrustedBoot{SecureBoot: true}}),
 		)
 		It("should not update the static drift hash when nodeTemplate dynamic field is updated", func() {
 			ExpectApplied(ctx, env.Client, nodeTemplate)
@@ -0,0 +1,75 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package offerings
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/multierr"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/providers/instancetype"
+)
+
+// Controller refreshes the region's instance types and the zones they're offered in in the background every
+// aws.offeringsRefreshInterval, so that listing instance types during a scale-up serves them from memory rather than
+// waiting for EC2 when they would otherwise have expired. Spot prices are left to the pricing controller.
+type Controller struct {
+	instanceTypeProvider *instancetype.Provider
+}
+
+func NewController(instanceTypeProvider *instancetype.Provider) *Controller {
+	return &Controller{
+		instanceTypeProvider: instanceTypeProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "offerings"
+}
+
+// refresh updates a resource that instance types are listed from
+type refresh struct {
+	resource string
+	update   func(context.Context) error
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	// Instance types are refreshed last, since refreshing them makes instance types be listed again with the
+	// offerings that were just refreshed
+	refreshes := []refresh{
+		{resource: offeringsResource, update: c.instanceTypeProvider.UpdateInstanceTypeOfferings},
+		{resource: instanceTypesResource, update: c.instanceTypeProvider.UpdateInstanceTypes},
+	}
+
+	var errs error
+	for _, r := range refreshes {
+		if err := r.update(ctx); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("refreshing %s, %w", r.resource, err))
+			continue
+		}
+		LastRefreshTime.With(prometheus.Labels{resourceLabel: r.resource}).SetToCurrentTime()
+	}
+	return reconcile.Result{RequeueAfter: settings.FromContext(ctx).OfferingsRefreshInterval}, errs
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
@@ -0,0 +1,48 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package offerings
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	resourceLabel          = "resource"
+
+	instanceTypesResource = "instance_types"
+	offeringsResource     = "offerings"
+)
+
+var (
+	LastRefreshTime = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "offerings_last_refresh_time_seconds",
+			Help:      "The time, in seconds since the epoch, that the region's instance types or instance type offerings were last refreshed in the background. Labeled by the refreshed resource.",
+		},
+		[]string{
+			resourceLabel,
+		},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(LastRefreshTime)
+}
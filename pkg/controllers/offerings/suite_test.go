@@ -0,0 +1,164 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package offerings_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecloudprovider "github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/offerings"
+	"github.com/aws/karpenter/pkg/test"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var controller *offerings.Controller
+var nodeTemplate *v1alpha1.AWSNodeTemplate
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Offerings")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv = test.NewEnvironment(ctx, env)
+	controller = offerings.NewController(awsEnv.InstanceTypesProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv.Reset()
+	offerings.LastRefreshTime.Reset()
+	nodeTemplate = &v1alpha1.AWSNodeTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: coretest.RandomName(),
+		},
+		Spec: v1alpha1.AWSNodeTemplateSpec{
+			AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+			},
+		},
+	}
+})
+
+var _ = Describe("Offerings", func() {
+	It("should list instance types without calling EC2 after refreshing them", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		describeInstanceTypes, describeOfferings := awsEnv.EC2API.Calls.Get("DescribeInstanceTypes"), awsEnv.EC2API.Calls.Get("DescribeInstanceTypeOfferings")
+		instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, &corev1beta1.KubeletConfiguration{}, nodeclassutil.New(nodeTemplate))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instanceTypes).ToNot(BeEmpty())
+		Expect(awsEnv.EC2API.Calls.Get("DescribeInstanceTypes")).To(Equal(describeInstanceTypes))
+		Expect(awsEnv.EC2API.Calls.Get("DescribeInstanceTypeOfferings")).To(Equal(describeOfferings))
+	})
+	It("should list instance types with the offerings of the latest refresh", func() {
+		instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, &corev1beta1.KubeletConfiguration{}, nodeclassutil.New(nodeTemplate))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(zones(instanceTypes, "m5.large")).To(ConsistOf("test-zone-1a", "test-zone-1b", "test-zone-1c"))
+
+		awsEnv.EC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{
+			InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+				{InstanceType: aws.String("m5.large"), Location: aws.String("test-zone-1a")},
+				{InstanceType: aws.String("m5.xlarge"), Location: aws.String("test-zone-1b")},
+			},
+		})
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		instanceTypes, err = awsEnv.InstanceTypesProvider.List(ctx, &corev1beta1.KubeletConfiguration{}, nodeclassutil.New(nodeTemplate))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(zones(instanceTypes, "m5.large")).To(ConsistOf("test-zone-1a"))
+		Expect(zones(instanceTypes, "m5.xlarge")).To(ConsistOf("test-zone-1b"))
+		Expect(zones(instanceTypes, "m5.2xlarge")).To(BeEmpty())
+	})
+	It("should keep the listed instance types when a refresh doesn't change them", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, &corev1beta1.KubeletConfiguration{}, nodeclassutil.New(nodeTemplate))
+		Expect(err).ToNot(HaveOccurred())
+
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		refreshed, err := awsEnv.InstanceTypesProvider.List(ctx, &corev1beta1.KubeletConfiguration{}, nodeclassutil.New(nodeTemplate))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(refreshed).To(HaveLen(len(instanceTypes)))
+		for i := range refreshed {
+			Expect(refreshed[i]).To(BeIdenticalTo(instanceTypes[i]))
+		}
+	})
+	It("should not refresh spot prices", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(awsEnv.EC2API.DescribeSpotPriceHistoryInput.IsNil()).To(BeTrue())
+	})
+	It("should record when each resource was last refreshed", func() {
+		start := time.Now()
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		for _, resource := range []string{"instance_types", "offerings"} {
+			metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_offerings_last_refresh_time_seconds", map[string]string{"resource": resource})
+			Expect(ok).To(BeTrue())
+			Expect(metric.GetGauge().GetValue()).To(BeNumerically(">=", float64(start.Unix())))
+		}
+	})
+	It("should requeue after the refresh interval", func() {
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{OfferingsRefreshInterval: lo.ToPtr(15 * time.Minute)}))
+		result := ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(result.RequeueAfter).To(Equal(15 * time.Minute))
+	})
+	It("should refresh the other resources when one of them fails", func() {
+		// The offerings are refreshed first, so they get the error
+		awsEnv.EC2API.NextError.Set(errors.New("throttled"))
+		ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+		_, ok := FindMetricWithLabelValues("karpenter_cloudprovider_offerings_last_refresh_time_seconds", map[string]string{"resource": "offerings"})
+		Expect(ok).To(BeFalse())
+		_, ok = FindMetricWithLabelValues("karpenter_cloudprovider_offerings_last_refresh_time_seconds", map[string]string{"resource": "instance_types"})
+		Expect(ok).To(BeTrue())
+	})
+})
+
+func zones(instanceTypes []*corecloudprovider.InstanceType, name string) []string {
+	instanceType, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == name })
+	if !ok {
+		return nil
+	}
+	return lo.Uniq(lo.Map(instanceType.Offerings, func(o corecloudprovider.Offering, _ int) string { return o.Zone }))
+}
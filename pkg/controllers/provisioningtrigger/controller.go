@@ -0,0 +1,206 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioningtrigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	sqsapi "github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corecloudprovider "github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/controllers/provisioning/scheduling"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	corescheduling "github.com/aws/karpenter-core/pkg/scheduling"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/controllers/interruption"
+)
+
+// maxVisibilityTimeout is the longest that SQS can hide a message for. Triggers that are due later than that are
+// hidden several times until they're due.
+const maxVisibilityTimeout = 12 * time.Hour
+
+// Controller continually polls an SQS queue for provisioning triggers that are sent by users or EventBridge rules,
+// and creates Machines for the triggers' Provisioners once they're due. This lets external schedulers, like batch
+// queues, launch nodes ahead of the pods that will need them.
+type Controller struct {
+	kubeClient    client.Client
+	clk           clock.Clock
+	cloudProvider *cloudprovider.CloudProvider
+	sqsProvider   *interruption.SQSProvider
+	cm            *pretty.ChangeMonitor
+}
+
+func NewController(kubeClient client.Client, clk clock.Clock, cloudProvider *cloudprovider.CloudProvider, sqsProvider *interruption.SQSProvider) *Controller {
+	return &Controller{
+		kubeClient:    kubeClient,
+		clk:           clk,
+		cloudProvider: cloudProvider,
+		sqsProvider:   sqsProvider,
+		cm:            pretty.NewChangeMonitor(),
+	}
+}
+
+// NewSQSProvider returns an SQS provider for the queue named by the aws.provisioningTriggerQueueName setting
+func NewSQSProvider(client sqsiface.SQSAPI) *interruption.SQSProvider {
+	return interruption.NewSQSProviderForQueue(client, func(ctx context.Context) string {
+		return settings.FromContext(ctx).ProvisioningTriggerQueueName
+	})
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("queue", settings.FromContext(ctx).ProvisioningTriggerQueueName))
+	if c.cm.HasChanged(settings.FromContext(ctx).ProvisioningTriggerQueueName, nil) {
+		logging.FromContext(ctx).Debugf("watching provisioning trigger queue")
+	}
+	sqsMessages, err := c.sqsProvider.GetSQSMessages(ctx)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("getting messages from queue, %w", err)
+	}
+	var errs error
+	for _, msg := range sqsMessages {
+		errs = multierr.Append(errs, c.handleMessage(ctx, msg))
+	}
+	return reconcile.Result{}, errs
+}
+
+func (c *Controller) Name() string {
+	return "provisioningtrigger"
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// handleMessage creates the Machines for the message's trigger if it's due, or hides the message until it's due.
+// Messages that fail to be handled are received again once their visibility timeout expires, so creating the
+// Machines is retried.
+func (c *Controller) handleMessage(ctx context.Context, msg *sqsapi.Message) error {
+	receivedMessages.Inc()
+	id := aws.StringValue(msg.MessageId)
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("message", id))
+	trigger, err := Parse(aws.StringValue(msg.Body))
+	if err != nil {
+		// If we fail to parse, then we should delete the message but still log the error
+		logging.FromContext(ctx).Errorf("parsing message, %v", err)
+		droppedMessages.Inc()
+		return c.sqsProvider.DeleteSQSMessage(ctx, msg)
+	}
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("provisioner", trigger.Provisioner))
+	if due := trigger.Due(c.clk.Now()); due > 0 {
+		return c.sqsProvider.ChangeMessageVisibility(ctx, msg, lo.Clamp(due, time.Second, maxVisibilityTimeout))
+	}
+	if err = c.provision(ctx, id, trigger); err != nil {
+		if !isUnsatisfiable(err) {
+			return fmt.Errorf("provisioning for trigger, %w", err)
+		}
+		// Triggers that can't be satisfied are dropped rather than retried until the message expires
+		logging.FromContext(ctx).Errorf("provisioning for trigger, %v", err)
+		droppedMessages.Inc()
+	}
+	return c.sqsProvider.DeleteSQSMessage(ctx, msg)
+}
+
+// provision creates the trigger's Machines. Machines are annotated with the id of the message, so that a message that
+// is received again after a partial failure only creates the Machines that are missing.
+func (c *Controller) provision(ctx context.Context, id string, trigger Trigger) error {
+	provisioner := &v1alpha5.Provisioner{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: trigger.Provisioner}, provisioner); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return unsatisfiableError{error: fmt.Errorf("provisioner not found")}
+		}
+		return fmt.Errorf("getting provisioner, %w", err)
+	}
+	if !provisioner.DeletionTimestamp.IsZero() {
+		return unsatisfiableError{error: fmt.Errorf("provisioner is being deleted")}
+	}
+	machineList := &v1alpha5.MachineList{}
+	if err := c.kubeClient.List(ctx, machineList, client.MatchingLabels{v1alpha5.ProvisionerNameLabelKey: provisioner.Name}); err != nil {
+		return fmt.Errorf("listing machines, %w", err)
+	}
+	created := lo.CountBy(machineList.Items, func(m v1alpha5.Machine) bool {
+		return m.Annotations[v1alpha1.AnnotationProvisioningTrigger] == id
+	})
+	if created >= trigger.Count {
+		return nil
+	}
+	if provisioner.Spec.Limits != nil {
+		if err := provisioner.Spec.Limits.ExceededBy(provisioner.Status.Resources); err != nil {
+			return unsatisfiableError{error: fmt.Errorf("provisioner limits, %w", err)}
+		}
+	}
+	instanceTypes, err := c.cloudProvider.GetInstanceTypes(ctx, provisioner)
+	if err != nil {
+		return fmt.Errorf("getting instance types, %w", err)
+	}
+	for i := created; i < trigger.Count; i++ {
+		machine, err := newMachine(provisioner, instanceTypes, trigger)
+		if err != nil {
+			return err
+		}
+		// The nodes are empty until the pods they're launched for arrive, so consolidation mustn't remove them first
+		machine.Annotations = lo.Assign(machine.Annotations, map[string]string{
+			v1alpha1.AnnotationProvisioningTrigger:     id,
+			v1alpha5.DoNotConsolidateNodeAnnotationKey: "true",
+		})
+		if err = c.kubeClient.Create(ctx, machine); err != nil {
+			return fmt.Errorf("creating machine, %w", err)
+		}
+		createdMachines.WithLabelValues(provisioner.Name).Inc()
+		logging.FromContext(ctx).With("machine", machine.Name).Infof("created machine for provisioning trigger")
+	}
+	return nil
+}
+
+// newMachine returns a Machine for the Provisioner, as the provisioning controller would create for pods that
+// tolerate the Provisioner and require the trigger's requirements
+func newMachine(provisioner *v1alpha5.Provisioner, instanceTypes []*corecloudprovider.InstanceType, trigger Trigger) (*v1alpha5.Machine, error) {
+	template := scheduling.NewNodeClaimTemplate(nodepoolutil.New(provisioner))
+	template.Requirements.Add(corescheduling.NewNodeSelectorRequirements(trigger.Requirements...).Values()...)
+	template.InstanceTypeOptions = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+		return i.Requirements.Intersects(template.Requirements) == nil &&
+			len(i.Offerings.Available().Requirements(template.Requirements)) > 0
+	})
+	if len(template.InstanceTypeOptions) == 0 {
+		return nil, unsatisfiableError{error: fmt.Errorf("no instance type satisfies the requirements of the provisioner and trigger")}
+	}
+	return template.ToMachine(provisioner), nil
+}
+
+// unsatisfiableError is returned for triggers that can't be provisioned for as they are, so retrying them won't help
+type unsatisfiableError struct {
+	error
+}
+
+func isUnsatisfiable(err error) bool {
+	return errors.As(err, &unsatisfiableError{})
+}
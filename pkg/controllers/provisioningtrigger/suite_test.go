@@ -0,0 +1,248 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioningtrigger_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/controllers/interruption"
+	"github.com/aws/karpenter/pkg/controllers/provisioningtrigger"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var sqsapi *fake.SQSAPI
+var sqsProvider *interruption.SQSProvider
+var fakeClock *clock.FakeClock
+var controller *provisioningtrigger.Controller
+
+var nodeTemplate *v1alpha1.AWSNodeTemplate
+var provisioner *v1alpha5.Provisioner
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ProvisioningTrigger")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+		ProvisioningTriggerQueueName: lo.ToPtr("test-cluster-triggers"),
+	}))
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+	fakeClock = clock.NewFakeClock(time.Now())
+	sqsapi = &fake.SQSAPI{}
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	sqsapi.Reset()
+	sqsProvider = provisioningtrigger.NewSQSProvider(sqsapi)
+	cloudProvider := cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, events.NewRecorder(&record.FakeRecorder{}),
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.WarmUp, fakeClock)
+	controller = provisioningtrigger.NewController(env.Client, fakeClock, cloudProvider, sqsProvider)
+
+	nodeTemplate = test.AWSNodeTemplate()
+	provisioner = test.Provisioner(coretest.ProvisionerOptions{
+		ProviderRef: &v1alpha5.MachineTemplateRef{
+			APIVersion: nodeTemplate.APIVersion,
+			Kind:       nodeTemplate.Kind,
+			Name:       nodeTemplate.Name,
+		},
+	})
+	ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("ProvisioningTrigger", func() {
+	It("should create machines for the provisioner of a trigger", func() {
+		sqsapi.Enqueue(triggerMessage(provisioningtrigger.Trigger{Provisioner: provisioner.Name, Count: 3}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		machines := ExpectTriggeredMachines(provisioner.Name)
+		Expect(machines).To(HaveLen(3))
+		for _, machine := range machines {
+			Expect(machine.Labels).To(HaveKeyWithValue(v1alpha5.ProvisionerNameLabelKey, provisioner.Name))
+			Expect(machine.OwnerReferences).To(ContainElement(HaveField("Name", provisioner.Name)))
+			Expect(machine.Spec.MachineTemplateRef.Name).To(Equal(nodeTemplate.Name))
+		}
+		Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+	})
+	It("should create machines for triggers that are the detail of EventBridge events", func() {
+		sqsapi.Enqueue(string(lo.Must(json.Marshal(map[string]any{
+			"version":     "0",
+			"source":      "custom.batch",
+			"detail-type": "Karpenter Provisioning Trigger",
+			"detail":      provisioningtrigger.Trigger{Provisioner: provisioner.Name, Count: 2},
+		}))))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		Expect(ExpectTriggeredMachines(provisioner.Name)).To(HaveLen(2))
+		Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+	})
+	It("should exclude the machines from consolidation", func() {
+		sqsapi.Enqueue(triggerMessage(provisioningtrigger.Trigger{Provisioner: provisioner.Name, Count: 1}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		machines := ExpectTriggeredMachines(provisioner.Name)
+		Expect(machines).To(HaveLen(1))
+		Expect(machines[0].Annotations).To(HaveKeyWithValue(v1alpha5.DoNotConsolidateNodeAnnotationKey, "true"))
+	})
+	It("should constrain machines to the requirements of the trigger", func() {
+		sqsapi.Enqueue(triggerMessage(provisioningtrigger.Trigger{
+			Provisioner: provisioner.Name,
+			Count:       1,
+			Requirements: []v1.NodeSelectorRequirement{
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+				{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"}},
+			},
+		}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		machines := ExpectTriggeredMachines(provisioner.Name)
+		Expect(machines).To(HaveLen(1))
+		Expect(machines[0].Spec.Requirements).To(ContainElements(
+			v1.NodeSelectorRequirement{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+			v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"}},
+		))
+	})
+	It("should defer triggers until they're due", func() {
+		sqsapi.Enqueue(triggerMessage(provisioningtrigger.Trigger{Provisioner: provisioner.Name, Count: 1, At: lo.ToPtr(fakeClock.Now().Add(time.Hour))}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		Expect(ExpectTriggeredMachines(provisioner.Name)).To(BeEmpty())
+		Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(0))
+		Expect(sqsapi.ChangeMessageVisibilityBehavior.SuccessfulCalls()).To(Equal(1))
+		Expect(aws.Int64Value(sqsapi.ChangeMessageVisibilityBehavior.CalledWithInput.Pop().VisibilityTimeout)).To(BeNumerically("==", time.Hour.Seconds()))
+	})
+	It("should defer triggers that are due in more than 12 hours several times", func() {
+		sqsapi.Enqueue(triggerMessage(provisioningtrigger.Trigger{Provisioner: provisioner.Name, Count: 1, At: lo.ToPtr(fakeClock.Now().Add(48 * time.Hour))}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		Expect(aws.Int64Value(sqsapi.ChangeMessageVisibilityBehavior.CalledWithInput.Pop().VisibilityTimeout)).To(BeNumerically("==", (12 * time.Hour).Seconds()))
+	})
+	It("should create machines for triggers that were due in the past", func() {
+		sqsapi.Enqueue(triggerMessage(provisioningtrigger.Trigger{Provisioner: provisioner.Name, Count: 1, At: lo.ToPtr(fakeClock.Now().Add(-time.Minute))}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		Expect(ExpectTriggeredMachines(provisioner.Name)).To(HaveLen(1))
+		Expect(sqsapi.ChangeMessageVisibilityBehavior.Calls()).To(Equal(0))
+	})
+	It("should only create the missing machines for a message that is received again", func() {
+		sqsapi.Enqueue(triggerMessage(provisioningtrigger.Trigger{Provisioner: provisioner.Name, Count: 2}))
+		sqsapi.DeleteMessageBehavior.Error.Set(fmt.Errorf("failed"))
+		ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+		Expect(ExpectTriggeredMachines(provisioner.Name)).To(HaveLen(2))
+
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(ExpectTriggeredMachines(provisioner.Name)).To(HaveLen(2))
+		Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+	})
+	It("should delete messages that aren't valid triggers", func() {
+		sqsapi.Enqueue(`{"provisioner": "", "count": 1}`)
+		sqsapi.Enqueue(`not json`)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		Expect(ExpectTriggeredMachines(provisioner.Name)).To(BeEmpty())
+		Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(2))
+	})
+	It("should delete messages for provisioners that don't exist", func() {
+		sqsapi.Enqueue(triggerMessage(provisioningtrigger.Trigger{Provisioner: "missing", Count: 1}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+	})
+	It("should delete messages for provisioners that exceeded their limits", func() {
+		provisioner.Spec.Limits = &v1alpha5.Limits{Resources: v1.ResourceList{v1.ResourceCPU: resource.MustParse("10")}}
+		provisioner.Status.Resources = v1.ResourceList{v1.ResourceCPU: resource.MustParse("16")}
+		ExpectApplied(ctx, env.Client, provisioner)
+		sqsapi.Enqueue(triggerMessage(provisioningtrigger.Trigger{Provisioner: provisioner.Name, Count: 1}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		Expect(ExpectTriggeredMachines(provisioner.Name)).To(BeEmpty())
+		Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+	})
+})
+
+var _ = Describe("Trigger", func() {
+	DescribeTable("should fail to parse invalid triggers",
+		func(body string) {
+			_, err := provisioningtrigger.Parse(body)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("without a provisioner", `{"count": 1}`),
+		Entry("without a count", `{"provisioner": "default"}`),
+		Entry("with a negative count", `{"provisioner": "default", "count": -1}`),
+		Entry("with an invalid requirement", `{"provisioner": "default", "count": 1, "requirements": [{"key": "foo", "operator": "Gt", "values": ["a", "b"]}]}`),
+		Entry("with an invalid time", `{"provisioner": "default", "count": 1, "at": "tomorrow"}`),
+		Entry("that isn't json", `default,1`),
+	)
+	It("should parse the detail of EventBridge events", func() {
+		trigger, err := provisioningtrigger.Parse(`{"version": "0", "detail-type": "Karpenter Provisioning Trigger", "detail": {"provisioner": "default", "count": 2, "at": "2023-01-01T08:00:00Z"}}`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(trigger.Provisioner).To(Equal("default"))
+		Expect(trigger.Count).To(Equal(2))
+		Expect(trigger.At.Equal(time.Date(2023, 1, 1, 8, 0, 0, 0, time.UTC))).To(BeTrue())
+	})
+})
+
+func triggerMessage(trigger provisioningtrigger.Trigger) string {
+	return string(lo.Must(json.Marshal(trigger)))
+}
+
+func ExpectTriggeredMachines(provisionerName string) []v1alpha5.Machine {
+	machineList := &v1alpha5.MachineList{}
+	Expect(env.Client.List(ctx, machineList)).To(Succeed())
+	return lo.Filter(machineList.Items, func(m v1alpha5.Machine, _ int) bool {
+		return m.Labels[v1alpha5.ProvisionerNameLabelKey] == provisionerName && m.Annotations[v1alpha1.AnnotationProvisioningTrigger] != ""
+	})
+}
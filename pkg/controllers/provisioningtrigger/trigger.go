@@ -0,0 +1,88 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioningtrigger
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+)
+
+// Trigger asks for nodes to be launched for a Provisioner ahead of the pods that will need them, e.g. by a batch
+// scheduler that knows when its next jobs start
+type Trigger struct {
+	// Provisioner is the name of the Provisioner that the nodes are launched for
+	Provisioner string `json:"provisioner"`
+	// Count is the number of nodes to launch
+	Count int `json:"count"`
+	// At is the time to launch the nodes at. Nodes are launched right away if it's unset or in the past.
+	At *time.Time `json:"at,omitempty"`
+	// Requirements further constrain the Provisioner's requirements for the nodes, e.g. to an instance type or zone
+	Requirements []v1.NodeSelectorRequirement `json:"requirements,omitempty"`
+}
+
+// envelope is the part of an EventBridge event that wraps the trigger, for triggers that are sent to the queue by
+// EventBridge rules rather than directly
+type envelope struct {
+	Detail json.RawMessage `json:"detail"`
+}
+
+// Parse parses a trigger from the body of an SQS message, which is either the trigger or an EventBridge event
+// whose detail is the trigger
+func Parse(body string) (Trigger, error) {
+	e := envelope{}
+	if err := json.Unmarshal([]byte(body), &e); err != nil {
+		return Trigger{}, fmt.Errorf("unmarshalling the message, %w", err)
+	}
+	raw := []byte(body)
+	if len(e.Detail) > 0 {
+		raw = e.Detail
+	}
+	trigger := Trigger{}
+	if err := json.Unmarshal(raw, &trigger); err != nil {
+		return Trigger{}, fmt.Errorf("unmarshalling the trigger, %w", err)
+	}
+	if err := trigger.Validate(); err != nil {
+		return Trigger{}, fmt.Errorf("validating the trigger, %w", err)
+	}
+	return trigger, nil
+}
+
+func (t Trigger) Validate() error {
+	if t.Provisioner == "" {
+		return fmt.Errorf("provisioner is required")
+	}
+	if t.Count <= 0 {
+		return fmt.Errorf("count must be positive, got %d", t.Count)
+	}
+	for _, requirement := range t.Requirements {
+		if err := v1alpha5.ValidateRequirement(requirement); err != nil {
+			return fmt.Errorf("invalid requirement %s, %w", requirement.Key, err)
+		}
+	}
+	return nil
+}
+
+// Due returns how long to wait before the trigger's nodes are launched
+func (t Trigger) Due(now time.Time) time.Duration {
+	if t.At == nil || !t.At.After(now) {
+		return 0
+	}
+	return t.At.Sub(now)
+}
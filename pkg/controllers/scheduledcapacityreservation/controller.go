@@ -0,0 +1,160 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduledcapacityreservation
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/logging"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/providers/capacityreservation"
+	"github.com/aws/karpenter/pkg/utils/attribution"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// LeadTime is how long before a scheduled scale-up starts that its capacity reservation is created, so that there's
+// time to retry if the capacity isn't available right away
+var LeadTime = 15 * time.Minute
+
+// Controller holds the scheduled capacity reservations of a NodeClass: it creates a targeted capacity reservation
+// shortly before each scheduled scale-up starts and cancels it once the scale-up is over, or once the scheduled
+// reservation is removed from the NodeClass
+type Controller struct {
+	clk                         clock.Clock
+	recorder                    events.Recorder
+	capacityReservationProvider *capacityreservation.Provider
+}
+
+func NewController(clk clock.Clock, recorder events.Recorder, capacityReservationProvider *capacityreservation.Provider) *Controller {
+	return &Controller{
+		clk:                         clk,
+		recorder:                    recorder,
+		capacityReservationProvider: capacityReservationProvider,
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context, nodeClass *v1beta1.NodeClass) (reconcile.Result, error) {
+	ctx = attribution.WithNodeClass(ctx, nodeClass.Name)
+	reservations, err := c.capacityReservationProvider.ListScheduled(ctx, nodeClass)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	held := sets.New[string]()
+	var errs error
+	if nodeClass.DeletionTimestamp.IsZero() {
+		for _, scheduled := range nodeClass.Spec.ScheduledCapacityReservations {
+			startsAt, ok := scheduled.HeldAt(c.clk.Now(), LeadTime)
+			if !ok {
+				continue
+			}
+			endsAt := startsAt.Add(scheduled.Duration.Duration)
+			if reservation, ok := lo.Find(reservations, func(r *ec2.CapacityReservation) bool { return matches(r, scheduled, endsAt) }); ok {
+				held.Insert(aws.StringValue(reservation.CapacityReservationId))
+				continue
+			}
+			reservation, err := c.capacityReservationProvider.CreateScheduled(ctx, nodeClass, scheduled, endsAt)
+			if err != nil {
+				errs = multierr.Append(errs, err)
+				c.recorder.Publish(CapacityReservationFailed(nodeClass, scheduled, err))
+				continue
+			}
+			held.Insert(aws.StringValue(reservation.CapacityReservationId))
+			logging.FromContext(ctx).With(
+				"capacity-reservation", aws.StringValue(reservation.CapacityReservationId),
+				"instance-type", scheduled.InstanceType,
+				"zone", scheduled.AvailabilityZone,
+				"count", scheduled.InstanceCount,
+				"ends-at", endsAt.Format(time.RFC3339)).Infof("created scheduled capacity reservation")
+		}
+	}
+	for _, reservation := range reservations {
+		id := aws.StringValue(reservation.CapacityReservationId)
+		if held.Has(id) {
+			continue
+		}
+		if err := c.capacityReservationProvider.Cancel(ctx, id); err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		logging.FromContext(ctx).With("capacity-reservation", id).Infof("cancelled scheduled capacity reservation")
+	}
+	if len(nodeClass.Spec.ScheduledCapacityReservations) == 0 {
+		return reconcile.Result{}, errs
+	}
+	return reconcile.Result{RequeueAfter: time.Minute}, errs
+}
+
+// matches returns true if the capacity reservation was created for the occurrence of the scheduled reservation that
+// ends at the given time
+func matches(reservation *ec2.CapacityReservation, scheduled v1beta1.ScheduledCapacityReservation, endsAt time.Time) bool {
+	return aws.StringValue(reservation.InstanceType) == scheduled.InstanceType &&
+		aws.StringValue(reservation.AvailabilityZone) == scheduled.AvailabilityZone &&
+		aws.Int64Value(reservation.TotalInstanceCount) == scheduled.InstanceCount &&
+		aws.TimeValue(reservation.EndDate).Truncate(time.Minute).Equal(endsAt)
+}
+
+//nolint:revive
+type NodeTemplateController struct {
+	*Controller
+}
+
+func NewNodeTemplateController(kubeClient client.Client, clk clock.Clock, recorder events.Recorder,
+	capacityReservationProvider *capacityreservation.Provider) corecontroller.Controller {
+	return corecontroller.Typed[*v1alpha1.AWSNodeTemplate](kubeClient, &NodeTemplateController{
+		Controller: NewController(clk, recorder, capacityReservationProvider),
+	})
+}
+
+func (c *NodeTemplateController) Reconcile(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (reconcile.Result, error) {
+	return c.Controller.Reconcile(ctx, nodeclassutil.New(nodeTemplate))
+}
+
+func (c *NodeTemplateController) Name() string {
+	return "awsnodetemplate.scheduledcapacityreservation"
+}
+
+func (c *NodeTemplateController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(&v1alpha1.AWSNodeTemplate{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		WithOptions(controller.Options{
+			RateLimiter: workqueue.NewMaxOfRateLimiter(
+				workqueue.NewItemExponentialFailureRateLimiter(time.Second, time.Minute),
+				// 10 qps, 100 bucket size
+				&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+			),
+			MaxConcurrentReconciles: 10,
+		}))
+}
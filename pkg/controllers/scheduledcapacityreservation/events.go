@@ -0,0 +1,41 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduledcapacityreservation
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	nodetemplateutil "github.com/aws/karpenter/pkg/utils/nodetemplate"
+)
+
+func CapacityReservationFailed(nodeClass *v1beta1.NodeClass, scheduled v1beta1.ScheduledCapacityReservation, err error) events.Event {
+	var involvedObject runtime.Object = nodeClass
+	if nodeClass.IsNodeTemplate {
+		involvedObject = nodetemplateutil.New(nodeClass)
+	}
+	return events.Event{
+		InvolvedObject: involvedObject,
+		Type:           v1.EventTypeWarning,
+		Reason:         "ScheduledCapacityReservationFailed",
+		Message: fmt.Sprintf("Failed to reserve %d %s instances in %s for the scale-up at %s, %s",
+			scheduled.InstanceCount, scheduled.InstanceType, scheduled.AvailabilityZone, scheduled.Start, err),
+		DedupeValues: []string{string(nodeClass.UID), scheduled.InstanceType, scheduled.AvailabilityZone, scheduled.Start},
+	}
+}
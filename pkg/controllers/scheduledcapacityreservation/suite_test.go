@@ -0,0 +1,205 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduledcapacityreservation_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/events"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/scheduledcapacityreservation"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var fakeClock *clock.FakeClock
+var controller corecontroller.Controller
+var nodeTemplate *v1alpha1.AWSNodeTemplate
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ScheduledCapacityReservation")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv = test.NewEnvironment(ctx, env)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	// Wednesday, ten minutes before the scheduled scale-up starts
+	fakeClock = clock.NewFakeClock(time.Date(2023, time.September, 6, 7, 50, 0, 0, time.UTC))
+	controller = scheduledcapacityreservation.NewNodeTemplateController(env.Client, fakeClock, events.NewRecorder(&record.FakeRecorder{}), awsEnv.CapacityReservationProvider)
+	nodeTemplate = &v1alpha1.AWSNodeTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: coretest.RandomName(),
+		},
+		Spec: v1alpha1.AWSNodeTemplateSpec{
+			AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+			},
+			ScheduledCapacityReservations: []v1alpha1.ScheduledCapacityReservation{{
+				InstanceType:     "m5.xlarge",
+				AvailabilityZone: "test-zone-1a",
+				InstanceCount:    10,
+				Days:             []string{"Wednesday"},
+				Start:            "08:00",
+				Duration:         metav1.Duration{Duration: 2 * time.Hour},
+			}},
+		},
+	}
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("ScheduledCapacityReservation", func() {
+	It("should create a targeted capacity reservation within the lead time of a scheduled scale-up", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		Expect(awsEnv.EC2API.CreateCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(1))
+		input := awsEnv.EC2API.CreateCapacityReservationBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValue(input.InstanceType)).To(Equal("m5.xlarge"))
+		Expect(aws.StringValue(input.AvailabilityZone)).To(Equal("test-zone-1a"))
+		Expect(aws.Int64Value(input.InstanceCount)).To(BeNumerically("==", 10))
+		Expect(aws.StringValue(input.InstancePlatform)).To(Equal(ec2.CapacityReservationInstancePlatformLinuxUnix))
+		Expect(aws.StringValue(input.InstanceMatchCriteria)).To(Equal(ec2.InstanceMatchCriteriaTargeted))
+		Expect(aws.StringValue(input.EndDateType)).To(Equal(ec2.EndDateTypeLimited))
+		Expect(aws.TimeValue(input.EndDate)).To(Equal(time.Date(2023, time.September, 6, 10, 0, 0, 0, time.UTC)))
+		Expect(input.TagSpecifications).To(HaveLen(1))
+		Expect(input.TagSpecifications[0].Tags).To(ContainElements(
+			&ec2.Tag{Key: aws.String(v1alpha5.MachineManagedByAnnotationKey), Value: aws.String("test-cluster")},
+			&ec2.Tag{Key: aws.String(v1alpha1.TagScheduledCapacityReservation), Value: aws.String(nodeTemplate.Name)},
+		))
+	})
+	It("should create a capacity reservation for the windows platform for windows node templates", func() {
+		nodeTemplate.Spec.AMIFamily = aws.String(v1alpha1.AMIFamilyWindows2022)
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		Expect(awsEnv.EC2API.CreateCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(1))
+		input := awsEnv.EC2API.CreateCapacityReservationBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValue(input.InstancePlatform)).To(Equal(ec2.CapacityReservationInstancePlatformWindows))
+	})
+	It("should not create a capacity reservation before the lead time of a scheduled scale-up", func() {
+		fakeClock.SetTime(time.Date(2023, time.September, 6, 7, 40, 0, 0, time.UTC))
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		Expect(awsEnv.EC2API.CreateCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(0))
+	})
+	It("should not create a capacity reservation on days without a scheduled scale-up", func() {
+		fakeClock.SetTime(time.Date(2023, time.September, 7, 7, 50, 0, 0, time.UTC))
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		Expect(awsEnv.EC2API.CreateCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(0))
+	})
+	It("should reuse the capacity reservation that was already created for the scheduled scale-up", func() {
+		awsEnv.EC2API.DescribeCapacityReservationsOutput.Set(&ec2.DescribeCapacityReservationsOutput{CapacityReservations: []*ec2.CapacityReservation{
+			scheduledReservation("cr-held", nodeTemplate.Name, "test-cluster", time.Date(2023, time.September, 6, 10, 0, 0, 0, time.UTC)),
+		}})
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		Expect(awsEnv.EC2API.CreateCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(0))
+		Expect(awsEnv.EC2API.CancelCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(0))
+	})
+	It("should not recreate the capacity reservation while it is still pending", func() {
+		reservation := scheduledReservation("cr-pending", nodeTemplate.Name, "test-cluster", time.Date(2023, time.September, 6, 10, 0, 0, 0, time.UTC))
+		reservation.State = aws.String(ec2.CapacityReservationStatePending)
+		awsEnv.EC2API.DescribeCapacityReservationsOutput.Set(&ec2.DescribeCapacityReservationsOutput{CapacityReservations: []*ec2.CapacityReservation{reservation}})
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		Expect(awsEnv.EC2API.CreateCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(0))
+		Expect(awsEnv.EC2API.CancelCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(0))
+	})
+	It("should cancel the capacity reservation once the scheduled scale-up is over", func() {
+		fakeClock.SetTime(time.Date(2023, time.September, 6, 10, 0, 0, 0, time.UTC))
+		awsEnv.EC2API.DescribeCapacityReservationsOutput.Set(&ec2.DescribeCapacityReservationsOutput{CapacityReservations: []*ec2.CapacityReservation{
+			scheduledReservation("cr-held", nodeTemplate.Name, "test-cluster", time.Date(2023, time.September, 6, 10, 0, 0, 0, time.UTC)),
+		}})
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		Expect(awsEnv.EC2API.CancelCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(1))
+		Expect(aws.StringValue(awsEnv.EC2API.CancelCapacityReservationBehavior.CalledWithInput.Pop().CapacityReservationId)).To(Equal("cr-held"))
+	})
+	It("should cancel the capacity reservation once the scheduled capacity reservation is removed", func() {
+		nodeTemplate.Spec.ScheduledCapacityReservations = nil
+		awsEnv.EC2API.DescribeCapacityReservationsOutput.Set(&ec2.DescribeCapacityReservationsOutput{CapacityReservations: []*ec2.CapacityReservation{
+			scheduledReservation("cr-held", nodeTemplate.Name, "test-cluster", time.Date(2023, time.September, 6, 10, 0, 0, 0, time.UTC)),
+		}})
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		Expect(awsEnv.EC2API.CancelCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(1))
+		Expect(aws.StringValue(awsEnv.EC2API.CancelCapacityReservationBehavior.CalledWithInput.Pop().CapacityReservationId)).To(Equal("cr-held"))
+	})
+	It("should ignore capacity reservations of other node templates and clusters", func() {
+		nodeTemplate.Spec.ScheduledCapacityReservations = nil
+		awsEnv.EC2API.DescribeCapacityReservationsOutput.Set(&ec2.DescribeCapacityReservationsOutput{CapacityReservations: []*ec2.CapacityReservation{
+			scheduledReservation("cr-other-template", "other-template", "test-cluster", time.Date(2023, time.September, 6, 10, 0, 0, 0, time.UTC)),
+			scheduledReservation("cr-other-cluster", nodeTemplate.Name, "other-cluster", time.Date(2023, time.September, 6, 10, 0, 0, 0, time.UTC)),
+			{CapacityReservationId: aws.String("cr-unmanaged"), InstanceType: aws.String("m5.xlarge"), AvailabilityZone: aws.String("test-zone-1a")},
+		}})
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		Expect(awsEnv.EC2API.CancelCapacityReservationBehavior.CalledWithInput.Len()).To(Equal(0))
+	})
+})
+
+func scheduledReservation(id, nodeTemplateName, clusterName string, endsAt time.Time) *ec2.CapacityReservation {
+	return &ec2.CapacityReservation{
+		CapacityReservationId:  aws.String(id),
+		InstanceType:           aws.String("m5.xlarge"),
+		AvailabilityZone:       aws.String("test-zone-1a"),
+		TotalInstanceCount:     aws.Int64(10),
+		AvailableInstanceCount: aws.Int64(10),
+		EndDate:                aws.Time(endsAt),
+		State:                  aws.String(ec2.CapacityReservationStateActive),
+		Tags: []*ec2.Tag{
+			{Key: aws.String(v1alpha5.MachineManagedByAnnotationKey), Value: aws.String(clusterName)},
+			{Key: aws.String(v1alpha1.TagScheduledCapacityReservation), Value: aws.String(nodeTemplateName)},
+		},
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spotplacementscore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/metrics"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/providers/instancetype"
+	"github.com/aws/karpenter/pkg/providers/spotplacementscore"
+	"github.com/aws/karpenter/pkg/utils/attribution"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// LowScore is the spot placement score at or below which spot launches for a NodePool are considered unlikely to
+// succeed in every zone it can launch into
+const LowScore = 3
+
+// Controller computes the spot placement score of each zone for every NodePool that can launch spot capacity, from the
+// instance types that a spot launch for the NodePool would request, so that operators can see when a NodePool's
+// requirements have become hard to satisfy before spot launches start failing
+type Controller struct {
+	kubeClient                 client.Client
+	recorder                   events.Recorder
+	instanceTypeProvider       *instancetype.Provider
+	spotPlacementScoreProvider *spotplacementscore.Provider
+}
+
+func NewController(kubeClient client.Client, recorder events.Recorder, instanceTypeProvider *instancetype.Provider,
+	spotPlacementScoreProvider *spotplacementscore.Provider) *Controller {
+	return &Controller{
+		kubeClient:                 kubeClient,
+		recorder:                   recorder,
+		instanceTypeProvider:       instanceTypeProvider,
+		spotPlacementScoreProvider: spotPlacementScoreProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "spotplacementscore"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	nodePoolList, err := nodepoolutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodepools, %w", err)
+	}
+	SpotPlacementScore.Reset()
+	for i := range nodePoolList.Items {
+		nodePool := &nodePoolList.Items[i]
+		if nodePool.Spec.Template.Spec.NodeClass == nil {
+			continue
+		}
+		log := logging.FromContext(ctx).With(lo.Ternary(nodePool.IsProvisioner, "provisioner", "nodepool"), nodePool.Name)
+		nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: nodePool.Spec.Template.Spec.NodeClass.Name, IsNodeTemplate: nodePool.IsProvisioner})
+		if err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				log.Errorf("resolving node class, %s", err)
+			}
+			continue
+		}
+		scores, err := c.scores(attribution.WithNodeClass(attribution.WithNodePool(ctx, nodePool.Name), nodeClass.Name), nodePool, nodeClass)
+		if err != nil {
+			log.Errorf("computing spot placement scores, %s", err)
+			continue
+		}
+		if len(scores) == 0 {
+			continue
+		}
+		for zone, score := range scores {
+			SpotPlacementScore.With(prometheus.Labels{metrics.NodePoolLabel: nodePool.Name, zoneLabel: zone}).Set(float64(score))
+		}
+		if best := lo.Max(lo.Values(scores)); best <= LowScore {
+			log.With("scores", scores).Debugf("spot launches are unlikely to succeed")
+			c.recorder.Publish(LowSpotPlacementScore(nodePool, best))
+		}
+	}
+	return reconcile.Result{RequeueAfter: time.Hour}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// scores returns the spot placement score of each zone that the NodePool can launch spot capacity into, for the
+// cheapest instance types that a spot launch for the NodePool would request. NodePools that can't launch spot capacity
+// aren't scored.
+func (c *Controller) scores(ctx context.Context, nodePool *corev1beta1.NodePool, nodeClass *v1beta1.NodeClass) (map[string]int64, error) {
+	requirements := scheduling.NewNodeSelectorRequirements(nodePool.Spec.Template.Spec.Requirements...)
+	if !requirements.Get(corev1beta1.CapacityTypeLabelKey).Has(corev1beta1.CapacityTypeSpot) {
+		return nil, nil
+	}
+	instanceTypes, err := c.instanceTypeProvider.List(ctx, nodePool.Spec.Template.Spec.KubeletConfiguration, nodeClass)
+	if err != nil {
+		return nil, fmt.Errorf("listing instance types, %w", err)
+	}
+	zones := sets.New[string]()
+	prices := map[string]float64{}
+	for _, it := range instanceTypes {
+		if it.Requirements.Compatible(requirements) != nil {
+			continue
+		}
+		offerings := lo.Filter(it.Offerings.Available().Requirements(requirements), func(o cloudprovider.Offering, _ int) bool {
+			return o.CapacityType == corev1beta1.CapacityTypeSpot
+		})
+		if len(offerings) == 0 {
+			continue
+		}
+		zones.Insert(lo.Map(offerings, func(o cloudprovider.Offering, _ int) string { return o.Zone })...)
+		prices[it.Name] = cloudprovider.Offerings(offerings).Cheapest().Price
+	}
+	if len(prices) == 0 {
+		return nil, nil
+	}
+	// Spot launches request at most the cheapest instance types, so only those contribute to the scores
+	names := lo.Keys(prices)
+	sort.Slice(names, func(i, j int) bool {
+		if prices[names[i]] == prices[names[j]] {
+			return names[i] < names[j]
+		}
+		return prices[names[i]] < prices[names[j]]
+	})
+	if len(names) > instance.MaxInstanceTypes {
+		names = names[:instance.MaxInstanceTypes]
+	}
+	scores, err := c.spotPlacementScoreProvider.Get(ctx, names, int64(settings.FromContext(ctx).SpotPlacementScoreTargetCapacity))
+	if err != nil {
+		return nil, err
+	}
+	return lo.PickBy(scores, func(zone string, _ int64) bool { return zones.Has(zone) }), nil
+}
@@ -0,0 +1,46 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spotplacementscore
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	zoneLabel              = "zone"
+)
+
+var (
+	SpotPlacementScore = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "nodepool_spot_placement_score",
+			Help:      "The spot placement score, from 1 to 10, of launching the configured target capacity for a nodepool in a zone, where 10 means that spot launches are highly likely to succeed. Only reported for nodepools that can launch spot capacity. Labeled by nodepool and zone.",
+		},
+		[]string{
+			metrics.NodePoolLabel,
+			zoneLabel,
+		},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(SpotPlacementScore)
+}
@@ -0,0 +1,216 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spotplacementscore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/spotplacementscore"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var recorder *coretest.EventRecorder
+var controller *spotplacementscore.Controller
+var nodeTemplate *v1alpha1.AWSNodeTemplate
+var provisioner *v1alpha5.Provisioner
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SpotPlacementScore")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{SpotPlacementScoreTargetCapacity: lo.ToPtr(50)}))
+	awsEnv = test.NewEnvironment(ctx, env)
+	recorder = coretest.NewEventRecorder()
+	controller = spotplacementscore.NewController(env.Client, recorder, awsEnv.InstanceTypesProvider, awsEnv.SpotPlacementScoreProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	recorder.Reset()
+	nodeTemplate = &v1alpha1.AWSNodeTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: coretest.RandomName(),
+		},
+		Spec: v1alpha1.AWSNodeTemplateSpec{
+			AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+			},
+		},
+	}
+	provisioner = coretest.Provisioner(coretest.ProvisionerOptions{
+		ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+		Requirements: []v1.NodeSelectorRequirement{{
+			Key:      v1alpha5.LabelCapacityType,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand},
+		}},
+	})
+	awsEnv.EC2API.GetSpotPlacementScoresBehavior.Output.Set(&ec2.GetSpotPlacementScoresOutput{SpotPlacementScores: []*ec2.SpotPlacementScore{
+		{AvailabilityZoneId: aws.String("testzone1a"), Score: aws.Int64(9)},
+		{AvailabilityZoneId: aws.String("testzone1b"), Score: aws.Int64(5)},
+		{AvailabilityZoneId: aws.String("testzone1c"), Score: aws.Int64(2)},
+	}})
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("SpotPlacementScore", func() {
+	It("should report the spot placement score of each zone", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		ExpectSpotPlacementScore(provisioner.Name, "test-zone-1a", 9)
+		ExpectSpotPlacementScore(provisioner.Name, "test-zone-1b", 5)
+		ExpectSpotPlacementScore(provisioner.Name, "test-zone-1c", 2)
+		Expect(recorder.Calls("LowSpotPlacementScore")).To(Equal(0))
+	})
+	It("should record the spot placement score of each zone", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		score, ok := awsEnv.SpotPlacementScoreProvider.Score("test-zone-1b")
+		Expect(ok).To(BeTrue())
+		Expect(score).To(BeNumerically("==", 5))
+		_, ok = awsEnv.SpotPlacementScoreProvider.Score("test-zone-1d")
+		Expect(ok).To(BeFalse())
+	})
+	It("should score the target capacity for the cheapest instance types a launch would request", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(awsEnv.EC2API.GetSpotPlacementScoresBehavior.CalledWithInput.Len()).To(Equal(1))
+		input := awsEnv.EC2API.GetSpotPlacementScoresBehavior.CalledWithInput.Pop()
+		Expect(aws.Int64Value(input.TargetCapacity)).To(BeNumerically("==", 50))
+		Expect(aws.BoolValue(input.SingleAvailabilityZone)).To(BeTrue())
+		Expect(input.InstanceTypes).ToNot(BeEmpty())
+		Expect(len(input.InstanceTypes)).To(BeNumerically("<=", instance.MaxInstanceTypes))
+	})
+	It("should share a single request between provisioners with the same requirements", func() {
+		other := coretest.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef:  &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+			Requirements: provisioner.Spec.Requirements,
+		})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner, other)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(awsEnv.EC2API.GetSpotPlacementScoresBehavior.CalledWithInput.Len()).To(Equal(1))
+		ExpectSpotPlacementScore(provisioner.Name, "test-zone-1a", 9)
+		ExpectSpotPlacementScore(other.Name, "test-zone-1a", 9)
+	})
+	It("should only request instance types that are compatible with the provisioner's requirements", func() {
+		provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+			Key:      v1.LabelInstanceTypeStable,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{"m5.large", "m5.xlarge"},
+		})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(awsEnv.EC2API.GetSpotPlacementScoresBehavior.CalledWithInput.Len()).To(Equal(1))
+		Expect(aws.StringValueSlice(awsEnv.EC2API.GetSpotPlacementScoresBehavior.CalledWithInput.Pop().InstanceTypes)).To(ConsistOf("m5.large", "m5.xlarge"))
+	})
+	It("should only report zones that the provisioner can launch into", func() {
+		provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+			Key:      v1.LabelTopologyZone,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{"test-zone-1a"},
+		})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		ExpectSpotPlacementScore(provisioner.Name, "test-zone-1a", 9)
+		_, found := FindMetricWithLabelValues("karpenter_cloudprovider_nodepool_spot_placement_score", map[string]string{
+			"nodepool": provisioner.Name,
+			"zone":     "test-zone-1b",
+		})
+		Expect(found).To(BeFalse())
+	})
+	It("should ignore scores of zones outside of the region", func() {
+		awsEnv.EC2API.GetSpotPlacementScoresBehavior.Output.Set(&ec2.GetSpotPlacementScoresOutput{SpotPlacementScores: []*ec2.SpotPlacementScore{
+			{AvailabilityZoneId: aws.String("otherzone1a"), Score: aws.Int64(10)},
+		}})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		_, found := FindMetricWithLabelValues("karpenter_cloudprovider_nodepool_spot_placement_score", map[string]string{
+			"nodepool": provisioner.Name,
+		})
+		Expect(found).To(BeFalse())
+	})
+	It("should publish an event when every zone has a low score", func() {
+		awsEnv.EC2API.GetSpotPlacementScoresBehavior.Output.Set(&ec2.GetSpotPlacementScoresOutput{SpotPlacementScores: []*ec2.SpotPlacementScore{
+			{AvailabilityZoneId: aws.String("testzone1a"), Score: aws.Int64(3)},
+			{AvailabilityZoneId: aws.String("testzone1b"), Score: aws.Int64(1)},
+			{AvailabilityZoneId: aws.String("testzone1c"), Score: aws.Int64(2)},
+		}})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(recorder.Calls("LowSpotPlacementScore")).To(Equal(1))
+	})
+	It("should not score provisioners that can't launch spot capacity", func() {
+		provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{{
+			Key:      v1alpha5.LabelCapacityType,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{v1alpha5.CapacityTypeOnDemand},
+		}}
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(awsEnv.EC2API.GetSpotPlacementScoresBehavior.CalledWithInput.Len()).To(Equal(0))
+	})
+	It("should not score provisioners whose node template doesn't exist", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(awsEnv.EC2API.GetSpotPlacementScoresBehavior.CalledWithInput.Len()).To(Equal(0))
+	})
+})
+
+func ExpectSpotPlacementScore(name, zone string, value float64) {
+	GinkgoHelper()
+	metric, found := FindMetricWithLabelValues("karpenter_cloudprovider_nodepool_spot_placement_score", map[string]string{
+		"nodepool": name,
+		"zone":     zone,
+	})
+	Expect(found).To(BeTrue())
+	Expect(metric.GetGauge().GetValue()).To(Equal(value))
+}
@@ -0,0 +1,152 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/metrics"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/instancetype"
+	"github.com/aws/karpenter/pkg/utils/attribution"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// Controller checks whether every NodePool could keep launching nodes after the cluster is upgraded to the next
+// Kubernetes minor version: the default AMIs of its NodeClass' AMI family must resolve from SSM for that version,
+// the images must be available, and at least one of the NodePool's instance types with an available offering in
+// the NodeClass' subnets must be compatible with one of the images
+type Controller struct {
+	kubeClient           client.Client
+	recorder             events.Recorder
+	amiProvider          *amifamily.Provider
+	instanceTypeProvider *instancetype.Provider
+}
+
+func NewController(kubeClient client.Client, recorder events.Recorder, amiProvider *amifamily.Provider, instanceTypeProvider *instancetype.Provider) *Controller {
+	return &Controller{
+		kubeClient:           kubeClient,
+		recorder:             recorder,
+		amiProvider:          amiProvider,
+		instanceTypeProvider: instanceTypeProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "upgrade"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	version, err := c.amiProvider.KubeServerVersion(ctx)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("getting kubernetes version, %w", err)
+	}
+	nextVersion, err := NextMinorVersion(version)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	nodePoolList, err := nodepoolutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodepools, %w", err)
+	}
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("kubernetes-version", nextVersion))
+	UpgradeReady.Reset()
+	for i := range nodePoolList.Items {
+		nodePool := &nodePoolList.Items[i]
+		if nodePool.Spec.Template.Spec.NodeClass == nil {
+			continue
+		}
+		log := logging.FromContext(ctx).With(lo.Ternary(nodePool.IsProvisioner, "provisioner", "nodepool"), nodePool.Name)
+		nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: nodePool.Spec.Template.Spec.NodeClass.Name, IsNodeTemplate: nodePool.IsProvisioner})
+		if err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				log.Errorf("resolving node class, %s", err)
+			}
+			continue
+		}
+		// AMIs that aren't the AMI family's defaults aren't tied to a Kubernetes version, so there's nothing to check
+		if nodeClass.Spec.LaunchTemplateName != nil || len(nodeClass.Spec.AMISelectorTerms) != 0 || lo.FromPtr(nodeClass.Spec.AMIFamily) == v1beta1.AMIFamilyCustom {
+			continue
+		}
+		ready := true
+		if err := c.check(attribution.WithNodeClass(attribution.WithNodePool(ctx, nodePool.Name), nodeClass.Name), nodePool, nodeClass, nextVersion); err != nil {
+			ready = false
+			log.Errorf("checking upgrade readiness, %s", err)
+			c.recorder.Publish(NodePoolNotReadyForUpgrade(nodePool, nextVersion, err))
+		}
+		UpgradeReady.With(prometheus.Labels{metrics.NodePoolLabel: nodePool.Name, kubernetesVersionLabel: nextVersion}).Set(lo.Ternary(ready, 1.0, 0.0))
+	}
+	return reconcile.Result{RequeueAfter: time.Hour}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// check returns an error describing why the NodePool wouldn't be able to launch nodes with the default AMIs of its
+// NodeClass' AMI family for the given Kubernetes version, or nil if it would
+func (c *Controller) check(ctx context.Context, nodePool *corev1beta1.NodePool, nodeClass *v1beta1.NodeClass, version string) error {
+	amis, amiErr := c.amiProvider.DefaultAMIsForVersion(ctx, nodeClass, version)
+	if len(amis) == 0 {
+		return fmt.Errorf("no default amis are available, %w", amiErr)
+	}
+	instanceTypes, err := c.instanceTypeProvider.List(ctx, nodePool.Spec.Template.Spec.KubeletConfiguration, nodeClass)
+	if err != nil {
+		return fmt.Errorf("listing instance types, %w", err)
+	}
+	requirements := scheduling.NewNodeSelectorRequirements(nodePool.Spec.Template.Spec.Requirements...)
+	instanceTypes = lo.Filter(instanceTypes, func(i *cloudprovider.InstanceType, _ int) bool {
+		return i.Requirements.Compatible(requirements) == nil && len(i.Offerings.Available().Requirements(requirements)) > 0
+	})
+	if len(amis.MapToInstanceTypes(instanceTypes)) == 0 {
+		return fmt.Errorf("no instance types with available offerings are compatible with amis %v", amis)
+	}
+	// Some of the default AMIs being unavailable only matters if the others don't cover the NodePool's instance types
+	if amiErr != nil {
+		logging.FromContext(ctx).With(lo.Ternary(nodePool.IsProvisioner, "provisioner", "nodepool"), nodePool.Name).Debugf("resolving default amis, %s", amiErr)
+	}
+	return nil
+}
+
+// NextMinorVersion returns the Kubernetes minor version that follows the given version, e.g. 1.28 for 1.27
+func NextMinorVersion(version string) (string, error) {
+	major, minor, found := strings.Cut(version, ".")
+	if !found {
+		return "", fmt.Errorf("parsing kubernetes version %q", version)
+	}
+	n, err := strconv.Atoi(minor)
+	if err != nil {
+		return "", fmt.Errorf("parsing kubernetes version %q, %w", version, err)
+	}
+	return fmt.Sprintf("%s.%d", major, n+1), nil
+}
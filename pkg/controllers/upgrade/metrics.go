@@ -0,0 +1,46 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	kubernetesVersionLabel = "kubernetes_version"
+)
+
+var (
+	UpgradeReady = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "nodepool_upgrade_ready",
+			Help:      "Whether a nodepool can launch nodes after the cluster is upgraded to the next Kubernetes minor version, 1 if it can and 0 if it can't. Only reported for nodepools that use the default AMIs of their AMI family. Labeled by nodepool and Kubernetes version.",
+		},
+		[]string{
+			metrics.NodePoolLabel,
+			kubernetesVersionLabel,
+		},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(UpgradeReady)
+}
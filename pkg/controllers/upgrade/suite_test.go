@@ -0,0 +1,180 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/upgrade"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var controller *upgrade.Controller
+var nodeTemplate *v1alpha1.AWSNodeTemplate
+var provisioner *v1alpha5.Provisioner
+var version string
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Upgrade")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv = test.NewEnvironment(ctx, env)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	controller = upgrade.NewController(env.Client, events.NewRecorder(&record.FakeRecorder{}), awsEnv.AMIProvider, awsEnv.InstanceTypesProvider)
+	nodeTemplate = &v1alpha1.AWSNodeTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: coretest.RandomName(),
+		},
+		Spec: v1alpha1.AWSNodeTemplateSpec{
+			AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+			},
+		},
+	}
+	provisioner = coretest.Provisioner(coretest.ProvisionerOptions{
+		ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+	})
+	version = lo.Must(upgrade.NextMinorVersion(lo.Must(awsEnv.AMIProvider.KubeServerVersion(ctx))))
+	awsEnv.SSMAPI.Parameters = map[string]string{
+		fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version):       "ami-amd64",
+		fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-gpu/recommended/image_id", version):   "ami-gpu",
+		fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-arm64/recommended/image_id", version): "ami-arm64",
+	}
+	awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+		{Name: aws.String("amd64"), ImageId: aws.String("ami-amd64"), Architecture: aws.String("x86_64"), State: aws.String(ec2.ImageStateAvailable), CreationDate: aws.String("2023-01-01T00:00:00.000Z")},
+		{Name: aws.String("gpu"), ImageId: aws.String("ami-gpu"), Architecture: aws.String("x86_64"), State: aws.String(ec2.ImageStateAvailable), CreationDate: aws.String("2023-01-01T00:00:00.000Z")},
+		{Name: aws.String("arm64"), ImageId: aws.String("ami-arm64"), Architecture: aws.String("arm64"), State: aws.String(ec2.ImageStateAvailable), CreationDate: aws.String("2023-01-01T00:00:00.000Z")},
+	}})
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("Upgrade", func() {
+	It("should report a provisioner as ready when the default amis for the next version are available", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		ExpectUpgradeReady(provisioner.Name, 1)
+	})
+	It("should report a provisioner as ready when only some of the default amis are available", func() {
+		delete(awsEnv.SSMAPI.Parameters, fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-gpu/recommended/image_id", version))
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		ExpectUpgradeReady(provisioner.Name, 1)
+	})
+	It("should report a provisioner as not ready when the default amis for the next version aren't published", func() {
+		awsEnv.SSMAPI.Parameters = map[string]string{"/aws/service/eks/optimized-ami/1.0/amazon-linux-2/recommended/image_id": "ami-amd64"}
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		ExpectUpgradeReady(provisioner.Name, 0)
+	})
+	It("should report a provisioner as not ready when the default amis for the next version aren't available", func() {
+		awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+			{Name: aws.String("amd64"), ImageId: aws.String("ami-amd64"), Architecture: aws.String("x86_64"), State: aws.String(ec2.ImageStatePending), CreationDate: aws.String("2023-01-01T00:00:00.000Z")},
+			{Name: aws.String("gpu"), ImageId: aws.String("ami-gpu"), Architecture: aws.String("x86_64"), State: aws.String(ec2.ImageStatePending), CreationDate: aws.String("2023-01-01T00:00:00.000Z")},
+			{Name: aws.String("arm64"), ImageId: aws.String("ami-arm64"), Architecture: aws.String("arm64"), State: aws.String(ec2.ImageStateDeregistered), CreationDate: aws.String("2023-01-01T00:00:00.000Z")},
+		}})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		ExpectUpgradeReady(provisioner.Name, 0)
+	})
+	It("should report a provisioner as not ready when none of its instance types are compatible with the available amis", func() {
+		delete(awsEnv.SSMAPI.Parameters, fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-arm64/recommended/image_id", version))
+		provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+			Key:      v1.LabelArchStable,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{v1alpha5.ArchitectureArm64},
+		})
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		ExpectUpgradeReady(provisioner.Name, 0)
+	})
+	It("should not report provisioners whose node template selects its own amis", func() {
+		nodeTemplate.Spec.AMISelector = map[string]string{"aws-ids": "ami-amd64"}
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		_, found := FindMetricWithLabelValues("karpenter_cloudprovider_nodepool_upgrade_ready", map[string]string{
+			"nodepool":           provisioner.Name,
+			"kubernetes_version": version,
+		})
+		Expect(found).To(BeFalse())
+	})
+	It("should not report provisioners whose node template doesn't exist", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		_, found := FindMetricWithLabelValues("karpenter_cloudprovider_nodepool_upgrade_ready", map[string]string{
+			"nodepool":           provisioner.Name,
+			"kubernetes_version": version,
+		})
+		Expect(found).To(BeFalse())
+	})
+	It("should compute the next minor version", func() {
+		Expect(upgrade.NextMinorVersion("1.27")).To(Equal("1.28"))
+		Expect(upgrade.NextMinorVersion("1.9")).To(Equal("1.10"))
+		_, err := upgrade.NextMinorVersion("1")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+func ExpectUpgradeReady(name string, value float64) {
+	GinkgoHelper()
+	metric, found := FindMetricWithLabelValues("karpenter_cloudprovider_nodepool_upgrade_ready", map[string]string{
+		"nodepool":           name,
+		"kubernetes_version": version,
+	})
+	Expect(found).To(BeTrue())
+	Expect(metric.GetGauge().GetValue()).To(Equal(value))
+}
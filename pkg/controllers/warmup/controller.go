@@ -0,0 +1,170 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package warmup
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/samber/lo"
+	lop "github.com/samber/lo/parallel"
+	"golang.org/x/time/rate"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/instancetype"
+	"github.com/aws/karpenter/pkg/providers/pricing"
+	"github.com/aws/karpenter/pkg/providers/securitygroup"
+	"github.com/aws/karpenter/pkg/providers/subnet"
+	"github.com/aws/karpenter/pkg/utils/attribution"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+var (
+	// MaxJitter spreads out when each NodePool is warmed up so that they don't all hit the EC2 APIs at once
+	MaxJitter = 5 * time.Second
+	// QPS and Burst rate limit the calls that the warm-up makes across all NodePools
+	QPS   = 5
+	Burst = 5
+	// PricingWaitTimeout bounds how long the warm-up waits for the pricing controller's first refresh before
+	// warming the instance types, since their offerings are priced from it
+	PricingWaitTimeout = 10 * time.Second
+)
+
+// Controller warms the subnet, security group, AMI, and instance type caches for every NodePool when Karpenter starts
+// or becomes the leader, before provisioning begins. Provisioning waits on the warm-up for at most cache.WarmUpTimeout.
+type Controller struct {
+	kubeClient            client.Client
+	subnetProvider        *subnet.Provider
+	securityGroupProvider *securitygroup.Provider
+	amiProvider           *amifamily.Provider
+	instanceTypeProvider  *instancetype.Provider
+	pricingProvider       *pricing.Provider
+	warmUp                *cache.WarmUp
+	start                 time.Time
+}
+
+func NewController(kubeClient client.Client, subnetProvider *subnet.Provider, securityGroupProvider *securitygroup.Provider,
+	amiProvider *amifamily.Provider, instanceTypeProvider *instancetype.Provider, pricingProvider *pricing.Provider, warmUp *cache.WarmUp) *Controller {
+	return &Controller{
+		kubeClient:            kubeClient,
+		subnetProvider:        subnetProvider,
+		securityGroupProvider: securityGroupProvider,
+		amiProvider:           amiProvider,
+		instanceTypeProvider:  instanceTypeProvider,
+		pricingProvider:       pricingProvider,
+		warmUp:                warmUp,
+		start:                 time.Now(),
+	}
+}
+
+func (c *Controller) Name() string {
+	return "warmup"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	// The warm-up only runs once per leader, after which the provider caches are kept warm by provisioning
+	if c.warmUp.IsDone() {
+		return reconcile.Result{RequeueAfter: time.Hour}, nil
+	}
+	// Always release provisioning, even if some of the NodePools couldn't be warmed up
+	defer c.warmUp.MarkDone()
+	ctx, cancel := context.WithTimeout(ctx, cache.WarmUpTimeout)
+	defer cancel()
+
+	nodePoolList, err := nodepoolutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodepools, %w", err)
+	}
+	nodePools := lo.Filter(nodePoolList.Items, func(n corev1beta1.NodePool, _ int) bool { return n.Spec.Template.Spec.NodeClass != nil })
+	limiter := rate.NewLimiter(rate.Limit(QPS), Burst)
+	c.waitForPricing(ctx)
+	lop.ForEach(nodePools, func(nodePool corev1beta1.NodePool, _ int) {
+		if err := c.warm(ctx, limiter, &nodePool); err != nil {
+			logging.FromContext(ctx).With(lo.Ternary(nodePool.IsProvisioner, "provisioner", "nodepool"), nodePool.Name).Errorf("warming up caches, %s", err)
+		}
+	})
+	logging.FromContext(ctx).With("count", len(nodePools), "duration", time.Since(c.start)).Infof("warmed up caches")
+	return reconcile.Result{RequeueAfter: time.Hour}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// warm resolves the NodePool's NodeClass through each provider after a random delay, waiting on the rate limiter
+// before each call so that many NodePools sharing the same NodeClass don't stack up calls at the same moment
+func (c *Controller) warm(ctx context.Context, limiter *rate.Limiter, nodePool *corev1beta1.NodePool) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(rand.Int63n(int64(MaxJitter) + 1))): //nolint:gosec
+	}
+	nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: nodePool.Spec.Template.Spec.NodeClass.Name, IsNodeTemplate: nodePool.IsProvisioner})
+	if err != nil {
+		return fmt.Errorf("resolving node class, %w", err)
+	}
+	ctx = attribution.WithNodeClass(attribution.WithNodePool(ctx, nodePool.Name), nodeClass.Name)
+	for _, f := range []func() error{
+		func() error { _, err := c.subnetProvider.List(ctx, nodeClass); return err },
+		func() error { _, err := c.securityGroupProvider.List(ctx, nodeClass); return err },
+		func() error {
+			if nodeClass.Spec.LaunchTemplateName != nil {
+				return nil
+			}
+			_, err := c.amiProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			return err
+		},
+		func() error {
+			_, err := c.instanceTypeProvider.List(ctx, nodePool.Spec.Template.Spec.KubeletConfiguration, nodeClass)
+			return err
+		},
+	} {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForPricing waits a short time for the pricing controller's first refresh so that the warmed instance types
+// are priced from live pricing instead of the static fallback
+func (c *Controller) waitForPricing(ctx context.Context) {
+	if settings.FromContext(ctx).IsolatedVPC || settings.FromContext(ctx).Airgapped {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, PricingWaitTimeout)
+	defer cancel()
+	for !c.pricingProvider.SpotLastUpdated().After(c.start) || !c.pricingProvider.OnDemandLastUpdated().After(c.start) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
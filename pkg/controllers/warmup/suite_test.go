@@ -0,0 +1,124 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package warmup_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/controllers/warmup"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var warmUp *awscache.WarmUp
+var controller *warmup.Controller
+var nodeTemplate *v1alpha1.AWSNodeTemplate
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "WarmUp")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{IsolatedVPC: lo.ToPtr(true)}))
+	awsEnv = test.NewEnvironment(ctx, env)
+	warmup.MaxJitter = 0
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	warmUp = awscache.NewWarmUp()
+	controller = warmup.NewController(env.Client, awsEnv.SubnetProvider, awsEnv.SecurityGroupProvider, awsEnv.AMIProvider,
+		awsEnv.InstanceTypesProvider, awsEnv.PricingProvider, warmUp)
+	nodeTemplate = &v1alpha1.AWSNodeTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: coretest.RandomName(),
+		},
+		Spec: v1alpha1.AWSNodeTemplateSpec{
+			AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+			},
+		},
+	}
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("WarmUp", func() {
+	It("should warm the caches for each provisioner's node template", func() {
+		provisioner := coretest.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		Expect(warmUp.IsDone()).To(BeTrue())
+		Expect(awsEnv.SubnetCache.ItemCount()).To(BeNumerically(">", 0))
+		Expect(awsEnv.SecurityGroupCache.ItemCount()).To(BeNumerically(">", 0))
+		Expect(awsEnv.InstanceTypeCache.ItemCount()).To(BeNumerically(">", 0))
+		Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(BeNumerically(">", 0))
+	})
+	It("should finish the warm-up when a provisioner's node template doesn't exist", func() {
+		provisioner := coretest.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{Name: "missing"},
+		})
+		ExpectApplied(ctx, env.Client, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		Expect(warmUp.IsDone()).To(BeTrue())
+		Expect(awsEnv.SubnetCache.ItemCount()).To(BeZero())
+	})
+	It("should not warm the caches again once the warm-up is done", func() {
+		provisioner := coretest.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		warmUp.MarkDone()
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		Expect(awsEnv.SubnetCache.ItemCount()).To(BeZero())
+		Expect(awsEnv.InstanceTypeCache.ItemCount()).To(BeZero())
+	})
+})
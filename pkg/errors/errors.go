@@ -16,7 +16,9 @@ package errors
 
 import (
 	"errors"
+	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -25,12 +27,15 @@ import (
 
 const (
 	launchTemplateNotFoundCode = "InvalidLaunchTemplateName.NotFoundException"
+	amiNotFoundCode            = "InvalidAMIID.NotFound"
 )
 
 var (
 	// This is not an exhaustive list, add to it as needed
 	notFoundErrorCodes = sets.NewString(
 		"InvalidInstanceID.NotFound",
+		"InvalidCapacityReservationId.NotFound",
+		"InvalidSpotInstanceRequestID.NotFound",
 		launchTemplateNotFoundCode,
 		sqs.ErrCodeQueueDoesNotExist,
 	)
@@ -42,6 +47,29 @@ var (
 		"UnfulfillableCapacity",
 		"Unsupported",
 	)
+	// incompatibleInstanceTypeErrorCodes signify that an instance type can't be launched with the rest of its launch
+	// template, such as an AMI that requires the Nitro System on an instance type that runs on Xen
+	incompatibleInstanceTypeErrorCodes = sets.NewString(
+		"InvalidParameterCombination",
+		"UnsupportedOperation",
+	)
+	// incompatibleInstanceTypeErrorMessages are the lowercased parts of the messages of incompatibleInstanceTypeErrorCodes
+	// that blame the instance type, since the same codes are returned for requests that are invalid for their zone or
+	// subnet. This is not an exhaustive list, add to it as needed
+	incompatibleInstanceTypeErrorMessages = []string{
+		"enhanced networking",
+		"virtualization type",
+		"boot mode",
+		"architecture",
+		"not supported for this instance type",
+		"instance type does not support",
+	}
+	// accessDeniedErrorCodes signify that the caller is missing the IAM permissions needed to perform the operation
+	accessDeniedErrorCodes = sets.NewString(
+		"AccessDenied",
+		"AccessDeniedException",
+		"UnauthorizedOperation",
+	)
 )
 
 // IsNotFound returns true if the err is an AWS error (even if it's
@@ -65,6 +93,54 @@ func IsUnfulfillableCapacity(err *ec2.CreateFleetError) bool {
 	return unfulfillableCapacityErrorCodes.Has(*err.ErrorCode)
 }
 
+// IsUnfulfillableCapacityErr returns true if the err is an AWS error (even if
+// it's wrapped) that means capacity is temporarily unavailable for launching
+func IsUnfulfillableCapacityErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		return unfulfillableCapacityErrorCodes.Has(awsError.Code())
+	}
+	return false
+}
+
+// IsIncompatibleInstanceTypeFleetErr returns true if the Fleet err means
+// the instance type of the override can't be launched with its launch
+// template, which won't change by retrying it
+func IsIncompatibleInstanceTypeFleetErr(err *ec2.CreateFleetError) bool {
+	if !incompatibleInstanceTypeErrorCodes.Has(*err.ErrorCode) {
+		return false
+	}
+	message := strings.ToLower(aws.StringValue(err.ErrorMessage))
+	for _, m := range incompatibleInstanceTypeErrorMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAccessDenied returns true if the err is an AWS error (even if it's
+// wrapped) that signals the caller isn't authorized to perform the operation
+func IsAccessDenied(err error) bool {
+	if err == nil {
+		return false
+	}
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		return accessDeniedErrorCodes.Has(awsError.Code())
+	}
+	return false
+}
+
+// IsAccessDeniedFleetErr returns true if the Fleet err means the caller
+// isn't authorized to launch one of the requested overrides
+func IsAccessDeniedFleetErr(err *ec2.CreateFleetError) bool {
+	return accessDeniedErrorCodes.Has(*err.ErrorCode)
+}
+
 func IsLaunchTemplateNotFound(err error) bool {
 	if err == nil {
 		return false
@@ -75,3 +151,51 @@ func IsLaunchTemplateNotFound(err error) bool {
 	}
 	return false
 }
+
+// IsAMINotFound returns true if the err is an AWS error (even if it's
+// wrapped) that signals an AMI no longer exists
+func IsAMINotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		return awsError.Code() == amiNotFoundCode
+	}
+	return false
+}
+
+// IsAMINotFoundFleetErr returns true if the Fleet err means the AMI of
+// one of the requested overrides no longer exists
+func IsAMINotFoundFleetErr(err *ec2.CreateFleetError) bool {
+	return *err.ErrorCode == amiNotFoundCode
+}
+
+// ReadOnlyError is returned when a mutating call isn't made, or was denied, since Karpenter is missing the IAM
+// permissions for it and is in read-only mode
+type ReadOnlyError struct {
+	// Operation is the AWS API that access was denied to
+	Operation string
+	error
+}
+
+func NewReadOnlyError(operation string, err error) *ReadOnlyError {
+	return &ReadOnlyError{
+		Operation: operation,
+		error:     err,
+	}
+}
+
+func (e *ReadOnlyError) Unwrap() error {
+	return e.error
+}
+
+// ReadOnlyOperation returns the AWS API that access was denied to and true if the err (even if it's wrapped) was
+// returned since Karpenter is in read-only mode
+func ReadOnlyOperation(err error) (string, bool) {
+	var readOnlyError *ReadOnlyError
+	if errors.As(err, &readOnlyError) {
+		return readOnlyError.Operation, true
+	}
+	return "", false
+}
@@ -20,6 +20,8 @@ import (
 	"log"
 	"math"
 	"sync"
+
+	"github.com/samber/lo"
 )
 
 // AtomicPtr is intended for use in mocks to easily expose variables for use in testing.  It makes setting and retrieving
@@ -168,3 +170,37 @@ func (a *AtomicPtrSlice[T]) ForEach(fn func(*T)) {
 		fn(clone(t))
 	}
 }
+
+// CallCounter counts the calls made to each operation of a fake API
+type CallCounter struct {
+	mu     sync.RWMutex
+	counts map[string]int
+}
+
+func (c *CallCounter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = nil
+}
+
+func (c *CallCounter) Inc(operation string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = map[string]int{}
+	}
+	c.counts[operation]++
+}
+
+func (c *CallCounter) Get(operation string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counts[operation]
+}
+
+// All returns a copy of the call count of each operation that has been called
+func (c *CallCounter) All() map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return lo.Assign(c.counts)
+}
@@ -0,0 +1,49 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/computeoptimizer"
+	"github.com/aws/aws-sdk-go/service/computeoptimizer/computeoptimizeriface"
+)
+
+type ComputeOptimizerAPI struct {
+	computeoptimizeriface.ComputeOptimizerAPI
+	ComputeOptimizerBehavior
+}
+
+type ComputeOptimizerBehavior struct {
+	GetEC2InstanceRecommendationsOutput AtomicPtr[computeoptimizer.GetEC2InstanceRecommendationsOutput]
+	NextError                           AtomicError
+}
+
+// Reset must be called between tests otherwise tests will pollute
+// each other.
+func (c *ComputeOptimizerAPI) Reset() {
+	c.GetEC2InstanceRecommendationsOutput.Reset()
+	c.NextError.Reset()
+}
+
+func (c *ComputeOptimizerAPI) GetEC2InstanceRecommendationsWithContext(_ aws.Context, _ *computeoptimizer.GetEC2InstanceRecommendationsInput, _ ...request.Option) (*computeoptimizer.GetEC2InstanceRecommendationsOutput, error) {
+	if !c.NextError.IsNil() {
+		return nil, c.NextError.Get()
+	}
+	if !c.GetEC2InstanceRecommendationsOutput.IsNil() {
+		return c.GetEC2InstanceRecommendationsOutput.Clone(), nil
+	}
+	return &computeoptimizer.GetEC2InstanceRecommendationsOutput{}, nil
+}
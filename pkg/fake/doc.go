@@ -0,0 +1,33 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake provides in-memory implementations of the EC2, SSM, SQS, EKS, and Pricing APIs that Karpenter
+// uses, so that NodePool and NodeClass configurations can be integration tested without an AWS account.
+//
+// The fakes are wired into the providers by test.NewEnvironment. Beyond stubbing outputs and errors for individual
+// calls, the EC2 fake can model the real-world behavior that most affects scheduling decisions:
+//
+//   - EC2API.AvailableCapacity limits how many instances can be launched into each instance type, zone, and
+//     capacity type. Launches into an exhausted pool fail with InsufficientInstanceCapacity, and terminating an
+//     instance returns its capacity to the pool.
+//   - EC2API.InsufficientCapacityPools injects InsufficientInstanceCapacity errors for pools regardless of capacity.
+//   - CreateFleet launches the first override with capacity, trying overrides by priority when the fleet's allocation
+//     strategy is prioritized and in the requested order otherwise.
+//   - EC2API.InterruptSpotInstance sends a spot interruption warning for a spot instance to an SQSAPI, which is
+//     returned to the interruption controller until it is deleted.
+//   - EC2API.Latency delays calls that launch, describe, tag, and terminate instances, so that timeouts and
+//     batching can be exercised.
+//
+// Reset must be called on each fake between tests, otherwise tests will pollute each other.
+package fake
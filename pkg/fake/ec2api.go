@@ -18,6 +18,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -43,28 +45,110 @@ type CapacityPool struct {
 	Zone         string
 }
 
+// CapacityPools models how many instances can be launched into each capacity pool. Pools without a limit have
+// unlimited capacity. Launching an instance consumes capacity from its pool and terminating it returns the capacity.
+type CapacityPools struct {
+	mu       sync.Mutex
+	capacity map[CapacityPool]int
+	launched map[string]CapacityPool // key: instance ID, value: the pool that the instance consumed capacity from
+}
+
+// Set limits the number of instances that can be launched into the pool
+func (c *CapacityPools) Set(pool CapacityPool, count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity == nil {
+		c.capacity = map[CapacityPool]int{}
+	}
+	c.capacity[pool] = count
+}
+
+// Get returns the remaining capacity of the pool, and whether the pool's capacity is limited
+func (c *CapacityPools) Get(pool CapacityPool) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count, ok := c.capacity[pool]
+	return count, ok
+}
+
+// take consumes capacity for the instance from the pool, returning false if the pool is out of capacity
+func (c *CapacityPools) take(pool CapacityPool, instanceID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count, ok := c.capacity[pool]
+	if !ok {
+		return true
+	}
+	if count <= 0 {
+		return false
+	}
+	c.capacity[pool] = count - 1
+	if c.launched == nil {
+		c.launched = map[string]CapacityPool{}
+	}
+	c.launched[instanceID] = pool
+	return true
+}
+
+// release returns the capacity consumed by the instance to its pool
+func (c *CapacityPools) release(instanceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pool, ok := c.launched[instanceID]
+	if !ok {
+		return
+	}
+	delete(c.launched, instanceID)
+	if count, ok := c.capacity[pool]; ok {
+		c.capacity[pool] = count + 1
+	}
+}
+
+func (c *CapacityPools) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capacity = nil
+	c.launched = nil
+}
+
 // EC2Behavior must be reset between tests otherwise tests will
 // pollute each other.
 type EC2Behavior struct {
-	DescribeImagesOutput                AtomicPtr[ec2.DescribeImagesOutput]
-	DescribeLaunchTemplatesOutput       AtomicPtr[ec2.DescribeLaunchTemplatesOutput]
-	DescribeSubnetsOutput               AtomicPtr[ec2.DescribeSubnetsOutput]
-	DescribeSecurityGroupsOutput        AtomicPtr[ec2.DescribeSecurityGroupsOutput]
-	DescribeInstanceTypesOutput         AtomicPtr[ec2.DescribeInstanceTypesOutput]
-	DescribeInstanceTypeOfferingsOutput AtomicPtr[ec2.DescribeInstanceTypeOfferingsOutput]
-	DescribeAvailabilityZonesOutput     AtomicPtr[ec2.DescribeAvailabilityZonesOutput]
-	DescribeSpotPriceHistoryInput       AtomicPtr[ec2.DescribeSpotPriceHistoryInput]
-	DescribeSpotPriceHistoryOutput      AtomicPtr[ec2.DescribeSpotPriceHistoryOutput]
-	CreateFleetBehavior                 MockedFunction[ec2.CreateFleetInput, ec2.CreateFleetOutput]
-	TerminateInstancesBehavior          MockedFunction[ec2.TerminateInstancesInput, ec2.TerminateInstancesOutput]
-	DescribeInstancesBehavior           MockedFunction[ec2.DescribeInstancesInput, ec2.DescribeInstancesOutput]
-	CreateTagsBehavior                  MockedFunction[ec2.CreateTagsInput, ec2.CreateTagsOutput]
-	CalledWithCreateLaunchTemplateInput AtomicPtrSlice[ec2.CreateLaunchTemplateInput]
-	CalledWithDescribeImagesInput       AtomicPtrSlice[ec2.DescribeImagesInput]
-	Instances                           sync.Map
-	LaunchTemplates                     sync.Map
-	InsufficientCapacityPools           atomic.Slice[CapacityPool]
-	NextError                           AtomicError
+	DescribeImagesOutput                    AtomicPtr[ec2.DescribeImagesOutput]
+	DescribeLaunchTemplatesOutput           AtomicPtr[ec2.DescribeLaunchTemplatesOutput]
+	DescribeSubnetsOutput                   AtomicPtr[ec2.DescribeSubnetsOutput]
+	DescribeSecurityGroupsOutput            AtomicPtr[ec2.DescribeSecurityGroupsOutput]
+	DescribeInstanceTypesOutput             AtomicPtr[ec2.DescribeInstanceTypesOutput]
+	DescribeInstanceTypeOfferingsOutput     AtomicPtr[ec2.DescribeInstanceTypeOfferingsOutput]
+	DescribeAvailabilityZonesOutput         AtomicPtr[ec2.DescribeAvailabilityZonesOutput]
+	DescribeSpotPriceHistoryInput           AtomicPtr[ec2.DescribeSpotPriceHistoryInput]
+	DescribeSpotPriceHistoryOutput          AtomicPtr[ec2.DescribeSpotPriceHistoryOutput]
+	DescribeCapacityReservationsOutput      AtomicPtr[ec2.DescribeCapacityReservationsOutput]
+	CreateFleetBehavior                     MockedFunction[ec2.CreateFleetInput, ec2.CreateFleetOutput]
+	RunInstancesBehavior                    MockedFunction[ec2.RunInstancesInput, ec2.Reservation]
+	CancelSpotInstanceRequestsBehavior      MockedFunction[ec2.CancelSpotInstanceRequestsInput, ec2.CancelSpotInstanceRequestsOutput]
+	TerminateInstancesBehavior              MockedFunction[ec2.TerminateInstancesInput, ec2.TerminateInstancesOutput]
+	DescribeInstancesBehavior               MockedFunction[ec2.DescribeInstancesInput, ec2.DescribeInstancesOutput]
+	CreateTagsBehavior                      MockedFunction[ec2.CreateTagsInput, ec2.CreateTagsOutput]
+	ModifyInstanceMetadataOptionsBehavior   MockedFunction[ec2.ModifyInstanceMetadataOptionsInput, ec2.ModifyInstanceMetadataOptionsOutput]
+	ModifyNetworkInterfaceAttributeBehavior MockedFunction[ec2.ModifyNetworkInterfaceAttributeInput, ec2.ModifyNetworkInterfaceAttributeOutput]
+	GetConsoleOutputBehavior                MockedFunction[ec2.GetConsoleOutputInput, ec2.GetConsoleOutputOutput]
+	CreateCapacityReservationBehavior       MockedFunction[ec2.CreateCapacityReservationInput, ec2.CreateCapacityReservationOutput]
+	CancelCapacityReservationBehavior       MockedFunction[ec2.CancelCapacityReservationInput, ec2.CancelCapacityReservationOutput]
+	GetSpotPlacementScoresBehavior          MockedFunction[ec2.GetSpotPlacementScoresInput, ec2.GetSpotPlacementScoresOutput]
+	DescribeImageAttributeBehavior          MockedFunction[ec2.DescribeImageAttributeInput, ec2.DescribeImageAttributeOutput]
+	CalledWithCreateLaunchTemplateInput     AtomicPtrSlice[ec2.CreateLaunchTemplateInput]
+	CreateLaunchTemplateError               AtomicError
+	CalledWithDescribeImagesInput           AtomicPtrSlice[ec2.DescribeImagesInput]
+	Instances                               sync.Map
+	// UEFIData is the UEFI data that images were registered with, keyed by their id
+	UEFIData                  sync.Map
+	LaunchTemplates           sync.Map
+	InsufficientCapacityPools atomic.Slice[CapacityPool]
+	AvailableCapacity         CapacityPools
+	Latency                   AtomicPtr[time.Duration]
+	Calls                     CallCounter
+	NextError                 AtomicError
 }
 
 type EC2API struct {
@@ -86,12 +170,24 @@ func (e *EC2API) Reset() {
 	e.DescribeInstanceTypeOfferingsOutput.Reset()
 	e.DescribeAvailabilityZonesOutput.Reset()
 	e.CreateFleetBehavior.Reset()
+	e.RunInstancesBehavior.Reset()
+	e.CancelSpotInstanceRequestsBehavior.Reset()
+	e.CreateTagsBehavior.Reset()
 	e.TerminateInstancesBehavior.Reset()
 	e.DescribeInstancesBehavior.Reset()
+	e.ModifyInstanceMetadataOptionsBehavior.Reset()
+	e.ModifyNetworkInterfaceAttributeBehavior.Reset()
+	e.GetConsoleOutputBehavior.Reset()
+	e.CreateCapacityReservationBehavior.Reset()
+	e.CancelCapacityReservationBehavior.Reset()
+	e.GetSpotPlacementScoresBehavior.Reset()
+	e.DescribeImageAttributeBehavior.Reset()
 	e.CalledWithCreateLaunchTemplateInput.Reset()
+	e.CreateLaunchTemplateError.Reset()
 	e.CalledWithDescribeImagesInput.Reset()
 	e.DescribeSpotPriceHistoryInput.Reset()
 	e.DescribeSpotPriceHistoryOutput.Reset()
+	e.DescribeCapacityReservationsOutput.Reset()
 	e.Instances.Range(func(k, v any) bool {
 		e.Instances.Delete(k)
 		return true
@@ -100,12 +196,102 @@ func (e *EC2API) Reset() {
 		e.LaunchTemplates.Delete(k)
 		return true
 	})
+	e.UEFIData.Range(func(k, v any) bool {
+		e.UEFIData.Delete(k)
+		return true
+	})
 	e.InsufficientCapacityPools.Reset()
+	e.AvailableCapacity.Reset()
+	e.Latency.Reset()
+	e.Calls.Reset()
 	e.NextError.Reset()
 }
 
+// simulateLatency blocks for the configured latency, returning early if the context is cancelled
+func (e *EC2API) simulateLatency(ctx context.Context) error {
+	if e.Latency.IsNil() {
+		return nil
+	}
+	select {
+	case <-time.After(*e.Latency.Clone()):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InterruptSpotInstance simulates EC2 reclaiming a spot instance by sending the spot interruption warning for the
+// instance to the interruption queue. The instance keeps running, as it would until the end of the warning period.
+func (e *EC2API) InterruptSpotInstance(instanceID string, queue *SQSAPI) error {
+	raw, ok := e.Instances.Load(instanceID)
+	if !ok {
+		return fmt.Errorf("instance with id '%s' does not exist", instanceID)
+	}
+	if raw.(*ec2.Instance).SpotInstanceRequestId == nil {
+		return fmt.Errorf("instance with id '%s' is not a spot instance", instanceID)
+	}
+	queue.Enqueue(SpotInterruptionMessage(instanceID))
+	return nil
+}
+
+// overrideInstanceType returns the instance type that the override launches, which is the first of the allowed instance
+// types when the override selects instance types by their instance requirements
+func overrideInstanceType(override *ec2.FleetLaunchTemplateOverridesRequest) string {
+	if override.InstanceRequirements != nil && len(override.InstanceRequirements.AllowedInstanceTypes) > 0 {
+		allowed := aws.StringValue(override.InstanceRequirements.AllowedInstanceTypes[0])
+		family, ok := strings.CutSuffix(allowed, ".*")
+		if !ok {
+			return allowed
+		}
+		// A family is resolved to its smallest instance type within the instance requirements
+		requirements := override.InstanceRequirements
+		matching := lo.Filter(defaultDescribeInstanceTypesOutput.InstanceTypes, func(info *ec2.InstanceTypeInfo, _ int) bool {
+			vcpus, memory := aws.Int64Value(info.VCpuInfo.DefaultVCpus), aws.Int64Value(info.MemoryInfo.SizeInMiB)
+			return strings.HasPrefix(aws.StringValue(info.InstanceType), family+".") &&
+				vcpus >= aws.Int64Value(requirements.VCpuCount.Min) && vcpus <= aws.Int64Value(requirements.VCpuCount.Max) &&
+				memory >= aws.Int64Value(requirements.MemoryMiB.Min) && memory <= aws.Int64Value(requirements.MemoryMiB.Max)
+		})
+		if len(matching) == 0 {
+			return allowed
+		}
+		return aws.StringValue(lo.MinBy(matching, func(a, b *ec2.InstanceTypeInfo) bool {
+			return aws.Int64Value(a.VCpuInfo.DefaultVCpus) < aws.Int64Value(b.VCpuInfo.DefaultVCpus)
+		}).InstanceType)
+	}
+	return aws.StringValue(override.InstanceType)
+}
+
+// orderOverrides returns the overrides of every launch template config in the order that the fleet's allocation
+// strategy tries them. Overrides are tried by priority, lowest first, when the strategy is prioritized. The fake
+// doesn't know the prices or capacity of pools, so the price and capacity based strategies try them in the order
+// that they were requested, which callers are expected to order by price.
+func orderOverrides(input *ec2.CreateFleetInput) []*ec2.FleetLaunchTemplateOverridesRequest {
+	overrides := lo.FlatMap(input.LaunchTemplateConfigs, func(ltc *ec2.FleetLaunchTemplateConfigRequest, _ int) []*ec2.FleetLaunchTemplateOverridesRequest {
+		return ltc.Overrides
+	})
+	prioritized := false
+	if input.OnDemandOptions != nil && aws.StringValue(input.OnDemandOptions.AllocationStrategy) == ec2.FleetOnDemandAllocationStrategyPrioritized {
+		prioritized = aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType) == v1alpha5.CapacityTypeOnDemand
+	}
+	if input.SpotOptions != nil && aws.StringValue(input.SpotOptions.AllocationStrategy) == ec2.SpotAllocationStrategyCapacityOptimizedPrioritized {
+		prioritized = aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType) == v1alpha5.CapacityTypeSpot
+	}
+	if prioritized {
+		// overrides without a priority are tried last
+		priority := func(o *ec2.FleetLaunchTemplateOverridesRequest) float64 {
+			return lo.Ternary(o.Priority != nil, aws.Float64Value(o.Priority), math.MaxFloat64)
+		}
+		sort.SliceStable(overrides, func(i, j int) bool { return priority(overrides[i]) < priority(overrides[j]) })
+	}
+	return overrides
+}
+
 // nolint: gocyclo
-func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFleetInput, _ ...request.Option) (*ec2.CreateFleetOutput, error) {
+func (e *EC2API) CreateFleetWithContext(ctx context.Context, input *ec2.CreateFleetInput, _ ...request.Option) (*ec2.CreateFleetOutput, error) {
+	e.Calls.Inc("CreateFleet")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
 	return e.CreateFleetBehavior.Invoke(input, func(input *ec2.CreateFleetInput) (*ec2.CreateFleetOutput, error) {
 		if input.LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName == nil {
 			return nil, fmt.Errorf("missing launch template name")
@@ -119,59 +305,70 @@ func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFlee
 		}
 
 		fulfilled := 0
-		for _, ltc := range input.LaunchTemplateConfigs {
-			for _, override := range ltc.Overrides {
-				skipInstance := false
-				e.InsufficientCapacityPools.Range(func(pool CapacityPool) bool {
-					if pool.InstanceType == aws.StringValue(override.InstanceType) &&
-						pool.Zone == aws.StringValue(override.AvailabilityZone) &&
-						pool.CapacityType == aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType) {
-						skippedPools = append(skippedPools, pool)
-						skipInstance = true
-						return false
-					}
-					return true
-				})
-				if skipInstance {
-					continue
+		overrides := orderOverrides(input)
+		launched := overrides[0]
+		for _, override := range overrides {
+			if fulfilled == int(*input.TargetCapacitySpecification.TotalTargetCapacity) {
+				break
+			}
+			skipInstance := false
+			e.InsufficientCapacityPools.Range(func(pool CapacityPool) bool {
+				if pool.InstanceType == overrideInstanceType(override) &&
+					pool.Zone == aws.StringValue(override.AvailabilityZone) &&
+					pool.CapacityType == aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType) {
+					skippedPools = append(skippedPools, pool)
+					skipInstance = true
+					return false
 				}
-				amiID := aws.String("")
-				if e.CalledWithCreateLaunchTemplateInput.Len() > 0 {
-					lt := e.CalledWithCreateLaunchTemplateInput.Pop()
-					amiID = lt.LaunchTemplateData.ImageId
-					e.CalledWithCreateLaunchTemplateInput.Add(lt)
+				return true
+			})
+			if skipInstance {
+				continue
+			}
+			amiID := aws.String("")
+			if e.CalledWithCreateLaunchTemplateInput.Len() > 0 {
+				lt := e.CalledWithCreateLaunchTemplateInput.Pop()
+				amiID = lt.LaunchTemplateData.ImageId
+				e.CalledWithCreateLaunchTemplateInput.Add(lt)
+			}
+			instanceState := ec2.InstanceStateNameRunning
+			for ; fulfilled < int(*input.TargetCapacitySpecification.TotalTargetCapacity); fulfilled++ {
+				pool := CapacityPool{
+					CapacityType: aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType),
+					InstanceType: overrideInstanceType(override),
+					Zone:         aws.StringValue(override.AvailabilityZone),
 				}
-				instanceState := ec2.InstanceStateNameRunning
-				for ; fulfilled < int(*input.TargetCapacitySpecification.TotalTargetCapacity); fulfilled++ {
-					instance := &ec2.Instance{
-						ImageId:               aws.String(*amiID),
-						InstanceId:            aws.String(test.RandomName()),
-						Placement:             &ec2.Placement{AvailabilityZone: input.LaunchTemplateConfigs[0].Overrides[0].AvailabilityZone},
-						PrivateDnsName:        aws.String(randomdata.IpV4Address()),
-						InstanceType:          input.LaunchTemplateConfigs[0].Overrides[0].InstanceType,
-						SpotInstanceRequestId: spotInstanceRequestID,
-						State: &ec2.InstanceState{
-							Name: &instanceState,
-						},
-					}
-					e.Instances.Store(*instance.InstanceId, instance)
-					instanceIds = append(instanceIds, instance.InstanceId)
+				instanceID := test.RandomName()
+				if !e.AvailableCapacity.take(pool, instanceID) {
+					skippedPools = append(skippedPools, pool)
+					break
 				}
-			}
-			if fulfilled == int(*input.TargetCapacitySpecification.TotalTargetCapacity) {
-				break
+				launched = override
+				instance := &ec2.Instance{
+					ImageId:               aws.String(*amiID),
+					InstanceId:            aws.String(instanceID),
+					Placement:             &ec2.Placement{AvailabilityZone: override.AvailabilityZone},
+					PrivateDnsName:        aws.String(randomdata.IpV4Address()),
+					InstanceType:          aws.String(overrideInstanceType(override)),
+					SpotInstanceRequestId: spotInstanceRequestID,
+					State: &ec2.InstanceState{
+						Name: &instanceState,
+					},
+				}
+				e.Instances.Store(*instance.InstanceId, instance)
+				instanceIds = append(instanceIds, instance.InstanceId)
 			}
 		}
 		result := &ec2.CreateFleetOutput{Instances: []*ec2.CreateFleetInstance{
 			{
 				InstanceIds:  instanceIds,
-				InstanceType: input.LaunchTemplateConfigs[0].Overrides[0].InstanceType,
+				InstanceType: aws.String(overrideInstanceType(launched)),
 				Lifecycle:    input.TargetCapacitySpecification.DefaultTargetCapacityType,
 				LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
 					Overrides: &ec2.FleetLaunchTemplateOverrides{
-						SubnetId:         input.LaunchTemplateConfigs[0].Overrides[0].SubnetId,
-						InstanceType:     input.LaunchTemplateConfigs[0].Overrides[0].InstanceType,
-						AvailabilityZone: input.LaunchTemplateConfigs[0].Overrides[0].AvailabilityZone,
+						SubnetId:         launched.SubnetId,
+						InstanceType:     launched.InstanceType,
+						AvailabilityZone: launched.AvailabilityZone,
 					},
 				},
 			},
@@ -191,12 +388,68 @@ func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFlee
 	})
 }
 
-func (e *EC2API) TerminateInstancesWithContext(_ context.Context, input *ec2.TerminateInstancesInput, _ ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+// RunInstancesWithContext launches a single instance of the input's instance type into the zone of its placement
+func (e *EC2API) RunInstancesWithContext(ctx context.Context, input *ec2.RunInstancesInput, _ ...request.Option) (*ec2.Reservation, error) {
+	e.Calls.Inc("RunInstances")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
+	return e.RunInstancesBehavior.Invoke(input, func(input *ec2.RunInstancesInput) (*ec2.Reservation, error) {
+		capacityType := v1alpha5.CapacityTypeOnDemand
+		var spotInstanceRequestID *string
+		if input.InstanceMarketOptions != nil && aws.StringValue(input.InstanceMarketOptions.MarketType) == ec2.MarketTypeSpot {
+			capacityType = v1alpha5.CapacityTypeSpot
+			spotInstanceRequestID = aws.String(test.RandomName())
+		}
+		pool := CapacityPool{CapacityType: capacityType, InstanceType: aws.StringValue(input.InstanceType), Zone: aws.StringValue(input.Placement.AvailabilityZone)}
+		insufficient := false
+		e.InsufficientCapacityPools.Range(func(p CapacityPool) bool {
+			insufficient = p == pool
+			return !insufficient
+		})
+		instanceID := test.RandomName()
+		if insufficient || !e.AvailableCapacity.take(pool, instanceID) {
+			return nil, awserr.New("InsufficientInstanceCapacity", "There is no Spot capacity available that matches your request.", nil)
+		}
+		instance := &ec2.Instance{
+			InstanceId:            aws.String(instanceID),
+			Placement:             &ec2.Placement{AvailabilityZone: input.Placement.AvailabilityZone},
+			PrivateDnsName:        aws.String(randomdata.IpV4Address()),
+			InstanceType:          input.InstanceType,
+			SubnetId:              input.SubnetId,
+			SpotInstanceRequestId: spotInstanceRequestID,
+			State:                 &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNamePending)},
+		}
+		e.Instances.Store(instanceID, instance)
+		return &ec2.Reservation{Instances: []*ec2.Instance{instance}}, nil
+	})
+}
+
+func (e *EC2API) CancelSpotInstanceRequestsWithContext(ctx context.Context, input *ec2.CancelSpotInstanceRequestsInput, _ ...request.Option) (*ec2.CancelSpotInstanceRequestsOutput, error) {
+	e.Calls.Inc("CancelSpotInstanceRequests")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
+	return e.CancelSpotInstanceRequestsBehavior.Invoke(input, func(input *ec2.CancelSpotInstanceRequestsInput) (*ec2.CancelSpotInstanceRequestsOutput, error) {
+		return &ec2.CancelSpotInstanceRequestsOutput{
+			CancelledSpotInstanceRequests: lo.Map(input.SpotInstanceRequestIds, func(id *string, _ int) *ec2.CancelledSpotInstanceRequest {
+				return &ec2.CancelledSpotInstanceRequest{SpotInstanceRequestId: id, State: aws.String(ec2.CancelSpotInstanceRequestStateCancelled)}
+			}),
+		}, nil
+	})
+}
+
+func (e *EC2API) TerminateInstancesWithContext(ctx context.Context, input *ec2.TerminateInstancesInput, _ ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+	e.Calls.Inc("TerminateInstances")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
 	return e.TerminateInstancesBehavior.Invoke(input, func(input *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
 		var instanceStateChanges []*ec2.InstanceStateChange
 		for _, id := range input.InstanceIds {
 			instanceID := *id
 			if _, ok := e.Instances.LoadAndDelete(instanceID); ok {
+				e.AvailableCapacity.release(instanceID)
 				instanceStateChanges = append(instanceStateChanges, &ec2.InstanceStateChange{
 					PreviousState: &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning), Code: aws.Int64(16)},
 					CurrentState:  &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameShuttingDown), Code: aws.Int64(32)},
@@ -208,18 +461,84 @@ func (e *EC2API) TerminateInstancesWithContext(_ context.Context, input *ec2.Ter
 	})
 }
 
-func (e *EC2API) CreateLaunchTemplateWithContext(_ context.Context, input *ec2.CreateLaunchTemplateInput, _ ...request.Option) (*ec2.CreateLaunchTemplateOutput, error) {
+func (e *EC2API) ModifyInstanceMetadataOptionsWithContext(ctx context.Context, input *ec2.ModifyInstanceMetadataOptionsInput, _ ...request.Option) (*ec2.ModifyInstanceMetadataOptionsOutput, error) {
+	e.Calls.Inc("ModifyInstanceMetadataOptions")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
+	return e.ModifyInstanceMetadataOptionsBehavior.Invoke(input, func(input *ec2.ModifyInstanceMetadataOptionsInput) (*ec2.ModifyInstanceMetadataOptionsOutput, error) {
+		raw, ok := e.Instances.Load(aws.StringValue(input.InstanceId))
+		if !ok {
+			return nil, awserr.New("InvalidInstanceID.NotFound", "instance not found", nil)
+		}
+		instance := raw.(*ec2.Instance)
+		instance.MetadataOptions = &ec2.InstanceMetadataOptionsResponse{
+			HttpEndpoint:            input.HttpEndpoint,
+			HttpProtocolIpv6:        input.HttpProtocolIpv6,
+			HttpPutResponseHopLimit: input.HttpPutResponseHopLimit,
+			HttpTokens:              input.HttpTokens,
+		}
+		return &ec2.ModifyInstanceMetadataOptionsOutput{InstanceId: input.InstanceId, InstanceMetadataOptions: instance.MetadataOptions}, nil
+	})
+}
+
+func (e *EC2API) ModifyNetworkInterfaceAttributeWithContext(ctx context.Context, input *ec2.ModifyNetworkInterfaceAttributeInput, _ ...request.Option) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+	e.Calls.Inc("ModifyNetworkInterfaceAttribute")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
+	return e.ModifyNetworkInterfaceAttributeBehavior.Invoke(input, func(input *ec2.ModifyNetworkInterfaceAttributeInput) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+		return &ec2.ModifyNetworkInterfaceAttributeOutput{}, nil
+	})
+}
+
+func (e *EC2API) DescribeImageAttributeWithContext(ctx context.Context, input *ec2.DescribeImageAttributeInput, _ ...request.Option) (*ec2.DescribeImageAttributeOutput, error) {
+	e.Calls.Inc("DescribeImageAttribute")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
+	return e.DescribeImageAttributeBehavior.Invoke(input, func(input *ec2.DescribeImageAttributeInput) (*ec2.DescribeImageAttributeOutput, error) {
+		output := &ec2.DescribeImageAttributeOutput{ImageId: input.ImageId}
+		if data, ok := e.UEFIData.Load(aws.StringValue(input.ImageId)); ok && aws.StringValue(input.Attribute) == ec2.ImageAttributeNameUefiData {
+			output.UefiData = &ec2.AttributeValue{Value: aws.String(data.(string))}
+		}
+		return output, nil
+	})
+}
+
+func (e *EC2API) GetConsoleOutputWithContext(ctx context.Context, input *ec2.GetConsoleOutputInput, _ ...request.Option) (*ec2.GetConsoleOutputOutput, error) {
+	e.Calls.Inc("GetConsoleOutput")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
+	return e.GetConsoleOutputBehavior.Invoke(input, func(input *ec2.GetConsoleOutputInput) (*ec2.GetConsoleOutputOutput, error) {
+		return &ec2.GetConsoleOutputOutput{InstanceId: input.InstanceId}, nil
+	})
+}
+
+func (e *EC2API) CreateLaunchTemplateWithContext(ctx context.Context, input *ec2.CreateLaunchTemplateInput, _ ...request.Option) (*ec2.CreateLaunchTemplateOutput, error) {
+	e.Calls.Inc("CreateLaunchTemplate")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
 	}
 	e.CalledWithCreateLaunchTemplateInput.Add(input)
+	if !e.CreateLaunchTemplateError.IsNil() {
+		return nil, e.CreateLaunchTemplateError.Get()
+	}
 	launchTemplate := &ec2.LaunchTemplate{LaunchTemplateName: input.LaunchTemplateName}
 	e.LaunchTemplates.Store(input.LaunchTemplateName, launchTemplate)
 	return &ec2.CreateLaunchTemplateOutput{LaunchTemplate: launchTemplate}, nil
 }
 
-func (e *EC2API) CreateTagsWithContext(_ context.Context, input *ec2.CreateTagsInput, _ ...request.Option) (*ec2.CreateTagsOutput, error) {
+func (e *EC2API) CreateTagsWithContext(ctx context.Context, input *ec2.CreateTagsInput, _ ...request.Option) (*ec2.CreateTagsOutput, error) {
+	e.Calls.Inc("CreateTags")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
 	return e.CreateTagsBehavior.Invoke(input, func(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
 		// Update passed in instances with the passed tags
 		for _, id := range input.Resources {
@@ -238,7 +557,11 @@ func (e *EC2API) CreateTagsWithContext(_ context.Context, input *ec2.CreateTagsI
 	})
 }
 
-func (e *EC2API) DescribeInstancesWithContext(_ context.Context, input *ec2.DescribeInstancesInput, _ ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+func (e *EC2API) DescribeInstancesWithContext(ctx context.Context, input *ec2.DescribeInstancesInput, _ ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	e.Calls.Inc("DescribeInstances")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
 	return e.DescribeInstancesBehavior.Invoke(input, func(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
 		var instances []*ec2.Instance
 
@@ -317,7 +640,11 @@ func filterInstances(instances []*ec2.Instance, filters []*ec2.Filter) []*ec2.In
 	return ret
 }
 
-func (e *EC2API) DescribeImagesWithContext(_ context.Context, input *ec2.DescribeImagesInput, _ ...request.Option) (*ec2.DescribeImagesOutput, error) {
+func (e *EC2API) DescribeImagesWithContext(ctx context.Context, input *ec2.DescribeImagesInput, _ ...request.Option) (*ec2.DescribeImagesOutput, error) {
+	e.Calls.Inc("DescribeImages")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
@@ -343,16 +670,29 @@ func (e *EC2API) DescribeImagesWithContext(_ context.Context, input *ec2.Describ
 	}, nil
 }
 
+// DescribeImagesPagesWithContext returns the images in pages of at most MaxResults images, and stops paging once fn
+// returns false
 func (e *EC2API) DescribeImagesPagesWithContext(ctx context.Context, input *ec2.DescribeImagesInput, fn func(*ec2.DescribeImagesOutput, bool) bool, _ ...request.Option) error {
 	out, err := e.DescribeImagesWithContext(ctx, input)
 	if err != nil {
 		return err
 	}
-	fn(out, false)
+	pageSize := int(aws.Int64Value(input.MaxResults))
+	if pageSize <= 0 || len(out.Images) <= pageSize {
+		fn(out, true)
+		return nil
+	}
+	for i := 0; i < len(out.Images); i += pageSize {
+		end := lo.Min([]int{i + pageSize, len(out.Images)})
+		if !fn(&ec2.DescribeImagesOutput{Images: out.Images[i:end]}, end == len(out.Images)) {
+			return nil
+		}
+	}
 	return nil
 }
 
 func (e *EC2API) DescribeLaunchTemplatesWithContext(_ context.Context, input *ec2.DescribeLaunchTemplatesInput, _ ...request.Option) (*ec2.DescribeLaunchTemplatesOutput, error) {
+	e.Calls.Inc("DescribeLaunchTemplates")
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
@@ -375,6 +715,7 @@ func (e *EC2API) DescribeLaunchTemplatesWithContext(_ context.Context, input *ec
 }
 
 func (e *EC2API) DescribeSubnetsWithContext(_ context.Context, input *ec2.DescribeSubnetsInput, _ ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	e.Calls.Inc("DescribeSubnets")
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
@@ -423,6 +764,7 @@ func (e *EC2API) DescribeSubnetsWithContext(_ context.Context, input *ec2.Descri
 }
 
 func (e *EC2API) DescribeSecurityGroupsWithContext(_ context.Context, input *ec2.DescribeSecurityGroupsInput, _ ...request.Option) (*ec2.DescribeSecurityGroupsOutput, error) {
+	e.Calls.Inc("DescribeSecurityGroups")
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
@@ -466,6 +808,7 @@ func (e *EC2API) DescribeSecurityGroupsWithContext(_ context.Context, input *ec2
 }
 
 func (e *EC2API) DescribeAvailabilityZonesWithContext(context.Context, *ec2.DescribeAvailabilityZonesInput, ...request.Option) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	e.Calls.Inc("DescribeAvailabilityZones")
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
@@ -481,6 +824,7 @@ func (e *EC2API) DescribeAvailabilityZonesWithContext(context.Context, *ec2.Desc
 }
 
 func (e *EC2API) DescribeInstanceTypesWithContext(_ context.Context, _ *ec2.DescribeInstanceTypesInput, _ ...request.Option) (*ec2.DescribeInstanceTypesOutput, error) {
+	e.Calls.Inc("DescribeInstanceTypes")
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
@@ -501,6 +845,7 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(ctx context.Context, inpu
 }
 
 func (e *EC2API) DescribeInstanceTypeOfferingsWithContext(_ context.Context, _ *ec2.DescribeInstanceTypeOfferingsInput, _ ...request.Option) (*ec2.DescribeInstanceTypeOfferingsOutput, error) {
+	e.Calls.Inc("DescribeInstanceTypeOfferings")
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
@@ -616,6 +961,7 @@ func (e *EC2API) DescribeInstanceTypeOfferingsPagesWithContext(ctx context.Conte
 }
 
 func (e *EC2API) DescribeSpotPriceHistoryWithContext(_ aws.Context, input *ec2.DescribeSpotPriceHistoryInput, _ ...request.Option) (*ec2.DescribeSpotPriceHistoryOutput, error) {
+	e.Calls.Inc("DescribeSpotPriceHistory")
 	e.DescribeSpotPriceHistoryInput.Set(input)
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
@@ -636,3 +982,72 @@ func (e *EC2API) DescribeSpotPriceHistoryPagesWithContext(ctx aws.Context, input
 	fn(out, false)
 	return nil
 }
+
+func (e *EC2API) GetSpotPlacementScoresWithContext(ctx context.Context, input *ec2.GetSpotPlacementScoresInput, _ ...request.Option) (*ec2.GetSpotPlacementScoresOutput, error) {
+	e.Calls.Inc("GetSpotPlacementScores")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
+	return e.GetSpotPlacementScoresBehavior.Invoke(input, func(_ *ec2.GetSpotPlacementScoresInput) (*ec2.GetSpotPlacementScoresOutput, error) {
+		return &ec2.GetSpotPlacementScoresOutput{}, nil
+	})
+}
+
+func (e *EC2API) GetSpotPlacementScoresPagesWithContext(ctx context.Context, input *ec2.GetSpotPlacementScoresInput, fn func(*ec2.GetSpotPlacementScoresOutput, bool) bool, _ ...request.Option) error {
+	out, err := e.GetSpotPlacementScoresWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+	fn(out, false)
+	return nil
+}
+
+func (e *EC2API) DescribeCapacityReservationsWithContext(_ context.Context, _ *ec2.DescribeCapacityReservationsInput, _ ...request.Option) (*ec2.DescribeCapacityReservationsOutput, error) {
+	e.Calls.Inc("DescribeCapacityReservations")
+	if !e.NextError.IsNil() {
+		defer e.NextError.Reset()
+		return nil, e.NextError.Get()
+	}
+	if !e.DescribeCapacityReservationsOutput.IsNil() {
+		return e.DescribeCapacityReservationsOutput.Clone(), nil
+	}
+	return &ec2.DescribeCapacityReservationsOutput{}, nil
+}
+
+func (e *EC2API) CreateCapacityReservationWithContext(ctx context.Context, input *ec2.CreateCapacityReservationInput, _ ...request.Option) (*ec2.CreateCapacityReservationOutput, error) {
+	e.Calls.Inc("CreateCapacityReservation")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
+	return e.CreateCapacityReservationBehavior.Invoke(input, func(input *ec2.CreateCapacityReservationInput) (*ec2.CreateCapacityReservationOutput, error) {
+		return &ec2.CreateCapacityReservationOutput{CapacityReservation: &ec2.CapacityReservation{
+			CapacityReservationId:  aws.String(test.RandomName()),
+			InstanceType:           input.InstanceType,
+			AvailabilityZone:       input.AvailabilityZone,
+			TotalInstanceCount:     input.InstanceCount,
+			AvailableInstanceCount: input.InstanceCount,
+			InstanceMatchCriteria:  input.InstanceMatchCriteria,
+			EndDate:                input.EndDate,
+			State:                  aws.String(ec2.CapacityReservationStateActive),
+		}}, nil
+	})
+}
+
+func (e *EC2API) CancelCapacityReservationWithContext(ctx context.Context, input *ec2.CancelCapacityReservationInput, _ ...request.Option) (*ec2.CancelCapacityReservationOutput, error) {
+	e.Calls.Inc("CancelCapacityReservation")
+	if err := e.simulateLatency(ctx); err != nil {
+		return nil, err
+	}
+	return e.CancelCapacityReservationBehavior.Invoke(input, func(input *ec2.CancelCapacityReservationInput) (*ec2.CancelCapacityReservationOutput, error) {
+		return &ec2.CancelCapacityReservationOutput{Return: aws.Bool(true)}, nil
+	})
+}
+
+func (e *EC2API) DescribeCapacityReservationsPagesWithContext(ctx context.Context, input *ec2.DescribeCapacityReservationsInput, fn func(*ec2.DescribeCapacityReservationsOutput, bool) bool, _ ...request.Option) error {
+	out, err := e.DescribeCapacityReservationsWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+	fn(out, false)
+	return nil
+}
@@ -0,0 +1,43 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+)
+
+type EventBridgeAPI struct {
+	eventbridgeiface.EventBridgeAPI
+	EventBridgeBehavior
+}
+
+type EventBridgeBehavior struct {
+	PutEventsBehavior MockedFunction[eventbridge.PutEventsInput, eventbridge.PutEventsOutput]
+}
+
+// Reset must be called between tests otherwise tests will pollute
+// each other.
+func (e *EventBridgeAPI) Reset() {
+	e.PutEventsBehavior.Reset()
+}
+
+func (e *EventBridgeAPI) PutEventsWithContext(_ aws.Context, input *eventbridge.PutEventsInput, _ ...request.Option) (*eventbridge.PutEventsOutput, error) {
+	return e.PutEventsBehavior.Invoke(input, func(input *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+		return &eventbridge.PutEventsOutput{FailedEntryCount: aws.Int64(0)}, nil
+	})
+}
@@ -0,0 +1,55 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/imagebuilder"
+	"github.com/aws/aws-sdk-go/service/imagebuilder/imagebuilderiface"
+	"github.com/samber/lo"
+)
+
+type ImageBuilderAPI struct {
+	imagebuilderiface.ImagebuilderAPI
+	// ImageSummaries are the images listed for each image pipeline arn, or for each image version arn when the build
+	// versions of an image are listed
+	ImageSummaries map[string][]*imagebuilder.ImageSummary
+	WantErr        error
+}
+
+func (a ImageBuilderAPI) ListImagePipelineImagesPagesWithContext(_ context.Context, input *imagebuilder.ListImagePipelineImagesInput,
+	fn func(*imagebuilder.ListImagePipelineImagesOutput, bool) bool, _ ...request.Option) error {
+	if a.WantErr != nil {
+		return a.WantErr
+	}
+	fn(&imagebuilder.ListImagePipelineImagesOutput{ImageSummaryList: a.ImageSummaries[lo.FromPtr(input.ImagePipelineArn)]}, true)
+	return nil
+}
+
+func (a ImageBuilderAPI) ListImageBuildVersionsPagesWithContext(_ context.Context, input *imagebuilder.ListImageBuildVersionsInput,
+	fn func(*imagebuilder.ListImageBuildVersionsOutput, bool) bool, _ ...request.Option) error {
+	if a.WantErr != nil {
+		return a.WantErr
+	}
+	fn(&imagebuilder.ListImageBuildVersionsOutput{ImageSummaryList: a.ImageSummaries[lo.FromPtr(input.ImageVersionArn)]}, true)
+	return nil
+}
+
+func (a *ImageBuilderAPI) Reset() {
+	a.ImageSummaries = nil
+	a.WantErr = nil
+}
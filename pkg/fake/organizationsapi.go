@@ -0,0 +1,50 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/organizations/organizationsiface"
+)
+
+type OrganizationsAPI struct {
+	organizationsiface.OrganizationsAPI
+	OrganizationsBehavior
+}
+
+type OrganizationsBehavior struct {
+	DescribeEffectivePolicyOutput AtomicPtr[organizations.DescribeEffectivePolicyOutput]
+	NextError                     AtomicError
+}
+
+// Reset must be called between tests otherwise tests will pollute
+// each other.
+func (o *OrganizationsAPI) Reset() {
+	o.DescribeEffectivePolicyOutput.Reset()
+	o.NextError.Reset()
+}
+
+func (o *OrganizationsAPI) DescribeEffectivePolicyWithContext(_ aws.Context, _ *organizations.DescribeEffectivePolicyInput, _ ...request.Option) (*organizations.DescribeEffectivePolicyOutput, error) {
+	if !o.NextError.IsNil() {
+		defer o.NextError.Reset()
+		return nil, o.NextError.Get()
+	}
+	if !o.DescribeEffectivePolicyOutput.IsNil() {
+		return o.DescribeEffectivePolicyOutput.Clone(), nil
+	}
+	return &organizations.DescribeEffectivePolicyOutput{}, nil
+}
@@ -16,11 +16,21 @@ package fake
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/spotinterruption"
 )
 
 const (
@@ -30,15 +40,20 @@ const (
 // SQSBehavior must be reset between tests otherwise tests will
 // pollute each other.
 type SQSBehavior struct {
-	GetQueueURLBehavior        MockedFunction[sqs.GetQueueUrlInput, sqs.GetQueueUrlOutput]
-	GetQueueAttributesBehavior MockedFunction[sqs.GetQueueAttributesInput, sqs.GetQueueAttributesOutput]
-	ReceiveMessageBehavior     MockedFunction[sqs.ReceiveMessageInput, sqs.ReceiveMessageOutput]
-	DeleteMessageBehavior      MockedFunction[sqs.DeleteMessageInput, sqs.DeleteMessageOutput]
+	GetQueueURLBehavior             MockedFunction[sqs.GetQueueUrlInput, sqs.GetQueueUrlOutput]
+	GetQueueAttributesBehavior      MockedFunction[sqs.GetQueueAttributesInput, sqs.GetQueueAttributesOutput]
+	ReceiveMessageBehavior          MockedFunction[sqs.ReceiveMessageInput, sqs.ReceiveMessageOutput]
+	DeleteMessageBehavior           MockedFunction[sqs.DeleteMessageInput, sqs.DeleteMessageOutput]
+	ChangeMessageVisibilityBehavior MockedFunction[sqs.ChangeMessageVisibilityInput, sqs.ChangeMessageVisibilityOutput]
 }
 
 type SQSAPI struct {
 	sqsiface.SQSAPI
 	SQSBehavior
+
+	mu     sync.Mutex
+	queue  []*sqs.Message   // messages that are returned by ReceiveMessage until they are deleted
+	hidden sets.Set[string] // receipt handles of messages whose visibility was changed, which aren't returned anymore
 }
 
 // Reset must be called between tests otherwise tests will pollute
@@ -48,6 +63,45 @@ func (s *SQSAPI) Reset() {
 	s.GetQueueAttributesBehavior.Reset()
 	s.ReceiveMessageBehavior.Reset()
 	s.DeleteMessageBehavior.Reset()
+	s.ChangeMessageVisibilityBehavior.Reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = nil
+	s.hidden = nil
+}
+
+// Enqueue adds a message with the body to the queue
+func (s *SQSAPI) Enqueue(body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := string(uuid.NewUUID())
+	s.queue = append(s.queue, &sqs.Message{
+		Body:          aws.String(body),
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String(id),
+	})
+}
+
+// SpotInterruptionMessage returns the body of the EventBridge message that EC2 sends two minutes before it
+// interrupts the spot instance
+func SpotInterruptionMessage(instanceID string) string {
+	return string(lo.Must(json.Marshal(spotinterruption.Message{
+		Metadata: messages.Metadata{
+			Version:    "0",
+			Account:    "000000000000",
+			DetailType: "EC2 Spot Instance Interruption Warning",
+			ID:         string(uuid.NewUUID()),
+			Region:     "us-west-2",
+			Resources:  []string{fmt.Sprintf("arn:aws:ec2:us-west-2:instance/%s", instanceID)},
+			Source:     "aws.ec2",
+			Time:       time.Now(),
+		},
+		Detail: spotinterruption.Detail{
+			InstanceID:     instanceID,
+			InstanceAction: "terminate",
+		},
+	})))
 }
 
 //nolint:revive,stylecheck
@@ -60,13 +114,38 @@ func (s *SQSAPI) GetQueueUrlWithContext(_ context.Context, input *sqs.GetQueueUr
 }
 
 func (s *SQSAPI) ReceiveMessageWithContext(_ context.Context, input *sqs.ReceiveMessageInput, _ ...request.Option) (*sqs.ReceiveMessageOutput, error) {
-	return s.ReceiveMessageBehavior.Invoke(input, func(_ *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
-		return nil, nil
+	return s.ReceiveMessageBehavior.Invoke(input, func(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		visible := lo.Reject(s.queue, func(m *sqs.Message, _ int) bool { return s.hidden.Has(aws.StringValue(m.ReceiptHandle)) })
+		if len(visible) == 0 {
+			return nil, nil
+		}
+		return &sqs.ReceiveMessageOutput{
+			Messages: lo.Slice(visible, 0, int(lo.Max([]int64{aws.Int64Value(input.MaxNumberOfMessages), 1}))),
+		}, nil
 	})
 }
 
 func (s *SQSAPI) DeleteMessageWithContext(_ context.Context, input *sqs.DeleteMessageInput, _ ...request.Option) (*sqs.DeleteMessageOutput, error) {
-	return s.DeleteMessageBehavior.Invoke(input, func(_ *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	return s.DeleteMessageBehavior.Invoke(input, func(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.queue = lo.Reject(s.queue, func(m *sqs.Message, _ int) bool {
+			return aws.StringValue(m.ReceiptHandle) == aws.StringValue(input.ReceiptHandle)
+		})
+		return nil, nil
+	})
+}
+
+func (s *SQSAPI) ChangeMessageVisibilityWithContext(_ context.Context, input *sqs.ChangeMessageVisibilityInput, _ ...request.Option) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return s.ChangeMessageVisibilityBehavior.Invoke(input, func(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.hidden == nil {
+			s.hidden = sets.New[string]()
+		}
+		s.hidden.Insert(aws.StringValue(input.ReceiptHandle))
 		return nil, nil
 	})
 }
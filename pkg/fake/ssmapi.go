@@ -20,6 +20,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/mitchellh/hashstructure/v2"
+	"github.com/samber/lo"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
@@ -32,9 +33,14 @@ type SSMAPI struct {
 	Parameters         map[string]string
 	GetParameterOutput *ssm.GetParameterOutput
 	WantErr            error
+	CalledWithInput    AtomicPtrSlice[ssm.GetParameterInput]
+
+	DescribeInstanceInformationOutput AtomicPtr[ssm.DescribeInstanceInformationOutput]
+	DescribeInstanceInformationError  AtomicError
 }
 
-func (a SSMAPI) GetParameterWithContext(_ context.Context, input *ssm.GetParameterInput, _ ...request.Option) (*ssm.GetParameterOutput, error) {
+func (a *SSMAPI) GetParameterWithContext(_ context.Context, input *ssm.GetParameterInput, _ ...request.Option) (*ssm.GetParameterOutput, error) {
+	a.CalledWithInput.Add(input)
 	if a.WantErr != nil {
 		return nil, a.WantErr
 	}
@@ -56,8 +62,32 @@ func (a SSMAPI) GetParameterWithContext(_ context.Context, input *ssm.GetParamet
 	}, nil
 }
 
+// DescribeInstanceInformationWithContext returns the instances of DescribeInstanceInformationOutput that match the
+// InstanceIds filter of the input
+func (a *SSMAPI) DescribeInstanceInformationWithContext(_ context.Context, input *ssm.DescribeInstanceInformationInput, _ ...request.Option) (*ssm.DescribeInstanceInformationOutput, error) {
+	if !a.DescribeInstanceInformationError.IsNil() {
+		return nil, a.DescribeInstanceInformationError.Get()
+	}
+	output := &ssm.DescribeInstanceInformationOutput{}
+	if !a.DescribeInstanceInformationOutput.IsNil() {
+		output = a.DescribeInstanceInformationOutput.Clone()
+	}
+	for _, filter := range input.Filters {
+		if aws.StringValue(filter.Key) != "InstanceIds" {
+			continue
+		}
+		output.InstanceInformationList = lo.Filter(output.InstanceInformationList, func(i *ssm.InstanceInformation, _ int) bool {
+			return lo.Contains(aws.StringValueSlice(filter.Values), aws.StringValue(i.InstanceId))
+		})
+	}
+	return output, nil
+}
+
 func (a *SSMAPI) Reset() {
+	a.DescribeInstanceInformationOutput.Reset()
+	a.DescribeInstanceInformationError.Reset()
 	a.GetParameterOutput = nil
 	a.Parameters = nil
 	a.WantErr = nil
+	a.CalledWithInput.Reset()
 }
@@ -75,8 +75,14 @@ func FilterDescribeSecurtyGroups(sgs []*ec2.SecurityGroup, filters []*ec2.Filter
 // FilterDescribeSubnets filters the passed in subnets based on the filters passed in.
 // Filters are chained with a logical "AND"
 func FilterDescribeSubnets(subnets []*ec2.Subnet, filters []*ec2.Filter) []*ec2.Subnet {
+	isOutpostFilter := func(filter *ec2.Filter, _ int) bool { return aws.StringValue(filter.Name) == "outpost-arn" }
 	return lo.Filter(subnets, func(subnet *ec2.Subnet, _ int) bool {
-		return Filter(filters, *subnet.SubnetId, "", subnet.Tags)
+		for _, filter := range lo.Filter(filters, isOutpostFilter) {
+			if !lo.Contains(aws.StringValueSlice(filter.Values), aws.StringValue(subnet.OutpostArn)) {
+				return false
+			}
+		}
+		return Filter(lo.Reject(filters, isOutpostFilter), *subnet.SubnetId, "", subnet.Tags)
 	})
 }
 
@@ -31,9 +31,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("c6g.large"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"arm64"}),
 			},
@@ -44,15 +48,25 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			MemoryInfo: &ec2.MemoryInfo{
 				SizeInMiB: aws.Int64(4096),
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(630),
+					BaselineIops:            aws.Int64(3600),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(3),
 				Ipv4AddressesPerInterface:    aws.Int64(10),
 				EncryptionInTransitSupported: aws.Bool(false),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(3),
+						BaselineBandwidthInGbps:  aws.Float64(0.75),
 					},
 				},
 			},
@@ -61,9 +75,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("dl1.24xlarge"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -88,28 +106,48 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			},
 			InstanceStorageInfo: &ec2.InstanceStorageInfo{NvmeSupport: aws.String("required"),
 				TotalSizeInGB: aws.Int64(4000),
+				Disks: []*ec2.DiskInfo{
+					{
+						SizeInGB: aws.Int64(1000),
+						Count:    aws.Int64(4),
+						Type:     aws.String("ssd"),
+					},
+				},
+			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(19000),
+					BaselineIops:            aws.Int64(80000),
+				},
 			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(60),
 				Ipv4AddressesPerInterface:    aws.Int64(50),
 				EncryptionInTransitSupported: aws.Bool(true),
+				EfaSupported:                 aws.Bool(true),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(15),
+						BaselineBandwidthInGbps:  aws.Float64(100),
 					},
 					{
 						NetworkCardIndex:         aws.Int64(1),
 						MaximumNetworkInterfaces: aws.Int64(15),
+						BaselineBandwidthInGbps:  aws.Float64(100),
 					},
 					{
 						NetworkCardIndex:         aws.Int64(2),
 						MaximumNetworkInterfaces: aws.Int64(15),
+						BaselineBandwidthInGbps:  aws.Float64(100),
 					},
 					{
 						NetworkCardIndex:         aws.Int64(3),
 						MaximumNetworkInterfaces: aws.Int64(15),
+						BaselineBandwidthInGbps:  aws.Float64(100),
 					},
 				},
 			},
@@ -118,9 +156,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("g4dn.8xlarge"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -145,16 +187,33 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			},
 			InstanceStorageInfo: &ec2.InstanceStorageInfo{NvmeSupport: aws.String("required"),
 				TotalSizeInGB: aws.Int64(900),
+				Disks: []*ec2.DiskInfo{
+					{
+						SizeInGB: aws.Int64(900),
+						Count:    aws.Int64(1),
+						Type:     aws.String("ssd"),
+					},
+				},
+			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(9500),
+					BaselineIops:            aws.Int64(40000),
+				},
 			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(4),
 				Ipv4AddressesPerInterface:    aws.Int64(15),
 				EncryptionInTransitSupported: aws.Bool(true),
+				EfaSupported:                 aws.Bool(true),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(4),
+						BaselineBandwidthInGbps:  aws.Float64(50),
 					},
 				},
 			},
@@ -163,9 +222,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("inf1.2xlarge"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -182,18 +245,31 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 						Name:         aws.String("Inferentia"),
 						Manufacturer: aws.String("AWS"),
 						Count:        aws.Int64(1),
+						MemoryInfo: &ec2.InferenceDeviceMemoryInfo{
+							SizeInMiB: aws.Int64(8192),
+						},
 					},
 				},
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(1190),
+					BaselineIops:            aws.Int64(6000),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(4),
 				Ipv4AddressesPerInterface:    aws.Int64(10),
 				EncryptionInTransitSupported: aws.Bool(true),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(4),
+						BaselineBandwidthInGbps:  aws.Float64(5),
 					},
 				},
 			},
@@ -202,9 +278,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("inf1.6xlarge"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -221,18 +301,31 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 						Name:         aws.String("Inferentia"),
 						Manufacturer: aws.String("AWS"),
 						Count:        aws.Int64(4),
+						MemoryInfo: &ec2.InferenceDeviceMemoryInfo{
+							SizeInMiB: aws.Int64(8192),
+						},
 					},
 				},
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(4750),
+					BaselineIops:            aws.Int64(20000),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(8),
 				Ipv4AddressesPerInterface:    aws.Int64(30),
 				EncryptionInTransitSupported: aws.Bool(true),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(8),
+						BaselineBandwidthInGbps:  aws.Float64(25),
 					},
 				},
 			},
@@ -241,9 +334,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("m5.large"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -254,15 +351,25 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			MemoryInfo: &ec2.MemoryInfo{
 				SizeInMiB: aws.Int64(8192),
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(650),
+					BaselineIops:            aws.Int64(3600),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(3),
 				Ipv4AddressesPerInterface:    aws.Int64(10),
 				EncryptionInTransitSupported: aws.Bool(false),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(3),
+						BaselineBandwidthInGbps:  aws.Float64(0.75),
 					},
 				},
 			},
@@ -271,9 +378,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("m5.metal"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(true),
 			Hypervisor:                    aws.String(""),
+			NitroTpmSupport:               aws.String("unsupported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -284,15 +395,25 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			MemoryInfo: &ec2.MemoryInfo{
 				SizeInMiB: aws.Int64(393216),
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(19000),
+					BaselineIops:            aws.Int64(80000),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(15),
 				Ipv4AddressesPerInterface:    aws.Int64(50),
 				EncryptionInTransitSupported: aws.Bool(false),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(15),
+						BaselineBandwidthInGbps:  aws.Float64(25),
 					},
 				},
 			},
@@ -301,9 +422,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("m5.xlarge"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -314,15 +439,25 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			MemoryInfo: &ec2.MemoryInfo{
 				SizeInMiB: aws.Int64(16384),
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(1150),
+					BaselineIops:            aws.Int64(6000),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(4),
 				Ipv4AddressesPerInterface:    aws.Int64(15),
 				EncryptionInTransitSupported: aws.Bool(false),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(4),
+						BaselineBandwidthInGbps:  aws.Float64(1.25),
 					},
 				},
 			},
@@ -331,9 +466,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("m6idn.32xlarge"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -346,20 +485,38 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			},
 			InstanceStorageInfo: &ec2.InstanceStorageInfo{NvmeSupport: aws.String("required"),
 				TotalSizeInGB: aws.Int64(7600),
+				Disks: []*ec2.DiskInfo{
+					{
+						SizeInGB: aws.Int64(1900),
+						Count:    aws.Int64(4),
+						Type:     aws.String("ssd"),
+					},
+				},
+			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(100000),
+					BaselineIops:            aws.Int64(400000),
+				},
 			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(14),
 				Ipv4AddressesPerInterface:    aws.Int64(50),
 				EncryptionInTransitSupported: aws.Bool(true),
+				EfaSupported:                 aws.Bool(true),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(7),
+						BaselineBandwidthInGbps:  aws.Float64(100),
 					},
 					{
 						NetworkCardIndex:         aws.Int64(1),
 						MaximumNetworkInterfaces: aws.Int64(7),
+						BaselineBandwidthInGbps:  aws.Float64(100),
 					},
 				},
 			},
@@ -368,9 +525,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("p3.8xlarge"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("xen"),
+			NitroTpmSupport:               aws.String("unsupported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -393,15 +554,25 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 					},
 				},
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("unsupported"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(7000),
+					BaselineIops:            aws.Int64(40000),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(8),
 				Ipv4AddressesPerInterface:    aws.Int64(30),
 				EncryptionInTransitSupported: aws.Bool(false),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(8),
+						BaselineBandwidthInGbps:  aws.Float64(10),
 					},
 				},
 			},
@@ -410,9 +581,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("t3.large"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(true),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -423,15 +598,25 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			MemoryInfo: &ec2.MemoryInfo{
 				SizeInMiB: aws.Int64(8192),
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(695),
+					BaselineIops:            aws.Int64(4000),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(3),
 				Ipv4AddressesPerInterface:    aws.Int64(12),
 				EncryptionInTransitSupported: aws.Bool(false),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(3),
+						BaselineBandwidthInGbps:  aws.Float64(0.512),
 					},
 				},
 			},
@@ -440,9 +625,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("t4g.medium"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(true),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(false),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"arm64"}),
 			},
@@ -453,15 +642,25 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			MemoryInfo: &ec2.MemoryInfo{
 				SizeInMiB: aws.Int64(4096),
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(347),
+					BaselineIops:            aws.Int64(2000),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(3),
 				Ipv4AddressesPerInterface:    aws.Int64(6),
 				EncryptionInTransitSupported: aws.Bool(false),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(3),
+						BaselineBandwidthInGbps:  aws.Float64(0.256),
 					},
 				},
 			},
@@ -470,9 +669,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("t4g.small"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(true),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(false),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"arm64"}),
 			},
@@ -483,15 +686,25 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			MemoryInfo: &ec2.MemoryInfo{
 				SizeInMiB: aws.Int64(2048),
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(174),
+					BaselineIops:            aws.Int64(1000),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(3),
 				Ipv4AddressesPerInterface:    aws.Int64(4),
 				EncryptionInTransitSupported: aws.Bool(false),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(3),
+						BaselineBandwidthInGbps:  aws.Float64(0.128),
 					},
 				},
 			},
@@ -500,9 +713,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("t4g.xlarge"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(true),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(false),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"arm64"}),
 			},
@@ -513,15 +730,25 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			MemoryInfo: &ec2.MemoryInfo{
 				SizeInMiB: aws.Int64(16384),
 			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(695),
+					BaselineIops:            aws.Int64(4000),
+				},
+			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(4),
 				Ipv4AddressesPerInterface:    aws.Int64(15),
 				EncryptionInTransitSupported: aws.Bool(false),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(4),
+						BaselineBandwidthInGbps:  aws.Float64(0.512),
 					},
 				},
 			},
@@ -530,9 +757,13 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			InstanceType:                  aws.String("trn1.2xlarge"),
 			SupportedUsageClasses:         aws.StringSlice([]string{"on-demand", "spot"}),
 			SupportedVirtualizationTypes:  aws.StringSlice([]string{"hvm"}),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
+			SupportedRootDeviceTypes:      aws.StringSlice([]string{"ebs"}),
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroTpmSupport:               aws.String("supported"),
+			DedicatedHostsSupported:       aws.Bool(true),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -545,16 +776,33 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			},
 			InstanceStorageInfo: &ec2.InstanceStorageInfo{NvmeSupport: aws.String("required"),
 				TotalSizeInGB: aws.Int64(474),
+				Disks: []*ec2.DiskInfo{
+					{
+						SizeInGB: aws.Int64(474),
+						Count:    aws.Int64(1),
+						Type:     aws.String("ssd"),
+					},
+				},
+			},
+			EbsInfo: &ec2.EbsInfo{
+				EbsOptimizedSupport: aws.String("default"),
+				NvmeSupport:         aws.String("required"),
+				EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+					BaselineBandwidthInMbps: aws.Int64(5000),
+					BaselineIops:            aws.Int64(16250),
+				},
 			},
 			NetworkInfo: &ec2.NetworkInfo{
 				MaximumNetworkInterfaces:     aws.Int64(4),
 				Ipv4AddressesPerInterface:    aws.Int64(15),
 				EncryptionInTransitSupported: aws.Bool(true),
+				EfaSupported:                 aws.Bool(false),
 				DefaultNetworkCardIndex:      aws.Int64(0),
 				NetworkCards: []*ec2.NetworkCardInfo{
 					{
 						NetworkCardIndex:         aws.Int64(0),
 						MaximumNetworkInterfaces: aws.Int64(4),
+						BaselineBandwidthInGbps:  aws.Float64(3.125),
 					},
 				},
 			},
@@ -0,0 +1,98 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+)
+
+var (
+	serviceLabel   = "service"
+	operationLabel = "operation"
+	errorCodeLabel = "error_code"
+	nodeClassLabel = "nodeclass"
+
+	APIRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "aws_api_requests_total",
+			Help:      "Number of AWS API calls made by Karpenter, including retries as a single call. Labeled by service, operation, and the error code if the call failed.",
+		},
+		[]string{
+			serviceLabel,
+			operationLabel,
+			errorCodeLabel,
+		},
+	)
+	AttributedAPIRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "aws_api_requests_attributed_total",
+			Help:      "Number of AWS API calls made by Karpenter on behalf of a nodepool or nodeclass, including retries as a single call. Batched calls are counted once for each nodepool and nodeclass that they're made on behalf of. Calls that aren't made on behalf of either aren't counted. Labeled by service, operation, nodepool, and nodeclass.",
+		},
+		[]string{
+			serviceLabel,
+			operationLabel,
+			metrics.NodePoolLabel,
+			nodeClassLabel,
+		},
+	)
+	APIRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "aws_api_request_duration_seconds",
+			Help:      "Duration of AWS API calls made by Karpenter in seconds, including any retries. Labeled by service and operation.",
+			Buckets:   metrics.DurationBuckets(),
+		},
+		[]string{
+			serviceLabel,
+			operationLabel,
+		},
+	)
+	APIThrottles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "aws_api_throttles_total",
+			Help:      "Number of AWS API call attempts that were throttled. Labeled by service and operation.",
+		},
+		[]string{
+			serviceLabel,
+			operationLabel,
+		},
+	)
+	CredentialsRefreshTime = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "credentials_refresh_time_seconds",
+			Help:      "Unix timestamp at which the AWS credentials currently used by Karpenter are refreshed, which is ahead of their expiration by 5 minutes for IRSA and 10 seconds for assumed roles. Only reported for credentials that expire.",
+		},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(APIRequests, AttributedAPIRequests, APIRequestDuration, APIThrottles, CredentialsRefreshTime)
+}
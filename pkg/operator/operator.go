@@ -30,13 +30,19 @@ import (
 	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	awscomputeoptimizer "github.com/aws/aws-sdk-go/service/computeoptimizer"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/eks"
 	"github.com/aws/aws-sdk-go/service/eks/eksiface"
+	"github.com/aws/aws-sdk-go/service/imagebuilder"
+	"github.com/aws/aws-sdk-go/service/organizations"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -44,52 +50,88 @@ import (
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/ptr"
 
+	"github.com/aws/karpenter-core/pkg/metrics"
 	"github.com/aws/karpenter-core/pkg/operator"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/capacityreservation"
+	"github.com/aws/karpenter/pkg/providers/computeoptimizer"
 	"github.com/aws/karpenter/pkg/providers/instance"
 	"github.com/aws/karpenter/pkg/providers/instancetype"
 	"github.com/aws/karpenter/pkg/providers/launchtemplate"
 	"github.com/aws/karpenter/pkg/providers/pricing"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
+	"github.com/aws/karpenter/pkg/providers/spotplacementscore"
 	"github.com/aws/karpenter/pkg/providers/subnet"
+	"github.com/aws/karpenter/pkg/providers/tagpolicy"
+	"github.com/aws/karpenter/pkg/providers/vpccni"
+	"github.com/aws/karpenter/pkg/utils/apirecord"
+	"github.com/aws/karpenter/pkg/utils/attribution"
 	"github.com/aws/karpenter/pkg/utils/project"
+	"github.com/aws/karpenter/pkg/utils/tracing"
 )
 
+// webIdentityExpiryWindow is how long before the expiration of web identity credentials that they are refreshed
+const webIdentityExpiryWindow = 5 * time.Minute
+
 // Operator is injected into the AWS CloudProvider's factories
 type Operator struct {
 	*operator.Operator
 
-	Session                   *session.Session
-	UnavailableOfferingsCache *awscache.UnavailableOfferings
-	EC2API                    ec2iface.EC2API
-	SubnetProvider            *subnet.Provider
-	SecurityGroupProvider     *securitygroup.Provider
-	AMIProvider               *amifamily.Provider
-	AMIResolver               *amifamily.Resolver
-	LaunchTemplateProvider    *launchtemplate.Provider
-	PricingProvider           *pricing.Provider
-	InstanceTypesProvider     *instancetype.Provider
-	InstanceProvider          *instance.Provider
+	Session                     *session.Session
+	UnavailableOfferingsCache   *awscache.UnavailableOfferings
+	ReadOnlyCache               *awscache.ReadOnly
+	SpotInterruptionsCache      *awscache.SpotInterruptions
+	WarmUp                      *awscache.WarmUp
+	BootstrapFailuresCache      *awscache.BootstrapFailures
+	EC2API                      ec2iface.EC2API
+	SubnetProvider              *subnet.Provider
+	CapacityReservationProvider *capacityreservation.Provider
+	SecurityGroupProvider       *securitygroup.Provider
+	AMIProvider                 *amifamily.Provider
+	AMIResolver                 *amifamily.Resolver
+	LaunchTemplateProvider      *launchtemplate.Provider
+	PricingProvider             *pricing.Provider
+	InstanceTypesProvider       *instancetype.Provider
+	InstanceProvider            *instance.Provider
+	ComputeOptimizerProvider    *computeoptimizer.Provider
+	SpotPlacementScoreProvider  *spotplacementscore.Provider
+	TagPolicyProvider           *tagpolicy.Provider
 }
 
 func NewOperator(ctx context.Context, operator *operator.Operator) (context.Context, *Operator) {
+	if err := tracing.Enable(ctx); err != nil {
+		logging.FromContext(ctx).Errorf("enabling tracing, %s", err)
+	}
 	config := &aws.Config{
 		STSRegionalEndpoint: endpoints.RegionalSTSEndpoint,
 	}
 
 	if assumeRoleARN := settings.FromContext(ctx).AssumeRoleARN; assumeRoleARN != "" {
-		config.Credentials = stscreds.NewCredentials(session.Must(session.NewSession()), assumeRoleARN,
-			func(provider *stscreds.AssumeRoleProvider) { setDurationAndExpiry(ctx, provider) })
+		config.Credentials = stscreds.NewCredentials(session.Must(session.NewSessionWithOptions(session.Options{
+			CredentialsProviderOptions: credentialsProviderOptions(),
+		})), assumeRoleARN, func(provider *stscreds.AssumeRoleProvider) { setDurationAndExpiry(ctx, provider) })
 	}
 
-	sess := withUserAgent(session.Must(session.NewSession(
-		request.WithRetryer(
+	sess := withAPIMetrics(WithTracing(WithCredentialsRefreshMetric(withUserAgent(session.Must(session.NewSessionWithOptions(session.Options{
+		Config: *request.WithRetryer(
 			config,
 			awsclient.DefaultRetryer{NumMaxRetries: awsclient.DefaultRetryerMaxNumRetries},
 		),
-	)))
+		CredentialsProviderOptions: credentialsProviderOptions(),
+	}))))))
+	if path := settings.FromContext(ctx).APIRecordFile; path != "" {
+		if err := apirecord.Record(ctx, sess, path); err != nil {
+			logging.FromContext(ctx).Fatalf("Recording AWS API calls, %s", err)
+		}
+	}
+	if path := settings.FromContext(ctx).APIReplayFile; path != "" {
+		logging.FromContext(ctx).With("path", path).Warnf("replaying AWS API calls from recording")
+		if err := apirecord.Replay(sess, path); err != nil {
+			logging.FromContext(ctx).Fatalf("Replaying AWS API calls, %s", err)
+		}
+	}
 
 	if *sess.Config.Region == "" {
 		logging.FromContext(ctx).Debug("retrieving region from IMDS")
@@ -107,6 +149,13 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 	} else {
 		logging.FromContext(ctx).With("cluster-endpoint", clusterEndpoint).Debugf("discovered cluster endpoint")
 	}
+	// The cluster CIDR is only needed to bootstrap AL2023 nodes, so it's resolved best-effort
+	clusterCIDR, err := ResolveClusterCIDR(ctx, eks.New(sess))
+	if err != nil {
+		logging.FromContext(ctx).Debugf("unable to detect the cluster CIDR, %s", err)
+	} else {
+		logging.FromContext(ctx).With("cluster-cidr", clusterCIDR).Debugf("discovered cluster CIDR")
+	}
 	// We perform best-effort on resolving the kube-dns IP
 	kubeDNSIP, err := kubeDNSIP(ctx, operator.KubernetesInterface)
 	if err != nil {
@@ -118,16 +167,23 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 	}
 
 	unavailableOfferingsCache := awscache.NewUnavailableOfferings()
+	readOnlyCache := awscache.NewReadOnly()
+	spotInterruptionsCache := awscache.NewSpotInterruptions()
+	spotLaunchFailuresCache := awscache.NewSpotLaunchFailures(operator.Clock)
+	warmUp := awscache.NewWarmUp()
+	bootstrapFailuresCache := awscache.NewBootstrapFailures()
 	subnetProvider := subnet.NewProvider(ec2api, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
 	securityGroupProvider := securitygroup.NewProvider(ec2api, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+	capacityReservationProvider := capacityreservation.NewProvider(ec2api, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
 	pricingProvider := pricing.NewProvider(
 		ctx,
 		pricing.NewAPI(sess, *sess.Config.Region),
 		ec2api,
 		*sess.Config.Region,
 	)
-	amiProvider := amifamily.NewProvider(operator.GetClient(), operator.KubernetesInterface, ssm.New(sess), ec2api,
-		cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval), cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+	computeOptimizerProvider := computeoptimizer.NewProvider(awscomputeoptimizer.New(sess))
+	amiProvider := amifamily.NewProvider(ctx, operator.Clock, *sess.Config.Region, operator.GetClient(), operator.KubernetesInterface, ssm.New(sess), imagebuilder.New(sess), ec2api,
+		amifamily.AssumeRole(sess), cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval), cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
 	amiResolver := amifamily.New(amiProvider)
 	launchTemplateProvider := launchtemplate.NewProvider(
 		ctx,
@@ -140,7 +196,9 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		operator.Elected(),
 		kubeDNSIP,
 		clusterEndpoint,
+		clusterCIDR,
 	)
+	spotPlacementScoreProvider := spotplacementscore.NewProvider(ec2api, aws.StringValue(sess.Config.Region))
 	instanceTypeProvider := instancetype.NewProvider(
 		*sess.Config.Region,
 		cache.New(awscache.InstanceTypesAndZonesTTL, awscache.DefaultCleanupInterval),
@@ -148,30 +206,49 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		subnetProvider,
 		unavailableOfferingsCache,
 		pricingProvider,
+		computeOptimizerProvider,
+		capacityReservationProvider,
+		bootstrapFailuresCache,
+		spotPlacementScoreProvider,
+		vpccni.NewProvider(operator.KubernetesInterface, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)),
 	)
 	instanceProvider := instance.NewProvider(
 		ctx,
 		aws.StringValue(sess.Config.Region),
 		ec2api,
 		unavailableOfferingsCache,
+		readOnlyCache,
+		spotInterruptionsCache,
+		spotLaunchFailuresCache,
+		bootstrapFailuresCache,
 		instanceTypeProvider,
 		subnetProvider,
 		launchTemplateProvider,
+		amiProvider,
+		capacityReservationProvider,
 	)
 
 	return ctx, &Operator{
-		Operator:                  operator,
-		Session:                   sess,
-		UnavailableOfferingsCache: unavailableOfferingsCache,
-		EC2API:                    ec2api,
-		SubnetProvider:            subnetProvider,
-		SecurityGroupProvider:     securityGroupProvider,
-		AMIProvider:               amiProvider,
-		AMIResolver:               amiResolver,
-		LaunchTemplateProvider:    launchTemplateProvider,
-		PricingProvider:           pricingProvider,
-		InstanceTypesProvider:     instanceTypeProvider,
-		InstanceProvider:          instanceProvider,
+		Operator:                    operator,
+		Session:                     sess,
+		UnavailableOfferingsCache:   unavailableOfferingsCache,
+		ReadOnlyCache:               readOnlyCache,
+		SpotInterruptionsCache:      spotInterruptionsCache,
+		WarmUp:                      warmUp,
+		BootstrapFailuresCache:      bootstrapFailuresCache,
+		EC2API:                      ec2api,
+		SubnetProvider:              subnetProvider,
+		CapacityReservationProvider: capacityReservationProvider,
+		SecurityGroupProvider:       securityGroupProvider,
+		AMIProvider:                 amiProvider,
+		AMIResolver:                 amiResolver,
+		LaunchTemplateProvider:      launchTemplateProvider,
+		PricingProvider:             pricingProvider,
+		InstanceTypesProvider:       instanceTypeProvider,
+		InstanceProvider:            instanceProvider,
+		ComputeOptimizerProvider:    computeOptimizerProvider,
+		SpotPlacementScoreProvider:  spotPlacementScoreProvider,
+		TagPolicyProvider:           tagpolicy.NewProvider(organizations.New(sess), cache.New(awscache.TagPolicyTTL, awscache.DefaultCleanupInterval)),
 	}
 }
 
@@ -182,6 +259,101 @@ func withUserAgent(sess *session.Session) *session.Session {
 	return sess
 }
 
+// credentialsProviderOptions configures web identity (IRSA) credentials to be refreshed ahead of their expiration.
+// Refreshing early means that a rotation of the projected service account token, or clock skew between the node and
+// STS, doesn't cause requests to be signed with credentials that AWS already considers expired.
+func credentialsProviderOptions() *session.CredentialsProviderOptions {
+	return &session.CredentialsProviderOptions{
+		WebIdentityRoleProviderOptions: func(provider *stscreds.WebIdentityRoleProvider) {
+			provider.ExpiryWindow = webIdentityExpiryWindow
+		},
+	}
+}
+
+// WithCredentialsRefreshMetric records the time at which the credentials used by the session are refreshed after each
+// AWS request. Credentials providers report that they expire ahead of their actual expiration by their expiry window
+// (e.g. webIdentityExpiryWindow), so this is when they are refreshed rather than when AWS stops accepting them.
+func WithCredentialsRefreshMetric(sess *session.Session) *session.Session {
+	sess.Handlers.Complete.PushBack(func(r *request.Request) {
+		if r.Config.Credentials == nil {
+			return
+		}
+		// Providers that don't expire (e.g. static credentials) return an error here
+		if refreshAt, err := r.Config.Credentials.ExpiresAt(); err == nil {
+			CredentialsRefreshTime.Set(float64(refreshAt.Unix()))
+		}
+	})
+	return sess
+}
+
+// apiSpanKey is the context key of the span that traces an AWS API call
+type apiSpanKey struct{}
+
+// WithTracing traces every AWS API call made by clients created from the session as a span named after its service
+// and operation (e.g. ec2.CreateFleet), in the trace of the request's context. Send handlers run for every attempt, so
+// the span is only started by the first one and covers the retries of the call.
+func WithTracing(sess *session.Session) *session.Session {
+	sess.Handlers.Send.PushFront(func(r *request.Request) {
+		if _, ok := r.Context().Value(apiSpanKey{}).(trace.Span); ok {
+			return
+		}
+		labels := apiLabels(r)
+		ctx, span := tracing.Start(r.Context(), fmt.Sprintf("%s.%s", labels[serviceLabel], labels[operationLabel]),
+			attribute.String("aws.service", labels[serviceLabel]),
+			attribute.String("aws.operation", labels[operationLabel]),
+		)
+		r.SetContext(context.WithValue(ctx, apiSpanKey{}, span))
+	})
+	sess.Handlers.Complete.PushBack(func(r *request.Request) {
+		span, ok := r.Context().Value(apiSpanKey{}).(trace.Span)
+		if !ok {
+			return
+		}
+		span.SetAttributes(attribute.String("aws.request-id", r.RequestID), attribute.Int("aws.retry-count", r.RetryCount))
+		tracing.End(span, r.Error)
+	})
+	return sess
+}
+
+// withAPIMetrics records the count, latency, and errors of every AWS API call made by clients created from the session
+func withAPIMetrics(sess *session.Session) *session.Session {
+	// Retry handlers run after every failed attempt, so throttling is counted even when a later retry succeeds
+	sess.Handlers.Retry.PushBack(func(r *request.Request) {
+		if r.IsErrorThrottle() {
+			APIThrottles.With(apiLabels(r)).Inc()
+		}
+	})
+	sess.Handlers.Complete.PushBack(func(r *request.Request) {
+		labels := apiLabels(r)
+		APIRequestDuration.With(labels).Observe(time.Since(r.Time).Seconds())
+		errorCode := ""
+		if r.Error != nil {
+			errorCode = "Unknown"
+			var aerr awserr.Error
+			if errors.As(r.Error, &aerr) {
+				errorCode = aerr.Code()
+			}
+		}
+		APIRequests.With(lo.Assign(labels, prometheus.Labels{errorCodeLabel: errorCode})).Inc()
+		// Batched calls are counted once for each nodepool and nodeclass that they're made on behalf of
+		for _, a := range attribution.AllFromContext(r.Context()) {
+			AttributedAPIRequests.With(lo.Assign(apiLabels(r), prometheus.Labels{metrics.NodePoolLabel: a.NodePool, nodeClassLabel: a.NodeClass})).Inc()
+		}
+	})
+	return sess
+}
+
+func apiLabels(r *request.Request) prometheus.Labels {
+	operation := ""
+	if r.Operation != nil {
+		operation = r.Operation.Name
+	}
+	return prometheus.Labels{
+		serviceLabel:   r.ClientInfo.ServiceName,
+		operationLabel: operation,
+	}
+}
+
 // checkEC2Connectivity makes a dry-run call to DescribeInstanceTypes.  If it fails, we provide an early indicator that we
 // are having issues connecting to the EC2 API.
 func checkEC2Connectivity(ctx context.Context, api *ec2.EC2) error {
@@ -207,6 +379,26 @@ func ResolveClusterEndpoint(ctx context.Context, eksAPI eksiface.EKSAPI) (string
 	return *out.Cluster.Endpoint, nil
 }
 
+// ResolveClusterCIDR returns the service CIDR of the cluster, which is its IPv6 CIDR for IPv6 clusters
+func ResolveClusterCIDR(ctx context.Context, eksAPI eksiface.EKSAPI) (*string, error) {
+	out, err := eksAPI.DescribeCluster(&eks.DescribeClusterInput{
+		Name: aws.String(settings.FromContext(ctx).ClusterName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve cluster CIDR, %w", err)
+	}
+	if out.Cluster.KubernetesNetworkConfig == nil {
+		return nil, fmt.Errorf("failed to resolve cluster CIDR, cluster has no kubernetes network config")
+	}
+	if cidr := aws.StringValue(out.Cluster.KubernetesNetworkConfig.ServiceIpv6Cidr); cidr != "" {
+		return aws.String(cidr), nil
+	}
+	if cidr := aws.StringValue(out.Cluster.KubernetesNetworkConfig.ServiceIpv4Cidr); cidr != "" {
+		return aws.String(cidr), nil
+	}
+	return nil, fmt.Errorf("failed to resolve cluster CIDR, cluster has no service CIDR")
+}
+
 func getCABundle(ctx context.Context, restConfig *rest.Config) (*string, error) {
 	// Discover CA Bundle from the REST client. We could alternatively
 	// have used the simpler client-go InClusterConfig() method.
@@ -17,12 +17,24 @@ package operator_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/eks"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	. "knative.dev/pkg/logging/testing"
 
 	"github.com/aws/karpenter/pkg/apis"
@@ -30,6 +42,7 @@ import (
 	"github.com/aws/karpenter/pkg/fake"
 	awscontext "github.com/aws/karpenter/pkg/operator"
 	"github.com/aws/karpenter/pkg/test"
+	"github.com/aws/karpenter/pkg/utils/tracing"
 
 	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
 	"github.com/aws/karpenter-core/pkg/operator/scheme"
@@ -107,4 +120,152 @@ var _ = Describe("Operator", func() {
 		_, err := awscontext.ResolveClusterEndpoint(ctx, fakeEKSAPI)
 		Expect(err).To(HaveOccurred())
 	})
+	It("should resolve the IPv4 service CIDR of the cluster", func() {
+		fakeEKSAPI.DescribeClusterBehaviour.Output.Set(
+			&eks.DescribeClusterOutput{
+				Cluster: &eks.Cluster{
+					KubernetesNetworkConfig: &eks.KubernetesNetworkConfigResponse{ServiceIpv4Cidr: lo.ToPtr("10.100.0.0/16")},
+				},
+			},
+		)
+		cidr, err := awscontext.ResolveClusterCIDR(ctx, fakeEKSAPI)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cidr).To(Equal(lo.ToPtr("10.100.0.0/16")))
+	})
+	It("should resolve the IPv6 service CIDR of IPv6 clusters", func() {
+		fakeEKSAPI.DescribeClusterBehaviour.Output.Set(
+			&eks.DescribeClusterOutput{
+				Cluster: &eks.Cluster{
+					KubernetesNetworkConfig: &eks.KubernetesNetworkConfigResponse{
+						IpFamily:        lo.ToPtr(eks.IpFamilyIpv6),
+						ServiceIpv6Cidr: lo.ToPtr("fd30:1c53:5f8a::/108"),
+					},
+				},
+			},
+		)
+		cidr, err := awscontext.ResolveClusterCIDR(ctx, fakeEKSAPI)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cidr).To(Equal(lo.ToPtr("fd30:1c53:5f8a::/108")))
+	})
+	It("should fail to resolve the cluster CIDR when the cluster has none", func() {
+		fakeEKSAPI.DescribeClusterBehaviour.Output.Set(&eks.DescribeClusterOutput{Cluster: &eks.Cluster{}})
+		_, err := awscontext.ResolveClusterCIDR(ctx, fakeEKSAPI)
+		Expect(err).To(HaveOccurred())
+	})
+	Context("Tracing", func() {
+		var recorder *tracetest.SpanRecorder
+		var status int
+		var ec2api *ec2.EC2
+
+		BeforeEach(func() {
+			recorder = tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			status = http.StatusOK
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(status)
+				if status != http.StatusOK {
+					fmt.Fprint(w, `<Response><Errors><Error><Code>UnauthorizedOperation</Code><Message>denied</Message></Error></Errors><RequestID>test-request</RequestID></Response>`)
+					return
+				}
+				fmt.Fprint(w, `<DescribeInstancesResponse><requestId>test-request</requestId></DescribeInstancesResponse>`)
+			}))
+			DeferCleanup(server.Close)
+			ec2api = ec2.New(awscontext.WithTracing(session.Must(session.NewSession(&aws.Config{
+				Region:      aws.String("us-west-2"),
+				Endpoint:    aws.String(server.URL),
+				Credentials: credentials.AnonymousCredentials,
+				MaxRetries:  aws.Int(0),
+			}))))
+		})
+		AfterEach(func() {
+			otel.SetTracerProvider(sdktrace.NewTracerProvider())
+		})
+
+		It("should trace AWS API calls in the trace of the request's context", func() {
+			parentCtx, parent := tracing.Start(ctx, "instance.Create")
+			_, err := ec2api.DescribeInstancesWithContext(parentCtx, &ec2.DescribeInstancesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			parent.End()
+
+			spans := lo.Filter(recorder.Ended(), func(s sdktrace.ReadOnlySpan, _ int) bool { return s.Name() == "ec2.DescribeInstances" })
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+			Expect(spans[0].SpanContext().TraceID()).To(Equal(parent.SpanContext().TraceID()))
+			Expect(spans[0].Status().Code).ToNot(Equal(codes.Error))
+		})
+		It("should record the error of failed AWS API calls on their span", func() {
+			status = http.StatusBadRequest
+			_, err := ec2api.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{})
+			Expect(err).To(HaveOccurred())
+
+			spans := lo.Filter(recorder.Ended(), func(s sdktrace.ReadOnlySpan, _ int) bool { return s.Name() == "ec2.DescribeInstances" })
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Status().Code).To(Equal(codes.Error))
+			Expect(spans[0].Status().Description).To(ContainSubstring("UnauthorizedOperation"))
+		})
+	})
+	Context("Credentials Refresh Metric", func() {
+		var endpoint string
+
+		BeforeEach(func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, `<DescribeInstancesResponse><requestId>test-request</requestId></DescribeInstancesResponse>`)
+			}))
+			DeferCleanup(server.Close)
+			endpoint = server.URL
+		})
+		newEC2API := func(creds *credentials.Credentials) *ec2.EC2 {
+			return ec2.New(awscontext.WithCredentialsRefreshMetric(session.Must(session.NewSession(&aws.Config{
+				Region:      aws.String("us-west-2"),
+				Endpoint:    aws.String(endpoint),
+				Credentials: creds,
+				MaxRetries:  aws.Int(0),
+			}))))
+		}
+		refreshTime := func() float64 {
+			metric, found := FindMetricWithLabelValues("karpenter_cloudprovider_credentials_refresh_time_seconds", map[string]string{})
+			Expect(found).To(BeTrue())
+			return metric.GetGauge().GetValue()
+		}
+
+		It("should record when credentials are refreshed, ahead of their expiration by the expiry window", func() {
+			provider := &expiringProvider{expiration: time.Now().Add(time.Hour), window: 5 * time.Minute}
+			_, err := newEC2API(credentials.NewCredentials(provider)).DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(refreshTime()).To(BeNumerically("==", provider.expiration.Add(-5*time.Minute).Unix()))
+		})
+		It("should refresh credentials that expire within the expiry window", func() {
+			provider := &expiringProvider{expiration: time.Now().Add(3 * time.Minute), window: 5 * time.Minute}
+			ec2api := newEC2API(credentials.NewCredentials(provider))
+			_, err := ec2api.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = ec2api.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(provider.retrievals).To(Equal(2))
+			Expect(refreshTime()).To(BeNumerically("<", time.Now().Unix()))
+		})
+		It("should not record credentials that don't expire", func() {
+			provider := &expiringProvider{expiration: time.Now().Add(time.Hour), window: 5 * time.Minute}
+			_, err := newEC2API(credentials.NewCredentials(provider)).DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = newEC2API(credentials.NewStaticCredentials("AKID", "SECRET", "")).DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(refreshTime()).To(BeNumerically("==", provider.expiration.Add(-5*time.Minute).Unix()))
+		})
+	})
 })
+
+// expiringProvider returns credentials that expire at the expiration, and reports them as expired from the start of
+// the expiry window like the AWS SDK's providers
+type expiringProvider struct {
+	credentials.Expiry
+	expiration time.Time
+	window     time.Duration
+	retrievals int
+}
+
+func (p *expiringProvider) Retrieve() (credentials.Value, error) {
+	p.retrievals++
+	p.SetExpiration(p.expiration, p.window)
+	return credentials.Value{AccessKeyID: "AKID", SecretAccessKey: "SECRET", ProviderName: "expiring"}, nil
+}
@@ -33,10 +33,33 @@ import (
 type AL2 struct {
 	DefaultFamily
 	*Options
+	// Variant selects a variant of the EKS optimized AMIs, e.g. fips resolves the FIPS-enabled AMIs
+	Variant string
 }
 
 // DefaultAMIs returns the AMI name, and Requirements, with an SSM query
 func (a AL2) DefaultAMIs(version string) []DefaultAMIOutput {
+	if a.Variant == v1beta1.AMIVariantFIPS {
+		// FIPS-enabled AMIs aren't built for accelerated instance types, which are left without a default AMI
+		return []DefaultAMIOutput{
+			{
+				Query: fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-fips/recommended/image_id", version),
+				Requirements: scheduling.NewRequirements(
+					scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureAmd64),
+					scheduling.NewRequirement(v1alpha1.LabelInstanceGPUCount, v1.NodeSelectorOpDoesNotExist),
+					scheduling.NewRequirement(v1alpha1.LabelInstanceAcceleratorCount, v1.NodeSelectorOpDoesNotExist),
+				),
+			},
+			{
+				Query: fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-%s-fips/recommended/image_id", version, v1alpha5.ArchitectureArm64),
+				Requirements: scheduling.NewRequirements(
+					scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureArm64),
+					scheduling.NewRequirement(v1alpha1.LabelInstanceGPUCount, v1.NodeSelectorOpDoesNotExist),
+					scheduling.NewRequirement(v1alpha1.LabelInstanceAcceleratorCount, v1.NodeSelectorOpDoesNotExist),
+				),
+			},
+		}
+	}
 	return []DefaultAMIOutput{
 		{
 			Query: fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version),
@@ -91,6 +114,8 @@ func (a AL2) UserData(kubeletConfig *corev1beta1.KubeletConfiguration, taints []
 			Labels:                  labels,
 			CABundle:                caBundle,
 			CustomUserData:          customUserData,
+			CloudWatchAgent:         a.Options.CloudWatchAgent,
+			Neuron:                  a.Options.Neuron,
 		},
 	}
 }
@@ -16,28 +16,46 @@ package amifamily
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/imagebuilder"
+	"github.com/aws/aws-sdk-go/service/imagebuilder/imagebuilderiface"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/clock"
 	"knative.dev/pkg/logging"
 
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/batcher"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/scheduling"
@@ -46,36 +64,86 @@ import (
 )
 
 type Provider struct {
+	ctx                    context.Context
+	clk                    clock.Clock
+	region                 string
+	partition              string
 	cache                  *cache.Cache
 	kubernetesVersionCache *cache.Cache
 	ssm                    ssmiface.SSMAPI
+	imagebuilder           imagebuilderiface.ImagebuilderAPI
+	ssmFailures            *ssmFailures
 	kubeClient             client.Client
 	ec2api                 ec2iface.EC2API
 	cm                     *pretty.ChangeMonitor
 	kubernetesInterface    kubernetes.Interface
+
+	// The batchers that describe images, as the cluster's account and as each of the roles that selector terms set,
+	// keyed by the role's arn
+	mu            sync.Mutex
+	ec2APIForRole EC2APIForRole
+	describers    map[string]*batcher.DescribeImagesBatcher
+	// The resolved selector terms that the cached AMIs of NodeClasses with selector terms were resolved from, keyed by
+	// their cache key, which are also guarded by mu
+	selectorTerms map[string][]v1beta1.AMISelectorTerm
+	// Whether images were registered with the UEFI variables that enable Secure Boot, keyed by their id, which is also
+	// guarded by mu. The UEFI data of an image can't be changed, so it's kept for as long as the controller runs.
+	uefiData map[string]bool
+}
+
+// EC2APIForRole returns an EC2 API whose calls are made with the credentials of the IAM role
+type EC2APIForRole func(roleARN string) ec2iface.EC2API
+
+// AssumeRole returns EC2 APIs whose calls are made as the IAM role, which is assumed with the session's credentials
+func AssumeRole(sess *session.Session) EC2APIForRole {
+	return func(roleARN string) ec2iface.EC2API {
+		return ec2.New(sess, &aws.Config{Credentials: stscreds.NewCredentials(sess, roleARN)})
+	}
 }
 
 type AMI struct {
-	Name         string
-	AmiID        string
-	CreationDate string
-	Requirements scheduling.Requirements
+	Name                string
+	AmiID               string
+	CreationDate        string
+	DeprecationTime     string
+	Requirements        scheduling.Requirements
+	BlockDeviceMappings []*ec2.BlockDeviceMapping
+	RootDeviceName      string
+	// selectorTerm is the position of the first selector term that selected the AMI, which orders the AMIs under the
+	// pinned sort strategy
+	selectorTerm int
+	// Candidate is whether the AMI is a candidate of the NodeClass' AMI rollout, in which case it's only launched for
+	// CandidateWeight percent of the launches
+	Candidate       bool
+	CandidateWeight int32
+	// Zones are the zones that the AMI can be launched in, if it can't be launched in every zone of the region. This is
+	// the case for AMIs whose EBS snapshots are stored on an Outpost, which can only be launched on that Outpost.
+	Zones []string
+	// OutpostARN is the ARN of the Outpost that the AMI's EBS snapshots are stored on, whose subnets it must be
+	// launched into, since the zones of an Outpost's subnets also have regional subnets
+	OutpostARN string
 }
 
 type AMIs []AMI
 
 // Sort orders the AMIs by creation date in descending order.
-// If creation date is nil or two AMIs have the same creation date, the AMIs will be sorted by name in ascending order.
+// If creation date is nil or two AMIs have the same creation date, the AMIs will be sorted by name in descending order.
 func (a AMIs) Sort() {
-	sort.Slice(a, func(i, j int) bool {
-		if a[i].CreationDate != "" || a[j].CreationDate != "" {
-			itime, _ := time.Parse(time.RFC3339, a[i].CreationDate)
-			jtime, _ := time.Parse(time.RFC3339, a[j].CreationDate)
-			if itime.Unix() != jtime.Unix() {
-				return itime.Unix() >= jtime.Unix()
-			}
-		}
-		return a[i].Name >= a[j].Name
+	a.SortBy(v1beta1.AMISortStrategyNewest)
+}
+
+// SortBy orders the AMIs by preference under the sort strategy, see AMI.Before
+func (a AMIs) SortBy(strategy string) {
+	sort.SliceStable(a, func(i, j int) bool {
+		return a[i].Before(a[j], strategy)
+	})
+}
+
+// DeprioritizeDeprecated moves the AMIs that are deprecated at the passed time after the ones that aren't, preserving
+// the order of the AMIs otherwise.
+func (a AMIs) DeprioritizeDeprecated(now time.Time) {
+	sort.SliceStable(a, func(i, j int) bool {
+		return !a[i].Deprecated(now) && a[j].Deprecated(now)
 	})
 }
 
@@ -91,39 +159,236 @@ func (a AMIs) String() string {
 	return sb.String()
 }
 
-// MapToInstanceTypes returns a map of AMIIDs that are the most recent on creationDate to compatible instancetypes
+// MapToInstanceTypes returns a map of AMIIDs that are the most recent on creationDate to compatible instancetypes.
+// Instance types are only compatible with AMIs whose boot mode, virtualization type and root device type they support.
+// When the AMIs include the candidates of a rollout, the candidates are preferred for the rollout's share of the calls,
+// so that launches are distributed between the current and the candidate AMIs by the weight of the rollout.
 func (a AMIs) MapToInstanceTypes(instanceTypes []*cloudprovider.InstanceType) map[string][]*cloudprovider.InstanceType {
+	current, candidates := a.split()
+	if len(candidates) > 0 && rand.Int31n(100) < candidates[0].CandidateWeight { //nolint:gosec
+		return append(candidates, current...).mapToInstanceTypes(instanceTypes)
+	}
+	return current.mapToInstanceTypes(instanceTypes)
+}
+
+// LaunchableIDs returns the ids of the AMIs that instances of the instance type may be launched with, which are both
+// the current and the candidate AMIs while a rollout is in progress
+func (a AMIs) LaunchableIDs(instanceType *cloudprovider.InstanceType) []string {
+	current, candidates := a.split()
+	var ids []string
+	if len(candidates) == 0 || candidates[0].CandidateWeight < 100 {
+		ids = append(ids, lo.Keys(current.mapToInstanceTypes([]*cloudprovider.InstanceType{instanceType}))...)
+	}
+	if len(candidates) > 0 && candidates[0].CandidateWeight > 0 {
+		ids = append(ids, lo.Keys(append(candidates, current...).mapToInstanceTypes([]*cloudprovider.InstanceType{instanceType}))...)
+	}
+	return lo.Uniq(ids)
+}
+
+func (a AMIs) split() (current, candidates AMIs) {
+	for _, ami := range a {
+		if ami.Candidate {
+			candidates = append(candidates, ami)
+		} else {
+			current = append(current, ami)
+		}
+	}
+	return current, candidates
+}
+
+// mapToInstanceTypes maps each instance type to the first AMI that it's compatible with. An AMI that can only be
+// launched in some zones is only mapped to the offerings of the instance type in those zones, and the offerings in the
+// other zones are mapped to the next compatible AMI.
+func (a AMIs) mapToInstanceTypes(instanceTypes []*cloudprovider.InstanceType) map[string][]*cloudprovider.InstanceType {
 	amiIDs := map[string][]*cloudprovider.InstanceType{}
 	for _, instanceType := range instanceTypes {
+		zones := sets.New(lo.Map(instanceType.Offerings, func(o cloudprovider.Offering, _ int) string { return o.Zone })...)
 		for _, ami := range a {
-			if err := instanceType.Requirements.Compatible(ami.Requirements); err == nil {
-				amiIDs[ami.AmiID] = append(amiIDs[ami.AmiID], instanceType)
+			if err := instanceType.Requirements.Compatible(ami.Requirements); err != nil {
+				continue
+			}
+			if len(ami.Zones) == 0 {
+				amiIDs[ami.AmiID] = append(amiIDs[ami.AmiID], withZones(instanceType, zones))
 				break
 			}
+			if covered := zones.Intersection(sets.New(ami.Zones...)); covered.Len() > 0 {
+				amiIDs[ami.AmiID] = append(amiIDs[ami.AmiID], withZones(instanceType, covered))
+				if zones = zones.Difference(covered); zones.Len() == 0 {
+					break
+				}
+			}
 		}
 	}
 	return amiIDs
 }
 
+// withZones returns the instance type with only its offerings in the zones
+func withZones(instanceType *cloudprovider.InstanceType, zones sets.Set[string]) *cloudprovider.InstanceType {
+	if lo.EveryBy(instanceType.Offerings, func(o cloudprovider.Offering) bool { return zones.Has(o.Zone) }) {
+		return instanceType
+	}
+	return &cloudprovider.InstanceType{
+		Name:         instanceType.Name,
+		Requirements: instanceType.Requirements,
+		Offerings:    lo.Filter(instanceType.Offerings, func(o cloudprovider.Offering, _ int) bool { return zones.Has(o.Zone) }),
+		Capacity:     instanceType.Capacity,
+		Overhead:     instanceType.Overhead,
+	}
+}
+
+// Before returns whether the AMI is preferred over the other under the sort strategy. The newest strategy prefers the
+// AMI with the later creation date, name-descending the AMI whose name has the higher version, and pinned the AMI
+// selected by the earlier selector term. AMIs that the strategy doesn't order are ordered by creation date, and then by
+// name, in descending order.
+func (a AMI) Before(other AMI, strategy string) bool {
+	switch strategy {
+	case v1beta1.AMISortStrategyNameDescending:
+		if c := compareVersions(a.Name, other.Name); c != 0 {
+			return c > 0
+		}
+	case v1beta1.AMISortStrategyPinned:
+		if a.selectorTerm != other.selectorTerm {
+			return a.selectorTerm < other.selectorTerm
+		}
+	}
+	creationTime, _ := time.Parse(time.RFC3339, a.CreationDate)
+	otherCreationTime, _ := time.Parse(time.RFC3339, other.CreationDate)
+	if creationTime.Unix() != otherCreationTime.Unix() {
+		return creationTime.Unix() > otherCreationTime.Unix()
+	}
+	return a.Name > other.Name
+}
+
+// compareVersions compares the names by the versions in them. Runs of digits are compared numerically and the text
+// between them lexically, so that my-ami-1.10.0 is ordered after my-ami-1.9.0.
+func compareVersions(a, b string) int {
+	for a != "" && b != "" {
+		aChunk, bChunk := versionChunk(a), versionChunk(b)
+		if isDigit(aChunk[0]) && isDigit(bChunk[0]) {
+			aNumber, bNumber := strings.TrimLeft(aChunk, "0"), strings.TrimLeft(bChunk, "0")
+			if len(aNumber) != len(bNumber) {
+				return lo.Ternary(len(aNumber) > len(bNumber), 1, -1)
+			}
+			if c := strings.Compare(aNumber, bNumber); c != 0 {
+				return c
+			}
+		} else if c := strings.Compare(aChunk, bChunk); c != 0 {
+			return c
+		}
+		a, b = a[len(aChunk):], b[len(bChunk):]
+	}
+	return strings.Compare(a, b)
+}
+
+// versionChunk returns the leading run of digits, or of other characters, of the non-empty name
+func versionChunk(name string) string {
+	i := 1
+	for i < len(name) && isDigit(name[i]) == isDigit(name[0]) {
+		i++
+	}
+	return name[:i]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Deprecated returns whether the AMI is deprecated at the passed time
+func (a AMI) Deprecated(now time.Time) bool {
+	if a.DeprecationTime == "" {
+		return false
+	}
+	deprecationTime, err := time.Parse(time.RFC3339, a.DeprecationTime)
+	return err == nil && !deprecationTime.After(now)
+}
+
+// UnencryptedVolumes returns the device names of the EBS volumes that an instance launched from the AMI with the passed
+// block device mappings would attach without encryption. EBS volumes that the AMI maps and that the block device
+// mappings don't override inherit the encryption of the AMI's snapshot.
+func (a AMI) UnencryptedVolumes(blockDeviceMappings []*v1beta1.BlockDeviceMapping) []string {
+	var devices []string
+	for _, blockDeviceMapping := range blockDeviceMappings {
+		if blockDeviceMapping.EBS == nil || !lo.FromPtr(blockDeviceMapping.EBS.Encrypted) {
+			devices = append(devices, lo.FromPtr(blockDeviceMapping.DeviceName))
+		}
+	}
+	for _, blockDeviceMapping := range a.BlockDeviceMappings {
+		if blockDeviceMapping.Ebs == nil || blockDeviceMapping.NoDevice != nil {
+			continue
+		}
+		if _, ok := lo.Find(blockDeviceMappings, func(b *v1beta1.BlockDeviceMapping) bool {
+			return lo.FromPtr(b.DeviceName) == lo.FromPtr(blockDeviceMapping.DeviceName)
+		}); ok {
+			continue
+		}
+		if !lo.FromPtr(blockDeviceMapping.Ebs.Encrypted) {
+			devices = append(devices, lo.FromPtr(blockDeviceMapping.DeviceName))
+		}
+	}
+	return devices
+}
+
 const (
 	kubernetesVersionCacheKey = "kubernetesVersion"
+	// publicSSMParameterPrefix prefixes the public parameters that AWS publishes the ids of its AMIs to
+	publicSSMParameterPrefix = "/aws/service/"
 )
 
-func NewProvider(kubeClient client.Client, kubernetesInterface kubernetes.Interface, ssm ssmiface.SSMAPI, ec2api ec2iface.EC2API,
-	cache, kubernetesVersionCache *cache.Cache) *Provider {
+// fipsPartitions are the partitions that FIPS-enabled AMIs are published in
+var fipsPartitions = sets.New(endpoints.AwsPartitionID, endpoints.AwsUsGovPartitionID)
+
+func NewProvider(ctx context.Context, clk clock.Clock, region string, kubeClient client.Client, kubernetesInterface kubernetes.Interface, ssm ssmiface.SSMAPI, imagebuilder imagebuilderiface.ImagebuilderAPI,
+	ec2api ec2iface.EC2API, ec2APIForRole EC2APIForRole, cache, kubernetesVersionCache *cache.Cache) *Provider {
 	return &Provider{
+		ctx:                    ctx,
+		clk:                    clk,
+		region:                 region,
+		partition:              partition(region),
 		cache:                  cache,
 		kubernetesVersionCache: kubernetesVersionCache,
 		ssm:                    ssm,
+		imagebuilder:           imagebuilder,
+		ssmFailures:            newSSMFailures(),
 		kubeClient:             kubeClient,
 		ec2api:                 ec2api,
 		cm:                     pretty.NewChangeMonitor(),
 		kubernetesInterface:    kubernetesInterface,
+		ec2APIForRole:          ec2APIForRole,
+		describers:             map[string]*batcher.DescribeImagesBatcher{},
+		selectorTerms:          map[string][]v1beta1.AMISelectorTerm{},
+		uefiData:               map[string]bool{},
+	}
+}
+
+// describeImages describes images as the role, or as the cluster's account if no role is set. The calls of every
+// NodeClass are batched, so that NodeClasses that share selectors describe their images once. The batcher of each
+// role is reused, so that its credentials are only refreshed once they expire.
+func (p *Provider) describeImages(ctx context.Context, roleARN string, input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	return p.describer(roleARN).DescribeImages(ctx, input)
+}
+
+func (p *Provider) describer(roleARN string) *batcher.DescribeImagesBatcher {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if describer, ok := p.describers[roleARN]; ok {
+		return describer
+	}
+	p.describers[roleARN] = batcher.NewDescribeImagesBatcher(p.ctx, lo.TernaryF(roleARN == "",
+		func() ec2iface.EC2API { return p.ec2api },
+		func() ec2iface.EC2API { return p.ec2APIForRole(roleARN) }))
+	return p.describers[roleARN]
+}
+
+// partition returns the partition of the region, assuming the aws partition for regions that the SDK doesn't know
+func partition(region string) string {
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		return p.ID()
 	}
+	return endpoints.AwsPartitionID
 }
 
 func (p *Provider) KubeServerVersion(ctx context.Context) (string, error) {
-	if version, ok := p.kubernetesVersionCache.Get(kubernetesVersionCacheKey); ok {
+	if version, ok := awscache.Get(p.kubernetesVersionCache, awscache.KubernetesVersionCacheName, kubernetesVersionCacheKey); ok {
 		return version.(string), nil
 	}
 	serverVersion, err := p.kubernetesInterface.Discovery().ServerVersion()
@@ -131,7 +396,7 @@ func (p *Provider) KubeServerVersion(ctx context.Context) (string, error) {
 		return "", err
 	}
 	version := fmt.Sprintf("%s.%s", serverVersion.Major, strings.TrimSuffix(serverVersion.Minor, "+"))
-	p.kubernetesVersionCache.SetDefault(kubernetesVersionCacheKey, version)
+	awscache.SetDefault(p.kubernetesVersionCache, awscache.KubernetesVersionCacheName, kubernetesVersionCacheKey, version)
 	if p.cm.HasChanged("kubernetes-version", version) {
 		logging.FromContext(ctx).With("version", version).Debugf("discovered kubernetes version")
 	}
@@ -140,6 +405,9 @@ func (p *Provider) KubeServerVersion(ctx context.Context) (string, error) {
 
 // Get Returning a list of AMIs with its associated requirements
 func (p *Provider) Get(ctx context.Context, nodeClass *v1beta1.NodeClass, options *Options) (AMIs, error) {
+	if RollbackRequested(nodeClass) {
+		return p.previousAMIs(ctx, nodeClass)
+	}
 	var err error
 	var amis AMIs
 	if len(nodeClass.Spec.AMISelectorTerms) == 0 {
@@ -148,129 +416,720 @@ func (p *Provider) Get(ctx context.Context, nodeClass *v1beta1.NodeClass, option
 			return nil, err
 		}
 	} else {
-		amis, err = p.getAMIs(ctx, nodeClass.Spec.AMISelectorTerms)
+		amis, err = p.getAMIs(ctx, nodeClass)
 		if err != nil {
 			return nil, err
 		}
 	}
-	amis.Sort()
+	now := p.clk.Now()
+	amis = prioritize(amis, nodeClass, now)
+	// The candidates of a rollout follow the current AMIs, so that they're only preferred when the rollout is chosen
+	if rollout := nodeClass.Spec.AMIRollout; rollout != nil {
+		candidateNodeClass := nodeClass.DeepCopy()
+		candidateNodeClass.Spec.AMISelectorTerms = rollout.AMISelectorTerms
+		candidates, err := p.getAMIs(ctx, candidateNodeClass)
+		if err != nil {
+			return nil, fmt.Errorf("resolving candidate amis, %w", err)
+		}
+		candidates = lo.Map(prioritize(candidates, nodeClass, now), func(a AMI, _ int) AMI {
+			a.Candidate, a.CandidateWeight = true, rollout.Weight
+			return a
+		})
+		amis = lo.Flatten([][]AMI{amis, candidates})
+	}
+	observeSelection(nodeClass, amis, now)
 	if p.cm.HasChanged(fmt.Sprintf("amis/%t/%s", nodeClass.IsNodeTemplate, nodeClass.Name), amis) {
 		logging.FromContext(ctx).With("ids", amis, "count", len(amis)).Debugf("discovered amis")
 	}
+	if deprecated := AMIs(lo.Filter(amis, func(a AMI, _ int) bool { return a.Deprecated(now) })); len(deprecated) > 0 &&
+		p.cm.HasChanged(fmt.Sprintf("amis-deprecated/%t/%s", nodeClass.IsNodeTemplate, nodeClass.Name), deprecated) {
+		logging.FromContext(ctx).With("ids", deprecated, "count", len(deprecated)).Infof("discovered deprecated amis")
+	}
+	return amis, nil
+}
+
+// RollbackRequested returns whether the NodeClass is annotated to launch its previous AMIs
+func RollbackRequested(nodeClass *v1beta1.NodeClass) bool {
+	return nodeClass.Annotations[lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.AnnotationAMIRollback, v1beta1.AnnotationAMIRollback)] == "true"
+}
+
+// previousAMIs returns the AMIs that the NodeClass resolved before its current AMIs, as recorded in its status. They're
+// returned instead of the AMIs that its selectors resolve during a rollback, so nodes launched with the current AMIs
+// are drifted and replaced with nodes launched with the previous AMIs.
+func (p *Provider) previousAMIs(ctx context.Context, nodeClass *v1beta1.NodeClass) (AMIs, error) {
+	if len(nodeClass.Status.PreviousAMIs) == 0 {
+		return nil, fmt.Errorf("rolling back amis, no previous amis have been resolved")
+	}
+	amis := AMIs(lo.Map(nodeClass.Status.PreviousAMIs, func(a v1beta1.AMI, _ int) AMI {
+		ami := AMI{Name: a.Name, AmiID: a.ID, CreationDate: a.CreationDate}
+		// Zones are reported as a requirement of the AMI in the status, see the nodeclass controller
+		requirements := lo.Reject(a.Requirements, func(r v1.NodeSelectorRequirement, _ int) bool {
+			if r.Key == v1.LabelTopologyZone && r.Operator == v1.NodeSelectorOpIn {
+				ami.Zones = r.Values
+				return true
+			}
+			return false
+		})
+		ami.Requirements = scheduling.NewNodeSelectorRequirements(requirements...)
+		return ami
+	}))
+	amis.Sort()
+	if p.cm.HasChanged(fmt.Sprintf("amis/%t/%s", nodeClass.IsNodeTemplate, nodeClass.Name), amis) {
+		logging.FromContext(ctx).With("ids", amis, "count", len(amis)).Infof("rolled back to previous amis")
+	}
 	return amis, nil
 }
 
+// prioritize orders the AMIs by preference under the sort strategy of the NodeClass, and applies its deprecation policy
+// to them
+func prioritize(amis AMIs, nodeClass *v1beta1.NodeClass, now time.Time) AMIs {
+	amis.SortBy(lo.FromPtr(nodeClass.Spec.AMISortStrategy))
+	switch lo.FromPtr(nodeClass.Spec.AMIDeprecationPolicy) {
+	case v1beta1.AMIDeprecationPolicyDeprioritize:
+		amis.DeprioritizeDeprecated(now)
+	case v1beta1.AMIDeprecationPolicyExclude:
+		amis = lo.Filter(amis, func(a AMI, _ int) bool { return !a.Deprecated(now) })
+	}
+	return amis
+}
+
+// CreationDates returns the creation date of each of the images, omitting any images that no longer exist
+func (p *Provider) CreationDates(ctx context.Context, imageIDs []string) (map[string]time.Time, error) {
+	creationDates := map[string]time.Time{}
+	if len(imageIDs) == 0 {
+		return creationDates, nil
+	}
+	start := time.Now()
+	output, err := p.describeImages(ctx, "", &ec2.DescribeImagesInput{
+		Filters:    []*ec2.Filter{{Name: aws.String("image-id"), Values: aws.StringSlice(imageIDs)}},
+		MaxResults: aws.Int64(500),
+	})
+	observeRequest(apiDescribeImages, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("describing images, %w", err)
+	}
+	for _, image := range output.Images {
+		if creationDate, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate)); err == nil {
+			creationDates[aws.StringValue(image.ImageId)] = creationDate
+		}
+	}
+	return creationDates, nil
+}
+
+// Invalidate deletes the AMIs resolved for the NodeClass from the cache, so that they're resolved again the next time
+// they're needed
+func (p *Provider) Invalidate(ctx context.Context, nodeClass *v1beta1.NodeClass) {
+	key, err := cacheKey(ctx, nodeClass)
+	if err != nil {
+		return
+	}
+	logging.FromContext(ctx).Debugf("invalidating amis in the cache because one of them no longer exists")
+	p.cache.Delete(key)
+}
+
+// InvalidateImage deletes the cached AMIs that a change to the image may affect, so that they're resolved again the
+// next time they're needed. These are the AMIs that were resolved along with it, and the AMIs that were resolved from
+// selector terms that it may have started matching, because they select by one of the tag keys that changed, or
+// because the image was created with the name and the terms select by a matching name or only by owner.
+func (p *Provider) InvalidateImage(ctx context.Context, id, name string, tagKeys []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, item := range p.cache.Items() {
+		amis, ok := item.Object.(AMIs)
+		if !ok {
+			continue
+		}
+		if lo.ContainsBy(amis, func(a AMI) bool { return a.AmiID == id }) || lo.ContainsBy(p.selectorTerms[key], func(term v1beta1.AMISelectorTerm) bool {
+			return mayMatch(term, name, tagKeys)
+		}) {
+			logging.FromContext(ctx).With("id", id).Debugf("invalidating amis in the cache because an ami changed")
+			p.cache.Delete(key)
+			delete(p.selectorTerms, key)
+		}
+	}
+}
+
+// mayMatch returns whether an image that was created with the name, or whose tags with the keys changed, may have
+// started matching the selector term
+func mayMatch(term v1beta1.AMISelectorTerm, name string, tagKeys []string) bool {
+	if lo.Some(lo.Keys(term.Tags), tagKeys) {
+		return true
+	}
+	if name == "" {
+		return false
+	}
+	return lo.Ternary(term.Name != "", utils.WildcardMatch(term.Name, name), term.ID == "" && len(term.Tags) == 0)
+}
+
+// InvalidateNodeClass deletes the AMIs resolved for the NodeClass that the key refers to from the cache, or for every
+// NodeClass if the key has no name, so that newly published AMIs are selected without waiting for the cache to expire
+func (p *Provider) InvalidateNodeClass(ctx context.Context, key nodeclassutil.Key) error {
+	if key.Name == "" {
+		logging.FromContext(ctx).Infof("invalidating amis in the cache on request")
+		p.cache.Flush()
+		return nil
+	}
+	nodeClass, err := nodeclassutil.Get(ctx, p.kubeClient, key)
+	if err != nil {
+		return err
+	}
+	k, err := cacheKey(ctx, nodeClass)
+	if err != nil {
+		return fmt.Errorf("computing cache key, %w", err)
+	}
+	logging.FromContext(ctx).With(lo.Ternary(key.IsNodeTemplate, "node-template", "node-class"), key.Name).Infof("invalidating amis in the cache on request")
+	p.cache.Delete(k)
+	return nil
+}
+
+// cacheKey returns the key that the AMIs resolved for the NodeClass are cached with
+func cacheKey(ctx context.Context, nodeClass *v1beta1.NodeClass) (string, error) {
+	key := lo.FromPtr(nodeClass.Spec.AMIFamily)
+	if variant := lo.FromPtr(nodeClass.Spec.AMIVariant); variant != "" {
+		key = fmt.Sprintf("%s/%s", key, variant)
+	}
+	if stream := lo.FromPtr(nodeClass.Spec.UbuntuStream); stream != "" {
+		key = fmt.Sprintf("%s/%s", key, stream)
+	}
+	if len(nodeClass.Spec.AMISelectorTerms) > 0 {
+		// The ssm parameters and image builder arns of the terms are only resolved once the AMIs aren't cached, so they're
+		// hashed alongside the filters
+		ssmParameters := lo.FilterMap(nodeClass.Spec.AMISelectorTerms, func(t v1beta1.AMISelectorTerm, _ int) (string, bool) { return t.SSM, t.SSM != "" })
+		imageBuilderARNs := lo.FilterMap(nodeClass.Spec.AMISelectorTerms, func(t v1beta1.AMISelectorTerm, _ int) (string, bool) {
+			return t.ImageBuilderARN, t.ImageBuilderARN != ""
+		})
+		hash, err := hashstructure.Hash([]interface{}{GetFilterAndOwnerSets(nodeClass.Spec.AMISelectorTerms), ssmParameters, imageBuilderARNs},
+			hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+		if err != nil {
+			return "", err
+		}
+		key = fmt.Sprint(hash)
+		// The deprecation policy changes which image is kept for each set of requirements, so the images selected under
+		// each policy are cached separately
+		if policy := lo.FromPtr(nodeClass.Spec.AMIDeprecationPolicy); policy != "" && policy != v1beta1.AMIDeprecationPolicyInclude {
+			key = fmt.Sprintf("%s/%s", key, policy)
+		}
+		// The sort strategy also changes which image is kept for each set of requirements, and the pinned strategy
+		// depends on the order of the terms, which the hash above ignores
+		switch strategy := lo.FromPtr(nodeClass.Spec.AMISortStrategy); strategy {
+		case v1beta1.AMISortStrategyNameDescending:
+			key = fmt.Sprintf("%s/%s", key, strategy)
+		case v1beta1.AMISortStrategyPinned:
+			hash, err := hashstructure.Hash(nodeClass.Spec.AMISelectorTerms, hashstructure.FormatV2, nil)
+			if err != nil {
+				return "", err
+			}
+			key = fmt.Sprintf("%s/%s-%d", key, strategy, hash)
+		}
+	}
+	// Images that don't support the trusted boot that the NodeClass requires are skipped while the AMIs are resolved, so
+	// the images selected under each requirement are cached separately
+	if trustedBoot := nodeClass.Spec.TrustedBoot; trustedBoot.Required() {
+		key = fmt.Sprintf("%s/trusted-boot-%t-%t", key, trustedBoot.NitroTPM, trustedBoot.SecureBoot)
+	}
+	// Denied images are skipped while the AMIs are resolved, so changes to the denied images take effect without
+	// waiting for the cached AMIs to expire
+	s := settings.FromContext(ctx)
+	if len(s.DeniedAMIIDs) > 0 || len(s.DeniedAMINames) > 0 || len(s.DeniedAMIOwners) > 0 {
+		hash, err := hashstructure.Hash([]interface{}{s.DeniedAMIIDs, s.DeniedAMINames, s.DeniedAMIOwners}, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+		if err != nil {
+			return "", err
+		}
+		key = fmt.Sprintf("%s/denied-%d", key, hash)
+	}
+	return key, nil
+}
+
+// denied returns whether the image is denied by the cluster's settings, in which case it's never selected
+func denied(ctx context.Context, image *ec2.Image) bool {
+	s := settings.FromContext(ctx)
+	if lo.Contains(s.DeniedAMIIDs, aws.StringValue(image.ImageId)) || lo.Contains(s.DeniedAMIOwners, aws.StringValue(image.OwnerId)) {
+		return true
+	}
+	return lo.ContainsBy(s.DeniedAMINames, func(pattern string) bool {
+		return deniedAMINameRegexp(pattern).MatchString(aws.StringValue(image.Name))
+	})
+}
+
+// deniedAMINameRegexps holds the compiled regexp of each denied AMI name pattern, since every image that's resolved is
+// matched against them
+// key: <pattern>, value: *regexp.Regexp
+var deniedAMINameRegexps sync.Map
+
+// deniedAMINameRegexp returns the regexp of a denied AMI name pattern, in which * matches any characters
+func deniedAMINameRegexp(pattern string) *regexp.Regexp {
+	if r, ok := deniedAMINameRegexps.Load(pattern); ok {
+		return r.(*regexp.Regexp)
+	}
+	r, _ := deniedAMINameRegexps.LoadOrStore(pattern, regexp.MustCompile("^"+strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")+"$"))
+	return r.(*regexp.Regexp)
+}
+
+// defaultAMIs returns the default AMIs of the NodeClass' AMI family, which are resolved from the variant or Ubuntu stream
+// that the NodeClass selects. The public parameters of the default AMIs have the same names in every partition, but
+// FIPS-enabled AMIs are only published in the partitions that have FIPS endpoints.
+func (p *Provider) defaultAMIs(nodeClass *v1beta1.NodeClass, options *Options, kubernetesVersion string) ([]DefaultAMIOutput, error) {
+	variant := lo.FromPtr(nodeClass.Spec.AMIVariant)
+	if variant == v1beta1.AMIVariantFIPS && !fipsPartitions.Has(p.partition) {
+		return nil, fmt.Errorf("fips amis aren't published in the %s partition", p.partition)
+	}
+	return GetAMIFamilyForNodeClass(nodeClass, options).DefaultAMIs(kubernetesVersion), nil
+}
+
 func (p *Provider) getDefaultAMIs(ctx context.Context, nodeClass *v1beta1.NodeClass, options *Options) (res AMIs, err error) {
-	if images, ok := p.cache.Get(lo.FromPtr(nodeClass.Spec.AMIFamily)); ok {
+	if settings.FromContext(ctx).Airgapped {
+		return nil, fmt.Errorf("default amis are discovered from public ssm parameters, which aren't available in airgapped mode, amiSelector must be specified")
+	}
+	key, err := cacheKey(ctx, nodeClass)
+	if err != nil {
+		return nil, err
+	}
+	if images, ok := awscache.Get(p.cache, awscache.AMICacheName, key); ok {
 		return images.(AMIs), nil
 	}
-	amiFamily := GetAMIFamily(nodeClass.Spec.AMIFamily, options)
 	kubernetesVersion, err := p.KubeServerVersion(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting kubernetes version %w", err)
 	}
-	defaultAMIs := amiFamily.DefaultAMIs(kubernetesVersion)
+	defaultAMIs, err := p.defaultAMIs(nodeClass, options, kubernetesVersion)
+	if err != nil {
+		return nil, err
+	}
 	for _, ami := range defaultAMIs {
 		if id, err := p.resolveSSMParameter(ctx, ami.Query); err != nil {
-			logging.FromContext(ctx).With("query", ami.Query).Errorf("discovering amis from ssm, %s", err)
+			// Failures are only logged when the parameter was queried, rather than every time its backoff is hit
+			if !errors.Is(err, errBackingOff) {
+				logging.FromContext(ctx).With("query", ami.Query).Errorf("discovering amis from ssm, %s", err)
+			}
 		} else {
 			res = append(res, AMI{AmiID: id, Requirements: ami.Requirements})
 		}
 	}
 	// Resolve Name and CreationDate information into the DefaultAMIs
-	if err = p.ec2api.DescribeImagesPagesWithContext(ctx, &ec2.DescribeImagesInput{
+	described := sets.New[string]()
+	start := time.Now()
+	output, err := p.describeImages(ctx, "", &ec2.DescribeImagesInput{
 		Filters:    []*ec2.Filter{{Name: aws.String("image-id"), Values: aws.StringSlice(lo.Map(res, func(a AMI, _ int) string { return a.AmiID }))}},
 		MaxResults: aws.Int64(500),
-	}, func(page *ec2.DescribeImagesOutput, _ bool) bool {
-		for i := range page.Images {
-			for j := range res {
-				if res[j].AmiID == aws.StringValue(page.Images[i].ImageId) {
-					res[j].Name = aws.StringValue(page.Images[i].Name)
-					res[j].CreationDate = aws.StringValue(page.Images[i].CreationDate)
-				}
+	})
+	observeRequest(apiDescribeImages, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("describing images, %w", err)
+	}
+	for _, image := range output.Images {
+		if denied(ctx, image) {
+			logging.FromContext(ctx).With("id", aws.StringValue(image.ImageId)).Debugf("skipping denied default ami")
+			res = lo.Reject(res, func(a AMI, _ int) bool { return a.AmiID == aws.StringValue(image.ImageId) })
+			continue
+		}
+		supported, err := p.supportsTrustedBoot(ctx, nodeClass.Spec.TrustedBoot, "", image)
+		if err != nil {
+			return nil, err
+		}
+		if !supported {
+			logging.FromContext(ctx).With("id", aws.StringValue(image.ImageId)).Debugf("skipping default ami that doesn't support trusted boot")
+			res = lo.Reject(res, func(a AMI, _ int) bool { return a.AmiID == aws.StringValue(image.ImageId) })
+			continue
+		}
+		described.Insert(aws.StringValue(image.ImageId))
+		for j := range res {
+			if res[j].AmiID == aws.StringValue(image.ImageId) {
+				res[j].Name = aws.StringValue(image.Name)
+				res[j].CreationDate = aws.StringValue(image.CreationDate)
+				res[j].DeprecationTime = aws.StringValue(image.DeprecationTime)
+				res[j].BlockDeviceMappings = image.BlockDeviceMappings
+				res[j].RootDeviceName = aws.StringValue(image.RootDeviceName)
+				res[j].Requirements = withImageRequirements(res[j].Requirements, p.getRequirementsFromImage(image))
+				res[j].Requirements.Add(trustedBootRequirements(nodeClass.Spec.TrustedBoot).Values()...)
 			}
 		}
-		return true
-	}); err != nil {
-		return nil, fmt.Errorf("describing images, %w", err)
 	}
-	p.cache.SetDefault(lo.FromPtr(nodeClass.Spec.AMIFamily), res)
+	// Default AMIs whose images couldn't be described can't be verified to support the trusted boot
+	if nodeClass.Spec.TrustedBoot.Required() {
+		res = lo.Filter(res, func(a AMI, _ int) bool { return described.Has(a.AmiID) })
+	}
+	awscache.SetDefault(p.cache, awscache.AMICacheName, key, res)
 	return res, nil
 }
 
+// DefaultAMIsForVersion resolves the default AMIs of the NodeClass' AMI family for the given Kubernetes version, bypassing
+// the cache. Unlike Get, it returns an error for every SSM parameter that can't be resolved and for every image that isn't
+// available, alongside the AMIs that are.
+func (p *Provider) DefaultAMIsForVersion(ctx context.Context, nodeClass *v1beta1.NodeClass, kubernetesVersion string) (AMIs, error) {
+	var res AMIs
+	var errs error
+	// Several of the default AMIs may share the same query, so we only report each failure once
+	failed := sets.New[string]()
+	defaultAMIs, err := p.defaultAMIs(nodeClass, &Options{}, kubernetesVersion)
+	if err != nil {
+		return nil, err
+	}
+	for _, ami := range defaultAMIs {
+		if failed.Has(ami.Query) {
+			continue
+		}
+		id, err := p.resolveSSMParameter(ctx, ami.Query)
+		if err != nil {
+			failed.Insert(ami.Query)
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		res = append(res, AMI{AmiID: id, Requirements: ami.Requirements})
+	}
+	if len(res) == 0 {
+		return nil, errs
+	}
+	available := sets.New[string]()
+	start := time.Now()
+	output, err := p.describeImages(ctx, "", &ec2.DescribeImagesInput{
+		Filters:    []*ec2.Filter{{Name: aws.String("image-id"), Values: aws.StringSlice(lo.Map(res, func(a AMI, _ int) string { return a.AmiID }))}},
+		MaxResults: aws.Int64(500),
+	})
+	observeRequest(apiDescribeImages, start, err)
+	if err != nil {
+		return nil, multierr.Append(errs, fmt.Errorf("describing images, %w", err))
+	}
+	for _, image := range output.Images {
+		if aws.StringValue(image.State) == ec2.ImageStateAvailable {
+			available.Insert(aws.StringValue(image.ImageId))
+		}
+	}
+	for _, id := range sets.List(sets.New(lo.Map(res, func(a AMI, _ int) string { return a.AmiID })...).Difference(available)) {
+		errs = multierr.Append(errs, fmt.Errorf("image %s is not available", id))
+	}
+	return lo.Filter(res, func(a AMI, _ int) bool { return available.Has(a.AmiID) }), errs
+}
+
 func (p *Provider) resolveSSMParameter(ctx context.Context, ssmQuery string) (string, error) {
+	if settings.FromContext(ctx).Airgapped && strings.HasPrefix(ssmQuery, publicSSMParameterPrefix) {
+		return "", fmt.Errorf("getting ssm parameter %q, public ssm parameters aren't available in airgapped mode", ssmQuery)
+	}
+	if failure, ok := p.ssmFailures.backingOff(ssmQuery, p.clk.Now()); ok {
+		return "", fmt.Errorf("getting ssm parameter %q, %w %d time(s) until %s, %s", ssmQuery, errBackingOff, failure.failures,
+			failure.retryAt.Format(time.RFC3339), failure.err)
+	}
+	start := time.Now()
 	output, err := p.ssm.GetParameterWithContext(ctx, &ssm.GetParameterInput{Name: aws.String(ssmQuery)})
+	observeRequest(apiGetParameter, start, err)
 	if err != nil {
+		// Requests that were cancelled say nothing about the parameter, so they aren't backed off from
+		if ctx.Err() == nil {
+			p.ssmFailures.record(ssmQuery, err, p.clk.Now())
+			SSMParameterResolutionFailures.WithLabelValues(ssmQuery, errorCode(err)).Inc()
+		}
 		return "", fmt.Errorf("getting ssm parameter %q, %w", ssmQuery, err)
 	}
+	p.ssmFailures.succeed(ssmQuery)
 	ami := aws.StringValue(output.Parameter.Value)
 	return ami, nil
 }
 
-func (p *Provider) getAMIs(ctx context.Context, terms []v1beta1.AMISelectorTerm) (AMIs, error) {
-	filterAndOwnerSets := GetFilterAndOwnerSets(terms)
-	hash, err := hashstructure.Hash(filterAndOwnerSets, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+// Reset forgets the ssm parameters that failed to resolve and the uefi data of images, so that they're queried again on
+// their next use
+func (p *Provider) Reset() {
+	p.ssmFailures.reset()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.uefiData = map[string]bool{}
+}
+
+func (p *Provider) getAMIs(ctx context.Context, nodeClass *v1beta1.NodeClass) (AMIs, error) {
+	key, err := cacheKey(ctx, nodeClass)
 	if err != nil {
 		return nil, err
 	}
-	if images, ok := p.cache.Get(fmt.Sprint(hash)); ok {
+	if images, ok := awscache.Get(p.cache, awscache.AMICacheName, key); ok {
 		return images.(AMIs), nil
 	}
+	terms, err := p.resolveSelectorTerms(ctx, nodeClass.Spec.AMISelectorTerms)
+	if err != nil {
+		return nil, err
+	}
+	filterAndOwnerSets := GetFilterAndOwnerSets(terms)
+	setTerms, idTerms := selectorTermIndexes(terms)
+	deprecationPolicy := lo.FromPtr(nodeClass.Spec.AMIDeprecationPolicy)
+	sortStrategy := lo.FromPtr(nodeClass.Spec.AMISortStrategy)
+	now := p.clk.Now()
+	// Only the preferred image for each set of requirements is kept, and the selector terms fail to resolve once they've
+	// matched more images than allowed, since the batcher stops paging through them, so that overly broad selectors can't
+	// exhaust memory
 	images := map[uint64]AMI{}
-	for _, filtersAndOwners := range filterAndOwnerSets {
-		if err = p.ec2api.DescribeImagesPagesWithContext(ctx, &ec2.DescribeImagesInput{
+	outpostARNs := map[uint64]string{}
+	maxImages := settings.FromContext(ctx).AMISelectorMaxImages
+	matched := 0
+	for k, filtersAndOwners := range filterAndOwnerSets {
+		start := time.Now()
+		output, err := p.describeImages(ctx, filtersAndOwners.RoleARN, &ec2.DescribeImagesInput{
 			// Don't include filters in the Describe Images call as EC2 API doesn't allow empty filters.
 			Filters:    lo.Ternary(len(filtersAndOwners.Filters) > 0, filtersAndOwners.Filters, nil),
 			Owners:     lo.Ternary(len(filtersAndOwners.Owners) > 0, aws.StringSlice(filtersAndOwners.Owners), nil),
 			MaxResults: aws.Int64(500),
-		}, func(page *ec2.DescribeImagesOutput, _ bool) bool {
-			for i := range page.Images {
-				reqs := p.getRequirementsFromImage(page.Images[i])
-				if !v1beta1.WellKnownArchitectures.Has(reqs.Get(v1.LabelArchStable).Any()) {
-					continue
-				}
-				reqsHash := lo.Must(hashstructure.Hash(reqs.NodeSelectorRequirements(), hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true}))
-				// If the proposed image is newer, store it so that we can return it
-				if v, ok := images[reqsHash]; ok {
-					candidateCreationTime, _ := time.Parse(time.RFC3339, lo.FromPtr(page.Images[i].CreationDate))
-					existingCreationTime, _ := time.Parse(time.RFC3339, v.CreationDate)
-					if existingCreationTime == candidateCreationTime && lo.FromPtr(page.Images[i].Name) < v.Name {
-						continue
-					}
-					if candidateCreationTime.Unix() < existingCreationTime.Unix() {
-						continue
-					}
-				}
-				images[reqsHash] = AMI{
-					Name:         lo.FromPtr(page.Images[i].Name),
-					AmiID:        lo.FromPtr(page.Images[i].ImageId),
-					CreationDate: lo.FromPtr(page.Images[i].CreationDate),
-					Requirements: reqs,
-				}
+		})
+		observeRequest(apiDescribeImages, start, err)
+		if err != nil {
+			if filtersAndOwners.RoleARN != "" {
+				return nil, fmt.Errorf("describing images as %q, %w", filtersAndOwners.RoleARN, err)
 			}
-			return true
-		}); err != nil {
 			return nil, fmt.Errorf("describing images, %w", err)
 		}
+		if matched += len(output.Images); matched > maxImages {
+			return nil, fmt.Errorf("ami selector terms matched more than %d images, use more specific selector terms", maxImages)
+		}
+		for _, image := range output.Images {
+			if !v1beta1.WellKnownArchitectures.Has(kubeArchitecture(image)) || utils.Excluded(image.Tags) || denied(ctx, image) {
+				continue
+			}
+			if !filtersAndOwners.CreatedWithin(image, now) {
+				continue
+			}
+			if supported, err := p.supportsTrustedBoot(ctx, nodeClass.Spec.TrustedBoot, filtersAndOwners.RoleARN, image); err != nil || !supported {
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			reqs := p.getRequirementsFromImage(image)
+			reqs.Add(trustedBootRequirements(nodeClass.Spec.TrustedBoot).Values()...)
+			outpostARN := imageOutpostARN(image)
+			candidate := AMI{
+				Name:                lo.FromPtr(image.Name),
+				AmiID:               lo.FromPtr(image.ImageId),
+				CreationDate:        lo.FromPtr(image.CreationDate),
+				DeprecationTime:     lo.FromPtr(image.DeprecationTime),
+				Requirements:        reqs,
+				BlockDeviceMappings: image.BlockDeviceMappings,
+				RootDeviceName:      lo.FromPtr(image.RootDeviceName),
+			}
+			// The terms that only select an id are combined into the last set
+			if k < len(setTerms) {
+				candidate.selectorTerm = setTerms[k]
+			} else {
+				candidate.selectorTerm = idTerms[candidate.AmiID]
+			}
+			if deprecationPolicy == v1beta1.AMIDeprecationPolicyExclude && candidate.Deprecated(now) {
+				continue
+			}
+			// Images on an Outpost are kept along with the regional images for the same requirements, since they can
+			// only be launched on the Outpost
+			reqsHash := lo.Must(hashstructure.Hash([]interface{}{reqs.NodeSelectorRequirements(), outpostARN}, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true}))
+			// If the proposed image is preferred, store it so that we can return it
+			if v, ok := images[reqsHash]; ok && !preferred(candidate, v, deprecationPolicy, sortStrategy, now) {
+				continue
+			}
+			images[reqsHash] = candidate
+			outpostARNs[reqsHash] = outpostARN
+		}
+	}
+	if err = p.restrictToOutposts(ctx, images, outpostARNs); err != nil {
+		return nil, err
 	}
-	p.cache.SetDefault(fmt.Sprint(hash), AMIs(lo.Values(images)))
+	awscache.SetDefault(p.cache, awscache.AMICacheName, key, AMIs(lo.Values(images)))
+	p.mu.Lock()
+	p.selectorTerms[key] = terms
+	p.mu.Unlock()
 	return lo.Values(images), nil
 }
 
+// imageOutpostARN returns the ARN of the Outpost that the image's EBS snapshots are stored on, if they aren't stored
+// in the region
+func imageOutpostARN(image *ec2.Image) string {
+	for _, blockDeviceMapping := range image.BlockDeviceMappings {
+		if blockDeviceMapping.Ebs != nil && aws.StringValue(blockDeviceMapping.Ebs.OutpostArn) != "" {
+			return aws.StringValue(blockDeviceMapping.Ebs.OutpostArn)
+		}
+	}
+	return ""
+}
+
+// restrictToOutposts restricts the images whose EBS snapshots are stored on an Outpost to the Outpost, and to the zones
+// of its subnets, and removes the images on Outposts that have no subnets. Regional images can be launched in every zone,
+// including Local Zones and Outposts.
+func (p *Provider) restrictToOutposts(ctx context.Context, images map[uint64]AMI, outpostARNs map[uint64]string) error {
+	arns := lo.Uniq(lo.Compact(lo.Values(outpostARNs)))
+	if len(arns) == 0 {
+		return nil
+	}
+	output, err := p.ec2api.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("outpost-arn"), Values: aws.StringSlice(arns)}},
+	})
+	if err != nil {
+		return fmt.Errorf("describing outpost subnets, %w", err)
+	}
+	zones := map[string][]string{}
+	for _, subnet := range output.Subnets {
+		zones[aws.StringValue(subnet.OutpostArn)] = lo.Uniq(append(zones[aws.StringValue(subnet.OutpostArn)], aws.StringValue(subnet.AvailabilityZone)))
+	}
+	for hash, arn := range outpostARNs {
+		if arn == "" {
+			continue
+		}
+		ami := images[hash]
+		if len(zones[arn]) == 0 {
+			logging.FromContext(ctx).With("id", ami.AmiID, "outpost", arn).Debugf("ignoring ami on an outpost without subnets")
+			delete(images, hash)
+			continue
+		}
+		ami.Zones = zones[arn]
+		ami.OutpostARN = arn
+		images[hash] = ami
+	}
+	return nil
+}
+
+// resolveSelectorTerms replaces the ssm parameter of each of the terms with the id of the AMI that the parameter is
+// set to, in the same way the default AMIs are resolved from their ssm parameters, and the image builder arn of each of
+// the terms with the id of the latest AMI that the pipeline or recipe built
+func (p *Provider) resolveSelectorTerms(ctx context.Context, terms []v1beta1.AMISelectorTerm) ([]v1beta1.AMISelectorTerm, error) {
+	var res []v1beta1.AMISelectorTerm
+	for _, term := range terms {
+		if term.SSM != "" {
+			id, err := p.resolveSSMParameter(ctx, term.SSM)
+			if err != nil {
+				return nil, fmt.Errorf("resolving ami selector terms, %w", err)
+			}
+			term.ID, term.SSM = id, ""
+		}
+		if term.ImageBuilderARN != "" {
+			id, err := p.resolveImageBuilderARN(ctx, term.ImageBuilderARN)
+			if err != nil {
+				return nil, fmt.Errorf("resolving ami selector terms, %w", err)
+			}
+			term.ID, term.ImageBuilderARN = id, ""
+		}
+		res = append(res, term)
+	}
+	return res, nil
+}
+
+// resolveImageBuilderARN returns the id of the AMI that the image builder pipeline or recipe most recently built, that's
+// still available, and that was distributed to the cluster's region. Images of a recipe are listed by the arn of the image that shares its name and
+// version, as image builder names images after the recipe that they're built from.
+func (p *Provider) resolveImageBuilderARN(ctx context.Context, imageBuilderARN string) (string, error) {
+	parsed, err := arn.Parse(imageBuilderARN)
+	if err != nil {
+		return "", fmt.Errorf("parsing image builder arn %q, %w", imageBuilderARN, err)
+	}
+	var latest *imagebuilder.ImageSummary
+	collect := func(summaries []*imagebuilder.ImageSummary) {
+		for _, summary := range summaries {
+			if summary.State == nil || lo.FromPtr(summary.State.Status) != imagebuilder.ImageStatusAvailable || summary.OutputResources == nil {
+				continue
+			}
+			if latest == nil || newer(lo.FromPtr(summary.DateCreated), lo.FromPtr(latest.DateCreated)) {
+				latest = summary
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(parsed.Resource, "image-pipeline/"):
+		err = p.imagebuilder.ListImagePipelineImagesPagesWithContext(ctx, &imagebuilder.ListImagePipelineImagesInput{
+			ImagePipelineArn: aws.String(imageBuilderARN),
+		}, func(page *imagebuilder.ListImagePipelineImagesOutput, _ bool) bool {
+			collect(page.ImageSummaryList)
+			return true
+		})
+	case strings.HasPrefix(parsed.Resource, "image-recipe/"):
+		parsed.Resource = "image/" + strings.TrimPrefix(parsed.Resource, "image-recipe/")
+		err = p.imagebuilder.ListImageBuildVersionsPagesWithContext(ctx, &imagebuilder.ListImageBuildVersionsInput{
+			ImageVersionArn: aws.String(parsed.String()),
+		}, func(page *imagebuilder.ListImageBuildVersionsOutput, _ bool) bool {
+			collect(page.ImageSummaryList)
+			return true
+		})
+	default:
+		return "", fmt.Errorf("image builder arn %q is neither an image pipeline nor an image recipe", imageBuilderARN)
+	}
+	if err != nil {
+		return "", fmt.Errorf("listing images of %q, %w", imageBuilderARN, err)
+	}
+	if latest == nil {
+		return "", fmt.Errorf("no available images were built by %q", imageBuilderARN)
+	}
+	ami, ok := lo.Find(latest.OutputResources.Amis, func(a *imagebuilder.Ami) bool { return lo.FromPtr(a.Region) == p.region })
+	if !ok {
+		return "", fmt.Errorf("image %q built by %q wasn't distributed to %s", lo.FromPtr(latest.Arn), imageBuilderARN, p.region)
+	}
+	return lo.FromPtr(ami.Image), nil
+}
+
+// newer returns whether the RFC3339 date is after the other, treating dates that can't be parsed as the oldest
+func newer(date, other string) bool {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return false
+	}
+	o, err := time.Parse(time.RFC3339, other)
+	return err != nil || t.After(o)
+}
+
+// preferred returns whether the candidate image should be selected over the existing image with the same requirements.
+// The image that the sort strategy orders first is preferred, unless the deprecation policy deprioritizes deprecated
+// images and only one of them is.
+func preferred(candidate, existing AMI, deprecationPolicy, sortStrategy string, now time.Time) bool {
+	if deprecationPolicy == v1beta1.AMIDeprecationPolicyDeprioritize && candidate.Deprecated(now) != existing.Deprecated(now) {
+		return existing.Deprecated(now)
+	}
+	return !existing.Before(candidate, sortStrategy)
+}
+
 type FiltersAndOwners struct {
 	Filters []*ec2.Filter
 	Owners  []string
+	// The creation date window of the term, which EC2 can't filter on so it's applied to the described images. The max
+	// age is kept as a duration rather than a date so that the window doesn't change the cache key as time passes.
+	MinCreationDate *time.Time
+	MaxCreationDate *time.Time
+	MaxAge          *time.Duration
+	// RoleARN is the role that the images are described as, if they aren't described as the cluster's account
+	RoleARN string
+}
+
+// CreatedWithin returns whether the image was created within the creation date window, if one is set
+func (f FiltersAndOwners) CreatedWithin(image *ec2.Image, now time.Time) bool {
+	if f.MinCreationDate == nil && f.MaxCreationDate == nil && f.MaxAge == nil {
+		return true
+	}
+	creationDate, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+	if err != nil {
+		return false
+	}
+	if f.MinCreationDate != nil && creationDate.Before(*f.MinCreationDate) {
+		return false
+	}
+	if f.MaxCreationDate != nil && creationDate.After(*f.MaxCreationDate) {
+		return false
+	}
+	return f.MaxAge == nil || !creationDate.Before(now.Add(-*f.MaxAge))
 }
 
 func GetFilterAndOwnerSets(terms []v1beta1.AMISelectorTerm) (res []FiltersAndOwners) {
 	idFilter := &ec2.Filter{Name: aws.String("image-id")}
 	for _, term := range terms {
 		switch {
-		case term.ID != "":
+		case onlySelectsID(term):
 			idFilter.Values = append(idFilter.Values, aws.String(term.ID))
 		default:
 			elem := FiltersAndOwners{
-				Owners: lo.Ternary(term.Owner != "", []string{term.Owner}, []string{"self", "amazon"}),
+				Owners:  lo.Ternary(term.Owner != "", []string{term.Owner}, []string{"self", "amazon"}),
+				RoleARN: term.RoleARN,
+			}
+			if term.MinCreationDate != nil {
+				elem.MinCreationDate = lo.ToPtr(term.MinCreationDate.Time)
+			}
+			if term.MaxCreationDate != nil {
+				elem.MaxCreationDate = lo.ToPtr(term.MaxCreationDate.Time)
+			}
+			if term.MaxAge != nil {
+				elem.MaxAge = lo.ToPtr(term.MaxAge.Duration)
+			}
+			// Only the terms whose ssm parameters have been resolved, or that set a role, combine an id with other
+			// fields. Like the terms that only have an id, they select the image whoever owns it, unless an owner is set.
+			if term.ID != "" {
+				elem.Owners = lo.Ternary(term.Owner != "", []string{term.Owner}, nil)
+				elem.Filters = append(elem.Filters, &ec2.Filter{
+					Name:   aws.String("image-id"),
+					Values: aws.StringSlice([]string{term.ID}),
+				})
 			}
 			if term.Name != "" {
 				elem.Filters = append(elem.Filters, &ec2.Filter{
@@ -300,6 +1159,29 @@ func GetFilterAndOwnerSets(terms []v1beta1.AMISelectorTerm) (res []FiltersAndOwn
 	return res
 }
 
+// selectorTermIndexes returns the position of the term behind each of the filter and owner sets of the terms, except
+// for the last set that combines the terms that only select an id, and the position of the first of those terms that
+// selects each id
+func selectorTermIndexes(terms []v1beta1.AMISelectorTerm) (sets []int, ids map[string]int) {
+	ids = map[string]int{}
+	for i, term := range terms {
+		if !onlySelectsID(term) {
+			sets = append(sets, i)
+		} else if _, ok := ids[term.ID]; !ok {
+			ids[term.ID] = i
+		}
+	}
+	return sets, ids
+}
+
+func onlySelectsID(term v1beta1.AMISelectorTerm) bool {
+	return term.ID != "" && term.Name == "" && term.Owner == "" && len(term.Tags) == 0 && !hasCreationDateWindow(term) && term.RoleARN == ""
+}
+
+func hasCreationDateWindow(term v1beta1.AMISelectorTerm) bool {
+	return term.MinCreationDate != nil || term.MaxCreationDate != nil || term.MaxAge != nil
+}
+
 func (p *Provider) getRequirementsFromImage(ec2Image *ec2.Image) scheduling.Requirements {
 	requirements := scheduling.NewRequirements()
 	for _, tag := range ec2Image.Tags {
@@ -308,10 +1190,101 @@ func (p *Provider) getRequirementsFromImage(ec2Image *ec2.Image) scheduling.Requ
 		}
 	}
 	// Always add the architecture of an image as a requirement, irrespective of what's specified in EC2 tags.
-	architecture := *ec2Image.Architecture
-	if value, ok := v1alpha1.AWSToKubeArchitectures[architecture]; ok {
-		architecture = value
+	requirements.Add(scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, kubeArchitecture(ec2Image)))
+	if rootDeviceType := aws.StringValue(ec2Image.RootDeviceType); rootDeviceType != "" {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstanceRootDeviceType, v1.NodeSelectorOpIn, rootDeviceType))
+	}
+	if virtualizationType := aws.StringValue(ec2Image.VirtualizationType); virtualizationType != "" {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstanceVirtualizationType, v1.NodeSelectorOpIn, virtualizationType))
+	}
+	// Images that prefer UEFI fall back to legacy BIOS on instance types that don't support it, so only images that
+	// require a boot mode constrain the instance types that they can be launched on. Otherwise, launches of instance
+	// types that don't support the image's boot mode would fail at Fleet time.
+	if bootMode := aws.StringValue(ec2Image.BootMode); bootMode == ec2.BootModeValuesLegacyBios || bootMode == ec2.BootModeValuesUefi {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstanceBootMode, v1.NodeSelectorOpIn, bootMode))
+	}
+	return requirements
+}
+
+// supportsTrustedBoot returns whether the image supports the trusted boot that the NodeClass requires. NitroTPM and
+// Secure Boot both rely on UEFI, so images that only prefer UEFI are supported as well, and are restricted to the
+// instance types that support it by trustedBootRequirements. Secure Boot also requires the image to be registered with
+// the UEFI variables that enable it, which are described as the role that the image was selected as.
+func (p *Provider) supportsTrustedBoot(ctx context.Context, trustedBoot *v1beta1.TrustedBoot, roleARN string, image *ec2.Image) (bool, error) {
+	if !trustedBoot.Required() {
+		return true, nil
+	}
+	if trustedBoot.NitroTPM && aws.StringValue(image.TpmSupport) != ec2.TpmSupportValuesV20 {
+		return false, nil
+	}
+	if !lo.Contains([]string{ec2.BootModeValuesUefi, ec2.BootModeValuesUefiPreferred}, aws.StringValue(image.BootMode)) {
+		return false, nil
+	}
+	if !trustedBoot.SecureBoot {
+		return true, nil
+	}
+	return p.hasUEFIData(ctx, roleARN, aws.StringValue(image.ImageId))
+}
+
+// hasUEFIData returns whether the image was registered with UEFI variables. Images without them boot without the
+// Secure Boot keys, so Secure Boot isn't enabled on their instances.
+func (p *Provider) hasUEFIData(ctx context.Context, roleARN string, id string) (bool, error) {
+	p.mu.Lock()
+	registered, ok := p.uefiData[id]
+	p.mu.Unlock()
+	if ok {
+		return registered, nil
+	}
+	api := lo.TernaryF(roleARN == "", func() ec2iface.EC2API { return p.ec2api }, func() ec2iface.EC2API { return p.ec2APIForRole(roleARN) })
+	start := time.Now()
+	output, err := api.DescribeImageAttributeWithContext(ctx, &ec2.DescribeImageAttributeInput{
+		ImageId:   aws.String(id),
+		Attribute: aws.String(ec2.ImageAttributeNameUefiData),
+	})
+	observeRequest(apiDescribeImageAttribute, start, err)
+	if err != nil {
+		return false, fmt.Errorf("describing uefi data of image %s, %w", id, err)
+	}
+	registered = output.UefiData != nil && aws.StringValue(output.UefiData.Value) != ""
+	p.mu.Lock()
+	p.uefiData[id] = registered
+	p.mu.Unlock()
+	return registered, nil
+}
+
+// trustedBootRequirements returns the requirements that the instance types that images are launched on must meet for
+// instances to boot with the trusted boot that the NodeClass requires
+func trustedBootRequirements(trustedBoot *v1beta1.TrustedBoot) scheduling.Requirements {
+	requirements := scheduling.NewRequirements()
+	if !trustedBoot.Required() {
+		return requirements
+	}
+	requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstanceBootMode, v1.NodeSelectorOpIn, ec2.BootModeTypeUefi))
+	if trustedBoot.NitroTPM {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstanceNitroTPMSupported, v1.NodeSelectorOpIn, "true"))
 	}
-	requirements.Add(scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, architecture))
 	return requirements
 }
+
+// withImageRequirements returns the requirements of a default AMI along with the requirements that the well-known label
+// tags of its image add, so that the capabilities that vendors tag their images with constrain the instance types that
+// default AMIs are launched on, just like those of the AMIs that are selected by the NodeClass. The requirements of the
+// default AMI's SSM parameter take precedence over tags on the same labels.
+func withImageRequirements(requirements scheduling.Requirements, imageRequirements scheduling.Requirements) scheduling.Requirements {
+	res := scheduling.NewRequirements(requirements.Values()...)
+	for key, requirement := range imageRequirements {
+		if !res.Has(key) {
+			res.Add(requirement)
+		}
+	}
+	return res
+}
+
+// kubeArchitecture returns the Kubernetes architecture of an image, e.g. amd64 for x86_64 images
+func kubeArchitecture(ec2Image *ec2.Image) string {
+	architecture := aws.StringValue(ec2Image.Architecture)
+	if value, ok := v1alpha1.AWSToKubeArchitectures[architecture]; ok {
+		return value
+	}
+	return architecture
+}
@@ -18,25 +18,39 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/imagebuilder"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	. "knative.dev/pkg/logging/testing"
 
 	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/operator/scheme"
 	"github.com/aws/karpenter-core/pkg/scheduling"
 	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
 	"github.com/aws/karpenter/pkg/test"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 )
 
 var ctx context.Context
@@ -137,6 +151,47 @@ var _ = Describe("AMIProvider", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(amis).To(HaveLen(4))
 	})
+	It("should add the well-known label tags of default AMIs to their requirements", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version):     amd64AMI,
+			fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-gpu/recommended/image_id", version): amd64NvidiaAMI,
+		}
+		awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+			{
+				Name:         aws.String(amd64AMI),
+				ImageId:      aws.String(amd64AMI),
+				CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+				Architecture: aws.String("x86_64"),
+			},
+			{
+				Name:         aws.String(amd64NvidiaAMI),
+				ImageId:      aws.String(amd64NvidiaAMI),
+				CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+				Architecture: aws.String("x86_64"),
+				Tags: []*ec2.Tag{
+					{Key: aws.String(v1alpha1.LabelInstanceGPUManufacturer), Value: aws.String("nvidia")},
+					{Key: aws.String(v1alpha1.LabelInstanceGPUCount), Value: aws.String("1")},
+					{Key: aws.String("foo"), Value: aws.String("bar")},
+				},
+			},
+		}})
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		nvidiaAMIs := lo.Filter(amis, func(a amifamily.AMI, _ int) bool { return a.AmiID == amd64NvidiaAMI })
+		Expect(nvidiaAMIs).ToNot(BeEmpty())
+		for _, ami := range nvidiaAMIs {
+			Expect(ami.Requirements.Get(v1alpha1.LabelInstanceGPUManufacturer).Values()).To(ConsistOf("nvidia"))
+			Expect(ami.Requirements.Has("foo")).To(BeFalse())
+		}
+		// The requirements of the ssm parameter take precedence over the tags
+		Expect(lo.ContainsBy(nvidiaAMIs, func(a amifamily.AMI) bool {
+			return a.Requirements.Get(v1alpha1.LabelInstanceGPUCount).Operator() == v1.NodeSelectorOpExists
+		})).To(BeTrue())
+		for _, ami := range lo.Reject(amis, func(a amifamily.AMI, _ int) bool { return a.AmiID == amd64NvidiaAMI }) {
+			Expect(ami.Requirements.Has(v1alpha1.LabelInstanceGPUManufacturer)).To(BeFalse())
+		}
+	})
 	It("should succeed to resolve AMIs (Bottlerocket)", func() {
 		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
 		awsEnv.SSMAPI.Parameters = map[string]string{
@@ -149,6 +204,56 @@ var _ = Describe("AMIProvider", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(amis).To(HaveLen(6))
 	})
+	It("should succeed to resolve AMIs (Bottlerocket FIPS)", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+		nodeClass.Spec.AMIVariant = lo.ToPtr(v1beta1.AMIVariantFIPS)
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-fips/x86_64/latest/image_id", version):        amd64AMI,
+			fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia-fips/x86_64/latest/image_id", version): amd64NvidiaAMI,
+			fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-fips/arm64/latest/image_id", version):         arm64AMI,
+			fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia-fips/arm64/latest/image_id", version):  arm64NvidiaAMI,
+		}
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).To(HaveLen(6))
+		Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ContainElements(amd64AMI, amd64NvidiaAMI, arm64AMI, arm64NvidiaAMI))
+	})
+	It("should cache the AMIs of each Bottlerocket variant separately", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s/x86_64/latest/image_id", version): amd64AMI,
+		}
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).To(HaveLen(1))
+
+		nodeClass.Spec.AMIVariant = lo.ToPtr(v1beta1.AMIVariantFIPS)
+		amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).To(HaveLen(0))
+	})
+	It("should succeed to resolve AMIs (AL2 FIPS)", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+		nodeClass.Spec.AMIVariant = lo.ToPtr(v1beta1.AMIVariantFIPS)
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-fips/recommended/image_id", version):       amd64AMI,
+			fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-arm64-fips/recommended/image_id", version): arm64AMI,
+			fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-gpu/recommended/image_id", version):        amd64NvidiaAMI,
+		}
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf(amd64AMI, arm64AMI))
+	})
+	It("should succeed to resolve AMIs (AL2023)", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyAL2023
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2023/x86_64/standard/recommended/image_id", version): amd64AMI,
+			fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2023/arm64/standard/recommended/image_id", version):  arm64AMI,
+		}
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf(amd64AMI, arm64AMI))
+	})
 	It("should succeed to resolve AMIs (Ubuntu)", func() {
 		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyUbuntu
 		awsEnv.SSMAPI.Parameters = map[string]string{
@@ -159,6 +264,43 @@ var _ = Describe("AMIProvider", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(amis).To(HaveLen(2))
 	})
+	It("should succeed to resolve AMIs (Ubuntu Pro)", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyUbuntu
+		nodeClass.Spec.UbuntuStream = lo.ToPtr(v1beta1.UbuntuStreamPro)
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/canonical/ubuntu/eks/20.04/%s/stable/current/amd64/hvm/ebs-gp2/ami-id", version):     amd64NvidiaAMI,
+			fmt.Sprintf("/aws/service/canonical/ubuntu/eks-pro/20.04/%s/stable/current/amd64/hvm/ebs-gp2/ami-id", version): amd64AMI,
+			fmt.Sprintf("/aws/service/canonical/ubuntu/eks-pro/20.04/%s/stable/current/arm64/hvm/ebs-gp2/ami-id", version): arm64AMI,
+		}
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf(amd64AMI, arm64AMI))
+	})
+	It("should succeed to resolve AMIs (Ubuntu minimal)", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyUbuntu
+		nodeClass.Spec.UbuntuStream = lo.ToPtr(v1beta1.UbuntuStreamMinimal)
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/canonical/ubuntu/eks-minimal/20.04/%s/stable/current/amd64/hvm/ebs-gp2/ami-id", version): amd64AMI,
+			fmt.Sprintf("/aws/service/canonical/ubuntu/eks-minimal/20.04/%s/stable/current/arm64/hvm/ebs-gp2/ami-id", version): arm64AMI,
+		}
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf(amd64AMI, arm64AMI))
+	})
+	It("should cache the AMIs of each Ubuntu stream separately", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyUbuntu
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/canonical/ubuntu/eks/20.04/%s/stable/current/amd64/hvm/ebs-gp2/ami-id", version): amd64AMI,
+		}
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).To(HaveLen(1))
+
+		nodeClass.Spec.UbuntuStream = lo.ToPtr(v1beta1.UbuntuStreamPro)
+		amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).To(HaveLen(0))
+	})
 	It("should succeed to resolve AMIs (Windows2019)", func() {
 		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyWindows2019
 		awsEnv.SSMAPI.Parameters = map[string]string{
@@ -220,6 +362,42 @@ var _ = Describe("AMIProvider", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(amis).To(HaveLen(1))
 	})
+	Context("Partitions", func() {
+		var provider *amifamily.Provider
+		BeforeEach(func() {
+			nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nodeClass.Spec.AMIVariant = lo.ToPtr(v1beta1.AMIVariantFIPS)
+			awsEnv.SSMAPI.Parameters = map[string]string{
+				fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-fips/x86_64/latest/image_id", version): amd64AMI,
+			}
+		})
+		It("should resolve FIPS-enabled AMIs in the aws-us-gov partition", func() {
+			provider = amifamily.NewProvider(ctx, awsEnv.Clock, "us-gov-west-1", env.Client, env.KubernetesInterface, awsEnv.SSMAPI, awsEnv.ImageBuilderAPI, awsEnv.EC2API, nil,
+				cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval), cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+			amis, err := provider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+		})
+		It("should fail to resolve FIPS-enabled AMIs in the aws-cn partition", func() {
+			provider = amifamily.NewProvider(ctx, awsEnv.Clock, "cn-north-1", env.Client, env.KubernetesInterface, awsEnv.SSMAPI, awsEnv.ImageBuilderAPI, awsEnv.EC2API, nil,
+				cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval), cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+			_, err := provider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("aws-cn"))
+			Expect(awsEnv.SSMAPI.CalledWithInput.Len()).To(BeZero())
+		})
+		It("should resolve the standard AMIs in the aws-cn partition", func() {
+			nodeClass.Spec.AMIVariant = nil
+			awsEnv.SSMAPI.Parameters = map[string]string{
+				fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s/x86_64/latest/image_id", version): amd64AMI,
+			}
+			provider = amifamily.NewProvider(ctx, awsEnv.Clock, "cn-north-1", env.Client, env.KubernetesInterface, awsEnv.SSMAPI, awsEnv.ImageBuilderAPI, awsEnv.EC2API, nil,
+				cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval), cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+			amis, err := provider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+		})
+	})
 	Context("AMI Selectors", func() {
 		It("should have default owners and use tags when prefixes aren't set", func() {
 			amiSelectorTerms := []v1beta1.AMISelectorTerm{
@@ -288,6 +466,42 @@ var _ = Describe("AMIProvider", func() {
 				},
 			}, filterAndOwnersSets)
 		})
+		It("should combine the ids resolved from ssm parameters with the other fields of their terms", func() {
+			amiSelectorTerms := []v1beta1.AMISelectorTerm{
+				{
+					ID:    "ami-abcd1234",
+					Owner: "123456789012",
+				},
+				{
+					ID:   "ami-cafeaced",
+					Tags: map[string]string{"foo": "bar"},
+				},
+			}
+			filterAndOwnersSets := amifamily.GetFilterAndOwnerSets(amiSelectorTerms)
+			ExpectConsistsOfFiltersAndOwners([]amifamily.FiltersAndOwners{
+				{
+					Filters: []*ec2.Filter{
+						{
+							Name:   aws.String("image-id"),
+							Values: aws.StringSlice([]string{"ami-abcd1234"}),
+						},
+					},
+					Owners: []string{"123456789012"},
+				},
+				{
+					Filters: []*ec2.Filter{
+						{
+							Name:   aws.String("image-id"),
+							Values: aws.StringSlice([]string{"ami-cafeaced"}),
+						},
+						{
+							Name:   aws.String("tag:foo"),
+							Values: aws.StringSlice([]string{"bar"}),
+						},
+					},
+				},
+			}, filterAndOwnersSets)
+		})
 		It("should allow only specifying owners", func() {
 			amiSelectorTerms := []v1beta1.AMISelectorTerm{
 				{
@@ -398,6 +612,1233 @@ var _ = Describe("AMIProvider", func() {
 			))
 		})
 	})
+	Context("Batching", func() {
+		It("should describe the images of node classes that share selector terms once", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-name"),
+					ImageId:      aws.String("ami-123"),
+					CreationDate: aws.String("2023-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+			}})
+			nodeClasses := []*v1beta1.NodeClass{nodeClass, test.NodeClass(v1beta1.NodeClass{Spec: nodeClass.Spec})}
+			var wg sync.WaitGroup
+			for _, nc := range nodeClasses {
+				wg.Add(1)
+				go func(nc *v1beta1.NodeClass) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					amis, err := awsEnv.AMIProvider.Get(ctx, nc, &amifamily.Options{})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(amis).To(HaveLen(1))
+					Expect(amis[0].AmiID).To(Equal("ami-123"))
+				}(nc)
+			}
+			wg.Wait()
+			Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(Equal(1))
+		})
+		It("should describe the default images of node classes once", func() {
+			nodeClasses := []*v1beta1.NodeClass{nodeClass, test.NodeClass(v1beta1.NodeClass{Spec: v1beta1.NodeClassSpec{AMIFamily: aws.String(v1beta1.AMIFamilyBottlerocket)}})}
+			var wg sync.WaitGroup
+			for _, nc := range nodeClasses {
+				wg.Add(1)
+				go func(nc *v1beta1.NodeClass) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					amis, err := awsEnv.AMIProvider.Get(ctx, nc, &amifamily.Options{})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(amis).ToNot(BeEmpty())
+				}(nc)
+			}
+			wg.Wait()
+			Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(Equal(1))
+		})
+	})
+	Context("Sort Strategy", func() {
+		var images []*ec2.Image
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			images = []*ec2.Image{
+				{
+					Name:         aws.String("my-ami-1.9.0"),
+					ImageId:      aws.String("ami-190"),
+					CreationDate: aws.String("2023-02-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+				{
+					// Backdated, e.g. because it was copied from an image that was created before the newest one
+					Name:         aws.String("my-ami-1.10.0"),
+					ImageId:      aws.String("ami-1100"),
+					CreationDate: aws.String("2023-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+			}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: images})
+		})
+		It("should select the newest image by default", func() {
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-190"))
+		})
+		It("should select the image with the highest version in its name", func() {
+			nodeClass.Spec.AMISortStrategy = aws.String(v1beta1.AMISortStrategyNameDescending)
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-1100"))
+		})
+		It("should select the image with the highest version in its name when the creation dates are equal", func() {
+			images[0].CreationDate = images[1].CreationDate
+			nodeClass.Spec.AMISortStrategy = aws.String(v1beta1.AMISortStrategyNameDescending)
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-1100"))
+		})
+		It("should order images with different requirements by the versions in their names", func() {
+			images[1].Architecture = aws.String("arm64")
+			nodeClass.Spec.AMISortStrategy = aws.String(v1beta1.AMISortStrategyNameDescending)
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(Equal([]string{"ami-1100", "ami-190"}))
+		})
+		It("should select the image of the first selector term when pinned", func() {
+			nodeClass.Spec.AMISortStrategy = aws.String(v1beta1.AMISortStrategyPinned)
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-1100"}, {ID: "ami-190"}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-1100"))
+
+			// Reordering the terms changes the selected image, even though the same images are selected
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-190"}, {ID: "ami-1100"}}
+			amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-190"))
+		})
+		It("should select the newest image of the first selector term that selects images when pinned", func() {
+			nodeClass.Spec.AMISortStrategy = aws.String(v1beta1.AMISortStrategyPinned)
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}, {ID: "ami-1100"}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-190"))
+		})
+		It("should sort amis by the versions in their names", func() {
+			amis := amifamily.AMIs{
+				{Name: "my-ami-v1.9.0", CreationDate: "2023-04-01T12:00:00Z"},
+				{Name: "my-ami-v1.10.0", CreationDate: "2023-03-01T12:00:00Z"},
+				{Name: "my-ami-v01.2", CreationDate: "2023-02-01T12:00:00Z"},
+				{Name: "my-ami-v1.10.1", CreationDate: "2023-01-01T12:00:00Z"},
+			}
+			amis.SortBy(v1beta1.AMISortStrategyNameDescending)
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.Name })).To(Equal([]string{"my-ami-v1.10.1", "my-ami-v1.10.0", "my-ami-v1.9.0", "my-ami-v01.2"}))
+		})
+	})
+	Context("Image Limits", func() {
+		var images []*ec2.Image
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			images = nil
+			for i := 0; i < 1200; i++ {
+				images = append(images, &ec2.Image{
+					Name:         aws.String(fmt.Sprintf("ami-%d", i)),
+					ImageId:      aws.String(fmt.Sprintf("ami-%d-id", i)),
+					CreationDate: aws.String(time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Hour).Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				})
+			}
+			// Shuffle the newest image into the first page, which shouldn't matter since every page is processed
+			images[0], images[len(images)-1] = images[len(images)-1], images[0]
+		})
+		It("should keep the newest image across every page of images", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: images})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-1199-id"))
+		})
+		It("should fail when the selector terms match more images than allowed", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: images})
+			limitedCtx := settings.ToContext(ctx, test.Settings(test.SettingOptions{AMISelectorMaxImages: lo.ToPtr(1000)}))
+			_, err := awsEnv.AMIProvider.Get(limitedCtx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("matched more than 1000 images"))
+
+			// The truncated result isn't cached, so the images resolve once the limit is raised
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-1199-id"))
+		})
+		It("should succeed when the selector terms match fewer images than allowed", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: images[:900]})
+			limitedCtx := settings.ToContext(ctx, test.Settings(test.SettingOptions{AMISelectorMaxImages: lo.ToPtr(1000)}))
+			amis, err := awsEnv.AMIProvider.Get(limitedCtx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-1199-id"))
+		})
+	})
+	Context("Exclusion", func() {
+		It("should select the newest image that isn't tagged to be excluded", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-old"),
+					ImageId:      aws.String("ami-123"),
+					CreationDate: aws.String("2022-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+				{
+					Name:         aws.String("ami-new"),
+					ImageId:      aws.String("ami-456"),
+					CreationDate: aws.String("2023-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags: []*ec2.Tag{
+						{Key: aws.String("foo"), Value: aws.String("bar")},
+						{Key: aws.String(v1beta1.TagExclude), Value: aws.String("true")},
+					},
+				},
+			}})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+		})
+		It("should not select images tagged to be excluded even when selected by id", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-456"}}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-new"),
+					ImageId:      aws.String("ami-456"),
+					CreationDate: aws.String("2023-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String(v1beta1.TagExclude), Value: aws.String("")}},
+				},
+			}})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(BeEmpty())
+		})
+	})
+	Context("Creation Date Window", func() {
+		BeforeEach(func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-old"),
+					ImageId:      aws.String("ami-123"),
+					CreationDate: aws.String(time.Now().Add(-60 * 24 * time.Hour).Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+				{
+					Name:         aws.String("ami-new"),
+					ImageId:      aws.String("ami-456"),
+					CreationDate: aws.String(time.Now().Add(-24 * time.Hour).Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+			}})
+		})
+		It("should select the newest image created before the max creation date", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{
+				Tags:            map[string]string{"foo": "bar"},
+				MaxCreationDate: &metav1.Time{Time: time.Now().Add(-30 * 24 * time.Hour)},
+			}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+		})
+		It("should not select images created before the min creation date", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{
+				Tags:            map[string]string{"foo": "bar"},
+				MinCreationDate: &metav1.Time{Time: time.Now().Add(-time.Hour)},
+			}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(BeEmpty())
+		})
+		It("should not select images older than the max age even when selected by id", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{ID: "ami-123", MaxAge: &metav1.Duration{Duration: 30 * 24 * time.Hour}},
+				{ID: "ami-456", MaxAge: &metav1.Duration{Duration: 30 * 24 * time.Hour}},
+			}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-456"))
+		})
+		It("should not merge id terms that have a creation date window with the other id terms", func() {
+			maxAge := 30 * 24 * time.Hour
+			filterAndOwnersSets := amifamily.GetFilterAndOwnerSets([]v1beta1.AMISelectorTerm{
+				{ID: "ami-123"},
+				{ID: "ami-456", MaxAge: &metav1.Duration{Duration: maxAge}},
+			})
+			ExpectConsistsOfFiltersAndOwners([]amifamily.FiltersAndOwners{
+				{
+					Filters: []*ec2.Filter{{Name: aws.String("image-id"), Values: aws.StringSlice([]string{"ami-123"})}},
+				},
+				{
+					Filters: []*ec2.Filter{{Name: aws.String("image-id"), Values: aws.StringSlice([]string{"ami-456"})}},
+					MaxAge:  &maxAge,
+				},
+			}, filterAndOwnersSets)
+		})
+	})
+	Context("Image Requirements", func() {
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:               aws.String("ami-uefi"),
+					ImageId:            aws.String("ami-123"),
+					CreationDate:       aws.String(time.Now().Add(-time.Hour).Format(time.RFC3339)),
+					Architecture:       aws.String("x86_64"),
+					RootDeviceType:     aws.String(ec2.DeviceTypeEbs),
+					VirtualizationType: aws.String(ec2.VirtualizationTypeHvm),
+					BootMode:           aws.String(ec2.BootModeValuesUefi),
+					Tags:               []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+				{
+					Name:               aws.String("ami-legacy-bios"),
+					ImageId:            aws.String("ami-456"),
+					CreationDate:       aws.String(time.Now().Add(-24 * time.Hour).Format(time.RFC3339)),
+					Architecture:       aws.String("x86_64"),
+					RootDeviceType:     aws.String(ec2.DeviceTypeEbs),
+					VirtualizationType: aws.String(ec2.VirtualizationTypeHvm),
+					BootMode:           aws.String(ec2.BootModeValuesLegacyBios),
+					Tags:               []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+			}})
+		})
+		It("should derive requirements from the root device type, virtualization type and boot mode of images", func() {
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(2))
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+			Expect(amis[0].Requirements.Get(v1alpha1.LabelInstanceRootDeviceType).Values()).To(ConsistOf(ec2.DeviceTypeEbs))
+			Expect(amis[0].Requirements.Get(v1alpha1.LabelInstanceVirtualizationType).Values()).To(ConsistOf(ec2.VirtualizationTypeHvm))
+			Expect(amis[0].Requirements.Get(v1alpha1.LabelInstanceBootMode).Values()).To(ConsistOf(ec2.BootModeValuesUefi))
+			Expect(amis[1].Requirements.Get(v1alpha1.LabelInstanceBootMode).Values()).To(ConsistOf(ec2.BootModeValuesLegacyBios))
+		})
+		It("should not constrain the boot mode of images that prefer uefi", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{{
+				Name:         aws.String("ami-uefi-preferred"),
+				ImageId:      aws.String("ami-123"),
+				CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+				Architecture: aws.String("x86_64"),
+				BootMode:     aws.String(ec2.BootModeValuesUefiPreferred),
+				Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+			}}})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].Requirements.Has(v1alpha1.LabelInstanceBootMode)).To(BeFalse())
+			Expect(amis[0].Requirements.Has(v1alpha1.LabelInstanceRootDeviceType)).To(BeFalse())
+		})
+		It("should only map instance types to the images whose boot mode they support", func() {
+			uefiInstanceType := &cloudprovider.InstanceType{
+				Name: "m5.large",
+				Requirements: scheduling.NewRequirements(
+					scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, "amd64"),
+					scheduling.NewRequirement(v1alpha1.LabelInstanceBootMode, v1.NodeSelectorOpIn, ec2.BootModeTypeLegacyBios, ec2.BootModeTypeUefi),
+				),
+			}
+			legacyBIOSInstanceType := &cloudprovider.InstanceType{
+				Name: "p3.8xlarge",
+				Requirements: scheduling.NewRequirements(
+					scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, "amd64"),
+					scheduling.NewRequirement(v1alpha1.LabelInstanceBootMode, v1.NodeSelectorOpIn, ec2.BootModeTypeLegacyBios),
+				),
+			}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			mapped := amis.MapToInstanceTypes([]*cloudprovider.InstanceType{uefiInstanceType, legacyBIOSInstanceType})
+			Expect(mapped).To(HaveLen(2))
+			Expect(mapped["ami-123"]).To(ConsistOf(uefiInstanceType))
+			Expect(mapped["ami-456"]).To(ConsistOf(legacyBIOSInstanceType))
+		})
+	})
+	Context("Trusted Boot", func() {
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-tpm"),
+					ImageId:      aws.String("ami-123"),
+					CreationDate: aws.String(time.Now().Add(-2 * time.Hour).Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+					BootMode:     aws.String(ec2.BootModeValuesUefiPreferred),
+					TpmSupport:   aws.String(ec2.TpmSupportValuesV20),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+				{
+					Name:         aws.String("ami-uefi"),
+					ImageId:      aws.String("ami-456"),
+					CreationDate: aws.String(time.Now().Add(-time.Hour).Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+					BootMode:     aws.String(ec2.BootModeValuesUefi),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+				{
+					Name:         aws.String("ami-legacy-bios"),
+					ImageId:      aws.String("ami-789"),
+					CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+			}})
+		})
+		It("should only select images that support NitroTPM when it's required", func() {
+			nodeClass.Spec.TrustedBoot = &v1beta1.TrustedBoot{NitroTPM: true}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+			Expect(amis[0].Requirements.Get(v1alpha1.LabelInstanceNitroTPMSupported).Values()).To(ConsistOf("true"))
+			Expect(amis[0].Requirements.Get(v1alpha1.LabelInstanceBootMode).Values()).To(ConsistOf(ec2.BootModeTypeUefi))
+		})
+		It("should only select images that boot with uefi when secure boot is required", func() {
+			nodeClass.Spec.TrustedBoot = &v1beta1.TrustedBoot{SecureBoot: true}
+			awsEnv.EC2API.UEFIData.Store("ami-123", "uefi-data")
+			awsEnv.EC2API.UEFIData.Store("ami-456", "uefi-data")
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			// Both images require uefi, so only the newest of them is selected
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-456"))
+			Expect(amis[0].Requirements.Get(v1alpha1.LabelInstanceBootMode).Values()).To(ConsistOf(ec2.BootModeTypeUefi))
+			Expect(amis[0].Requirements.Has(v1alpha1.LabelInstanceNitroTPMSupported)).To(BeFalse())
+		})
+		It("should only select images that were registered with uefi data when secure boot is required", func() {
+			nodeClass.Spec.TrustedBoot = &v1beta1.TrustedBoot{SecureBoot: true}
+			awsEnv.EC2API.UEFIData.Store("ami-123", "uefi-data")
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+			// The legacy bios image isn't described, since it can't boot with uefi
+			Expect(awsEnv.EC2API.DescribeImageAttributeBehavior.CalledWithInput.Len()).To(Equal(2))
+		})
+		It("should fail when the uefi data of images can't be described", func() {
+			nodeClass.Spec.TrustedBoot = &v1beta1.TrustedBoot{SecureBoot: true}
+			awsEnv.EC2API.DescribeImageAttributeBehavior.Error.Set(fmt.Errorf("not authorized to perform ec2:DescribeImageAttribute"))
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+		})
+		It("should not describe the uefi data of images when only NitroTPM is required", func() {
+			nodeClass.Spec.TrustedBoot = &v1beta1.TrustedBoot{NitroTPM: true}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.EC2API.DescribeImageAttributeBehavior.CalledWithInput.Len()).To(BeZero())
+		})
+		It("should not skip images when neither NitroTPM nor secure boot are required", func() {
+			nodeClass.Spec.TrustedBoot = &v1beta1.TrustedBoot{}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-456", "ami-789"))
+		})
+		It("should only launch images that require NitroTPM on instance types that support it", func() {
+			nodeClass.Spec.TrustedBoot = &v1beta1.TrustedBoot{NitroTPM: true}
+			supportedInstanceType := &cloudprovider.InstanceType{
+				Name: "m5.large",
+				Requirements: scheduling.NewRequirements(
+					scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, "amd64"),
+					scheduling.NewRequirement(v1alpha1.LabelInstanceBootMode, v1.NodeSelectorOpIn, ec2.BootModeTypeLegacyBios, ec2.BootModeTypeUefi),
+					scheduling.NewRequirement(v1alpha1.LabelInstanceNitroTPMSupported, v1.NodeSelectorOpIn, "true"),
+				),
+			}
+			unsupportedInstanceType := &cloudprovider.InstanceType{
+				Name: "m5.metal",
+				Requirements: scheduling.NewRequirements(
+					scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, "amd64"),
+					scheduling.NewRequirement(v1alpha1.LabelInstanceBootMode, v1.NodeSelectorOpIn, ec2.BootModeTypeLegacyBios, ec2.BootModeTypeUefi),
+					scheduling.NewRequirement(v1alpha1.LabelInstanceNitroTPMSupported, v1.NodeSelectorOpIn, "false"),
+				),
+			}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			mapped := amis.MapToInstanceTypes([]*cloudprovider.InstanceType{supportedInstanceType, unsupportedInstanceType})
+			Expect(mapped).To(HaveLen(1))
+			Expect(mapped["ami-123"]).To(ConsistOf(supportedInstanceType))
+		})
+		It("should skip default amis that don't support the trusted boot", func() {
+			nodeClass.Spec.AMISelectorTerms = nil
+			nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			nodeClass.Spec.TrustedBoot = &v1beta1.TrustedBoot{SecureBoot: true}
+			awsEnv.EC2API.UEFIData.Store("ami-456", "uefi-data")
+			awsEnv.SSMAPI.Parameters = map[string]string{
+				fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version):       "ami-456",
+				fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-gpu/recommended/image_id", version):   "ami-789",
+				fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-arm64/recommended/image_id", version): "ami-000",
+			}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).ToNot(BeEmpty())
+			for _, ami := range amis {
+				Expect(ami.AmiID).To(Equal("ami-456"))
+				Expect(ami.Requirements.Get(v1alpha1.LabelInstanceBootMode).Values()).To(ConsistOf(ec2.BootModeTypeUefi))
+			}
+		})
+	})
+	Context("AMI Rollout", func() {
+		var amd64InstanceType, arm64InstanceType *cloudprovider.InstanceType
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "amd64-ami-id"}}
+			nodeClass.Spec.AMIRollout = &v1beta1.AMIRollout{AMISelectorTerms: []v1beta1.AMISelectorTerm{{ID: "amd64-nvidia-ami-id"}}}
+			amd64InstanceType = &cloudprovider.InstanceType{
+				Name:         "m5.large",
+				Requirements: scheduling.NewRequirements(scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, "amd64")),
+			}
+			arm64InstanceType = &cloudprovider.InstanceType{
+				Name:         "m6g.large",
+				Requirements: scheduling.NewRequirements(scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, "arm64")),
+			}
+		})
+		It("should resolve the candidate AMIs after the current AMIs", func() {
+			nodeClass.Spec.AMIRollout.Weight = 10
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(2))
+			Expect(amis[0].AmiID).To(Equal("amd64-ami-id"))
+			Expect(amis[0].Candidate).To(BeFalse())
+			Expect(amis[1].AmiID).To(Equal("amd64-nvidia-ami-id"))
+			Expect(amis[1].Candidate).To(BeTrue())
+			Expect(amis[1].CandidateWeight).To(BeNumerically("==", 10))
+		})
+		It("should only launch the current AMIs when the weight is 0", func() {
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < 100; i++ {
+				Expect(lo.Keys(amis.MapToInstanceTypes([]*cloudprovider.InstanceType{amd64InstanceType}))).To(ConsistOf("amd64-ami-id"))
+			}
+			Expect(amis.LaunchableIDs(amd64InstanceType)).To(ConsistOf("amd64-ami-id"))
+		})
+		It("should only launch the candidate AMIs when the weight is 100", func() {
+			nodeClass.Spec.AMIRollout.Weight = 100
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < 100; i++ {
+				Expect(lo.Keys(amis.MapToInstanceTypes([]*cloudprovider.InstanceType{amd64InstanceType}))).To(ConsistOf("amd64-nvidia-ami-id"))
+			}
+			Expect(amis.LaunchableIDs(amd64InstanceType)).To(ConsistOf("amd64-nvidia-ami-id"))
+		})
+		It("should distribute launches between the current and the candidate AMIs by weight", func() {
+			nodeClass.Spec.AMIRollout.Weight = 50
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			launches := map[string]int{}
+			for i := 0; i < 1000; i++ {
+				for amiID := range amis.MapToInstanceTypes([]*cloudprovider.InstanceType{amd64InstanceType}) {
+					launches[amiID]++
+				}
+			}
+			Expect(launches["amd64-ami-id"]).To(BeNumerically("~", 500, 100))
+			Expect(launches["amd64-nvidia-ami-id"]).To(BeNumerically("~", 500, 100))
+			Expect(amis.LaunchableIDs(amd64InstanceType)).To(ConsistOf("amd64-ami-id", "amd64-nvidia-ami-id"))
+		})
+		It("should launch the current AMIs for instance types that no candidate AMI is compatible with", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "amd64-ami-id"}, {ID: "arm64-ami-id"}}
+			nodeClass.Spec.AMIRollout.Weight = 100
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			mapped := amis.MapToInstanceTypes([]*cloudprovider.InstanceType{amd64InstanceType, arm64InstanceType})
+			Expect(mapped).To(HaveLen(2))
+			Expect(mapped["amd64-nvidia-ami-id"]).To(ConsistOf(amd64InstanceType))
+			Expect(mapped["arm64-ami-id"]).To(ConsistOf(arm64InstanceType))
+		})
+	})
+	Context("Outposts", func() {
+		var instanceType *cloudprovider.InstanceType
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-regional"}, {ID: "ami-outpost"}}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("golden-regional"),
+					ImageId:      aws.String("ami-regional"),
+					CreationDate: aws.String("2023-08-01T00:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+						{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{SnapshotId: aws.String("snap-regional")}},
+					},
+				},
+				{
+					Name:         aws.String("golden-outpost"),
+					ImageId:      aws.String("ami-outpost"),
+					CreationDate: aws.String("2023-09-01T00:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+						{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{SnapshotId: aws.String("snap-outpost"),
+							OutpostArn: aws.String("arn:aws:outposts:us-west-2:111122223333:outpost/op-0123456789abcdef0")}},
+					},
+				},
+			}})
+			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				{SubnetId: aws.String("subnet-regional"), AvailabilityZone: aws.String("test-zone-1b")},
+				{SubnetId: aws.String("subnet-outpost"), AvailabilityZone: aws.String("test-zone-1a"),
+					OutpostArn: aws.String("arn:aws:outposts:us-west-2:111122223333:outpost/op-0123456789abcdef0")},
+			}})
+			instanceType = &cloudprovider.InstanceType{
+				Name:         "m5.large",
+				Requirements: scheduling.NewRequirements(scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, "amd64")),
+				Offerings: []cloudprovider.Offering{
+					{CapacityType: v1alpha5.CapacityTypeOnDemand, Zone: "test-zone-1a", Available: true},
+					{CapacityType: v1alpha5.CapacityTypeOnDemand, Zone: "test-zone-1b", Available: true},
+				},
+			}
+		})
+		It("should restrict AMIs on an Outpost to the zones of its subnets", func() {
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(2))
+			Expect(amis[0].AmiID).To(Equal("ami-outpost"))
+			Expect(amis[0].Zones).To(ConsistOf("test-zone-1a"))
+			Expect(amis[0].OutpostARN).To(Equal("arn:aws:outposts:us-west-2:111122223333:outpost/op-0123456789abcdef0"))
+			Expect(amis[1].AmiID).To(Equal("ami-regional"))
+			Expect(amis[1].Zones).To(BeEmpty())
+			Expect(amis[1].OutpostARN).To(BeEmpty())
+		})
+		It("should ignore AMIs on an Outpost without subnets", func() {
+			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				{SubnetId: aws.String("subnet-regional"), AvailabilityZone: aws.String("test-zone-1b")},
+			}})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-regional"))
+		})
+		It("should map the offerings in the zones of an Outpost to its AMI and the other offerings to the next AMI", func() {
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			mapped := amis.MapToInstanceTypes([]*cloudprovider.InstanceType{instanceType})
+			Expect(mapped).To(HaveLen(2))
+			Expect(mapped["ami-outpost"]).To(HaveLen(1))
+			Expect(lo.Map(mapped["ami-outpost"][0].Offerings, func(o cloudprovider.Offering, _ int) string { return o.Zone })).To(ConsistOf("test-zone-1a"))
+			Expect(mapped["ami-regional"]).To(HaveLen(1))
+			Expect(lo.Map(mapped["ami-regional"][0].Offerings, func(o cloudprovider.Offering, _ int) string { return o.Zone })).To(ConsistOf("test-zone-1b"))
+		})
+		It("should map every offering to a regional AMI that is newer than the AMI on the Outpost", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{Name: aws.String("golden-regional"), ImageId: aws.String("ami-regional"), CreationDate: aws.String("2023-10-01T00:00:00Z"), Architecture: aws.String("x86_64")},
+				{Name: aws.String("golden-outpost"), ImageId: aws.String("ami-outpost"), CreationDate: aws.String("2023-09-01T00:00:00Z"), Architecture: aws.String("x86_64"),
+					BlockDeviceMappings: []*ec2.BlockDeviceMapping{{DeviceName: aws.String("/dev/xvda"),
+						Ebs: &ec2.EbsBlockDevice{OutpostArn: aws.String("arn:aws:outposts:us-west-2:111122223333:outpost/op-0123456789abcdef0")}}}},
+			}})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			mapped := amis.MapToInstanceTypes([]*cloudprovider.InstanceType{instanceType})
+			Expect(mapped).To(HaveLen(1))
+			Expect(mapped["ami-regional"]).To(ConsistOf(instanceType))
+		})
+	})
+	Context("Denied AMIs", func() {
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("golden-1.0"),
+					ImageId:      aws.String("ami-123"),
+					OwnerId:      aws.String("111122223333"),
+					CreationDate: aws.String("2022-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+				{
+					Name:         aws.String("golden-1.1-rc1"),
+					ImageId:      aws.String("ami-456"),
+					OwnerId:      aws.String("444455556666"),
+					CreationDate: aws.String("2023-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+			}})
+		})
+		DescribeTable("should select the newest image that isn't denied",
+			func(options test.SettingOptions) {
+				deniedCtx := settings.ToContext(ctx, test.Settings(options))
+				amis, err := awsEnv.AMIProvider.Get(deniedCtx, nodeClass, &amifamily.Options{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(amis).To(HaveLen(1))
+				Expect(amis[0].AmiID).To(Equal("ami-123"))
+			},
+			Entry("by id", test.SettingOptions{DeniedAMIIDs: []string{"ami-456"}}),
+			Entry("by name", test.SettingOptions{DeniedAMINames: []string{"*-rc*"}}),
+			Entry("by owner", test.SettingOptions{DeniedAMIOwners: []string{"444455556666"}}),
+		)
+		It("should not select any image when all of them are denied", func() {
+			deniedCtx := settings.ToContext(ctx, test.Settings(test.SettingOptions{DeniedAMINames: []string{"golden-*"}}))
+			amis, err := awsEnv.AMIProvider.Get(deniedCtx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(BeEmpty())
+		})
+		It("should not match names that only partially match a denied name", func() {
+			deniedCtx := settings.ToContext(ctx, test.Settings(test.SettingOptions{DeniedAMINames: []string{"golden"}}))
+			amis, err := awsEnv.AMIProvider.Get(deniedCtx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-456"))
+		})
+		It("should apply changes to the denied images without waiting for the cache to expire", func() {
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-456"))
+
+			deniedCtx := settings.ToContext(ctx, test.Settings(test.SettingOptions{DeniedAMIIDs: []string{"ami-456"}}))
+			amis, err = awsEnv.AMIProvider.Get(deniedCtx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+		})
+	})
+	Context("SSM Parameters", func() {
+		BeforeEach(func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-golden-1"),
+					ImageId:      aws.String("ami-123"),
+					CreationDate: aws.String("2022-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("pipeline"), Value: aws.String("golden")}},
+				},
+				{
+					Name:         aws.String("ami-golden-2"),
+					ImageId:      aws.String("ami-456"),
+					CreationDate: aws.String("2023-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("pipeline"), Value: aws.String("golden")}},
+				},
+			}})
+			awsEnv.SSMAPI.Parameters = map[string]string{"/golden/al2/x86_64": "ami-123"}
+		})
+		It("should select the ami that an ssm parameter in the selector terms is set to", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/x86_64"}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+			Expect(amis[0].Name).To(Equal("ami-golden-1"))
+		})
+		It("should only select the ami if it matches the other fields of the term", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/x86_64", Tags: map[string]string{"pipeline": "other"}}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(BeEmpty())
+		})
+		It("should fail when the ssm parameter can't be resolved", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/arm64"}}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("/golden/al2/arm64"))
+		})
+		It("should select the ami that the ssm parameter is changed to once the cached amis expire", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/x86_64"}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+
+			awsEnv.SSMAPI.Parameters = map[string]string{"/golden/al2/x86_64": "ami-456"}
+			amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+
+			awsEnv.EC2Cache.Flush()
+			amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-456"))
+		})
+		It("should cache the amis of different ssm parameters separately", func() {
+			awsEnv.SSMAPI.Parameters["/golden/al2/latest"] = "ami-456"
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/x86_64"}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/latest"}}
+			amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis[0].AmiID).To(Equal("ami-456"))
+		})
+	})
+	Context("Metrics", func() {
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-amd64"),
+					ImageId:      aws.String("ami-123"),
+					CreationDate: aws.String(time.Now().Add(-2 * time.Hour).Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+				{
+					Name:         aws.String("ami-arm64"),
+					ImageId:      aws.String("ami-456"),
+					CreationDate: aws.String(time.Now().Add(-24 * time.Hour).Format(time.RFC3339)),
+					Architecture: aws.String("arm64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+			}})
+		})
+		It("should record the number of resolved amis and the age of the newest", func() {
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			metric, found := FindMetricWithLabelValues("karpenter_cloudprovider_amis_resolved", map[string]string{"nodeclass": nodeClass.Name, "nodeclass_kind": "NodeClass"})
+			Expect(found).To(BeTrue())
+			Expect(metric.GetGauge().GetValue()).To(BeNumerically("==", 2))
+			metric, found = FindMetricWithLabelValues("karpenter_cloudprovider_amis_resolved_none", map[string]string{"nodeclass": nodeClass.Name, "nodeclass_kind": "NodeClass"})
+			Expect(found).To(BeTrue())
+			Expect(metric.GetGauge().GetValue()).To(BeNumerically("==", 0))
+			metric, found = FindMetricWithLabelValues("karpenter_cloudprovider_newest_ami_age_seconds", map[string]string{"nodeclass": nodeClass.Name, "nodeclass_kind": "NodeClass"})
+			Expect(found).To(BeTrue())
+			Expect(metric.GetGauge().GetValue()).To(BeNumerically("~", (2 * time.Hour).Seconds(), 60))
+		})
+		It("should delete the metrics of the node class once it's deleted", func() {
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			amifamily.DeleteNodeClassMetrics(nodeclassutil.Key{Name: nodeClass.Name, IsNodeTemplate: true})
+			_, found := FindMetricWithLabelValues("karpenter_cloudprovider_amis_resolved", map[string]string{"nodeclass": nodeClass.Name, "nodeclass_kind": "NodeClass"})
+			Expect(found).To(BeTrue())
+			amifamily.DeleteNodeClassMetrics(nodeclassutil.Key{Name: nodeClass.Name})
+			for _, name := range []string{"karpenter_cloudprovider_amis_resolved", "karpenter_cloudprovider_amis_resolved_none", "karpenter_cloudprovider_newest_ami_age_seconds"} {
+				_, found = FindMetricWithLabelValues(name, map[string]string{"nodeclass": nodeClass.Name})
+				Expect(found).To(BeFalse())
+			}
+		})
+		It("should record when no amis match", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "baz"}}}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(BeEmpty())
+			metric, found := FindMetricWithLabelValues("karpenter_cloudprovider_amis_resolved_none", map[string]string{"nodeclass": nodeClass.Name, "nodeclass_kind": "NodeClass"})
+			Expect(found).To(BeTrue())
+			Expect(metric.GetGauge().GetValue()).To(BeNumerically("==", 1))
+			_, found = FindMetricWithLabelValues("karpenter_cloudprovider_newest_ami_age_seconds", map[string]string{"nodeclass": nodeClass.Name, "nodeclass_kind": "NodeClass"})
+			Expect(found).To(BeFalse())
+		})
+		It("should record the duration and the errors of the requests that resolve amis", func() {
+			requests := func() uint64 {
+				metric, found := FindMetricWithLabelValues("karpenter_cloudprovider_ami_request_duration_seconds", map[string]string{"api": "DescribeImages"})
+				return lo.Ternary(found, metric.GetHistogram().GetSampleCount(), 0)
+			}
+			failures := func() float64 {
+				metric, found := FindMetricWithLabelValues("karpenter_cloudprovider_ami_request_errors_total", map[string]string{"api": "DescribeImages", "error_code": "RequestLimitExceeded"})
+				return lo.Ternary(found, metric.GetCounter().GetValue(), 0)
+			}
+			requestsBefore, failuresBefore := requests(), failures()
+			awsEnv.EC2API.NextError.Set(awserr.New("RequestLimitExceeded", "rate exceeded", nil))
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			_, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requests()).To(BeNumerically("==", requestsBefore+2))
+			Expect(failures()).To(BeNumerically("==", failuresBefore+1))
+		})
+	})
+	Context("SSM Failures", func() {
+		BeforeEach(func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-golden-1"),
+					ImageId:      aws.String("ami-123"),
+					CreationDate: aws.String("2022-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+				},
+			}})
+			awsEnv.SSMAPI.Parameters = map[string]string{"/golden/al2/x86_64": "ami-123"}
+		})
+		It("should back off from querying a parameter that failed to resolve", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/arm64"}}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			_, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("backing off"))
+			Expect(awsEnv.SSMAPI.CalledWithInput.Len()).To(Equal(1))
+		})
+		It("should query the parameter again once the failures are reset", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/arm64"}}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+
+			awsEnv.SSMAPI.Parameters["/golden/al2/arm64"] = "ami-123"
+			awsEnv.AMIProvider.Reset()
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(awsEnv.SSMAPI.CalledWithInput.Len()).To(Equal(2))
+		})
+		It("should not back off from parameters that resolve", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/x86_64"}}
+			for i := 0; i < 2; i++ {
+				_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+				Expect(err).ToNot(HaveOccurred())
+				awsEnv.EC2Cache.Flush()
+			}
+			Expect(awsEnv.SSMAPI.CalledWithInput.Len()).To(Equal(2))
+		})
+		It("should back off from the default AMIs that failed to resolve", func() {
+			nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			awsEnv.SSMAPI.Parameters = map[string]string{
+				fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version): amd64AMI,
+			}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			queried := awsEnv.SSMAPI.CalledWithInput.Len()
+
+			awsEnv.EC2Cache.Flush()
+			_, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			// Only the parameter that resolved is queried again
+			Expect(awsEnv.SSMAPI.CalledWithInput.Len()).To(Equal(queried + 1))
+		})
+		It("should count the failures of each parameter", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/gpu"}}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			metric, found := FindMetricWithLabelValues("karpenter_cloudprovider_ssm_parameter_resolution_failures", map[string]string{
+				"parameter":  "/golden/al2/gpu",
+				"error_code": ssm.ErrCodeParameterNotFound,
+			})
+			Expect(found).To(BeTrue())
+			Expect(metric.GetCounter().GetValue()).To(BeNumerically("==", 1))
+		})
+	})
+	Context("Image Builder", func() {
+		pipelineARN := "arn:aws:imagebuilder:us-west-2:123456789012:image-pipeline/golden"
+		recipeARN := "arn:aws:imagebuilder:us-west-2:123456789012:image-recipe/golden/1.0.0"
+		imageSummary := func(date, status string, amis map[string]string) *imagebuilder.ImageSummary {
+			return &imagebuilder.ImageSummary{
+				Arn:         aws.String(fmt.Sprintf("arn:aws:imagebuilder:us-west-2:123456789012:image/golden/1.0.0/%s", date)),
+				DateCreated: aws.String(date),
+				State:       &imagebuilder.ImageState{Status: aws.String(status)},
+				OutputResources: &imagebuilder.OutputResources{Amis: lo.MapToSlice(amis, func(region, id string) *imagebuilder.Ami {
+					return &imagebuilder.Ami{Region: aws.String(region), Image: aws.String(id)}
+				})},
+			}
+		}
+		BeforeEach(func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-golden-1"),
+					ImageId:      aws.String("ami-123"),
+					CreationDate: aws.String("2022-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+				},
+				{
+					Name:         aws.String("ami-golden-2"),
+					ImageId:      aws.String("ami-456"),
+					CreationDate: aws.String("2023-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+				},
+			}})
+		})
+		It("should select the ami of the latest available image built by a pipeline", func() {
+			awsEnv.ImageBuilderAPI.ImageSummaries = map[string][]*imagebuilder.ImageSummary{
+				pipelineARN: {
+					imageSummary("2022-01-01T12:00:00Z", imagebuilder.ImageStatusAvailable, map[string]string{"us-west-2": "ami-456"}),
+					imageSummary("2022-06-01T12:00:00Z", imagebuilder.ImageStatusAvailable, map[string]string{"us-west-2": "ami-123"}),
+					imageSummary("2023-01-01T12:00:00Z", imagebuilder.ImageStatusFailed, nil),
+				},
+			}
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ImageBuilderARN: pipelineARN}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+		})
+		It("should select the ami of the latest available image built from a recipe", func() {
+			awsEnv.ImageBuilderAPI.ImageSummaries = map[string][]*imagebuilder.ImageSummary{
+				"arn:aws:imagebuilder:us-west-2:123456789012:image/golden/1.0.0": {
+					imageSummary("2023-01-01T12:00:00Z", imagebuilder.ImageStatusAvailable, map[string]string{"us-west-2": "ami-456"}),
+				},
+			}
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ImageBuilderARN: recipeARN}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-456"))
+		})
+		It("should select the ami that the image was distributed to the cluster's region with", func() {
+			awsEnv.ImageBuilderAPI.ImageSummaries = map[string][]*imagebuilder.ImageSummary{
+				pipelineARN: {
+					imageSummary("2023-01-01T12:00:00Z", imagebuilder.ImageStatusAvailable, map[string]string{"us-east-1": "ami-123", "us-west-2": "ami-456"}),
+				},
+			}
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ImageBuilderARN: pipelineARN}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-456"))
+		})
+		It("should fail when the pipeline hasn't built an available image", func() {
+			awsEnv.ImageBuilderAPI.ImageSummaries = map[string][]*imagebuilder.ImageSummary{
+				pipelineARN: {imageSummary("2023-01-01T12:00:00Z", imagebuilder.ImageStatusBuilding, nil)},
+			}
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ImageBuilderARN: pipelineARN}}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(pipelineARN))
+		})
+		It("should fail when the image wasn't distributed to the cluster's region", func() {
+			awsEnv.ImageBuilderAPI.ImageSummaries = map[string][]*imagebuilder.ImageSummary{
+				pipelineARN: {imageSummary("2023-01-01T12:00:00Z", imagebuilder.ImageStatusAvailable, map[string]string{"us-east-1": "ami-123"})},
+			}
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ImageBuilderARN: pipelineARN}}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("us-west-2"))
+		})
+	})
+	Context("Assumed Roles", func() {
+		roleARN := "arn:aws:iam::111122223333:role/golden-images"
+		BeforeEach(func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-local"),
+					ImageId:      aws.String("ami-123"),
+					CreationDate: aws.String("2023-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("local"), Value: aws.String("true")}},
+				},
+			}})
+			// Tags of shared images are only visible to the account that owns them, so the golden image is only tagged as
+			// the role describes it
+			awsEnv.AssumedRoleEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-golden"),
+					ImageId:      aws.String("ami-456"),
+					CreationDate: aws.String("2023-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("golden"), Value: aws.String("true")}},
+				},
+			}})
+		})
+		It("should select the images that the role describes", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"golden": "true"}, RoleARN: roleARN}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-456"))
+			Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(BeZero())
+			input := awsEnv.AssumedRoleEC2API.CalledWithDescribeImagesInput.Pop()
+			Expect(aws.StringValueSlice(input.Owners)).To(ConsistOf("self", "amazon"))
+		})
+		It("should only describe the images of the terms that set a role as the role", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{Tags: map[string]string{"golden": "true"}, RoleARN: roleARN},
+				{Tags: map[string]string{"local": "true"}},
+			}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(Equal(1))
+			Expect(awsEnv.AssumedRoleEC2API.CalledWithDescribeImagesInput.Len()).To(Equal(1))
+		})
+		It("should describe the image of an id as the role whoever owns it", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-456", RoleARN: roleARN}}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-456"))
+			Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(BeZero())
+			input := awsEnv.AssumedRoleEC2API.CalledWithDescribeImagesInput.Pop()
+			Expect(input.Owners).To(BeEmpty())
+		})
+		It("should fail with the role when the images can't be described as the role", func() {
+			awsEnv.AssumedRoleEC2API.NextError.Set(fmt.Errorf("not authorized to perform sts:AssumeRole"))
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"golden": "true"}, RoleARN: roleARN}}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(roleARN))
+		})
+		It("should cache the images of terms with different roles separately", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"golden": "true"}, RoleARN: roleARN}}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			nodeClass.Spec.AMISelectorTerms[0].RoleARN = "arn:aws:iam::444455556666:role/golden-images"
+			_, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.AssumedRoleEC2API.CalledWithDescribeImagesInput.Len()).To(Equal(2))
+		})
+	})
+	Context("Invalidation", func() {
+		var other *v1beta1.NodeClass
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			other = test.NodeClass()
+			other.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Name: amd64AMI}}
+			ExpectApplied(ctx, env.Client, nodeClass, other)
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = awsEnv.AMIProvider.Get(ctx, other, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(2))
+		})
+		It("should only invalidate the amis of the node class", func() {
+			Expect(awsEnv.AMIProvider.InvalidateNodeClass(ctx, nodeclassutil.Key{Name: nodeClass.Name})).To(Succeed())
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(1))
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(Equal(3))
+		})
+		It("should invalidate the amis of a node template", func() {
+			nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AMISelector: map[string]string{"foo": "bar"}})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeclassutil.New(nodeTemplate), &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.AMIProvider.InvalidateNodeClass(ctx, nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true})).To(Succeed())
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(1))
+		})
+		It("should invalidate the amis of every node class without a name", func() {
+			Expect(awsEnv.AMIProvider.InvalidateNodeClass(ctx, nodeclassutil.Key{})).To(Succeed())
+			Expect(awsEnv.EC2Cache.ItemCount()).To(BeZero())
+		})
+		It("should fail for node classes that don't exist", func() {
+			err := awsEnv.AMIProvider.InvalidateNodeClass(ctx, nodeclassutil.Key{Name: "missing"})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(awsEnv.EC2Cache.ItemCount()).To(Equal(2))
+		})
+	})
+	Context("Airgapped", func() {
+		var airgappedCtx context.Context
+		BeforeEach(func() {
+			airgappedCtx = settings.ToContext(ctx, test.Settings(test.SettingOptions{Airgapped: lo.ToPtr(true)}))
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String("ami-golden-1"),
+					ImageId:      aws.String("ami-123"),
+					CreationDate: aws.String("2022-01-01T12:00:00Z"),
+					Architecture: aws.String("x86_64"),
+				},
+			}})
+			awsEnv.SSMAPI.Parameters = map[string]string{
+				"/golden/al2/x86_64": "ami-123",
+				fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version): "ami-123",
+			}
+		})
+		It("should fail to resolve the default AMIs", func() {
+			nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			_, err := awsEnv.AMIProvider.Get(airgappedCtx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("airgapped"))
+		})
+		It("should fail to resolve public ssm parameters in the selector terms", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version)}}
+			_, err := awsEnv.AMIProvider.Get(airgappedCtx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("airgapped"))
+		})
+		It("should resolve private ssm parameters in the selector terms", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/golden/al2/x86_64"}}
+			amis, err := awsEnv.AMIProvider.Get(airgappedCtx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+		})
+		It("should resolve the AMIs of the selector terms", func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-123"}}
+			amis, err := awsEnv.AMIProvider.Get(airgappedCtx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-123"))
+		})
+	})
+	Context("Deprecation", func() {
+		var images []*ec2.Image
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			images = []*ec2.Image{
+				{
+					Name:            aws.String("ami-deprecated"),
+					ImageId:         aws.String("ami-deprecated-id"),
+					CreationDate:    aws.String(time.Now().Add(-time.Hour).Format(time.RFC3339)),
+					DeprecationTime: aws.String(time.Now().Add(-time.Minute).Format(time.RFC3339)),
+					Architecture:    aws.String("x86_64"),
+					Tags:            []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+				{
+					Name:         aws.String("ami-current"),
+					ImageId:      aws.String("ami-current-id"),
+					CreationDate: aws.String(time.Now().Add(-24 * time.Hour).Format(time.RFC3339)),
+					// Images that will only be deprecated in the future aren't deprecated yet
+					DeprecationTime: aws.String(time.Now().Add(time.Hour).Format(time.RFC3339)),
+					Architecture:    aws.String("x86_64"),
+					Tags:            []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+				},
+			}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: images})
+		})
+		It("should select the newest image whether or not it's deprecated by default", func() {
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-deprecated-id"))
+			Expect(amis[0].DeprecationTime).To(Equal(aws.StringValue(images[0].DeprecationTime)))
+		})
+		It("should select an image that isn't deprecated over a newer deprecated image when deprioritizing", func() {
+			nodeClass.Spec.AMIDeprecationPolicy = aws.String(v1beta1.AMIDeprecationPolicyDeprioritize)
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-current-id"))
+		})
+		It("should select a deprecated image when deprioritizing if no other image matches its requirements", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: images[:1]})
+			nodeClass.Spec.AMIDeprecationPolicy = aws.String(v1beta1.AMIDeprecationPolicyDeprioritize)
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-deprecated-id"))
+		})
+		It("should order deprecated images after the ones that aren't when deprioritizing", func() {
+			images[0].Architecture = aws.String("arm64")
+			nodeClass.Spec.AMIDeprecationPolicy = aws.String(v1beta1.AMIDeprecationPolicyDeprioritize)
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(Equal([]string{"ami-current-id", "ami-deprecated-id"}))
+		})
+		It("should never select deprecated images when excluding them", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: images[:1]})
+			nodeClass.Spec.AMIDeprecationPolicy = aws.String(v1beta1.AMIDeprecationPolicyExclude)
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(BeEmpty())
+		})
+		It("should deprecate images once their deprecation time has passed", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: images[1:]})
+			nodeClass.Spec.AMIDeprecationPolicy = aws.String(v1beta1.AMIDeprecationPolicyExclude)
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-current-id"))
+
+			awsEnv.Clock.Step(2 * time.Hour)
+			awsEnv.EC2Cache.Flush()
+			amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(BeEmpty())
+		})
+		It("should cache the images selected under each policy separately", func() {
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis[0].AmiID).To(Equal("ami-deprecated-id"))
+			nodeClass.Spec.AMIDeprecationPolicy = aws.String(v1beta1.AMIDeprecationPolicyExclude)
+			amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-current-id"))
+		})
+	})
 })
 
 func ExpectConsistsOfFiltersAndOwners(expected, actual []amifamily.FiltersAndOwners) {
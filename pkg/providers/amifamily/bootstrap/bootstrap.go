@@ -27,6 +27,8 @@ import (
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	"github.com/aws/karpenter-core/pkg/utils/resources"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 )
 
 // Options is the node bootstrapping parameters passed from Karpenter to the provisioning node
@@ -40,6 +42,9 @@ type Options struct {
 	AWSENILimitedPodDensity bool
 	ContainerRuntime        *string
 	CustomUserData          *string
+	CloudWatchAgent         *v1beta1.CloudWatchAgent
+	DomainJoin              *v1beta1.DomainJoin
+	Neuron                  *v1beta1.Neuron
 }
 
 func (o Options) kubeletExtraArgs() (args []string) {
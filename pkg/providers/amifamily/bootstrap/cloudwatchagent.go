@@ -0,0 +1,131 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+)
+
+const (
+	cloudWatchAgentConfigPath        = "/opt/aws/amazon-cloudwatch-agent/etc/karpenter.json"
+	cloudWatchAgentDebURLFormat      = "https://s3.amazonaws.com/amazoncloudwatch-agent/ubuntu/%s/latest/amazon-cloudwatch-agent.deb"
+	cloudWatchAgentWindowsMSIURL     = "https://s3.amazonaws.com/amazoncloudwatch-agent/windows/amd64/latest/amazon-cloudwatch-agent.msi"
+	cloudWatchAgentWindowsConfigPath = "$env:ProgramData\\Amazon\\AmazonCloudWatchAgent\\karpenter.json"
+	fluentBitConfigPath              = "/etc/fluent-bit/fluent-bit.conf"
+	// fluentBitVersion is the version of fluent-bit that is installed from the fluent-bit package repository
+	fluentBitVersion    = "2.1.10"
+	fluentBitKeyURL     = "https://packages.fluentbit.io/fluentbit.key"
+	fluentBitPackageURL = "https://packages.fluentbit.io"
+	// fluentBitKeyFingerprint pins the key that signs the fluent-bit packages, so that packages are only installed if
+	// they are signed by the fluent-bit maintainers
+	fluentBitKeyFingerprint = "C3C0A28534B9293EAF51FABD9F9DDC083888C1CD"
+)
+
+// cloudWatchAgentScript returns a shell script that installs and starts the configured agent on Linux AMI families.
+// The script is run after the EKS bootstrap script so that a failure to install the agent never blocks the node
+// from joining the cluster.
+func cloudWatchAgentScript(agent *v1beta1.CloudWatchAgent) string {
+	if agent == nil {
+		return ""
+	}
+	var script bytes.Buffer
+	script.WriteString("#!/bin/bash -x\n")
+	script.WriteString("exec > >(tee /var/log/karpenter-cloudwatch-agent.log|logger -t karpenter-cloudwatch-agent -s 2>/dev/console) 2>&1\n")
+	switch lo.FromPtrOr(agent.Type, v1beta1.CloudWatchAgentTypeCloudWatchAgent) {
+	case v1beta1.CloudWatchAgentTypeFluentBit:
+		script.WriteString(fluentBitInstallScript())
+		script.WriteString("mkdir -p /etc/fluent-bit\n")
+		if agent.Config != nil {
+			script.WriteString(heredoc(fluentBitConfigPath, *agent.Config))
+		} else {
+			script.WriteString("TOKEN=$(curl -sS -X PUT 'http://169.254.169.254/latest/api/token' -H 'X-aws-ec2-metadata-token-ttl-seconds: 60')\n")
+			script.WriteString("REGION=$(curl -sS -H \"X-aws-ec2-metadata-token: $TOKEN\" http://169.254.169.254/latest/meta-data/placement/region)\n")
+			script.WriteString(fmt.Sprintf("aws ssm get-parameter --region \"$REGION\" --with-decryption --name %s --query Parameter.Value --output text > %s\n",
+				quoteShell(lo.FromPtr(agent.ConfigSSMParameter)), fluentBitConfigPath))
+		}
+		script.WriteString("systemctl enable --now fluent-bit\n")
+	default:
+		script.WriteString("if command -v yum >/dev/null 2>&1; then\n")
+		script.WriteString("  yum install -y amazon-cloudwatch-agent\n")
+		script.WriteString("else\n")
+		script.WriteString(fmt.Sprintf("  curl -sSfL -o /tmp/amazon-cloudwatch-agent.deb %s\n", fmt.Sprintf(cloudWatchAgentDebURLFormat, "$(dpkg --print-architecture)")))
+		script.WriteString("  dpkg -i -E /tmp/amazon-cloudwatch-agent.deb\n")
+		script.WriteString("fi\n")
+		source := fmt.Sprintf("ssm:%s", lo.FromPtr(agent.ConfigSSMParameter))
+		if agent.Config != nil {
+			script.WriteString(heredoc(cloudWatchAgentConfigPath, *agent.Config))
+			source = fmt.Sprintf("file:%s", cloudWatchAgentConfigPath)
+		}
+		script.WriteString(fmt.Sprintf("/opt/aws/amazon-cloudwatch-agent/bin/amazon-cloudwatch-agent-ctl -a fetch-config -m ec2 -s -c %s\n", quoteShell(source)))
+	}
+	return script.String()
+}
+
+// cloudWatchAgentPowerShell returns the PowerShell commands that install and start the CloudWatch agent on Windows
+// AMI families. Only the CloudWatch agent is supported on Windows.
+func cloudWatchAgentPowerShell(agent *v1beta1.CloudWatchAgent) string {
+	if agent == nil {
+		return ""
+	}
+	var script bytes.Buffer
+	script.WriteString(fmt.Sprintf("Invoke-WebRequest -UseBasicParsing -Uri '%s' -OutFile \"$env:TEMP\\amazon-cloudwatch-agent.msi\"\n", cloudWatchAgentWindowsMSIURL))
+	script.WriteString("Start-Process msiexec.exe -Wait -ArgumentList '/i', \"$env:TEMP\\amazon-cloudwatch-agent.msi\", '/qn'\n")
+	source := quotePowerShell(fmt.Sprintf("ssm:%s", lo.FromPtr(agent.ConfigSSMParameter)))
+	if agent.Config != nil {
+		script.WriteString(fmt.Sprintf("Set-Content -Path \"%s\" -Value %s\n", cloudWatchAgentWindowsConfigPath, quotePowerShell(*agent.Config)))
+		source = fmt.Sprintf("\"file:%s\"", cloudWatchAgentWindowsConfigPath)
+	}
+	script.WriteString(fmt.Sprintf("& \"$env:ProgramFiles\\Amazon\\AmazonCloudWatchAgent\\amazon-cloudwatch-agent-ctl.ps1\" -a fetch-config -m ec2 -s -c %s\n", source))
+	return script.String()
+}
+
+// fluentBitInstallScript installs the pinned fluent-bit version from the fluent-bit package repository. The package
+// manager verifies the packages against the repository's signing key, which is only trusted if it has the pinned
+// fingerprint.
+func fluentBitInstallScript() string {
+	var script bytes.Buffer
+	script.WriteString(fmt.Sprintf("curl -sSfL -o /tmp/fluentbit.key %s\n", fluentBitKeyURL))
+	script.WriteString(fmt.Sprintf("gpg --show-keys --with-colons /tmp/fluentbit.key | grep -q '^fpr:::::::::%s:$' || exit 1\n", fluentBitKeyFingerprint))
+	script.WriteString("if command -v yum >/dev/null 2>&1; then\n")
+	script.WriteString("  rpm --import /tmp/fluentbit.key\n")
+	script.WriteString("  cat <<KARPENTER_EOF > /etc/yum.repos.d/fluent-bit.repo\n")
+	script.WriteString("[fluent-bit]\nname=Fluent Bit\n")
+	script.WriteString(fmt.Sprintf("baseurl=%s/amazonlinux/$(. /etc/os-release; echo $VERSION_ID)/\\$basearch/\n", fluentBitPackageURL))
+	script.WriteString("gpgcheck=1\nrepo_gpgcheck=1\ngpgkey=file:///tmp/fluentbit.key\nenabled=1\n")
+	script.WriteString("KARPENTER_EOF\n")
+	script.WriteString(fmt.Sprintf("  yum install -y fluent-bit-%s\n", fluentBitVersion))
+	script.WriteString("else\n")
+	script.WriteString("  gpg --dearmor < /tmp/fluentbit.key > /usr/share/keyrings/fluentbit-keyring.gpg\n")
+	script.WriteString(fmt.Sprintf("  echo \"deb [signed-by=/usr/share/keyrings/fluentbit-keyring.gpg] %s/ubuntu/$(lsb_release -cs) $(lsb_release -cs) main\" > /etc/apt/sources.list.d/fluent-bit.list\n", fluentBitPackageURL))
+	script.WriteString(fmt.Sprintf("  apt-get update && apt-get install -y fluent-bit=%s\n", fluentBitVersion))
+	script.WriteString("fi\n")
+	return script.String()
+}
+
+// quoteShell quotes s as a single shell word without any shell expansion
+func quoteShell(s string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(s, "'", `'\''`))
+}
+
+// heredoc writes contents to path without any shell expansion
+func heredoc(path, contents string) string {
+	return fmt.Sprintf("cat <<'KARPENTER_EOF' > %s\n%s\nKARPENTER_EOF\n", path, contents)
+}
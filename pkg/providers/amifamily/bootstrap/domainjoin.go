@@ -0,0 +1,80 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+)
+
+const (
+	joinDirectoryServiceDomainDocument = "AWS-JoinDirectoryServiceDomain"
+	// domainJoinAttempts bounds how long the node waits for the SSM agent to register before the join command is accepted
+	domainJoinAttempts = 30
+)
+
+// domainJoinPowerShell returns the PowerShell commands that join a Windows node to an Active Directory domain. The
+// join is run through SSM, which reboots the node once it completes, so these commands must be the last ones in the
+// user data.
+func domainJoinPowerShell(domainJoin *v1beta1.DomainJoin) string {
+	if domainJoin == nil {
+		return ""
+	}
+	var script bytes.Buffer
+	if lo.FromPtr(domainJoin.GMSA) {
+		script.WriteString("Install-WindowsFeature -Name RSAT-AD-PowerShell\n")
+	}
+	document := lo.FromPtr(domainJoin.SSMDocument)
+	parameters := lo.MapValues(domainJoin.SSMDocumentParameters, func(v string, _ string) []string { return []string{v} })
+	if domainJoin.DirectoryID != nil {
+		document = joinDirectoryServiceDomainDocument
+		parameters = map[string][]string{
+			"directoryId":   {*domainJoin.DirectoryID},
+			"directoryName": {lo.FromPtr(domainJoin.DirectoryName)},
+		}
+		if len(domainJoin.DNSIPAddresses) > 0 {
+			parameters["dnsIpAddresses"] = domainJoin.DNSIPAddresses
+		}
+	}
+	script.WriteString("$IMDSToken = Invoke-RestMethod -Method PUT -Uri 'http://169.254.169.254/latest/api/token' -Headers @{'X-aws-ec2-metadata-token-ttl-seconds' = '60'}\n")
+	script.WriteString("$InstanceID = Invoke-RestMethod -Uri 'http://169.254.169.254/latest/meta-data/instance-id' -Headers @{'X-aws-ec2-metadata-token' = $IMDSToken}\n")
+	script.WriteString("$Region = Invoke-RestMethod -Uri 'http://169.254.169.254/latest/meta-data/placement/region' -Headers @{'X-aws-ec2-metadata-token' = $IMDSToken}\n")
+	script.WriteString(fmt.Sprintf("for ($i = 0; $i -lt %d; $i++) {\n", domainJoinAttempts))
+	script.WriteString(fmt.Sprintf("  try { Send-SSMCommand -Region $Region -InstanceId $InstanceID -DocumentName %s -Parameter %s -ErrorAction Stop | Out-Null; break }\n",
+		quotePowerShell(document), powerShellHashtable(parameters)))
+	script.WriteString("  catch { Start-Sleep -Seconds 10 }\n")
+	script.WriteString("}\n")
+	return script.String()
+}
+
+// powerShellHashtable renders parameters as a PowerShell hashtable literal with its keys sorted so that the
+// generated user data is stable
+func powerShellHashtable(parameters map[string][]string) string {
+	keys := lo.Keys(parameters)
+	sort.Strings(keys)
+	return fmt.Sprintf("@{%s}", strings.Join(lo.Map(keys, func(k string, _ int) string {
+		return fmt.Sprintf("%s = @(%s)", quotePowerShell(k), strings.Join(lo.Map(parameters[k], func(v string, _ int) string { return quotePowerShell(v) }), ", "))
+	}), "; "))
+}
+
+func quotePowerShell(s string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(s, "'", "''"))
+}
@@ -42,7 +42,7 @@ const (
 )
 
 func (e EKS) Script() (string, error) {
-	userData, err := e.mergeCustomUserData(lo.Compact([]string{lo.FromPtr(e.CustomUserData), e.eksBootstrapScript()})...)
+	userData, err := mergeCustomUserData(lo.Compact([]string{lo.FromPtr(e.CustomUserData), neuronScript(e.Neuron), e.eksBootstrapScript(), cloudWatchAgentScript(e.CloudWatchAgent)})...)
 	if err != nil {
 		return "", err
 	}
@@ -92,7 +92,7 @@ func (e EKS) kubeletExtraArgs() []string {
 	return args
 }
 
-func (e EKS) mergeCustomUserData(userDatas ...string) (string, error) {
+func mergeCustomUserData(userDatas ...string) (string, error) {
 	var outputBuffer bytes.Buffer
 	writer := multipart.NewWriter(&outputBuffer)
 	if err := writer.SetBoundary(Boundary); err != nil {
@@ -101,7 +101,7 @@ func (e EKS) mergeCustomUserData(userDatas ...string) (string, error) {
 	outputBuffer.WriteString(MIMEVersionHeader + "\n")
 	outputBuffer.WriteString(fmt.Sprintf(MIMEContentTypeHeaderTemplate, Boundary) + "\n\n")
 	for _, userData := range userDatas {
-		mimedUserData, err := e.mimeify(userData)
+		mimedUserData, err := mimeify(userData)
 		if err != nil {
 			return "", err
 		}
@@ -122,16 +122,25 @@ func (e EKS) isIPv6() bool {
 
 // mimeify returns userData in a mime format
 // if the userData passed in is already in a mime format, then the input is returned without modification
-func (e EKS) mimeify(customUserData string) (string, error) {
-	if strings.HasPrefix(strings.TrimSpace(customUserData), "MIME-Version:") {
+func mimeify(customUserData string) (string, error) {
+	if isMIME(customUserData) {
 		return customUserData, nil
 	}
+	return mimePart(`text/x-shellscript; charset="us-ascii"`, customUserData)
+}
+
+func isMIME(userData string) bool {
+	return strings.HasPrefix(strings.TrimSpace(userData), "MIME-Version:")
+}
+
+// mimePart returns a mime document with a single part of the content type
+func mimePart(contentType string, customUserData string) (string, error) {
 	var outputBuffer bytes.Buffer
 	writer := multipart.NewWriter(&outputBuffer)
 	outputBuffer.WriteString(MIMEVersionHeader + "\n")
 	outputBuffer.WriteString(fmt.Sprintf(MIMEContentTypeHeaderTemplate, writer.Boundary()) + "\n\n")
 	partWriter, err := writer.CreatePart(textproto.MIMEHeader{
-		"Content-Type": []string{`text/x-shellscript; charset="us-ascii"`},
+		"Content-Type": []string{contentType},
 	})
 	if err != nil {
 		return "", fmt.Errorf("creating multi-part section from custom user-data: %w", err)
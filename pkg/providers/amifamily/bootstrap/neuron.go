@@ -0,0 +1,70 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+)
+
+const (
+	neuronYumRepository = "https://yum.repos.neuron.amazonaws.com"
+	neuronAptRepository = "https://apt.repos.neuron.amazonaws.com"
+	neuronDriverPackage = "aws-neuronx-dkms"
+	// neuronDeviceTimeoutSeconds bounds how long bootstrap waits for the driver to expose its first device
+	neuronDeviceTimeoutSeconds = 60
+)
+
+// neuronScript returns a shell script that installs, if needed, and loads the Neuron driver on inf and trn instance
+// types. The script is run before the EKS bootstrap script so that Neuron devices exist by the time the kubelet and
+// the Neuron device plugin start. It exits early on all other instance types, since a launch template may be shared
+// by instance types with and without Neuron devices.
+func neuronScript(neuron *v1beta1.Neuron) string {
+	if neuron == nil {
+		return ""
+	}
+	yumPackage, aptPackage := neuronDriverPackage, neuronDriverPackage
+	if neuron.DriverVersion != nil {
+		yumPackage = fmt.Sprintf("%s-%s", neuronDriverPackage, *neuron.DriverVersion)
+		aptPackage = fmt.Sprintf("%s=%s", neuronDriverPackage, *neuron.DriverVersion)
+	}
+	var script bytes.Buffer
+	script.WriteString("#!/bin/bash -x\n")
+	script.WriteString("exec > >(tee /var/log/karpenter-neuron.log|logger -t karpenter-neuron -s 2>/dev/console) 2>&1\n")
+	script.WriteString("TOKEN=$(curl -sS -X PUT 'http://169.254.169.254/latest/api/token' -H 'X-aws-ec2-metadata-token-ttl-seconds: 60')\n")
+	script.WriteString("INSTANCE_TYPE=$(curl -sS -H \"X-aws-ec2-metadata-token: $TOKEN\" http://169.254.169.254/latest/meta-data/instance-type)\n")
+	script.WriteString("case \"$INSTANCE_TYPE\" in\n")
+	script.WriteString("  inf*|trn*) ;;\n")
+	script.WriteString("  *) exit 0 ;;\n")
+	script.WriteString("esac\n")
+	script.WriteString("if ! modinfo neuron >/dev/null 2>&1; then\n")
+	script.WriteString("  if command -v yum >/dev/null 2>&1; then\n")
+	script.WriteString(fmt.Sprintf("    printf '[neuron]\\nname=Neuron YUM Repository\\nbaseurl=%s\\nenabled=1\\nmetadata_expire=0\\n' > /etc/yum.repos.d/neuron.repo\n", neuronYumRepository))
+	script.WriteString(fmt.Sprintf("    rpm --import %s/GPG-PUB-KEY-AMAZON-AWS-NEURON.PUB\n", neuronYumRepository))
+	script.WriteString(fmt.Sprintf("    yum install -y \"kernel-devel-$(uname -r)\" \"kernel-headers-$(uname -r)\" %s\n", yumPackage))
+	script.WriteString("  else\n")
+	script.WriteString("    . /etc/os-release\n")
+	script.WriteString(fmt.Sprintf("    echo \"deb %s $VERSION_CODENAME main\" > /etc/apt/sources.list.d/neuron.list\n", neuronAptRepository))
+	script.WriteString(fmt.Sprintf("    curl -sSfL %s/GPG-PUB-KEY-AMAZON-AWS-NEURON.PUB | apt-key add -\n", neuronAptRepository))
+	script.WriteString(fmt.Sprintf("    apt-get update && apt-get install -y \"linux-headers-$(uname -r)\" %s\n", aptPackage))
+	script.WriteString("  fi\n")
+	script.WriteString("fi\n")
+	script.WriteString("modprobe neuron\n")
+	script.WriteString(fmt.Sprintf("for i in $(seq %d); do [ -e /dev/neuron0 ] && break; sleep 1; done\n", neuronDeviceTimeoutSeconds))
+	script.WriteString("[ -e /dev/neuron0 ] || echo 'Neuron devices were not found after loading the Neuron driver'\n")
+	return script.String()
+}
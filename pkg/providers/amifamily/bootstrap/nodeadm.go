@@ -0,0 +1,203 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/utils/resources"
+)
+
+const (
+	nodeConfigAPIVersion  = "node.eks.aws/v1alpha1"
+	nodeConfigKind        = "NodeConfig"
+	nodeConfigContentType = "application/node.eks.aws"
+)
+
+// Nodeadm bootstraps AL2023 nodes, which join the cluster with nodeadm rather than the bootstrap.sh script. nodeadm is
+// configured by a NodeConfig in a part of the MIME user data.
+type Nodeadm struct {
+	Options
+	// ClusterCIDR is the service CIDR of the cluster, which nodeadm requires to configure the kubelet
+	ClusterCIDR *string
+}
+
+// NodeConfig is the subset of nodeadm's node.eks.aws/v1alpha1 NodeConfig that Karpenter sets
+// https://awslabs.github.io/amazon-eks-ami/nodeadm/doc/api/
+type NodeConfig struct {
+	metav1.TypeMeta `json:",inline"`
+	Spec            NodeConfigSpec `json:"spec"`
+}
+
+type NodeConfigSpec struct {
+	Cluster ClusterDetails `json:"cluster"`
+	Kubelet KubeletOptions `json:"kubelet,omitempty"`
+}
+
+type ClusterDetails struct {
+	Name                 string `json:"name"`
+	APIServerEndpoint    string `json:"apiServerEndpoint"`
+	CertificateAuthority string `json:"certificateAuthority,omitempty"`
+	CIDR                 string `json:"cidr"`
+}
+
+type KubeletOptions struct {
+	// Config is merged into the kubelet's KubeletConfiguration
+	Config map[string]interface{} `json:"config,omitempty"`
+	// Flags are passed to the kubelet on its command line
+	Flags []string `json:"flags,omitempty"`
+}
+
+func (n Nodeadm) Script() (string, error) {
+	nodeConfig, err := n.nodeConfigPart()
+	if err != nil {
+		return "", err
+	}
+	customUserData, err := n.customUserDataPart()
+	if err != nil {
+		return "", err
+	}
+	// nodeadm merges the NodeConfigs in the order of their parts, so Karpenter's configuration takes precedence over
+	// the custom user data's
+	userData, err := mergeCustomUserData(lo.Compact([]string{customUserData, nodeConfig, cloudWatchAgentScript(n.CloudWatchAgent)})...)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(strings.ReplaceAll(userData, "\r", ""))), nil
+}
+
+// customUserDataPart returns the custom user data in a mime format. Custom user data that isn't already in a mime
+// format is a NodeConfig if it's of that kind, and a shell script otherwise.
+func (n Nodeadm) customUserDataPart() (string, error) {
+	customUserData := lo.FromPtr(n.CustomUserData)
+	if customUserData == "" || isMIME(customUserData) {
+		return customUserData, nil
+	}
+	typeMeta := metav1.TypeMeta{}
+	if err := yaml.Unmarshal([]byte(customUserData), &typeMeta); err == nil && typeMeta.Kind == nodeConfigKind {
+		return mimePart(nodeConfigContentType, customUserData)
+	}
+	return mimeify(customUserData)
+}
+
+func (n Nodeadm) nodeConfigPart() (string, error) {
+	if lo.FromPtr(n.ClusterCIDR) == "" {
+		return "", fmt.Errorf("nodeadm requires the cluster CIDR, which couldn't be resolved from the EKS cluster")
+	}
+	nodeConfig := NodeConfig{
+		TypeMeta: metav1.TypeMeta{APIVersion: nodeConfigAPIVersion, Kind: nodeConfigKind},
+		Spec: NodeConfigSpec{
+			Cluster: ClusterDetails{
+				Name:                 n.ClusterName,
+				APIServerEndpoint:    n.ClusterEndpoint,
+				CertificateAuthority: lo.FromPtr(n.CABundle),
+				CIDR:                 lo.FromPtr(n.ClusterCIDR),
+			},
+			Kubelet: KubeletOptions{
+				Config: n.kubeletConfig(),
+				Flags:  lo.Compact([]string{n.nodeLabelFlag()}),
+			},
+		},
+	}
+	raw, err := yaml.Marshal(nodeConfig)
+	if err != nil {
+		return "", fmt.Errorf("marshaling NodeConfig, %w", err)
+	}
+	return mimePart(nodeConfigContentType, string(raw))
+}
+
+// kubeletConfig returns the KubeletConfiguration fields that Karpenter sets. Like with bootstrap.sh, the max pods are
+// 110 when ENI-limited pod density is disabled and they aren't set.
+//
+//nolint:gocyclo
+func (n Nodeadm) kubeletConfig() map[string]interface{} {
+	config := map[string]interface{}{}
+	if len(n.Taints) > 0 {
+		config["registerWithTaints"] = n.sortedTaints()
+	}
+	if !n.AWSENILimitedPodDensity {
+		config["maxPods"] = 110
+	}
+	if n.KubeletConfig == nil {
+		return config
+	}
+	if n.KubeletConfig.MaxPods != nil {
+		config["maxPods"] = *n.KubeletConfig.MaxPods
+	}
+	if n.KubeletConfig.PodsPerCore != nil {
+		config["podsPerCore"] = *n.KubeletConfig.PodsPerCore
+	}
+	if len(n.KubeletConfig.ClusterDNS) > 0 {
+		config["clusterDNS"] = n.KubeletConfig.ClusterDNS
+	}
+	if n.KubeletConfig.SystemReserved != nil {
+		config["systemReserved"] = resources.StringMap(n.KubeletConfig.SystemReserved)
+	}
+	if n.KubeletConfig.KubeReserved != nil {
+		config["kubeReserved"] = resources.StringMap(n.KubeletConfig.KubeReserved)
+	}
+	if n.KubeletConfig.EvictionHard != nil {
+		config["evictionHard"] = n.KubeletConfig.EvictionHard
+	}
+	if n.KubeletConfig.EvictionSoft != nil {
+		config["evictionSoft"] = n.KubeletConfig.EvictionSoft
+	}
+	if n.KubeletConfig.EvictionSoftGracePeriod != nil {
+		config["evictionSoftGracePeriod"] = lo.MapValues(n.KubeletConfig.EvictionSoftGracePeriod, func(v metav1.Duration, _ string) string { return v.Duration.String() })
+	}
+	if n.KubeletConfig.EvictionMaxPodGracePeriod != nil {
+		config["evictionMaxPodGracePeriod"] = *n.KubeletConfig.EvictionMaxPodGracePeriod
+	}
+	if n.KubeletConfig.ImageGCHighThresholdPercent != nil {
+		config["imageGCHighThresholdPercent"] = *n.KubeletConfig.ImageGCHighThresholdPercent
+	}
+	if n.KubeletConfig.ImageGCLowThresholdPercent != nil {
+		config["imageGCLowThresholdPercent"] = *n.KubeletConfig.ImageGCLowThresholdPercent
+	}
+	if n.KubeletConfig.CPUCFSQuota != nil {
+		config["cpuCFSQuota"] = *n.KubeletConfig.CPUCFSQuota
+	}
+	return config
+}
+
+// nodeLabelFlag returns the --node-labels flag, which isn't part of the KubeletConfiguration. nodeadm passes flags to
+// the kubelet without a shell, so the labels aren't quoted.
+func (n Nodeadm) nodeLabelFlag() string {
+	keys := lo.Reject(lo.Keys(n.Labels), func(key string, _ int) bool { return v1alpha5.LabelDomainExceptions.Has(key) })
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys) // ensures this list is deterministic, for easy testing.
+	return fmt.Sprintf("--node-labels=%s", strings.Join(lo.Map(keys, func(key string, _ int) string {
+		return fmt.Sprintf("%s=%s", key, n.Labels[key])
+	}), ","))
+}
+
+func (n Nodeadm) sortedTaints() []core.Taint {
+	taints := lo.Map(n.Taints, func(t core.Taint, _ int) core.Taint { return t })
+	sort.Slice(taints, func(i, j int) bool {
+		return fmt.Sprintf("%s=%s:%s", taints[i].Key, taints[i].Value, taints[i].Effect) < fmt.Sprintf("%s=%s:%s", taints[j].Key, taints[j].Value, taints[j].Effect)
+	})
+	return taints
+}
@@ -25,6 +25,9 @@ import (
 
 type Windows struct {
 	Options
+	// ContainerRuntime is passed to the bootstrap script when it's set, rather than the container runtime of the
+	// kubelet configuration
+	ContainerRuntime string
 }
 
 // nolint:gocyclo
@@ -48,9 +51,14 @@ func (w Windows) Script() (string, error) {
 	if w.KubeletConfig != nil && len(w.KubeletConfig.ClusterDNS) > 0 {
 		userData.WriteString(fmt.Sprintf(` -DNSClusterIP '%s'`, w.KubeletConfig.ClusterDNS[0]))
 	}
-	if w.KubeletConfig != nil && w.KubeletConfig.ContainerRuntime != nil {
+	if w.ContainerRuntime != "" {
+		userData.WriteString(fmt.Sprintf(` -ContainerRuntime '%s'`, w.ContainerRuntime))
+	} else if w.KubeletConfig != nil && w.KubeletConfig.ContainerRuntime != nil {
 		userData.WriteString(fmt.Sprintf(` -ContainerRuntime '%s'`, *w.KubeletConfig.ContainerRuntime))
 	}
-	userData.WriteString("\n</powershell>")
+	userData.WriteString("\n")
+	userData.WriteString(cloudWatchAgentPowerShell(w.CloudWatchAgent))
+	userData.WriteString(domainJoinPowerShell(w.DomainJoin))
+	userData.WriteString("</powershell>")
 	return base64.StdEncoding.EncodeToString(userData.Bytes()), nil
 }
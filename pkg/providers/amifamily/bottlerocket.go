@@ -38,13 +38,16 @@ import (
 type Bottlerocket struct {
 	DefaultFamily
 	*Options
+	// Variant is appended to the name of the Bottlerocket variant that default AMIs are resolved from, e.g. fips resolves
+	// the aws-k8s-<version>-fips and aws-k8s-<version>-nvidia-fips variants
+	Variant string
 }
 
 // DefaultAMIs returns the AMI name, and Requirements, with an SSM query
 func (b Bottlerocket) DefaultAMIs(version string) []DefaultAMIOutput {
 	return []DefaultAMIOutput{
 		{
-			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s/x86_64/latest/image_id", version),
+			Query: b.query(version, v1alpha5.ArchitectureAmd64, false),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureAmd64),
 				scheduling.NewRequirement(v1alpha1.LabelInstanceGPUCount, v1.NodeSelectorOpDoesNotExist),
@@ -52,21 +55,21 @@ func (b Bottlerocket) DefaultAMIs(version string) []DefaultAMIOutput {
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia/x86_64/latest/image_id", version),
+			Query: b.query(version, v1alpha5.ArchitectureAmd64, true),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureAmd64),
 				scheduling.NewRequirement(v1alpha1.LabelInstanceGPUCount, v1.NodeSelectorOpExists),
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia/x86_64/latest/image_id", version),
+			Query: b.query(version, v1alpha5.ArchitectureAmd64, true),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureAmd64),
 				scheduling.NewRequirement(v1alpha1.LabelInstanceAcceleratorCount, v1.NodeSelectorOpExists),
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s/%s/latest/image_id", version, v1alpha5.ArchitectureArm64),
+			Query: b.query(version, v1alpha5.ArchitectureArm64, false),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureArm64),
 				scheduling.NewRequirement(v1alpha1.LabelInstanceGPUCount, v1.NodeSelectorOpDoesNotExist),
@@ -74,14 +77,14 @@ func (b Bottlerocket) DefaultAMIs(version string) []DefaultAMIOutput {
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia/%s/latest/image_id", version, v1alpha5.ArchitectureArm64),
+			Query: b.query(version, v1alpha5.ArchitectureArm64, true),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureArm64),
 				scheduling.NewRequirement(v1alpha1.LabelInstanceGPUCount, v1.NodeSelectorOpExists),
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia/%s/latest/image_id", version, v1alpha5.ArchitectureArm64),
+			Query: b.query(version, v1alpha5.ArchitectureArm64, true),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureArm64),
 				scheduling.NewRequirement(v1alpha1.LabelInstanceAcceleratorCount, v1.NodeSelectorOpExists),
@@ -90,6 +93,19 @@ func (b Bottlerocket) DefaultAMIs(version string) []DefaultAMIOutput {
 	}
 }
 
+// query returns the SSM parameter of the latest AMI of the Bottlerocket variant for the version and architecture. The
+// parameters of amd64 AMIs are published under x86_64.
+func (b Bottlerocket) query(version string, arch string, nvidia bool) string {
+	variant := fmt.Sprintf("aws-k8s-%s", version)
+	if nvidia {
+		variant += "-nvidia"
+	}
+	if b.Variant != "" {
+		variant += "-" + b.Variant
+	}
+	return fmt.Sprintf("/aws/service/bottlerocket/%s/%s/latest/image_id", variant, lo.Ternary(arch == v1alpha5.ArchitectureAmd64, "x86_64", arch))
+}
+
 // UserData returns the default userdata script for the AMI Family
 func (b Bottlerocket) UserData(kubeletConfig *corev1beta1.KubeletConfiguration, taints []v1.Taint, labels map[string]string, caBundle *string, _ []*cloudprovider.InstanceType, customUserData *string) bootstrap.Bootstrapper {
 	return bootstrap.Bottlerocket{
@@ -0,0 +1,173 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package amifamily
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	nodeClassLabel         = "nodeclass"
+	nodeClassKindLabel     = "nodeclass_kind"
+	imageIDLabel           = "image_id"
+	candidateLabel         = "candidate"
+	ssmParameterLabel      = "parameter"
+	errorCodeLabel         = "error_code"
+	apiLabel               = "api"
+
+	apiGetParameter           = "GetParameter"
+	apiDescribeImages         = "DescribeImages"
+	apiDescribeImageAttribute = "DescribeImageAttribute"
+)
+
+var (
+	AMIRolloutLaunches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "ami_rollout_launches_total",
+			Help:      "Number of launches that launch templates were resolved for with an AMI of a node class that has an AMI rollout. Labeled by node class, its kind, image ID, and whether the image is a candidate of the rollout.",
+		},
+		[]string{
+			nodeClassLabel,
+			nodeClassKindLabel,
+			imageIDLabel,
+			candidateLabel,
+		})
+	SSMParameterResolutionFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "ssm_parameter_resolution_failures",
+			Help:      "Number of times that an SSM parameter failed to resolve to an AMI ID. The parameter isn't queried again until its backoff expires. Labeled by SSM parameter and error code.",
+		},
+		[]string{
+			ssmParameterLabel,
+			errorCodeLabel,
+		})
+	ResolvedAMIs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "amis_resolved",
+			Help:      "Number of AMIs that the AMI selector terms or the default AMIs of a node class resolved to, including the candidates of an AMI rollout. Labeled by node class and its kind.",
+		},
+		[]string{
+			nodeClassLabel,
+			nodeClassKindLabel,
+		})
+	NoAMIsResolved = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "amis_resolved_none",
+			Help:      "Whether no AMIs matched the AMI selector terms or the default AMIs of a node class, in which case no nodes can be launched for it. Labeled by node class and its kind.",
+		},
+		[]string{
+			nodeClassLabel,
+			nodeClassKindLabel,
+		})
+	NewestAMIAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "newest_ami_age_seconds",
+			Help:      "Time since the newest of the AMIs that a node class resolved to was created in seconds. Labeled by node class and its kind.",
+		},
+		[]string{
+			nodeClassLabel,
+			nodeClassKindLabel,
+		})
+	AMIRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "ami_request_duration_seconds",
+			Help:      "Duration of the SSM and EC2 requests that resolve AMIs in seconds. Labeled by API.",
+			Buckets:   metrics.DurationBuckets(),
+		},
+		[]string{
+			apiLabel,
+		})
+	AMIRequestErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "ami_request_errors_total",
+			Help:      "Number of SSM and EC2 requests that resolve AMIs that failed. Labeled by API and error code.",
+		},
+		[]string{
+			apiLabel,
+			errorCodeLabel,
+		})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(AMIRolloutLaunches, SSMParameterResolutionFailures, ResolvedAMIs, NoAMIsResolved, NewestAMIAge,
+		AMIRequestDuration, AMIRequestErrors)
+}
+
+// observeRequest records the duration of a request that resolves AMIs, and its error if it failed
+func observeRequest(api string, start time.Time, err error) {
+	AMIRequestDuration.With(prometheus.Labels{apiLabel: api}).Observe(time.Since(start).Seconds())
+	if err != nil {
+		AMIRequestErrors.With(prometheus.Labels{apiLabel: api, errorCodeLabel: errorCode(err)}).Inc()
+	}
+}
+
+// nodeClassLabels returns the labels of the metrics of the node class. AWSNodeTemplates and NodeClasses can share a
+// name, so they're told apart by their kind.
+func nodeClassLabels(key nodeclassutil.Key) prometheus.Labels {
+	return prometheus.Labels{
+		nodeClassLabel:     key.Name,
+		nodeClassKindLabel: lo.Ternary(key.IsNodeTemplate, "AWSNodeTemplate", "NodeClass"),
+	}
+}
+
+// DeleteNodeClassMetrics deletes the metrics of the node class once it's deleted
+func DeleteNodeClassMetrics(key nodeclassutil.Key) {
+	labels := nodeClassLabels(key)
+	ResolvedAMIs.Delete(labels)
+	NoAMIsResolved.Delete(labels)
+	NewestAMIAge.Delete(labels)
+	AMIRolloutLaunches.DeletePartialMatch(labels)
+}
+
+// observeSelection records the AMIs that the node class resolved to
+func observeSelection(nodeClass *v1beta1.NodeClass, amis AMIs, now time.Time) {
+	labels := nodeClassLabels(nodeclassutil.Key{Name: nodeClass.Name, IsNodeTemplate: nodeClass.IsNodeTemplate})
+	ResolvedAMIs.With(labels).Set(float64(len(amis)))
+	NoAMIsResolved.With(labels).Set(lo.Ternary(len(amis) == 0, 1.0, 0.0))
+	var newest time.Time
+	for _, ami := range amis {
+		if creationDate, err := time.Parse(time.RFC3339, ami.CreationDate); err == nil && creationDate.After(newest) {
+			newest = creationDate
+		}
+	}
+	if newest.IsZero() {
+		NewestAMIAge.Delete(labels)
+		return
+	}
+	NewestAMIAge.With(labels).Set(now.Sub(newest).Seconds())
+}
@@ -18,21 +18,26 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/imdario/mergo"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/samber/lo"
 	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 
 	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/providers/amifamily/bootstrap"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/scheduling"
+	"github.com/aws/karpenter-core/pkg/utils/resources"
 )
 
 var DefaultEBS = v1beta1.BlockDevice{
@@ -54,11 +59,18 @@ type Options struct {
 	InstanceProfile         string
 	CABundle                *string `hash:"ignore"`
 	// Level-triggered fields that may change out of sync.
-	SecurityGroups           []v1alpha1.SecurityGroup
-	Tags                     map[string]string
-	Labels                   map[string]string `hash:"ignore"`
-	KubeDNSIP                net.IP
+	SecurityGroups []v1alpha1.SecurityGroup
+	Tags           map[string]string
+	Labels         map[string]string `hash:"ignore"`
+	KubeDNSIP      net.IP
+	// ClusterCIDR is the service CIDR of the cluster, if it could be resolved
+	ClusterCIDR              *string
 	AssociatePublicIPAddress *bool
+	CloudWatchAgent          *v1beta1.CloudWatchAgent
+	DomainJoin               *v1beta1.DomainJoin
+	Neuron                   *v1beta1.Neuron
+	// CapacityReservationID targets the capacity reservation that the NodeClaim requires, if any
+	CapacityReservationID string
 }
 
 // LaunchTemplate holds the dynamically generated launch template parameters
@@ -70,6 +82,10 @@ type LaunchTemplate struct {
 	AMIID               string
 	InstanceTypes       []*cloudprovider.InstanceType `hash:"ignore"`
 	DetailedMonitoring  bool
+	// CPUOptions are the cores and threads per core that the instance types are launched with, if the NodeClass sets them
+	CPUOptions *v1beta1.CPUOptions
+	// OutpostARN is the ARN of the Outpost that the AMI can only be launched on, if it's stored on one
+	OutpostARN string `hash:"ignore"`
 }
 
 // AMIFamily can be implemented to override the default logic for generating dynamic launch template parameters
@@ -117,7 +133,7 @@ func New(amiProvider *Provider) *Resolver {
 // Resolve generates launch templates using the static options and dynamically generates launch template parameters.
 // Multiple ResolvedTemplates are returned based on the instanceTypes passed in to support special AMIs for certain instance types like GPUs.
 func (r Resolver) Resolve(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType, options *Options) ([]*LaunchTemplate, error) {
-	amiFamily := GetAMIFamily(nodeClass.Spec.AMIFamily, options)
+	amiFamily := GetAMIFamilyForNodeClass(nodeClass, options)
 	amis, err := r.amiProvider.Get(ctx, nodeClass, options)
 	if err != nil {
 		return nil, err
@@ -129,15 +145,46 @@ func (r Resolver) Resolve(ctx context.Context, nodeClass *v1beta1.NodeClass, nod
 	if len(mappedAMIs) == 0 {
 		return nil, fmt.Errorf("no instance types satisfy requirements of amis %v", amis)
 	}
+	if nodeClass.Spec.AMIRollout != nil {
+		for amiID := range mappedAMIs {
+			ami, _ := lo.Find(amis, func(a AMI) bool { return a.AmiID == amiID })
+			AMIRolloutLaunches.With(lo.Assign(nodeClassLabels(nodeclassutil.Key{Name: nodeClass.Name, IsNodeTemplate: nodeClass.IsNodeTemplate}), prometheus.Labels{
+				imageIDLabel:   amiID,
+				candidateLabel: strconv.FormatBool(ami.Candidate),
+			})).Inc()
+		}
+	}
+	metadataOptions := nodeClass.Spec.MetadataOptions
+	if metadataOptions == nil {
+		metadataOptions = amiFamily.DefaultMetadataOptions()
+	}
+	detailedMonitoring, metadataOptions, err := resolveMonitoringOverrides(nodeClass, nodeClaim, metadataOptions)
+	if err != nil {
+		return nil, err
+	}
 	var resolvedTemplates []*LaunchTemplate
 	for amiID, instanceTypes := range mappedAMIs {
-		maxPodsToInstanceTypes := lo.GroupBy(instanceTypes, func(instanceType *cloudprovider.InstanceType) int {
-			return int(instanceType.Capacity.Pods().Value())
+		ami, _ := lo.Find(amis, func(a AMI) bool { return a.AmiID == amiID })
+		blockDeviceMappings := nodeClass.Spec.BlockDeviceMappings
+		if len(blockDeviceMappings) == 0 {
+			blockDeviceMappings = defaultBlockDeviceMappings(amiFamily, ami)
+		}
+		if settings.FromContext(ctx).RequireEBSEncryption {
+			if devices := ami.UnencryptedVolumes(blockDeviceMappings); len(devices) > 0 {
+				return nil, fmt.Errorf("ebs encryption is required, but ami %s would launch unencrypted volumes %v", amiID, devices)
+			}
+		}
+		overridesToInstanceTypes := lo.GroupBy(instanceTypes, func(instanceType *cloudprovider.InstanceType) launchTemplateOverrides {
+			return newLaunchTemplateOverrides(nodeClass, instanceType)
 		})
 		// In order to support reserved ENIs for CNI custom networking setups,
 		// we need to pass down the max-pods calculation to the kubelet.
 		// This requires that we resolve a unique launch template per max-pods value.
-		for maxPods, instanceTypes := range maxPodsToInstanceTypes {
+		// Likewise, instance types whose system reserved resources are overridden by the NodeClass, or whose kube
+		// reserved resources are computed by a formula that the bootstrap doesn't know, need a launch template that
+		// passes them to the kubelet, and instance types whose cores or threads per core differ need launch templates
+		// with different CPU options.
+		for overrides, instanceTypes := range overridesToInstanceTypes {
 			kubeletConfig := &corev1beta1.KubeletConfiguration{}
 			if nodeClaim.Spec.KubeletConfiguration != nil {
 				if err := mergo.Merge(kubeletConfig, nodeClaim.Spec.KubeletConfiguration); err != nil {
@@ -145,7 +192,13 @@ func (r Resolver) Resolve(ctx context.Context, nodeClass *v1beta1.NodeClass, nod
 				}
 			}
 			if kubeletConfig.MaxPods == nil {
-				kubeletConfig.MaxPods = lo.ToPtr(int32(maxPods))
+				kubeletConfig.MaxPods = lo.ToPtr(int32(overrides.maxPods))
+			}
+			if overrides.systemReserved != "" {
+				kubeletConfig.SystemReserved = instanceTypes[0].Overhead.SystemReserved
+			}
+			if overrides.kubeReserved != "" {
+				kubeletConfig.KubeReserved = instanceTypes[0].Overhead.KubeReserved
 			}
 			resolved := &LaunchTemplate{
 				Options: options,
@@ -157,17 +210,13 @@ func (r Resolver) Resolve(ctx context.Context, nodeClass *v1beta1.NodeClass, nod
 					instanceTypes,
 					nodeClass.Spec.UserData,
 				),
-				BlockDeviceMappings: nodeClass.Spec.BlockDeviceMappings,
-				MetadataOptions:     nodeClass.Spec.MetadataOptions,
-				DetailedMonitoring:  aws.BoolValue(nodeClass.Spec.DetailedMonitoring),
+				BlockDeviceMappings: blockDeviceMappings,
+				MetadataOptions:     metadataOptions,
+				DetailedMonitoring:  detailedMonitoring,
 				AMIID:               amiID,
 				InstanceTypes:       instanceTypes,
-			}
-			if len(resolved.BlockDeviceMappings) == 0 {
-				resolved.BlockDeviceMappings = amiFamily.DefaultBlockDeviceMappings()
-			}
-			if resolved.MetadataOptions == nil {
-				resolved.MetadataOptions = amiFamily.DefaultMetadataOptions()
+				CPUOptions:          overrides.cpuOptions(),
+				OutpostARN:          ami.OutpostARN,
 			}
 			resolvedTemplates = append(resolvedTemplates, resolved)
 		}
@@ -175,10 +224,103 @@ func (r Resolver) Resolve(ctx context.Context, nodeClass *v1beta1.NodeClass, nod
 	return resolvedTemplates, nil
 }
 
+// launchTemplateOverrides are the kubelet configuration and CPU options that depend on the instance type, and so on
+// the launch template
+type launchTemplateOverrides struct {
+	maxPods int
+	// systemReserved is the string representation of the system reserved resources of instance types that a NodeClass
+	// overrides them for, which is empty for other instance types
+	systemReserved string
+	// kubeReserved is the string representation of the kube reserved resources of instance types when the NodeClass
+	// computes them with a formula other than EKS, which is empty otherwise
+	kubeReserved string
+	// coreCount and threadsPerCore are the CPU options of instance types when the NodeClass sets them, which are 0
+	// otherwise
+	coreCount      int64
+	threadsPerCore int64
+}
+
+func newLaunchTemplateOverrides(nodeClass *v1beta1.NodeClass, instanceType *cloudprovider.InstanceType) launchTemplateOverrides {
+	overrides := launchTemplateOverrides{maxPods: int(instanceType.Capacity.Pods().Value())}
+	if override, ok := nodeClass.Spec.InstanceTypeOverride(instanceType.Name); ok && len(override.SystemReserved) > 0 {
+		overrides.systemReserved = resources.String(instanceType.Overhead.SystemReserved)
+	}
+	if nodeClass.Spec.KubeReservedFormula() != v1beta1.KubeReservedFormulaEKS {
+		overrides.kubeReserved = resources.String(instanceType.Overhead.KubeReserved)
+	}
+	// The vCPU capacity of instance types is their cores times threads per core with the CPU options, so whichever
+	// isn't set is derived from it
+	if options := nodeClass.Spec.CPUOptions; options != nil {
+		vcpus := instanceType.Capacity.Cpu().Value()
+		overrides.coreCount, overrides.threadsPerCore = lo.FromPtr(options.CoreCount), lo.FromPtr(options.ThreadsPerCore)
+		switch {
+		case overrides.coreCount == 0 && overrides.threadsPerCore != 0:
+			overrides.coreCount = vcpus / overrides.threadsPerCore
+		case overrides.threadsPerCore == 0 && overrides.coreCount != 0:
+			overrides.threadsPerCore = vcpus / overrides.coreCount
+		}
+	}
+	return overrides
+}
+
+// cpuOptions returns the CPU options that the launch template sets, which EC2 requires both the cores and threads per
+// core of
+func (o launchTemplateOverrides) cpuOptions() *v1beta1.CPUOptions {
+	if o.coreCount == 0 || o.threadsPerCore == 0 {
+		return nil
+	}
+	return &v1beta1.CPUOptions{CoreCount: aws.Int64(o.coreCount), ThreadsPerCore: aws.Int64(o.threadsPerCore)}
+}
+
+// defaultBlockDeviceMappings returns the AMI family's default block device mappings for the AMI. AMI families list the
+// root volume first, which is moved to the root device of the AMI, since a NodeClass that resolves AMIs for several
+// architectures may resolve images whose root device names differ.
+func defaultBlockDeviceMappings(amiFamily AMIFamily, ami AMI) []*v1beta1.BlockDeviceMapping {
+	blockDeviceMappings := amiFamily.DefaultBlockDeviceMappings()
+	if len(blockDeviceMappings) == 0 || ami.RootDeviceName == "" {
+		return blockDeviceMappings
+	}
+	if _, ok := lo.Find(blockDeviceMappings, func(b *v1beta1.BlockDeviceMapping) bool {
+		return lo.FromPtr(b.DeviceName) == ami.RootDeviceName
+	}); ok {
+		return blockDeviceMappings
+	}
+	root := *blockDeviceMappings[0]
+	root.DeviceName = aws.String(ami.RootDeviceName)
+	return append([]*v1beta1.BlockDeviceMapping{&root}, blockDeviceMappings[1:]...)
+}
+
+// resolveMonitoringOverrides applies the detailed monitoring and instance metadata tags that a NodePool's annotations set
+// on top of the NodeClass values, so that only the pools that need it pay for detailed monitoring.
+func resolveMonitoringOverrides(nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, metadataOptions *v1beta1.MetadataOptions) (bool, *v1beta1.MetadataOptions, error) {
+	detailedMonitoringKey, instanceMetadataTagsKey := v1beta1.AnnotationDetailedMonitoring, v1beta1.AnnotationInstanceMetadataTags
+	if nodeClass.IsNodeTemplate {
+		detailedMonitoringKey, instanceMetadataTagsKey = v1alpha1.AnnotationDetailedMonitoring, v1alpha1.AnnotationInstanceMetadataTags
+	}
+	detailedMonitoring := aws.BoolValue(nodeClass.Spec.DetailedMonitoring)
+	if value, ok := nodeClaim.Annotations[detailedMonitoringKey]; ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, nil, fmt.Errorf("parsing annotation %s, %w", detailedMonitoringKey, err)
+		}
+		detailedMonitoring = enabled
+	}
+	if value, ok := nodeClaim.Annotations[instanceMetadataTagsKey]; ok {
+		if !lo.Contains(ec2.LaunchTemplateInstanceMetadataTagsState_Values(), value) {
+			return false, nil, fmt.Errorf("parsing annotation %s, %q is not one of %v", instanceMetadataTagsKey, value, ec2.LaunchTemplateInstanceMetadataTagsState_Values())
+		}
+		metadataOptions = lo.ToPtr(*metadataOptions)
+		metadataOptions.InstanceMetadataTags = aws.String(value)
+	}
+	return detailedMonitoring, metadataOptions, nil
+}
+
 func GetAMIFamily(amiFamily *string, options *Options) AMIFamily {
 	switch aws.StringValue(amiFamily) {
 	case v1alpha1.AMIFamilyBottlerocket:
 		return &Bottlerocket{Options: options}
+	case v1alpha1.AMIFamilyAL2023:
+		return &AL2023{Options: options}
 	case v1alpha1.AMIFamilyUbuntu:
 		return &Ubuntu{Options: options}
 	case v1alpha1.AMIFamilyWindows2019:
@@ -192,6 +334,21 @@ func GetAMIFamily(amiFamily *string, options *Options) AMIFamily {
 	}
 }
 
+// GetAMIFamilyForNodeClass returns the AMI family of the NodeClass, configured with the variant and stream of its
+// default AMIs
+func GetAMIFamilyForNodeClass(nodeClass *v1beta1.NodeClass, options *Options) AMIFamily {
+	amiFamily := GetAMIFamily(nodeClass.Spec.AMIFamily, options)
+	switch f := amiFamily.(type) {
+	case *AL2:
+		f.Variant = lo.FromPtr(nodeClass.Spec.AMIVariant)
+	case *Bottlerocket:
+		f.Variant = lo.FromPtr(nodeClass.Spec.AMIVariant)
+	case *Ubuntu:
+		f.Stream = lo.FromPtr(nodeClass.Spec.UbuntuStream)
+	}
+	return amiFamily
+}
+
 func (o Options) DefaultMetadataOptions() *v1beta1.MetadataOptions {
 	return &v1beta1.MetadataOptions{
 		HTTPEndpoint:            aws.String(ec2.LaunchTemplateInstanceMetadataEndpointStateEnabled),
@@ -0,0 +1,98 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package amifamily
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+
+	awscache "github.com/aws/karpenter/pkg/cache"
+)
+
+// errBackingOff is returned for the ssm parameters that aren't queried again until the backoff of their last failure
+// expires
+var errBackingOff = errors.New("backing off after failing to resolve")
+
+// ssmFailure is the last failure to resolve an ssm parameter
+type ssmFailure struct {
+	err      error
+	failures int
+	retryAt  time.Time
+}
+
+// ssmFailures tracks the ssm parameters that recently failed to resolve, so that a parameter that doesn't exist or
+// whose lookups are throttled is retried with an exponential backoff rather than on every reconcile
+type ssmFailures struct {
+	mu       sync.Mutex
+	failures map[string]ssmFailure
+}
+
+func newSSMFailures() *ssmFailures {
+	return &ssmFailures{failures: map[string]ssmFailure{}}
+}
+
+// backingOff returns the last failure of the parameter if it shouldn't be queried again yet
+func (s *ssmFailures) backingOff(query string, now time.Time) (ssmFailure, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failure, ok := s.failures[query]
+	return failure, ok && now.Before(failure.retryAt)
+}
+
+// record stores the failure of the parameter, doubling its backoff up to awscache.SSMFailureMaxBackoff. Failures are
+// counted from scratch once the parameter hasn't failed for longer than the maximum backoff.
+func (s *ssmFailures) record(query string, err error, now time.Time) ssmFailure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failure := s.failures[query]
+	if now.Sub(failure.retryAt) > awscache.SSMFailureMaxBackoff {
+		failure.failures = 0
+	}
+	backoff := awscache.SSMFailureBackoff
+	for i := 0; i < failure.failures && backoff < awscache.SSMFailureMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > awscache.SSMFailureMaxBackoff {
+		backoff = awscache.SSMFailureMaxBackoff
+	}
+	failure = ssmFailure{err: err, failures: failure.failures + 1, retryAt: now.Add(backoff)}
+	s.failures[query] = failure
+	return failure
+}
+
+// succeed forgets the failures of the parameter
+func (s *ssmFailures) succeed(query string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, query)
+}
+
+func (s *ssmFailures) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = map[string]ssmFailure{}
+}
+
+// errorCode returns the code of the AWS error, or Unknown for errors that didn't come from AWS
+func errorCode(err error) string {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code()
+	}
+	return "Unknown"
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
 
 	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
@@ -32,19 +33,29 @@ import (
 type Ubuntu struct {
 	DefaultFamily
 	*Options
+	// Stream is the stream of Canonical's EKS images that the default AMIs are resolved from
+	Stream string
+}
+
+// ubuntuStreamProducts are the products that Canonical publishes the SSM parameters of each stream's EKS images under
+var ubuntuStreamProducts = map[string]string{
+	v1beta1.UbuntuStreamStandard: "eks",
+	v1beta1.UbuntuStreamPro:      "eks-pro",
+	v1beta1.UbuntuStreamMinimal:  "eks-minimal",
 }
 
 // DefaultAMIs returns the AMI name, and Requirements, with an SSM query
 func (u Ubuntu) DefaultAMIs(version string) []DefaultAMIOutput {
+	product := ubuntuStreamProducts[lo.Ternary(u.Stream == "", v1beta1.UbuntuStreamStandard, u.Stream)]
 	return []DefaultAMIOutput{
 		{
-			Query: fmt.Sprintf("/aws/service/canonical/ubuntu/eks/20.04/%s/stable/current/%s/hvm/ebs-gp2/ami-id", version, v1alpha5.ArchitectureAmd64),
+			Query: fmt.Sprintf("/aws/service/canonical/ubuntu/%s/20.04/%s/stable/current/%s/hvm/ebs-gp2/ami-id", product, version, v1alpha5.ArchitectureAmd64),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureAmd64),
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/canonical/ubuntu/eks/20.04/%s/stable/current/%s/hvm/ebs-gp2/ami-id", version, v1alpha5.ArchitectureArm64),
+			Query: fmt.Sprintf("/aws/service/canonical/ubuntu/%s/20.04/%s/stable/current/%s/hvm/ebs-gp2/ami-id", product, version, v1alpha5.ArchitectureArm64),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureArm64),
 			),
@@ -64,6 +75,8 @@ func (u Ubuntu) UserData(kubeletConfig *corev1beta1.KubeletConfiguration, taints
 			Labels:                  labels,
 			CABundle:                caBundle,
 			CustomUserData:          customUserData,
+			CloudWatchAgent:         u.Options.CloudWatchAgent,
+			Neuron:                  u.Options.Neuron,
 		},
 	}
 }
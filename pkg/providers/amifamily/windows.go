@@ -59,6 +59,7 @@ func (w Windows) DefaultAMIs(version string) []DefaultAMIOutput {
 // UserData returns the default userdata script for the AMI Family
 func (w Windows) UserData(kubeletConfig *corev1beta1.KubeletConfiguration, taints []v1.Taint, labels map[string]string, caBundle *string, _ []*cloudprovider.InstanceType, customUserData *string) bootstrap.Bootstrapper {
 	return bootstrap.Windows{
+		ContainerRuntime: w.containerRuntime(),
 		Options: bootstrap.Options{
 			ClusterName:     w.Options.ClusterName,
 			ClusterEndpoint: w.Options.ClusterEndpoint,
@@ -67,10 +68,21 @@ func (w Windows) UserData(kubeletConfig *corev1beta1.KubeletConfiguration, taint
 			Labels:          labels,
 			CABundle:        caBundle,
 			CustomUserData:  customUserData,
+			CloudWatchAgent: w.Options.CloudWatchAgent,
+			DomainJoin:      w.Options.DomainJoin,
 		},
 	}
 }
 
+// containerRuntime returns the container runtime that nodes must bootstrap with, whatever the kubelet configuration
+// asks for. The EKS optimized Windows Server 2022 AMIs only ship with containerd.
+func (w Windows) containerRuntime() string {
+	if w.Version == v1alpha1.Windows2022 {
+		return "containerd"
+	}
+	return ""
+}
+
 // DefaultBlockDeviceMappings returns the default block device mappings for the AMI Family
 func (w Windows) DefaultBlockDeviceMappings() []*v1beta1.BlockDeviceMapping {
 	sda1EBS := DefaultEBS
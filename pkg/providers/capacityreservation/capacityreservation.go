@@ -0,0 +1,178 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capacityreservation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+)
+
+const cacheKey = "reservations"
+
+// Provider discovers the On-Demand Capacity Reservations in the region so that pods which require a reservation by its
+// id can be launched into it
+type Provider struct {
+	sync.Mutex
+	ec2api ec2iface.EC2API
+	cache  *cache.Cache
+	cm     *pretty.ChangeMonitor
+}
+
+func NewProvider(ec2api ec2iface.EC2API, cache *cache.Cache) *Provider {
+	return &Provider{
+		ec2api: ec2api,
+		cache:  cache,
+		cm:     pretty.NewChangeMonitor(),
+	}
+}
+
+// List returns the active capacity reservations in the region
+func (p *Provider) List(ctx context.Context) ([]*ec2.CapacityReservation, error) {
+	reservations, err := p.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(reservations, func(r *ec2.CapacityReservation, _ int) bool {
+		return aws.StringValue(r.State) == ec2.CapacityReservationStateActive
+	}), nil
+}
+
+// list returns the active and pending capacity reservations in the region. A reservation that Karpenter just created
+// is pending until EC2 has provisioned its capacity, and must still be found so that it isn't created again.
+func (p *Provider) list(ctx context.Context) ([]*ec2.CapacityReservation, error) {
+	p.Lock()
+	defer p.Unlock()
+	if reservations, ok := awscache.Get(p.cache, awscache.CapacityReservationsCacheName, cacheKey); ok {
+		return reservations.([]*ec2.CapacityReservation), nil
+	}
+	var reservations []*ec2.CapacityReservation
+	if err := p.ec2api.DescribeCapacityReservationsPagesWithContext(ctx, &ec2.DescribeCapacityReservationsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("state"), Values: aws.StringSlice([]string{ec2.CapacityReservationStatePending, ec2.CapacityReservationStateActive})}},
+	}, func(output *ec2.DescribeCapacityReservationsOutput, _ bool) bool {
+		reservations = append(reservations, output.CapacityReservations...)
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing capacity reservations, %w", err)
+	}
+	awscache.SetDefault(p.cache, awscache.CapacityReservationsCacheName, cacheKey, reservations)
+	if p.cm.HasChanged("capacity-reservations", lo.Map(reservations, func(r *ec2.CapacityReservation, _ int) string { return aws.StringValue(r.CapacityReservationId) })) {
+		logging.FromContext(ctx).
+			With("capacity-reservations", lo.Map(reservations, func(r *ec2.CapacityReservation, _ int) string {
+				return fmt.Sprintf("%s (%s, %s, %s)", aws.StringValue(r.CapacityReservationId), aws.StringValue(r.InstanceType), aws.StringValue(r.AvailabilityZone), aws.StringValue(r.State))
+			})).
+			Debugf("discovered capacity reservations")
+	}
+	return reservations, nil
+}
+
+// Get returns the active capacity reservation with the given id
+func (p *Provider) Get(ctx context.Context, id string) (*ec2.CapacityReservation, error) {
+	reservations, err := p.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reservation, ok := lo.Find(reservations, func(r *ec2.CapacityReservation) bool {
+		return aws.StringValue(r.CapacityReservationId) == id
+	})
+	if !ok {
+		return nil, fmt.Errorf("capacity reservation %s is not active", id)
+	}
+	return reservation, nil
+}
+
+// ListScheduled returns the active and pending capacity reservations that Karpenter created for the scheduled capacity
+// reservations of the NodeClass
+func (p *Provider) ListScheduled(ctx context.Context, nodeClass *v1beta1.NodeClass) ([]*ec2.CapacityReservation, error) {
+	reservations, err := p.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(reservations, func(r *ec2.CapacityReservation, _ int) bool {
+		tags := lo.SliceToMap(r.Tags, func(t *ec2.Tag) (string, string) { return aws.StringValue(t.Key), aws.StringValue(t.Value) })
+		return tags[v1alpha5.MachineManagedByAnnotationKey] == settings.FromContext(ctx).ClusterName &&
+			tags[scheduledTagKey(nodeClass)] == nodeClass.Name
+	}), nil
+}
+
+// CreateScheduled creates a targeted capacity reservation for a scheduled capacity reservation of the NodeClass. EC2
+// cancels the reservation at the given time, even if Karpenter doesn't get to it first.
+func (p *Provider) CreateScheduled(ctx context.Context, nodeClass *v1beta1.NodeClass, reservation v1beta1.ScheduledCapacityReservation,
+	endsAt time.Time) (*ec2.CapacityReservation, error) {
+	p.Lock()
+	defer p.Unlock()
+	out, err := p.ec2api.CreateCapacityReservationWithContext(ctx, &ec2.CreateCapacityReservationInput{
+		InstanceType:          aws.String(reservation.InstanceType),
+		AvailabilityZone:      aws.String(reservation.AvailabilityZone),
+		InstanceCount:         aws.Int64(reservation.InstanceCount),
+		InstancePlatform:      aws.String(instancePlatform(nodeClass)),
+		InstanceMatchCriteria: aws.String(ec2.InstanceMatchCriteriaTargeted),
+		EndDateType:           aws.String(ec2.EndDateTypeLimited),
+		EndDate:               aws.Time(endsAt),
+		TagSpecifications: []*ec2.TagSpecification{{
+			ResourceType: aws.String(ec2.ResourceTypeCapacityReservation),
+			Tags: []*ec2.Tag{
+				{Key: aws.String(v1alpha5.MachineManagedByAnnotationKey), Value: aws.String(settings.FromContext(ctx).ClusterName)},
+				{Key: aws.String(scheduledTagKey(nodeClass)), Value: aws.String(nodeClass.Name)},
+			},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating capacity reservation, %w", err)
+	}
+	p.cache.Delete(cacheKey)
+	return out.CapacityReservation, nil
+}
+
+// Cancel cancels the capacity reservation with the given id. Reservations that no longer exist are ignored.
+func (p *Provider) Cancel(ctx context.Context, id string) error {
+	p.Lock()
+	defer p.Unlock()
+	if _, err := p.ec2api.CancelCapacityReservationWithContext(ctx, &ec2.CancelCapacityReservationInput{
+		CapacityReservationId: aws.String(id),
+	}); err != nil && !awserrors.IsNotFound(err) {
+		return fmt.Errorf("cancelling capacity reservation %s, %w", id, err)
+	}
+	p.cache.Delete(cacheKey)
+	return nil
+}
+
+func scheduledTagKey(nodeClass *v1beta1.NodeClass) string {
+	return lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.TagScheduledCapacityReservation, v1beta1.TagScheduledCapacityReservation)
+}
+
+func instancePlatform(nodeClass *v1beta1.NodeClass) string {
+	if lo.Contains([]string{v1beta1.AMIFamilyWindows2019, v1beta1.AMIFamilyWindows2022}, lo.FromPtr(nodeClass.Spec.AMIFamily)) {
+		return ec2.CapacityReservationInstancePlatformWindows
+	}
+	return ec2.CapacityReservationInstancePlatformLinuxUnix
+}
@@ -0,0 +1,148 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package computeoptimizer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/computeoptimizer"
+	"github.com/aws/aws-sdk-go/service/computeoptimizer/computeoptimizeriface"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/logging"
+)
+
+// Recommendation is the rightsizing recommendation that AWS Compute Optimizer made for an instance
+type Recommendation struct {
+	InstanceID          string
+	CurrentInstanceType string
+	Finding             string
+	// RecommendedInstanceType is the highest ranked instance type that Compute Optimizer recommends instead of the
+	// current instance type, if any
+	RecommendedInstanceType string
+}
+
+// Provider caches the Compute Optimizer recommendations for EC2 instances. Compute Optimizer only refreshes its
+// recommendations daily from at least 30 hours of CloudWatch metrics, so these are meant to be updated infrequently.
+type Provider struct {
+	computeOptimizer computeoptimizeriface.ComputeOptimizerAPI
+
+	mu              sync.RWMutex
+	recommendations map[string]Recommendation // key: instance ID
+	overprovisioned sets.Set[string]
+
+	// SeqNum is a monotonically increasing change counter for the set of overprovisioned instance types
+	SeqNum uint64
+}
+
+func NewProvider(computeOptimizer computeoptimizeriface.ComputeOptimizerAPI) *Provider {
+	return &Provider{
+		computeOptimizer: computeOptimizer,
+		recommendations:  map[string]Recommendation{},
+		overprovisioned:  sets.New[string](),
+	}
+}
+
+// Update fetches the latest recommendations for all instances in the account that Compute Optimizer has analyzed
+func (p *Provider) Update(ctx context.Context) error {
+	recommendations := map[string]Recommendation{}
+	input := &computeoptimizer.GetEC2InstanceRecommendationsInput{}
+	for {
+		output, err := p.computeOptimizer.GetEC2InstanceRecommendationsWithContext(ctx, input)
+		if err != nil {
+			return fmt.Errorf("getting ec2 instance recommendations, %w", err)
+		}
+		for _, r := range output.InstanceRecommendations {
+			recommendation := newRecommendation(r)
+			if recommendation.InstanceID == "" {
+				continue
+			}
+			recommendations[recommendation.InstanceID] = recommendation
+		}
+		if aws.StringValue(output.NextToken) == "" {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+	overprovisioned := overprovisionedInstanceTypes(lo.Values(recommendations))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !overprovisioned.Equal(p.overprovisioned) {
+		logging.FromContext(ctx).With("instance-types", sets.List(overprovisioned)).Debugf("discovered overprovisioned instance types")
+		atomic.AddUint64(&p.SeqNum, 1)
+	}
+	p.recommendations = recommendations
+	p.overprovisioned = overprovisioned
+	return nil
+}
+
+// Get returns the recommendation for the instance, if Compute Optimizer has made one
+func (p *Provider) Get(instanceID string) (Recommendation, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	recommendation, ok := p.recommendations[instanceID]
+	return recommendation, ok
+}
+
+// Overprovisioned returns true if Compute Optimizer found the majority of the analyzed instances of the instance type
+// to be overprovisioned
+func (p *Provider) Overprovisioned(instanceType string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.overprovisioned.Has(instanceType)
+}
+
+func newRecommendation(r *computeoptimizer.InstanceRecommendation) Recommendation {
+	recommendation := Recommendation{
+		// Instance ARNs are of the form arn:aws:ec2:<region>:<account>:instance/<instance-id>
+		InstanceID:          aws.StringValue(r.InstanceArn)[strings.LastIndex(aws.StringValue(r.InstanceArn), "/")+1:],
+		CurrentInstanceType: aws.StringValue(r.CurrentInstanceType),
+		Finding:             aws.StringValue(r.Finding),
+	}
+	options := lo.Filter(r.RecommendationOptions, func(o *computeoptimizer.InstanceRecommendationOption, _ int) bool {
+		return aws.StringValue(o.InstanceType) != "" && aws.StringValue(o.InstanceType) != recommendation.CurrentInstanceType
+	})
+	if len(options) > 0 {
+		recommendation.RecommendedInstanceType = aws.StringValue(lo.MinBy(options, func(a, b *computeoptimizer.InstanceRecommendationOption) bool {
+			return aws.Int64Value(a.Rank) < aws.Int64Value(b.Rank)
+		}).InstanceType)
+	}
+	return recommendation
+}
+
+func overprovisionedInstanceTypes(recommendations []Recommendation) sets.Set[string] {
+	overprovisioned := sets.New[string]()
+	for instanceType, recs := range lo.GroupBy(recommendations, func(r Recommendation) string { return r.CurrentInstanceType }) {
+		if lo.CountBy(recs, func(r Recommendation) bool { return r.Finding == computeoptimizer.FindingOverprovisioned })*2 > len(recs) {
+			overprovisioned.Insert(instanceType)
+		}
+	}
+	return overprovisioned
+}
+
+// Reset clears all of the recommendations
+func (p *Provider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recommendations = map[string]Recommendation{}
+	p.overprovisioned = sets.New[string]()
+	atomic.AddUint64(&p.SeqNum, 1)
+}
@@ -0,0 +1,137 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package computeoptimizer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/computeoptimizer"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "knative.dev/pkg/logging/testing"
+
+	"github.com/aws/karpenter/pkg/fake"
+	computeoptimizerprovider "github.com/aws/karpenter/pkg/providers/computeoptimizer"
+)
+
+var ctx context.Context
+var computeOptimizerAPI *fake.ComputeOptimizerAPI
+var computeOptimizerProvider *computeoptimizerprovider.Provider
+
+func TestComputeOptimizer(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ComputeOptimizer")
+}
+
+var _ = BeforeEach(func() {
+	computeOptimizerAPI = &fake.ComputeOptimizerAPI{}
+	computeOptimizerProvider = computeoptimizerprovider.NewProvider(computeOptimizerAPI)
+})
+
+func recommendation(instanceID string, instanceType string, finding string, options ...string) *computeoptimizer.InstanceRecommendation {
+	r := &computeoptimizer.InstanceRecommendation{
+		InstanceArn:         aws.String("arn:aws:ec2:us-west-2:111122223333:instance/" + instanceID),
+		CurrentInstanceType: aws.String(instanceType),
+		Finding:             aws.String(finding),
+	}
+	for i, option := range options {
+		r.RecommendationOptions = append(r.RecommendationOptions, &computeoptimizer.InstanceRecommendationOption{
+			InstanceType: aws.String(option),
+			Rank:         aws.Int64(int64(i + 1)),
+		})
+	}
+	return r
+}
+
+var _ = Describe("ComputeOptimizer", func() {
+	It("should return the recommendation for an instance", func() {
+		computeOptimizerAPI.GetEC2InstanceRecommendationsOutput.Set(&computeoptimizer.GetEC2InstanceRecommendationsOutput{
+			InstanceRecommendations: []*computeoptimizer.InstanceRecommendation{
+				recommendation("i-0123456789abcdef0", "m5.2xlarge", computeoptimizer.FindingOverprovisioned, "m5.2xlarge", "m5.xlarge", "m5.large"),
+			},
+		})
+		Expect(computeOptimizerProvider.Update(ctx)).To(Succeed())
+
+		r, ok := computeOptimizerProvider.Get("i-0123456789abcdef0")
+		Expect(ok).To(BeTrue())
+		Expect(r).To(Equal(computeoptimizerprovider.Recommendation{
+			InstanceID:              "i-0123456789abcdef0",
+			CurrentInstanceType:     "m5.2xlarge",
+			Finding:                 computeoptimizer.FindingOverprovisioned,
+			RecommendedInstanceType: "m5.xlarge",
+		}))
+		_, ok = computeOptimizerProvider.Get("i-00000000000000000")
+		Expect(ok).To(BeFalse())
+	})
+	It("should not recommend an instance type when the only option is the current instance type", func() {
+		computeOptimizerAPI.GetEC2InstanceRecommendationsOutput.Set(&computeoptimizer.GetEC2InstanceRecommendationsOutput{
+			InstanceRecommendations: []*computeoptimizer.InstanceRecommendation{
+				recommendation("i-0123456789abcdef0", "m5.large", computeoptimizer.FindingOptimized, "m5.large"),
+			},
+		})
+		Expect(computeOptimizerProvider.Update(ctx)).To(Succeed())
+
+		r, ok := computeOptimizerProvider.Get("i-0123456789abcdef0")
+		Expect(ok).To(BeTrue())
+		Expect(r.RecommendedInstanceType).To(BeEmpty())
+	})
+	It("should consider instance types overprovisioned when the majority of their instances are overprovisioned", func() {
+		computeOptimizerAPI.GetEC2InstanceRecommendationsOutput.Set(&computeoptimizer.GetEC2InstanceRecommendationsOutput{
+			InstanceRecommendations: []*computeoptimizer.InstanceRecommendation{
+				recommendation("i-00000000000000001", "m5.2xlarge", computeoptimizer.FindingOverprovisioned),
+				recommendation("i-00000000000000002", "m5.2xlarge", computeoptimizer.FindingOverprovisioned),
+				recommendation("i-00000000000000003", "m5.2xlarge", computeoptimizer.FindingOptimized),
+				recommendation("i-00000000000000004", "c5.large", computeoptimizer.FindingOverprovisioned),
+				recommendation("i-00000000000000005", "c5.large", computeoptimizer.FindingUnderprovisioned),
+			},
+		})
+		seqNum := computeOptimizerProvider.SeqNum
+		Expect(computeOptimizerProvider.Update(ctx)).To(Succeed())
+		Expect(computeOptimizerProvider.SeqNum).ToNot(Equal(seqNum))
+
+		Expect(computeOptimizerProvider.Overprovisioned("m5.2xlarge")).To(BeTrue())
+		Expect(computeOptimizerProvider.Overprovisioned("c5.large")).To(BeFalse())
+		Expect(computeOptimizerProvider.Overprovisioned("t3.large")).To(BeFalse())
+	})
+	It("should not change the sequence number when the overprovisioned instance types don't change", func() {
+		computeOptimizerAPI.GetEC2InstanceRecommendationsOutput.Set(&computeoptimizer.GetEC2InstanceRecommendationsOutput{
+			InstanceRecommendations: []*computeoptimizer.InstanceRecommendation{
+				recommendation("i-00000000000000001", "m5.2xlarge", computeoptimizer.FindingOverprovisioned),
+			},
+		})
+		Expect(computeOptimizerProvider.Update(ctx)).To(Succeed())
+		seqNum := computeOptimizerProvider.SeqNum
+		Expect(computeOptimizerProvider.Update(ctx)).To(Succeed())
+		Expect(computeOptimizerProvider.SeqNum).To(Equal(seqNum))
+	})
+	It("should keep the previous recommendations when the update fails", func() {
+		computeOptimizerAPI.GetEC2InstanceRecommendationsOutput.Set(&computeoptimizer.GetEC2InstanceRecommendationsOutput{
+			InstanceRecommendations: []*computeoptimizer.InstanceRecommendation{
+				recommendation("i-00000000000000001", "m5.2xlarge", computeoptimizer.FindingOverprovisioned),
+			},
+		})
+		Expect(computeOptimizerProvider.Update(ctx)).To(Succeed())
+		computeOptimizerAPI.NextError.Set(errors.New("opt-in required"))
+		Expect(computeOptimizerProvider.Update(ctx)).ToNot(Succeed())
+
+		_, ok := computeOptimizerProvider.Get("i-00000000000000001")
+		Expect(ok).To(BeTrue())
+		Expect(computeOptimizerProvider.Overprovisioned("m5.2xlarge")).To(BeTrue())
+	})
+})
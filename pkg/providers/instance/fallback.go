@@ -0,0 +1,171 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+)
+
+// OnDemandFallbackPolicy controls whether and when a NodePool that allows both spot and on-demand launches on-demand
+// instances because spot capacity is unavailable. It's read from the NodePool's annotations, and allows falling back
+// right away by default.
+type OnDemandFallbackPolicy struct {
+	// Enabled is whether the NodePool falls back to on-demand at all
+	Enabled bool
+	// AfterSpotFailures is the number of spot launches that must have failed for lack of capacity since the last
+	// successful spot launch of the NodePool
+	AfterSpotFailures int
+	// After is how long spot launches of the NodePool must have been failing for lack of capacity
+	After time.Duration
+	// MaxPercent is the largest percentage of the NodePool's instances that may be on-demand fallback instances
+	MaxPercent int
+}
+
+// OnDemandFallbackPolicyFor returns the on-demand fallback policy of the NodeClaim's NodePool
+func OnDemandFallbackPolicyFor(nodeClaim *corev1beta1.NodeClaim) (OnDemandFallbackPolicy, error) {
+	enabledKey, afterSpotFailuresKey, afterKey, maxPercentKey := v1beta1.AnnotationOnDemandFallback, v1beta1.AnnotationOnDemandFallbackAfterSpotFailures,
+		v1beta1.AnnotationOnDemandFallbackAfter, v1beta1.AnnotationOnDemandFallbackMaxPercent
+	if nodeClaim.IsMachine {
+		enabledKey, afterSpotFailuresKey, afterKey, maxPercentKey = v1alpha1.AnnotationOnDemandFallback, v1alpha1.AnnotationOnDemandFallbackAfterSpotFailures,
+			v1alpha1.AnnotationOnDemandFallbackAfter, v1alpha1.AnnotationOnDemandFallbackMaxPercent
+	}
+	policy := OnDemandFallbackPolicy{Enabled: true, MaxPercent: 100}
+	if value, ok := nodeClaim.Annotations[enabledKey]; ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return OnDemandFallbackPolicy{}, fmt.Errorf("parsing annotation %s, %w", enabledKey, err)
+		}
+		policy.Enabled = enabled
+	}
+	if value, ok := nodeClaim.Annotations[afterSpotFailuresKey]; ok {
+		failures, err := strconv.Atoi(value)
+		if err != nil || failures < 0 {
+			return OnDemandFallbackPolicy{}, fmt.Errorf("parsing annotation %s, %q is not a non-negative integer", afterSpotFailuresKey, value)
+		}
+		policy.AfterSpotFailures = failures
+	}
+	if value, ok := nodeClaim.Annotations[afterKey]; ok {
+		after, err := time.ParseDuration(value)
+		if err != nil || after < 0 {
+			return OnDemandFallbackPolicy{}, fmt.Errorf("parsing annotation %s, %q is not a non-negative duration", afterKey, value)
+		}
+		policy.After = after
+	}
+	if value, ok := nodeClaim.Annotations[maxPercentKey]; ok {
+		percent, err := strconv.Atoi(value)
+		if err != nil || percent < 0 || percent > 100 {
+			return OnDemandFallbackPolicy{}, fmt.Errorf("parsing annotation %s, %q is not a percentage between 0 and 100", maxPercentKey, value)
+		}
+		policy.MaxPercent = percent
+	}
+	return policy, nil
+}
+
+// isOnDemandFallback returns true if the NodeClaim allows spot, but is launched as on-demand because none of its spot
+// offerings are available
+func (p *Provider) isOnDemandFallback(nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) bool {
+	return p.getCapacityType(nodeClaim, instanceTypes) == v1alpha5.CapacityTypeOnDemand &&
+		scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...).Get(v1alpha5.LabelCapacityType).Has(v1alpha5.CapacityTypeSpot)
+}
+
+// checkOnDemandFallback returns an insufficient capacity error if the on-demand fallback policy of the NodeClaim's
+// NodePool doesn't allow it to fall back to on-demand, so that the NodeClaim is launched again once spot capacity is
+// available or the policy allows the fallback
+func (p *Provider) checkOnDemandFallback(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) error {
+	policy, err := OnDemandFallbackPolicyFor(nodeClaim)
+	if err != nil {
+		return err
+	}
+	reason, err := p.onDemandFallbackDeniedReason(ctx, nodeClaim, policy)
+	if err != nil {
+		return err
+	}
+	OnDemandFallbacks.WithLabelValues(nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey], strconv.FormatBool(reason == "")).Inc()
+	if reason != "" {
+		return cloudprovider.NewInsufficientCapacityError(fmt.Errorf("spot capacity is unavailable and on-demand fallback isn't allowed, %s", reason))
+	}
+	return nil
+}
+
+// onDemandFallbackDeniedReason returns why the policy doesn't allow the NodeClaim to fall back to on-demand, or an
+// empty string if it does
+func (p *Provider) onDemandFallbackDeniedReason(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, policy OnDemandFallbackPolicy) (string, error) {
+	if !policy.Enabled {
+		return "on-demand fallback is disabled", nil
+	}
+	failure, _ := p.spotLaunchFailures.Get(nodeclaimutil.OwnerKey(nodeClaim))
+	if failure.Count < policy.AfterSpotFailures {
+		return fmt.Sprintf("%d of %d spot launches have failed", failure.Count, policy.AfterSpotFailures), nil
+	}
+	if policy.After > 0 && (failure.Count == 0 || p.spotLaunchFailures.FailingFor(nodeclaimutil.OwnerKey(nodeClaim)) < policy.After) {
+		return fmt.Sprintf("spot launches haven't been failing for %s", policy.After), nil
+	}
+	if policy.MaxPercent < 100 {
+		fallbacks, total, err := p.countOnDemandFallbacks(ctx, nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey])
+		if err != nil {
+			return "", err
+		}
+		// The new instance has to keep the share of fallback instances within the limit
+		if (fallbacks+1)*100 > policy.MaxPercent*(total+1) {
+			return fmt.Sprintf("%d of %d instances are already on-demand fallback instances, the limit is %d%%", fallbacks, total, policy.MaxPercent), nil
+		}
+	}
+	return "", nil
+}
+
+// countOnDemandFallbacks returns the number of the NodePool's instances that are on-demand fallback instances, and
+// the number of all its instances. Concurrent launches of the NodePool share the listing.
+func (p *Provider) countOnDemandFallbacks(ctx context.Context, nodePoolName string) (int, int, error) {
+	out, err := p.ec2Batcher.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String(fmt.Sprintf("tag:%s", v1alpha5.ProvisionerNameLabelKey)),
+				Values: aws.StringSlice([]string{nodePoolName}),
+			},
+			{
+				Name:   aws.String("tag-key"),
+				Values: aws.StringSlice([]string{fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName)}),
+			},
+			instanceStateFilter,
+		},
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("describing ec2 instances, %w", err)
+	}
+	instances, err := instancesFromOutput(out)
+	if err = cloudprovider.IgnoreMachineNotFoundError(err); err != nil {
+		return 0, 0, fmt.Errorf("getting instances from output, %w", err)
+	}
+	fallbacks := lo.CountBy(instances, func(i *Instance) bool {
+		return i.Tags[v1alpha1.TagOnDemandFallbackInstance] == "true" || i.Tags[v1beta1.TagOnDemandFallbackInstance] == "true"
+	})
+	return fallbacks, len(instances), nil
+}
@@ -16,22 +16,28 @@ package instance
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/pkg/logging"
 
 	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
 	"github.com/aws/karpenter-core/pkg/utils/resources"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
@@ -39,10 +45,14 @@ import (
 	"github.com/aws/karpenter/pkg/batcher"
 	"github.com/aws/karpenter/pkg/cache"
 	awserrors "github.com/aws/karpenter/pkg/errors"
+	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/capacityreservation"
 	"github.com/aws/karpenter/pkg/providers/instancetype"
 	"github.com/aws/karpenter/pkg/providers/launchtemplate"
 	"github.com/aws/karpenter/pkg/providers/subnet"
 	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+	"github.com/aws/karpenter/pkg/utils/tracing"
 
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
@@ -51,8 +61,22 @@ import (
 
 var (
 	// MaxInstanceTypes defines the number of instance type options to pass to CreateFleet
-	MaxInstanceTypes                 = 60
+	MaxInstanceTypes = 60
+	// MaxAttributeBasedInstanceTypes is the number of instance type options to pass to CreateFleet when instance types
+	// are selected by attributes, which is the most instance types that an override's instance requirements can allow
+	MaxAttributeBasedInstanceTypes = 400
+	// MaxLaunchTemplateConfigs is the number of launch template configs that CreateFleet accepts
+	MaxLaunchTemplateConfigs = 50
+	// MaxPersistentSpotLaunchAttempts is the number of the cheapest overrides that a spot instance that EC2 stops or
+	// hibernates on interruption is tried in, since each of them is a separate RunInstances call
+	MaxPersistentSpotLaunchAttempts  = 5
 	instanceTypeFlexibilityThreshold = 5 // falling back to on-demand without flexibility risks insufficient capacity errors
+	// SpotInterruptionThreshold is the number of spot interruptions that a NodePool must have received within the
+	// SpotInterruptionsTTL before its spot launches that span fewer pools than the aws.spotMinPools setting are capacity-optimized
+	SpotInterruptionThreshold = 2
+
+	// errAMINotFound signifies that a launch failed because one of the AMIs resolved for it no longer exists
+	errAMINotFound = errors.New("resolved ami no longer exists")
 
 	instanceStateFilter = &ec2.Filter{
 		Name:   aws.String("instance-state-name"),
@@ -61,49 +85,228 @@ var (
 )
 
 type Provider struct {
-	region                 string
-	ec2api                 ec2iface.EC2API
-	unavailableOfferings   *cache.UnavailableOfferings
+	region               string
+	ec2api               ec2iface.EC2API
+	unavailableOfferings *cache.UnavailableOfferings
+	readOnly             *cache.ReadOnly
+	spotInterruptions    *cache.SpotInterruptions
+	// spotLaunchFailures are the spot launches of each NodePool that failed for lack of capacity, which its on-demand
+	// fallback policy may require before it falls back to on-demand
+	spotLaunchFailures *cache.SpotLaunchFailures
+	// bootstrapFailures are the instance types that repeatedly failed to launch for each NodeClass, such as older
+	// generations that its AMI doesn't support
+	bootstrapFailures      *cache.BootstrapFailures
 	instanceTypeProvider   *instancetype.Provider
 	subnetProvider         *subnet.Provider
 	launchTemplateProvider *launchtemplate.Provider
-	ec2Batcher             *batcher.EC2API
+	// amiProvider is invalidated when an AMI resolved for a launch no longer exists
+	amiProvider *amifamily.Provider
+	// capacityReservationProvider resolves the capacity reservation that a NodeClaim requires by id
+	capacityReservationProvider *capacityreservation.Provider
+	ec2Batcher                  *batcher.EC2API
 }
 
 func NewProvider(ctx context.Context, region string, ec2api ec2iface.EC2API, unavailableOfferings *cache.UnavailableOfferings,
-	instanceTypeProvider *instancetype.Provider, subnetProvider *subnet.Provider, launchTemplateProvider *launchtemplate.Provider) *Provider {
+	readOnly *cache.ReadOnly, spotInterruptions *cache.SpotInterruptions, spotLaunchFailures *cache.SpotLaunchFailures,
+	bootstrapFailures *cache.BootstrapFailures, instanceTypeProvider *instancetype.Provider, subnetProvider *subnet.Provider, launchTemplateProvider *launchtemplate.Provider, amiProvider *amifamily.Provider, capacityReservationProvider *capacityreservation.Provider) *Provider {
 	return &Provider{
-		region:                 region,
-		ec2api:                 ec2api,
-		unavailableOfferings:   unavailableOfferings,
-		instanceTypeProvider:   instanceTypeProvider,
-		subnetProvider:         subnetProvider,
-		launchTemplateProvider: launchTemplateProvider,
-		ec2Batcher:             batcher.EC2(ctx, ec2api),
+		region:                      region,
+		ec2api:                      ec2api,
+		unavailableOfferings:        unavailableOfferings,
+		readOnly:                    readOnly,
+		spotInterruptions:           spotInterruptions,
+		spotLaunchFailures:          spotLaunchFailures,
+		bootstrapFailures:           bootstrapFailures,
+		instanceTypeProvider:        instanceTypeProvider,
+		subnetProvider:              subnetProvider,
+		launchTemplateProvider:      launchTemplateProvider,
+		amiProvider:                 amiProvider,
+		capacityReservationProvider: capacityReservationProvider,
+		ec2Batcher:                  batcher.EC2(ctx, ec2api),
 	}
 }
 
-func (p *Provider) Create(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) (*Instance, error) {
-	instanceTypes = p.filterInstanceTypes(nodeClaim, instanceTypes)
-	instanceTypes = orderInstanceTypesByPrice(instanceTypes, scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...))
-	if len(instanceTypes) > MaxInstanceTypes {
-		instanceTypes = instanceTypes[0:MaxInstanceTypes]
+func (p *Provider) Create(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) (_ *Instance, err error) {
+	ctx, span := tracing.Start(ctx, "instance.Create", attribute.Int("instance-type-count", len(instanceTypes)))
+	defer func() { tracing.End(span, err) }()
+	// avoid issuing launches that are known to fail until the read-only state expires and permissions can be re-checked
+	if operation, ok := p.readOnly.IsReadOnly(); ok {
+		return nil, awserrors.NewReadOnlyError(operation, fmt.Errorf("launching instance, read-only mode after access was denied to %s", operation))
+	}
+	capacityReservationID, err := getCapacityReservationID(nodeClaim)
+	if err != nil {
+		return nil, err
+	}
+	if capacityReservationID == "" {
+		capacityReservationID = p.scheduledCapacityReservationID(ctx, nodeClass, nodeClaim, instanceTypes)
+	}
+	if capacityReservationID != "" {
+		if nodeClaim, instanceTypes, err = p.targetCapacityReservation(ctx, nodeClaim, instanceTypes, capacityReservationID); err != nil {
+			return nil, err
+		}
 	}
+	if subnetID := nodeClaim.Annotations[lo.Ternary(nodeClaim.IsMachine, v1alpha1.AnnotationLaunchSubnetID, v1beta1.AnnotationLaunchSubnetID)]; subnetID != "" {
+		if nodeClass, nodeClaim, instanceTypes, err = p.targetSubnet(ctx, nodeClass, nodeClaim, instanceTypes, subnetID); err != nil {
+			return nil, err
+		}
+	}
+	instanceTypes = p.filterInstanceTypes(ctx, nodeClaim, instanceTypes)
+	instanceTypes = p.orderInstanceTypesByPrice(ctx, instanceTypes, scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...))
+	if maxInstanceTypes := lo.Ternary(nodeClass.Spec.IsAttributeBasedInstanceSelection(), MaxAttributeBasedInstanceTypes, MaxInstanceTypes); len(instanceTypes) > maxInstanceTypes {
+		instanceTypes = instanceTypes[0:maxInstanceTypes]
+	}
+	if p.isOnDemandFallback(nodeClaim, instanceTypes) {
+		if err = p.checkOnDemandFallback(ctx, nodeClaim); err != nil {
+			return nil, err
+		}
+	}
+	nodeClass = withCapacityTypeOverride(nodeClass, p.getCapacityType(nodeClaim, instanceTypes))
 	tags := getTags(ctx, nodeClass, nodeClaim)
 	fleetInstance, err := p.launchInstance(ctx, nodeClass, nodeClaim, instanceTypes, tags)
-	if awserrors.IsLaunchTemplateNotFound(err) {
-		// retry once if launch template is not found. This allows karpenter to generate a new LT if the
-		// cache was out-of-sync on the first try
+	if awserrors.IsLaunchTemplateNotFound(err) || errors.Is(err, errAMINotFound) {
+		// retry once if launch template or AMI is not found. This allows karpenter to generate a new LT, or to
+		// resolve the AMIs again if one was deregistered after it was resolved, if the cache was out-of-sync on
+		// the first try
 		fleetInstance, err = p.launchInstance(ctx, nodeClass, nodeClaim, instanceTypes, tags)
 	}
 	if err != nil {
 		return nil, err
 	}
-	return NewInstanceFromFleet(fleetInstance, tags), nil
+	instance := NewInstanceFromFleet(fleetInstance, tags)
+	instance.CapacityReservationID = capacityReservationID
+	return instance, nil
+}
+
+// withCapacityTypeOverride returns a copy of the NodeClass with the override for the capacity type applied to its tags,
+// userData and detailed monitoring, so that the instance and its launch template are configured for the capacity type
+func withCapacityTypeOverride(nodeClass *v1beta1.NodeClass, capacityType string) *v1beta1.NodeClass {
+	override, ok := nodeClass.Spec.CapacityTypeOverride(capacityType)
+	if !ok {
+		return nodeClass
+	}
+	nodeClass = nodeClass.DeepCopy()
+	nodeClass.Spec.Tags = lo.Assign(nodeClass.Spec.Tags, override.Tags)
+	if override.UserData != nil {
+		nodeClass.Spec.UserData = override.UserData
+	}
+	if override.DetailedMonitoring != nil {
+		nodeClass.Spec.DetailedMonitoring = override.DetailedMonitoring
+	}
+	return nodeClass
+}
+
+// getCapacityReservationID returns the id of the capacity reservation that the NodeClaim requires, if any. Well known
+// labels aren't propagated to the NodeClaim's labels, so it's read from the requirements, or from the launch override
+// annotation if the requirements don't name a reservation.
+func getCapacityReservationID(nodeClaim *corev1beta1.NodeClaim) (string, error) {
+	key := lo.Ternary(nodeClaim.IsMachine, v1alpha1.AnnotationLaunchCapacityReservationID, v1beta1.AnnotationLaunchCapacityReservationID)
+	override := nodeClaim.Annotations[key]
+	requirement := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...).
+		Get(lo.Ternary(nodeClaim.IsMachine, v1alpha1.LabelCapacityReservationID, v1beta1.LabelCapacityReservationID))
+	if requirement.Operator() != v1.NodeSelectorOpIn {
+		return override, nil
+	}
+	if override != "" && !requirement.Has(override) {
+		return "", fmt.Errorf("annotation %s=%s conflicts with required capacity reservation %s", key, override, requirement.Any())
+	}
+	return lo.Ternary(override != "", override, requirement.Any()), nil
+}
+
+// scheduledCapacityReservationID returns the id of a capacity reservation that is held for the scheduled capacity
+// reservations of the NodeClass and has room for the NodeClaim, if any, so that anticipated scale-ups are launched into
+// the capacity that was reserved for them
+func (p *Provider) scheduledCapacityReservationID(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim,
+	instanceTypes []*cloudprovider.InstanceType) string {
+	if len(nodeClass.Spec.ScheduledCapacityReservations) == 0 {
+		return ""
+	}
+	reservations, err := p.capacityReservationProvider.ListScheduled(ctx, nodeClass)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("listing scheduled capacity reservations, %s", err)
+		return ""
+	}
+	requirements := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)
+	if !requirements.Get(v1alpha5.LabelCapacityType).Has(v1alpha5.CapacityTypeOnDemand) {
+		return ""
+	}
+	reservation, ok := lo.Find(reservations, func(r *ec2.CapacityReservation) bool {
+		zone := aws.StringValue(r.AvailabilityZone)
+		if aws.StringValue(r.State) != ec2.CapacityReservationStateActive || aws.Int64Value(r.AvailableInstanceCount) == 0 ||
+			!requirements.Get(v1.LabelTopologyZone).Has(zone) {
+			return false
+		}
+		return lo.ContainsBy(instanceTypes, func(i *cloudprovider.InstanceType) bool {
+			return i.Name == aws.StringValue(r.InstanceType) && lo.ContainsBy(i.Offerings.Available(), func(o cloudprovider.Offering) bool {
+				return o.CapacityType == v1alpha5.CapacityTypeOnDemand && o.Zone == zone
+			})
+		})
+	})
+	if !ok {
+		return ""
+	}
+	return aws.StringValue(reservation.CapacityReservationId)
+}
+
+// targetCapacityReservation constrains the NodeClaim to the instance type, zone, and capacity type of the capacity
+// reservation that it requires, so that the instance is launched into the reservation rather than shared capacity.
+// The reservation id is added to the NodeClaim's labels so that the launch template targets it and the kubelet
+// registers the node with it.
+func (p *Provider) targetCapacityReservation(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType,
+	id string) (*corev1beta1.NodeClaim, []*cloudprovider.InstanceType, error) {
+	reservation, err := p.capacityReservationProvider.Get(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolving capacity reservation, %w", err)
+	}
+	nodeClaim = nodeClaim.DeepCopy()
+	nodeClaim.Labels = lo.Assign(nodeClaim.Labels, map[string]string{v1beta1.LabelCapacityReservationID: id})
+	nodeClaim.Spec.Requirements = append(nodeClaim.Spec.Requirements,
+		v1.NodeSelectorRequirement{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{aws.StringValue(reservation.InstanceType)}},
+		v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{aws.StringValue(reservation.AvailabilityZone)}},
+		v1.NodeSelectorRequirement{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeOnDemand}},
+	)
+	requirements := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)
+	instanceTypes = lo.Filter(instanceTypes, func(i *cloudprovider.InstanceType, _ int) bool {
+		return requirements.Compatible(i.Requirements) == nil && len(i.Offerings.Requirements(requirements).Available()) > 0
+	})
+	if len(instanceTypes) == 0 {
+		return nil, nil, fmt.Errorf("no instance types are compatible with capacity reservation %s (%s in %s)",
+			id, aws.StringValue(reservation.InstanceType), aws.StringValue(reservation.AvailabilityZone))
+	}
+	return nodeClaim, instanceTypes, nil
+}
+
+// targetSubnet constrains the launch to the subnet of the NodeClaim's launch override annotation, which must be one of
+// the subnets that the NodeClass selects so that the override can't place instances outside of the NodeClass' network.
+// The NodeClaim is constrained to the subnet's zone, and the returned NodeClass selects only the subnet.
+func (p *Provider) targetSubnet(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType,
+	id string) (*v1beta1.NodeClass, *corev1beta1.NodeClaim, []*cloudprovider.InstanceType, error) {
+	subnets, err := p.subnetProvider.List(ctx, nodeClass)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("getting subnets, %w", err)
+	}
+	subnet, ok := lo.Find(subnets, func(s *ec2.Subnet) bool { return aws.StringValue(s.SubnetId) == id })
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("launch override subnet %s is not selected by the subnetSelector", id)
+	}
+	nodeClass = nodeClass.DeepCopy()
+	nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{ID: id}}
+	nodeClaim = nodeClaim.DeepCopy()
+	nodeClaim.Spec.Requirements = append(nodeClaim.Spec.Requirements,
+		v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{aws.StringValue(subnet.AvailabilityZone)}},
+	)
+	requirements := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)
+	instanceTypes = lo.Filter(instanceTypes, func(i *cloudprovider.InstanceType, _ int) bool {
+		return requirements.Compatible(i.Requirements) == nil && len(i.Offerings.Requirements(requirements).Available()) > 0
+	})
+	if len(instanceTypes) == 0 {
+		return nil, nil, nil, fmt.Errorf("no instance types are compatible with launch override subnet %s (%s)", id, aws.StringValue(subnet.AvailabilityZone))
+	}
+	logging.FromContext(ctx).With("subnet", id).Infof("launching into the subnet of the launch override annotation")
+	return nodeClass, nodeClaim, instanceTypes, nil
 }
 
 func (p *Provider) Link(ctx context.Context, id, provisionerName string) error {
-	_, err := p.ec2api.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+	_, err := p.ec2Batcher.CreateTags(ctx, &ec2.CreateTagsInput{
 		Resources: aws.StringSlice([]string{id}),
 		Tags: []*ec2.Tag{
 			{
@@ -120,12 +323,17 @@ func (p *Provider) Link(ctx context.Context, id, provisionerName string) error {
 		if awserrors.IsNotFound(err) {
 			return cloudprovider.NewMachineNotFoundError(fmt.Errorf("linking tags, %w", err))
 		}
+		if awserrors.IsAccessDenied(err) {
+			p.readOnly.MarkDenied(ctx, "CreateTags", err)
+		}
 		return fmt.Errorf("linking tags, %w", err)
 	}
 	return nil
 }
 
-func (p *Provider) Get(ctx context.Context, id string) (*Instance, error) {
+func (p *Provider) Get(ctx context.Context, id string) (_ *Instance, err error) {
+	ctx, span := tracing.Start(ctx, "instance.Get", attribute.String("instance-id", id))
+	defer func() { tracing.End(span, err) }()
 	out, err := p.ec2Batcher.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
 		InstanceIds: aws.StringSlice([]string{id}),
 		Filters:     []*ec2.Filter{instanceStateFilter},
@@ -146,9 +354,11 @@ func (p *Provider) Get(ctx context.Context, id string) (*Instance, error) {
 	return instances[0], nil
 }
 
-func (p *Provider) List(ctx context.Context) ([]*Instance, error) {
+func (p *Provider) List(ctx context.Context) (_ []*Instance, err error) {
+	ctx, span := tracing.Start(ctx, "instance.List")
+	defer func() { tracing.End(span, err) }()
 	var out = &ec2.DescribeInstancesOutput{}
-	err := p.ec2api.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{
+	err = p.ec2api.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{
 		Filters: []*ec2.Filter{
 			{
 				Name:   aws.String("tag-key"),
@@ -171,13 +381,70 @@ func (p *Provider) List(ctx context.Context) ([]*Instance, error) {
 	return instances, cloudprovider.IgnoreMachineNotFoundError(err)
 }
 
-func (p *Provider) Delete(ctx context.Context, id string) error {
+// ModifyMetadataOptions changes the IMDS settings of a running instance
+func (p *Provider) ModifyMetadataOptions(ctx context.Context, id string, metadataOptions *v1beta1.MetadataOptions) error {
+	if _, err := p.ec2api.ModifyInstanceMetadataOptionsWithContext(ctx, &ec2.ModifyInstanceMetadataOptionsInput{
+		InstanceId:              aws.String(id),
+		HttpEndpoint:            metadataOptions.HTTPEndpoint,
+		HttpProtocolIpv6:        metadataOptions.HTTPProtocolIPv6,
+		HttpPutResponseHopLimit: metadataOptions.HTTPPutResponseHopLimit,
+		HttpTokens:              metadataOptions.HTTPTokens,
+	}); err != nil {
+		if awserrors.IsNotFound(err) {
+			return cloudprovider.NewMachineNotFoundError(err)
+		}
+		return fmt.Errorf("modifying instance metadata options, %w", err)
+	}
+	return nil
+}
+
+// ModifySecurityGroups replaces the security groups of a running instance's primary network interface. Interfaces
+// that are attached later, such as the ones the VPC CNI attaches for pod IPs, are managed by whatever attached them.
+func (p *Provider) ModifySecurityGroups(ctx context.Context, instance *Instance, securityGroupIDs []string) error {
+	if instance.PrimaryNetworkInterfaceID == "" {
+		return fmt.Errorf("instance %s has no primary network interface", instance.ID)
+	}
+	if _, err := p.ec2api.ModifyNetworkInterfaceAttributeWithContext(ctx, &ec2.ModifyNetworkInterfaceAttributeInput{
+		NetworkInterfaceId: aws.String(instance.PrimaryNetworkInterfaceID),
+		Groups:             aws.StringSlice(securityGroupIDs),
+	}); err != nil {
+		return fmt.Errorf("modifying security groups of network interface %s, %w", instance.PrimaryNetworkInterfaceID, err)
+	}
+	return nil
+}
+
+// ConsoleOutput returns the most recent serial console output of an instance, which EC2 keeps for a short time after
+// the instance is terminated
+func (p *Provider) ConsoleOutput(ctx context.Context, id string) (string, error) {
+	out, err := p.ec2api.GetConsoleOutputWithContext(ctx, &ec2.GetConsoleOutputInput{InstanceId: aws.String(id)})
+	if err != nil {
+		if awserrors.IsNotFound(err) {
+			return "", cloudprovider.NewMachineNotFoundError(err)
+		}
+		// Console output is only used to diagnose failures, so a missing permission doesn't stop other launches or
+		// terminations the way read-only mode does
+		if awserrors.IsAccessDenied(err) {
+			logging.FromContext(ctx).Errorf("getting console output, ec2:GetConsoleOutput is denied, %s", err)
+		}
+		return "", fmt.Errorf("getting console output, %w", err)
+	}
+	output, err := base64.StdEncoding.DecodeString(aws.StringValue(out.Output))
+	if err != nil {
+		return "", fmt.Errorf("decoding console output, %w", err)
+	}
+	return string(output), nil
+}
+
+func (p *Provider) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := tracing.Start(ctx, "instance.Delete", attribute.String("instance-id", id))
+	defer func() { tracing.End(span, err) }()
 	if _, err := p.ec2Batcher.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
 		InstanceIds: []*string{aws.String(id)},
 	}); err != nil {
 		if awserrors.IsNotFound(err) {
 			return cloudprovider.NewMachineNotFoundError(fmt.Errorf("instance already terminated"))
 		}
+		// A denied TerminateInstances doesn't stop launches, since read-only mode only guards the calls that launch instances
 		if _, e := p.Get(ctx, id); err != nil {
 			if cloudprovider.IsMachineNotFoundError(e) {
 				return e
@@ -197,13 +464,23 @@ func (p *Provider) launchInstance(ctx context.Context, nodeClass *v1beta1.NodeCl
 	}
 
 	// Get Launch Template Configs, which may differ due to GPU or Architecture requirements
+	start := time.Now()
 	launchTemplateConfigs, err := p.getLaunchTemplateConfigs(ctx, nodeClass, nodeClaim, instanceTypes, zonalSubnets, capacityType, tags)
 	if err != nil {
+		// launches can't succeed without the launch templates, so a denied CreateLaunchTemplate stops them like CreateFleet
+		if operation, ok := awserrors.ReadOnlyOperation(err); ok {
+			p.readOnly.MarkDenied(ctx, operation, err)
+		}
 		return nil, fmt.Errorf("getting launch template configs, %w", err)
 	}
+	ObservePhase(ctx, PhaseLaunchTemplate, nodeclaimutil.OwnerKey(nodeClaim).Name, time.Since(start))
 	if err := p.checkODFallback(nodeClaim, instanceTypes, launchTemplateConfigs); err != nil {
 		logging.FromContext(ctx).Warn(err.Error())
 	}
+	instanceTags := tags
+	if p.isOnDemandFallback(nodeClaim, instanceTypes) {
+		instanceTags = lo.Assign(tags, map[string]string{lo.Ternary(nodeClaim.IsMachine, v1alpha1.TagOnDemandFallbackInstance, v1beta1.TagOnDemandFallbackInstance): "true"})
+	}
 	// Create fleet
 	createFleetInput := &ec2.CreateFleetInput{
 		Type:                  aws.String(ec2.FleetTypeInstant),
@@ -214,18 +491,43 @@ func (p *Provider) launchInstance(ctx context.Context, nodeClass *v1beta1.NodeCl
 			TotalTargetCapacity:       aws.Int64(1),
 		},
 		TagSpecifications: []*ec2.TagSpecification{
-			{ResourceType: aws.String(ec2.ResourceTypeInstance), Tags: utils.MergeTags(tags)},
-			{ResourceType: aws.String(ec2.ResourceTypeVolume), Tags: utils.MergeTags(tags)},
-			{ResourceType: aws.String(ec2.ResourceTypeFleet), Tags: utils.MergeTags(tags)},
+			{ResourceType: aws.String(ec2.ResourceTypeInstance), Tags: utils.MergeTags(instanceTags)},
+			{ResourceType: aws.String(ec2.ResourceTypeVolume), Tags: utils.MergeTags(withoutDisabledOwnershipTags(ctx, tags))},
+			{ResourceType: aws.String(ec2.ResourceTypeFleet), Tags: utils.MergeTags(withoutDisabledOwnershipTags(ctx, tags))},
 		},
 	}
 	if capacityType == v1alpha5.CapacityTypeSpot {
-		createFleetInput.SpotOptions = &ec2.SpotOptionsRequest{AllocationStrategy: aws.String(ec2.SpotAllocationStrategyPriceCapacityOptimized)}
+		behavior, err := SpotInterruptionBehavior(nodeClaim)
+		if err != nil {
+			return nil, err
+		}
+		if behavior != ec2.SpotInstanceInterruptionBehaviorTerminate {
+			return p.runPersistentSpotInstance(ctx, nodeClaim, launchTemplateConfigs, instanceTypes, []*ec2.TagSpecification{
+				{ResourceType: aws.String(ec2.ResourceTypeInstance), Tags: utils.MergeTags(instanceTags)},
+				{ResourceType: aws.String(ec2.ResourceTypeVolume), Tags: utils.MergeTags(withoutDisabledOwnershipTags(ctx, tags))},
+				{ResourceType: aws.String(ec2.ResourceTypeSpotInstancesRequest), Tags: utils.MergeTags(withoutDisabledOwnershipTags(ctx, tags))},
+			}, behavior)
+		}
+		allocationStrategy := ec2.SpotAllocationStrategyPriceCapacityOptimized
+		// If the NodePool's spot capacity keeps being reclaimed from the few pools that it can launch into, we'd rather
+		// launch into the deepest of them than the cheapest
+		if p.shouldDiversifySpot(ctx, nodeClaim, instanceTypes) {
+			allocationStrategy = ec2.SpotAllocationStrategyCapacityOptimized
+		}
+		createFleetInput.SpotOptions = &ec2.SpotOptionsRequest{AllocationStrategy: aws.String(allocationStrategy)}
 	} else {
-		createFleetInput.OnDemandOptions = &ec2.OnDemandOptionsRequest{AllocationStrategy: aws.String(ec2.FleetOnDemandAllocationStrategyLowestPrice)}
+		allocationStrategy := ec2.FleetOnDemandAllocationStrategyLowestPrice
+		if p.prioritizeOverrides(ctx, launchTemplateConfigs, instanceTypes) {
+			allocationStrategy = ec2.FleetOnDemandAllocationStrategyPrioritized
+		}
+		createFleetInput.OnDemandOptions = &ec2.OnDemandOptionsRequest{AllocationStrategy: aws.String(allocationStrategy)}
 	}
 
-	createFleetOutput, err := p.ec2Batcher.CreateFleet(ctx, createFleetInput)
+	start = time.Now()
+	fleetCtx, span := tracing.Start(ctx, "ec2.CreateFleet", attribute.String("capacity-type", capacityType))
+	createFleetOutput, err := p.ec2Batcher.CreateFleet(fleetCtx, createFleetInput)
+	tracing.End(span, err)
+	ObservePhase(ctx, PhaseCreateFleet, nodeclaimutil.OwnerKey(nodeClaim).Name, time.Since(start))
 	p.subnetProvider.UpdateInflightIPs(createFleetInput, createFleetOutput, instanceTypes, lo.Values(zonalSubnets), capacityType)
 	if err != nil {
 		if awserrors.IsLaunchTemplateNotFound(err) {
@@ -234,22 +536,159 @@ func (p *Provider) launchInstance(ctx context.Context, nodeClass *v1beta1.NodeCl
 			}
 			return nil, fmt.Errorf("creating fleet %w", err)
 		}
+		if awserrors.IsAMINotFound(err) {
+			p.amiProvider.Invalidate(ctx, nodeClass)
+			return nil, fmt.Errorf("creating fleet, %w, %w", errAMINotFound, err)
+		}
+		if awserrors.IsAccessDenied(err) {
+			p.readOnly.MarkDenied(ctx, "CreateFleet", err)
+			err = awserrors.NewReadOnlyError("CreateFleet", err)
+		}
 		var reqFailure awserr.RequestFailure
 		if errors.As(err, &reqFailure) {
 			return nil, fmt.Errorf("creating fleet %w (%s)", err, reqFailure.RequestID())
 		}
 		return nil, fmt.Errorf("creating fleet %w", err)
 	}
-	p.updateUnavailableOfferingsCache(ctx, createFleetOutput.Errors, capacityType)
+	p.updateUnavailableOfferingsCache(ctx, createFleetOutput.Errors, instanceTypes, capacityType)
+	p.updateBootstrapFailuresCache(ctx, nodeClass, nodeClaim, createFleetInput, instanceTypes, createFleetOutput.Errors)
 	if len(createFleetOutput.Instances) == 0 || len(createFleetOutput.Instances[0].InstanceIds) == 0 {
-		return nil, combineFleetErrors(createFleetOutput.Errors)
+		err = combineFleetErrors(createFleetOutput.Errors)
+		if capacityType == v1alpha5.CapacityTypeSpot && cloudprovider.IsInsufficientCapacityError(err) {
+			p.spotLaunchFailures.MarkFailed(nodeclaimutil.OwnerKey(nodeClaim))
+		}
+		if lo.SomeBy(createFleetOutput.Errors, awserrors.IsAMINotFoundFleetErr) {
+			p.amiProvider.Invalidate(ctx, nodeClass)
+			return nil, fmt.Errorf("%w, %w", errAMINotFound, err)
+		}
+		// only enter read-only mode if every override was denied, since a single denied override could be scoped by IAM conditions
+		if len(createFleetOutput.Errors) > 0 && lo.EveryBy(createFleetOutput.Errors, awserrors.IsAccessDeniedFleetErr) {
+			p.readOnly.MarkDenied(ctx, "CreateFleet", err)
+			return nil, awserrors.NewReadOnlyError("CreateFleet", err)
+		}
+		return nil, err
+	}
+	if capacityType == v1alpha5.CapacityTypeSpot {
+		p.spotLaunchFailures.MarkSucceeded(nodeclaimutil.OwnerKey(nodeClaim))
 	}
 	return createFleetOutput.Instances[0], nil
 }
 
+// runPersistentSpotInstance launches a spot instance for a persistent spot request, since EC2 only stops or hibernates
+// the spot instances of persistent requests on interruption, and instant fleets only make one-time requests. The
+// overrides are tried one at a time in the order of their instance types, which are ordered by price, until one of
+// them has capacity.
+func (p *Provider) runPersistentSpotInstance(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest,
+	instanceTypes []*cloudprovider.InstanceType, tagSpecifications []*ec2.TagSpecification, behavior string) (*ec2.CreateFleetInstance, error) {
+	type candidate struct {
+		launchTemplate *ec2.FleetLaunchTemplateSpecificationRequest
+		override       *ec2.FleetLaunchTemplateOverridesRequest
+	}
+	ranks := lo.SliceToMap(lo.Range(len(instanceTypes)), func(i int) (string, int) { return instanceTypes[i].Name, i })
+	var candidates []candidate
+	for _, config := range launchTemplateConfigs {
+		for _, override := range config.Overrides {
+			if override.InstanceType == nil {
+				return nil, fmt.Errorf("spot interruption behavior %s can't be combined with attribute-based instance selection", behavior)
+			}
+			candidates = append(candidates, candidate{launchTemplate: config.LaunchTemplateSpecification, override: override})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return ranks[aws.StringValue(candidates[i].override.InstanceType)] < ranks[aws.StringValue(candidates[j].override.InstanceType)]
+	})
+	if len(candidates) > MaxPersistentSpotLaunchAttempts {
+		candidates = candidates[:MaxPersistentSpotLaunchAttempts]
+	}
+	var errs error
+	for _, c := range candidates {
+		reservation, err := p.ec2api.RunInstancesWithContext(ctx, &ec2.RunInstancesInput{
+			LaunchTemplate: &ec2.LaunchTemplateSpecification{
+				LaunchTemplateId:   c.launchTemplate.LaunchTemplateId,
+				LaunchTemplateName: c.launchTemplate.LaunchTemplateName,
+				Version:            c.launchTemplate.Version,
+			},
+			InstanceType: c.override.InstanceType,
+			SubnetId:     c.override.SubnetId,
+			Placement:    &ec2.Placement{AvailabilityZone: c.override.AvailabilityZone},
+			MinCount:     aws.Int64(1),
+			MaxCount:     aws.Int64(1),
+			InstanceMarketOptions: &ec2.InstanceMarketOptionsRequest{
+				MarketType: aws.String(ec2.MarketTypeSpot),
+				SpotOptions: &ec2.SpotMarketOptions{
+					SpotInstanceType:             aws.String(ec2.SpotInstanceTypePersistent),
+					InstanceInterruptionBehavior: aws.String(behavior),
+				},
+			},
+			TagSpecifications: tagSpecifications,
+		})
+		if err != nil {
+			if awserrors.IsUnfulfillableCapacityErr(err) {
+				var awsError awserr.Error
+				errors.As(err, &awsError)
+				p.unavailableOfferings.MarkUnavailable(ctx, awsError.Code(), aws.StringValue(c.override.InstanceType), aws.StringValue(c.override.AvailabilityZone), v1alpha5.CapacityTypeSpot)
+				errs = multierr.Append(errs, err)
+				continue
+			}
+			if awserrors.IsLaunchTemplateNotFound(err) {
+				p.launchTemplateProvider.Invalidate(ctx, aws.StringValue(c.launchTemplate.LaunchTemplateName), aws.StringValue(c.launchTemplate.LaunchTemplateId))
+			}
+			return nil, fmt.Errorf("running persistent spot instance, %w", err)
+		}
+		p.spotLaunchFailures.MarkSucceeded(nodeclaimutil.OwnerKey(nodeClaim))
+		return &ec2.CreateFleetInstance{
+			InstanceIds:  []*string{reservation.Instances[0].InstanceId},
+			InstanceType: c.override.InstanceType,
+			Lifecycle:    aws.String(v1alpha5.CapacityTypeSpot),
+			LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+				Overrides: &ec2.FleetLaunchTemplateOverrides{
+					InstanceType:     c.override.InstanceType,
+					SubnetId:         c.override.SubnetId,
+					AvailabilityZone: c.override.AvailabilityZone,
+				},
+			},
+		}, nil
+	}
+	p.spotLaunchFailures.MarkFailed(nodeclaimutil.OwnerKey(nodeClaim))
+	return nil, cloudprovider.NewInsufficientCapacityError(fmt.Errorf("running persistent spot instance, %w", errs))
+}
+
+// SpotInterruptionBehavior returns what EC2 does with the NodeClaim's spot instance when it is interrupted,
+// which is terminate unless the NodePool's annotations ask for the instance to be stopped or hibernated
+func SpotInterruptionBehavior(nodeClaim *corev1beta1.NodeClaim) (string, error) {
+	key := lo.Ternary(nodeClaim.IsMachine, v1alpha1.AnnotationSpotInterruptionBehavior, v1beta1.AnnotationSpotInterruptionBehavior)
+	value, ok := nodeClaim.Annotations[key]
+	if !ok {
+		return ec2.SpotInstanceInterruptionBehaviorTerminate, nil
+	}
+	if !lo.Contains(ec2.SpotInstanceInterruptionBehavior_Values(), value) {
+		return "", fmt.Errorf("parsing annotation %s, %q is not one of %v", key, value, ec2.SpotInstanceInterruptionBehavior_Values())
+	}
+	return value, nil
+}
+
+// CancelSpotInstanceRequest cancels the spot request that the instance was launched for, so that EC2 doesn't launch
+// another instance for a persistent request once the instance is terminated
+func (p *Provider) CancelSpotInstanceRequest(ctx context.Context, id string) error {
+	instance, err := p.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if instance.SpotInstanceRequestID == "" {
+		return nil
+	}
+	if _, err := p.ec2api.CancelSpotInstanceRequestsWithContext(ctx, &ec2.CancelSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: []*string{aws.String(instance.SpotInstanceRequestID)},
+	}); err != nil && !awserrors.IsNotFound(err) {
+		return fmt.Errorf("canceling spot instance request, %w", err)
+	}
+	return nil
+}
+
 func getTags(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim) map[string]string {
-	overridableTags := map[string]string{
-		"Name": fmt.Sprintf("%s/%s", v1alpha5.ProvisionerNameLabelKey, nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey]),
+	overridableTags := map[string]string{}
+	if !lo.Contains(settings.FromContext(ctx).DisabledManagedTags, "Name") {
+		overridableTags["Name"] = fmt.Sprintf("%s/%s", v1alpha5.ProvisionerNameLabelKey, nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey])
 	}
 	staticTags := map[string]string{
 		fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName): "owned",
@@ -259,9 +698,19 @@ func getTags(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev
 	return lo.Assign(overridableTags, settings.FromContext(ctx).Tags, nodeClass.Spec.Tags, staticTags)
 }
 
+// withoutDisabledOwnershipTags returns the tags without the karpenter.sh/managed-by tag if aws.disabledManagedTags
+// disables it. It's applied to every resource but the instance, which keeps it so that instances can still be
+// attributed to the cluster.
+func withoutDisabledOwnershipTags(ctx context.Context, tags map[string]string) map[string]string {
+	if !lo.Contains(settings.FromContext(ctx).DisabledManagedTags, v1alpha5.MachineManagedByAnnotationKey) {
+		return tags
+	}
+	return lo.OmitByKeys(tags, []string{v1alpha5.MachineManagedByAnnotationKey})
+}
+
 func (p *Provider) checkODFallback(nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType, launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest) error {
 	// only evaluate for on-demand fallback if the capacity type for the request is OD and both OD and spot are allowed in requirements
-	if p.getCapacityType(nodeClaim, instanceTypes) != v1alpha5.CapacityTypeOnDemand || !scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...).Get(v1alpha5.LabelCapacityType).Has(v1alpha5.CapacityTypeSpot) {
+	if !p.isOnDemandFallback(nodeClaim, instanceTypes) {
 		return nil
 	}
 
@@ -285,13 +734,31 @@ func (p *Provider) checkODFallback(nodeClaim *corev1beta1.NodeClaim, instanceTyp
 func (p *Provider) getLaunchTemplateConfigs(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim,
 	instanceTypes []*cloudprovider.InstanceType, zonalSubnets map[string]*ec2.Subnet, capacityType string, tags map[string]string) ([]*ec2.FleetLaunchTemplateConfigRequest, error) {
 	var launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest
-	launchTemplates, err := p.launchTemplateProvider.EnsureAll(ctx, nodeClass, nodeClaim, instanceTypes, map[string]string{v1alpha5.LabelCapacityType: capacityType}, tags)
+	launchTemplates, err := p.launchTemplateProvider.EnsureAll(ctx, nodeClass, nodeClaim, instanceTypes, map[string]string{v1alpha5.LabelCapacityType: capacityType},
+		withoutDisabledOwnershipTags(ctx, tags))
 	if err != nil {
 		return nil, fmt.Errorf("getting launch templates, %w", err)
 	}
-	for launchTemplateName, instanceTypes := range launchTemplates {
+	zones := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...).Get(v1.LabelTopologyZone)
+	// instanceTypes are ordered by price, so launch templates are ranked by their cheapest instance type
+	priceRanks := lo.SliceToMap(lo.Range(len(instanceTypes)), func(i int) (string, int) { return instanceTypes[i].Name, i })
+	ranks := map[*ec2.FleetLaunchTemplateConfigRequest]int{}
+	for launchTemplateName, launchTemplate := range launchTemplates {
+		subnets := zonalSubnets
+		// AMIs on an Outpost can only be launched into the Outpost's subnets, rather than into every subnet of its zones
+		if launchTemplate.OutpostARN != "" {
+			if subnets, err = p.subnetProvider.ZonalSubnetsOnOutpost(ctx, nodeClass, launchTemplate.OutpostARN); err != nil {
+				return nil, fmt.Errorf("getting outpost subnets, %w", err)
+			}
+		}
+		overrides := p.getOverrides(launchTemplate.InstanceTypes, subnets, zones, capacityType)
+		if nodeClass.Spec.IsAttributeBasedInstanceSelection() {
+			if overrides, err = p.getAttributeBasedOverrides(ctx, nodeClass, nodeClaim, launchTemplate.InstanceTypes, subnets, zones, capacityType); err != nil {
+				return nil, err
+			}
+		}
 		launchTemplateConfig := &ec2.FleetLaunchTemplateConfigRequest{
-			Overrides: p.getOverrides(instanceTypes, zonalSubnets, scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...).Get(v1.LabelTopologyZone), capacityType),
+			Overrides: overrides,
 			LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
 				LaunchTemplateName: aws.String(launchTemplateName),
 				Version:            aws.String("$Latest"),
@@ -299,11 +766,19 @@ func (p *Provider) getLaunchTemplateConfigs(ctx context.Context, nodeClass *v1be
 		}
 		if len(launchTemplateConfig.Overrides) > 0 {
 			launchTemplateConfigs = append(launchTemplateConfigs, launchTemplateConfig)
+			ranks[launchTemplateConfig] = lo.Min(lo.Map(launchTemplate.InstanceTypes, func(it *cloudprovider.InstanceType, _ int) int { return priceRanks[it.Name] }))
 		}
 	}
 	if len(launchTemplateConfigs) == 0 {
 		return nil, fmt.Errorf("no capacity offerings are currently available given the constraints")
 	}
+	// Instance types whose kubelet configuration or CPU options differ get their own launch templates, which can exceed
+	// what CreateFleet accepts, so only the launch templates of the cheapest instance types are launched with
+	if len(launchTemplateConfigs) > MaxLaunchTemplateConfigs {
+		sort.SliceStable(launchTemplateConfigs, func(i, j int) bool { return ranks[launchTemplateConfigs[i]] < ranks[launchTemplateConfigs[j]] })
+		logging.FromContext(ctx).With("launch-templates", len(launchTemplateConfigs)).Debugf("launching with the %d launch templates of the cheapest instance types", MaxLaunchTemplateConfigs)
+		launchTemplateConfigs = launchTemplateConfigs[:MaxLaunchTemplateConfigs]
+	}
 	return launchTemplateConfigs, nil
 }
 
@@ -350,10 +825,177 @@ func (p *Provider) getOverrides(instanceTypes []*cloudprovider.InstanceType, zon
 	return overrides
 }
 
-func (p *Provider) updateUnavailableOfferingsCache(ctx context.Context, errors []*ec2.CreateFleetError, capacityType string) {
+// getAttributeBasedOverrides creates and returns a launch template override for each zone that the instance types have
+// offerings in, which lets EC2 Fleet choose the instance type by its instance requirements rather than passing an
+// override for each instance type
+func (p *Provider) getAttributeBasedOverrides(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType,
+	zonalSubnets map[string]*ec2.Subnet, zones *scheduling.Requirement, capacityType string) ([]*ec2.FleetLaunchTemplateOverridesRequest, error) {
+	instanceTypesByZone := map[string][]*cloudprovider.InstanceType{}
+	for _, it := range instanceTypes {
+		for _, offering := range it.Offerings.Available() {
+			if offering.CapacityType == capacityType && zones.Has(offering.Zone) {
+				instanceTypesByZone[offering.Zone] = append(instanceTypesByZone[offering.Zone], it)
+			}
+		}
+	}
+	launchable, err := p.launchableInstanceTypes(ctx, nodeClass, nodeClaim)
+	if err != nil {
+		return nil, err
+	}
+	allInstanceTypes, err := p.instanceTypeProvider.GetInstanceTypes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting instance types, %w", err)
+	}
+	var overrides []*ec2.FleetLaunchTemplateOverridesRequest
+	for _, zone := range lo.Keys(instanceTypesByZone) {
+		subnet, ok := zonalSubnets[zone]
+		if !ok {
+			continue
+		}
+		requirements := instanceRequirements(instanceTypesByZone[zone])
+		requirements.AllowedInstanceTypes = allowedInstanceTypes(instanceTypesByZone[zone], requirements, allInstanceTypes, func(instanceType string) bool {
+			it, ok := launchable[instanceType]
+			return ok && lo.ContainsBy(it.Offerings.Available(), func(o cloudprovider.Offering) bool {
+				return o.Zone == zone && o.CapacityType == capacityType
+			})
+		})
+		overrides = append(overrides, &ec2.FleetLaunchTemplateOverridesRequest{
+			InstanceRequirements: requirements,
+			SubnetId:             subnet.SubnetId,
+			AvailabilityZone:     subnet.AvailabilityZone,
+		})
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return aws.StringValue(overrides[i].AvailabilityZone) < aws.StringValue(overrides[j].AvailabilityZone)
+	})
+	return overrides, nil
+}
+
+// launchableInstanceTypes returns the instance types of the NodeClass that the NodeClaim's requirements allow, by name.
+// The instance type requirement is kept, since it carries the instance types that the NodePool allows or excludes.
+func (p *Provider) launchableInstanceTypes(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim) (map[string]*cloudprovider.InstanceType, error) {
+	instanceTypes, err := p.instanceTypeProvider.List(ctx, nodeClaim.Spec.KubeletConfiguration, nodeClass)
+	if err != nil {
+		return nil, fmt.Errorf("getting instance types, %w", err)
+	}
+	requirements := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)
+	return lo.SliceToMap(lo.Filter(instanceTypes, func(it *cloudprovider.InstanceType, _ int) bool {
+		return requirements.Compatible(it.Requirements) == nil
+	}), func(it *cloudprovider.InstanceType) (string, *cloudprovider.InstanceType) { return it.Name, it }), nil
+}
+
+// instanceRequirements returns the instance requirements that span the vCPUs, memory and accelerators of the instance
+// types. Bare metal, burstable and previous generation instance types are included, since EC2 Fleet excludes them by
+// default, and the instance types that it may choose are narrowed by the allowed instance types instead.
+func instanceRequirements(instanceTypes []*cloudprovider.InstanceType) *ec2.InstanceRequirementsRequest {
+	vcpus := lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) int64 { return labelValue(it, v1alpha1.LabelInstanceCPU) })
+	memory := lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) int64 { return labelValue(it, v1alpha1.LabelInstanceMemory) })
+	accelerators := lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) int64 {
+		return lo.Max([]int64{labelValue(it, v1alpha1.LabelInstanceGPUCount), labelValue(it, v1alpha1.LabelInstanceAcceleratorCount)})
+	})
+	return &ec2.InstanceRequirementsRequest{
+		VCpuCount:            &ec2.VCpuCountRangeRequest{Min: aws.Int64(lo.Min(vcpus)), Max: aws.Int64(lo.Max(vcpus))},
+		MemoryMiB:            &ec2.MemoryMiBRequest{Min: aws.Int64(lo.Min(memory)), Max: aws.Int64(lo.Max(memory))},
+		AcceleratorCount:     &ec2.AcceleratorCountRequest{Min: aws.Int64(lo.Min(accelerators)), Max: aws.Int64(lo.Max(accelerators))},
+		BareMetal:            aws.String(ec2.BareMetalIncluded),
+		BurstablePerformance: aws.String(ec2.BurstablePerformanceIncluded),
+		InstanceGenerations:  aws.StringSlice([]string{ec2.InstanceGenerationCurrent, ec2.InstanceGenerationPrevious}),
+	}
+}
+
+// allowedInstanceTypes returns the instance type patterns that EC2 Fleet may choose from within the instance
+// requirements. The family of each instance type is allowed as a whole, so that EC2 Fleet can choose any of its sizes
+// within the vCPU and memory ranges, unless one of those sizes can't be launched, e.g. because the NodeClaim's
+// requirements, including the instance types of its NodePool, exclude it, its offering is unavailable or it failed to
+// bootstrap. The family is then limited to the
+// instance types that were scheduled against.
+func allowedInstanceTypes(instanceTypes []*cloudprovider.InstanceType, requirements *ec2.InstanceRequirementsRequest, allInstanceTypes []*ec2.InstanceTypeInfo,
+	isLaunchable func(string) bool) []*string {
+	inRange := func(info *ec2.InstanceTypeInfo) bool {
+		vcpus, memory := aws.Int64Value(info.VCpuInfo.DefaultVCpus), aws.Int64Value(info.MemoryInfo.SizeInMiB)
+		return vcpus >= aws.Int64Value(requirements.VCpuCount.Min) && vcpus <= aws.Int64Value(requirements.VCpuCount.Max) &&
+			memory >= aws.Int64Value(requirements.MemoryMiB.Min) && memory <= aws.Int64Value(requirements.MemoryMiB.Max)
+	}
+	sizesByFamily := lo.GroupBy(lo.Filter(allInstanceTypes, func(info *ec2.InstanceTypeInfo, _ int) bool { return inRange(info) }),
+		func(info *ec2.InstanceTypeInfo) string { return instanceFamily(aws.StringValue(info.InstanceType)) })
+	allowed := sets.New[string]()
+	for family, familyInstanceTypes := range lo.GroupBy(instanceTypes, func(it *cloudprovider.InstanceType) string { return instanceFamily(it.Name) }) {
+		if lo.EveryBy(sizesByFamily[family], func(info *ec2.InstanceTypeInfo) bool { return isLaunchable(aws.StringValue(info.InstanceType)) }) {
+			allowed.Insert(family + ".*")
+			continue
+		}
+		allowed.Insert(lo.Map(familyInstanceTypes, func(it *cloudprovider.InstanceType, _ int) string { return it.Name })...)
+	}
+	return aws.StringSlice(sets.List(allowed))
+}
+
+// instanceFamily returns the family of an instance type, e.g. m5 for m5.large
+func instanceFamily(instanceType string) string {
+	family, _, _ := strings.Cut(instanceType, ".")
+	return family
+}
+
+func labelValue(it *cloudprovider.InstanceType, key string) int64 {
+	value, _ := strconv.ParseInt(it.Requirements.Get(key).Any(), 10, 64)
+	return value
+}
+
+// updateUnavailableOfferingsCache marks the offerings that EC2 Fleet had no capacity for as unavailable. Errors for
+// overrides with instance requirements don't always name an instance type, in which case none of the instance types
+// had capacity in the zone.
+func (p *Provider) updateUnavailableOfferingsCache(ctx context.Context, errors []*ec2.CreateFleetError, instanceTypes []*cloudprovider.InstanceType, capacityType string) {
 	for _, err := range errors {
-		if awserrors.IsUnfulfillableCapacity(err) {
-			p.unavailableOfferings.MarkUnavailableForFleetErr(ctx, err, capacityType)
+		if !awserrors.IsUnfulfillableCapacity(err) {
+			continue
+		}
+		if err.LaunchTemplateAndOverrides != nil && err.LaunchTemplateAndOverrides.Overrides != nil &&
+			err.LaunchTemplateAndOverrides.Overrides.InstanceType == nil && err.LaunchTemplateAndOverrides.Overrides.InstanceRequirements != nil {
+			zone := aws.StringValue(err.LaunchTemplateAndOverrides.Overrides.AvailabilityZone)
+			for _, it := range instanceTypes {
+				if lo.ContainsBy(it.Offerings.Available(), func(o cloudprovider.Offering) bool { return o.Zone == zone && o.CapacityType == capacityType }) {
+					p.unavailableOfferings.MarkUnavailable(ctx, aws.StringValue(err.ErrorCode), it.Name, zone, capacityType)
+				}
+			}
+			continue
+		}
+		p.unavailableOfferings.MarkUnavailableForFleetErr(ctx, err, capacityType)
+	}
+}
+
+// updateBootstrapFailuresCache records the instance types that can't be launched with their launch templates, so
+// that an instance type that keeps failing, such as a Xen instance type with an AMI that requires the Nitro System,
+// is excluded from the NodeClass for a while. When every instance type of the fleet failed, the NodeClass itself is
+// more likely to be misconfigured, so nothing is recorded. Overrides with instance requirements request every instance
+// type that they were created for.
+func (p *Provider) updateBootstrapFailuresCache(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim,
+	createFleetInput *ec2.CreateFleetInput, instanceTypes []*cloudprovider.InstanceType, errors []*ec2.CreateFleetError) {
+	failed := map[string]*ec2.CreateFleetError{}
+	for _, err := range errors {
+		if err.LaunchTemplateAndOverrides == nil || err.LaunchTemplateAndOverrides.Overrides == nil || !awserrors.IsIncompatibleInstanceTypeFleetErr(err) {
+			continue
+		}
+		if instanceType := aws.StringValue(err.LaunchTemplateAndOverrides.Overrides.InstanceType); instanceType != "" {
+			failed[instanceType] = err
+		}
+	}
+	requested := sets.New[string]()
+	for _, config := range createFleetInput.LaunchTemplateConfigs {
+		for _, override := range config.Overrides {
+			if override.InstanceType == nil && override.InstanceRequirements != nil {
+				requested.Insert(lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) string { return it.Name })...)
+				continue
+			}
+			requested.Insert(aws.StringValue(override.InstanceType))
+		}
+	}
+	if len(failed) == 0 || sets.KeySet(failed).IsSuperset(requested.Delete("")) {
+		return
+	}
+	key := nodeclassutil.Key{Name: nodeClass.Name, IsNodeTemplate: nodeClass.IsNodeTemplate}
+	for instanceType, err := range failed {
+		if p.bootstrapFailures.MarkFailed(key, instanceType, nodeClaim.Name, fmt.Sprintf("%s: %s", aws.StringValue(err.ErrorCode), aws.StringValue(err.ErrorMessage))) {
+			logging.FromContext(ctx).With("instance-type", instanceType, "ttl", cache.BootstrapFailureExclusionTTL).
+				Infof("excluding instance type from node class after %d nodes failed to launch, %s", cache.BootstrapFailureThreshold, aws.StringValue(err.ErrorMessage))
 		}
 	}
 }
@@ -376,17 +1018,18 @@ func (p *Provider) getCapacityType(nodeClaim *corev1beta1.NodeClaim, instanceTyp
 	return v1alpha5.CapacityTypeOnDemand
 }
 
-func orderInstanceTypesByPrice(instanceTypes []*cloudprovider.InstanceType, requirements scheduling.Requirements) []*cloudprovider.InstanceType {
+func (p *Provider) orderInstanceTypesByPrice(ctx context.Context, instanceTypes []*cloudprovider.InstanceType, requirements scheduling.Requirements) []*cloudprovider.InstanceType {
 	// Order instance types so that we get the cheapest instance types of the available offerings
-	sort.Slice(instanceTypes, func(i, j int) bool {
-		iPrice := math.MaxFloat64
-		jPrice := math.MaxFloat64
-		if len(instanceTypes[i].Offerings.Available().Requirements(requirements)) > 0 {
-			iPrice = instanceTypes[i].Offerings.Available().Requirements(requirements).Cheapest().Price
-		}
-		if len(instanceTypes[j].Offerings.Available().Requirements(requirements)) > 0 {
-			jPrice = instanceTypes[j].Offerings.Available().Requirements(requirements).Cheapest().Price
+	prices := lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, float64) {
+		price := math.MaxFloat64
+		for _, offering := range it.Offerings.Available().Requirements(requirements) {
+			price = math.Min(price, p.scoredPrice(ctx, it.Name, offering))
 		}
+		return it.Name, price
+	})
+	sort.Slice(instanceTypes, func(i, j int) bool {
+		iPrice := prices[instanceTypes[i].Name]
+		jPrice := prices[instanceTypes[j].Name]
 		if iPrice == jPrice {
 			return instanceTypes[i].Name < instanceTypes[j].Name
 		}
@@ -395,16 +1038,96 @@ func orderInstanceTypesByPrice(instanceTypes []*cloudprovider.InstanceType, requ
 	return instanceTypes
 }
 
+// scoredPrice returns the price of the offering, biased by the aws.computeOptimizerPriceBias setting and raised by up
+// to the aws.offeringScorePriceTolerance setting as its score falls, so that offerings that are more likely to be
+// fulfilled are preferred over similarly priced ones
+func (p *Provider) scoredPrice(ctx context.Context, instanceType string, offering cloudprovider.Offering) float64 {
+	price := p.instanceTypeProvider.BiasPrice(ctx, instanceType, offering.Price)
+	tolerance := settings.FromContext(ctx).OfferingScorePriceTolerance
+	if tolerance == 0 {
+		return price
+	}
+	score := p.instanceTypeProvider.OfferingScore(instanceType, offering.Zone, offering.CapacityType)
+	return price * (1 + tolerance*float64(instancetype.MaxOfferingScore-score)/instancetype.MaxOfferingScore)
+}
+
+// prioritizeOverrides prioritizes the on-demand launch template overrides by the scored price of their offerings, so
+// that EC2 Fleet launches the override with the lowest scored price that has capacity. It returns false, and leaves the
+// overrides unprioritized, when offerings aren't scored or the overrides select instance types by their attributes.
+func (p *Provider) prioritizeOverrides(ctx context.Context, launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest, instanceTypes []*cloudprovider.InstanceType) bool {
+	if settings.FromContext(ctx).OfferingScorePriceTolerance == 0 {
+		return false
+	}
+	instanceTypesByName := lo.KeyBy(instanceTypes, func(it *cloudprovider.InstanceType) string { return it.Name })
+	overrides := lo.FlatMap(launchTemplateConfigs, func(ltc *ec2.FleetLaunchTemplateConfigRequest, _ int) []*ec2.FleetLaunchTemplateOverridesRequest {
+		return ltc.Overrides
+	})
+	prices := map[*ec2.FleetLaunchTemplateOverridesRequest]float64{}
+	for _, override := range overrides {
+		it, ok := instanceTypesByName[aws.StringValue(override.InstanceType)]
+		if !ok {
+			return false
+		}
+		offering, ok := lo.Find(it.Offerings, func(o cloudprovider.Offering) bool {
+			return o.CapacityType == v1alpha5.CapacityTypeOnDemand && o.Zone == aws.StringValue(override.AvailabilityZone)
+		})
+		if !ok {
+			return false
+		}
+		prices[override] = p.scoredPrice(ctx, it.Name, offering)
+	}
+	sort.SliceStable(overrides, func(i, j int) bool { return prices[overrides[i]] < prices[overrides[j]] })
+	for i, override := range overrides {
+		override.Priority = aws.Float64(float64(i))
+	}
+	return true
+}
+
 // filterInstanceTypes is used to provide filtering on the list of potential instance types to further limit it to those
 // that make the most sense given our specific AWS cloudprovider.
-func (p *Provider) filterInstanceTypes(nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) []*cloudprovider.InstanceType {
-	instanceTypes = filterExoticInstanceTypes(instanceTypes)
+func (p *Provider) filterInstanceTypes(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) []*cloudprovider.InstanceType {
+	filtered := filterExoticInstanceTypes(instanceTypes)
 	// If we could potentially launch either a spot or on-demand node, we want to filter out the spot instance types that
 	// are more expensive than the cheapest on-demand type.
-	if p.isMixedCapacityLaunch(nodeClaim, instanceTypes) {
-		instanceTypes = filterUnwantedSpot(instanceTypes)
+	if p.isMixedCapacityLaunch(nodeClaim, filtered) {
+		filtered = filterUnwantedSpot(filtered)
 	}
-	return instanceTypes
+	return filtered
+}
+
+// shouldDiversifySpot returns true if a spot launch for the NodeClaim should ask EC2 Fleet to launch into the capacity
+// pools with the most spare capacity rather than weighing in their price. This is the case when the instance types
+// span fewer spot capacity pools than the aws.spotMinPools setting asks for, and the NodeClaim's NodePool has recently
+// been interrupted at least SpotInterruptionThreshold times.
+func (p *Provider) shouldDiversifySpot(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) bool {
+	minPools := settings.FromContext(ctx).SpotMinPools
+	if minPools == 0 || p.getCapacityType(nodeClaim, instanceTypes) != v1alpha5.CapacityTypeSpot {
+		return false
+	}
+	interruptions := p.spotInterruptions.Count(nodeclaimutil.OwnerKey(nodeClaim))
+	if interruptions < SpotInterruptionThreshold {
+		return false
+	}
+	requirements := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)
+	pools := spotPools(instanceTypes, requirements)
+	if pools >= minPools {
+		return false
+	}
+	logging.FromContext(ctx).With(
+		"interruptions", interruptions,
+		"pools", pools,
+		"min-pools", minPools).Debugf("launching spot into the capacity pools with the most spare capacity")
+	return true
+}
+
+// spotPools returns the number of spot capacity pools, i.e. instance type and zone pairs, that the instance types can
+// launch into given the requirements
+func spotPools(instanceTypes []*cloudprovider.InstanceType, requirements scheduling.Requirements) int {
+	return lo.SumBy(instanceTypes, func(it *cloudprovider.InstanceType) int {
+		return lo.CountBy(it.Offerings.Available(), func(o cloudprovider.Offering) bool {
+			return o.CapacityType == v1alpha5.CapacityTypeSpot && requirements.Get(v1.LabelTopologyZone).Has(o.Zone)
+		})
+	})
 }
 
 // isMixedCapacityLaunch returns true if provisioners and available offerings could potentially allow either a spot or
@@ -0,0 +1,91 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+)
+
+// Phases of launching a node, used as the value of the phase label
+const (
+	// PhaseResolution is the time spent resolving the node class and instance types for the launch
+	PhaseResolution = "resolution"
+	// PhaseLaunchTemplate is the time spent finding or creating the launch templates for the launch
+	PhaseLaunchTemplate = "launch_template"
+	// PhaseCreateFleet is the time spent calling CreateFleet
+	PhaseCreateFleet = "create_fleet"
+	// PhaseRegistration is the time between the instance being launched and its node registering with the cluster
+	PhaseRegistration = "registration"
+	// PhaseInitialization is the time between the node registering and it becoming initialized and ready
+	PhaseInitialization = "initialization"
+)
+
+var (
+	phaseLabel       = "phase"
+	provisionerLabel = "provisioner"
+	allowedLabel     = "allowed"
+
+	LaunchPhaseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "launch_phase_duration_seconds",
+			Help:      "Duration of each phase of launching a node in seconds. Labeled by phase and provisioner.",
+			Buckets:   metrics.DurationBuckets(),
+		},
+		[]string{
+			phaseLabel,
+			provisionerLabel,
+		},
+	)
+	OnDemandFallbacks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "on_demand_fallbacks",
+			Help:      "Number of launches that would fall back from spot to on-demand because spot capacity is unavailable. Labeled by provisioner and whether its on-demand fallback policy allowed the fallback.",
+		},
+		[]string{
+			provisionerLabel,
+			allowedLabel,
+		},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(LaunchPhaseDuration, OnDemandFallbacks)
+}
+
+// ObservePhase records the duration of a launch phase for the provisioner, and its completion in the timeline of the
+// context, if any
+func ObservePhase(ctx context.Context, phase, provisionerName string, duration time.Duration) {
+	LaunchPhaseDuration.With(prometheus.Labels{
+		phaseLabel:       phase,
+		provisionerLabel: provisionerName,
+	}).Observe(duration.Seconds())
+	if timeline, ok := ctx.Value(timelineKey{}).(*Timeline); ok {
+		timeline.complete(phase, time.Now())
+	}
+}
@@ -16,15 +16,20 @@ package instance_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
 	. "knative.dev/pkg/logging/testing"
 
 	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
@@ -37,11 +42,15 @@ import (
 	coretest "github.com/aws/karpenter-core/pkg/test"
 	. "github.com/aws/karpenter-core/pkg/test/expectations"
 	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/cloudprovider"
+	awserrors "github.com/aws/karpenter/pkg/errors"
 	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/providers/instance"
 	"github.com/aws/karpenter/pkg/test"
 	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 )
@@ -67,7 +76,7 @@ var _ = BeforeSuite(func() {
 	ctx = settings.ToContext(ctx, test.Settings())
 	awsEnv = test.NewEnvironment(ctx, env)
 	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, events.NewRecorder(&record.FakeRecorder{}),
-		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider)
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.WarmUp, &clock.RealClock{})
 })
 
 var _ = AfterSuite(func() {
@@ -135,4 +144,739 @@ var _ = Describe("InstanceProvider", func() {
 		Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
 		Expect(instance).To(BeNil())
 	})
+	It("should return an ICE error once the capacity of the capacity pools is exhausted", func() {
+		ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+		for _, pool := range []fake.CapacityPool{
+			{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1b"},
+			{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
+			{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: "m5.xlarge", Zone: "test-zone-1b"},
+		} {
+			awsEnv.EC2API.AvailableCapacity.Set(pool, 0)
+		}
+		awsEnv.EC2API.AvailableCapacity.Set(fake.CapacityPool{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1a"}, 1)
+		instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+		Expect(err).ToNot(HaveOccurred())
+		instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool { return i.Name == "m5.xlarge" })
+
+		instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+		Expect(err).ToNot(HaveOccurred())
+
+		// The only pool with capacity is now exhausted
+		_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+		Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
+
+		// Terminating the instance returns its capacity to the pool
+		Expect(awsEnv.InstanceProvider.Delete(ctx, instance.ID)).To(Succeed())
+		awsEnv.UnavailableOfferingsCache.Flush()
+		_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	It("should stop launching instances after being denied access to CreateFleet", func() {
+		ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+		awsEnv.EC2API.CreateFleetBehavior.Error.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+		instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+		Expect(err).ToNot(HaveOccurred())
+
+		instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+		Expect(err).To(HaveOccurred())
+		Expect(instance).To(BeNil())
+		Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(Equal(1))
+		operation, ok := awserrors.ReadOnlyOperation(err)
+		Expect(ok).To(BeTrue())
+		Expect(operation).To(Equal("CreateFleet"))
+		_, readOnly := awsEnv.ReadOnlyCache.IsReadOnly()
+		Expect(readOnly).To(BeTrue())
+
+		// Subsequent launches should be short-circuited without calling CreateFleet
+		instance, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+		Expect(err).To(HaveOccurred())
+		Expect(instance).To(BeNil())
+		Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(Equal(1))
+	})
+	It("should stop launching instances after being denied access to CreateLaunchTemplate", func() {
+		ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+		awsEnv.EC2API.CreateLaunchTemplateError.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+		instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+		Expect(err).ToNot(HaveOccurred())
+
+		instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+		Expect(err).To(HaveOccurred())
+		Expect(instance).To(BeNil())
+		Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(BeZero())
+		operation, ok := awserrors.ReadOnlyOperation(err)
+		Expect(ok).To(BeTrue())
+		Expect(operation).To(Equal("CreateLaunchTemplate"))
+		operation, readOnly := awsEnv.ReadOnlyCache.IsReadOnly()
+		Expect(readOnly).To(BeTrue())
+		Expect(operation).To(Equal("CreateLaunchTemplate"))
+	})
+	It("should not stop launching instances after being denied access to TerminateInstances", func() {
+		ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+		instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+		Expect(err).ToNot(HaveOccurred())
+		instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+		Expect(err).ToNot(HaveOccurred())
+
+		awsEnv.EC2API.TerminateInstancesBehavior.Error.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+		err = awsEnv.InstanceProvider.Delete(ctx, instance.ID)
+		Expect(err).To(HaveOccurred())
+		Expect(awserrors.IsAccessDenied(err)).To(BeTrue())
+		_, readOnly := awsEnv.ReadOnlyCache.IsReadOnly()
+		Expect(readOnly).To(BeFalse())
+
+		_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(Equal(2))
+	})
+	It("should not stop launching instances after being denied access to GetConsoleOutput", func() {
+		awsEnv.EC2API.GetConsoleOutputBehavior.Error.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+		_, err := awsEnv.InstanceProvider.ConsoleOutput(ctx, fake.InstanceID())
+		Expect(err).To(HaveOccurred())
+		Expect(awserrors.IsAccessDenied(err)).To(BeTrue())
+		_, readOnly := awsEnv.ReadOnlyCache.IsReadOnly()
+		Expect(readOnly).To(BeFalse())
+	})
+	Context("Spot Interruption Behavior", func() {
+		var instanceTypes []*corecloudprovider.InstanceType
+		BeforeEach(func() {
+			awsEnv.Reset()
+			machine.Annotations = map[string]string{v1alpha1.AnnotationSpotInterruptionBehavior: "hibernate"}
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}},
+			}
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			var err error
+			instanceTypes, err = cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.large" || i.Name == "m5.xlarge"
+			})
+		})
+		It("should launch a persistent spot request with the spot interruption behavior set on the machine", func() {
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.CapacityType).To(Equal(v1alpha5.CapacityTypeSpot))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(BeZero())
+			Expect(awsEnv.EC2API.RunInstancesBehavior.CalledWithInput.Len()).To(Equal(1))
+			input := awsEnv.EC2API.RunInstancesBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(input.InstanceMarketOptions.MarketType)).To(Equal(ec2.MarketTypeSpot))
+			Expect(aws.StringValue(input.InstanceMarketOptions.SpotOptions.SpotInstanceType)).To(Equal(ec2.SpotInstanceTypePersistent))
+			Expect(aws.StringValue(input.InstanceMarketOptions.SpotOptions.InstanceInterruptionBehavior)).To(Equal("hibernate"))
+			Expect(aws.StringValue(input.InstanceType)).To(Equal("m5.large"))
+			Expect(lo.Map(input.TagSpecifications, func(t *ec2.TagSpecification, _ int) string { return aws.StringValue(t.ResourceType) })).
+				To(ConsistOf(ec2.ResourceTypeInstance, ec2.ResourceTypeVolume, ec2.ResourceTypeSpotInstancesRequest))
+		})
+		It("should try the next instance type when a persistent spot request has no capacity", func() {
+			for _, zone := range []string{"test-zone-1a", "test-zone-1b", "test-zone-1c"} {
+				awsEnv.EC2API.InsufficientCapacityPools.Add(fake.CapacityPool{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: "m5.large", Zone: zone})
+			}
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Type).To(Equal("m5.xlarge"))
+			Expect(awsEnv.EC2API.RunInstancesBehavior.CalledWithInput.Len()).To(BeNumerically(">", 1))
+			Expect(awsEnv.UnavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)).To(BeTrue())
+		})
+		It("should return an insufficient capacity error when no persistent spot request has capacity", func() {
+			for _, zone := range []string{"test-zone-1a", "test-zone-1b", "test-zone-1c"} {
+				for _, instanceType := range []string{"m5.large", "m5.xlarge"} {
+					awsEnv.EC2API.InsufficientCapacityPools.Add(fake.CapacityPool{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: instanceType, Zone: zone})
+				}
+			}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(awsEnv.EC2API.RunInstancesBehavior.CalledWithInput.Len()).To(BeNumerically("<=", instance.MaxPersistentSpotLaunchAttempts))
+		})
+		It("should fail to launch when the spot interruption behavior is invalid", func() {
+			machine.Annotations = map[string]string{v1alpha1.AnnotationSpotInterruptionBehavior: "pause"}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).To(HaveOccurred())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(BeZero())
+			Expect(awsEnv.EC2API.RunInstancesBehavior.Calls()).To(BeZero())
+		})
+		It("should cancel the spot request of the instance", func() {
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceProvider.CancelSpotInstanceRequest(ctx, instance.ID)).To(Succeed())
+			Expect(awsEnv.EC2API.CancelSpotInstanceRequestsBehavior.CalledWithInput.Len()).To(Equal(1))
+			Expect(awsEnv.EC2API.CancelSpotInstanceRequestsBehavior.CalledWithInput.Pop().SpotInstanceRequestIds).To(HaveLen(1))
+		})
+	})
+	Context("Launch Failures", func() {
+		var instanceTypes []*corecloudprovider.InstanceType
+		incompatible := func(instanceType string) *ec2.CreateFleetError {
+			return &ec2.CreateFleetError{
+				ErrorCode:    aws.String("InvalidParameterCombination"),
+				ErrorMessage: aws.String(fmt.Sprintf("Enhanced networking with the Elastic Network Adapter (ENA) is required for the '%s' instance type. Ensure that you are using an AMI that is enabled for ENA.", instanceType)),
+				LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+					Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String(instanceType), AvailabilityZone: aws.String("test-zone-1a")},
+				},
+			}
+		}
+		failWith := func(errs ...*ec2.CreateFleetError) {
+			awsEnv.EC2API.CreateFleetBehavior.Output.Set(&ec2.CreateFleetOutput{
+				Instances: []*ec2.CreateFleetInstance{{
+					InstanceIds:  aws.StringSlice([]string{coretest.RandomName()}),
+					InstanceType: aws.String("m5.large"),
+					LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+						Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1a")},
+					},
+				}},
+				Errors: errs,
+			})
+		}
+		launch := func(name string) {
+			m := machine.DeepCopy()
+			m.Name = name
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(m), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		BeforeEach(func() {
+			awsEnv.Reset()
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			var err error
+			instanceTypes, err = cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return lo.Contains([]string{"m5.large", "m5.xlarge"}, i.Name)
+			})
+		})
+		It("should exclude an instance type from the node template after it repeatedly fails to launch", func() {
+			key := nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true}
+			failWith(incompatible("m5.xlarge"))
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.xlarge")).To(BeFalse())
+				launch(coretest.RandomName())
+			}
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.xlarge")).To(BeTrue())
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.large")).To(BeFalse())
+			Expect(awsEnv.BootstrapFailuresCache.Excluded(key)["m5.xlarge"].Reason).To(Equal("InvalidParameterCombination: Enhanced networking with the Elastic Network Adapter (ENA) is required for the 'm5.xlarge' instance type. Ensure that you are using an AMI that is enabled for ENA."))
+		})
+		It("should count a machine that fails to launch the same instance type more than once as one failure", func() {
+			failWith(incompatible("m5.xlarge"))
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				launch(machine.Name)
+			}
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true}, "m5.xlarge")).To(BeFalse())
+		})
+		It("should not exclude instance types that fail for lack of capacity", func() {
+			err := incompatible("m5.xlarge")
+			err.ErrorCode = aws.String("InsufficientInstanceCapacity")
+			failWith(err)
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				launch(coretest.RandomName())
+			}
+			Expect(awsEnv.BootstrapFailuresCache.Excluded(nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true})).To(BeEmpty())
+		})
+		It("should not exclude instance types that fail because of their zone", func() {
+			err := incompatible("m5.xlarge")
+			err.ErrorMessage = aws.String("The requested configuration is currently not supported. Please check the documentation for supported configurations.")
+			failWith(err)
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				launch(coretest.RandomName())
+			}
+			Expect(awsEnv.BootstrapFailuresCache.Excluded(nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true})).To(BeEmpty())
+		})
+		It("should not exclude instance types when every instance type of the launch fails", func() {
+			failWith(incompatible("m5.large"), incompatible("m5.xlarge"))
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				launch(coretest.RandomName())
+			}
+			Expect(awsEnv.BootstrapFailuresCache.Excluded(nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true})).To(BeEmpty())
+		})
+	})
+	Context("Spot Diversification", func() {
+		var instanceTypes []*corecloudprovider.InstanceType
+		BeforeEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{SpotMinPools: lo.ToPtr(4)}))
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}},
+			}
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			var err error
+			instanceTypes, err = cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			// m5.xlarge alone spans fewer spot pools than required, and m5.metal is filtered out
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.xlarge" || i.Name == "m5.metal"
+			})
+		})
+		var createFleetInput *ec2.CreateFleetInput
+		launchedInstanceTypes := func() []string {
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput = awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			return lo.Uniq(lo.FlatMap(createFleetInput.LaunchTemplateConfigs, func(ltc *ec2.FleetLaunchTemplateConfigRequest, _ int) []string {
+				return lo.Map(ltc.Overrides, func(o *ec2.FleetLaunchTemplateOverridesRequest, _ int) string { return aws.StringValue(o.InstanceType) })
+			}))
+		}
+		It("should launch into the deepest spot pools when the provisioner was recently interrupted", func() {
+			for i := 0; i < instance.SpotInterruptionThreshold; i++ {
+				awsEnv.SpotInterruptionsCache.MarkInterrupted(nodepoolutil.Key{Name: provisioner.Name, IsProvisioner: true}, coretest.RandomName())
+			}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			// metal instance types are still filtered out when generic ones would work
+			Expect(launchedInstanceTypes()).To(ConsistOf("m5.xlarge"))
+			Expect(aws.StringValue(createFleetInput.SpotOptions.AllocationStrategy)).To(Equal(ec2.SpotAllocationStrategyCapacityOptimized))
+		})
+		It("should not diversify when the provisioner wasn't interrupted often enough", func() {
+			for i := 0; i < instance.SpotInterruptionThreshold-1; i++ {
+				awsEnv.SpotInterruptionsCache.MarkInterrupted(nodepoolutil.Key{Name: provisioner.Name, IsProvisioner: true}, coretest.RandomName())
+			}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launchedInstanceTypes()).To(ConsistOf("m5.xlarge"))
+			Expect(aws.StringValue(createFleetInput.SpotOptions.AllocationStrategy)).To(Equal(ec2.SpotAllocationStrategyPriceCapacityOptimized))
+		})
+		It("should not diversify when the filtered instance types already span enough spot pools", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{SpotMinPools: lo.ToPtr(2)}))
+			for i := 0; i < instance.SpotInterruptionThreshold; i++ {
+				awsEnv.SpotInterruptionsCache.MarkInterrupted(nodepoolutil.Key{Name: provisioner.Name, IsProvisioner: true}, coretest.RandomName())
+			}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launchedInstanceTypes()).To(ConsistOf("m5.xlarge"))
+			Expect(aws.StringValue(createFleetInput.SpotOptions.AllocationStrategy)).To(Equal(ec2.SpotAllocationStrategyPriceCapacityOptimized))
+		})
+		It("should not diversify when spotMinPools is disabled", func() {
+			ctx = settings.ToContext(ctx, test.Settings())
+			for i := 0; i < instance.SpotInterruptionThreshold; i++ {
+				awsEnv.SpotInterruptionsCache.MarkInterrupted(nodepoolutil.Key{Name: provisioner.Name, IsProvisioner: true}, coretest.RandomName())
+			}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launchedInstanceTypes()).To(ConsistOf("m5.xlarge"))
+			Expect(aws.StringValue(createFleetInput.SpotOptions.AllocationStrategy)).To(Equal(ec2.SpotAllocationStrategyPriceCapacityOptimized))
+		})
+	})
+	Context("On-Demand Fallback", func() {
+		var instanceTypes []*corecloudprovider.InstanceType
+		BeforeEach(func() {
+			awsEnv.Reset()
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}},
+			}
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			var err error
+			instanceTypes, err = cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool { return i.Name == "m5.xlarge" })
+			// Spot capacity is unavailable in every zone, so the machine can only be launched as on-demand
+			for _, zone := range []string{"test-zone-1a", "test-zone-1b", "test-zone-1c"} {
+				awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "test", "m5.xlarge", zone, v1alpha5.CapacityTypeSpot)
+			}
+			instanceTypes, err = cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool { return i.Name == "m5.xlarge" })
+		})
+		markSpotFailed := func(times int) {
+			for i := 0; i < times; i++ {
+				awsEnv.SpotLaunchFailuresCache.MarkFailed(nodepoolutil.Key{Name: provisioner.Name, IsProvisioner: true})
+			}
+		}
+		fallbackInstance := func(tagged bool) *ec2.Instance {
+			tags := []*ec2.Tag{{Key: aws.String(v1alpha5.ProvisionerNameLabelKey), Value: aws.String(provisioner.Name)}}
+			if tagged {
+				tags = append(tags, &ec2.Tag{Key: aws.String(v1alpha1.TagOnDemandFallbackInstance), Value: aws.String("true")})
+			}
+			return &ec2.Instance{
+				InstanceId:   aws.String(fake.InstanceID()),
+				InstanceType: aws.String("m5.xlarge"),
+				Placement:    &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+				State:        &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+				Tags: append(tags, &ec2.Tag{
+					Key:   aws.String("kubernetes.io/cluster/" + settings.FromContext(ctx).ClusterName),
+					Value: aws.String("owned"),
+				}),
+			}
+		}
+		It("should fall back to on-demand and tag the instance by default", func() {
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(createFleetInput.TargetCapacitySpecification.DefaultTargetCapacityType)).To(Equal(v1alpha5.CapacityTypeOnDemand))
+			tagSpecification, ok := lo.Find(createFleetInput.TagSpecifications, func(t *ec2.TagSpecification) bool {
+				return aws.StringValue(t.ResourceType) == ec2.ResourceTypeInstance
+			})
+			Expect(ok).To(BeTrue())
+			Expect(tagSpecification.Tags).To(ContainElement(&ec2.Tag{Key: aws.String(v1alpha1.TagOnDemandFallbackInstance), Value: aws.String("true")}))
+		})
+		It("should not tag on-demand instances that didn't fall back from spot", func() {
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeOnDemand}},
+			}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, tagSpecification := range createFleetInput.TagSpecifications {
+				Expect(tagSpecification.Tags).ToNot(ContainElement(HaveField("Key", HaveValue(Equal(v1alpha1.TagOnDemandFallbackInstance)))))
+			}
+		})
+		It("should return an ICE error when on-demand fallback is disabled", func() {
+			machine.Annotations = map[string]string{v1alpha1.AnnotationOnDemandFallback: "false"}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(Equal(0))
+		})
+		It("should fall back to on-demand once enough spot launches have failed", func() {
+			machine.Annotations = map[string]string{v1alpha1.AnnotationOnDemandFallbackAfterSpotFailures: "3"}
+			markSpotFailed(2)
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
+
+			markSpotFailed(1)
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("should not fall back to on-demand after a spot launch succeeds", func() {
+			machine.Annotations = map[string]string{v1alpha1.AnnotationOnDemandFallbackAfterSpotFailures: "1"}
+			markSpotFailed(1)
+			awsEnv.SpotLaunchFailuresCache.MarkSucceeded(nodepoolutil.Key{Name: provisioner.Name, IsProvisioner: true})
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
+		})
+		It("should not fall back to on-demand until spot launches have been failing for long enough", func() {
+			machine.Annotations = map[string]string{v1alpha1.AnnotationOnDemandFallbackAfter: "1h"}
+			markSpotFailed(5)
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
+
+			awsEnv.Clock.Step(time.Hour)
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("should fall back to on-demand while the share of fallback instances is within the limit", func() {
+			machine.Annotations = map[string]string{v1alpha1.AnnotationOnDemandFallbackMaxPercent: "50"}
+			for _, i := range []*ec2.Instance{fallbackInstance(true), fallbackInstance(false), fallbackInstance(false)} {
+				awsEnv.EC2API.Instances.Store(aws.StringValue(i.InstanceId), i)
+			}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("should not fall back to on-demand when the share of fallback instances would exceed the limit", func() {
+			machine.Annotations = map[string]string{v1alpha1.AnnotationOnDemandFallbackMaxPercent: "50"}
+			for _, i := range []*ec2.Instance{fallbackInstance(true), fallbackInstance(false)} {
+				awsEnv.EC2API.Instances.Store(aws.StringValue(i.InstanceId), i)
+			}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(Equal(0))
+		})
+		It("should fail to launch when the on-demand fallback policy is invalid", func() {
+			machine.Annotations = map[string]string{v1alpha1.AnnotationOnDemandFallbackMaxPercent: "150"}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).To(HaveOccurred())
+			Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeFalse())
+		})
+	})
+	Context("Outposts", func() {
+		outpostARN := "arn:aws:outposts:us-west-2:111122223333:outpost/op-0123456789abcdef0"
+		BeforeEach(func() {
+			awsEnv.Reset()
+			nodeTemplate.Spec.AMISelector = map[string]string{"aws::ids": "ami-regional,ami-outpost"}
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeOnDemand}},
+			}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{Name: aws.String("golden-regional"), ImageId: aws.String("ami-regional"), CreationDate: aws.String("2023-08-01T00:00:00Z"), Architecture: aws.String("x86_64")},
+				{Name: aws.String("golden-outpost"), ImageId: aws.String("ami-outpost"), CreationDate: aws.String("2023-09-01T00:00:00Z"), Architecture: aws.String("x86_64"),
+					BlockDeviceMappings: []*ec2.BlockDeviceMapping{{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{OutpostArn: aws.String(outpostARN)}}}},
+			}})
+			// The regional subnet in the Outpost's zone has more available IP addresses than the Outpost's subnet
+			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				{SubnetId: aws.String("subnet-regional-1a"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(1000),
+					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("regional-1a")}}},
+				{SubnetId: aws.String("subnet-regional-1b"), AvailabilityZone: aws.String("test-zone-1b"), AvailableIpAddressCount: aws.Int64(1000),
+					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("regional-1b")}}},
+				{SubnetId: aws.String("subnet-outpost"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(10), OutpostArn: aws.String(outpostARN),
+					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("outpost")}}},
+			}})
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+		})
+		It("should only launch AMIs on an Outpost into the Outpost's subnets", func() {
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool { return i.Name == "m5.xlarge" })
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			input := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(input.LaunchTemplateConfigs).To(HaveLen(2))
+			imageIDs := map[string]string{}
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(in *ec2.CreateLaunchTemplateInput) {
+				imageIDs[aws.StringValue(in.LaunchTemplateName)] = aws.StringValue(in.LaunchTemplateData.ImageId)
+			})
+			subnetsByAMI := map[string][]string{}
+			for _, config := range input.LaunchTemplateConfigs {
+				imageID := imageIDs[aws.StringValue(config.LaunchTemplateSpecification.LaunchTemplateName)]
+				for _, override := range config.Overrides {
+					subnetsByAMI[imageID] = lo.Uniq(append(subnetsByAMI[imageID], aws.StringValue(override.SubnetId)))
+				}
+			}
+			Expect(subnetsByAMI["ami-outpost"]).To(ConsistOf("subnet-outpost"))
+			Expect(subnetsByAMI["ami-regional"]).To(ConsistOf("subnet-regional-1b"))
+		})
+	})
+	Context("Attribute-Based Instance Selection", func() {
+		var instanceTypes []*corecloudprovider.InstanceType
+		BeforeEach(func() {
+			awsEnv.Reset()
+			nodeTemplate.Spec.InstanceSelectionMode = lo.ToPtr(v1alpha1.InstanceSelectionModeAttributeBased)
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeOnDemand}},
+			}
+			// Fixing max pods lets the instance types share a launch template
+			machine.Spec.Kubelet = &v1alpha5.KubeletConfiguration{MaxPods: lo.ToPtr[int32](110)}
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+		})
+		getInstanceTypes := func() []*corecloudprovider.InstanceType {
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			return lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.large" || i.Name == "m5.xlarge"
+			})
+		}
+		overridesByZone := func() map[string]*ec2.FleetLaunchTemplateOverridesRequest {
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(createFleetInput.LaunchTemplateConfigs).To(HaveLen(1))
+			overrides := createFleetInput.LaunchTemplateConfigs[0].Overrides
+			byZone := lo.KeyBy(overrides, func(o *ec2.FleetLaunchTemplateOverridesRequest) string { return aws.StringValue(o.AvailabilityZone) })
+			Expect(byZone).To(HaveLen(len(overrides)))
+			return byZone
+		}
+		BeforeEach(func() {
+			instanceTypes = getInstanceTypes()
+		})
+		It("should pass an override with instance requirements for each zone", func() {
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			overrides := overridesByZone()
+			Expect(overrides).To(HaveLen(3))
+			for _, override := range overrides {
+				Expect(override.InstanceType).To(BeNil())
+				Expect(override.SubnetId).ToNot(BeNil())
+				Expect(override.InstanceRequirements.MemoryMiB.Min).To(Equal(aws.Int64(8192)))
+				Expect(override.InstanceRequirements.AcceleratorCount).To(Equal(&ec2.AcceleratorCountRequest{Min: aws.Int64(0), Max: aws.Int64(0)}))
+			}
+			// m5.xlarge is only offered in test-zone-1a and test-zone-1b
+			for _, zone := range []string{"test-zone-1a", "test-zone-1b"} {
+				Expect(aws.StringValueSlice(overrides[zone].InstanceRequirements.AllowedInstanceTypes)).To(Equal([]string{"m5.*"}))
+				Expect(overrides[zone].InstanceRequirements.VCpuCount).To(Equal(&ec2.VCpuCountRangeRequest{Min: aws.Int64(2), Max: aws.Int64(4)}))
+				Expect(overrides[zone].InstanceRequirements.MemoryMiB.Max).To(Equal(aws.Int64(16384)))
+			}
+			Expect(aws.StringValueSlice(overrides["test-zone-1c"].InstanceRequirements.AllowedInstanceTypes)).To(Equal([]string{"m5.*"}))
+			Expect(overrides["test-zone-1c"].InstanceRequirements.VCpuCount).To(Equal(&ec2.VCpuCountRangeRequest{Min: aws.Int64(2), Max: aws.Int64(2)}))
+			Expect(overrides["test-zone-1c"].InstanceRequirements.MemoryMiB.Max).To(Equal(aws.Int64(8192)))
+		})
+		It("should only allow instance types with an available offering in the zone", func() {
+			awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "test", "m5.xlarge", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)
+			instanceTypes = getInstanceTypes()
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			overrides := overridesByZone()
+			Expect(overrides["test-zone-1a"].InstanceRequirements.VCpuCount).To(Equal(&ec2.VCpuCountRangeRequest{Min: aws.Int64(2), Max: aws.Int64(2)}))
+			Expect(overrides["test-zone-1b"].InstanceRequirements.VCpuCount).To(Equal(&ec2.VCpuCountRangeRequest{Min: aws.Int64(2), Max: aws.Int64(4)}))
+		})
+		It("should only allow the instance types that were scheduled against when other sizes of their family can't be launched", func() {
+			machine.Spec.Requirements = append(machine.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key: v1alpha1.LabelInstanceSize, Operator: v1.NodeSelectorOpNotIn, Values: []string{"xlarge"},
+			})
+			allInstanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(allInstanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.large" || i.Name == "m5.metal"
+			})
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			for _, override := range overridesByZone() {
+				Expect(aws.StringValueSlice(override.InstanceRequirements.AllowedInstanceTypes)).To(Equal([]string{"m5.large", "m5.metal"}))
+			}
+		})
+		It("should not allow the sizes of a family that the machine's instance types exclude", func() {
+			describeInstanceTypes, err := awsEnv.EC2API.DescribeInstanceTypesWithContext(ctx, &ec2.DescribeInstanceTypesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			xlarge, ok := lo.Find(describeInstanceTypes.InstanceTypes, func(info *ec2.InstanceTypeInfo) bool { return aws.StringValue(info.InstanceType) == "m5.xlarge" })
+			Expect(ok).To(BeTrue())
+			twoXLarge := lo.ToPtr(*xlarge)
+			twoXLarge.InstanceType = aws.String("m5.2xlarge")
+			twoXLarge.VCpuInfo = &ec2.VCpuInfo{DefaultCores: aws.Int64(4), DefaultVCpus: aws.Int64(8)}
+			twoXLarge.MemoryInfo = &ec2.MemoryInfo{SizeInMiB: aws.Int64(32768)}
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{InstanceTypes: append(describeInstanceTypes.InstanceTypes, twoXLarge)})
+			describeOfferings, err := awsEnv.EC2API.DescribeInstanceTypeOfferingsWithContext(ctx, &ec2.DescribeInstanceTypeOfferingsInput{})
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.EC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{
+				InstanceTypeOfferings: append(describeOfferings.InstanceTypeOfferings, lo.Map([]string{"test-zone-1a", "test-zone-1b", "test-zone-1c"}, func(zone string, _ int) *ec2.InstanceTypeOffering {
+					return &ec2.InstanceTypeOffering{InstanceType: aws.String("m5.2xlarge"), Location: aws.String(zone)}
+				})...),
+			})
+			awsEnv.InstanceTypeCache.Flush()
+			// The NodePool's instance type requirement ends up in the requirements of its machines
+			machine.Spec.Requirements = append(machine.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpNotIn, Values: []string{"m5.2xlarge"},
+			})
+			allInstanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(allInstanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.large" || i.Name == "m5.metal"
+			})
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			overrides := overridesByZone()
+			Expect(overrides).ToNot(BeEmpty())
+			for _, override := range overrides {
+				Expect(aws.StringValueSlice(override.InstanceRequirements.AllowedInstanceTypes)).ToNot(ContainElement("m5.*"))
+				Expect(aws.StringValueSlice(override.InstanceRequirements.AllowedInstanceTypes)).ToNot(ContainElement("m5.2xlarge"))
+			}
+		})
+		It("should mark every instance type of the zone as unavailable when the fleet has no capacity for the instance requirements", func() {
+			awsEnv.EC2API.CreateFleetBehavior.Output.Set(&ec2.CreateFleetOutput{Errors: []*ec2.CreateFleetError{{
+				ErrorCode: aws.String("InsufficientInstanceCapacity"),
+				LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{Overrides: &ec2.FleetLaunchTemplateOverrides{
+					AvailabilityZone:     aws.String("test-zone-1a"),
+					InstanceRequirements: &ec2.InstanceRequirements{},
+				}},
+			}}})
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(awsEnv.UnavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)).To(BeTrue())
+			Expect(awsEnv.UnavailableOfferingsCache.IsUnavailable("m5.xlarge", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)).To(BeTrue())
+			Expect(awsEnv.UnavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1b", v1alpha5.CapacityTypeOnDemand)).To(BeFalse())
+		})
+		It("should exclude an instance type that the fleet names after it repeatedly fails to launch", func() {
+			awsEnv.EC2API.CreateFleetBehavior.Output.Set(&ec2.CreateFleetOutput{
+				Instances: []*ec2.CreateFleetInstance{{
+					InstanceIds:  aws.StringSlice([]string{coretest.RandomName()}),
+					InstanceType: aws.String("m5.large"),
+					LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+						Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1a")},
+					},
+				}},
+				Errors: []*ec2.CreateFleetError{{
+					ErrorCode:    aws.String("InvalidParameterCombination"),
+					ErrorMessage: aws.String("Enhanced networking with the Elastic Network Adapter (ENA) is required for the 'm5.xlarge' instance type. Ensure that you are using an AMI that is enabled for ENA."),
+					LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+						Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String("m5.xlarge"), AvailabilityZone: aws.String("test-zone-1a")},
+					},
+				}},
+			})
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				m := machine.DeepCopy()
+				m.Name = coretest.RandomName()
+				_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(m), instanceTypes)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			key := nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true}
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.xlarge")).To(BeTrue())
+			Expect(awsEnv.BootstrapFailuresCache.IsExcluded(key, "m5.large")).To(BeFalse())
+		})
+		It("should only pass overrides for the zones that the machine requires", func() {
+			machine.Spec.Requirements = append(machine.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"},
+			})
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Keys(overridesByZone())).To(ConsistOf("test-zone-1b"))
+		})
+		It("should launch one of the allowed instance types", func() {
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Type).To(BeElementOf("m5.large", "m5.xlarge"))
+		})
+	})
+	Context("Offering Scores", func() {
+		BeforeEach(func() {
+			awsEnv.Reset()
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{OfferingScorePriceTolerance: lo.ToPtr(0.5)}))
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeOnDemand}},
+			}
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+		})
+		// markRecentlyUnavailable records insufficient capacity errors for the offering, without leaving it unavailable
+		markRecentlyUnavailable := func(instanceType, zone string, times int) {
+			for i := 0; i < times; i++ {
+				awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", instanceType, zone, v1alpha5.CapacityTypeOnDemand)
+			}
+			awsEnv.UnavailableOfferingsCache.Delete(instanceType, zone, v1alpha5.CapacityTypeOnDemand)
+		}
+		getInstanceTypes := func(names ...string) []*corecloudprovider.InstanceType {
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			return lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool { return lo.Contains(names, i.Name) })
+		}
+		It("should prioritize on-demand overrides by their scored price", func() {
+			markRecentlyUnavailable("m5.xlarge", "test-zone-1a", 1)
+			launched, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), getInstanceTypes("m5.xlarge"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(createFleetInput.OnDemandOptions.AllocationStrategy)).To(Equal(ec2.FleetOnDemandAllocationStrategyPrioritized))
+			priorities := lo.SliceToMap(createFleetInput.LaunchTemplateConfigs[0].Overrides, func(o *ec2.FleetLaunchTemplateOverridesRequest) (string, float64) {
+				return aws.StringValue(o.AvailabilityZone), aws.Float64Value(o.Priority)
+			})
+			Expect(priorities).To(Equal(map[string]float64{"test-zone-1b": 0, "test-zone-1a": 1}))
+			Expect(launched.Zone).To(Equal("test-zone-1b"))
+		})
+		It("should launch the next prioritized override when the highest priority pool has no capacity", func() {
+			markRecentlyUnavailable("m5.xlarge", "test-zone-1a", 1)
+			awsEnv.EC2API.AvailableCapacity.Set(fake.CapacityPool{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1b"}, 0)
+			launched, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), getInstanceTypes("m5.xlarge"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launched.Zone).To(Equal("test-zone-1a"))
+		})
+		It("should order instance types by their scored price", func() {
+			maxInstanceTypes := instance.MaxInstanceTypes
+			instance.MaxInstanceTypes = 1
+			DeferCleanup(func() { instance.MaxInstanceTypes = maxInstanceTypes })
+			// t3.large is cheaper than m5.large, but not once it's scored as unlikely to be fulfilled in every zone
+			for _, zone := range []string{"test-zone-1a", "test-zone-1b", "test-zone-1c"} {
+				markRecentlyUnavailable("t3.large", zone, 4)
+			}
+			launched, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), getInstanceTypes("t3.large", "m5.large"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launched.Type).To(Equal("m5.large"))
+		})
+		It("should prefer the cheapest instance type when prices differ by more than the tolerance", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{OfferingScorePriceTolerance: lo.ToPtr(0.1)}))
+			maxInstanceTypes := instance.MaxInstanceTypes
+			instance.MaxInstanceTypes = 1
+			DeferCleanup(func() { instance.MaxInstanceTypes = maxInstanceTypes })
+			for _, zone := range []string{"test-zone-1a", "test-zone-1b", "test-zone-1c"} {
+				markRecentlyUnavailable("t3.large", zone, 4)
+			}
+			launched, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), getInstanceTypes("t3.large", "m5.large"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launched.Type).To(Equal("t3.large"))
+		})
+		It("should not prioritize overrides when offerings aren't scored", func() {
+			ctx = settings.ToContext(ctx, test.Settings())
+			markRecentlyUnavailable("m5.xlarge", "test-zone-1a", 1)
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), getInstanceTypes("m5.xlarge"))
+			Expect(err).ToNot(HaveOccurred())
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(createFleetInput.OnDemandOptions.AllocationStrategy)).To(Equal(ec2.FleetOnDemandAllocationStrategyLowestPrice))
+			for _, override := range createFleetInput.LaunchTemplateConfigs[0].Overrides {
+				Expect(override.Priority).To(BeNil())
+			}
+		})
+		It("should not prioritize overrides that select instance types by their attributes", func() {
+			nodeTemplate.Spec.InstanceSelectionMode = lo.ToPtr(v1alpha1.InstanceSelectionModeAttributeBased)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), getInstanceTypes("m5.xlarge"))
+			Expect(err).ToNot(HaveOccurred())
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(createFleetInput.OnDemandOptions.AllocationStrategy)).To(Equal(ec2.FleetOnDemandAllocationStrategyLowestPrice))
+		})
+		It("should keep the spot allocation strategy", func() {
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}},
+			}
+			_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), getInstanceTypes("m5.xlarge"))
+			Expect(err).ToNot(HaveOccurred())
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(createFleetInput.SpotOptions.AllocationStrategy)).To(Equal(ec2.SpotAllocationStrategyPriceCapacityOptimized))
+		})
+	})
 })
@@ -0,0 +1,60 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// timelineFormat keeps the milliseconds of each timestamp, since most launch phases take less than a second
+const timelineFormat = "2006-01-02T15:04:05.000Z07:00"
+
+type timelineKey struct{}
+
+// Timeline records when each phase of a launch completed, so that a slow launch can be traced back to the phase that
+// held it up. The registration and initialization of the node are the transition times of the NodeClaim's Registered
+// and Initialized conditions.
+type Timeline struct {
+	mu     sync.Mutex
+	phases map[string]time.Time
+}
+
+// WithTimeline returns a context that records the completion of each launch phase observed with it in the timeline
+func WithTimeline(ctx context.Context) (context.Context, *Timeline) {
+	timeline := &Timeline{phases: map[string]time.Time{}}
+	return context.WithValue(ctx, timelineKey{}, timeline), timeline
+}
+
+func (t *Timeline) complete(phase string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases[phase] = at
+}
+
+// String returns the completion time of each phase as a JSON object keyed by the phase
+func (t *Timeline) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	formatted := map[string]string{}
+	for phase, at := range t.phases {
+		formatted[phase] = at.UTC().Format(timelineFormat)
+	}
+	// a map of strings always marshals
+	raw, _ := json.Marshal(formatted)
+	return string(raw)
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/samber/lo"
 
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 )
 
 // Instance is an internal data representation of either an ec2.Instance or an ec2.FleetInstance
@@ -37,6 +38,16 @@ type Instance struct {
 	SecurityGroupIDs []string
 	SubnetID         string
 	Tags             map[string]string
+	// MetadataOptions are the instance's current IMDS settings, or nil if they aren't known
+	MetadataOptions *v1beta1.MetadataOptions
+	// CapacityReservationID is the capacity reservation that the instance was launched into, if any
+	CapacityReservationID string
+	// PrimaryNetworkInterfaceID is the network interface at device index 0, which the instance was launched with
+	PrimaryNetworkInterfaceID string
+	// StateReason is why the instance last changed state, e.g. why it was stopped or terminated by EC2
+	StateReason string
+	// SpotInstanceRequestID is the spot request that the instance was launched for, if it's a spot instance
+	SpotInstanceRequestID string
 }
 
 func NewInstance(out *ec2.Instance) *Instance {
@@ -51,12 +62,36 @@ func NewInstance(out *ec2.Instance) *Instance {
 		SecurityGroupIDs: lo.Map(out.SecurityGroups, func(securitygroup *ec2.GroupIdentifier, _ int) string {
 			return aws.StringValue(securitygroup.GroupId)
 		}),
-		SubnetID: aws.StringValue(out.SubnetId),
-		Tags:     lo.SliceToMap(out.Tags, func(t *ec2.Tag) (string, string) { return aws.StringValue(t.Key), aws.StringValue(t.Value) }),
+		SubnetID:                  aws.StringValue(out.SubnetId),
+		Tags:                      lo.SliceToMap(out.Tags, func(t *ec2.Tag) (string, string) { return aws.StringValue(t.Key), aws.StringValue(t.Value) }),
+		MetadataOptions:           newMetadataOptions(out.MetadataOptions),
+		CapacityReservationID:     aws.StringValue(out.CapacityReservationId),
+		PrimaryNetworkInterfaceID: primaryNetworkInterfaceID(out.NetworkInterfaces),
+		StateReason:               newStateReason(out.StateReason),
+		SpotInstanceRequestID:     aws.StringValue(out.SpotInstanceRequestId),
 	}
 
 }
 
+func newStateReason(out *ec2.StateReason) string {
+	if out == nil {
+		return ""
+	}
+	return aws.StringValue(out.Message)
+}
+
+func newMetadataOptions(out *ec2.InstanceMetadataOptionsResponse) *v1beta1.MetadataOptions {
+	if out == nil {
+		return nil
+	}
+	return &v1beta1.MetadataOptions{
+		HTTPEndpoint:            out.HttpEndpoint,
+		HTTPProtocolIPv6:        out.HttpProtocolIpv6,
+		HTTPPutResponseHopLimit: out.HttpPutResponseHopLimit,
+		HTTPTokens:              out.HttpTokens,
+	}
+}
+
 func NewInstanceFromFleet(out *ec2.CreateFleetInstance, tags map[string]string) *Instance {
 	return &Instance{
 		LaunchTime:   time.Now(), // estimate the launch time since we just launched
@@ -70,3 +105,13 @@ func NewInstanceFromFleet(out *ec2.CreateFleetInstance, tags map[string]string)
 		Tags:         tags,
 	}
 }
+
+func primaryNetworkInterfaceID(networkInterfaces []*ec2.InstanceNetworkInterface) string {
+	eni, ok := lo.Find(networkInterfaces, func(eni *ec2.InstanceNetworkInterface) bool {
+		return eni.Attachment != nil && aws.Int64Value(eni.Attachment.DeviceIndex) == 0
+	})
+	if !ok {
+		return ""
+	}
+	return aws.StringValue(eni.NetworkInterfaceId)
+}
@@ -16,16 +16,21 @@ package instancetype
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/prometheus/client_golang/prometheus"
 
 	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	awscache "github.com/aws/karpenter/pkg/cache"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
@@ -33,29 +38,58 @@ import (
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/pkg/logging"
 
+	"github.com/aws/karpenter/pkg/providers/capacityreservation"
+	"github.com/aws/karpenter/pkg/providers/computeoptimizer"
 	"github.com/aws/karpenter/pkg/providers/pricing"
+	"github.com/aws/karpenter/pkg/providers/spotplacementscore"
 	"github.com/aws/karpenter/pkg/providers/subnet"
+	"github.com/aws/karpenter/pkg/providers/vpccni"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/scheduling"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	"github.com/aws/karpenter-core/pkg/utils/resources"
 )
 
 const (
 	InstanceTypesCacheKey           = "types"
+	InstanceTypeOfferingsCacheKey   = "offerings"
 	InstanceTypeZonesCacheKeyPrefix = "zones:"
 )
 
+const (
+	// MaxOfferingScore is the score of offerings that are expected to be fulfilled, which is also the highest spot
+	// placement score
+	MaxOfferingScore = 10
+	// UnavailableOfferingScorePenalty is how much each recent insufficient capacity error of an offering lowers its score
+	UnavailableOfferingScorePenalty = 3
+)
+
 type Provider struct {
 	region          string
 	ec2api          ec2iface.EC2API
 	subnetProvider  *subnet.Provider
 	pricingProvider *pricing.Provider
+	// computeOptimizerProvider biases selection away from instance types that are consistently overprovisioned
+	computeOptimizerProvider *computeoptimizer.Provider
+	// capacityReservationProvider lets pods that require a capacity reservation by id schedule to its instance type
+	capacityReservationProvider *capacityreservation.Provider
+	// spotPlacementScoreProvider scores spot offerings by how likely they are to be fulfilled
+	spotPlacementScoreProvider *spotplacementscore.Provider
+	// vpcCNIProvider discovers how the VPC CNI assigns addresses to pods, which limits the pod density of instance types
+	vpcCNIProvider *vpccni.Provider
 	// Has one cache entry for all the instance types (key: InstanceTypesCacheKey)
-	// Has one cache entry for all the zones for each subnet selector (key: InstanceTypesZonesCacheKeyPrefix:<hash_of_selector>)
-	// Values cached *before* considering insufficient capacity errors from the unavailableOfferings cache.
+	// Has one cache entry for the zones that each instance type is offered in across the region (key: InstanceTypeOfferingsCacheKey)
+	// Has one cache entry for the zones of the region's offerings for each subnet selector (key: InstanceTypesZonesCacheKeyPrefix<offeringsSeqNum>:<hash_of_selector>)
+	// Values cached *before* considering insufficient capacity errors from the unavailableOfferings cache. The offerings
+	// refresh controller replaces both entries in the background without expiring them, so they're only fetched while
+	// listing instance types until it first refreshes them.
 	// Fully initialized Instance Types are also cached based on the set of all instance types, zones, unavailableOfferings cache,
 	// node template, and kubelet configuration from the provisioner
 
@@ -63,22 +97,34 @@ type Provider struct {
 	cache *cache.Cache
 
 	unavailableOfferings *awscache.UnavailableOfferings
-	cm                   *pretty.ChangeMonitor
+	// bootstrapFailures excludes instance types whose nodes repeatedly failed to register with a NodeClass
+	bootstrapFailures *awscache.BootstrapFailures
+	cm                *pretty.ChangeMonitor
 	// instanceTypesSeqNum is a monotonically increasing change counter used to avoid the expensive hashing operation on instance types
 	instanceTypesSeqNum uint64
+	// offeringsSeqNum is a monotonically increasing change counter of the region's offerings, which invalidates the
+	// zones of the offerings for each subnet selector
+	offeringsSeqNum uint64
 }
 
 func NewProvider(region string, cache *cache.Cache, ec2api ec2iface.EC2API, subnetProvider *subnet.Provider,
-	unavailableOfferingsCache *awscache.UnavailableOfferings, pricingProvider *pricing.Provider) *Provider {
+	unavailableOfferingsCache *awscache.UnavailableOfferings, pricingProvider *pricing.Provider, computeOptimizerProvider *computeoptimizer.Provider,
+	capacityReservationProvider *capacityreservation.Provider, bootstrapFailures *awscache.BootstrapFailures,
+	spotPlacementScoreProvider *spotplacementscore.Provider, vpcCNIProvider *vpccni.Provider) *Provider {
 	return &Provider{
-		ec2api:               ec2api,
-		region:               region,
-		subnetProvider:       subnetProvider,
-		pricingProvider:      pricingProvider,
-		cache:                cache,
-		unavailableOfferings: unavailableOfferingsCache,
-		cm:                   pretty.NewChangeMonitor(),
-		instanceTypesSeqNum:  0,
+		ec2api:                      ec2api,
+		region:                      region,
+		subnetProvider:              subnetProvider,
+		pricingProvider:             pricingProvider,
+		computeOptimizerProvider:    computeOptimizerProvider,
+		capacityReservationProvider: capacityReservationProvider,
+		spotPlacementScoreProvider:  spotPlacementScoreProvider,
+		vpcCNIProvider:              vpcCNIProvider,
+		cache:                       cache,
+		unavailableOfferings:        unavailableOfferingsCache,
+		bootstrapFailures:           bootstrapFailures,
+		cm:                          pretty.NewChangeMonitor(),
+		instanceTypesSeqNum:         0,
 	}
 }
 
@@ -94,17 +140,53 @@ func (p *Provider) List(ctx context.Context, kc *corev1beta1.KubeletConfiguratio
 		return nil, err
 	}
 
+	reservations := p.getCapacityReservations(ctx)
+	cni, err := p.vpcCNIProvider.Get(ctx)
+	// Pod density falls back to the default configuration of the VPC CNI, like when it isn't discovered. Clusters that
+	// run another CNI don't have the VPC CNI at all, so that's only logged at debug level, and every error only once.
+	if err != nil && p.cm.HasChanged("vpc-cni-error", err.Error()) {
+		if errors.IsNotFound(err) {
+			logging.FromContext(ctx).Debugf("discovering vpc cni configuration, %s", err)
+		} else {
+			logging.FromContext(ctx).Errorf("discovering vpc cni configuration, %s", err)
+		}
+	}
+	excludedClasses := sets.New(settings.FromContext(ctx).ExcludedInstanceClasses...).Insert(nodeClass.Spec.ExcludedInstanceClasses...)
+
 	// Compute fully initialized instance types hash key
 	instanceTypeZonesHash, _ := hashstructure.Hash(instanceTypeZones, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
 	kcHash, _ := hashstructure.Hash(kc, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
-	key := fmt.Sprintf("%d-%d-%s-%016x-%016x", p.instanceTypesSeqNum, p.unavailableOfferings.SeqNum, nodeClass.UID, instanceTypeZonesHash, kcHash)
+	reservationsHash, _ := hashstructure.Hash(reservations, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+	cniHash, _ := hashstructure.Hash(cni, hashstructure.FormatV2, &hashstructure.HashOptions{})
+	// Quantities don't export their values, so the overrides are hashed by the string representations of their resources
+	overridesHash, _ := hashstructure.Hash(lo.Map(nodeClass.Spec.InstanceTypeOverrides, func(o v1beta1.InstanceTypeOverride, _ int) []interface{} {
+		return []interface{}{o.InstanceTypes, resources.StringMap(o.Capacity), resources.StringMap(o.SystemReserved), o.VMMemoryOverheadPercent}
+	}), hashstructure.FormatV2, &hashstructure.HashOptions{})
+	// and the kube reserved formula by its JSON representation, in which quantities are strings
+	kubeReserved, _ := json.Marshal(nodeClass.Spec.KubeReserved)
+	kubeReservedHash, _ := hashstructure.Hash(string(kubeReserved), hashstructure.FormatV2, &hashstructure.HashOptions{})
+	cpuOptionsHash, _ := hashstructure.Hash(nodeClass.Spec.CPUOptions, hashstructure.FormatV2, &hashstructure.HashOptions{})
+	memoryOverheadHash, _ := hashstructure.Hash([]interface{}{settings.FromContext(ctx).VMMemoryOverheadPercent, settings.FromContext(ctx).VMMemoryOverheadByInstanceFamily,
+		settings.FromContext(ctx).VMMemoryOverheadByAMIFamily, nodeClass.Spec.AMIFamily}, hashstructure.FormatV2, &hashstructure.HashOptions{})
+	key := fmt.Sprintf("%d-%d-%d-%s-%016x-%016x-%016x-%016x-%016x-%016x-%016x-%016x-%s", p.instanceTypesSeqNum, p.unavailableOfferings.SeqNum, p.computeOptimizerProvider.SeqNum, nodeClass.UID, instanceTypeZonesHash, kcHash, reservationsHash, overridesHash, kubeReservedHash, cpuOptionsHash, memoryOverheadHash, cniHash, strings.Join(sets.List(excludedClasses), ","))
 
-	if item, ok := p.cache.Get(key); ok {
-		return item.([]*cloudprovider.InstanceType), nil
+	if item, ok := awscache.Get(p.cache, awscache.InstanceTypesCacheName, key); ok {
+		return p.filterExcluded(nodeClass, item.([]*cloudprovider.InstanceType)), nil
 	}
-	// Reject any instance types that don't have any offerings due to zone
-	result := lo.Reject(lo.Map(instanceTypes, func(i *ec2.InstanceTypeInfo, _ int) *cloudprovider.InstanceType {
-		return NewInstanceType(ctx, i, kc, p.region, nodeClass, p.createOfferings(ctx, i, instanceTypeZones[aws.StringValue(i.InstanceType)]))
+	// Reject any instance types of the excluded classes, that don't support the CPU options, or that don't have any
+	// offerings due to zone
+	allowed := lo.FilterMap(instanceTypes, func(i *ec2.InstanceTypeInfo, _ int) (*ec2.InstanceTypeInfo, bool) {
+		if excludedClasses.HasAny(instanceClasses(i)...) {
+			return nil, false
+		}
+		return withCPUOptions(i, nodeClass.Spec.CPUOptions)
+	})
+	result := lo.Reject(lo.Map(allowed, func(i *ec2.InstanceTypeInfo, _ int) *cloudprovider.InstanceType {
+		instanceType := NewInstanceType(ctx, i, kc, p.region, nodeClass, p.createOfferings(ctx, i, instanceTypeZones[aws.StringValue(i.InstanceType)]), cni)
+		if ids, ok := reservations[instanceType.Name]; ok {
+			instanceType.Requirements[v1alpha1.LabelCapacityReservationID] = capacityReservationRequirement(reservations, ids)
+		}
+		return instanceType
 	}), func(i *cloudprovider.InstanceType, _ int) bool {
 		return len(i.Offerings) == 0
 	})
@@ -116,8 +198,59 @@ func (p *Provider) List(ctx context.Context, kc *corev1beta1.KubeletConfiguratio
 			InstanceTypeLabel: *instanceType.InstanceType,
 		}).Set(float64(aws.Int64Value(instanceType.MemoryInfo.SizeInMiB) * 1024 * 1024))
 	}
-	p.cache.SetDefault(key, result)
-	return result, nil
+	awscache.SetDefault(p.cache, awscache.InstanceTypesCacheName, key, result)
+	return p.filterExcluded(nodeClass, result), nil
+}
+
+// filterExcluded removes the instance types whose nodes repeatedly failed to register with the NodeClass. Exclusions
+// expire independently of the instance types cache, so they're applied to its results rather than cached with them.
+func (p *Provider) filterExcluded(nodeClass *v1beta1.NodeClass, instanceTypes []*cloudprovider.InstanceType) []*cloudprovider.InstanceType {
+	key := nodeclassutil.Key{Name: nodeClass.Name, IsNodeTemplate: nodeClass.IsNodeTemplate}
+	return lo.Reject(instanceTypes, func(i *cloudprovider.InstanceType, _ int) bool {
+		return p.bootstrapFailures.IsExcluded(key, i.Name)
+	})
+}
+
+// getCapacityReservations returns the ids of the active capacity reservations that have instances available, keyed by
+// their instance type. Failing to list them only prevents pods that require a capacity reservation from scheduling, so
+// the error is logged rather than returned.
+func (p *Provider) getCapacityReservations(ctx context.Context) map[string]sets.Set[string] {
+	reservations, err := p.capacityReservationProvider.List(ctx)
+	if err != nil {
+		if p.cm.HasChanged("capacity-reservations-error", err.Error()) {
+			logging.FromContext(ctx).Errorf("listing capacity reservations, %s", err)
+		}
+		return nil
+	}
+	result := map[string]sets.Set[string]{}
+	for _, reservation := range reservations {
+		if aws.Int64Value(reservation.AvailableInstanceCount) == 0 {
+			continue
+		}
+		instanceType := aws.StringValue(reservation.InstanceType)
+		if _, ok := result[instanceType]; !ok {
+			result[instanceType] = sets.New[string]()
+		}
+		result[instanceType].Insert(aws.StringValue(reservation.CapacityReservationId))
+	}
+	return result
+}
+
+// capacityReservationRequirement allows an instance type to be launched for pods that require one of its own capacity
+// reservations, or that don't require a capacity reservation at all. NotIn is used rather than In so that the
+// reservation id isn't applied as a label to every node of the instance type.
+func capacityReservationRequirement(reservations map[string]sets.Set[string], ids sets.Set[string]) *scheduling.Requirement {
+	others := sets.New[string]()
+	for _, reserved := range reservations {
+		others = others.Union(reserved)
+	}
+	return scheduling.NewRequirement(v1alpha1.LabelCapacityReservationID, v1.NodeSelectorOpNotIn, sets.List(others.Difference(ids))...)
+}
+
+// Price returns the last known price for the instance type, zone, and capacity type described by the well known labels
+// of a node or machine, without the bias that's applied to offering prices when ranking instance types
+func (p *Provider) Price(labels map[string]string) (float64, bool) {
+	return p.pricingProvider.Price(labels)
 }
 
 func (p *Provider) LivenessProbe(req *http.Request) error {
@@ -157,6 +290,32 @@ func (p *Provider) createOfferings(ctx context.Context, instanceType *ec2.Instan
 	return offerings
 }
 
+// BiasPrice inflates the price of instance types that Compute Optimizer has found to be consistently overprovisioned,
+// so that cheaper alternatives that also fit the pods are preferred when ordering instance types to launch. Offering
+// prices are left unbiased so that scheduling, consolidation and recommendations compare real prices.
+func (p *Provider) BiasPrice(ctx context.Context, instanceType string, price float64) float64 {
+	bias := settings.FromContext(ctx).ComputeOptimizerPriceBias
+	if bias == 0 || !p.computeOptimizerProvider.Overprovisioned(instanceType) {
+		return price
+	}
+	return price * (1 + bias)
+}
+
+// OfferingScore returns how likely a launch into the offering is to be fulfilled, from 0 to MaxOfferingScore. Spot
+// offerings start from the latest spot placement score of their zone, if it was scored, which every instance type in
+// the zone shares, and every offering loses UnavailableOfferingScorePenalty for each insufficient capacity error that it
+// returned within the last hour.
+func (p *Provider) OfferingScore(instanceType, zone, capacityType string) int64 {
+	score := int64(MaxOfferingScore)
+	if capacityType == ec2.UsageClassTypeSpot {
+		if placementScore, ok := p.spotPlacementScoreProvider.Score(zone); ok {
+			score = placementScore
+		}
+	}
+	score -= int64(UnavailableOfferingScorePenalty * p.unavailableOfferings.RecentlyUnavailable(instanceType, zone, capacityType))
+	return lo.Clamp(score, 0, MaxOfferingScore)
+}
+
 func (p *Provider) getInstanceTypeZones(ctx context.Context, nodeClass *v1beta1.NodeClass) (map[string]sets.Set[string], error) {
 	// DO NOT REMOVE THIS LOCK ----------------------------------------------------------------------------
 	// We lock here so that multiple callers to getInstanceTypeZones do not result in cache misses and multiple
@@ -169,8 +328,12 @@ func (p *Provider) getInstanceTypeZones(ctx context.Context, nodeClass *v1beta1.
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash the subnet selector: %w", err)
 	}
-	cacheKey := fmt.Sprintf("%s%016x", InstanceTypeZonesCacheKeyPrefix, subnetSelectorHash)
-	if cached, ok := p.cache.Get(cacheKey); ok {
+	offerings, err := p.getInstanceTypeOfferings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := fmt.Sprintf("%s%d:%016x", InstanceTypeZonesCacheKeyPrefix, p.offeringsSeqNum, subnetSelectorHash)
+	if cached, ok := awscache.Get(p.cache, awscache.InstanceTypesCacheName, cacheKey); ok {
 		return cached.(map[string]sets.Set[string]), nil
 	}
 
@@ -182,31 +345,70 @@ func (p *Provider) getInstanceTypeZones(ctx context.Context, nodeClass *v1beta1.
 	if len(subnets) == 0 {
 		return nil, nil
 	}
-	zones := sets.NewString(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string {
+	zones := sets.New(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string {
 		return aws.StringValue(subnet.AvailabilityZone)
 	})...)
-
-	// Get offerings from EC2
 	instanceTypeZones := map[string]sets.Set[string]{}
+	for instanceType, offeringZones := range offerings {
+		if instanceZones := offeringZones.Intersection(zones); instanceZones.Len() > 0 {
+			instanceTypeZones[instanceType] = instanceZones
+		}
+	}
+	if p.cm.HasChanged("zonal-offerings", nodeClass.Spec.SubnetSelectorTerms) {
+		logging.FromContext(ctx).With("zones", sets.List(zones), "instance-type-count", len(instanceTypeZones), "node-template", nodeClass.Name).Debugf("discovered offerings for instance types")
+	}
+	awscache.SetDefault(p.cache, awscache.InstanceTypesCacheName, cacheKey, instanceTypeZones)
+	return instanceTypeZones, nil
+}
+
+// getInstanceTypeOfferings returns the zones of the region that each instance type is offered in, describing them
+// if the offerings refresh controller hasn't refreshed them yet. The caller must hold the lock.
+func (p *Provider) getInstanceTypeOfferings(ctx context.Context) (map[string]sets.Set[string], error) {
+	if cached, ok := awscache.Get(p.cache, awscache.InstanceTypesCacheName, InstanceTypeOfferingsCacheKey); ok {
+		return cached.(map[string]sets.Set[string]), nil
+	}
+	offerings, err := p.describeInstanceTypeOfferings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.offeringsSeqNum++
+	awscache.SetDefault(p.cache, awscache.InstanceTypesCacheName, InstanceTypeOfferingsCacheKey, offerings)
+	return offerings, nil
+}
+
+// UpdateInstanceTypeOfferings refreshes the zones that each instance type is offered in across the region, which
+// listing instance types then uses until the next refresh. Instance types are only listed again when the offerings
+// changed.
+func (p *Provider) UpdateInstanceTypeOfferings(ctx context.Context) error {
+	offerings, err := p.describeInstanceTypeOfferings(ctx)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cached, ok := awscache.Get(p.cache, awscache.InstanceTypesCacheName, InstanceTypeOfferingsCacheKey); !ok || !equality.Semantic.DeepEqual(cached, offerings) {
+		p.offeringsSeqNum++
+	}
+	awscache.Set(p.cache, awscache.InstanceTypesCacheName, InstanceTypeOfferingsCacheKey, offerings, cache.NoExpiration)
+	return nil
+}
+
+// describeInstanceTypeOfferings returns the zones of the region that each instance type is offered in
+func (p *Provider) describeInstanceTypeOfferings(ctx context.Context) (map[string]sets.Set[string], error) {
+	offerings := map[string]sets.Set[string]{}
 	if err := p.ec2api.DescribeInstanceTypeOfferingsPagesWithContext(ctx, &ec2.DescribeInstanceTypeOfferingsInput{LocationType: aws.String("availability-zone")},
 		func(output *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
 			for _, offering := range output.InstanceTypeOfferings {
-				if zones.Has(aws.StringValue(offering.Location)) {
-					if _, ok := instanceTypeZones[aws.StringValue(offering.InstanceType)]; !ok {
-						instanceTypeZones[aws.StringValue(offering.InstanceType)] = sets.New[string]()
-					}
-					instanceTypeZones[aws.StringValue(offering.InstanceType)].Insert(aws.StringValue(offering.Location))
+				if _, ok := offerings[aws.StringValue(offering.InstanceType)]; !ok {
+					offerings[aws.StringValue(offering.InstanceType)] = sets.New[string]()
 				}
+				offerings[aws.StringValue(offering.InstanceType)].Insert(aws.StringValue(offering.Location))
 			}
 			return true
 		}); err != nil {
 		return nil, fmt.Errorf("describing instance type zone offerings, %w", err)
 	}
-	if p.cm.HasChanged("zonal-offerings", nodeClass.Spec.SubnetSelectorTerms) {
-		logging.FromContext(ctx).With("zones", zones.List(), "instance-type-count", len(instanceTypeZones), "node-template", nodeClass.Name).Debugf("discovered offerings for instance types")
-	}
-	p.cache.SetDefault(cacheKey, instanceTypeZones)
-	return instanceTypeZones, nil
+	return offerings, nil
 }
 
 // GetInstanceTypes retrieves all instance types from the ec2 DescribeInstanceTypes API using some opinionated filters
@@ -219,9 +421,35 @@ func (p *Provider) GetInstanceTypes(ctx context.Context) ([]*ec2.InstanceTypeInf
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if cached, ok := p.cache.Get(InstanceTypesCacheKey); ok {
+	if cached, ok := awscache.Get(p.cache, awscache.InstanceTypesCacheName, InstanceTypesCacheKey); ok {
 		return cached.([]*ec2.InstanceTypeInfo), nil
 	}
+	instanceTypes, err := p.describeInstanceTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&p.instanceTypesSeqNum, 1)
+	awscache.SetDefault(p.cache, awscache.InstanceTypesCacheName, InstanceTypesCacheKey, instanceTypes)
+	return instanceTypes, nil
+}
+
+// UpdateInstanceTypes refreshes the instance types, which listing instance types then uses until the next refresh.
+// Instance types are only listed again when they changed.
+func (p *Provider) UpdateInstanceTypes(ctx context.Context) error {
+	instanceTypes, err := p.describeInstanceTypes(ctx)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cached, ok := awscache.Get(p.cache, awscache.InstanceTypesCacheName, InstanceTypesCacheKey); !ok || !equality.Semantic.DeepEqual(cached, instanceTypes) {
+		atomic.AddUint64(&p.instanceTypesSeqNum, 1)
+	}
+	awscache.Set(p.cache, awscache.InstanceTypesCacheName, InstanceTypesCacheKey, instanceTypes, cache.NoExpiration)
+	return nil
+}
+
+func (p *Provider) describeInstanceTypes(ctx context.Context) ([]*ec2.InstanceTypeInfo, error) {
 	var instanceTypes []*ec2.InstanceTypeInfo
 	if err := p.ec2api.DescribeInstanceTypesPagesWithContext(ctx, &ec2.DescribeInstanceTypesInput{
 		Filters: []*ec2.Filter{
@@ -244,7 +472,5 @@ func (p *Provider) GetInstanceTypes(ctx context.Context) ([]*ec2.InstanceTypeInf
 		logging.FromContext(ctx).With(
 			"count", len(instanceTypes)).Debugf("discovered instance types")
 	}
-	atomic.AddUint64(&p.instanceTypesSeqNum, 1)
-	p.cache.SetDefault(InstanceTypesCacheKey, instanceTypes)
 	return instanceTypes, nil
 }
@@ -16,19 +16,26 @@ package instancetype_test
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/computeoptimizer"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -58,11 +65,13 @@ import (
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/cloudprovider"
 	"github.com/aws/karpenter/pkg/fake"
 	"github.com/aws/karpenter/pkg/providers/instance"
 	"github.com/aws/karpenter/pkg/providers/instancetype"
 	"github.com/aws/karpenter/pkg/providers/pricing"
+	"github.com/aws/karpenter/pkg/providers/vpccni"
 	"github.com/aws/karpenter/pkg/test"
 )
 
@@ -95,7 +104,7 @@ var _ = BeforeSuite(func() {
 
 	fakeClock = &clock.FakeClock{}
 	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, events.NewRecorder(&record.FakeRecorder{}),
-		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider)
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.WarmUp, fakeClock)
 	cluster = state.NewCluster(fakeClock, env.Client, cloudProvider)
 	prov = provisioning.NewProvisioner(env.Client, env.KubernetesInterface.CoreV1(), events.NewRecorder(&record.FakeRecorder{}), cloudProvider, cluster)
 })
@@ -167,6 +176,17 @@ var _ = AfterEach(func() {
 
 var _ = Describe("Instance Types", func() {
 	It("should support individual instance type labels", func() {
+		awsEnv.EC2API.DescribeCapacityReservationsOutput.Set(&ec2.DescribeCapacityReservationsOutput{
+			CapacityReservations: []*ec2.CapacityReservation{
+				{
+					CapacityReservationId:  aws.String("cr-g4dn8xlarge"),
+					InstanceType:           aws.String("g4dn.8xlarge"),
+					AvailabilityZone:       aws.String("test-zone-1a"),
+					AvailableInstanceCount: aws.Int64(1),
+					State:                  aws.String(ec2.CapacityReservationStateActive),
+				},
+			},
+		})
 		ExpectApplied(ctx, env.Client, provisioner, windowsProvisioner, nodeTemplate, windowsNodeTemplate)
 
 		nodeSelector := map[string]string{
@@ -181,6 +201,15 @@ var _ = Describe("Instance Types", func() {
 			// Well Known to AWS
 			v1alpha1.LabelInstanceHypervisor:                   "nitro",
 			v1alpha1.LabelInstanceEncryptionInTransitSupported: "true",
+			v1alpha1.LabelInstanceBootMode:                     "uefi",
+			v1alpha1.LabelInstanceVirtualizationType:           "hvm",
+			v1alpha1.LabelInstanceRootDeviceType:               "ebs",
+			v1alpha1.LabelInstanceNitroTPMSupported:            "true",
+			v1alpha1.LabelInstanceEBSNVMeSupported:             "true",
+			v1alpha1.LabelInstanceEFASupported:                 "true",
+			v1alpha1.LabelInstanceDedicatedHostsSupported:      "true",
+			v1alpha1.LabelInstanceStoreVolumeCount:             "1",
+			v1alpha1.LabelInstanceStoreVolumeSize:              "900",
 			v1alpha1.LabelInstanceCategory:                     "g",
 			v1alpha1.LabelInstanceGeneration:                   "4",
 			v1alpha1.LabelInstanceFamily:                       "g4dn",
@@ -188,6 +217,9 @@ var _ = Describe("Instance Types", func() {
 			v1alpha1.LabelInstanceCPU:                          "32",
 			v1alpha1.LabelInstanceMemory:                       "131072",
 			v1alpha1.LabelInstanceNetworkBandwidth:             "50000",
+			v1alpha1.LabelInstanceEBSBandwidth:                 "9500",
+			v1alpha1.LabelInstanceEBSIOPS:                      "40000",
+			v1alpha1.LabelRootVolumeIOPS:                       "3000",
 			v1alpha1.LabelInstancePods:                         "58",
 			v1alpha1.LabelInstanceGPUName:                      "t4",
 			v1alpha1.LabelInstanceGPUManufacturer:              "nvidia",
@@ -197,6 +229,8 @@ var _ = Describe("Instance Types", func() {
 			v1alpha1.LabelInstanceAcceleratorName:              "inferentia",
 			v1alpha1.LabelInstanceAcceleratorManufacturer:      "aws",
 			v1alpha1.LabelInstanceAcceleratorCount:             "1",
+			v1alpha1.LabelInstanceAcceleratorMemory:            "8192",
+			v1alpha1.LabelCapacityReservationID:                "cr-g4dn8xlarge",
 			// Deprecated Labels
 			v1.LabelFailureDomainBetaRegion: "",
 			v1.LabelFailureDomainBetaZone:   "test-zone-1a",
@@ -234,6 +268,15 @@ var _ = Describe("Instance Types", func() {
 			// Well Known to AWS
 			v1alpha1.LabelInstanceHypervisor:                   "nitro",
 			v1alpha1.LabelInstanceEncryptionInTransitSupported: "true",
+			v1alpha1.LabelInstanceBootMode:                     "uefi",
+			v1alpha1.LabelInstanceVirtualizationType:           "hvm",
+			v1alpha1.LabelInstanceRootDeviceType:               "ebs",
+			v1alpha1.LabelInstanceNitroTPMSupported:            "true",
+			v1alpha1.LabelInstanceEBSNVMeSupported:             "true",
+			v1alpha1.LabelInstanceEFASupported:                 "true",
+			v1alpha1.LabelInstanceDedicatedHostsSupported:      "true",
+			v1alpha1.LabelInstanceStoreVolumeCount:             "1",
+			v1alpha1.LabelInstanceStoreVolumeSize:              "900",
 			v1alpha1.LabelInstanceCategory:                     "g",
 			v1alpha1.LabelInstanceGeneration:                   "4",
 			v1alpha1.LabelInstanceFamily:                       "g4dn",
@@ -241,6 +284,9 @@ var _ = Describe("Instance Types", func() {
 			v1alpha1.LabelInstanceCPU:                          "32",
 			v1alpha1.LabelInstanceMemory:                       "131072",
 			v1alpha1.LabelInstanceNetworkBandwidth:             "50000",
+			v1alpha1.LabelInstanceEBSBandwidth:                 "9500",
+			v1alpha1.LabelInstanceEBSIOPS:                      "40000",
+			v1alpha1.LabelRootVolumeIOPS:                       "3000",
 			v1alpha1.LabelInstancePods:                         "58",
 			v1alpha1.LabelInstanceGPUName:                      "t4",
 			v1alpha1.LabelInstanceGPUManufacturer:              "nvidia",
@@ -263,6 +309,8 @@ var _ = Describe("Instance Types", func() {
 					v1alpha1.LabelInstanceAcceleratorCount,
 					v1alpha1.LabelInstanceAcceleratorName,
 					v1alpha1.LabelInstanceAcceleratorManufacturer,
+					v1alpha1.LabelInstanceAcceleratorMemory,
+					v1alpha1.LabelCapacityReservationID,
 					v1.LabelWindowsBuild,
 				)).UnsortedList(), lo.Keys(v1alpha5.NormalizedLabels)...)))
 
@@ -285,6 +333,13 @@ var _ = Describe("Instance Types", func() {
 			// Well Known to AWS
 			v1alpha1.LabelInstanceHypervisor:                   "nitro",
 			v1alpha1.LabelInstanceEncryptionInTransitSupported: "true",
+			v1alpha1.LabelInstanceBootMode:                     "uefi",
+			v1alpha1.LabelInstanceVirtualizationType:           "hvm",
+			v1alpha1.LabelInstanceRootDeviceType:               "ebs",
+			v1alpha1.LabelInstanceNitroTPMSupported:            "true",
+			v1alpha1.LabelInstanceEBSNVMeSupported:             "true",
+			v1alpha1.LabelInstanceEFASupported:                 "false",
+			v1alpha1.LabelInstanceDedicatedHostsSupported:      "true",
 			v1alpha1.LabelInstanceCategory:                     "inf",
 			v1alpha1.LabelInstanceGeneration:                   "1",
 			v1alpha1.LabelInstanceFamily:                       "inf1",
@@ -292,10 +347,14 @@ var _ = Describe("Instance Types", func() {
 			v1alpha1.LabelInstanceCPU:                          "8",
 			v1alpha1.LabelInstanceMemory:                       "16384",
 			v1alpha1.LabelInstanceNetworkBandwidth:             "5000",
+			v1alpha1.LabelInstanceEBSBandwidth:                 "1190",
+			v1alpha1.LabelInstanceEBSIOPS:                      "6000",
+			v1alpha1.LabelRootVolumeIOPS:                       "3000",
 			v1alpha1.LabelInstancePods:                         "38",
 			v1alpha1.LabelInstanceAcceleratorName:              "inferentia",
 			v1alpha1.LabelInstanceAcceleratorManufacturer:      "aws",
 			v1alpha1.LabelInstanceAcceleratorCount:             "1",
+			v1alpha1.LabelInstanceAcceleratorMemory:            "8192",
 			// Deprecated Labels
 			v1.LabelFailureDomainBetaRegion: "",
 			v1.LabelFailureDomainBetaZone:   "test-zone-1a",
@@ -305,13 +364,16 @@ var _ = Describe("Instance Types", func() {
 			"topology.ebs.csi.aws.com/zone": "test-zone-1a",
 		}
 
-		// Ensure that we're exercising all well known labels except for gpu labels and nvme
+		// Ensure that we're exercising all well known labels except for gpu labels and local storage
 		expectedLabels := append(v1alpha5.WellKnownLabels.Difference(sets.New(
 			v1alpha1.LabelInstanceGPUCount,
 			v1alpha1.LabelInstanceGPUName,
 			v1alpha1.LabelInstanceGPUManufacturer,
 			v1alpha1.LabelInstanceGPUMemory,
 			v1alpha1.LabelInstanceLocalNVME,
+			v1alpha1.LabelInstanceStoreVolumeCount,
+			v1alpha1.LabelInstanceStoreVolumeSize,
+			v1alpha1.LabelCapacityReservationID,
 			v1.LabelWindowsBuild,
 		)).UnsortedList(), lo.Keys(v1alpha5.NormalizedLabels)...)
 		Expect(lo.Keys(nodeSelector)).To(ContainElements(expectedLabels))
@@ -500,6 +562,82 @@ var _ = Describe("Instance Types", func() {
 		ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 		ExpectScheduled(ctx, env.Client, pod)
 	})
+	It("should launch instance types with enough dedicated EBS bandwidth and IOPS", func() {
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		pod := coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{
+				{Key: v1alpha1.LabelInstanceEBSBandwidth, Operator: v1.NodeSelectorOpGt, Values: []string{"5000"}},
+				{Key: v1alpha1.LabelInstanceEBSIOPS, Operator: v1.NodeSelectorOpGt, Values: []string{"30000"}},
+			},
+		})
+		ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+		node := ExpectScheduled(ctx, env.Client, pod)
+		Expect(lo.Must(strconv.Atoi(node.Labels[v1alpha1.LabelInstanceEBSBandwidth]))).To(BeNumerically(">", 5000))
+		Expect(lo.Must(strconv.Atoi(node.Labels[v1alpha1.LabelInstanceEBSIOPS]))).To(BeNumerically(">", 30000))
+	})
+	It("should not launch when no instance type has enough dedicated EBS IOPS", func() {
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		pod := coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{
+				{Key: v1alpha1.LabelInstanceEBSIOPS, Operator: v1.NodeSelectorOpGt, Values: []string{"1000000"}},
+			},
+		})
+		ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+		ExpectNotScheduled(ctx, env.Client, pod)
+	})
+	It("should not launch when the default root volume doesn't have enough IOPS", func() {
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		pod := coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{
+				{Key: v1alpha1.LabelRootVolumeIOPS, Operator: v1.NodeSelectorOpGt, Values: []string{"3000"}},
+			},
+		})
+		ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+		ExpectNotScheduled(ctx, env.Client, pod)
+	})
+	It("should launch instance types whose root volume supports enough IOPS", func() {
+		nodeTemplate.Spec.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+			{
+				DeviceName: aws.String("/dev/xvda"),
+				EBS: &v1alpha1.BlockDevice{
+					VolumeType: aws.String(ec2.VolumeTypeIo2),
+					VolumeSize: lo.ToPtr(resource.MustParse("100Gi")),
+					IOPS:       aws.Int64(32000),
+				},
+			},
+		}
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		pod := coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{
+				{Key: v1alpha1.LabelRootVolumeIOPS, Operator: v1.NodeSelectorOpGt, Values: []string{"10000"}},
+			},
+		})
+		ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+		node := ExpectScheduled(ctx, env.Client, pod)
+		// The volume's IOPS are capped by the instance type's dedicated EBS IOPS
+		Expect(lo.Must(strconv.Atoi(node.Labels[v1alpha1.LabelRootVolumeIOPS]))).To(BeNumerically(">", 10000))
+		Expect(lo.Must(strconv.Atoi(node.Labels[v1alpha1.LabelRootVolumeIOPS]))).To(BeNumerically("<=", lo.Must(strconv.Atoi(node.Labels[v1alpha1.LabelInstanceEBSIOPS]))))
+		Expect(lo.Must(strconv.Atoi(node.Labels[v1alpha1.LabelRootVolumeIOPS]))).To(BeNumerically("<=", 32000))
+	})
+	It("should compute the root volume IOPS of gp2 volumes from their size", func() {
+		nodeTemplate.Spec.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+			{
+				DeviceName: aws.String("/dev/xvda"),
+				EBS: &v1alpha1.BlockDevice{
+					VolumeType: aws.String(ec2.VolumeTypeGp2),
+					VolumeSize: lo.ToPtr(resource.MustParse("100Gi")),
+				},
+			},
+		}
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		pod := coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{
+				{Key: v1alpha1.LabelRootVolumeIOPS, Operator: v1.NodeSelectorOpIn, Values: []string{"300"}},
+			},
+		})
+		ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+		ExpectScheduled(ctx, env.Client, pod)
+	})
 	It("should fail to launch AWS Pod ENI if the setting enabling it isn't set", func() {
 		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
 			EnablePodENI: lo.ToPtr(false),
@@ -654,6 +792,111 @@ var _ = Describe("Instance Types", func() {
 		}
 		Expect(nodeNames.Len()).To(Equal(1))
 	})
+	Context("Accelerators", func() {
+		var infos map[string]*ec2.InstanceTypeInfo
+		newInstanceType := func(info *ec2.InstanceTypeInfo) *corecloudprovider.InstanceType {
+			return instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
+		}
+		capacity := func(it *corecloudprovider.InstanceType, name v1.ResourceName) int64 {
+			Expect(it.Capacity).To(HaveKey(name))
+			return lo.ToPtr(it.Capacity[name]).Value()
+		}
+		BeforeEach(func() {
+			instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			infos = lo.SliceToMap(instanceInfo, func(info *ec2.InstanceTypeInfo) (string, *ec2.InstanceTypeInfo) {
+				return aws.StringValue(info.InstanceType), info
+			})
+		})
+		It("should advertise the GPUs and accelerators of every manufacturer", func() {
+			for name, counts := range map[string]map[v1.ResourceName]int64{
+				"dl1.24xlarge": {v1alpha1.ResourceHabanaGaudi: 8},
+				"g4dn.8xlarge": {v1alpha1.ResourceNVIDIAGPU: 1},
+				"inf1.6xlarge": {v1alpha1.ResourceAWSNeuron: 4},
+				"trn1.2xlarge": {v1alpha1.ResourceAWSNeuron: 1},
+				"m5.large":     {},
+			} {
+				it := newInstanceType(infos[name])
+				for _, resourceName := range []v1.ResourceName{v1alpha1.ResourceNVIDIAGPU, v1alpha1.ResourceAMDGPU, v1alpha1.ResourceAWSNeuron, v1alpha1.ResourceHabanaGaudi} {
+					Expect(capacity(it, resourceName)).To(Equal(counts[resourceName]), "%s %s", name, resourceName)
+				}
+			}
+		})
+		It("should label instance types with the memory of their accelerators", func() {
+			it := newInstanceType(infos["inf1.2xlarge"])
+			Expect(it.Requirements.Get(v1alpha1.LabelInstanceAcceleratorMemory).Values()).To(ConsistOf("8192"))
+			it = newInstanceType(infos["m5.large"])
+			Expect(it.Requirements.Get(v1alpha1.LabelInstanceAcceleratorMemory).Operator()).To(Equal(v1.NodeSelectorOpDoesNotExist))
+		})
+		It("should label trn1 instance types with their Trainium accelerators", func() {
+			it := newInstanceType(infos["trn1.2xlarge"])
+			// The accelerator name of trn1 instance types has always been inferentia
+			Expect(it.Requirements.Get(v1alpha1.LabelInstanceAcceleratorName).Values()).To(ConsistOf("inferentia"))
+			Expect(it.Requirements.Get(v1alpha1.LabelInstanceAcceleratorManufacturer).Values()).To(ConsistOf("aws"))
+			Expect(it.Requirements.Get(v1alpha1.LabelInstanceAcceleratorCount).Values()).To(ConsistOf("1"))
+			Expect(it.Requirements.Get(v1alpha1.LabelInstanceAcceleratorMemory).Values()).To(ConsistOf("32768"))
+		})
+		It("should prefer the accelerators that DescribeInstanceTypes reports", func() {
+			info := *infos["trn1.2xlarge"]
+			info.InstanceType = aws.String("trn1.32xlarge")
+			info.InferenceAcceleratorInfo = &ec2.InferenceAcceleratorInfo{
+				Accelerators: []*ec2.InferenceDeviceInfo{
+					{
+						Name:         aws.String("Trainium"),
+						Manufacturer: aws.String("AWS"),
+						Count:        aws.Int64(8),
+						MemoryInfo:   &ec2.InferenceDeviceMemoryInfo{SizeInMiB: aws.Int64(65536)},
+					},
+				},
+			}
+			it := newInstanceType(&info)
+			Expect(capacity(it, v1alpha1.ResourceAWSNeuron)).To(BeNumerically("==", 8))
+			Expect(it.Requirements.Get(v1alpha1.LabelInstanceAcceleratorCount).Values()).To(ConsistOf("8"))
+			Expect(it.Requirements.Get(v1alpha1.LabelInstanceAcceleratorMemory).Values()).To(ConsistOf("65536"))
+		})
+		It("should advertise Neuron devices for Inferentia2 accelerators", func() {
+			info := *infos["inf1.6xlarge"]
+			info.InstanceType = aws.String("inf2.24xlarge")
+			info.InferenceAcceleratorInfo = &ec2.InferenceAcceleratorInfo{
+				Accelerators: []*ec2.InferenceDeviceInfo{
+					{
+						Name:         aws.String("Inferentia2"),
+						Manufacturer: aws.String("AWS"),
+						Count:        aws.Int64(6),
+						MemoryInfo:   &ec2.InferenceDeviceMemoryInfo{SizeInMiB: aws.Int64(32768)},
+					},
+				},
+			}
+			it := newInstanceType(&info)
+			Expect(capacity(it, v1alpha1.ResourceAWSNeuron)).To(BeNumerically("==", 6))
+			Expect(it.Requirements.Get(v1alpha1.LabelInstanceAcceleratorName).Values()).To(ConsistOf("inferentia2"))
+		})
+		It("should sum the GPUs of every manufacturer separately", func() {
+			info := *infos["g4dn.8xlarge"]
+			info.GpuInfo = &ec2.GpuInfo{
+				Gpus: []*ec2.GpuDeviceInfo{
+					{Name: aws.String("T4"), Manufacturer: aws.String("NVIDIA"), Count: aws.Int64(2)},
+					{Name: aws.String("A10G"), Manufacturer: aws.String("NVIDIA"), Count: aws.Int64(1)},
+					{Name: aws.String("Radeon Pro V520"), Manufacturer: aws.String("AMD"), Count: aws.Int64(4)},
+					{Name: aws.String("Gaudi HL-205"), Manufacturer: aws.String("Habana"), Count: aws.Int64(8)},
+				},
+			}
+			it := newInstanceType(&info)
+			Expect(capacity(it, v1alpha1.ResourceNVIDIAGPU)).To(BeNumerically("==", 3))
+			Expect(capacity(it, v1alpha1.ResourceAMDGPU)).To(BeNumerically("==", 4))
+			Expect(capacity(it, v1alpha1.ResourceHabanaGaudi)).To(BeNumerically("==", 8))
+		})
+		It("should not advertise resources for accelerators without a known device plugin", func() {
+			info := *infos["m5.large"]
+			info.GpuInfo = &ec2.GpuInfo{
+				Gpus: []*ec2.GpuDeviceInfo{{Name: aws.String("U30"), Manufacturer: aws.String("Xilinx"), Count: aws.Int64(1), MemoryInfo: &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(4096)}}},
+			}
+			it := newInstanceType(&info)
+			for _, resourceName := range []v1.ResourceName{v1alpha1.ResourceNVIDIAGPU, v1alpha1.ResourceAMDGPU, v1alpha1.ResourceAWSNeuron, v1alpha1.ResourceHabanaGaudi} {
+				Expect(capacity(it, resourceName)).To(BeNumerically("==", 0))
+			}
+		})
+	})
 	It("should set pods to 110 if not using ENI-based pod density", func() {
 		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
 			EnableENILimitedPodDensity: lo.ToPtr(false),
@@ -661,7 +904,7 @@ var _ = Describe("Instance Types", func() {
 		instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
 		Expect(err).To(BeNil())
 		for _, info := range instanceInfo {
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 110))
 		}
 	})
@@ -669,11 +912,132 @@ var _ = Describe("Instance Types", func() {
 		instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
 		Expect(err).To(BeNil())
 		for _, info := range instanceInfo {
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			Expect(it.Capacity.Pods().Value()).ToNot(BeNumerically("==", 110))
 		}
 	})
-	It("should set pods to 110 even ENILimitedPodDensity is enabled in awssettings but amifamily doesn't support", func() {
+	Context("VPC CNI Pod Density", func() {
+		var daemonSet *appsv1.DaemonSet
+		podsByInstanceType := func() map[string]int64 {
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			return lo.SliceToMap(instanceTypes, func(it *corecloudprovider.InstanceType) (string, int64) {
+				return it.Name, it.Capacity.Pods().Value()
+			})
+		}
+		BeforeEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableVPCCNIPodDensity: lo.ToPtr(true)}))
+			daemonSet = &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: vpccni.DaemonSetName, Namespace: vpccni.Namespace},
+				Spec: appsv1.DaemonSetSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "aws-node"}},
+					Template: v1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"k8s-app": "aws-node"}},
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{{Name: "aws-node", Image: "amazon-k8s-cni"}},
+						},
+					},
+				},
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		})
+		AfterEach(func() {
+			ExpectDeleted(ctx, env.Client, daemonSet)
+		})
+		It("should compute pods from secondary addresses by default", func() {
+			ExpectApplied(ctx, env.Client, daemonSet)
+			pods := podsByInstanceType()
+			Expect(pods["m5.xlarge"]).To(BeNumerically("==", 58))
+			Expect(pods["p3.8xlarge"]).To(BeNumerically("==", 234))
+		})
+		It("should compute pods from prefixes with prefix delegation", func() {
+			daemonSet.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "ENABLE_PREFIX_DELEGATION", Value: "true"}}
+			ExpectApplied(ctx, env.Client, daemonSet)
+			pods := podsByInstanceType()
+			// Limited to 110 pods for instance types with up to 30 vCPUs, and 250 pods for larger ones
+			Expect(pods["m5.xlarge"]).To(BeNumerically("==", 110))
+			Expect(pods["g4dn.8xlarge"]).To(BeNumerically("==", 250))
+			// Prefix delegation isn't supported on instance types that aren't built on Nitro
+			Expect(pods["p3.8xlarge"]).To(BeNumerically("==", 234))
+		})
+		It("should not use the primary network interface with custom networking", func() {
+			daemonSet.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "AWS_VPC_K8S_CNI_CUSTOM_NETWORK_CFG", Value: "true"}}
+			ExpectApplied(ctx, env.Client, daemonSet)
+			pods := podsByInstanceType()
+			Expect(pods["m5.xlarge"]).To(BeNumerically("==", 44))
+			Expect(pods["p3.8xlarge"]).To(BeNumerically("==", 205))
+		})
+		It("should not reserve more network interfaces than aws.reservedENIs with custom networking", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableVPCCNIPodDensity: lo.ToPtr(true), ReservedENIs: lo.ToPtr(2)}))
+			daemonSet.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "AWS_VPC_K8S_CNI_CUSTOM_NETWORK_CFG", Value: "true"}}
+			ExpectApplied(ctx, env.Client, daemonSet)
+			Expect(podsByInstanceType()["m5.xlarge"]).To(BeNumerically("==", 30))
+		})
+		It("should not limit pods by addresses with IPv6", func() {
+			daemonSet.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "ENABLE_IPv6", Value: "true"}}
+			ExpectApplied(ctx, env.Client, daemonSet)
+			pods := podsByInstanceType()
+			Expect(pods["m5.xlarge"]).To(BeNumerically("==", 110))
+			Expect(pods["p3.8xlarge"]).To(BeNumerically("==", 250))
+		})
+		It("should ignore variables that aren't true", func() {
+			daemonSet.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "ENABLE_PREFIX_DELEGATION", Value: "false"},
+				{Name: "AWS_VPC_K8S_CNI_CUSTOM_NETWORK_CFG", Value: "yes"},
+				{Name: "ENABLE_IPv6", ValueFrom: &v1.EnvVarSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{Key: "ipv6"}}},
+				{Name: "WARM_IP_TARGET", Value: "5"},
+			}
+			ExpectApplied(ctx, env.Client, daemonSet)
+			Expect(podsByInstanceType()["m5.xlarge"]).To(BeNumerically("==", 58))
+		})
+		It("should use the static pod density when the aws-node daemonset doesn't exist", func() {
+			Expect(podsByInstanceType()["m5.xlarge"]).To(BeNumerically("==", 58))
+		})
+		It("should ignore the aws-node daemonset when disabled", func() {
+			ctx = settings.ToContext(ctx, test.Settings())
+			daemonSet.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "ENABLE_PREFIX_DELEGATION", Value: "true"}}
+			ExpectApplied(ctx, env.Client, daemonSet)
+			Expect(podsByInstanceType()["m5.xlarge"]).To(BeNumerically("==", 58))
+		})
+		It("should use the kubelet's max pods over the VPC CNI's", func() {
+			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{MaxPods: lo.ToPtr[int32](20)}
+			daemonSet.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "ENABLE_PREFIX_DELEGATION", Value: "true"}}
+			ExpectApplied(ctx, env.Client, provisioner, daemonSet)
+			Expect(podsByInstanceType()["m5.xlarge"]).To(BeNumerically("==", 20))
+		})
+		It("should compute Windows pods from prefixes with Windows prefix delegation", func() {
+			configMap := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: vpccni.ConfigMapName, Namespace: vpccni.Namespace},
+				Data:       map[string]string{"enable-windows-prefix-delegation": "true"},
+			}
+			ExpectApplied(ctx, env.Client, configMap, windowsProvisioner, windowsNodeTemplate)
+			defer ExpectDeleted(ctx, env.Client, configMap)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, windowsProvisioner)
+			Expect(err).ToNot(HaveOccurred())
+			byName := lo.KeyBy(instanceTypes, func(it *corecloudprovider.InstanceType) string { return it.Name })
+			// Limited to 110 pods for instance types with up to 30 vCPUs
+			Expect(byName["m5.xlarge"].Capacity.Pods().Value()).To(BeNumerically("==", 110))
+			Expect(byName["m5.xlarge"].Capacity.Name(v1alpha1.ResourcePrivateIPv4Address, resource.DecimalSI).Value()).To(BeNumerically("==", 224))
+			// Prefix delegation isn't supported on instance types that aren't built on Nitro
+			Expect(byName["p3.8xlarge"].Capacity.Pods().Value()).To(BeNumerically("==", 29))
+		})
+		It("should pass the VPC CNI's max pods to the kubelet", func() {
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.xlarge"}}}
+			daemonSet.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "ENABLE_PREFIX_DELEGATION", Value: "true"}}
+			ExpectApplied(ctx, env.Client, provisioner, daemonSet)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Status.Capacity.Pods().Value()).To(BeNumerically("==", 110))
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">", 0))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(input *ec2.CreateLaunchTemplateInput) {
+				userData, err := base64.StdEncoding.DecodeString(aws.StringValue(input.LaunchTemplateData.UserData))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(userData)).To(ContainSubstring("--max-pods=110"))
+			})
+		})
+	})
+	It("should limit pods on Windows to the IPv4 addresses of the primary network interface when using ENI-based pod density", func() {
 		instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
 		Expect(err).To(BeNil())
 		windowsNodeTemplate := &v1alpha1.AWSNodeTemplate{
@@ -693,7 +1057,18 @@ var _ = Describe("Instance Types", func() {
 			EnableENILimitedPodDensity: lo.ToPtr(true),
 		}))
 		for _, info := range instanceInfo {
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(windowsNodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(windowsNodeTemplate), nil, nil)
+			Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", aws.Int64Value(info.NetworkInfo.Ipv4AddressesPerInterface)-1))
+		}
+	})
+	It("should set pods on Windows to 110 if not using ENI-based pod density", func() {
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+			EnableENILimitedPodDensity: lo.ToPtr(false),
+		}))
+		instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+		Expect(err).To(BeNil())
+		for _, info := range instanceInfo {
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(windowsNodeTemplate), nil, nil)
 			Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 110))
 		}
 	})
@@ -750,9 +1125,84 @@ var _ = Describe("Instance Types", func() {
 			})
 			Expect(ok).To(BeTrue())
 		})
+		Context("VM Memory Overhead", func() {
+			// The m5.xlarge has 16384Mi of memory
+			memory := func() string {
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+				return it.Capacity.Memory().String()
+			}
+			It("should subtract aws.vmMemoryOverheadPercent by default", func() {
+				Expect(memory()).To(Equal("15155Mi"))
+			})
+			It("should subtract the overhead of the instance family", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					VMMemoryOverheadByInstanceFamily: map[string]float64{"m5": 0.05, "c5": 0.2},
+				}))
+				Expect(memory()).To(Equal("15564Mi"))
+			})
+			It("should prefer the overhead of the instance type to the overhead of its family", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					VMMemoryOverheadByInstanceFamily: map[string]float64{"m5": 0.05, "m5.xlarge": 0.01},
+				}))
+				Expect(memory()).To(Equal("16220Mi"))
+			})
+			It("should subtract the overhead of the AMI family", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					VMMemoryOverheadByAMIFamily: map[string]float64{v1alpha1.AMIFamilyBottlerocket: 0.1},
+				}))
+				Expect(memory()).To(Equal("15155Mi"))
+				nodeTemplate.Spec.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+				Expect(memory()).To(Equal("14745Mi"))
+			})
+			It("should prefer the overhead of the instance family to the overhead of the AMI family", func() {
+				nodeTemplate.Spec.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					VMMemoryOverheadByInstanceFamily: map[string]float64{"m5": 0.05},
+					VMMemoryOverheadByAMIFamily:      map[string]float64{v1alpha1.AMIFamilyBottlerocket: 0.1},
+				}))
+				Expect(memory()).To(Equal("15564Mi"))
+			})
+			It("should prefer the overhead of an instance type override to the overheads of the settings", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					VMMemoryOverheadByInstanceFamily: map[string]float64{"m5.xlarge": 0.01},
+				}))
+				nodeTemplate.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{{InstanceTypes: []string{"m5.*"}, VMMemoryOverheadPercent: lo.ToPtr("0.02")}}
+				Expect(memory()).To(Equal("16056Mi"))
+			})
+			It("should use the overheads of the settings when the instance type override doesn't set one", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					VMMemoryOverheadByInstanceFamily: map[string]float64{"m5": 0.05},
+				}))
+				nodeTemplate.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{{InstanceTypes: []string{"m5.*"}, SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}}}
+				Expect(memory()).To(Equal("15564Mi"))
+			})
+			It("should reserve kube reserved memory and eviction thresholds from the memory after the overhead", func() {
+				nodeTemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaGKE)}
+				before := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					VMMemoryOverheadByInstanceFamily: map[string]float64{"m5": 0.01},
+				}))
+				after := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+				Expect(after.Overhead.KubeReserved.Memory().Cmp(*before.Overhead.KubeReserved.Memory())).To(Equal(1))
+			})
+			It("should list instance types with the overheads that are currently set", func() {
+				list := func() string {
+					instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, &v1beta1.KubeletConfiguration{}, nodeclassutil.New(nodeTemplate))
+					Expect(err).ToNot(HaveOccurred())
+					it, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.xlarge" })
+					Expect(ok).To(BeTrue())
+					return it.Capacity.Memory().String()
+				}
+				Expect(list()).To(Equal("15155Mi"))
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					VMMemoryOverheadByInstanceFamily: map[string]float64{"m5": 0.05},
+				}))
+				Expect(list()).To(Equal("15564Mi"))
+			})
+		})
 		Context("System Reserved Resources", func() {
 			It("should use defaults when no kubelet is specified", func() {
-				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.SystemReserved.Cpu().String()).To(Equal("0"))
 				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("0"))
 				Expect(it.Overhead.SystemReserved.StorageEphemeral().String()).To(Equal("0"))
@@ -767,15 +1217,96 @@ var _ = Describe("Instance Types", func() {
 						},
 					},
 				})
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.SystemReserved.Cpu().String()).To(Equal("2"))
 				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("20Gi"))
 				Expect(it.Overhead.SystemReserved.StorageEphemeral().String()).To(Equal("10Gi"))
 			})
 		})
+		Context("Instance Type Overrides", func() {
+			It("should replace the capacity of instance types that match an override", func() {
+				nodeTemplate.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{{
+					InstanceTypes: []string{"m5.*"},
+					Capacity:      v1.ResourceList{v1.ResourcePods: resource.MustParse("20"), v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
+				}}
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+				Expect(it.Capacity.Pods().String()).To(Equal("20"))
+				Expect(it.Capacity.Name(v1alpha1.ResourceNVIDIAGPU, resource.DecimalSI).String()).To(Equal("1"))
+				Expect(it.Capacity.Cpu().String()).To(Equal("4"))
+			})
+			It("should add the reserved resources of an override to the kubelet's system reserved resources", func() {
+				nodeTemplate.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{{
+					InstanceTypes:  []string{"m5.xlarge"},
+					SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+				}}
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{
+					SystemReserved: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"), v1.ResourceMemory: resource.MustParse("1Gi")},
+				}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+				Expect(it.Overhead.SystemReserved.Cpu().String()).To(Equal("1"))
+				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("3Gi"))
+			})
+			It("should only apply the first override that matches an instance type", func() {
+				nodeTemplate.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{
+					{InstanceTypes: []string{"m5.xlarge"}, SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")}},
+					{InstanceTypes: []string{"m5.*"}, SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("4Gi")}},
+				}
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("2Gi"))
+			})
+			It("should not change instance types that don't match an override", func() {
+				nodeTemplate.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{{
+					InstanceTypes:  []string{"p4d.24xlarge", "g5.*"},
+					Capacity:       v1.ResourceList{v1.ResourcePods: resource.MustParse("20")},
+					SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+				}}
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+				Expect(it.Capacity.Pods().String()).To(Equal("58"))
+				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("0"))
+			})
+			It("should list instance types again when the overrides change", func() {
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+				Expect(err).ToNot(HaveOccurred())
+				it, ok := lo.Find(instanceTypes, func(i *corecloudprovider.InstanceType) bool { return i.Name == "m5.xlarge" })
+				Expect(ok).To(BeTrue())
+				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("0"))
+
+				nodeTemplate.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{{
+					InstanceTypes:  []string{"m5.xlarge"},
+					SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+				}}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				instanceTypes, err = cloudProvider.GetInstanceTypes(ctx, provisioner)
+				Expect(err).ToNot(HaveOccurred())
+				it, ok = lo.Find(instanceTypes, func(i *corecloudprovider.InstanceType) bool { return i.Name == "m5.xlarge" })
+				Expect(ok).To(BeTrue())
+				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("2Gi"))
+			})
+		})
+		Context("Windows", func() {
+			It("should reserve memory for the OS", func() {
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(windowsNodeTemplate), nil, nil)
+				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("1536Mi"))
+			})
+			It("should override the memory reserved for the OS when system reserved is specified", func() {
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{
+					SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+				}, "", nodeclassutil.New(windowsNodeTemplate), nil, nil)
+				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("1Gi"))
+			})
+			It("should use the Windows memory eviction threshold", func() {
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(windowsNodeTemplate), nil, nil)
+				Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("500Mi"))
+			})
+			It("should compute kube reserved memory from the Windows pod density", func() {
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(windowsNodeTemplate), nil, nil)
+				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 14))
+				Expect(it.Overhead.KubeReserved.Memory().String()).To(Equal("409Mi"))
+			})
+		})
 		Context("Kube Reserved Resources", func() {
 			It("should use defaults when no kubelet is specified", func() {
-				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.KubeReserved.Cpu().String()).To(Equal("80m"))
 				Expect(it.Overhead.KubeReserved.Memory().String()).To(Equal("893Mi"))
 				Expect(it.Overhead.KubeReserved.StorageEphemeral().String()).To(Equal("1Gi"))
@@ -792,11 +1323,79 @@ var _ = Describe("Instance Types", func() {
 						v1.ResourceMemory:           resource.MustParse("10Gi"),
 						v1.ResourceEphemeralStorage: resource.MustParse("2Gi"),
 					},
-				}), "", nodeclassutil.New(nodeTemplate), nil)
+				}), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.KubeReserved.Cpu().String()).To(Equal("2"))
 				Expect(it.Overhead.KubeReserved.Memory().String()).To(Equal("10Gi"))
 				Expect(it.Overhead.KubeReserved.StorageEphemeral().String()).To(Equal("2Gi"))
 			})
+			Context("Formulas", func() {
+				BeforeEach(func() {
+					ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+						VMMemoryOverheadPercent: lo.ToPtr[float64](0),
+					}))
+				})
+				It("should reserve the same resources with the EKS formula as by default", func() {
+					nodeTemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaEKS)}
+					it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+					Expect(it.Overhead.KubeReserved.Cpu().String()).To(Equal("80m"))
+					Expect(it.Overhead.KubeReserved.Memory().String()).To(Equal("893Mi"))
+					Expect(it.Overhead.KubeReserved.StorageEphemeral().String()).To(Equal("1Gi"))
+				})
+				It("should reserve memory in proportion to the instance type's memory with the GKE formula", func() {
+					nodeTemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaGKE)}
+					it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+					// 25% of the first 4Gi, 20% of the next 4Gi and 10% of the other 8Gi of the m5.xlarge
+					Expect(it.Overhead.KubeReserved.Memory().Value()).To(BeNumerically("~", 2.6*1024*1024*1024, 1))
+					Expect(it.Overhead.KubeReserved.Cpu().String()).To(Equal("80m"))
+					Expect(it.Overhead.KubeReserved.StorageEphemeral().String()).To(Equal("1Gi"))
+				})
+				It("should reserve the steps and quantities of a custom formula", func() {
+					nodeTemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{
+						Formula:          lo.ToPtr(v1alpha1.KubeReservedFormulaCustom),
+						CPU:              []v1alpha1.ReservedStep{{UpTo: lo.ToPtr(resource.MustParse("2")), Ratio: "0.1"}, {Ratio: "0.05"}},
+						Memory:           []v1alpha1.ReservedStep{{UpTo: lo.ToPtr(resource.MustParse("8Gi")), Ratio: "0.1"}},
+						MemoryPerPod:     lo.ToPtr(resource.MustParse("10Mi")),
+						BaseMemory:       lo.ToPtr(resource.MustParse("100Mi")),
+						EphemeralStorage: lo.ToPtr(resource.MustParse("2Gi")),
+					}
+					it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+					Expect(it.Overhead.KubeReserved.Cpu().String()).To(Equal("300m"))
+					// 10% of the first 8Gi, and 10Mi for each of the 58 pods of the m5.xlarge
+					Expect(it.Overhead.KubeReserved.Memory().Value()).To(BeNumerically("~", (0.8*1024+580+100)*1024*1024, 1))
+					Expect(it.Overhead.KubeReserved.StorageEphemeral().String()).To(Equal("2Gi"))
+				})
+				It("should reserve nothing but ephemeral storage for an empty custom formula", func() {
+					nodeTemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaCustom)}
+					it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+					Expect(it.Overhead.KubeReserved.Cpu().IsZero()).To(BeTrue())
+					Expect(it.Overhead.KubeReserved.Memory().IsZero()).To(BeTrue())
+					Expect(it.Overhead.KubeReserved.StorageEphemeral().String()).To(Equal("1Gi"))
+				})
+				It("should prefer the kubelet's kube reserved to the formula", func() {
+					nodeTemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaGKE)}
+					it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{
+						KubeReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+					}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+					Expect(it.Overhead.KubeReserved.Memory().String()).To(Equal("1Gi"))
+					Expect(it.Overhead.KubeReserved.Cpu().String()).To(Equal("80m"))
+				})
+				It("should list instance types again when the formula changes", func() {
+					ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+					instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+					Expect(err).ToNot(HaveOccurred())
+					it, ok := lo.Find(instanceTypes, func(i *corecloudprovider.InstanceType) bool { return i.Name == "m5.xlarge" })
+					Expect(ok).To(BeTrue())
+					Expect(it.Overhead.KubeReserved.Memory().String()).To(Equal("893Mi"))
+
+					nodeTemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaGKE)}
+					ExpectApplied(ctx, env.Client, nodeTemplate)
+					instanceTypes, err = cloudProvider.GetInstanceTypes(ctx, provisioner)
+					Expect(err).ToNot(HaveOccurred())
+					it, ok = lo.Find(instanceTypes, func(i *corecloudprovider.InstanceType) bool { return i.Name == "m5.xlarge" })
+					Expect(ok).To(BeTrue())
+					Expect(it.Overhead.KubeReserved.Memory().Value()).To(BeNumerically("~", 2.6*1024*1024*1024, 1))
+				})
+			})
 		})
 		Context("Eviction Thresholds", func() {
 			BeforeEach(func() {
@@ -819,7 +1418,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("500Mi"))
 				})
 				It("should override eviction threshold when specified as a percentage value", func() {
@@ -836,7 +1435,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().Value()).To(BeNumerically("~", float64(it.Capacity.Memory().Value())*0.1, 10))
 				})
 				It("should consider the eviction threshold disabled when specified as 100%", func() {
@@ -853,7 +1452,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("0"))
 				})
 				It("should used default eviction threshold for memory when evictionHard not specified", func() {
@@ -870,7 +1469,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("50Mi"))
 				})
 			})
@@ -889,7 +1488,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("500Mi"))
 				})
 				It("should override eviction threshold when specified as a percentage value", func() {
@@ -909,7 +1508,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().Value()).To(BeNumerically("~", float64(it.Capacity.Memory().Value())*0.1, 10))
 				})
 				It("should consider the eviction threshold disabled when specified as 100%", func() {
@@ -926,7 +1525,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("0"))
 				})
 				It("should ignore eviction threshold when using Bottlerocket AMI", func() {
@@ -947,12 +1546,12 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("1Gi"))
 				})
 			})
 			It("should take the default eviction threshold when none is specified", func() {
-				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.EvictionThreshold.Cpu().String()).To(Equal("0"))
 				Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("100Mi"))
 				Expect(it.Overhead.EvictionThreshold.StorageEphemeral().AsApproximateFloat64()).To(BeNumerically("~", resources.Quantity("2Gi").AsApproximateFloat64()))
@@ -974,7 +1573,7 @@ var _ = Describe("Instance Types", func() {
 						},
 					},
 				})
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("3Gi"))
 			})
 			It("should take the greater of evictionHard and evictionSoft for overhead as a value", func() {
@@ -994,7 +1593,7 @@ var _ = Describe("Instance Types", func() {
 						},
 					},
 				})
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.EvictionThreshold.Memory().Value()).To(BeNumerically("~", float64(it.Capacity.Memory().Value())*0.05, 10))
 			})
 			It("should take the greater of evictionHard and evictionSoft for overhead with mixed percentage/value", func() {
@@ -1014,7 +1613,7 @@ var _ = Describe("Instance Types", func() {
 						},
 					},
 				})
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.EvictionThreshold.Memory().Value()).To(BeNumerically("~", float64(it.Capacity.Memory().Value())*0.1, 10))
 			})
 		})
@@ -1024,11 +1623,11 @@ var _ = Describe("Instance Types", func() {
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{})
 			for _, info := range instanceInfo {
 				if *info.InstanceType == "t3.large" {
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 35))
 				}
 				if *info.InstanceType == "m6idn.32xlarge" {
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 345))
 				}
 			}
@@ -1038,7 +1637,7 @@ var _ = Describe("Instance Types", func() {
 			Expect(err).To(BeNil())
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{MaxPods: ptr.Int32(10)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 10))
 			}
 		})
@@ -1051,7 +1650,7 @@ var _ = Describe("Instance Types", func() {
 			Expect(err).To(BeNil())
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{MaxPods: ptr.Int32(10)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 10))
 			}
 		})
@@ -1066,7 +1665,7 @@ var _ = Describe("Instance Types", func() {
 				return *info.InstanceType == "t3.large"
 			})
 			Expect(ok).To(Equal(true))
-			it := instancetype.NewInstanceType(ctx, t3Large, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, t3Large, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			// t3.large
 			// maxInterfaces = 3
 			// maxIPv4PerInterface = 12
@@ -1086,7 +1685,7 @@ var _ = Describe("Instance Types", func() {
 				return *info.InstanceType == "t3.large"
 			})
 			Expect(ok).To(Equal(true))
-			it := instancetype.NewInstanceType(ctx, t3Large, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, t3Large, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			// t3.large
 			// maxInterfaces = 3
 			// maxIPv4PerInterface = 12
@@ -1101,7 +1700,7 @@ var _ = Describe("Instance Types", func() {
 			Expect(err).To(BeNil())
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{PodsPerCore: ptr.Int32(1)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", ptr.Int64Value(info.VCpuInfo.DefaultVCpus)))
 			}
 		})
@@ -1110,7 +1709,7 @@ var _ = Describe("Instance Types", func() {
 			Expect(err).To(BeNil())
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{PodsPerCore: ptr.Int32(4), MaxPods: ptr.Int32(20)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", lo.Min([]int64{20, ptr.Int64Value(info.VCpuInfo.DefaultVCpus) * 4})))
 			}
 		})
@@ -1120,8 +1719,8 @@ var _ = Describe("Instance Types", func() {
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{PodsPerCore: ptr.Int32(1)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
-				limitedPods := instancetype.ENILimitedPods(ctx, info)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
+				limitedPods := instancetype.ENILimitedPods(ctx, info, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", limitedPods.Value()))
 			}
 		})
@@ -1134,7 +1733,7 @@ var _ = Describe("Instance Types", func() {
 			Expect(err).To(BeNil())
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{PodsPerCore: ptr.Int32(0)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 110))
 			}
 		})
@@ -1226,6 +1825,201 @@ var _ = Describe("Instance Types", func() {
 			}
 		})
 	})
+	Context("Compute Optimizer", func() {
+		BeforeEach(func() {
+			awsEnv.ComputeOptimizerAPI.GetEC2InstanceRecommendationsOutput.Set(&computeoptimizer.GetEC2InstanceRecommendationsOutput{
+				InstanceRecommendations: []*computeoptimizer.InstanceRecommendation{
+					{
+						InstanceArn:         aws.String("arn:aws:ec2:us-west-2:111122223333:instance/i-0123456789abcdef0"),
+						CurrentInstanceType: aws.String("m5.xlarge"),
+						Finding:             aws.String(computeoptimizer.FindingOverprovisioned),
+					},
+				},
+			})
+			Expect(awsEnv.ComputeOptimizerProvider.Update(ctx)).To(Succeed())
+		})
+		It("should inflate the price of overprovisioned instance types by the configured bias", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ComputeOptimizerPriceBias: lo.ToPtr(0.5)}))
+			Expect(awsEnv.InstanceTypesProvider.BiasPrice(ctx, "m5.xlarge", 1.0)).To(BeNumerically("~", 1.5))
+			Expect(awsEnv.InstanceTypesProvider.BiasPrice(ctx, "m5.large", 1.0)).To(BeNumerically("~", 1.0))
+		})
+		It("should not bias the price of offerings", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ComputeOptimizerPriceBias: lo.ToPtr(0.5)}))
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).ToNot(HaveOccurred())
+			for _, it := range instanceTypes {
+				price, ok := awsEnv.PricingProvider.OnDemandPrice(it.Name)
+				if !ok {
+					continue
+				}
+				for _, offering := range it.Offerings.Requirements(scheduling.NewRequirements(scheduling.NewRequirement(v1alpha5.LabelCapacityType, v1.NodeSelectorOpIn, v1alpha5.CapacityTypeOnDemand))) {
+					Expect(offering.Price).To(BeNumerically("~", price))
+				}
+			}
+		})
+		It("should not change prices when there is no bias", func() {
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).ToNot(HaveOccurred())
+			it, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.xlarge" })
+			Expect(ok).To(BeTrue())
+			price, ok := awsEnv.PricingProvider.OnDemandPrice("m5.xlarge")
+			Expect(ok).To(BeTrue())
+			offering, ok := it.Offerings.Get(v1alpha5.CapacityTypeOnDemand, "test-zone-1a")
+			Expect(ok).To(BeTrue())
+			Expect(offering.Price).To(BeNumerically("~", price))
+		})
+	})
+	Context("Bootstrap Failures", func() {
+		exclude := func(key nodeclassutil.Key, instanceType string) {
+			for i := 0; i < awscache.BootstrapFailureThreshold; i++ {
+				awsEnv.BootstrapFailuresCache.MarkFailed(key, instanceType, fmt.Sprintf("machine-%d", i), "node didn't register within 15m0s")
+			}
+		}
+		It("should not list the instance types that are excluded from the node template", func() {
+			exclude(nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true}, "m5.xlarge")
+			exclude(nodeclassutil.Key{Name: "other", IsNodeTemplate: true}, "m5.large")
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).ToNot(HaveOccurred())
+			names := lo.Map(instanceTypes, func(it *corecloudprovider.InstanceType, _ int) string { return it.Name })
+			Expect(names).ToNot(ContainElement("m5.xlarge"))
+			Expect(names).To(ContainElement("m5.large"))
+		})
+		It("should not launch instance types that are excluded from the node template", func() {
+			exclude(nodeclassutil.Key{Name: nodeTemplate.Name, IsNodeTemplate: true}, "m5.xlarge")
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.xlarge"}})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectNotScheduled(ctx, env.Client, pod)
+		})
+	})
+	Context("Excluded Instance Classes", func() {
+		listNames := func() []string {
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).ToNot(HaveOccurred())
+			return lo.Map(instanceTypes, func(it *corecloudprovider.InstanceType, _ int) string { return it.Name })
+		}
+		It("should not list metal instance types when metal is excluded", func() {
+			nodeTemplate.Spec.ExcludedInstanceClasses = []string{v1alpha1.InstanceClassMetal}
+			names := listNames()
+			Expect(names).ToNot(ContainElement("m5.metal"))
+			Expect(names).To(ContainElements("m5.large", "m5.xlarge"))
+		})
+		It("should not list burstable instance types when burstable is excluded", func() {
+			nodeTemplate.Spec.ExcludedInstanceClasses = []string{v1alpha1.InstanceClassBurstable}
+			names := listNames()
+			Expect(names).ToNot(ContainElement("t3.large"))
+			Expect(names).To(ContainElement("m5.large"))
+		})
+		It("should not list odd-sized instance types when odd-sized is excluded", func() {
+			nodeTemplate.Spec.ExcludedInstanceClasses = []string{v1alpha1.InstanceClassOddSized}
+			names := listNames()
+			Expect(names).ToNot(ContainElement("dl1.24xlarge"))
+			Expect(names).To(ContainElements("m5.metal", "m5.xlarge", "p3.8xlarge", "t3.large"))
+		})
+		It("should not list previous generation instance types when previous-generation is excluded", func() {
+			output, err := awsEnv.EC2API.DescribeInstanceTypesWithContext(ctx, &ec2.DescribeInstanceTypesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{
+				InstanceTypes: lo.Map(output.InstanceTypes, func(info *ec2.InstanceTypeInfo, _ int) *ec2.InstanceTypeInfo {
+					info = lo.ToPtr(*info)
+					info.CurrentGeneration = aws.Bool(aws.StringValue(info.InstanceType) != "m5.xlarge")
+					return info
+				}),
+			})
+			nodeTemplate.Spec.ExcludedInstanceClasses = []string{v1alpha1.InstanceClassPreviousGeneration}
+			names := listNames()
+			Expect(names).ToNot(ContainElement("m5.xlarge"))
+			Expect(names).To(ContainElement("m5.large"))
+		})
+		It("should not list the instance types of the classes that are excluded by settings", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				ExcludedInstanceClasses: []string{v1alpha1.InstanceClassMetal},
+			}))
+			nodeTemplate.Spec.ExcludedInstanceClasses = []string{v1alpha1.InstanceClassBurstable}
+			names := listNames()
+			Expect(names).ToNot(ContainElements("m5.metal", "t3.large"))
+			Expect(names).To(ContainElement("m5.large"))
+		})
+		It("should list the instance types of a class once it's no longer excluded", func() {
+			nodeTemplate.Spec.ExcludedInstanceClasses = []string{v1alpha1.InstanceClassMetal}
+			Expect(listNames()).ToNot(ContainElement("m5.metal"))
+			nodeTemplate.Spec.ExcludedInstanceClasses = nil
+			Expect(listNames()).To(ContainElement("m5.metal"))
+		})
+		It("should not launch instance types of an excluded class", func() {
+			nodeTemplate.Spec.ExcludedInstanceClasses = []string{v1alpha1.InstanceClassMetal}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.metal"}})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectNotScheduled(ctx, env.Client, pod)
+		})
+	})
+	Context("CPU Options", func() {
+		BeforeEach(func() {
+			output, err := awsEnv.EC2API.DescribeInstanceTypesWithContext(ctx, &ec2.DescribeInstanceTypesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			// The m5.large has a single core and the m5.xlarge two, each with two threads
+			validCores := map[string][]int64{"m5.large": {1}, "m5.xlarge": {1, 2}}
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{
+				InstanceTypes: lo.Map(output.InstanceTypes, func(info *ec2.InstanceTypeInfo, _ int) *ec2.InstanceTypeInfo {
+					cores, ok := validCores[aws.StringValue(info.InstanceType)]
+					if !ok {
+						return info
+					}
+					info = lo.ToPtr(*info)
+					info.VCpuInfo = &ec2.VCpuInfo{
+						DefaultCores:          info.VCpuInfo.DefaultCores,
+						DefaultVCpus:          info.VCpuInfo.DefaultVCpus,
+						DefaultThreadsPerCore: aws.Int64(2),
+						ValidCores:            aws.Int64Slice(cores),
+						ValidThreadsPerCore:   aws.Int64Slice([]int64{1, 2}),
+					}
+					return info
+				}),
+			})
+		})
+		list := func() map[string]*corecloudprovider.InstanceType {
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, &v1beta1.KubeletConfiguration{}, nodeclassutil.New(nodeTemplate))
+			Expect(err).ToNot(HaveOccurred())
+			return lo.SliceToMap(instanceTypes, func(it *corecloudprovider.InstanceType) (string, *corecloudprovider.InstanceType) { return it.Name, it })
+		}
+		It("should halve the vCPUs of instance types when simultaneous multithreading is disabled", func() {
+			nodeTemplate.Spec.CPUOptions = &v1alpha1.CPUOptions{ThreadsPerCore: aws.Int64(1)}
+			instanceTypes := list()
+			Expect(instanceTypes).To(HaveLen(2))
+			Expect(instanceTypes["m5.large"].Capacity.Cpu().String()).To(Equal("1"))
+			Expect(instanceTypes["m5.xlarge"].Capacity.Cpu().String()).To(Equal("2"))
+			Expect(instanceTypes["m5.xlarge"].Requirements.Get(v1alpha1.LabelInstanceCPU).Values()).To(ConsistOf("2"))
+		})
+		It("should compute the vCPUs of instance types from the core count and their default threads per core", func() {
+			nodeTemplate.Spec.CPUOptions = &v1alpha1.CPUOptions{CoreCount: aws.Int64(1)}
+			instanceTypes := list()
+			Expect(instanceTypes).To(HaveLen(2))
+			Expect(instanceTypes["m5.large"].Capacity.Cpu().String()).To(Equal("2"))
+			Expect(instanceTypes["m5.xlarge"].Capacity.Cpu().String()).To(Equal("2"))
+		})
+		It("should only list the instance types that support the core count", func() {
+			nodeTemplate.Spec.CPUOptions = &v1alpha1.CPUOptions{CoreCount: aws.Int64(2), ThreadsPerCore: aws.Int64(1)}
+			instanceTypes := list()
+			Expect(lo.Keys(instanceTypes)).To(ConsistOf("m5.xlarge"))
+			Expect(instanceTypes["m5.xlarge"].Capacity.Cpu().String()).To(Equal("2"))
+		})
+		It("should compute kube reserved resources and pods per core from the vCPUs with the CPU options", func() {
+			nodeTemplate.Spec.CPUOptions = &v1alpha1.CPUOptions{ThreadsPerCore: aws.Int64(1)}
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, &v1beta1.KubeletConfiguration{PodsPerCore: ptr.Int32(4)}, nodeclassutil.New(nodeTemplate))
+			Expect(err).ToNot(HaveOccurred())
+			instanceType, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.xlarge" })
+			Expect(ok).To(BeTrue())
+			// 6% of the first core and 1% of the second
+			Expect(instanceType.Overhead.KubeReserved.Cpu().String()).To(Equal("70m"))
+			Expect(instanceType.Capacity.Pods().String()).To(Equal("8"))
+		})
+		It("should list all instance types with their vCPUs without CPU options", func() {
+			instanceTypes := list()
+			Expect(len(instanceTypes)).To(BeNumerically(">", 2))
+			Expect(instanceTypes["m5.xlarge"].Capacity.Cpu().String()).To(Equal("4"))
+		})
+	})
 	Context("Insufficient Capacity Error Cache", func() {
 		It("should launch instances of different type on second reconciliation attempt with Insufficient Capacity Error Cache fallback", func() {
 			awsEnv.EC2API.InsufficientCapacityPools.Set([]fake.CapacityPool{{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "inf1.6xlarge", Zone: "test-zone-1a"}})
@@ -1338,6 +2132,131 @@ var _ = Describe("Instance Types", func() {
 			node := ExpectScheduled(ctx, env.Client, pod)
 			Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "inf1.6xlarge"))
 		})
+		It("should export the expiry of unavailable offerings as metrics", func() {
+			awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)
+			labels := map[string]string{
+				"instance_type": "m5.large",
+				"zone":          "test-zone-1a",
+				"capacity_type": v1alpha5.CapacityTypeSpot,
+			}
+			metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_unavailable_offering_expiry_time_seconds", labels)
+			Expect(ok).To(BeTrue())
+			Expect(metric.GetGauge().GetValue()).To(BeNumerically(">", float64(time.Now().Unix())))
+
+			awsEnv.UnavailableOfferingsCache.Delete("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)
+			_, ok = FindMetricWithLabelValues("karpenter_cloudprovider_unavailable_offering_expiry_time_seconds", labels)
+			Expect(ok).To(BeFalse())
+		})
+		It("should mark offerings as unavailable for the unavailableOfferingsTTL", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{UnavailableOfferingsTTL: lo.ToPtr(10 * time.Minute)}))
+			awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)
+			awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)
+			offerings := awsEnv.UnavailableOfferingsCache.List()
+			Expect(offerings).To(HaveLen(1))
+			Expect(offerings[0].Expiration).To(BeTemporally("~", time.Now().Add(10*time.Minute), time.Minute))
+		})
+		It("should double the TTL of offerings that repeatedly return insufficient capacity errors up to the unavailableOfferingsMaxTTL", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				UnavailableOfferingsTTL:    lo.ToPtr(time.Minute),
+				UnavailableOfferingsMaxTTL: lo.ToPtr(5 * time.Minute),
+			}))
+			labels := map[string]string{
+				"instance_type": "m5.large",
+				"zone":          "test-zone-1a",
+				"capacity_type": v1alpha5.CapacityTypeOnDemand,
+			}
+			for _, ttl := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+				awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)
+				metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_unavailable_offering_ttl_seconds", labels)
+				Expect(ok).To(BeTrue())
+				Expect(metric.GetGauge().GetValue()).To(Equal(ttl.Seconds()))
+			}
+			// Other offerings aren't affected
+			awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1b", v1alpha5.CapacityTypeOnDemand)
+			metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_unavailable_offering_ttl_seconds", lo.Assign(labels, map[string]string{"zone": "test-zone-1b"}))
+			Expect(ok).To(BeTrue())
+			Expect(metric.GetGauge().GetValue()).To(Equal(time.Minute.Seconds()))
+		})
+		It("should not extend the TTL without an unavailableOfferingsMaxTTL", func() {
+			awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)
+			awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)
+			metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_unavailable_offering_ttl_seconds", map[string]string{
+				"instance_type": "m5.large",
+				"zone":          "test-zone-1a",
+				"capacity_type": v1alpha5.CapacityTypeSpot,
+			})
+			Expect(ok).To(BeTrue())
+			Expect(metric.GetGauge().GetValue()).To(Equal((3 * time.Minute).Seconds()))
+		})
+		Context("Endpoint", func() {
+			serve := func(method, query string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				awscache.NewUnavailableOfferingsHandler(awsEnv.UnavailableOfferingsCache).ServeHTTP(w, httptest.NewRequest(method, awscache.UnavailableOfferingsPath+query, nil))
+				return w
+			}
+			list := func(query string) []awscache.UnavailableOffering {
+				w := serve(http.MethodGet, query)
+				Expect(w.Code).To(Equal(http.StatusOK))
+				var offerings []awscache.UnavailableOffering
+				Expect(json.Unmarshal(w.Body.Bytes(), &offerings)).To(Succeed())
+				return offerings
+			}
+			BeforeEach(func() {
+				awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)
+				awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1b", v1alpha5.CapacityTypeSpot)
+				awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.xlarge", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)
+			})
+			It("should list the unavailable offerings", func() {
+				offerings := list("")
+				Expect(lo.Map(offerings, func(o awscache.UnavailableOffering, _ int) string {
+					return o.InstanceType + "/" + o.Zone + "/" + o.CapacityType
+				})).To(Equal([]string{
+					"m5.xlarge/test-zone-1a/on-demand",
+					"m5.large/test-zone-1a/spot",
+					"m5.large/test-zone-1b/spot",
+				}))
+				for _, o := range offerings {
+					Expect(o.Expiration).To(BeTemporally(">", time.Now()))
+				}
+			})
+			It("should list the unavailable offerings that match the query", func() {
+				Expect(list("?instanceType=m5.large&zone=test-zone-1b")).To(ConsistOf(HaveField("Zone", "test-zone-1b")))
+				Expect(list("?capacityType=on-demand")).To(ConsistOf(HaveField("InstanceType", "m5.xlarge")))
+				Expect(list("?zone=test-zone-1c")).To(BeEmpty())
+			})
+			It("should make the offerings that match available", func() {
+				Expect(awsEnv.UnavailableOfferingsCache.DeleteMatching("m5.large", "", "")).To(Equal(2))
+				Expect(awsEnv.UnavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)).To(BeFalse())
+				Expect(awsEnv.UnavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1b", v1alpha5.CapacityTypeSpot)).To(BeFalse())
+				Expect(awsEnv.UnavailableOfferingsCache.IsUnavailable("m5.xlarge", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)).To(BeTrue())
+			})
+			It("should make every offering available", func() {
+				Expect(awsEnv.UnavailableOfferingsCache.DeleteMatching("", "", "")).To(Equal(3))
+				Expect(list("")).To(BeEmpty())
+			})
+			It("should offer instance types again once their offerings are made available", func() {
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+				Expect(err).ToNot(HaveOccurred())
+				m5Large, _ := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.large" })
+				Expect(m5Large.Offerings.Available().Requirements(scheduling.NewRequirements(
+					scheduling.NewRequirement(v1alpha5.LabelCapacityType, v1.NodeSelectorOpIn, v1alpha5.CapacityTypeSpot),
+					scheduling.NewRequirement(v1.LabelTopologyZone, v1.NodeSelectorOpIn, "test-zone-1a"),
+				))).To(BeEmpty())
+				awsEnv.UnavailableOfferingsCache.DeleteMatching("", "", "")
+				instanceTypes, err = cloudProvider.GetInstanceTypes(ctx, provisioner)
+				Expect(err).ToNot(HaveOccurred())
+				m5Large, _ = lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.large" })
+				Expect(m5Large.Offerings.Available().Requirements(scheduling.NewRequirements(
+					scheduling.NewRequirement(v1alpha5.LabelCapacityType, v1.NodeSelectorOpIn, v1alpha5.CapacityTypeSpot),
+					scheduling.NewRequirement(v1.LabelTopologyZone, v1.NodeSelectorOpIn, "test-zone-1a"),
+				))).ToNot(BeEmpty())
+			})
+			It("should reject requests that aren't GETs", func() {
+				Expect(serve(http.MethodDelete, "").Code).To(Equal(http.StatusMethodNotAllowed))
+				Expect(list("")).To(HaveLen(3))
+			})
+		})
 		It("should launch instances in a different zone on second reconciliation attempt with Insufficient Capacity Error Cache fallback (Habana)", func() {
 			awsEnv.EC2API.InsufficientCapacityPools.Set([]fake.CapacityPool{{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "dl1.24xlarge", Zone: "test-zone-1a"}})
 			pod := coretest.UnschedulablePod(coretest.PodOptions{
@@ -1436,6 +2355,57 @@ var _ = Describe("Instance Types", func() {
 			Expect(instanceTypeNames.Has("m5.xlarge"))
 		})
 	})
+	Context("Offering Scores", func() {
+		BeforeEach(func() {
+			awsEnv.EC2API.GetSpotPlacementScoresBehavior.Output.Set(&ec2.GetSpotPlacementScoresOutput{SpotPlacementScores: []*ec2.SpotPlacementScore{
+				{AvailabilityZoneId: aws.String("testzone1a"), Score: aws.Int64(9)},
+				{AvailabilityZoneId: aws.String("testzone1b"), Score: aws.Int64(2)},
+			}})
+		})
+		It("should give offerings without capacity signals the highest score", func() {
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)).To(BeNumerically("==", instancetype.MaxOfferingScore))
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)).To(BeNumerically("==", instancetype.MaxOfferingScore))
+		})
+		It("should score spot offerings by the spot placement score of their zone", func() {
+			_, err := awsEnv.SpotPlacementScoreProvider.Get(ctx, []string{"m5.large", "m5.xlarge"}, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)).To(BeNumerically("==", 9))
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.xlarge", "test-zone-1b", v1alpha5.CapacityTypeSpot)).To(BeNumerically("==", 2))
+			// Zones that weren't scored keep the highest score
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1c", v1alpha5.CapacityTypeSpot)).To(BeNumerically("==", instancetype.MaxOfferingScore))
+		})
+		It("should score every instance type in a zone by the zone's spot placement score", func() {
+			_, err := awsEnv.SpotPlacementScoreProvider.Get(ctx, []string{"m5.large"}, 10)
+			Expect(err).ToNot(HaveOccurred())
+			// EC2 scores the request as a whole, so it doesn't rank the instance types of the zone against each other
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("c6g.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)).
+				To(Equal(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)))
+		})
+		It("should not score on-demand offerings by spot placement scores", func() {
+			_, err := awsEnv.SpotPlacementScoreProvider.Get(ctx, []string{"m5.large"}, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1b", v1alpha5.CapacityTypeOnDemand)).To(BeNumerically("==", instancetype.MaxOfferingScore))
+		})
+		It("should lower the score of offerings that recently returned insufficient capacity errors", func() {
+			awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)).
+				To(BeNumerically("==", instancetype.MaxOfferingScore-instancetype.UnavailableOfferingScorePenalty))
+			// The offering is scored lower even once it's available again
+			awsEnv.UnavailableOfferingsCache.Delete("m5.large", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)
+			awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)).
+				To(BeNumerically("==", instancetype.MaxOfferingScore-2*instancetype.UnavailableOfferingScorePenalty))
+			// Other offerings aren't affected
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1b", v1alpha5.CapacityTypeOnDemand)).To(BeNumerically("==", instancetype.MaxOfferingScore))
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)).To(BeNumerically("==", instancetype.MaxOfferingScore))
+		})
+		It("should not score offerings below zero", func() {
+			_, err := awsEnv.SpotPlacementScoreProvider.Get(ctx, []string{"m5.large"}, 10)
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.UnavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1b", v1alpha5.CapacityTypeSpot)
+			Expect(awsEnv.InstanceTypesProvider.OfferingScore("m5.large", "test-zone-1b", v1alpha5.CapacityTypeSpot)).To(BeNumerically("==", 0))
+		})
+	})
 	Context("CapacityType", func() {
 		It("should default to on-demand", func() {
 			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
@@ -1508,6 +2478,122 @@ var _ = Describe("Instance Types", func() {
 			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.ProvisionerNameLabelKey, provisioner.Name))
 		})
 	})
+	Context("Capacity Reservations", func() {
+		BeforeEach(func() {
+			awsEnv.EC2API.DescribeCapacityReservationsOutput.Set(&ec2.DescribeCapacityReservationsOutput{
+				CapacityReservations: []*ec2.CapacityReservation{
+					{
+						CapacityReservationId:  aws.String("cr-m5large"),
+						InstanceType:           aws.String("m5.large"),
+						AvailabilityZone:       aws.String("test-zone-1b"),
+						AvailableInstanceCount: aws.Int64(2),
+						State:                  aws.String(ec2.CapacityReservationStateActive),
+					},
+					{
+						CapacityReservationId:  aws.String("cr-m5xlarge"),
+						InstanceType:           aws.String("m5.xlarge"),
+						AvailabilityZone:       aws.String("test-zone-1a"),
+						AvailableInstanceCount: aws.Int64(1),
+						State:                  aws.String(ec2.CapacityReservationStateActive),
+					},
+					{
+						CapacityReservationId:  aws.String("cr-m52xlarge"),
+						InstanceType:           aws.String("m5.2xlarge"),
+						AvailabilityZone:       aws.String("test-zone-1a"),
+						AvailableInstanceCount: aws.Int64(0),
+						State:                  aws.String(ec2.CapacityReservationStateActive),
+					},
+				},
+			})
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}},
+			}
+		})
+		It("should only allow instance types with available capacity reservations to schedule pods that require them", func() {
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).ToNot(HaveOccurred())
+			for _, it := range instanceTypes {
+				requirement := it.Requirements.Get(v1alpha1.LabelCapacityReservationID)
+				switch it.Name {
+				case "m5.large":
+					Expect(requirement.Operator()).To(Equal(v1.NodeSelectorOpNotIn))
+					Expect(requirement.Has("cr-m5large")).To(BeTrue())
+					Expect(requirement.Has("cr-m5xlarge")).To(BeFalse())
+				case "m5.xlarge":
+					Expect(requirement.Operator()).To(Equal(v1.NodeSelectorOpNotIn))
+					Expect(requirement.Has("cr-m5xlarge")).To(BeTrue())
+					Expect(requirement.Has("cr-m5large")).To(BeFalse())
+				default:
+					Expect(requirement.Operator()).To(Equal(v1.NodeSelectorOpDoesNotExist))
+				}
+			}
+		})
+		It("should launch pods that require a capacity reservation into it", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{v1alpha1.LabelCapacityReservationID: "cr-m5large"},
+			})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelCapacityReservationID, "cr-m5large"))
+			Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "m5.large"))
+			Expect(node.Labels).To(HaveKeyWithValue(v1.LabelTopologyZone, "test-zone-1b"))
+			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeOnDemand))
+
+			call := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(call.TargetCapacitySpecification.DefaultTargetCapacityType)).To(Equal(v1alpha5.CapacityTypeOnDemand))
+			for _, ltc := range call.LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(aws.StringValue(override.InstanceType)).To(Equal("m5.large"))
+					Expect(aws.StringValue(override.AvailabilityZone)).To(Equal("test-zone-1b"))
+				}
+			}
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(ltInput.LaunchTemplateData.CapacityReservationSpecification).ToNot(BeNil())
+				Expect(aws.StringValue(ltInput.LaunchTemplateData.CapacityReservationSpecification.CapacityReservationTarget.CapacityReservationId)).To(Equal("cr-m5large"))
+			})
+		})
+		It("should not target a capacity reservation for pods that don't require one", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.large"},
+			})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).ToNot(HaveKey(v1alpha1.LabelCapacityReservationID))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(ltInput.LaunchTemplateData.CapacityReservationSpecification).To(BeNil())
+			})
+		})
+		It("should not launch pods that require a capacity reservation with no available instances", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{v1alpha1.LabelCapacityReservationID: "cr-m52xlarge"},
+			})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectNotScheduled(ctx, env.Client, pod)
+		})
+		It("should not launch pods that require a capacity reservation that isn't active", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{v1alpha1.LabelCapacityReservationID: "cr-unknown"},
+			})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectNotScheduled(ctx, env.Client, pod)
+		})
+		It("should not launch pods that require a capacity reservation into a zone that it isn't in", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{
+					v1alpha1.LabelCapacityReservationID: "cr-m5large",
+					v1.LabelTopologyZone:                "test-zone-1a",
+				},
+			})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectNotScheduled(ctx, env.Client, pod)
+		})
+	})
 	Context("Ephemeral Storage", func() {
 		BeforeEach(func() {
 			nodeTemplate.Spec.AMIFamily = aws.String(v1alpha1.AMIFamilyAL2)
@@ -34,6 +34,7 @@ import (
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/vpccni"
 
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
@@ -48,27 +49,50 @@ const (
 
 var (
 	instanceTypeScheme = regexp.MustCompile(`(^[a-z]+)(\-[0-9]+tb)?([0-9]+).*\.`)
+
+	// Windows Server uses more memory for the OS than Linux, which kubelet doesn't reserve for by default
+	windowsSystemReservedMemory = resource.MustParse("1536Mi")
+	// kubelet evicts pods on Windows when less memory than this is available, rather than 100Mi on Linux
+	windowsEvictionHardMemory = resource.MustParse("500Mi")
 )
 
+// NewInstanceType returns the instance type that instances of the EC2 instance type are launched as with the NodeClass.
+// Its pod density is computed for the configuration of the VPC CNI, or for the default configuration if it's nil.
 func NewInstanceType(ctx context.Context, info *ec2.InstanceTypeInfo, kc *corev1beta1.KubeletConfiguration,
-	region string, nodeClass *v1beta1.NodeClass, offerings cloudprovider.Offerings) *cloudprovider.InstanceType {
+	region string, nodeClass *v1beta1.NodeClass, offerings cloudprovider.Offerings, cni *vpccni.Config) *cloudprovider.InstanceType {
 
-	amiFamily := amifamily.GetAMIFamily(nodeClass.Spec.AMIFamily, &amifamily.Options{})
-	return &cloudprovider.InstanceType{
+	amiFamily := amifamily.GetAMIFamilyForNodeClass(nodeClass, &amifamily.Options{})
+	instanceType := &cloudprovider.InstanceType{
 		Name:         aws.StringValue(info.InstanceType),
-		Requirements: computeRequirements(ctx, info, offerings, region, amiFamily, kc),
+		Requirements: computeRequirements(ctx, info, offerings, region, amiFamily, nodeClass.Spec.BlockDeviceMappings, kc, cni),
 		Offerings:    offerings,
-		Capacity:     computeCapacity(ctx, info, amiFamily, nodeClass.Spec.BlockDeviceMappings, kc),
+		Capacity:     computeCapacity(ctx, info, amiFamily, nodeClass, kc, cni),
 		Overhead: &cloudprovider.InstanceTypeOverhead{
-			KubeReserved:      kubeReservedResources(cpu(info), pods(ctx, info, amiFamily, kc), ENILimitedPods(ctx, info), amiFamily, kc),
-			SystemReserved:    systemReservedResources(kc),
-			EvictionThreshold: evictionThreshold(memory(ctx, info), ephemeralStorage(amiFamily, nodeClass.Spec.BlockDeviceMappings), amiFamily, kc),
+			KubeReserved:      kubeReservedResources(cpu(info), memory(ctx, info, nodeClass), pods(ctx, info, amiFamily, kc, cni), ENILimitedPods(ctx, info, cni), amiFamily, kc, nodeClass),
+			SystemReserved:    systemReservedResources(amiFamily, kc),
+			EvictionThreshold: evictionThreshold(memory(ctx, info, nodeClass), ephemeralStorage(amiFamily, nodeClass.Spec.BlockDeviceMappings), amiFamily, kc),
 		},
 	}
+	if override, ok := nodeClass.Spec.InstanceTypeOverride(instanceType.Name); ok {
+		applyOverride(instanceType, override)
+	}
+	return instanceType
+}
+
+// applyOverride replaces the capacity of the instance type with the override's, and adds the override's reserved
+// resources to the instance type's system reserved resources. The launch template resolver passes the resulting
+// system reserved resources to the kubelet, so that nodes allocate the same resources that pods were scheduled against.
+func applyOverride(instanceType *cloudprovider.InstanceType, override *v1beta1.InstanceTypeOverride) {
+	for name, quantity := range override.Capacity {
+		instanceType.Capacity[name] = quantity
+	}
+	if len(override.SystemReserved) > 0 {
+		instanceType.Overhead.SystemReserved = resources.Merge(instanceType.Overhead.SystemReserved, override.SystemReserved)
+	}
 }
 
 func computeRequirements(ctx context.Context, info *ec2.InstanceTypeInfo, offerings cloudprovider.Offerings, region string,
-	amiFamily amifamily.AMIFamily, kc *corev1beta1.KubeletConfiguration) scheduling.Requirements {
+	amiFamily amifamily.AMIFamily, blockDeviceMappings []*v1beta1.BlockDeviceMapping, kc *corev1beta1.KubeletConfiguration, cni *vpccni.Config) scheduling.Requirements {
 	requirements := scheduling.NewRequirements(
 		// Well Known Upstream
 		scheduling.NewRequirement(v1.LabelInstanceTypeStable, v1.NodeSelectorOpIn, aws.StringValue(info.InstanceType)),
@@ -83,7 +107,10 @@ func computeRequirements(ctx context.Context, info *ec2.InstanceTypeInfo, offeri
 		scheduling.NewRequirement(v1alpha1.LabelInstanceCPU, v1.NodeSelectorOpIn, fmt.Sprint(aws.Int64Value(info.VCpuInfo.DefaultVCpus))),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceMemory, v1.NodeSelectorOpIn, fmt.Sprint(aws.Int64Value(info.MemoryInfo.SizeInMiB))),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceNetworkBandwidth, v1.NodeSelectorOpDoesNotExist),
-		scheduling.NewRequirement(v1alpha1.LabelInstancePods, v1.NodeSelectorOpIn, fmt.Sprint(pods(ctx, info, amiFamily, kc))),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceEBSBandwidth, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceEBSIOPS, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelRootVolumeIOPS, v1.NodeSelectorOpIn, fmt.Sprint(rootVolumeIOPS(info, amiFamily, blockDeviceMappings))),
+		scheduling.NewRequirement(v1alpha1.LabelInstancePods, v1.NodeSelectorOpIn, fmt.Sprint(pods(ctx, info, amiFamily, kc, cni))),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceCategory, v1.NodeSelectorOpDoesNotExist),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceFamily, v1.NodeSelectorOpDoesNotExist),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceGeneration, v1.NodeSelectorOpDoesNotExist),
@@ -96,8 +123,19 @@ func computeRequirements(ctx context.Context, info *ec2.InstanceTypeInfo, offeri
 		scheduling.NewRequirement(v1alpha1.LabelInstanceAcceleratorName, v1.NodeSelectorOpDoesNotExist),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceAcceleratorManufacturer, v1.NodeSelectorOpDoesNotExist),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceAcceleratorCount, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceAcceleratorMemory, v1.NodeSelectorOpDoesNotExist),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceHypervisor, v1.NodeSelectorOpIn, aws.StringValue(info.Hypervisor)),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceEncryptionInTransitSupported, v1.NodeSelectorOpIn, fmt.Sprint(aws.BoolValue(info.NetworkInfo.EncryptionInTransitSupported))),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceBootMode, v1.NodeSelectorOpIn, aws.StringValueSlice(info.SupportedBootModes)...),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceVirtualizationType, v1.NodeSelectorOpIn, aws.StringValueSlice(info.SupportedVirtualizationTypes)...),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceRootDeviceType, v1.NodeSelectorOpIn, aws.StringValueSlice(info.SupportedRootDeviceTypes)...),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceNitroTPMSupported, v1.NodeSelectorOpIn, fmt.Sprint(aws.StringValue(info.NitroTpmSupport) == ec2.NitroTpmSupportSupported)),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceEBSNVMeSupported, v1.NodeSelectorOpIn, fmt.Sprint(info.EbsInfo != nil && lo.Contains([]string{ec2.EbsNvmeSupportSupported, ec2.EbsNvmeSupportRequired}, aws.StringValue(info.EbsInfo.NvmeSupport)))),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceEFASupported, v1.NodeSelectorOpIn, fmt.Sprint(aws.BoolValue(info.NetworkInfo.EfaSupported))),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceDedicatedHostsSupported, v1.NodeSelectorOpIn, fmt.Sprint(aws.BoolValue(info.DedicatedHostsSupported))),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceStoreVolumeCount, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceStoreVolumeSize, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelCapacityReservationID, v1.NodeSelectorOpDoesNotExist),
 	)
 	// Instance Type Labels
 	instanceFamilyParts := instanceTypeScheme.FindStringSubmatch(aws.StringValue(info.InstanceType))
@@ -113,10 +151,21 @@ func computeRequirements(ctx context.Context, info *ec2.InstanceTypeInfo, offeri
 	if info.InstanceStorageInfo != nil && aws.StringValue(info.InstanceStorageInfo.NvmeSupport) != ec2.EphemeralNvmeSupportUnsupported {
 		requirements[v1alpha1.LabelInstanceLocalNVME].Insert(fmt.Sprint(aws.Int64Value(info.InstanceStorageInfo.TotalSizeInGB)))
 	}
+	// Instance store volumes, of which the size is the size of the smallest volume in GB
+	if info.InstanceStorageInfo != nil && len(info.InstanceStorageInfo.Disks) > 0 {
+		requirements.Get(v1alpha1.LabelInstanceStoreVolumeCount).Insert(fmt.Sprint(lo.SumBy(info.InstanceStorageInfo.Disks, func(d *ec2.DiskInfo) int64 { return aws.Int64Value(d.Count) })))
+		requirements.Get(v1alpha1.LabelInstanceStoreVolumeSize).Insert(fmt.Sprint(lo.Min(lo.Map(info.InstanceStorageInfo.Disks, func(d *ec2.DiskInfo, _ int) int64 { return aws.Int64Value(d.SizeInGB) }))))
+	}
 	// Network bandwidth
 	if bandwidth, ok := InstanceTypeBandwidthMegabits[aws.StringValue(info.InstanceType)]; ok {
 		requirements[v1alpha1.LabelInstanceNetworkBandwidth].Insert(fmt.Sprint(bandwidth))
 	}
+	// Dedicated EBS bandwidth, which is only guaranteed for instance types that are EBS-optimized by default since we
+	// don't enable EBS optimization in the launch template
+	if info.EbsInfo != nil && aws.StringValue(info.EbsInfo.EbsOptimizedSupport) == ec2.EbsOptimizedSupportDefault && info.EbsInfo.EbsOptimizedInfo != nil {
+		requirements.Get(v1alpha1.LabelInstanceEBSBandwidth).Insert(fmt.Sprint(aws.Int64Value(info.EbsInfo.EbsOptimizedInfo.BaselineBandwidthInMbps)))
+		requirements.Get(v1alpha1.LabelInstanceEBSIOPS).Insert(fmt.Sprint(aws.Int64Value(info.EbsInfo.EbsOptimizedInfo.BaselineIops)))
+	}
 	// GPU Labels
 	if info.GpuInfo != nil && len(info.GpuInfo.Gpus) == 1 {
 		gpu := info.GpuInfo.Gpus[0]
@@ -126,29 +175,57 @@ func computeRequirements(ctx context.Context, info *ec2.InstanceTypeInfo, offeri
 		requirements.Get(v1alpha1.LabelInstanceGPUMemory).Insert(fmt.Sprint(aws.Int64Value(gpu.MemoryInfo.SizeInMiB)))
 	}
 	// Accelerators
-	if info.InferenceAcceleratorInfo != nil && len(info.InferenceAcceleratorInfo.Accelerators) == 1 {
-		accelerator := info.InferenceAcceleratorInfo.Accelerators[0]
+	if accelerators := inferenceAccelerators(info); len(accelerators) == 1 {
+		accelerator := accelerators[0]
 		requirements.Get(v1alpha1.LabelInstanceAcceleratorName).Insert(lowerKabobCase(aws.StringValue(accelerator.Name)))
 		requirements.Get(v1alpha1.LabelInstanceAcceleratorManufacturer).Insert(lowerKabobCase(aws.StringValue(accelerator.Manufacturer)))
 		requirements.Get(v1alpha1.LabelInstanceAcceleratorCount).Insert(fmt.Sprint(aws.Int64Value(accelerator.Count)))
+		if accelerator.MemoryInfo != nil {
+			requirements.Get(v1alpha1.LabelInstanceAcceleratorMemory).Insert(fmt.Sprint(aws.Int64Value(accelerator.MemoryInfo.SizeInMiB)))
+		}
 	}
 	// Windows Build Version Labels
 	if family, ok := amiFamily.(*amifamily.Windows); ok {
 		requirements.Get(v1.LabelWindowsBuild).Insert(family.Build)
 	}
-	return hardcodeNeuron(requirements, info)
+	return requirements
 }
 
-// TODO: remove function once DescribeInstanceTypes contains the accelerator data
+// acceleratorResourceNames maps the manufacturers of the GPUs and accelerators that DescribeInstanceTypes reports to the
+// extended resources that their device plugins advertise
+var acceleratorResourceNames = map[string]v1.ResourceName{
+	"NVIDIA": v1alpha1.ResourceNVIDIAGPU,
+	"AMD":    v1alpha1.ResourceAMDGPU,
+	"Habana": v1alpha1.ResourceHabanaGaudi,
+	"AWS":    v1alpha1.ResourceAWSNeuron,
+}
+
+// trainiumAccelerators are the Trainium accelerators of the instance types that DescribeInstanceTypes doesn't report
+// accelerators for
+// TODO: remove once DescribeInstanceTypes contains the accelerator data for every trn1 instance type
 // Values found from: https://aws.amazon.com/ec2/instance-types/trn1/
-func hardcodeNeuron(requirements scheduling.Requirements, info *ec2.InstanceTypeInfo) scheduling.Requirements {
-	// Trn1 Accelerators
-	if strings.HasPrefix(*info.InstanceType, "trn1") {
-		requirements.Get(v1alpha1.LabelInstanceAcceleratorName).Insert(lowerKabobCase("Inferentia"))
-		requirements.Get(v1alpha1.LabelInstanceAcceleratorManufacturer).Insert(lowerKabobCase("AWS"))
-		requirements.Get(v1alpha1.LabelInstanceAcceleratorCount).Insert(fmt.Sprint(awsNeurons(info)))
-	}
-	return requirements
+var trainiumAccelerators = map[string]int64{
+	"trn1.2xlarge":   1,
+	"trn1.32xlarge":  16,
+	"trn1n.32xlarge": 16,
+}
+
+// inferenceAccelerators returns the accelerators that DescribeInstanceTypes reports for the instance type, falling
+// back to the Trainium accelerators of instance types that it doesn't report them for. These have always been named
+// Inferentia, which existing selectors on the accelerator name rely on.
+func inferenceAccelerators(info *ec2.InstanceTypeInfo) []*ec2.InferenceDeviceInfo {
+	if info.InferenceAcceleratorInfo != nil && len(info.InferenceAcceleratorInfo.Accelerators) > 0 {
+		return info.InferenceAcceleratorInfo.Accelerators
+	}
+	if count, ok := trainiumAccelerators[aws.StringValue(info.InstanceType)]; ok {
+		return []*ec2.InferenceDeviceInfo{{
+			Name:         aws.String("Inferentia"),
+			Manufacturer: aws.String("AWS"),
+			Count:        aws.Int64(count),
+			MemoryInfo:   &ec2.InferenceDeviceMemoryInfo{SizeInMiB: aws.Int64(32768)},
+		}}
+	}
+	return nil
 }
 
 func getOS(info *ec2.InstanceTypeInfo, amiFamily amifamily.AMIFamily) []string {
@@ -171,31 +248,63 @@ func getArchitecture(info *ec2.InstanceTypeInfo) string {
 }
 
 func computeCapacity(ctx context.Context, info *ec2.InstanceTypeInfo, amiFamily amifamily.AMIFamily,
-	blockDeviceMappings []*v1beta1.BlockDeviceMapping, kc *corev1beta1.KubeletConfiguration) v1.ResourceList {
+	nodeClass *v1beta1.NodeClass, kc *corev1beta1.KubeletConfiguration, cni *vpccni.Config) v1.ResourceList {
 
 	resourceList := v1.ResourceList{
-		v1.ResourceCPU:               *cpu(info),
-		v1.ResourceMemory:            *memory(ctx, info),
-		v1.ResourceEphemeralStorage:  *ephemeralStorage(amiFamily, blockDeviceMappings),
-		v1.ResourcePods:              *pods(ctx, info, amiFamily, kc),
-		v1alpha1.ResourceAWSPodENI:   *awsPodENI(ctx, aws.StringValue(info.InstanceType)),
-		v1alpha1.ResourceNVIDIAGPU:   *nvidiaGPUs(info),
-		v1alpha1.ResourceAMDGPU:      *amdGPUs(info),
-		v1alpha1.ResourceAWSNeuron:   *awsNeurons(info),
-		v1alpha1.ResourceHabanaGaudi: *habanaGaudis(info),
+		v1.ResourceCPU:              *cpu(info),
+		v1.ResourceMemory:           *memory(ctx, info, nodeClass),
+		v1.ResourceEphemeralStorage: *ephemeralStorage(amiFamily, nodeClass.Spec.BlockDeviceMappings),
+		v1.ResourcePods:             *pods(ctx, info, amiFamily, kc, cni),
+		v1alpha1.ResourceAWSPodENI:  *awsPodENI(ctx, aws.StringValue(info.InstanceType)),
+	}
+	for name, quantity := range accelerators(info) {
+		resourceList[name] = quantity
 	}
 	if _, ok := amiFamily.(*amifamily.Windows); ok {
 		//ResourcePrivateIPv4Address is the same as ENILimitedPods on Windows node
-		resourceList[v1alpha1.ResourcePrivateIPv4Address] = *privateIPv4Address(info)
+		resourceList[v1alpha1.ResourcePrivateIPv4Address] = *privateIPv4Address(info, cni)
 	}
 	return resourceList
 }
 
+// withCPUOptions returns the instance type with the cores, threads per core and vCPUs that its instances are launched
+// with when the CPU options are set, or false if the instance type doesn't support them. Options that aren't set
+// default to the instance type's.
+func withCPUOptions(info *ec2.InstanceTypeInfo, options *v1beta1.CPUOptions) (*ec2.InstanceTypeInfo, bool) {
+	if options == nil || (options.CoreCount == nil && options.ThreadsPerCore == nil) {
+		return info, true
+	}
+	cores, threadsPerCore := aws.Int64Value(info.VCpuInfo.DefaultCores), aws.Int64Value(info.VCpuInfo.DefaultThreadsPerCore)
+	if threadsPerCore == 0 && cores > 0 {
+		threadsPerCore = aws.Int64Value(info.VCpuInfo.DefaultVCpus) / cores
+	}
+	if options.CoreCount != nil {
+		if !lo.Contains(aws.Int64ValueSlice(info.VCpuInfo.ValidCores), *options.CoreCount) {
+			return nil, false
+		}
+		cores = *options.CoreCount
+	}
+	if options.ThreadsPerCore != nil {
+		if !lo.Contains(aws.Int64ValueSlice(info.VCpuInfo.ValidThreadsPerCore), *options.ThreadsPerCore) {
+			return nil, false
+		}
+		threadsPerCore = *options.ThreadsPerCore
+	}
+	if cores == 0 || threadsPerCore == 0 {
+		return nil, false
+	}
+	vCPUInfo := *info.VCpuInfo
+	vCPUInfo.DefaultCores, vCPUInfo.DefaultThreadsPerCore, vCPUInfo.DefaultVCpus = aws.Int64(cores), aws.Int64(threadsPerCore), aws.Int64(cores*threadsPerCore)
+	launched := *info
+	launched.VCpuInfo = &vCPUInfo
+	return &launched, true
+}
+
 func cpu(info *ec2.InstanceTypeInfo) *resource.Quantity {
 	return resources.Quantity(fmt.Sprint(*info.VCpuInfo.DefaultVCpus))
 }
 
-func memory(ctx context.Context, info *ec2.InstanceTypeInfo) *resource.Quantity {
+func memory(ctx context.Context, info *ec2.InstanceTypeInfo, nodeClass *v1beta1.NodeClass) *resource.Quantity {
 	sizeInMib := *info.MemoryInfo.SizeInMiB
 	// Gravitons have an extra 64 MiB of cma reserved memory that we can't use
 	if len(info.ProcessorInfo.SupportedArchitectures) > 0 && *info.ProcessorInfo.SupportedArchitectures[0] == "arm64" {
@@ -203,10 +312,34 @@ func memory(ctx context.Context, info *ec2.InstanceTypeInfo) *resource.Quantity
 	}
 	mem := resources.Quantity(fmt.Sprintf("%dMi", sizeInMib))
 	// Account for VM overhead in calculation
-	mem.Sub(resource.MustParse(fmt.Sprintf("%dMi", int64(math.Ceil(float64(mem.Value())*awssettings.FromContext(ctx).VMMemoryOverheadPercent/1024/1024)))))
+	mem.Sub(resource.MustParse(fmt.Sprintf("%dMi", int64(math.Ceil(float64(mem.Value())*vmMemoryOverheadPercent(ctx, aws.StringValue(info.InstanceType), nodeClass)/1024/1024)))))
 	return mem
 }
 
+// vmMemoryOverheadPercent returns the share of the memory of the instance type that isn't available to its instances,
+// e.g. because of the hypervisor. The instance type overrides of the NodeClass take precedence over the overheads of
+// instance types and instance families, then of AMI families, and then over aws.vmMemoryOverheadPercent.
+func vmMemoryOverheadPercent(ctx context.Context, instanceType string, nodeClass *v1beta1.NodeClass) float64 {
+	if override, ok := nodeClass.Spec.InstanceTypeOverride(instanceType); ok && override.VMMemoryOverheadPercent != nil {
+		if percent, err := strconv.ParseFloat(*override.VMMemoryOverheadPercent, 64); err == nil {
+			return percent
+		}
+	}
+	s := awssettings.FromContext(ctx)
+	// aws.vmMemoryOverheadByInstanceFamily is keyed by instance families and instance types, and the exact instance type
+	// takes precedence so that e.g. m5.metal can be excluded from the overhead of m5
+	if percent, ok := s.VMMemoryOverheadByInstanceFamily[instanceType]; ok {
+		return percent
+	}
+	if percent, ok := s.VMMemoryOverheadByInstanceFamily[strings.Split(instanceType, ".")[0]]; ok {
+		return percent
+	}
+	if percent, ok := s.VMMemoryOverheadByAMIFamily[lo.FromPtrOr(nodeClass.Spec.AMIFamily, v1beta1.AMIFamilyAL2)]; ok {
+		return percent
+	}
+	return s.VMMemoryOverheadPercent
+}
+
 // Setting ephemeral-storage to be either the default value or what is defined in blockDeviceMappings
 func ephemeralStorage(amiFamily amifamily.AMIFamily, blockDeviceMappings []*v1beta1.BlockDeviceMapping) *resource.Quantity {
 	if len(blockDeviceMappings) != 0 {
@@ -233,128 +366,143 @@ func ephemeralStorage(amiFamily amifamily.AMIFamily, blockDeviceMappings []*v1be
 	return amifamily.DefaultEBS.VolumeSize
 }
 
-func awsPodENI(ctx context.Context, name string) *resource.Quantity {
-	// https://docs.aws.amazon.com/eks/latest/userguide/security-groups-for-pods.html#supported-instance-types
-	limits, ok := Limits[name]
-	if awssettings.FromContext(ctx).EnablePodENI && ok && limits.IsTrunkingCompatible {
-		return resources.Quantity(fmt.Sprint(limits.BranchInterface))
-	}
-	return resources.Quantity("0")
-}
-
-func nvidiaGPUs(info *ec2.InstanceTypeInfo) *resource.Quantity {
-	count := int64(0)
-	if info.GpuInfo != nil {
-		for _, gpu := range info.GpuInfo.Gpus {
-			if *gpu.Manufacturer == "NVIDIA" {
-				count += *gpu.Count
+// rootVolume returns the block device that backs the ephemeral storage of the node, which is the volume that pods read
+// from and write to
+func rootVolume(amiFamily amifamily.AMIFamily, blockDeviceMappings []*v1beta1.BlockDeviceMapping) *v1beta1.BlockDevice {
+	if len(blockDeviceMappings) != 0 {
+		switch amiFamily.(type) {
+		case *amifamily.Custom:
+			// We can't know which volume a custom AMI is going to use, so assume it's the last one, as for ephemeral storage
+			if blockDeviceMapping := blockDeviceMappings[len(blockDeviceMappings)-1]; blockDeviceMapping.EBS != nil {
+				return blockDeviceMapping.EBS
+			}
+		default:
+			if blockDeviceMapping, ok := lo.Find(blockDeviceMappings, func(bdm *v1beta1.BlockDeviceMapping) bool {
+				return *bdm.DeviceName == *amiFamily.EphemeralBlockDevice()
+			}); ok && blockDeviceMapping.EBS != nil {
+				return blockDeviceMapping.EBS
 			}
 		}
 	}
-	return resources.Quantity(fmt.Sprint(count))
+	if blockDeviceMapping, ok := lo.Find(amiFamily.DefaultBlockDeviceMappings(), func(item *v1beta1.BlockDeviceMapping) bool {
+		return *amiFamily.EphemeralBlockDevice() == *item.DeviceName
+	}); ok {
+		return blockDeviceMapping.EBS
+	}
+	return &amifamily.DefaultEBS
 }
 
-func amdGPUs(info *ec2.InstanceTypeInfo) *resource.Quantity {
-	count := int64(0)
-	if info.GpuInfo != nil {
-		for _, gpu := range info.GpuInfo.Gpus {
-			if *gpu.Manufacturer == "AMD" {
-				count += *gpu.Count
-			}
-		}
+// rootVolumeIOPS returns the IOPS that the root volume can sustain on the instance type. This is the baseline IOPS of
+// the volume, capped by the baseline IOPS of the instance type when it's EBS-optimized by default.
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ebs-volume-types.html
+func rootVolumeIOPS(info *ec2.InstanceTypeInfo, amiFamily amifamily.AMIFamily, blockDeviceMappings []*v1beta1.BlockDeviceMapping) int64 {
+	volume := rootVolume(amiFamily, blockDeviceMappings)
+	var iops int64
+	// EC2 creates gp2 volumes when the volume type isn't specified
+	switch lo.FromPtrOr(volume.VolumeType, ec2.VolumeTypeGp2) {
+	case ec2.VolumeTypeGp3:
+		iops = lo.FromPtrOr(volume.IOPS, 3000)
+	case ec2.VolumeTypeGp2:
+		// gp2 volumes provide 3 IOPS per GiB, between 100 and 16,000 IOPS
+		size := lo.FromPtrOr(volume.VolumeSize, lo.FromPtr(amifamily.DefaultEBS.VolumeSize))
+		iops = lo.Clamp(size.Value()/(1<<30)*3, 100, 16000)
+	case ec2.VolumeTypeIo1, ec2.VolumeTypeIo2:
+		iops = lo.FromPtr(volume.IOPS)
+	case ec2.VolumeTypeSt1:
+		iops = 500
+	case ec2.VolumeTypeSc1:
+		iops = 250
+	default:
+		iops = 100
 	}
-	return resources.Quantity(fmt.Sprint(count))
+	if info.EbsInfo != nil && aws.StringValue(info.EbsInfo.EbsOptimizedSupport) == ec2.EbsOptimizedSupportDefault && info.EbsInfo.EbsOptimizedInfo != nil {
+		iops = lo.Min([]int64{iops, aws.Int64Value(info.EbsInfo.EbsOptimizedInfo.BaselineIops)})
+	}
+	return iops
 }
 
-// TODO: remove trn1 hardcode values once DescribeInstanceTypes contains the accelerator data
-// Values found from: https://aws.amazon.com/ec2/instance-types/trn1/
-func awsNeurons(info *ec2.InstanceTypeInfo) *resource.Quantity {
-	count := int64(0)
-	if *info.InstanceType == "trn1.2xlarge" {
-		count = int64(1)
-	} else if *info.InstanceType == "trn1.32xlarge" {
-		count = int64(16)
-	} else if *info.InstanceType == "trn1n.32xlarge" {
-		count = int64(16)
-	} else if info.InferenceAcceleratorInfo != nil {
-		for _, accelerator := range info.InferenceAcceleratorInfo.Accelerators {
-			count += *accelerator.Count
-		}
+func awsPodENI(ctx context.Context, name string) *resource.Quantity {
+	// https://docs.aws.amazon.com/eks/latest/userguide/security-groups-for-pods.html#supported-instance-types
+	limits, ok := Limits[name]
+	if awssettings.FromContext(ctx).EnablePodENI && ok && limits.IsTrunkingCompatible {
+		return resources.Quantity(fmt.Sprint(limits.BranchInterface))
 	}
-	return resources.Quantity(fmt.Sprint(count))
+	return resources.Quantity("0")
 }
 
-func habanaGaudis(info *ec2.InstanceTypeInfo) *resource.Quantity {
-	count := int64(0)
+// accelerators returns the extended resources of the GPUs and accelerators that DescribeInstanceTypes reports for the
+// instance type, summed by the resource that their manufacturer's device plugin advertises. Every resource in
+// acceleratorResourceNames is returned, even if the instance type has none of them.
+func accelerators(info *ec2.InstanceTypeInfo) v1.ResourceList {
+	counts := lo.MapEntries(acceleratorResourceNames, func(_ string, name v1.ResourceName) (v1.ResourceName, int64) { return name, 0 })
 	if info.GpuInfo != nil {
 		for _, gpu := range info.GpuInfo.Gpus {
-			if *gpu.Manufacturer == "Habana" {
-				count += *gpu.Count
+			if name, ok := acceleratorResourceNames[aws.StringValue(gpu.Manufacturer)]; ok {
+				counts[name] += aws.Int64Value(gpu.Count)
 			}
 		}
 	}
-	return resources.Quantity(fmt.Sprint(count))
-}
-
-func ENILimitedPods(ctx context.Context, info *ec2.InstanceTypeInfo) *resource.Quantity {
-	// The number of pods per node is calculated using the formula:
-	// max number of ENIs * (IPv4 Addresses per ENI -1) + 2
-	// https://github.com/awslabs/amazon-eks-ami/blob/master/files/eni-max-pods.txt#L20
-
-	// VPC CNI only uses the default network interface
-	// https://github.com/aws/amazon-vpc-cni-k8s/blob/3294231c0dce52cfe473bf6c62f47956a3b333b6/scripts/gen_vpc_ip_limits.go#L162
-	networkInterfaces := *info.NetworkInfo.NetworkCards[*info.NetworkInfo.DefaultNetworkCardIndex].MaximumNetworkInterfaces
-	usableNetworkInterfaces := lo.Max([]int64{(networkInterfaces - int64(awssettings.FromContext(ctx).ReservedENIs)), 0})
-	if usableNetworkInterfaces == 0 {
-		return resource.NewQuantity(0, resource.DecimalSI)
+	for _, accelerator := range inferenceAccelerators(info) {
+		if name, ok := acceleratorResourceNames[aws.StringValue(accelerator.Manufacturer)]; ok {
+			counts[name] += aws.Int64Value(accelerator.Count)
+		}
 	}
-	addressesPerInterface := *info.NetworkInfo.Ipv4AddressesPerInterface
-	return resources.Quantity(fmt.Sprint(usableNetworkInterfaces*(addressesPerInterface-1) + 2))
+	return lo.MapValues(counts, func(count int64, _ v1.ResourceName) resource.Quantity { return *resources.Quantity(fmt.Sprint(count)) })
 }
 
-func privateIPv4Address(info *ec2.InstanceTypeInfo) *resource.Quantity {
+// ENILimitedPods returns the number of pods that the VPC CNI can assign addresses to on the instance type, for the
+// configuration of the VPC CNI or its default configuration if it's nil
+func ENILimitedPods(ctx context.Context, info *ec2.InstanceTypeInfo, cni *vpccni.Config) *resource.Quantity {
+	return resources.Quantity(fmt.Sprint(lo.FromPtr(cni).MaxPods(info, awssettings.FromContext(ctx).ReservedENIs)))
+}
 
+func privateIPv4Address(info *ec2.InstanceTypeInfo, cni *vpccni.Config) *resource.Quantity {
 	//https://github.com/aws/amazon-vpc-resource-controller-k8s/blob/ecbd6965a0100d9a070110233762593b16023287/pkg/provider/ip/provider.go#L297
-	capacity := aws.Int64Value(info.NetworkInfo.Ipv4AddressesPerInterface) - 1
-	return resources.Quantity(fmt.Sprint(capacity))
+	return resources.Quantity(fmt.Sprint(lo.FromPtr(cni).WindowsAddresses(info)))
 }
 
-func systemReservedResources(kc *corev1beta1.KubeletConfiguration) v1.ResourceList {
+func systemReservedResources(amiFamily amifamily.AMIFamily, kc *corev1beta1.KubeletConfiguration) v1.ResourceList {
 	if kc != nil && kc.SystemReserved != nil {
 		return kc.SystemReserved
 	}
+	if isWindows(amiFamily) {
+		return v1.ResourceList{v1.ResourceMemory: windowsSystemReservedMemory}
+	}
 	return v1.ResourceList{}
 }
 
-func kubeReservedResources(cpus, pods, eniLimitedPods *resource.Quantity, amiFamily amifamily.AMIFamily, kc *corev1beta1.KubeletConfiguration) v1.ResourceList {
+// reservedStep reserves a ratio of the amount of a resource between the previous step's upTo and its own
+type reservedStep struct {
+	upTo  int64
+	ratio float64
+}
+
+var (
+	// eksCPUReservedSteps are in millicores, computed from
+	// https://github.com/bottlerocket-os/bottlerocket/pull/1388/files#diff-bba9e4e3e46203be2b12f22e0d654ebd270f0b478dd34f40c31d7aa695620f2fR611
+	eksCPUReservedSteps = []reservedStep{{upTo: 1000, ratio: 0.06}, {upTo: 2000, ratio: 0.01}, {upTo: 4000, ratio: 0.005}, {upTo: 1 << 31, ratio: 0.0025}}
+	// gkeMemoryReservedSteps are in bytes, from
+	// https://cloud.google.com/kubernetes-engine/docs/concepts/plan-node-sizes#memory_and_cpu_reservations
+	gkeMemoryReservedSteps = []reservedStep{{upTo: 4 << 30, ratio: 0.25}, {upTo: 8 << 30, ratio: 0.2}, {upTo: 16 << 30, ratio: 0.1}, {upTo: 128 << 30, ratio: 0.06}, {upTo: math.MaxInt64, ratio: 0.02}}
+)
+
+func kubeReservedResources(cpus, memory, pods, eniLimitedPods *resource.Quantity, amiFamily amifamily.AMIFamily, kc *corev1beta1.KubeletConfiguration, nodeClass *v1beta1.NodeClass) v1.ResourceList {
 	if amiFamily.FeatureFlags().UsesENILimitedMemoryOverhead {
 		pods = eniLimitedPods
 	}
 	resources := v1.ResourceList{
+		v1.ResourceCPU:              *resource.NewMilliQuantity(reserved(cpus.MilliValue(), eksCPUReservedSteps), resource.DecimalSI),
 		v1.ResourceMemory:           resource.MustParse(fmt.Sprintf("%dMi", (11*pods.Value())+255)),
 		v1.ResourceEphemeralStorage: resource.MustParse("1Gi"), // default kube-reserved ephemeral-storage
 	}
-	// kube-reserved Computed from
-	// https://github.com/bottlerocket-os/bottlerocket/pull/1388/files#diff-bba9e4e3e46203be2b12f22e0d654ebd270f0b478dd34f40c31d7aa695620f2fR611
-	for _, cpuRange := range []struct {
-		start      int64
-		end        int64
-		percentage float64
-	}{
-		{start: 0, end: 1000, percentage: 0.06},
-		{start: 1000, end: 2000, percentage: 0.01},
-		{start: 2000, end: 4000, percentage: 0.005},
-		{start: 4000, end: 1 << 31, percentage: 0.0025},
-	} {
-		if cpu := cpus.MilliValue(); cpu >= cpuRange.start {
-			r := float64(cpuRange.end - cpuRange.start)
-			if cpu < cpuRange.end {
-				r = float64(cpu - cpuRange.start)
-			}
-			cpuOverhead := resources.Cpu()
-			cpuOverhead.Add(*resource.NewMilliQuantity(int64(r*cpuRange.percentage), resource.DecimalSI))
-			resources[v1.ResourceCPU] = *cpuOverhead
+	switch nodeClass.Spec.KubeReservedFormula() {
+	case v1beta1.KubeReservedFormulaGKE:
+		resources[v1.ResourceMemory] = resource.MustParse("255Mi")
+		if memory.Cmp(resource.MustParse("1Gi")) >= 0 {
+			resources[v1.ResourceMemory] = *resource.NewQuantity(reserved(memory.Value(), gkeMemoryReservedSteps), resource.BinarySI)
 		}
+	case v1beta1.KubeReservedFormulaCustom:
+		resources = customKubeReservedResources(cpus, memory, pods, nodeClass.Spec.KubeReserved)
 	}
 	if kc != nil && kc.KubeReserved != nil {
 		return lo.Assign(resources, kc.KubeReserved)
@@ -362,11 +510,55 @@ func kubeReservedResources(cpus, pods, eniLimitedPods *resource.Quantity, amiFam
 	return resources
 }
 
+// customKubeReservedResources reserves the CPU and memory steps, the memory per pod and base memory, and the ephemeral
+// storage of a Custom formula
+func customKubeReservedResources(cpus, memory, pods *resource.Quantity, kubeReserved *v1beta1.KubeReserved) v1.ResourceList {
+	memoryReserved := resource.NewQuantity(reserved(memory.Value(), newReservedSteps(kubeReserved.Memory, (*resource.Quantity).Value)), resource.BinarySI)
+	if kubeReserved.MemoryPerPod != nil {
+		memoryReserved.Add(*resource.NewQuantity(kubeReserved.MemoryPerPod.Value()*pods.Value(), resource.BinarySI))
+	}
+	if kubeReserved.BaseMemory != nil {
+		memoryReserved.Add(*kubeReserved.BaseMemory)
+	}
+	return v1.ResourceList{
+		v1.ResourceCPU:              *resource.NewMilliQuantity(reserved(cpus.MilliValue(), newReservedSteps(kubeReserved.CPU, (*resource.Quantity).MilliValue)), resource.DecimalSI),
+		v1.ResourceMemory:           *memoryReserved,
+		v1.ResourceEphemeralStorage: lo.FromPtrOr(kubeReserved.EphemeralStorage, resource.MustParse("1Gi")),
+	}
+}
+
+// newReservedSteps converts the steps of a Custom formula to the unit that value returns
+func newReservedSteps(steps []v1beta1.ReservedStep, value func(*resource.Quantity) int64) []reservedStep {
+	return lo.Map(steps, func(step v1beta1.ReservedStep, _ int) reservedStep {
+		ratio, _ := strconv.ParseFloat(step.Ratio, 64) // validated by the webhook
+		if step.UpTo == nil {
+			return reservedStep{upTo: math.MaxInt64, ratio: ratio}
+		}
+		return reservedStep{upTo: value(step.UpTo), ratio: ratio}
+	})
+}
+
+// reserved sums the ratio of the amount within each of the steps, truncating each step's reservation
+func reserved(amount int64, steps []reservedStep) int64 {
+	var total, start int64
+	for _, step := range steps {
+		if amount <= start {
+			break
+		}
+		total += int64(float64(lo.Min([]int64{amount, step.upTo})-start) * step.ratio)
+		start = step.upTo
+	}
+	return total
+}
+
 func evictionThreshold(memory *resource.Quantity, storage *resource.Quantity, amiFamily amifamily.AMIFamily, kc *corev1beta1.KubeletConfiguration) v1.ResourceList {
 	overhead := v1.ResourceList{
 		v1.ResourceMemory:           resource.MustParse("100Mi"),
 		v1.ResourceEphemeralStorage: resource.MustParse(fmt.Sprint(math.Ceil(float64(storage.Value()) / 100 * 10))),
 	}
+	if isWindows(amiFamily) {
+		overhead[v1.ResourceMemory] = windowsEvictionHardMemory
+	}
 	if kc == nil {
 		return overhead
 	}
@@ -394,13 +586,16 @@ func evictionThreshold(memory *resource.Quantity, storage *resource.Quantity, am
 	return lo.Assign(overhead, override)
 }
 
-func pods(ctx context.Context, info *ec2.InstanceTypeInfo, amiFamily amifamily.AMIFamily, kc *corev1beta1.KubeletConfiguration) *resource.Quantity {
+func pods(ctx context.Context, info *ec2.InstanceTypeInfo, amiFamily amifamily.AMIFamily, kc *corev1beta1.KubeletConfiguration, cni *vpccni.Config) *resource.Quantity {
 	var count int64
 	switch {
 	case kc != nil && kc.MaxPods != nil:
 		count = int64(ptr.Int32Value(kc.MaxPods))
 	case awssettings.FromContext(ctx).EnableENILimitedPodDensity && amiFamily.FeatureFlags().SupportsENILimitedPodDensity:
-		count = ENILimitedPods(ctx, info).Value()
+		count = ENILimitedPods(ctx, info, cni).Value()
+	case awssettings.FromContext(ctx).EnableENILimitedPodDensity && isWindows(amiFamily):
+		// Windows pods only get IP addresses from the primary network interface
+		count = lo.FromPtr(cni).WindowsMaxPods(info)
 	default:
 		count = 110
 
@@ -411,6 +606,43 @@ func pods(ctx context.Context, info *ec2.InstanceTypeInfo, amiFamily amifamily.A
 	return resources.Quantity(fmt.Sprint(count))
 }
 
+func isWindows(amiFamily amifamily.AMIFamily) bool {
+	_, ok := amiFamily.(*amifamily.Windows)
+	return ok
+}
+
+// instanceClasses returns the classes of instance types that the instance type belongs to, which NodeClasses and
+// aws.excludedInstanceClasses can exclude. Instance types that EC2 doesn't report a generation for are treated as current.
+func instanceClasses(info *ec2.InstanceTypeInfo) []string {
+	var classes []string
+	if aws.BoolValue(info.BareMetal) {
+		classes = append(classes, v1beta1.InstanceClassMetal)
+	}
+	if info.CurrentGeneration != nil && !aws.BoolValue(info.CurrentGeneration) {
+		classes = append(classes, v1beta1.InstanceClassPreviousGeneration)
+	}
+	if aws.BoolValue(info.BurstablePerformanceSupported) {
+		classes = append(classes, v1beta1.InstanceClassBurstable)
+	}
+	if parts := strings.Split(aws.StringValue(info.InstanceType), "."); len(parts) == 2 && !aws.BoolValue(info.BareMetal) && isOddSize(parts[1]) {
+		classes = append(classes, v1beta1.InstanceClassOddSized)
+	}
+	return classes
+}
+
+// isOddSize returns whether an instance size isn't one of the sizes below large, or a power of two multiple of large
+func isOddSize(size string) bool {
+	switch size {
+	case "nano", "micro", "small", "medium", "large", "xlarge":
+		return false
+	}
+	multiple, err := strconv.Atoi(strings.TrimSuffix(size, "xlarge"))
+	if err != nil || !strings.HasSuffix(size, "xlarge") {
+		return true
+	}
+	return multiple <= 0 || multiple&(multiple-1) != 0
+}
+
 func lowerKabobCase(s string) string {
 	return strings.ToLower(strings.ReplaceAll(s, " ", "-"))
 }
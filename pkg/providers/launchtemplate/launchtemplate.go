@@ -39,11 +39,13 @@ import (
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	awserrors "github.com/aws/karpenter/pkg/errors"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
 	"github.com/aws/karpenter/pkg/providers/subnet"
 	"github.com/aws/karpenter/pkg/utils"
+	"github.com/aws/karpenter/pkg/utils/tracing"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
@@ -65,9 +67,17 @@ type Provider struct {
 	cm                    *pretty.ChangeMonitor
 	KubeDNSIP             net.IP
 	ClusterEndpoint       string
+	ClusterCIDR           *string
 }
 
-func NewProvider(ctx context.Context, cache *cache.Cache, ec2api ec2iface.EC2API, amiFamily *amifamily.Resolver, securityGroupProvider *securitygroup.Provider, subnetProvider *subnet.Provider, caBundle *string, startAsync <-chan struct{}, kubeDNSIP net.IP, clusterEndpoint string) *Provider {
+// LaunchTemplate is a launch template that's ensured to exist, and the instance types to launch with it
+type LaunchTemplate struct {
+	InstanceTypes []*cloudprovider.InstanceType
+	// OutpostARN is the ARN of the Outpost whose subnets the launch template's AMI must be launched into, if any
+	OutpostARN string
+}
+
+func NewProvider(ctx context.Context, cache *cache.Cache, ec2api ec2iface.EC2API, amiFamily *amifamily.Resolver, securityGroupProvider *securitygroup.Provider, subnetProvider *subnet.Provider, caBundle *string, startAsync <-chan struct{}, kubeDNSIP net.IP, clusterEndpoint string, clusterCIDR *string) *Provider {
 	l := &Provider{
 		ec2api:                ec2api,
 		amiFamily:             amiFamily,
@@ -78,8 +88,9 @@ func NewProvider(ctx context.Context, cache *cache.Cache, ec2api ec2iface.EC2API
 		cm:                    pretty.NewChangeMonitor(),
 		KubeDNSIP:             kubeDNSIP,
 		ClusterEndpoint:       clusterEndpoint,
+		ClusterCIDR:           clusterCIDR,
 	}
-	l.cache.OnEvicted(l.cachedEvictedFunc(ctx))
+	awscache.OnEvicted(l.cache, awscache.LaunchTemplatesCacheName, l.cachedEvictedFunc(ctx))
 	go func() {
 		// only hydrate cache once elected leader
 		select {
@@ -93,13 +104,15 @@ func NewProvider(ctx context.Context, cache *cache.Cache, ec2api ec2iface.EC2API
 }
 
 func (p *Provider) EnsureAll(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim,
-	instanceTypes []*cloudprovider.InstanceType, additionalLabels map[string]string, tags map[string]string) (map[string][]*cloudprovider.InstanceType, error) {
+	instanceTypes []*cloudprovider.InstanceType, additionalLabels map[string]string, tags map[string]string) (_ map[string]*LaunchTemplate, err error) {
+	ctx, span := tracing.Start(ctx, "launchtemplate.EnsureAll")
+	defer func() { tracing.End(span, err) }()
 
 	p.Lock()
 	defer p.Unlock()
 	// If Launch Template is directly specified then just use it
 	if nodeClass.Spec.LaunchTemplateName != nil {
-		return map[string][]*cloudprovider.InstanceType{ptr.StringValue(nodeClass.Spec.LaunchTemplateName): instanceTypes}, nil
+		return map[string]*LaunchTemplate{ptr.StringValue(nodeClass.Spec.LaunchTemplateName): {InstanceTypes: instanceTypes}}, nil
 	}
 	options, err := p.createAMIOptions(ctx, nodeClass, lo.Assign(nodeClaim.Labels, additionalLabels), tags)
 	if err != nil {
@@ -109,14 +122,21 @@ func (p *Provider) EnsureAll(ctx context.Context, nodeClass *v1beta1.NodeClass,
 	if err != nil {
 		return nil, err
 	}
-	launchTemplates := map[string][]*cloudprovider.InstanceType{}
+	launchTemplates := map[string]*LaunchTemplate{}
 	for _, resolvedLaunchTemplate := range resolvedLaunchTemplates {
 		// Ensure the launch template exists, or create it
 		ec2LaunchTemplate, err := p.ensureLaunchTemplate(ctx, resolvedLaunchTemplate)
 		if err != nil {
 			return nil, err
 		}
-		launchTemplates[*ec2LaunchTemplate.LaunchTemplateName] = resolvedLaunchTemplate.InstanceTypes
+		// Instance types whose kubelet overrides don't change the user data, like the max pods of Custom user data,
+		// resolve to the same launch template, which has the same AMI, and so the same Outpost
+		launchTemplate, ok := launchTemplates[*ec2LaunchTemplate.LaunchTemplateName]
+		if !ok {
+			launchTemplate = &LaunchTemplate{OutpostARN: resolvedLaunchTemplate.OutpostARN}
+			launchTemplates[*ec2LaunchTemplate.LaunchTemplateName] = launchTemplate
+		}
+		launchTemplate.InstanceTypes = append(launchTemplate.InstanceTypes, resolvedLaunchTemplate.InstanceTypes...)
 	}
 	return launchTemplates, nil
 }
@@ -126,8 +146,8 @@ func (p *Provider) Invalidate(ctx context.Context, ltName string, ltID string) {
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("launch-template-name", ltName, "launch-template-id", ltID))
 	p.Lock()
 	defer p.Unlock()
-	defer p.cache.OnEvicted(p.cachedEvictedFunc(ctx))
-	p.cache.OnEvicted(nil)
+	defer awscache.OnEvicted(p.cache, awscache.LaunchTemplatesCacheName, p.cachedEvictedFunc(ctx))
+	awscache.OnEvicted(p.cache, awscache.LaunchTemplatesCacheName, nil)
 	logging.FromContext(ctx).Debugf("invalidating launch template in the cache because it no longer exists")
 	p.cache.Delete(ltName)
 }
@@ -168,10 +188,18 @@ func (p *Provider) createAMIOptions(ctx context.Context, nodeClass *v1beta1.Node
 		SecurityGroups: lo.Map(securityGroups, func(s *ec2.SecurityGroup, _ int) v1alpha1.SecurityGroup {
 			return v1alpha1.SecurityGroup{ID: aws.StringValue(s.GroupId), Name: aws.StringValue(s.GroupName)}
 		}),
-		Tags:      tags,
-		Labels:    labels,
-		CABundle:  p.caBundle,
-		KubeDNSIP: p.KubeDNSIP,
+		Tags:            tags,
+		Labels:          labels,
+		CABundle:        p.caBundle,
+		KubeDNSIP:       p.KubeDNSIP,
+		ClusterCIDR:     p.ClusterCIDR,
+		CloudWatchAgent: nodeClass.Spec.CloudWatchAgent,
+		DomainJoin:      nodeClass.Spec.DomainJoin,
+		Neuron:          nodeClass.Spec.Neuron,
+	}
+	// Pods that require a capacity reservation must be launched into it, so it's targeted by the launch template
+	if id, ok := labels[v1beta1.LabelCapacityReservationID]; ok {
+		options.CapacityReservationID = id
 	}
 	if ok, err := p.subnetProvider.CheckAnyPublicIPAssociations(ctx, nodeClass); err != nil {
 		return nil, err
@@ -190,8 +218,8 @@ func (p *Provider) ensureLaunchTemplate(ctx context.Context, options *amifamily.
 	name := launchTemplateName(options)
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("launch-template-name", name))
 	// Read from cache
-	if launchTemplate, ok := p.cache.Get(name); ok {
-		p.cache.SetDefault(name, launchTemplate)
+	if launchTemplate, ok := awscache.Get(p.cache, awscache.LaunchTemplatesCacheName, name); ok {
+		awscache.SetDefault(p.cache, awscache.LaunchTemplatesCacheName, name, launchTemplate)
 		return launchTemplate.(*ec2.LaunchTemplate), nil
 	}
 	// Attempt to find an existing LT.
@@ -201,6 +229,9 @@ func (p *Provider) ensureLaunchTemplate(ctx context.Context, options *amifamily.
 	// Create LT if one doesn't exist
 	if awserrors.IsNotFound(err) {
 		launchTemplate, err = p.createLaunchTemplate(ctx, options)
+		if awserrors.IsAccessDenied(err) {
+			return nil, awserrors.NewReadOnlyError("CreateLaunchTemplate", fmt.Errorf("creating launch template, %w", err))
+		}
 		if err != nil {
 			return nil, fmt.Errorf("creating launch template, %w", err)
 		}
@@ -214,7 +245,7 @@ func (p *Provider) ensureLaunchTemplate(ctx context.Context, options *amifamily.
 		}
 		launchTemplate = output.LaunchTemplates[0]
 	}
-	p.cache.SetDefault(name, launchTemplate)
+	awscache.SetDefault(p.cache, awscache.LaunchTemplatesCacheName, name, launchTemplate)
 	return launchTemplate, nil
 }
 
@@ -243,8 +274,16 @@ func (p *Provider) createLaunchTemplate(ctx context.Context, options *amifamily.
 				HttpProtocolIpv6:        options.MetadataOptions.HTTPProtocolIPv6,
 				HttpPutResponseHopLimit: options.MetadataOptions.HTTPPutResponseHopLimit,
 				HttpTokens:              options.MetadataOptions.HTTPTokens,
+				InstanceMetadataTags:    options.MetadataOptions.InstanceMetadataTags,
 			},
 			NetworkInterfaces: networkInterface,
+			CpuOptions: lo.Ternary(options.CPUOptions == nil, nil, &ec2.LaunchTemplateCpuOptionsRequest{
+				CoreCount:      lo.FromPtr(options.CPUOptions).CoreCount,
+				ThreadsPerCore: lo.FromPtr(options.CPUOptions).ThreadsPerCore,
+			}),
+			CapacityReservationSpecification: lo.Ternary(options.CapacityReservationID == "", nil, &ec2.LaunchTemplateCapacityReservationSpecificationRequest{
+				CapacityReservationTarget: &ec2.CapacityReservationTarget{CapacityReservationId: aws.String(options.CapacityReservationID)},
+			}),
 			TagSpecifications: []*ec2.LaunchTemplateTagSpecificationRequest{
 				{ResourceType: aws.String(ec2.ResourceTypeNetworkInterface), Tags: utils.MergeTags(options.Tags)},
 			},
@@ -323,7 +362,7 @@ func (p *Provider) hydrateCache(ctx context.Context) {
 		Filters: []*ec2.Filter{{Name: aws.String(fmt.Sprintf("tag:%s", karpenterManagedTagKey)), Values: []*string{aws.String(clusterName)}}},
 	}, func(output *ec2.DescribeLaunchTemplatesOutput, _ bool) bool {
 		for _, lt := range output.LaunchTemplates {
-			p.cache.SetDefault(*lt.LaunchTemplateName, lt)
+			awscache.SetDefault(p.cache, awscache.LaunchTemplatesCacheName, *lt.LaunchTemplateName, lt)
 		}
 		return true
 	}); err != nil {
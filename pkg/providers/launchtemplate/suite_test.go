@@ -47,6 +47,7 @@ import (
 	"github.com/aws/karpenter/pkg/cloudprovider"
 	"github.com/aws/karpenter/pkg/fake"
 	"github.com/aws/karpenter/pkg/providers/amifamily/bootstrap"
+	"github.com/aws/karpenter/pkg/providers/instance"
 	"github.com/aws/karpenter/pkg/providers/instancetype"
 	"github.com/aws/karpenter/pkg/test"
 	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
@@ -90,7 +91,7 @@ var _ = BeforeSuite(func() {
 
 	fakeClock = &clock.FakeClock{}
 	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, events.NewRecorder(&record.FakeRecorder{}),
-		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider)
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.WarmUp, fakeClock)
 	cluster = state.NewCluster(fakeClock, env.Client, cloudProvider)
 	prov = provisioning.NewProvisioner(env.Client, env.KubernetesInterface.CoreV1(), events.NewRecorder(&record.FakeRecorder{}), cloudProvider, cluster)
 })
@@ -132,6 +133,7 @@ var _ = BeforeEach(func() {
 
 	awsEnv.LaunchTemplateProvider.KubeDNSIP = net.ParseIP("10.0.100.10")
 	awsEnv.LaunchTemplateProvider.ClusterEndpoint = "https://test-cluster"
+	awsEnv.LaunchTemplateProvider.ClusterCIDR = lo.ToPtr("10.100.0.0/16")
 })
 
 var _ = AfterEach(func() {
@@ -233,6 +235,30 @@ var _ = Describe("LaunchTemplates", func() {
 			}
 			Expect(lts1.Equal(lts2)).To(BeTrue())
 		})
+		It("should keep every instance type that resolves to the same launch template", func() {
+			// Custom user data doesn't pass the max pods to the kubelet, so instance types with different max pods
+			// resolve to the same launch template
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyCustom
+			nodeTemplate.Spec.AMISelector = map[string]string{"*": "*"}
+			provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key:      v1.LabelInstanceTypeStable,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{"m5.large", "m5.xlarge"},
+			})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			instanceTypes := sets.NewString()
+			for _, ltConfig := range createFleetInput.LaunchTemplateConfigs {
+				for _, override := range ltConfig.Overrides {
+					instanceTypes.Insert(aws.StringValue(override.InstanceType))
+				}
+			}
+			Expect(instanceTypes.List()).To(ConsistOf("m5.large", "m5.xlarge"))
+		})
 		It("should recover from an out-of-sync launch template cache", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{MaxPods: aws.Int32(1)}
 			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
@@ -256,6 +282,50 @@ var _ = Describe("LaunchTemplates", func() {
 			Expect(awsEnv.EC2API.CreateFleetBehavior.SuccessfulCalls()).To(BeNumerically("==", 2))
 
 		})
+		It("should resolve the amis again when one is deregistered before the launch", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String(coretest.RandomName()),
+					ImageId:      aws.String("ami-123"),
+					Architecture: aws.String("x86_64"),
+					CreationDate: aws.String("2020-01-01T12:00:00Z"),
+				},
+				{
+					Name:         aws.String(coretest.RandomName()),
+					ImageId:      aws.String("ami-456"),
+					Architecture: aws.String("x86_64"),
+					CreationDate: aws.String("2021-01-01T12:00:00Z"),
+				},
+			}})
+			nodeTemplate.Spec.AMISelector = map[string]string{"*": "*"}
+			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{MaxPods: aws.Int32(1)}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+
+			// ami-456 is deregistered while it's still cached
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:         aws.String(coretest.RandomName()),
+					ImageId:      aws.String("ami-123"),
+					Architecture: aws.String("x86_64"),
+					CreationDate: aws.String("2020-01-01T12:00:00Z"),
+				},
+			}})
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Reset()
+			awsEnv.EC2API.CreateFleetBehavior.Error.Set(awserr.New("InvalidAMIID.NotFound", "The image id '[ami-456]' does not exist", nil), fake.MaxCalls(1))
+			pod = coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			// should call fleet twice. Once will fail on the deregistered AMI and the next will succeed
+			Expect(awsEnv.EC2API.CreateFleetBehavior.FailedCalls()).To(BeNumerically("==", 1))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.SuccessfulCalls()).To(BeNumerically("==", 2))
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(aws.StringValue(ltInput.LaunchTemplateData.ImageId)).To(Equal("ami-123"))
+			})
+		})
 	})
 	Context("Labels", func() {
 		It("should apply labels to the node", func() {
@@ -428,6 +498,61 @@ var _ = Describe("LaunchTemplates", func() {
 			ExpectTags(createFleetInput.TagSpecifications[2].Tags, nodeTemplate.Spec.Tags)
 			ExpectTagsNotFound(createFleetInput.TagSpecifications[0].Tags, settingsTags)
 		})
+		It("should not apply the Name tag when it's disabled", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{DisabledManagedTags: []string{"Name"}}))
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, spec := range createFleetInput.TagSpecifications {
+				Expect(lo.Map(spec.Tags, func(t *ec2.Tag, _ int) string { return aws.StringValue(t.Key) })).ToNot(ContainElement("Name"))
+				ExpectTags(spec.Tags, map[string]string{v1alpha5.ProvisionerNameLabelKey: provisioner.Name})
+			}
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(input *ec2.CreateLaunchTemplateInput) {
+				Expect(lo.Map(input.TagSpecifications[0].Tags, func(t *ec2.Tag, _ int) string { return aws.StringValue(t.Key) })).ToNot(ContainElement("Name"))
+			})
+		})
+		It("should apply a Name tag from the node template when the Name tag is disabled", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{DisabledManagedTags: []string{"Name"}}))
+			nodeTemplate.Spec.Tags = map[string]string{"Name": "myname"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, spec := range createFleetInput.TagSpecifications {
+				ExpectTags(spec.Tags, nodeTemplate.Spec.Tags)
+			}
+		})
+		It("should only apply a disabled managed-by tag to instances", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				DisabledManagedTags: []string{v1alpha5.MachineManagedByAnnotationKey},
+			}))
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ownershipTags := map[string]string{
+				v1alpha5.MachineManagedByAnnotationKey: "test-cluster",
+			}
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(*createFleetInput.TagSpecifications[0].ResourceType).To(Equal(ec2.ResourceTypeInstance))
+			ExpectTags(createFleetInput.TagSpecifications[0].Tags, ownershipTags)
+			for _, spec := range createFleetInput.TagSpecifications[1:] {
+				ExpectTagsNotFound(spec.Tags, ownershipTags)
+				ExpectTags(spec.Tags, map[string]string{
+					"kubernetes.io/cluster/test-cluster": "owned",
+					v1alpha5.ProvisionerNameLabelKey:     provisioner.Name,
+				})
+			}
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(input *ec2.CreateLaunchTemplateInput) {
+				ExpectTagsNotFound(input.TagSpecifications[0].Tags, ownershipTags)
+				ExpectTags(input.TagSpecifications[0].Tags, map[string]string{"karpenter.k8s.aws/cluster": "test-cluster"})
+				ExpectTagsNotFound(input.LaunchTemplateData.TagSpecifications[0].Tags, ownershipTags)
+			})
+		})
 	})
 	Context("Block Device Mappings", func() {
 		It("should default AL2 block device mappings", func() {
@@ -444,6 +569,137 @@ var _ = Describe("LaunchTemplates", func() {
 				Expect(ltInput.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Iops).To(BeNil())
 			})
 		})
+		It("should default the root volume of the Ubuntu minimal stream to 20Gi", func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			nodeTemplate.Spec.UbuntuStream = aws.String(v1alpha1.UbuntuStreamMinimal)
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(len(ltInput.LaunchTemplateData.BlockDeviceMappings)).To(Equal(1))
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(20)))
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeType).To(Equal("gp3"))
+			})
+		})
+		It("should default the root volume of the Ubuntu pro stream to 20Gi", func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			nodeTemplate.Spec.UbuntuStream = aws.String(v1alpha1.UbuntuStreamPro)
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(len(ltInput.LaunchTemplateData.BlockDeviceMappings)).To(Equal(1))
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(20)))
+			})
+		})
+		It("should default the root volume to the root device of each AMI", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:           aws.String(coretest.RandomName()),
+					ImageId:        aws.String("ami-123"),
+					Architecture:   aws.String("x86_64"),
+					RootDeviceName: aws.String("/dev/xvda"),
+					CreationDate:   aws.String("2022-08-15T12:00:00Z"),
+				},
+				{
+					Name:           aws.String(coretest.RandomName()),
+					ImageId:        aws.String("ami-456"),
+					Architecture:   aws.String("arm64"),
+					RootDeviceName: aws.String("/dev/sda1"),
+					CreationDate:   aws.String("2022-08-15T12:00:00Z"),
+				},
+			}})
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			nodeTemplate.Spec.AMISelector = map[string]string{"*": "*"}
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{{
+				Key:      v1.LabelArchStable,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{v1alpha5.ArchitectureAmd64, v1alpha5.ArchitectureArm64},
+			}}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pods := []*v1.Pod{
+				coretest.UnschedulablePod(coretest.PodOptions{NodeSelector: map[string]string{v1.LabelArchStable: v1alpha5.ArchitectureAmd64}}),
+				coretest.UnschedulablePod(coretest.PodOptions{NodeSelector: map[string]string{v1.LabelArchStable: v1alpha5.ArchitectureArm64}}),
+			}
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pods...)
+			for _, pod := range pods {
+				ExpectScheduled(ctx, env.Client, pod)
+			}
+			rootDeviceNames := map[string]string{}
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(len(ltInput.LaunchTemplateData.BlockDeviceMappings)).To(Equal(1))
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(20)))
+				rootDeviceNames[*ltInput.LaunchTemplateData.ImageId] = *ltInput.LaunchTemplateData.BlockDeviceMappings[0].DeviceName
+			})
+			Expect(rootDeviceNames).To(Equal(map[string]string{"ami-123": "/dev/xvda", "ami-456": "/dev/sda1"}))
+		})
+		It("should only launch the AMI of the architecture that the provisioner requires", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:           aws.String(coretest.RandomName()),
+					ImageId:        aws.String("ami-123"),
+					Architecture:   aws.String("x86_64"),
+					RootDeviceName: aws.String("/dev/xvda"),
+					CreationDate:   aws.String("2022-08-15T12:00:00Z"),
+				},
+				{
+					Name:           aws.String(coretest.RandomName()),
+					ImageId:        aws.String("ami-456"),
+					Architecture:   aws.String("arm64"),
+					RootDeviceName: aws.String("/dev/sda1"),
+					CreationDate:   aws.String("2022-08-15T12:00:00Z"),
+				},
+			}})
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			nodeTemplate.Spec.AMISelector = map[string]string{"*": "*"}
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{{
+				Key:      v1.LabelArchStable,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{v1alpha5.ArchitectureArm64},
+			}}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(*ltInput.LaunchTemplateData.ImageId).To(Equal("ami-456"))
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[0].DeviceName).To(Equal("/dev/sda1"))
+			})
+		})
+		It("should keep the Bottlerocket data volume when moving the root volume to the root device of the AMI", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					Name:           aws.String(coretest.RandomName()),
+					ImageId:        aws.String("ami-123"),
+					Architecture:   aws.String("arm64"),
+					RootDeviceName: aws.String("/dev/sda1"),
+					CreationDate:   aws.String("2022-08-15T12:00:00Z"),
+				},
+			}})
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			nodeTemplate.Spec.AMISelector = map[string]string{"*": "*"}
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{{
+				Key:      v1.LabelArchStable,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{v1alpha5.ArchitectureArm64},
+			}}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(len(ltInput.LaunchTemplateData.BlockDeviceMappings)).To(Equal(2))
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[0].DeviceName).To(Equal("/dev/sda1"))
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(4)))
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[1].DeviceName).To(Equal("/dev/xvdb"))
+			})
+		})
 		It("should use custom block device mapping", func() {
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
 			nodeTemplate.Spec.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
@@ -593,6 +849,56 @@ var _ = Describe("LaunchTemplates", func() {
 				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[0].Ebs.KmsKeyId).To(Equal("arn:aws:kms:us-west-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"))
 			})
 		})
+		Context("EBS Encryption Required", func() {
+			BeforeEach(func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{RequireEBSEncryption: lo.ToPtr(true)}))
+			})
+			It("should launch with the default block device mappings", func() {
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+			})
+			It("should not create a launch template with an unencrypted block device mapping", func() {
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+				nodeTemplate.Spec.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+					{
+						DeviceName: aws.String("/dev/xvda"),
+						EBS: &v1alpha1.BlockDevice{
+							VolumeType: aws.String("gp3"),
+							VolumeSize: lo.ToPtr(resource.MustParse("20Gi")),
+						},
+					},
+				}
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeZero())
+			})
+			It("should not create a launch template when the AMI maps an unencrypted volume", func() {
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+				nodeTemplate.Spec.AMISelector = map[string]string{"aws-ids": "ami-123"}
+				awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						Name:         aws.String(coretest.RandomName()),
+						ImageId:      aws.String("ami-123"),
+						Architecture: aws.String("x86_64"),
+						CreationDate: aws.String("2022-08-15T12:00:00Z"),
+						BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+							{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{Encrypted: aws.Bool(false)}},
+							{DeviceName: aws.String("/dev/xvdb"), Ebs: &ec2.EbsBlockDevice{Encrypted: aws.Bool(false)}},
+						},
+					},
+				}})
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeZero())
+			})
+		})
 	})
 	Context("Ephemeral Storage", func() {
 		It("should pack pods when a daemonset has an ephemeral-storage request", func() {
@@ -773,7 +1079,7 @@ var _ = Describe("LaunchTemplates", func() {
 			}))
 
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			overhead := it.Overhead.Total()
 			Expect(overhead.Memory().String()).To(Equal("993Mi"))
 		})
@@ -784,7 +1090,7 @@ var _ = Describe("LaunchTemplates", func() {
 			}))
 
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			overhead := it.Overhead.Total()
 			Expect(overhead.Memory().String()).To(Equal("993Mi"))
 		})
@@ -823,7 +1129,7 @@ var _ = Describe("LaunchTemplates", func() {
 			}))
 
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			overhead := it.Overhead.Total()
 			Expect(overhead.Memory().String()).To(Equal("993Mi"))
 		})
@@ -834,7 +1140,7 @@ var _ = Describe("LaunchTemplates", func() {
 			}))
 
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			overhead := it.Overhead.Total()
 			Expect(overhead.Memory().String()).To(Equal("1565Mi"))
 		})
@@ -893,6 +1199,53 @@ var _ = Describe("LaunchTemplates", func() {
 				}
 			})
 		})
+		It("should specify --system-reserved with the reserved resources of instance type overrides", func() {
+			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
+				SystemReserved: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")},
+			}
+			nodeTemplate.Spec.InstanceTypeOverrides = []v1alpha1.InstanceTypeOverride{{
+				InstanceTypes:  []string{"*"},
+				SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+			}}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("--system-reserved=", "cpu=500m", "memory=2Gi")
+		})
+		It("should specify --kube-reserved with the reservation of a kube reserved formula", func() {
+			nodeTemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{
+				Formula:      lo.ToPtr(v1alpha1.KubeReservedFormulaCustom),
+				CPU:          []v1alpha1.ReservedStep{{Ratio: "0.1"}},
+				MemoryPerPod: lo.ToPtr(resource.MustParse("10Mi")),
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.xlarge"}})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("--kube-reserved=", "cpu=400m", "ephemeral-storage=1Gi", "memory=580Mi")
+		})
+		It("should limit the launch template configs of a kube reserved formula that differs for every instance type", func() {
+			maxLaunchTemplateConfigs := instance.MaxLaunchTemplateConfigs
+			instance.MaxLaunchTemplateConfigs = 2
+			DeferCleanup(func() { instance.MaxLaunchTemplateConfigs = maxLaunchTemplateConfigs })
+			nodeTemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaGKE)}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">", 2))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop().LaunchTemplateConfigs).To(HaveLen(2))
+		})
+		It("should not specify --kube-reserved with the EKS formula", func() {
+			nodeTemplate.Spec.KubeReserved = &v1alpha1.KubeReserved{Formula: lo.ToPtr(v1alpha1.KubeReservedFormulaEKS)}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("--kube-reserved")
+		})
 		It("should specify --kube-reserved when overriding system reserved values", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				KubeReserved: v1.ResourceList{
@@ -1758,6 +2111,128 @@ var _ = Describe("LaunchTemplates", func() {
 				ExpectLaunchTemplatesCreatedWithUserData(fmt.Sprintf(string(content), provisioner.Name))
 			})
 		})
+		Context("Windows Container Runtime", func() {
+			BeforeEach(func() {
+				provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{{Key: v1.LabelOSStable, Operator: v1.NodeSelectorOpIn, Values: []string{string(v1.Windows)}}}
+				provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{ContainerRuntime: aws.String("dockerd")}
+			})
+			It("should bootstrap Windows2022 nodes with containerd", func() {
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+				ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+				pod := coretest.UnschedulablePod(coretest.PodOptions{
+					NodeSelector: map[string]string{
+						v1.LabelOSStable:     string(v1.Windows),
+						v1.LabelWindowsBuild: v1alpha1.Windows2022Build,
+					},
+				})
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				ExpectLaunchTemplatesCreatedWithUserDataContaining("-ContainerRuntime 'containerd'")
+				ExpectLaunchTemplatesCreatedWithUserDataNotContaining("dockerd")
+			})
+			It("should bootstrap Windows2019 nodes with the container runtime of the provisioner", func() {
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2019
+				ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+				pod := coretest.UnschedulablePod(coretest.PodOptions{
+					NodeSelector: map[string]string{
+						v1.LabelOSStable:     string(v1.Windows),
+						v1.LabelWindowsBuild: v1alpha1.Windows2019Build,
+					},
+				})
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				ExpectLaunchTemplatesCreatedWithUserDataContaining("-ContainerRuntime 'dockerd'")
+			})
+		})
+		Context("AL2023", func() {
+			BeforeEach(func() {
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2023
+			})
+			It("should bootstrap with a nodeadm NodeConfig", func() {
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				ExpectLaunchTemplatesCreatedWithUserDataContaining(
+					"Content-Type: application/node.eks.aws",
+					"apiVersion: node.eks.aws/v1alpha1",
+					"kind: NodeConfig",
+					"name: test-cluster",
+					"apiServerEndpoint: https://test-cluster",
+					"certificateAuthority: ca-bundle",
+					"cidr: 10.100.0.0/16",
+					"clusterDNS:\n      - 10.0.100.10",
+					"maxPods: ",
+					fmt.Sprintf("%s=%s", v1alpha5.ProvisionerNameLabelKey, provisioner.Name),
+				)
+				ExpectLaunchTemplatesCreatedWithUserDataNotContaining("/etc/eks/bootstrap.sh")
+			})
+			It("should set the kubelet configuration in the NodeConfig", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					EnableENILimitedPodDensity: lo.ToPtr(false),
+				}))
+				provisioner.Spec.Taints = []v1.Taint{{Key: "foo", Value: "bar", Effect: v1.TaintEffectNoSchedule}}
+				provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
+					SystemReserved: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+					EvictionHard:   map[string]string{"memory.available": "5%"},
+				}
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				pod := coretest.UnschedulablePod(coretest.PodOptions{Tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}}})
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				ExpectLaunchTemplatesCreatedWithUserDataContaining(
+					"maxPods: 110",
+					"registerWithTaints:\n      - effect: NoSchedule\n        key: foo\n        value: bar",
+					"systemReserved:\n        memory: 1Gi",
+					"evictionHard:\n        memory.available: 5%",
+				)
+			})
+			It("should merge a custom NodeConfig before Karpenter's", func() {
+				nodeTemplate.Spec.UserData = aws.String("apiVersion: node.eks.aws/v1alpha1\nkind: NodeConfig\nspec:\n  kubelet:\n    config:\n      shutdownGracePeriod: 30s\n")
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(input *ec2.CreateLaunchTemplateInput) {
+					userData, err := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					parts := strings.Split(string(userData), "Content-Type: application/node.eks.aws")
+					Expect(parts).To(HaveLen(3))
+					Expect(parts[1]).To(ContainSubstring("shutdownGracePeriod: 30s"))
+					Expect(parts[2]).To(ContainSubstring("cidr: 10.100.0.0/16"))
+				})
+			})
+			It("should merge a custom shell script", func() {
+				nodeTemplate.Spec.UserData = aws.String("#!/bin/bash\necho custom\n")
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				ExpectLaunchTemplatesCreatedWithUserDataContaining(`Content-Type: text/x-shellscript; charset="us-ascii"`, "echo custom", "kind: NodeConfig")
+			})
+			It("should fail to launch when the cluster CIDR isn't known", func() {
+				awsEnv.LaunchTemplateProvider.ClusterCIDR = nil
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeZero())
+			})
+			It("should default to a single 20Gi root volume", func() {
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(input *ec2.CreateLaunchTemplateInput) {
+					Expect(input.LaunchTemplateData.BlockDeviceMappings).To(HaveLen(1))
+					Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].DeviceName).To(Equal("/dev/xvda"))
+					Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(20)))
+					Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeType).To(Equal(ec2.VolumeTypeGp3))
+				})
+			})
+		})
 	})
 	Context("Detailed Monitoring", func() {
 		It("should default detailed monitoring to off", func() {
@@ -1783,6 +2258,320 @@ var _ = Describe("LaunchTemplates", func() {
 				Expect(aws.BoolValue(ltInput.LaunchTemplateData.Monitoring.Enabled)).To(BeTrue())
 			})
 		})
+		It("should override detailed monitoring with the provisioner annotation", func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			nodeTemplate.Spec.DetailedMonitoring = aws.Bool(true)
+			provisioner.Spec.Annotations = map[string]string{v1alpha1.AnnotationDetailedMonitoring: "false"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(aws.BoolValue(ltInput.LaunchTemplateData.Monitoring.Enabled)).To(BeFalse())
+			})
+		})
+		It("should override instance metadata tags with the provisioner annotation", func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			nodeTemplate.Spec.MetadataOptions = &v1alpha1.MetadataOptions{
+				HTTPTokens:           aws.String(ec2.LaunchTemplateHttpTokensStateRequired),
+				InstanceMetadataTags: aws.String(ec2.LaunchTemplateInstanceMetadataTagsStateDisabled),
+			}
+			provisioner.Spec.Annotations = map[string]string{v1alpha1.AnnotationInstanceMetadataTags: ec2.LaunchTemplateInstanceMetadataTagsStateEnabled}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(aws.StringValue(ltInput.LaunchTemplateData.MetadataOptions.HttpTokens)).To(Equal(ec2.LaunchTemplateHttpTokensStateRequired))
+				Expect(aws.StringValue(ltInput.LaunchTemplateData.MetadataOptions.InstanceMetadataTags)).To(Equal(ec2.LaunchTemplateInstanceMetadataTagsStateEnabled))
+			})
+		})
+		It("should not launch with an invalid provisioner annotation", func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			provisioner.Spec.Annotations = map[string]string{v1alpha1.AnnotationDetailedMonitoring: "sometimes"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectNotScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeZero())
+		})
+	})
+	Context("Capacity Type Overrides", func() {
+		BeforeEach(func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			nodeTemplate.Spec.CapacityTypeOverrides = []v1alpha1.CapacityTypeOverride{{
+				CapacityType:       v1alpha5.CapacityTypeSpot,
+				Tags:               map[string]string{"agent": "spot"},
+				UserData:           aws.String("#!/bin/bash\necho spot-agent"),
+				DetailedMonitoring: aws.Bool(true),
+			}}
+		})
+		It("should apply the override to spot instances", func() {
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{{
+				Key:      v1alpha5.LabelCapacityType,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{v1alpha5.CapacityTypeSpot},
+			}}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("echo spot-agent")
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(aws.BoolValue(ltInput.LaunchTemplateData.Monitoring.Enabled)).To(BeTrue())
+			})
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(createFleetInput.TagSpecifications[0].Tags).To(ContainElement(&ec2.Tag{Key: aws.String("agent"), Value: aws.String("spot")}))
+		})
+		It("should not apply the override to on-demand instances", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("echo spot-agent")
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(aws.BoolValue(ltInput.LaunchTemplateData.Monitoring.Enabled)).To(BeFalse())
+			})
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(createFleetInput.TagSpecifications[0].Tags).ToNot(ContainElement(&ec2.Tag{Key: aws.String("agent"), Value: aws.String("spot")}))
+		})
+	})
+	Context("CloudWatch Agent", func() {
+		It("should not install an agent by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("amazon-cloudwatch-agent", "fluent-bit")
+		})
+		It("should install the CloudWatch agent with an inline config after bootstrapping", func() {
+			nodeTemplate.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{Config: aws.String(`{"metrics":{}}`)}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(
+				"yum install -y amazon-cloudwatch-agent",
+				"cat <<'KARPENTER_EOF' > /opt/aws/amazon-cloudwatch-agent/etc/karpenter.json\n{\"metrics\":{}}\nKARPENTER_EOF",
+				"-c 'file:/opt/aws/amazon-cloudwatch-agent/etc/karpenter.json'",
+			)
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(input *ec2.CreateLaunchTemplateInput) {
+				userData, err := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(err).To(BeNil())
+				Expect(strings.Index(string(userData), "/etc/eks/bootstrap.sh")).To(BeNumerically("<", strings.Index(string(userData), "amazon-cloudwatch-agent-ctl")))
+			})
+		})
+		It("should install the CloudWatch agent with a config from SSM", func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			nodeTemplate.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{ConfigSSMParameter: aws.String("AmazonCloudWatch-linux")}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("dpkg -i -E /tmp/amazon-cloudwatch-agent.deb", "-c 'ssm:AmazonCloudWatch-linux'")
+		})
+		It("should install fluent-bit with a config from SSM", func() {
+			nodeTemplate.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{
+				Type:               aws.String(v1alpha1.CloudWatchAgentTypeFluentBit),
+				ConfigSSMParameter: aws.String("fluent-bit-config"),
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("--name 'fluent-bit-config'", "yum install -y fluent-bit-2.1.10", "systemctl enable --now fluent-bit")
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("amazon-cloudwatch-agent-ctl", "install.sh")
+		})
+		It("should quote the SSM parameter name", func() {
+			nodeTemplate.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{ConfigSSMParameter: aws.String("config'; reboot; echo '")}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`-c 'ssm:config'\''; reboot; echo '\'''`)
+		})
+		It("should install the CloudWatch agent on Windows", func() {
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{{Key: v1.LabelOSStable, Operator: v1.NodeSelectorOpIn, Values: []string{string(v1.Windows)}}}
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			nodeTemplate.Spec.CloudWatchAgent = &v1alpha1.CloudWatchAgent{ConfigSSMParameter: aws.String("AmazonCloudWatch-windows")}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{
+					v1.LabelOSStable:     string(v1.Windows),
+					v1.LabelWindowsBuild: "10.0.20348",
+				},
+			})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("amazon-cloudwatch-agent.msi", "-c 'ssm:AmazonCloudWatch-windows'\n</powershell>")
+		})
+	})
+	Context("Domain Join", func() {
+		var windowsPod *v1.Pod
+		BeforeEach(func() {
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{{Key: v1.LabelOSStable, Operator: v1.NodeSelectorOpIn, Values: []string{string(v1.Windows)}}}
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			windowsPod = coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{
+					v1.LabelOSStable:     string(v1.Windows),
+					v1.LabelWindowsBuild: "10.0.20348",
+				},
+			})
+		})
+		It("should join an AWS Directory Service directory after bootstrapping", func() {
+			nodeTemplate.Spec.DomainJoin = &v1alpha1.DomainJoin{
+				DirectoryID:    aws.String("d-1234567890"),
+				DirectoryName:  aws.String("corp.example.com"),
+				DNSIPAddresses: []string{"10.0.0.10", "10.0.1.10"},
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, windowsPod)
+			ExpectScheduled(ctx, env.Client, windowsPod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(
+				"-DocumentName 'AWS-JoinDirectoryServiceDomain' -Parameter @{'directoryId' = @('d-1234567890'); 'directoryName' = @('corp.example.com'); 'dnsIpAddresses' = @('10.0.0.10', '10.0.1.10')}",
+			)
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("RSAT-AD-PowerShell")
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(input *ec2.CreateLaunchTemplateInput) {
+				userData, err := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(err).To(BeNil())
+				Expect(strings.Index(string(userData), "Start-EKSBootstrap.ps1")).To(BeNumerically("<", strings.Index(string(userData), "Send-SSMCommand")))
+			})
+		})
+		It("should join a domain with a custom SSM document and install gMSA prerequisites", func() {
+			nodeTemplate.Spec.DomainJoin = &v1alpha1.DomainJoin{
+				SSMDocument:           aws.String("custom-domain-join"),
+				SSMDocumentParameters: map[string]string{"ouPath": "OU=Nodes,DC=corp,DC=example,DC=com"},
+				GMSA:                  aws.Bool(true),
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, windowsPod)
+			ExpectScheduled(ctx, env.Client, windowsPod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(
+				"Install-WindowsFeature -Name RSAT-AD-PowerShell",
+				"-DocumentName 'custom-domain-join' -Parameter @{'ouPath' = @('OU=Nodes,DC=corp,DC=example,DC=com')}",
+				"-ContainerRuntime 'containerd'",
+			)
+		})
+	})
+	Context("Neuron", func() {
+		It("should not load the Neuron driver by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("modprobe neuron")
+		})
+		It("should load the Neuron driver before bootstrapping", func() {
+			nodeTemplate.Spec.Neuron = &v1alpha1.Neuron{}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("inf*|trn*) ;;", "yum install -y \"kernel-devel-$(uname -r)\" \"kernel-headers-$(uname -r)\" aws-neuronx-dkms\n", "modprobe neuron")
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(input *ec2.CreateLaunchTemplateInput) {
+				userData, err := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(err).To(BeNil())
+				Expect(strings.Index(string(userData), "modprobe neuron")).To(BeNumerically("<", strings.Index(string(userData), "/etc/eks/bootstrap.sh")))
+			})
+		})
+		It("should pin the Neuron driver version", func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			nodeTemplate.Spec.Neuron = &v1alpha1.Neuron{DriverVersion: aws.String("2.14.5.0")}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("aws-neuronx-dkms-2.14.5.0", "aws-neuronx-dkms=2.14.5.0")
+		})
+	})
+	Context("CPU Options", func() {
+		BeforeEach(func() {
+			output, err := awsEnv.EC2API.DescribeInstanceTypesWithContext(ctx, &ec2.DescribeInstanceTypesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			validCores := map[string][]int64{"m5.large": {1}, "m5.xlarge": {1, 2}}
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{
+				InstanceTypes: lo.Map(output.InstanceTypes, func(info *ec2.InstanceTypeInfo, _ int) *ec2.InstanceTypeInfo {
+					cores, ok := validCores[aws.StringValue(info.InstanceType)]
+					if !ok {
+						return info
+					}
+					info = lo.ToPtr(*info)
+					info.VCpuInfo = &ec2.VCpuInfo{
+						DefaultCores:        info.VCpuInfo.DefaultCores,
+						DefaultVCpus:        info.VCpuInfo.DefaultVCpus,
+						ValidCores:          aws.Int64Slice(cores),
+						ValidThreadsPerCore: aws.Int64Slice([]int64{1, 2}),
+					}
+					return info
+				}),
+			})
+		})
+		It("should not set CPU options by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(input *ec2.CreateLaunchTemplateInput) {
+				Expect(input.LaunchTemplateData.CpuOptions).To(BeNil())
+			})
+		})
+		It("should set the core count and threads per core of the node template", func() {
+			nodeTemplate.Spec.CPUOptions = &v1alpha1.CPUOptions{CoreCount: aws.Int64(2), ThreadsPerCore: aws.Int64(1)}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "m5.xlarge"))
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			Expect(aws.Int64Value(input.LaunchTemplateData.CpuOptions.CoreCount)).To(BeNumerically("==", 2))
+			Expect(aws.Int64Value(input.LaunchTemplateData.CpuOptions.ThreadsPerCore)).To(BeNumerically("==", 1))
+		})
+		It("should create a launch template for each core count when simultaneous multithreading is disabled", func() {
+			nodeTemplate.Spec.CPUOptions = &v1alpha1.CPUOptions{ThreadsPerCore: aws.Int64(1)}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			// Small enough for the single vCPU of an m5.large to run it
+			pod := coretest.UnschedulablePod(coretest.PodOptions{ResourceRequirements: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")}}})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			var coreCounts []int64
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(input *ec2.CreateLaunchTemplateInput) {
+				Expect(aws.Int64Value(input.LaunchTemplateData.CpuOptions.ThreadsPerCore)).To(BeNumerically("==", 1))
+				coreCounts = append(coreCounts, aws.Int64Value(input.LaunchTemplateData.CpuOptions.CoreCount))
+			})
+			Expect(coreCounts).To(ConsistOf(int64(1), int64(2)))
+		})
+		It("should limit the launch template configs when simultaneous multithreading is disabled across many sizes", func() {
+			maxLaunchTemplateConfigs := instance.MaxLaunchTemplateConfigs
+			instance.MaxLaunchTemplateConfigs = 2
+			DeferCleanup(func() { instance.MaxLaunchTemplateConfigs = maxLaunchTemplateConfigs })
+			output, err := awsEnv.EC2API.DescribeInstanceTypesWithContext(ctx, &ec2.DescribeInstanceTypesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{
+				InstanceTypes: lo.Map(output.InstanceTypes, func(info *ec2.InstanceTypeInfo, _ int) *ec2.InstanceTypeInfo {
+					info = lo.ToPtr(*info)
+					info.VCpuInfo = &ec2.VCpuInfo{
+						DefaultCores:        info.VCpuInfo.DefaultCores,
+						DefaultVCpus:        info.VCpuInfo.DefaultVCpus,
+						ValidThreadsPerCore: aws.Int64Slice([]int64{1, 2}),
+					}
+					return info
+				}),
+			})
+			nodeTemplate.Spec.CPUOptions = &v1alpha1.CPUOptions{ThreadsPerCore: aws.Int64(1)}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{ResourceRequirements: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")}}})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">", 2))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop().LaunchTemplateConfigs).To(HaveLen(2))
+		})
 	})
 })
 
@@ -34,9 +34,13 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
 	"knative.dev/pkg/logging"
 
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
+
+	awscache "github.com/aws/karpenter/pkg/cache"
 )
 
 // Provider provides actual pricing data to the AWS cloud provider to allow it to make more informed decisions
@@ -158,6 +162,16 @@ func (p *Provider) SpotPrice(instanceType string, zone string) (float64, bool) {
 	return 0.0, false
 }
 
+// Price returns the last known price for the instance type, zone, and capacity type described by the well known labels
+// of a node or machine
+func (p *Provider) Price(labels map[string]string) (float64, bool) {
+	instanceType := labels[v1.LabelInstanceTypeStable]
+	if labels[v1alpha5.LabelCapacityType] == v1alpha5.CapacityTypeSpot {
+		return p.SpotPrice(instanceType, labels[v1.LabelTopologyZone])
+	}
+	return p.OnDemandPrice(instanceType)
+}
+
 func (p *Provider) UpdateOnDemandPricing(ctx context.Context) error {
 	// standard on-demand instances
 	var wg sync.WaitGroup
@@ -220,6 +234,7 @@ func (p *Provider) UpdateOnDemandPricing(ctx context.Context) error {
 			TopologyLabel:     "",
 		}).Set(price)
 	}
+	p.recordCacheSize()
 	if p.cm.HasChanged("on-demand-prices", p.onDemandPrices) {
 		logging.FromContext(ctx).With("instance-type-count", len(p.onDemandPrices)).Debugf("updated on-demand pricing")
 	}
@@ -378,6 +393,7 @@ func (p *Provider) UpdateSpotPricing(ctx context.Context) error {
 	}
 
 	p.spotUpdateTime = time.Now()
+	p.recordCacheSize()
 	if p.cm.HasChanged("spot-prices", p.spotPrices) {
 		logging.FromContext(ctx).With(
 			"instance-type-count", len(p.onDemandPrices),
@@ -394,6 +410,15 @@ func (p *Provider) LivenessProbe(_ *http.Request) error {
 	return nil
 }
 
+// recordCacheSize reports the number of on-demand and spot prices that are currently known
+func (p *Provider) recordCacheSize() {
+	size := len(p.onDemandPrices)
+	for _, zonalPrices := range p.spotPrices {
+		size += len(zonalPrices.prices)
+	}
+	awscache.RecordSize(awscache.PricingCacheName, size)
+}
+
 func populateInitialSpotPricing(pricing map[string]float64) map[string]zonal {
 	m := map[string]zonal{}
 	for it, price := range pricing {
@@ -31,6 +31,8 @@ import (
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/utils"
 )
 
 type Provider struct {
@@ -38,6 +40,8 @@ type Provider struct {
 	ec2api ec2iface.EC2API
 	cache  *cache.Cache
 	cm     *pretty.ChangeMonitor
+	// filterSets are the filters that the cached security groups were resolved from, keyed by their cache key
+	filterSets map[string][][]*ec2.Filter
 }
 
 const TTL = 5 * time.Minute
@@ -47,7 +51,8 @@ func NewProvider(ec2api ec2iface.EC2API, cache *cache.Cache) *Provider {
 		ec2api: ec2api,
 		cm:     pretty.NewChangeMonitor(),
 		// TODO: Remove cache for v1beta1, utilize resolved security groups from the AWSNodeTemplate.status
-		cache: cache,
+		cache:      cache,
+		filterSets: map[string][][]*ec2.Filter{},
 	}
 }
 
@@ -75,12 +80,42 @@ func (p *Provider) List(ctx context.Context, nodeClass *v1beta1.NodeClass) ([]*e
 	return securityGroups, nil
 }
 
+// Invalidate deletes the cached security groups that a change to the security group may affect, so that they're
+// resolved again the next time they're needed. These are the security groups that were resolved along with it, and the
+// security groups that were resolved by its name or by one of the tag keys that changed, which it may have started
+// matching.
+func (p *Provider) Invalidate(ctx context.Context, id, name string, tagKeys []string) {
+	p.Lock()
+	defer p.Unlock()
+	for key, item := range p.cache.Items() {
+		securityGroups, ok := item.Object.([]*ec2.SecurityGroup)
+		if !ok {
+			continue
+		}
+		if lo.ContainsBy(securityGroups, func(s *ec2.SecurityGroup) bool { return aws.StringValue(s.GroupId) == id }) ||
+			utils.SelectsTagKeys(p.filterSets[key], tagKeys) || selectsName(p.filterSets[key], name) {
+			logging.FromContext(ctx).With("security-group", id).Debugf("invalidating security groups in the cache because a security group changed")
+			p.cache.Delete(key)
+			delete(p.filterSets, key)
+		}
+	}
+}
+
+// selectsName returns whether any of the filter sets selects security groups by the name
+func selectsName(filterSets [][]*ec2.Filter, name string) bool {
+	return name != "" && lo.ContainsBy(filterSets, func(filters []*ec2.Filter) bool {
+		return lo.ContainsBy(filters, func(f *ec2.Filter) bool {
+			return aws.StringValue(f.Name) == "group-name" && lo.Contains(aws.StringValueSlice(f.Values), name)
+		})
+	})
+}
+
 func (p *Provider) getSecurityGroups(ctx context.Context, filterSets [][]*ec2.Filter) ([]*ec2.SecurityGroup, error) {
 	hash, err := hashstructure.Hash(filterSets, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
 	if err != nil {
 		return nil, err
 	}
-	if sg, ok := p.cache.Get(fmt.Sprint(hash)); ok {
+	if sg, ok := awscache.Get(p.cache, awscache.SecurityGroupsCacheName, fmt.Sprint(hash)); ok {
 		return sg.([]*ec2.SecurityGroup), nil
 	}
 	securityGroups := map[string]*ec2.SecurityGroup{}
@@ -90,10 +125,14 @@ func (p *Provider) getSecurityGroups(ctx context.Context, filterSets [][]*ec2.Fi
 			return nil, fmt.Errorf("describing security groups %+v, %w", filterSets, err)
 		}
 		for i := range output.SecurityGroups {
+			if utils.Excluded(output.SecurityGroups[i].Tags) {
+				continue
+			}
 			securityGroups[lo.FromPtr(output.SecurityGroups[i].GroupId)] = output.SecurityGroups[i]
 		}
 	}
-	p.cache.SetDefault(fmt.Sprint(hash), lo.Values(securityGroups))
+	awscache.SetDefault(p.cache, awscache.SecurityGroupsCacheName, fmt.Sprint(hash), lo.Values(securityGroups))
+	p.filterSets[fmt.Sprint(hash)] = filterSets
 	return lo.Values(securityGroups), nil
 }
 
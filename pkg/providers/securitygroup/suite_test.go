@@ -269,6 +269,23 @@ var _ = Describe("SecurityGroupProvider", func() {
 			},
 		}, securityGroups)
 	})
+	It("should not discover security groups tagged to be excluded", func() {
+		awsEnv.EC2API.DescribeSecurityGroupsOutput.Set(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{
+			{GroupName: aws.String("test-sgName-1"), GroupId: aws.String("test-sg-1"), Tags: []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("test-sg-1")}}},
+			{GroupName: aws.String("test-sgName-2"), GroupId: aws.String("test-sg-2"), Tags: []*ec2.Tag{
+				{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("test-sg-2")},
+				{Key: aws.String(v1beta1.TagExclude), Value: aws.String("")},
+			}},
+		}})
+		securityGroups, err := awsEnv.SecurityGroupProvider.List(ctx, nodeClass)
+		Expect(err).To(BeNil())
+		ExpectConsistsOfSecurityGroups([]*ec2.SecurityGroup{
+			{
+				GroupId:   aws.String("test-sg-1"),
+				GroupName: aws.String("test-sgName-1"),
+			},
+		}, securityGroups)
+	})
 })
 
 func ExpectConsistsOfSecurityGroups(expected, actual []*ec2.SecurityGroup) {
@@ -0,0 +1,135 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spotplacementscore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+
+	awscache "github.com/aws/karpenter/pkg/cache"
+)
+
+// Provider gets spot placement scores, which estimate how likely a spot request for a number of instances of any of a
+// set of instance types is to succeed in each availability zone of the region. Scores range from 1 to 10, where 10
+// means that the request is highly likely to succeed.
+type Provider struct {
+	sync.Mutex
+	ec2api ec2iface.EC2API
+	region string
+	// zoneNames maps availability zone ids, which scores are reported for, to the zone names used by offerings
+	zoneNames map[string]string
+	// scores holds the latest score of each zone
+	// key: <zone>, value: int64
+	scores *cache.Cache
+	// requests holds the scores of recent requests, so that identical requests share a single call
+	// key: <targetCapacity>:<instanceTypes>, value: map[string]int64
+	requests *cache.Cache
+}
+
+func NewProvider(ec2api ec2iface.EC2API, region string) *Provider {
+	return &Provider{
+		ec2api:   ec2api,
+		region:   region,
+		scores:   cache.New(awscache.SpotPlacementScoresTTL, awscache.DefaultCleanupInterval),
+		requests: cache.New(awscache.SpotPlacementScoreRequestsTTL, awscache.DefaultCleanupInterval),
+	}
+}
+
+// Get returns the spot placement score of each availability zone of the region for launching targetCapacity instances
+// of any of the instance types, keyed by zone name. Zones that EC2 didn't score aren't returned. Requests for the same
+// instance types and target capacity within the SpotPlacementScoreRequestsTTL share the scores of a single call.
+func (p *Provider) Get(ctx context.Context, instanceTypes []string, targetCapacity int64) (map[string]int64, error) {
+	instanceTypes = lo.Uniq(instanceTypes)
+	sort.Strings(instanceTypes)
+	requestKey := fmt.Sprintf("%d:%s", targetCapacity, strings.Join(instanceTypes, ","))
+	if scores, ok := awscache.Get(p.requests, awscache.SpotPlacementScoreRequestsCacheName, requestKey); ok {
+		return lo.Assign(scores.(map[string]int64)), nil
+	}
+	zoneNames, err := p.getZoneNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	input := &ec2.GetSpotPlacementScoresInput{
+		InstanceTypes:          aws.StringSlice(instanceTypes),
+		TargetCapacity:         aws.Int64(targetCapacity),
+		SingleAvailabilityZone: aws.Bool(true),
+	}
+	if p.region != "" {
+		input.RegionNames = aws.StringSlice([]string{p.region})
+	}
+	scores := map[string]int64{}
+	if err := p.ec2api.GetSpotPlacementScoresPagesWithContext(ctx, input, func(output *ec2.GetSpotPlacementScoresOutput, _ bool) bool {
+		for _, score := range output.SpotPlacementScores {
+			if zone, ok := zoneNames[aws.StringValue(score.AvailabilityZoneId)]; ok {
+				scores[zone] = aws.Int64Value(score.Score)
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("getting spot placement scores, %w", err)
+	}
+	// EC2 scores the request as a whole rather than each of its instance types, so only the score of the zone is recorded
+	for zone, score := range scores {
+		awscache.SetDefault(p.scores, awscache.SpotPlacementScoresCacheName, zone, score)
+	}
+	awscache.SetDefault(p.requests, awscache.SpotPlacementScoreRequestsCacheName, requestKey, scores)
+	return lo.Assign(scores), nil
+}
+
+// Score returns the latest spot placement score of the zone, if it was scored within the SpotPlacementScoresTTL. The
+// score is for the instance types of the latest request as a whole, so it compares zones rather than instance types.
+func (p *Provider) Score(zone string) (int64, bool) {
+	score, ok := awscache.Get(p.scores, awscache.SpotPlacementScoresCacheName, zone)
+	if !ok {
+		return 0, false
+	}
+	return score.(int64), true
+}
+
+// getZoneNames returns the names of the availability zones of the region, keyed by zone id. Zone ids never change, so
+// these are only described once.
+func (p *Provider) getZoneNames(ctx context.Context) (map[string]string, error) {
+	p.Lock()
+	defer p.Unlock()
+	if p.zoneNames != nil {
+		return p.zoneNames, nil
+	}
+	output, err := p.ec2api.DescribeAvailabilityZonesWithContext(ctx, &ec2.DescribeAvailabilityZonesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("describing availability zones, %w", err)
+	}
+	p.zoneNames = lo.SliceToMap(output.AvailabilityZones, func(zone *ec2.AvailabilityZone) (string, string) {
+		return aws.StringValue(zone.ZoneId), aws.StringValue(zone.ZoneName)
+	})
+	return p.zoneNames, nil
+}
+
+// Reset forgets the availability zones of the region, the scores of every zone and the scores of recent requests
+func (p *Provider) Reset() {
+	p.Lock()
+	defer p.Unlock()
+	p.zoneNames = nil
+	p.scores.Flush()
+	p.requests.Flush()
+}
@@ -30,6 +30,8 @@ import (
 	"knative.dev/pkg/logging"
 
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/utils"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/utils/functional"
@@ -42,6 +44,8 @@ type Provider struct {
 	cache       *cache.Cache
 	cm          *pretty.ChangeMonitor
 	inflightIPs map[string]int64
+	// filterSets are the filters that the cached subnets were resolved from, keyed by their cache key
+	filterSets map[string][][]*ec2.Filter
 }
 
 func NewProvider(ec2api ec2iface.EC2API, cache *cache.Cache) *Provider {
@@ -53,6 +57,7 @@ func NewProvider(ec2api ec2iface.EC2API, cache *cache.Cache) *Provider {
 		cache: cache,
 		// inflightIPs is used to track IPs from known launched instances
 		inflightIPs: map[string]int64{},
+		filterSets:  map[string][][]*ec2.Filter{},
 	}
 }
 
@@ -67,7 +72,7 @@ func (p *Provider) List(ctx context.Context, nodeClass *v1beta1.NodeClass) ([]*e
 	if err != nil {
 		return nil, err
 	}
-	if subnets, ok := p.cache.Get(fmt.Sprint(hash)); ok {
+	if subnets, ok := awscache.Get(p.cache, awscache.SubnetsCacheName, fmt.Sprint(hash)); ok {
 		return subnets.([]*ec2.Subnet), nil
 	}
 
@@ -83,7 +88,8 @@ func (p *Provider) List(ctx context.Context, nodeClass *v1beta1.NodeClass) ([]*e
 			delete(p.inflightIPs, lo.FromPtr(output.Subnets[i].SubnetId)) // remove any previously tracked IP addresses since we just refreshed from EC2
 		}
 	}
-	p.cache.SetDefault(fmt.Sprint(hash), lo.Values(subnets))
+	awscache.SetDefault(p.cache, awscache.SubnetsCacheName, fmt.Sprint(hash), lo.Values(subnets))
+	p.filterSets[fmt.Sprint(hash)] = filterSets
 	if p.cm.HasChanged(fmt.Sprintf("subnets/%t/%s", nodeClass.IsNodeTemplate, nodeClass.Name), subnets) {
 		logging.FromContext(ctx).
 			With("subnets", lo.Map(lo.Values(subnets), func(s *ec2.Subnet, _ int) string {
@@ -145,6 +151,33 @@ func (p *Provider) ZonalSubnetsForLaunch(ctx context.Context, nodeClass *v1beta1
 	return zonalSubnets, nil
 }
 
+// ZonalSubnetsOnOutpost returns a mapping of zone to the subnet of the Outpost with the most available IP addresses, out
+// of the NodeClass' subnets, for launching AMIs that can only be launched on the Outpost
+func (p *Provider) ZonalSubnetsOnOutpost(ctx context.Context, nodeClass *v1beta1.NodeClass, outpostARN string) (map[string]*ec2.Subnet, error) {
+	subnets, err := p.List(ctx, nodeClass)
+	if err != nil {
+		return nil, err
+	}
+	p.Lock()
+	defer p.Unlock()
+	availableIPs := func(subnet *ec2.Subnet) int64 {
+		if ips, ok := p.inflightIPs[*subnet.SubnetId]; ok {
+			return ips
+		}
+		return aws.Int64Value(subnet.AvailableIpAddressCount)
+	}
+	zonalSubnets := map[string]*ec2.Subnet{}
+	for _, subnet := range subnets {
+		if aws.StringValue(subnet.OutpostArn) != outpostARN {
+			continue
+		}
+		if current, ok := zonalSubnets[*subnet.AvailabilityZone]; !ok || availableIPs(subnet) > availableIPs(current) {
+			zonalSubnets[*subnet.AvailabilityZone] = subnet
+		}
+	}
+	return zonalSubnets, nil
+}
+
 // UpdateInflightIPs is used to refresh the in-memory IP usage by adding back unused IPs after a CreateFleet response is returned
 func (p *Provider) UpdateInflightIPs(createFleetInput *ec2.CreateFleetInput, createFleetOutput *ec2.CreateFleetOutput, instanceTypes []*cloudprovider.InstanceType,
 	subnets []*ec2.Subnet, capacityType string) {
@@ -204,6 +237,25 @@ func (p *Provider) UpdateInflightIPs(createFleetInput *ec2.CreateFleetInput, cre
 	}
 }
 
+// Invalidate deletes the cached subnets that a change to the subnet may affect, so that they're resolved again the next
+// time they're needed. These are the subnets that were resolved along with it, and the subnets that were resolved by
+// one of the tag keys that changed, which it may have started matching.
+func (p *Provider) Invalidate(ctx context.Context, id string, tagKeys []string) {
+	p.Lock()
+	defer p.Unlock()
+	for key, item := range p.cache.Items() {
+		subnets, ok := item.Object.([]*ec2.Subnet)
+		if !ok {
+			continue
+		}
+		if lo.ContainsBy(subnets, func(s *ec2.Subnet) bool { return aws.StringValue(s.SubnetId) == id }) || utils.SelectsTagKeys(p.filterSets[key], tagKeys) {
+			logging.FromContext(ctx).With("subnet", id).Debugf("invalidating subnets in the cache because a subnet changed")
+			p.cache.Delete(key)
+			delete(p.filterSets, key)
+		}
+	}
+}
+
 func (p *Provider) LivenessProbe(_ *http.Request) error {
 	p.Lock()
 	//nolint: staticcheck
@@ -220,6 +220,20 @@ var _ = Describe("SubnetProvider", func() {
 				},
 			}, subnets)
 		})
+		It("should record cache hits and misses", func() {
+			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{
+				{
+					ID: "subnet-test1",
+				},
+			}
+			hits, misses := cacheMetricValue("karpenter_cloudprovider_cache_hits_total"), cacheMetricValue("karpenter_cloudprovider_cache_misses_total")
+			_, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			_, err = awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(cacheMetricValue("karpenter_cloudprovider_cache_hits_total")).To(Equal(hits + 1))
+			Expect(cacheMetricValue("karpenter_cloudprovider_cache_misses_total")).To(Equal(misses + 1))
+		})
 	})
 	Context("CheckAnyPublicIPAssociations", func() {
 		It("should note that no subnets assign a public IPv4 address to EC2 instances on launch", func() {
@@ -258,3 +272,11 @@ func ExpectConsistsOfSubnets(expected, actual []*ec2.Subnet) {
 		Expect(ok).To(BeTrue(), `Expected subnet with {"SubnetId": %q, "AvailabilityZone": %q, "AvailableIpAddressCount": %q} to exist`, lo.FromPtr(elem.SubnetId), lo.FromPtr(elem.AvailabilityZone), lo.FromPtr(elem.AvailableIpAddressCount))
 	}
 }
+
+func cacheMetricValue(name string) float64 {
+	metric, ok := FindMetricWithLabelValues(name, map[string]string{"cache": "subnets"})
+	if !ok {
+		return 0
+	}
+	return metric.GetCounter().GetValue()
+}
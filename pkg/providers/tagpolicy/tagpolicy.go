@@ -0,0 +1,170 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tagpolicy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/organizations/organizationsiface"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+
+	awscache "github.com/aws/karpenter/pkg/cache"
+
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+)
+
+const cacheKey = "effective"
+
+// Rule is a tag of an AWS Organizations tag policy. Tags whose key matches the rule's key regardless of case must use
+// its capitalization and, if the rule has values, one of its values.
+type Rule struct {
+	Key string
+	// Values are the values that are allowed for the tag, which may contain * wildcards. Any value is allowed if empty.
+	Values []string
+}
+
+// Policy is the effective tag policy of the account
+type Policy struct {
+	Rules []Rule
+}
+
+// Violations returns a description of each of the tags that don't comply with the policy. Tags that the policy
+// doesn't mention comply, as tag policies don't require tags to be set.
+func (p *Policy) Violations(tags map[string]string) []string {
+	var violations []string
+	for _, key := range lo.Keys(tags) {
+		for _, rule := range p.Rules {
+			if !strings.EqualFold(key, rule.Key) {
+				continue
+			}
+			if key != rule.Key {
+				violations = append(violations, fmt.Sprintf("tag key %q must be capitalized as %q", key, rule.Key))
+			}
+			if len(rule.Values) > 0 && !lo.SomeBy(rule.Values, func(v string) bool { return matches(v, tags[key]) }) {
+				violations = append(violations, fmt.Sprintf("tag %s value %q is not one of %v", key, tags[key], rule.Values))
+			}
+		}
+	}
+	sort.Strings(violations)
+	return violations
+}
+
+// matches returns true if the value matches the pattern of an allowed tag value, in which * matches any characters
+func matches(pattern, value string) bool {
+	return regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$").MatchString(value)
+}
+
+// Provider retrieves the effective AWS Organizations tag policy of the account, so that the tags of a NodeClass can be
+// checked against it before instances are created with them
+type Provider struct {
+	sync.Mutex
+	organizations organizationsiface.OrganizationsAPI
+	cache         *cache.Cache
+	cm            *pretty.ChangeMonitor
+}
+
+func NewProvider(organizations organizationsiface.OrganizationsAPI, cache *cache.Cache) *Provider {
+	return &Provider{
+		organizations: organizations,
+		cache:         cache,
+		cm:            pretty.NewChangeMonitor(),
+	}
+}
+
+// Get returns the effective tag policy of the account, which has no rules if the account isn't in an organization or
+// no tag policy applies to it
+func (p *Provider) Get(ctx context.Context) (*Policy, error) {
+	p.Lock()
+	defer p.Unlock()
+	if policy, ok := awscache.Get(p.cache, awscache.TagPoliciesCacheName, cacheKey); ok {
+		return policy.(*Policy), nil
+	}
+	output, err := p.organizations.DescribeEffectivePolicyWithContext(ctx, &organizations.DescribeEffectivePolicyInput{
+		PolicyType: aws.String(organizations.EffectivePolicyTypeTagPolicy),
+	})
+	policy := &Policy{}
+	if err != nil {
+		var awsError awserr.Error
+		if !errors.As(err, &awsError) || !lo.Contains([]string{organizations.ErrCodeEffectivePolicyNotFoundException,
+			organizations.ErrCodeAWSOrganizationsNotInUseException}, awsError.Code()) {
+			return nil, fmt.Errorf("describing effective tag policy, %w", err)
+		}
+	} else if output.EffectivePolicy != nil {
+		if policy, err = parse(aws.StringValue(output.EffectivePolicy.PolicyContent)); err != nil {
+			return nil, fmt.Errorf("parsing effective tag policy, %w", err)
+		}
+	}
+	awscache.SetDefault(p.cache, awscache.TagPoliciesCacheName, cacheKey, policy)
+	if p.cm.HasChanged("tag-policy", policy) {
+		logging.FromContext(ctx).With("tags", lo.Map(policy.Rules, func(r Rule, _ int) string { return r.Key })).Debugf("discovered tag policy")
+	}
+	return policy, nil
+}
+
+// effectivePolicy is the content of an effective tag policy, e.g.
+// {"tags":{"costcenter":{"tag_key":{"@@assign":"CostCenter"},"tag_value":{"@@assign":["100","200*"]}}}}
+type effectivePolicy struct {
+	Tags map[string]struct {
+		TagKey   assignable[string]   `json:"tag_key"`
+		TagValue assignable[[]string] `json:"tag_value"`
+	} `json:"tags"`
+}
+
+// assignable is a value of a tag policy, which may be wrapped in the @@assign operator
+type assignable[T any] struct {
+	Value T
+}
+
+func (a *assignable[T]) UnmarshalJSON(raw []byte) error {
+	var operator struct {
+		Assign *T `json:"@@assign"`
+	}
+	if err := json.Unmarshal(raw, &operator); err == nil && operator.Assign != nil {
+		a.Value = *operator.Assign
+		return nil
+	}
+	return json.Unmarshal(raw, &a.Value)
+}
+
+func parse(content string) (*Policy, error) {
+	policy := &Policy{}
+	if content == "" {
+		return policy, nil
+	}
+	effective := effectivePolicy{}
+	if err := json.Unmarshal([]byte(content), &effective); err != nil {
+		return nil, err
+	}
+	for name, tag := range effective.Tags {
+		policy.Rules = append(policy.Rules, Rule{
+			Key:    lo.Ternary(tag.TagKey.Value != "", tag.TagKey.Value, name),
+			Values: tag.TagValue.Value,
+		})
+	}
+	sort.Slice(policy.Rules, func(i, j int) bool { return policy.Rules[i].Key < policy.Rules[j].Key })
+	return policy, nil
+}
@@ -0,0 +1,201 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpccni
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+	awscache "github.com/aws/karpenter/pkg/cache"
+
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+)
+
+const (
+	// Namespace and DaemonSetName identify the DaemonSet that runs the VPC CNI, whose aws-node container is configured
+	// through its environment variables
+	Namespace     = "kube-system"
+	DaemonSetName = "aws-node"
+	containerName = "aws-node"
+	// ConfigMapName identifies the ConfigMap that configures the VPC resource controller, which assigns the addresses of
+	// Windows pods
+	ConfigMapName = "amazon-vpc-cni"
+	cacheKey      = "aws-node"
+
+	// AddressesPerPrefix is the number of addresses in each /28 prefix that the VPC CNI assigns to a network interface
+	// with prefix delegation
+	AddressesPerPrefix = 16
+	// Pod density isn't limited by addresses with prefix delegation or IPv6, so it's limited like the EKS max pods
+	// calculator does instead: https://github.com/awslabs/amazon-eks-ami/blob/master/files/max-pods-calculator.sh
+	maxPodsCPUThreshold = 30
+	maxPodsFewCPUs      = 110
+	maxPodsManyCPUs     = 250
+)
+
+// Config is the configuration of the VPC CNI that determines how many pods it can assign addresses to on a node. The
+// WARM_ENI_TARGET, WARM_IP_TARGET, MINIMUM_IP_TARGET and WARM_PREFIX_TARGET variables only change how many addresses
+// the VPC CNI keeps available ahead of pods, not how many it can assign, so they don't affect pod density.
+type Config struct {
+	// PrefixDelegation assigns /28 prefixes rather than secondary addresses to network interfaces on Nitro instance
+	// types (ENABLE_PREFIX_DELEGATION)
+	PrefixDelegation bool
+	// CustomNetworking assigns pods addresses from the subnets of ENIConfigs, so the primary network interface doesn't
+	// have any addresses for pods (AWS_VPC_K8S_CNI_CUSTOM_NETWORK_CFG)
+	CustomNetworking bool
+	// IPv6 assigns pods addresses from an IPv6 prefix of the primary network interface (ENABLE_IPv6)
+	IPv6 bool
+	// WindowsPrefixDelegation assigns /28 prefixes rather than secondary addresses to the primary network interface of
+	// Windows nodes on Nitro instance types (enable-windows-prefix-delegation)
+	WindowsPrefixDelegation bool
+}
+
+// MaxPods returns the number of pods that the VPC CNI can assign addresses to on an instance of the instance type,
+// excluding the reserved network interfaces. The zero Config is the default configuration of the VPC CNI, which
+// assigns secondary addresses.
+func (c Config) MaxPods(info *ec2.InstanceTypeInfo, reservedENIs int) int64 {
+	if c.IPv6 {
+		return podsCeiling(info)
+	}
+	// VPC CNI only uses the default network interface
+	// https://github.com/aws/amazon-vpc-cni-k8s/blob/3294231c0dce52cfe473bf6c62f47956a3b333b6/scripts/gen_vpc_ip_limits.go#L162
+	networkInterfaces := aws.Int64Value(info.NetworkInfo.NetworkCards[aws.Int64Value(info.NetworkInfo.DefaultNetworkCardIndex)].MaximumNetworkInterfaces)
+	if c.CustomNetworking {
+		reservedENIs = lo.Max([]int{reservedENIs, 1})
+	}
+	usableNetworkInterfaces := lo.Max([]int64{networkInterfaces - int64(reservedENIs), 0})
+	if usableNetworkInterfaces == 0 {
+		return 0
+	}
+	// The primary address of each network interface isn't assigned to pods
+	addressesPerInterface := aws.Int64Value(info.NetworkInfo.Ipv4AddressesPerInterface) - 1
+	// Prefix delegation is only supported on Nitro instance types
+	if c.PrefixDelegation && aws.StringValue(info.Hypervisor) == ec2.InstanceTypeHypervisorNitro {
+		return lo.Min([]int64{usableNetworkInterfaces*addressesPerInterface*AddressesPerPrefix + 2, podsCeiling(info)})
+	}
+	// Pods on the host network don't use addresses from the network interfaces, e.g. aws-node and kube-proxy
+	// https://github.com/awslabs/amazon-eks-ami/blob/master/files/eni-max-pods.txt#L20
+	return usableNetworkInterfaces*addressesPerInterface + 2
+}
+
+// WindowsAddresses returns the number of addresses that the VPC resource controller can assign to Windows pods on an
+// instance of the instance type. Windows pods only get addresses from the primary network interface. The zero Config
+// is the default configuration of the VPC resource controller, which assigns secondary addresses.
+func (c Config) WindowsAddresses(info *ec2.InstanceTypeInfo) int64 {
+	// The primary address of the network interface isn't assigned to pods
+	addresses := aws.Int64Value(info.NetworkInfo.Ipv4AddressesPerInterface) - 1
+	if c.WindowsPrefixDelegation && aws.StringValue(info.Hypervisor) == ec2.InstanceTypeHypervisorNitro {
+		return addresses * AddressesPerPrefix
+	}
+	return addresses
+}
+
+// WindowsMaxPods returns the number of Windows pods that the VPC resource controller can assign addresses to on an
+// instance of the instance type, which is limited like MaxPods with prefix delegation
+func (c Config) WindowsMaxPods(info *ec2.InstanceTypeInfo) int64 {
+	return lo.Min([]int64{c.WindowsAddresses(info), lo.Ternary(c.WindowsPrefixDelegation, podsCeiling(info), math.MaxInt64)})
+}
+
+// podsCeiling is the number of pods that the EKS max pods calculator limits instance types to
+func podsCeiling(info *ec2.InstanceTypeInfo) int64 {
+	return lo.Ternary(aws.Int64Value(info.VCpuInfo.DefaultVCpus) > maxPodsCPUThreshold, int64(maxPodsManyCPUs), int64(maxPodsFewCPUs))
+}
+
+// Provider discovers the configuration of the VPC CNI from the aws-node DaemonSet, so that the pod density of instance
+// types is computed the way that the VPC CNI assigns addresses in the cluster
+type Provider struct {
+	sync.Mutex
+	kubernetesInterface kubernetes.Interface
+	cache               *cache.Cache
+	cm                  *pretty.ChangeMonitor
+}
+
+func NewProvider(kubernetesInterface kubernetes.Interface, cache *cache.Cache) *Provider {
+	return &Provider{
+		kubernetesInterface: kubernetesInterface,
+		cache:               cache,
+		cm:                  pretty.NewChangeMonitor(),
+	}
+}
+
+// Get returns the configuration of the VPC CNI, or nil if the aws.enableVPCCNIPodDensity setting is disabled or the
+// cluster has neither the aws-node DaemonSet nor the amazon-vpc-cni ConfigMap
+func (p *Provider) Get(ctx context.Context) (*Config, error) {
+	if !settings.FromContext(ctx).EnableVPCCNIPodDensity {
+		return nil, nil
+	}
+	p.Lock()
+	defer p.Unlock()
+	if config, ok := awscache.Get(p.cache, awscache.VPCCNICacheName, cacheKey); ok {
+		return config.(*Config), nil
+	}
+	daemonSet, err := p.kubernetesInterface.AppsV1().DaemonSets(Namespace).Get(ctx, DaemonSetName, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("getting daemonset %s/%s, %w", Namespace, DaemonSetName, err)
+	}
+	var config *Config
+	if err == nil {
+		container, ok := lo.Find(daemonSet.Spec.Template.Spec.Containers, func(c v1.Container) bool { return c.Name == containerName })
+		if !ok {
+			return nil, fmt.Errorf("daemonset %s/%s has no %s container", Namespace, DaemonSetName, containerName)
+		}
+		config = &Config{
+			PrefixDelegation: envBool(container, "ENABLE_PREFIX_DELEGATION"),
+			CustomNetworking: envBool(container, "AWS_VPC_K8S_CNI_CUSTOM_NETWORK_CFG"),
+			IPv6:             envBool(container, "ENABLE_IPv6"),
+		}
+	}
+	configMap, err := p.kubernetesInterface.CoreV1().ConfigMaps(Namespace).Get(ctx, ConfigMapName, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("getting configmap %s/%s, %w", Namespace, ConfigMapName, err)
+	}
+	if err == nil {
+		config = lo.Ternary(config != nil, config, &Config{})
+		config.WindowsPrefixDelegation = parseBool(configMap.Data["enable-windows-prefix-delegation"])
+	}
+	awscache.SetDefault(p.cache, awscache.VPCCNICacheName, cacheKey, config)
+	if p.cm.HasChanged("vpc-cni", config) {
+		logging.FromContext(ctx).With("config", pretty.Concise(config)).Debugf("discovered vpc cni configuration")
+	}
+	return config, nil
+}
+
+// envBool returns whether the environment variable of the container is set to true. Variables that are set from other
+// sources can't be resolved, so they're treated as unset like the VPC CNI treats values that it can't parse.
+func envBool(container v1.Container, name string) bool {
+	env, ok := lo.Find(container.Env, func(e v1.EnvVar) bool { return e.Name == name })
+	if !ok {
+		return false
+	}
+	return parseBool(env.Value)
+}
+
+func parseBool(s string) bool {
+	value, err := strconv.ParseBool(s)
+	return err == nil && value
+}
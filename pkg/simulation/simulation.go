@@ -0,0 +1,234 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulation feeds synthetic pending pod workloads through Karpenter's scheduler and the AWS instance type,
+// pricing, and instance providers running against fake AWS APIs. It reports the instance mix, cost, and AWS API
+// call volume that Karpenter would produce, for capacity planning and for regression testing changes to instance
+// selection.
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakekubernetes "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecloudprovider "github.com/aws/karpenter-core/pkg/cloudprovider"
+	corefake "github.com/aws/karpenter-core/pkg/cloudprovider/fake"
+	"github.com/aws/karpenter-core/pkg/controllers/provisioning/scheduling"
+	"github.com/aws/karpenter-core/pkg/controllers/state"
+	"github.com/aws/karpenter-core/pkg/events"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+// Options configures the fake AWS environment that workloads are simulated against
+type Options struct {
+	// KubernetesVersion is the version of the cluster that AMIs are resolved for
+	KubernetesVersion string
+	// SpotPriceRatio is the spot price of each instance type as a fraction of its on-demand price. If unset, spot
+	// instances are priced the same as on-demand instances.
+	SpotPriceRatio float64
+}
+
+// Simulator simulates provisioning for pending pods. The fake AWS APIs and providers are exposed through Environment
+// so that the instance types, capacity, and prices that are simulated against can be customized.
+type Simulator struct {
+	Environment *test.Environment
+
+	options Options
+}
+
+// Workload is a set of identical pending pods
+type Workload struct {
+	Replicas     int
+	Requests     v1.ResourceList
+	NodeSelector map[string]string
+}
+
+// Node is a node that would be launched for the simulated pods
+type Node struct {
+	InstanceType string
+	Zone         string
+	CapacityType string
+	Price        float64
+	Pods         int
+}
+
+// Report is the result of simulating provisioning for a set of pods
+type Report struct {
+	Pods            int
+	UnscheduledPods int
+	FailedLaunches  int
+	Nodes           []Node
+	APICalls        map[string]int
+	Duration        time.Duration
+}
+
+// New constructs a Simulator backed by fake AWS APIs and a fake Kubernetes API server
+func New(ctx context.Context, options Options) *Simulator {
+	kubernetesVersion := strings.SplitN(lo.Ternary(options.KubernetesVersion != "", options.KubernetesVersion, "1.27"), ".", 2)
+	kubernetesInterface := fakekubernetes.NewSimpleClientset()
+	kubernetesInterface.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{
+		Major: kubernetesVersion[0],
+		Minor: kubernetesVersion[len(kubernetesVersion)-1],
+	}
+	return &Simulator{
+		Environment: test.NewEnvironment(ctx, &coretest.Environment{KubernetesInterface: kubernetesInterface}),
+		options:     options,
+	}
+}
+
+// Pods returns the pending pods of the workloads
+func Pods(workloads ...Workload) []*v1.Pod {
+	var pods []*v1.Pod
+	for _, workload := range workloads {
+		pods = append(pods, coretest.UnschedulablePods(coretest.PodOptions{
+			ResourceRequirements: v1.ResourceRequirements{Requests: workload.Requests},
+			NodeSelector:         workload.NodeSelector,
+		}, workload.Replicas)...)
+	}
+	// The scheduler tracks its progress through the pods by UID, which the API server would otherwise assign
+	for _, pod := range pods {
+		pod.UID = uuid.NewUUID()
+	}
+	return pods
+}
+
+// Run simulates provisioning nodes from the NodePool and NodeClass for the pending pods. Customizations to the fake
+// AWS APIs and the provider caches are kept between runs, but each report only includes the API calls made for its
+// own pods.
+func (s *Simulator) Run(ctx context.Context, nodePool *corev1beta1.NodePool, nodeClass *v1beta1.NodeClass, pods []*v1.Pod) (*Report, error) {
+	start := time.Now()
+	if s.options.SpotPriceRatio > 0 {
+		if err := s.discountSpotPrices(ctx); err != nil {
+			return nil, err
+		}
+	}
+	s.Environment.EC2API.Calls.Reset()
+	instanceTypes, err := s.Environment.InstanceTypesProvider.List(ctx, nodePool.Spec.Template.Spec.KubeletConfiguration, nodeClass)
+	if err != nil {
+		return nil, fmt.Errorf("listing instance types, %w", err)
+	}
+	cloudProvider := corefake.NewCloudProvider()
+	cloudProvider.InstanceTypes = instanceTypes
+	scheduler := scheduling.NewScheduler(ctx, nil, []*scheduling.NodeClaimTemplate{scheduling.NewNodeClaimTemplate(nodePool)},
+		nil, state.NewCluster(clock.RealClock{}, nil, cloudProvider), nil, &scheduling.Topology{},
+		map[nodepoolutil.Key][]*corecloudprovider.InstanceType{{Name: nodePool.Name, IsProvisioner: nodePool.IsProvisioner}: instanceTypes}, nil,
+		events.NewRecorder(&record.FakeRecorder{}), scheduling.SchedulerOptions{})
+	results, err := scheduler.Solve(ctx, pods)
+	if err != nil {
+		return nil, fmt.Errorf("scheduling pods, %w", err)
+	}
+	report := &Report{Pods: len(pods), UnscheduledPods: len(results.PodErrors)}
+	for _, nodeClaim := range results.NewNodeClaims {
+		instance, err := s.Environment.InstanceProvider.Create(ctx, nodeClass, nodeClaim.ToNodeClaim(nodePool), nodeClaim.InstanceTypeOptions)
+		if err != nil {
+			report.FailedLaunches++
+			report.UnscheduledPods += len(nodeClaim.Pods)
+			continue
+		}
+		node := Node{InstanceType: instance.Type, Zone: instance.Zone, CapacityType: instance.CapacityType, Pods: len(nodeClaim.Pods)}
+		if instanceType, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == instance.Type }); ok {
+			if offering, ok := instanceType.Offerings.Get(instance.CapacityType, instance.Zone); ok {
+				node.Price = offering.Price
+			}
+		}
+		report.Nodes = append(report.Nodes, node)
+	}
+	report.APICalls = s.Environment.EC2API.Calls.All()
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// discountSpotPrices prices the spot offerings of every instance type in every zone at a fraction of its on-demand price
+func (s *Simulator) discountSpotPrices(ctx context.Context) error {
+	zones, err := s.Environment.EC2API.DescribeAvailabilityZonesWithContext(ctx, &ec2.DescribeAvailabilityZonesInput{})
+	if err != nil {
+		return fmt.Errorf("describing availability zones, %w", err)
+	}
+	history := &ec2.DescribeSpotPriceHistoryOutput{}
+	for _, instanceType := range s.Environment.PricingProvider.InstanceTypes() {
+		price, ok := s.Environment.PricingProvider.OnDemandPrice(instanceType)
+		if !ok {
+			continue
+		}
+		for _, zone := range zones.AvailabilityZones {
+			history.SpotPriceHistory = append(history.SpotPriceHistory, &ec2.SpotPrice{
+				AvailabilityZone: zone.ZoneName,
+				InstanceType:     aws.String(instanceType),
+				SpotPrice:        aws.String(fmt.Sprintf("%f", price*s.options.SpotPriceRatio)),
+				Timestamp:        aws.Time(time.Now()),
+			})
+		}
+	}
+	s.Environment.EC2API.DescribeSpotPriceHistoryOutput.Set(history)
+	if err := s.Environment.PricingProvider.UpdateSpotPricing(ctx); err != nil {
+		return fmt.Errorf("updating spot pricing, %w", err)
+	}
+	return nil
+}
+
+// HourlyCost is the total price per hour of the nodes that would be launched
+func (r *Report) HourlyCost() float64 {
+	return lo.SumBy(r.Nodes, func(n Node) float64 { return n.Price })
+}
+
+// InstanceMix is the number of nodes that would be launched of each instance type and capacity type
+func (r *Report) InstanceMix() map[string]int {
+	return lo.CountValuesBy(r.Nodes, func(n Node) string { return fmt.Sprintf("%s/%s", n.InstanceType, n.CapacityType) })
+}
+
+// Print writes a human readable summary of the report
+func (r *Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+	fmt.Fprintf(tw, "Pods:\t%d (%d unscheduled)\n", r.Pods, r.UnscheduledPods)
+	fmt.Fprintf(tw, "Nodes:\t%d (%d failed launches)\n", len(r.Nodes), r.FailedLaunches)
+	fmt.Fprintf(tw, "Cost:\t$%.4f/hour\n", r.HourlyCost())
+	fmt.Fprintf(tw, "Duration:\t%s\n", r.Duration)
+	fmt.Fprintf(tw, "Instance Mix:\n")
+	mix := r.InstanceMix()
+	for _, key := range sortedKeys(mix) {
+		fmt.Fprintf(tw, "  %s\t%d\n", key, mix[key])
+	}
+	fmt.Fprintf(tw, "API Calls:\n")
+	for _, key := range sortedKeys(r.APICalls) {
+		fmt.Fprintf(tw, "  %s\t%d\n", key, r.APICalls[key])
+	}
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := lo.Keys(m)
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,133 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulation_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/simulation"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var simulator *simulation.Simulator
+var nodePool *corev1beta1.NodePool
+var nodeClass *v1beta1.NodeClass
+
+func TestSimulation(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Simulation")
+}
+
+var _ = BeforeEach(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	simulator = simulation.New(ctx, simulation.Options{SpotPriceRatio: 0.3})
+	nodeClass = test.NodeClass()
+	nodePool = coretest.NodePool(corev1beta1.NodePool{
+		Spec: corev1beta1.NodePoolSpec{
+			Template: corev1beta1.NodeClaimTemplate{
+				Spec: corev1beta1.NodeClaimSpec{
+					NodeClass: &corev1beta1.NodeClassReference{Name: nodeClass.Name},
+					Requirements: []v1.NodeSelectorRequirement{
+						{
+							Key:      corev1beta1.CapacityTypeLabelKey,
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{corev1beta1.CapacityTypeSpot, corev1beta1.CapacityTypeOnDemand},
+						},
+					},
+				},
+			},
+		},
+	})
+})
+
+var _ = Describe("Simulation", func() {
+	It("should launch nodes for all of the pending pods", func() {
+		pods := simulation.Pods(
+			simulation.Workload{Replicas: 20, Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"), v1.ResourceMemory: resource.MustParse("1Gi")}},
+			simulation.Workload{Replicas: 5, Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), v1.ResourceMemory: resource.MustParse("8Gi")}},
+		)
+		report, err := simulator.Run(ctx, nodePool, nodeClass, pods)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(report.Pods).To(Equal(25))
+		Expect(report.UnscheduledPods).To(BeZero())
+		Expect(report.FailedLaunches).To(BeZero())
+		Expect(report.Nodes).ToNot(BeEmpty())
+		Expect(lo.SumBy(report.Nodes, func(n simulation.Node) int { return n.Pods })).To(Equal(25))
+		Expect(report.HourlyCost()).To(BeNumerically(">", 0))
+		Expect(lo.Sum(lo.Values(report.InstanceMix()))).To(Equal(len(report.Nodes)))
+		Expect(report.APICalls).To(HaveKeyWithValue("CreateFleet", len(report.Nodes)))
+	})
+	It("should launch discounted spot instances when the NodePool allows spot", func() {
+		report, err := simulator.Run(ctx, nodePool, nodeClass, simulation.Pods(simulation.Workload{Replicas: 3, Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Nodes).ToNot(BeEmpty())
+		for _, node := range report.Nodes {
+			Expect(node.CapacityType).To(Equal(corev1beta1.CapacityTypeSpot))
+		}
+	})
+	It("should launch on-demand instances when the NodePool only allows on-demand", func() {
+		nodePool.Spec.Template.Spec.Requirements = []v1.NodeSelectorRequirement{
+			{Key: corev1beta1.CapacityTypeLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{corev1beta1.CapacityTypeOnDemand}},
+		}
+		report, err := simulation.New(ctx, simulation.Options{}).Run(ctx, nodePool, nodeClass, simulation.Pods(simulation.Workload{Replicas: 3, Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Nodes).ToNot(BeEmpty())
+		for _, node := range report.Nodes {
+			Expect(node.CapacityType).To(Equal(corev1beta1.CapacityTypeOnDemand))
+		}
+	})
+	It("should report pods that can't be scheduled", func() {
+		report, err := simulator.Run(ctx, nodePool, nodeClass, simulation.Pods(simulation.Workload{Replicas: 2, Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1000")}}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.UnscheduledPods).To(Equal(2))
+		Expect(report.Nodes).To(BeEmpty())
+	})
+	It("should report failed launches when instances can't be created", func() {
+		simulator.Environment.EC2API.CreateFleetBehavior.Error.Set(fmt.Errorf("insufficient capacity"), fake.MaxCalls(0))
+		report, err := simulator.Run(ctx, nodePool, nodeClass, simulation.Pods(simulation.Workload{Replicas: 3, Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.FailedLaunches).To(BeNumerically(">", 0))
+		Expect(report.UnscheduledPods).To(Equal(3))
+		Expect(report.Nodes).To(BeEmpty())
+	})
+	It("should only report the API calls made for each run", func() {
+		pods := simulation.Pods(simulation.Workload{Replicas: 3, Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}})
+		first, err := simulator.Run(ctx, nodePool, nodeClass, pods)
+		Expect(err).ToNot(HaveOccurred())
+		second, err := simulator.Run(ctx, nodePool, nodeClass, pods)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(second.APICalls).To(HaveKeyWithValue("CreateFleet", len(second.Nodes)))
+		Expect(lo.Sum(lo.Values(second.APICalls))).To(BeNumerically("<=", lo.Sum(lo.Values(first.APICalls))))
+	})
+})
@@ -17,20 +17,28 @@ package test
 import (
 	"context"
 	"net"
+	"time"
 
+	clocktesting "k8s.io/utils/clock/testing"
 	"knative.dev/pkg/ptr"
 
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/patrickmn/go-cache"
 
 	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/fake"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/capacityreservation"
+	"github.com/aws/karpenter/pkg/providers/computeoptimizer"
 	"github.com/aws/karpenter/pkg/providers/instance"
 	"github.com/aws/karpenter/pkg/providers/instancetype"
 	"github.com/aws/karpenter/pkg/providers/launchtemplate"
 	"github.com/aws/karpenter/pkg/providers/pricing"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
+	"github.com/aws/karpenter/pkg/providers/spotplacementscore"
 	"github.com/aws/karpenter/pkg/providers/subnet"
+	"github.com/aws/karpenter/pkg/providers/tagpolicy"
+	"github.com/aws/karpenter/pkg/providers/vpccni"
 
 	coretest "github.com/aws/karpenter-core/pkg/test"
 
@@ -38,53 +46,95 @@ import (
 )
 
 type Environment struct {
+	// Clock is the clock of the providers that depend on the time, which is reset to the current time
+	Clock *clocktesting.FakeClock
+
 	// API
-	EC2API     *fake.EC2API
-	SSMAPI     *fake.SSMAPI
-	PricingAPI *fake.PricingAPI
+	EC2API              *fake.EC2API
+	AssumedRoleEC2API   *fake.EC2API
+	SSMAPI              *fake.SSMAPI
+	ImageBuilderAPI     *fake.ImageBuilderAPI
+	PricingAPI          *fake.PricingAPI
+	ComputeOptimizerAPI *fake.ComputeOptimizerAPI
+	OrganizationsAPI    *fake.OrganizationsAPI
 
 	// Cache
 	EC2Cache                  *cache.Cache
 	KubernetesVersionCache    *cache.Cache
 	InstanceTypeCache         *cache.Cache
 	UnavailableOfferingsCache *awscache.UnavailableOfferings
+	ReadOnlyCache             *awscache.ReadOnly
+	SpotInterruptionsCache    *awscache.SpotInterruptions
+	SpotLaunchFailuresCache   *awscache.SpotLaunchFailures
+	WarmUp                    *awscache.WarmUp
+	BootstrapFailuresCache    *awscache.BootstrapFailures
 	LaunchTemplateCache       *cache.Cache
 	SubnetCache               *cache.Cache
 	SecurityGroupCache        *cache.Cache
+	CapacityReservationCache  *cache.Cache
+	TagPolicyCache            *cache.Cache
+	VPCCNICache               *cache.Cache
 
 	// Providers
-	InstanceTypesProvider  *instancetype.Provider
-	InstanceProvider       *instance.Provider
-	SubnetProvider         *subnet.Provider
-	SecurityGroupProvider  *securitygroup.Provider
-	PricingProvider        *pricing.Provider
-	AMIProvider            *amifamily.Provider
-	AMIResolver            *amifamily.Resolver
-	LaunchTemplateProvider *launchtemplate.Provider
+	InstanceTypesProvider       *instancetype.Provider
+	InstanceProvider            *instance.Provider
+	SubnetProvider              *subnet.Provider
+	SecurityGroupProvider       *securitygroup.Provider
+	CapacityReservationProvider *capacityreservation.Provider
+	PricingProvider             *pricing.Provider
+	AMIProvider                 *amifamily.Provider
+	AMIResolver                 *amifamily.Resolver
+	LaunchTemplateProvider      *launchtemplate.Provider
+	ComputeOptimizerProvider    *computeoptimizer.Provider
+	SpotPlacementScoreProvider  *spotplacementscore.Provider
+	TagPolicyProvider           *tagpolicy.Provider
+	VPCCNIProvider              *vpccni.Provider
 }
 
 func NewEnvironment(ctx context.Context, env *coretest.Environment) *Environment {
+	clk := clocktesting.NewFakeClock(time.Now())
+
 	// API
 	ec2api := &fake.EC2API{}
+	// The images of AMI selector terms that set a role are described with their own API, whatever the role
+	assumedRoleEC2API := &fake.EC2API{}
 	ssmapi := &fake.SSMAPI{}
+	imageBuilderAPI := &fake.ImageBuilderAPI{}
 
 	// cache
 	ec2Cache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	kubernetesVersionCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	instanceTypeCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	unavailableOfferingsCache := awscache.NewUnavailableOfferings()
+	readOnlyCache := awscache.NewReadOnly()
+	spotInterruptionsCache := awscache.NewSpotInterruptions()
+	spotLaunchFailuresCache := awscache.NewSpotLaunchFailures(clk)
+	// Tests provision immediately rather than waiting on the cache warm-up
+	warmUp := awscache.NewWarmUp()
+	warmUp.MarkDone()
+	bootstrapFailuresCache := awscache.NewBootstrapFailures()
 	launchTemplateCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	subnetCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	securityGroupCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
+	capacityReservationCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	fakePricingAPI := &fake.PricingAPI{}
+	computeOptimizerAPI := &fake.ComputeOptimizerAPI{}
+	organizationsAPI := &fake.OrganizationsAPI{}
+	tagPolicyCache := cache.New(awscache.TagPolicyTTL, awscache.DefaultCleanupInterval)
+	vpcCNICache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 
 	// Providers
 	pricingProvider := pricing.NewProvider(ctx, fakePricingAPI, ec2api, "")
+	computeOptimizerProvider := computeoptimizer.NewProvider(computeOptimizerAPI)
 	subnetProvider := subnet.NewProvider(ec2api, subnetCache)
 	securityGroupProvider := securitygroup.NewProvider(ec2api, securityGroupCache)
-	amiProvider := amifamily.NewProvider(env.Client, env.KubernetesInterface, ssmapi, ec2api, ec2Cache, kubernetesVersionCache)
+	capacityReservationProvider := capacityreservation.NewProvider(ec2api, capacityReservationCache)
+	amiProvider := amifamily.NewProvider(ctx, clk, "us-west-2", env.Client, env.KubernetesInterface, ssmapi, imageBuilderAPI, ec2api,
+		func(string) ec2iface.EC2API { return assumedRoleEC2API }, ec2Cache, kubernetesVersionCache)
 	amiResolver := amifamily.New(amiProvider)
-	instanceTypesProvider := instancetype.NewProvider("", instanceTypeCache, ec2api, subnetProvider, unavailableOfferingsCache, pricingProvider)
+	spotPlacementScoreProvider := spotplacementscore.NewProvider(ec2api, "")
+	vpcCNIProvider := vpccni.NewProvider(env.KubernetesInterface, vpcCNICache)
+	instanceTypesProvider := instancetype.NewProvider("", instanceTypeCache, ec2api, subnetProvider, unavailableOfferingsCache, pricingProvider, computeOptimizerProvider, capacityReservationProvider, bootstrapFailuresCache, spotPlacementScoreProvider, vpcCNIProvider)
 	launchTemplateProvider :=
 		launchtemplate.NewProvider(
 			ctx,
@@ -97,21 +147,35 @@ func NewEnvironment(ctx context.Context, env *coretest.Environment) *Environment
 			make(chan struct{}),
 			net.ParseIP("10.0.100.10"),
 			"https://test-cluster",
+			ptr.String("10.100.0.0/16"),
 		)
 	instanceProvider :=
 		instance.NewProvider(ctx,
 			"",
 			ec2api,
 			unavailableOfferingsCache,
+			readOnlyCache,
+			spotInterruptionsCache,
+			spotLaunchFailuresCache,
+			bootstrapFailuresCache,
 			instanceTypesProvider,
 			subnetProvider,
 			launchTemplateProvider,
+			amiProvider,
+			capacityReservationProvider,
 		)
 
 	return &Environment{
-		EC2API:     ec2api,
-		SSMAPI:     ssmapi,
-		PricingAPI: fakePricingAPI,
+		Clock: clk,
+
+		EC2API:            ec2api,
+		AssumedRoleEC2API: assumedRoleEC2API,
+		SSMAPI:            ssmapi,
+		ImageBuilderAPI:   imageBuilderAPI,
+		PricingAPI:        fakePricingAPI,
+
+		ComputeOptimizerAPI: computeOptimizerAPI,
+		OrganizationsAPI:    organizationsAPI,
 
 		EC2Cache:                  ec2Cache,
 		KubernetesVersionCache:    kubernetesVersionCache,
@@ -119,32 +183,60 @@ func NewEnvironment(ctx context.Context, env *coretest.Environment) *Environment
 		LaunchTemplateCache:       launchTemplateCache,
 		SubnetCache:               subnetCache,
 		SecurityGroupCache:        securityGroupCache,
+		CapacityReservationCache:  capacityReservationCache,
+		TagPolicyCache:            tagPolicyCache,
+		VPCCNICache:               vpcCNICache,
 		UnavailableOfferingsCache: unavailableOfferingsCache,
-
-		InstanceTypesProvider:  instanceTypesProvider,
-		InstanceProvider:       instanceProvider,
-		SubnetProvider:         subnetProvider,
-		SecurityGroupProvider:  securityGroupProvider,
-		PricingProvider:        pricingProvider,
-		AMIProvider:            amiProvider,
-		AMIResolver:            amiResolver,
-		LaunchTemplateProvider: launchTemplateProvider,
+		ReadOnlyCache:             readOnlyCache,
+		SpotInterruptionsCache:    spotInterruptionsCache,
+		SpotLaunchFailuresCache:   spotLaunchFailuresCache,
+		WarmUp:                    warmUp,
+		BootstrapFailuresCache:    bootstrapFailuresCache,
+
+		InstanceTypesProvider:       instanceTypesProvider,
+		InstanceProvider:            instanceProvider,
+		SubnetProvider:              subnetProvider,
+		SecurityGroupProvider:       securityGroupProvider,
+		CapacityReservationProvider: capacityReservationProvider,
+		PricingProvider:             pricingProvider,
+		AMIProvider:                 amiProvider,
+		AMIResolver:                 amiResolver,
+		LaunchTemplateProvider:      launchTemplateProvider,
+		ComputeOptimizerProvider:    computeOptimizerProvider,
+		SpotPlacementScoreProvider:  spotPlacementScoreProvider,
+		TagPolicyProvider:           tagpolicy.NewProvider(organizationsAPI, tagPolicyCache),
+		VPCCNIProvider:              vpcCNIProvider,
 	}
 }
 
 func (env *Environment) Reset() {
+	env.Clock.SetTime(time.Now())
 	env.EC2API.Reset()
+	env.AssumedRoleEC2API.Reset()
 	env.SSMAPI.Reset()
+	env.ImageBuilderAPI.Reset()
 	env.PricingAPI.Reset()
 	env.PricingProvider.Reset()
+	env.ComputeOptimizerAPI.Reset()
+	env.OrganizationsAPI.Reset()
+	env.ComputeOptimizerProvider.Reset()
+	env.SpotPlacementScoreProvider.Reset()
+	env.AMIProvider.Reset()
 
 	env.EC2Cache.Flush()
 	env.KubernetesVersionCache.Flush()
 	env.InstanceTypeCache.Flush()
 	env.UnavailableOfferingsCache.Flush()
+	env.ReadOnlyCache.Flush()
+	env.SpotInterruptionsCache.Flush()
+	env.SpotLaunchFailuresCache.Flush()
+	env.BootstrapFailuresCache.Flush()
 	env.LaunchTemplateCache.Flush()
 	env.SubnetCache.Flush()
 	env.SecurityGroupCache.Flush()
+	env.CapacityReservationCache.Flush()
+	env.TagPolicyCache.Flush()
+	env.VPCCNICache.Flush()
 
 	mfs, err := crmetrics.Registry.Gather()
 	if err != nil {
@@ -54,6 +54,7 @@ func ExpectMetadataOptionsEqual(mo1 *v1alpha1.MetadataOptions, mo2 *v1beta1.Meta
 		Expect(lo.FromPtr(mo1.HTTPProtocolIPv6)).To(Equal(lo.FromPtr(mo2.HTTPProtocolIPv6)))
 		Expect(lo.FromPtr(mo1.HTTPPutResponseHopLimit)).To(Equal(lo.FromPtr(mo2.HTTPPutResponseHopLimit)))
 		Expect(lo.FromPtr(mo1.HTTPTokens)).To(Equal(lo.FromPtr(mo2.HTTPTokens)))
+		Expect(lo.FromPtr(mo1.InstanceMetadataTags)).To(Equal(lo.FromPtr(mo2.InstanceMetadataTags)))
 	}
 }
 
@@ -83,6 +84,17 @@ func ExpectAMIStatusEqual(amis1 []v1alpha1.AMI, amis2 []v1beta1.AMI) {
 	for i := range amis1 {
 		Expect(amis1[i].ID).To(Equal(amis2[i].ID))
 		Expect(amis1[i].Name).To(Equal(amis2[i].Name))
+		Expect(amis1[i].CreationDate).To(Equal(amis2[i].CreationDate))
 		Expect(amis1[i].Requirements).To(ConsistOf(lo.Map(amis2[i].Requirements, func(r v1.NodeSelectorRequirement, _ int) interface{} { return BeEquivalentTo(r) })...))
 	}
 }
+
+func ExpectExcludedInstanceTypesStatusEqual(excluded1 []v1alpha1.ExcludedInstanceType, excluded2 []v1beta1.ExcludedInstanceType) {
+	// Expect that all ExcludedInstanceType Status entries are present and the same
+	Expect(excluded1).To(HaveLen(len(excluded2)))
+	for i := range excluded1 {
+		Expect(excluded1[i].Name).To(Equal(excluded2[i].Name))
+		Expect(excluded1[i].ExcludedUntil).To(Equal(excluded2[i].ExcludedUntil))
+		Expect(excluded1[i].Reason).To(Equal(excluded2[i].Reason))
+	}
+}
@@ -16,6 +16,7 @@ package test
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/imdario/mergo"
 	"github.com/samber/lo"
@@ -24,16 +25,45 @@ import (
 )
 
 type SettingOptions struct {
-	ClusterName                *string
-	ClusterEndpoint            *string
-	DefaultInstanceProfile     *string
-	EnablePodENI               *bool
-	EnableENILimitedPodDensity *bool
-	IsolatedVPC                *bool
-	VMMemoryOverheadPercent    *float64
-	InterruptionQueueName      *string
-	Tags                       map[string]string
-	ReservedENIs               *int
+	ClusterName                      *string
+	ClusterEndpoint                  *string
+	DefaultInstanceProfile           *string
+	EnablePodENI                     *bool
+	EnableENILimitedPodDensity       *bool
+	IsolatedVPC                      *bool
+	VMMemoryOverheadPercent          *float64
+	VMMemoryOverheadByInstanceFamily map[string]float64
+	VMMemoryOverheadByAMIFamily      map[string]float64
+	InterruptionQueueName            *string
+	Tags                             map[string]string
+	ReservedENIs                     *int
+	EnableComputeOptimizer           *bool
+	ComputeOptimizerPriceBias        *float64
+	EnforceMetadataOptions           *bool
+	RequireEBSEncryption             *bool
+	SpotMinPools                     *int
+	LifecycleWebhookURL              *string
+	LifecycleEventBusName            *string
+	SubnetRebalancingThreshold       *float64
+	TerminationRecordTTL             *time.Duration
+	SpotPlacementScoreTargetCapacity *int
+	AMISelectorMaxImages             *int
+	DeniedAMIIDs                     []string
+	DeniedAMINames                   []string
+	DeniedAMIOwners                  []string
+	ProvisioningTriggerQueueName     *string
+	Airgapped                        *bool
+	NodeTerminationHandlerParity     *bool
+	ValidateTagPolicies              *bool
+	VerifySSMAgentRegistration       *bool
+	DisabledManagedTags              []string
+	ExcludedInstanceClasses          []string
+	OfferingScorePriceTolerance      *float64
+	UnavailableOfferingsTTL          *time.Duration
+	UnavailableOfferingsMaxTTL       *time.Duration
+	EnableICECacheEndpoint           *bool
+	EnableVPCCNIPodDensity           *bool
+	OfferingsRefreshInterval         *time.Duration
 }
 
 func Settings(overrides ...SettingOptions) *awssettings.Settings {
@@ -44,15 +74,44 @@ func Settings(overrides ...SettingOptions) *awssettings.Settings {
 		}
 	}
 	return &awssettings.Settings{
-		ClusterName:                lo.FromPtrOr(options.ClusterName, "test-cluster"),
-		ClusterEndpoint:            lo.FromPtrOr(options.ClusterEndpoint, "https://test-cluster"),
-		DefaultInstanceProfile:     lo.FromPtrOr(options.DefaultInstanceProfile, "test-instance-profile"),
-		EnablePodENI:               lo.FromPtrOr(options.EnablePodENI, true),
-		EnableENILimitedPodDensity: lo.FromPtrOr(options.EnableENILimitedPodDensity, true),
-		IsolatedVPC:                lo.FromPtrOr(options.IsolatedVPC, false),
-		VMMemoryOverheadPercent:    lo.FromPtrOr(options.VMMemoryOverheadPercent, 0.075),
-		InterruptionQueueName:      lo.FromPtrOr(options.InterruptionQueueName, ""),
-		Tags:                       options.Tags,
-		ReservedENIs:               lo.FromPtrOr(options.ReservedENIs, 0),
+		ClusterName:                      lo.FromPtrOr(options.ClusterName, "test-cluster"),
+		ClusterEndpoint:                  lo.FromPtrOr(options.ClusterEndpoint, "https://test-cluster"),
+		DefaultInstanceProfile:           lo.FromPtrOr(options.DefaultInstanceProfile, "test-instance-profile"),
+		EnablePodENI:                     lo.FromPtrOr(options.EnablePodENI, true),
+		EnableENILimitedPodDensity:       lo.FromPtrOr(options.EnableENILimitedPodDensity, true),
+		IsolatedVPC:                      lo.FromPtrOr(options.IsolatedVPC, false),
+		VMMemoryOverheadPercent:          lo.FromPtrOr(options.VMMemoryOverheadPercent, 0.075),
+		VMMemoryOverheadByInstanceFamily: options.VMMemoryOverheadByInstanceFamily,
+		VMMemoryOverheadByAMIFamily:      options.VMMemoryOverheadByAMIFamily,
+		InterruptionQueueName:            lo.FromPtrOr(options.InterruptionQueueName, ""),
+		Tags:                             options.Tags,
+		ReservedENIs:                     lo.FromPtrOr(options.ReservedENIs, 0),
+		EnableComputeOptimizer:           lo.FromPtrOr(options.EnableComputeOptimizer, false),
+		ComputeOptimizerPriceBias:        lo.FromPtrOr(options.ComputeOptimizerPriceBias, 0),
+		EnforceMetadataOptions:           lo.FromPtrOr(options.EnforceMetadataOptions, false),
+		RequireEBSEncryption:             lo.FromPtrOr(options.RequireEBSEncryption, false),
+		SpotMinPools:                     lo.FromPtrOr(options.SpotMinPools, 0),
+		LifecycleWebhookURL:              lo.FromPtrOr(options.LifecycleWebhookURL, ""),
+		LifecycleEventBusName:            lo.FromPtrOr(options.LifecycleEventBusName, ""),
+		SubnetRebalancingThreshold:       lo.FromPtrOr(options.SubnetRebalancingThreshold, 0),
+		TerminationRecordTTL:             lo.FromPtrOr(options.TerminationRecordTTL, 0),
+		SpotPlacementScoreTargetCapacity: lo.FromPtrOr(options.SpotPlacementScoreTargetCapacity, 0),
+		AMISelectorMaxImages:             lo.FromPtrOr(options.AMISelectorMaxImages, 10000),
+		DeniedAMIIDs:                     options.DeniedAMIIDs,
+		DeniedAMINames:                   options.DeniedAMINames,
+		DeniedAMIOwners:                  options.DeniedAMIOwners,
+		ProvisioningTriggerQueueName:     lo.FromPtrOr(options.ProvisioningTriggerQueueName, ""),
+		Airgapped:                        lo.FromPtrOr(options.Airgapped, false),
+		NodeTerminationHandlerParity:     lo.FromPtrOr(options.NodeTerminationHandlerParity, false),
+		ValidateTagPolicies:              lo.FromPtrOr(options.ValidateTagPolicies, false),
+		VerifySSMAgentRegistration:       lo.FromPtrOr(options.VerifySSMAgentRegistration, false),
+		DisabledManagedTags:              options.DisabledManagedTags,
+		ExcludedInstanceClasses:          options.ExcludedInstanceClasses,
+		OfferingScorePriceTolerance:      lo.FromPtrOr(options.OfferingScorePriceTolerance, 0),
+		UnavailableOfferingsTTL:          lo.FromPtrOr(options.UnavailableOfferingsTTL, 3*time.Minute),
+		UnavailableOfferingsMaxTTL:       lo.FromPtrOr(options.UnavailableOfferingsMaxTTL, 0),
+		EnableICECacheEndpoint:           lo.FromPtrOr(options.EnableICECacheEndpoint, false),
+		EnableVPCCNIPodDensity:           lo.FromPtrOr(options.EnableVPCCNIPodDensity, false),
+		OfferingsRefreshInterval:         lo.FromPtrOr(options.OfferingsRefreshInterval, 5*time.Minute),
 	}
 }
@@ -0,0 +1,241 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apirecord
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"knative.dev/pkg/logging"
+)
+
+// redacted replaces the values of fields that may contain secrets
+const redacted = "REDACTED"
+
+var (
+	// sensitiveFields are removed from recordings since they may contain credentials or secrets
+	sensitiveFields = map[string]struct{}{
+		"AccessKeyId":     {},
+		"SecretAccessKey": {},
+		"SessionToken":    {},
+		"Token":           {},
+		"UserData":        {},
+	}
+	// accountIDPattern matches AWS account IDs (e.g. in ARNs and owner IDs) so that they can be anonymized
+	accountIDPattern = regexp.MustCompile(`\b\d{12}\b`)
+)
+
+// Entry is a single recorded AWS API call
+type Entry struct {
+	Service   string          `json:"service"`
+	Operation string          `json:"operation"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     *Error          `json:"error,omitempty"`
+}
+
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Record appends every AWS API call made by clients created from the session to the file at path, one JSON entry per
+// line. Credentials, user data, and account IDs are scrubbed from the recording so that it can be shared. The file is
+// closed when the context is done, and calls made after that aren't recorded.
+func Record(ctx context.Context, sess *session.Session, path string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("opening api recording, %w", err)
+	}
+	var mu sync.Mutex
+	closed := false
+	encoder := json.NewEncoder(file)
+	sess.Handlers.Complete.PushBack(func(r *request.Request) {
+		key := entryKey(r.ClientInfo.ServiceName, operationName(r))
+		entry, err := newEntry(r)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("recording %s call, %s", key, err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		if err := encoder.Encode(entry); err != nil {
+			logging.FromContext(ctx).Errorf("recording %s call, %s", key, err)
+		}
+	})
+	go func() {
+		<-ctx.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		closed = true
+		if err := file.Close(); err != nil {
+			logging.FromContext(ctx).Errorf("closing api recording, %s", err)
+		}
+	}()
+	return nil
+}
+
+// Replay serves the AWS API calls made by clients created from the session from the recording at path instead of
+// calling AWS. Calls to each operation are answered with the recorded calls to the same operation, in the order that
+// they were recorded. Calls that weren't recorded fail.
+func Replay(sess *session.Session, path string) error {
+	entries, err := readEntries(path)
+	if err != nil {
+		return err
+	}
+	var mu sync.Mutex
+	// Service clients add their own handlers when they are created, so the handlers that sign, send, and unmarshal the
+	// request are replaced on each request while it's being built
+	sess.Handlers.Build.PushBack(func(r *request.Request) {
+		r.Handlers.Sign.Clear()
+		r.Handlers.Send.Clear()
+		r.Handlers.UnmarshalMeta.Clear()
+		r.Handlers.ValidateResponse.Clear()
+		r.Handlers.UnmarshalError.Clear()
+		r.Handlers.Unmarshal.Clear()
+		r.Handlers.Send.PushBack(func(r *request.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			key := entryKey(r.ClientInfo.ServiceName, operationName(r))
+			r.HTTPResponse = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}
+			r.Retryable = aws.Bool(false)
+			if len(entries[key]) == 0 {
+				r.Error = awserr.New("NoRecordedResponse", fmt.Sprintf("no recorded response for %s", key), nil)
+				return
+			}
+			entry := entries[key][0]
+			entries[key] = entries[key][1:]
+			if entry.Error != nil {
+				r.Error = awserr.New(entry.Error.Code, entry.Error.Message, nil)
+				return
+			}
+			if r.DataFilled() && len(entry.Output) > 0 {
+				if err := json.Unmarshal(entry.Output, r.Data); err != nil {
+					r.Error = awserr.New(request.ErrCodeSerialization, "unmarshaling recorded response", err)
+				}
+			}
+		})
+	})
+	return nil
+}
+
+func newEntry(r *request.Request) (*Entry, error) {
+	entry := &Entry{Service: r.ClientInfo.ServiceName, Operation: operationName(r)}
+	var err error
+	if entry.Input, err = scrub(r.Params); err != nil {
+		return nil, err
+	}
+	if r.Error != nil {
+		entry.Error = &Error{Code: "Unknown", Message: scrubString(r.Error.Error())}
+		var aerr awserr.Error
+		if errors.As(r.Error, &aerr) {
+			entry.Error = &Error{Code: aerr.Code(), Message: scrubString(aerr.Message())}
+		}
+		return entry, nil
+	}
+	if entry.Output, err = scrub(r.Data); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func readEntries(path string) (map[string][]*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening api recording, %w", err)
+	}
+	defer file.Close()
+	entries := map[string][]*Entry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("parsing api recording, %w", err)
+		}
+		key := entryKey(entry.Service, entry.Operation)
+		entries[key] = append(entries[key], entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading api recording, %w", err)
+	}
+	return entries, nil
+}
+
+// scrub serializes the value, redacting sensitive fields and anonymizing account IDs
+func scrub(v interface{}) (json.RawMessage, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(scrubValue(generic))
+}
+
+func scrubValue(v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		for k, val := range typed {
+			if _, ok := sensitiveFields[k]; ok && val != nil {
+				typed[k] = redacted
+				continue
+			}
+			typed[k] = scrubValue(val)
+		}
+		return typed
+	case []interface{}:
+		for i := range typed {
+			typed[i] = scrubValue(typed[i])
+		}
+		return typed
+	case string:
+		return scrubString(typed)
+	default:
+		return v
+	}
+}
+
+// scrubString anonymizes the account IDs in the string, including those of ARNs
+func scrubString(s string) string {
+	return accountIDPattern.ReplaceAllString(s, "000000000000")
+}
+
+func operationName(r *request.Request) string {
+	if r.Operation == nil {
+		return ""
+	}
+	return r.Operation.Name
+}
+
+func entryKey(service, operation string) string {
+	return fmt.Sprintf("%s/%s", service, operation)
+}
@@ -0,0 +1,163 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apirecord_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/utils/apirecord"
+)
+
+var dir string
+
+func TestAPIs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "APIRecord")
+}
+
+var _ = BeforeEach(func() {
+	dir = GinkgoT().TempDir()
+})
+
+var _ = Describe("APIRecord", func() {
+	var replayPath string
+	var ec2api *ec2.EC2
+
+	BeforeEach(func() {
+		replayPath = filepath.Join(dir, "replay.jsonl")
+		ExpectRecording(replayPath,
+			apirecord.Entry{
+				Service:   ec2.ServiceName,
+				Operation: "DescribeInstances",
+				Output: lo.Must(json.Marshal(&ec2.DescribeInstancesOutput{
+					Reservations: []*ec2.Reservation{{
+						OwnerId:   aws.String("123456789012"),
+						Instances: []*ec2.Instance{{InstanceId: aws.String("i-0123456789abcdef0")}},
+					}},
+				})),
+			},
+			apirecord.Entry{
+				Service:   ec2.ServiceName,
+				Operation: "TerminateInstances",
+				Error: &apirecord.Error{Code: "UnauthorizedOperation",
+					Message: "User: arn:aws:sts::123456789012:assumed-role/KarpenterControllerRole/karpenter is not authorized to perform: ec2:TerminateInstances"},
+			},
+			apirecord.Entry{
+				Service:   ec2.ServiceName,
+				Operation: "DescribeInstanceAttribute",
+				Output: lo.Must(json.Marshal(&ec2.DescribeInstanceAttributeOutput{
+					InstanceId: aws.String("i-0123456789abcdef0"),
+					UserData:   &ec2.AttributeValue{Value: aws.String("c2VjcmV0")},
+				})),
+			},
+		)
+		sess := session.Must(session.NewSession(&aws.Config{
+			Region:      aws.String("us-west-2"),
+			Credentials: credentials.AnonymousCredentials,
+		}))
+		Expect(apirecord.Replay(sess, replayPath)).To(Succeed())
+		ec2api = ec2.New(sess)
+	})
+
+	It("should replay recorded responses in order", func() {
+		out, err := ec2api.DescribeInstances(&ec2.DescribeInstancesInput{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(aws.StringValue(out.Reservations[0].Instances[0].InstanceId)).To(Equal("i-0123456789abcdef0"))
+
+		// The recording only contains one DescribeInstances call
+		_, err = ec2api.DescribeInstances(&ec2.DescribeInstancesInput{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.(awserr.Error).Code()).To(Equal("NoRecordedResponse"))
+	})
+	It("should replay recorded errors", func() {
+		_, err := ec2api.TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: aws.StringSlice([]string{"i-0123456789abcdef0"})})
+		Expect(err).To(HaveOccurred())
+		Expect(err.(awserr.Error).Code()).To(Equal("UnauthorizedOperation"))
+	})
+	It("should record calls with sensitive values scrubbed", func() {
+		recordPath := filepath.Join(dir, "record.jsonl")
+		sess := session.Must(session.NewSession(&aws.Config{
+			Region:      aws.String("us-west-2"),
+			Credentials: credentials.AnonymousCredentials,
+		}))
+		Expect(apirecord.Replay(sess, replayPath)).To(Succeed())
+		Expect(apirecord.Record(context.Background(), sess, recordPath)).To(Succeed())
+		ec2api = ec2.New(sess)
+
+		_, err := ec2api.DescribeInstances(&ec2.DescribeInstancesInput{})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = ec2api.DescribeInstanceAttribute(&ec2.DescribeInstanceAttributeInput{
+			InstanceId: aws.String("i-0123456789abcdef0"),
+			Attribute:  aws.String(ec2.InstanceAttributeNameUserData),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = ec2api.TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: aws.StringSlice([]string{"i-0123456789abcdef0"})})
+		Expect(err).To(HaveOccurred())
+
+		recording := string(lo.Must(os.ReadFile(recordPath)))
+		Expect(recording).To(ContainSubstring("i-0123456789abcdef0"))
+		Expect(recording).To(ContainSubstring("UnauthorizedOperation"))
+		Expect(recording).ToNot(ContainSubstring("123456789012"))
+		Expect(recording).ToNot(ContainSubstring("c2VjcmV0"))
+		Expect(recording).To(ContainSubstring("REDACTED"))
+		Expect(recording).To(ContainSubstring("arn:aws:sts::000000000000:assumed-role/KarpenterControllerRole"))
+	})
+	It("should stop recording calls once the context is done", func() {
+		recordPath := filepath.Join(dir, "record.jsonl")
+		sess := session.Must(session.NewSession(&aws.Config{
+			Region:      aws.String("us-west-2"),
+			Credentials: credentials.AnonymousCredentials,
+		}))
+		recordCtx, cancel := context.WithCancel(context.Background())
+		Expect(apirecord.Replay(sess, replayPath)).To(Succeed())
+		Expect(apirecord.Record(recordCtx, sess, recordPath)).To(Succeed())
+		ec2api = ec2.New(sess)
+
+		_, err := ec2api.DescribeInstances(&ec2.DescribeInstancesInput{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lo.Must(os.ReadFile(recordPath))).ToNot(BeEmpty())
+
+		cancel()
+		// The file is closed asynchronously, so calls are made until one of them is no longer recorded
+		Eventually(func() bool {
+			before := lo.Must(os.ReadFile(recordPath))
+			_, _ = ec2api.DescribeInstances(&ec2.DescribeInstancesInput{})
+			return string(lo.Must(os.ReadFile(recordPath))) == string(before)
+		}).Should(BeTrue())
+	})
+})
+
+func ExpectRecording(path string, entries ...apirecord.Entry) {
+	file, err := os.Create(path)
+	Expect(err).ToNot(HaveOccurred())
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for i := range entries {
+		Expect(encoder.Encode(entries[i])).To(Succeed())
+	}
+}
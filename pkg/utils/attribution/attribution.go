@@ -0,0 +1,85 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attribution
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+)
+
+type attributionKeyType struct{}
+type linkedKeyType struct{}
+
+var (
+	attributionKey = attributionKeyType{}
+	linkedKey      = linkedKeyType{}
+)
+
+// Attribution is the NodePool and NodeClass that AWS API calls are made on behalf of, so that the calls can be counted
+// against the configuration that triggered them. Either may be empty if the calls aren't specific to one.
+type Attribution struct {
+	NodePool  string
+	NodeClass string
+}
+
+// WithNodePool attributes the AWS API calls made with the context to the NodePool, keeping any NodeClass that they're
+// already attributed to
+func WithNodePool(ctx context.Context, nodePool string) context.Context {
+	a := FromContext(ctx)
+	a.NodePool = nodePool
+	return context.WithValue(ctx, attributionKey, a)
+}
+
+// WithNodeClass attributes the AWS API calls made with the context to the NodeClass, keeping any NodePool that they're
+// already attributed to
+func WithNodeClass(ctx context.Context, nodeClass string) context.Context {
+	a := FromContext(ctx)
+	a.NodeClass = nodeClass
+	return context.WithValue(ctx, attributionKey, a)
+}
+
+// FromContext returns what the AWS API calls made with the context are attributed to. The zero value means that they
+// aren't attributed to any NodePool or NodeClass.
+func FromContext(ctx context.Context) Attribution {
+	if ctx == nil {
+		return Attribution{}
+	}
+	a, _ := ctx.Value(attributionKey).(Attribution)
+	return a
+}
+
+// WithLinked attributes the AWS API calls made with the context to everything that the calls made with each of the
+// linked contexts are attributed to, such as a batched call that's made on behalf of every caller in the batch
+func WithLinked(ctx context.Context, linked []context.Context) context.Context {
+	return context.WithValue(ctx, linkedKey, lo.Uniq(lo.FlatMap(linked, func(l context.Context, _ int) []Attribution {
+		return AllFromContext(l)
+	})))
+}
+
+// AllFromContext returns everything that the AWS API calls made with the context are attributed to, without
+// duplicates. It's empty if they aren't attributed to any NodePool or NodeClass.
+func AllFromContext(ctx context.Context) []Attribution {
+	if ctx == nil {
+		return nil
+	}
+	if linked, ok := ctx.Value(linkedKey).([]Attribution); ok {
+		return linked
+	}
+	if a := FromContext(ctx); a != (Attribution{}) {
+		return []Attribution{a}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attribution_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/karpenter/pkg/utils/attribution"
+)
+
+func TestAPIs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Attribution")
+}
+
+var _ = Describe("Attribution", func() {
+	It("should not attribute calls by default", func() {
+		Expect(attribution.FromContext(context.Background())).To(Equal(attribution.Attribution{}))
+	})
+	It("should attribute calls to a nodepool and a nodeclass", func() {
+		ctx := attribution.WithNodeClass(attribution.WithNodePool(context.Background(), "default"), "al2")
+		Expect(attribution.FromContext(ctx)).To(Equal(attribution.Attribution{NodePool: "default", NodeClass: "al2"}))
+	})
+	It("should keep the nodepool when the nodeclass is set afterwards and vice versa", func() {
+		ctx := attribution.WithNodePool(attribution.WithNodeClass(context.Background(), "al2"), "default")
+		Expect(attribution.FromContext(ctx)).To(Equal(attribution.Attribution{NodePool: "default", NodeClass: "al2"}))
+		ctx = attribution.WithNodePool(ctx, "other")
+		Expect(attribution.FromContext(ctx)).To(Equal(attribution.Attribution{NodePool: "other", NodeClass: "al2"}))
+	})
+	It("should attribute calls to everything that the linked contexts are attributed to", func() {
+		ctx := attribution.WithLinked(context.Background(), []context.Context{
+			attribution.WithNodePool(context.Background(), "default"),
+			attribution.WithNodePool(context.Background(), "default"),
+			attribution.WithNodeClass(context.Background(), "al2"),
+			context.Background(),
+		})
+		Expect(attribution.AllFromContext(ctx)).To(ConsistOf(
+			attribution.Attribution{NodePool: "default"},
+			attribution.Attribution{NodeClass: "al2"},
+		))
+	})
+	It("should attribute unlinked calls to their own attribution", func() {
+		Expect(attribution.AllFromContext(context.Background())).To(BeEmpty())
+		ctx := attribution.WithNodePool(context.Background(), "default")
+		Expect(attribution.AllFromContext(ctx)).To(ConsistOf(attribution.Attribution{NodePool: "default"}))
+	})
+	It("should be available to the handlers of AWS API requests made with the context", func() {
+		sess := session.Must(session.NewSession(&aws.Config{
+			Region:      aws.String("us-west-2"),
+			Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		}))
+		var attributed attribution.Attribution
+		sess.Handlers.Validate.PushBack(func(r *request.Request) {
+			attributed = attribution.FromContext(r.Context())
+			r.Error = errors.New("stop before sending")
+		})
+		ctx := attribution.WithNodeClass(attribution.WithNodePool(context.Background(), "default"), "al2")
+		_, err := ec2.New(sess).DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{})
+		Expect(err).To(HaveOccurred())
+		Expect(attributed).To(Equal(attribution.Attribution{NodePool: "default", NodeClass: "al2"}))
+	})
+})
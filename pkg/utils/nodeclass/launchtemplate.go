@@ -0,0 +1,213 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeclass
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+)
+
+const ssmImageIDPrefix = "resolve:ssm:"
+
+// NewFromLaunchTemplate converts the data of an existing EC2 launch template version into an equivalent NodeClass.
+// The launch template's image and user data are carried over as-is, so the NodeClass uses the Custom AMIFamily.
+func NewFromLaunchTemplate(name string, data *ec2.ResponseLaunchTemplateData) (*v1beta1.NodeClass, error) {
+	userData, err := decodeUserData(data.UserData)
+	if err != nil {
+		return nil, err
+	}
+	nodeClass := newNodeClass(name, data.ImageId, userData)
+	nodeClass.Spec.SecurityGroupSelectorTerms = newSecurityGroupSelectorTerms(append(aws.StringValueSlice(data.SecurityGroupIds), aws.StringValueSlice(data.SecurityGroups)...))
+	for _, ni := range data.NetworkInterfaces {
+		nodeClass.Spec.SecurityGroupSelectorTerms = append(nodeClass.Spec.SecurityGroupSelectorTerms, newSecurityGroupSelectorTerms(aws.StringValueSlice(ni.Groups))...)
+		if ni.SubnetId != nil {
+			nodeClass.Spec.SubnetSelectorTerms = append(nodeClass.Spec.SubnetSelectorTerms, v1beta1.SubnetSelectorTerm{ID: aws.StringValue(ni.SubnetId)})
+		}
+	}
+	nodeClass.Spec.SecurityGroupSelectorTerms = lo.UniqBy(nodeClass.Spec.SecurityGroupSelectorTerms, func(term v1beta1.SecurityGroupSelectorTerm) string {
+		return term.ID + "/" + term.Name
+	})
+	for _, bdm := range data.BlockDeviceMappings {
+		// Instance store volumes are exposed by the instance type rather than configured on the NodeClass
+		if bdm.Ebs == nil {
+			continue
+		}
+		nodeClass.Spec.BlockDeviceMappings = append(nodeClass.Spec.BlockDeviceMappings, &v1beta1.BlockDeviceMapping{
+			DeviceName: bdm.DeviceName,
+			EBS: &v1beta1.BlockDevice{
+				DeleteOnTermination: bdm.Ebs.DeleteOnTermination,
+				Encrypted:           bdm.Ebs.Encrypted,
+				IOPS:                bdm.Ebs.Iops,
+				KMSKeyID:            bdm.Ebs.KmsKeyId,
+				SnapshotID:          bdm.Ebs.SnapshotId,
+				Throughput:          bdm.Ebs.Throughput,
+				VolumeSize:          newVolumeSize(bdm.Ebs.VolumeSize),
+				VolumeType:          bdm.Ebs.VolumeType,
+			},
+		})
+	}
+	if mo := data.MetadataOptions; mo != nil {
+		nodeClass.Spec.MetadataOptions = &v1beta1.MetadataOptions{
+			HTTPEndpoint:            mo.HttpEndpoint,
+			HTTPProtocolIPv6:        mo.HttpProtocolIpv6,
+			HTTPPutResponseHopLimit: mo.HttpPutResponseHopLimit,
+			HTTPTokens:              mo.HttpTokens,
+			InstanceMetadataTags:    mo.InstanceMetadataTags,
+		}
+	}
+	if data.Monitoring != nil {
+		nodeClass.Spec.DetailedMonitoring = data.Monitoring.Enabled
+	}
+	for _, ts := range data.TagSpecifications {
+		if aws.StringValue(ts.ResourceType) != ec2.ResourceTypeInstance && aws.StringValue(ts.ResourceType) != ec2.ResourceTypeVolume {
+			continue
+		}
+		for _, tag := range ts.Tags {
+			addTag(nodeClass, aws.StringValue(tag.Key), aws.StringValue(tag.Value))
+		}
+	}
+	return nodeClass, nil
+}
+
+// NewFromLaunchConfiguration converts an existing Auto Scaling launch configuration into an equivalent NodeClass.
+// The launch configuration's image and user data are carried over as-is, so the NodeClass uses the Custom AMIFamily.
+func NewFromLaunchConfiguration(name string, lc *autoscaling.LaunchConfiguration) (*v1beta1.NodeClass, error) {
+	userData, err := decodeUserData(lc.UserData)
+	if err != nil {
+		return nil, err
+	}
+	nodeClass := newNodeClass(name, lc.ImageId, userData)
+	nodeClass.Spec.SecurityGroupSelectorTerms = newSecurityGroupSelectorTerms(aws.StringValueSlice(lc.SecurityGroups))
+	for _, bdm := range lc.BlockDeviceMappings {
+		if bdm.Ebs == nil {
+			continue
+		}
+		nodeClass.Spec.BlockDeviceMappings = append(nodeClass.Spec.BlockDeviceMappings, &v1beta1.BlockDeviceMapping{
+			DeviceName: bdm.DeviceName,
+			EBS: &v1beta1.BlockDevice{
+				DeleteOnTermination: bdm.Ebs.DeleteOnTermination,
+				Encrypted:           bdm.Ebs.Encrypted,
+				IOPS:                bdm.Ebs.Iops,
+				SnapshotID:          bdm.Ebs.SnapshotId,
+				Throughput:          bdm.Ebs.Throughput,
+				VolumeSize:          newVolumeSize(bdm.Ebs.VolumeSize),
+				VolumeType:          bdm.Ebs.VolumeType,
+			},
+		})
+	}
+	if mo := lc.MetadataOptions; mo != nil {
+		nodeClass.Spec.MetadataOptions = &v1beta1.MetadataOptions{
+			HTTPEndpoint:            mo.HttpEndpoint,
+			HTTPPutResponseHopLimit: mo.HttpPutResponseHopLimit,
+			HTTPTokens:              mo.HttpTokens,
+		}
+	}
+	if lc.InstanceMonitoring != nil {
+		nodeClass.Spec.DetailedMonitoring = lc.InstanceMonitoring.Enabled
+	}
+	return nodeClass, nil
+}
+
+// MergeAutoScalingGroup adds the subnets and launch-propagated tags of the Auto Scaling group that a launch template or
+// launch configuration was used by to the converted NodeClass
+func MergeAutoScalingGroup(nodeClass *v1beta1.NodeClass, asg *autoscaling.Group) {
+	for _, id := range strings.Split(aws.StringValue(asg.VPCZoneIdentifier), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			nodeClass.Spec.SubnetSelectorTerms = append(nodeClass.Spec.SubnetSelectorTerms, v1beta1.SubnetSelectorTerm{ID: id})
+		}
+	}
+	nodeClass.Spec.SubnetSelectorTerms = lo.UniqBy(nodeClass.Spec.SubnetSelectorTerms, func(term v1beta1.SubnetSelectorTerm) string {
+		return term.ID
+	})
+	for _, tag := range asg.Tags {
+		if aws.BoolValue(tag.PropagateAtLaunch) {
+			addTag(nodeClass, aws.StringValue(tag.Key), aws.StringValue(tag.Value))
+		}
+	}
+}
+
+func newNodeClass(name string, imageID *string, userData *string) *v1beta1.NodeClass {
+	nodeClass := &v1beta1.NodeClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1beta1.SchemeGroupVersion.String(),
+			Kind:       "NodeClass",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1beta1.NodeClassSpec{
+			AMIFamily: aws.String(v1beta1.AMIFamilyCustom),
+			UserData:  userData,
+		},
+	}
+	if id := aws.StringValue(imageID); strings.HasPrefix(id, ssmImageIDPrefix) {
+		nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: strings.TrimPrefix(id, ssmImageIDPrefix)}}
+	} else if id != "" {
+		nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: id}}
+	}
+	return nodeClass
+}
+
+// newSecurityGroupSelectorTerms selects security groups by ID, falling back to the group name for groups in the
+// default VPC that are referenced by name
+func newSecurityGroupSelectorTerms(groups []string) []v1beta1.SecurityGroupSelectorTerm {
+	return lo.Map(groups, func(group string, _ int) v1beta1.SecurityGroupSelectorTerm {
+		if strings.HasPrefix(group, "sg-") {
+			return v1beta1.SecurityGroupSelectorTerm{ID: group}
+		}
+		return v1beta1.SecurityGroupSelectorTerm{Name: group}
+	})
+}
+
+func newVolumeSize(size *int64) *resource.Quantity {
+	if size == nil {
+		return nil
+	}
+	return lo.ToPtr(resource.MustParse(fmt.Sprintf("%dGi", *size)))
+}
+
+// addTag adds a tag to the NodeClass unless it is reserved by AWS or managed by Karpenter
+func addTag(nodeClass *v1beta1.NodeClass, key, value string) {
+	if strings.HasPrefix(key, "aws:") {
+		return
+	}
+	if _, restricted := lo.Find(v1beta1.RestrictedTagPatterns, func(pattern *regexp.Regexp) bool { return pattern.MatchString(key) }); restricted {
+		return
+	}
+	if nodeClass.Spec.Tags == nil {
+		nodeClass.Spec.Tags = map[string]string{}
+	}
+	nodeClass.Spec.Tags[key] = value
+}
+
+func decodeUserData(userData *string) (*string, error) {
+	if aws.StringValue(userData) == "" {
+		return nil, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(aws.StringValue(userData))
+	if err != nil {
+		return nil, fmt.Errorf("decoding user data, %w", err)
+	}
+	return aws.String(string(decoded)), nil
+}
@@ -17,8 +17,10 @@ package nodeclass
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -43,20 +45,43 @@ func New(nodeTemplate *v1alpha1.AWSNodeTemplate) *v1beta1.NodeClass {
 			OriginalSecurityGroupSelector: nodeTemplate.Spec.SecurityGroupSelector,
 			AMISelectorTerms:              NewAMISelectorTerms(nodeTemplate.Spec.AMISelector),
 			OriginalAMISelector:           nodeTemplate.Spec.AMISelector,
+			AMIDeprecationPolicy:          nodeTemplate.Spec.AMIDeprecationPolicy,
+			AMISortStrategy:               nodeTemplate.Spec.AMISortStrategy,
+			AMIVariant:                    nodeTemplate.Spec.AMIVariant,
+			UbuntuStream:                  nodeTemplate.Spec.UbuntuStream,
+			TrustedBoot:                   NewTrustedBoot(nodeTemplate.Spec.TrustedBoot),
+			AMIRollout:                    NewAMIRollout(nodeTemplate.Spec.AMIRollout),
 			AMIFamily:                     nodeTemplate.Spec.AMIFamily,
 			UserData:                      nodeTemplate.Spec.UserData,
 			Tags:                          nodeTemplate.Spec.Tags,
 			BlockDeviceMappings:           NewBlockDeviceMappings(nodeTemplate.Spec.BlockDeviceMappings),
 			DetailedMonitoring:            nodeTemplate.Spec.DetailedMonitoring,
+			CloudWatchAgent:               NewCloudWatchAgent(nodeTemplate.Spec.CloudWatchAgent),
+			DomainJoin:                    NewDomainJoin(nodeTemplate.Spec.DomainJoin),
+			Neuron:                        NewNeuron(nodeTemplate.Spec.Neuron),
+			CPUOptions:                    NewCPUOptions(nodeTemplate.Spec.CPUOptions),
+			MaintenanceWindows:            NewMaintenanceWindows(nodeTemplate.Spec.MaintenanceWindows),
+			RegistrationTTL:               nodeTemplate.Spec.RegistrationTTL,
+			SecurityGroupDriftRemediation: nodeTemplate.Spec.SecurityGroupDriftRemediation,
+			MinimumNodeLifetime:           nodeTemplate.Spec.MinimumNodeLifetime,
+			ScheduledCapacityReservations: NewScheduledCapacityReservations(nodeTemplate.Spec.ScheduledCapacityReservations),
+			InstanceTypeOverrides:         NewInstanceTypeOverrides(nodeTemplate.Spec.InstanceTypeOverrides),
+			KubeReserved:                  NewKubeReserved(nodeTemplate.Spec.KubeReserved),
+			ExcludedInstanceClasses:       nodeTemplate.Spec.ExcludedInstanceClasses,
+			InstanceSelectionMode:         nodeTemplate.Spec.InstanceSelectionMode,
+			CapacityTypeOverrides:         NewCapacityTypeOverrides(nodeTemplate.Spec.CapacityTypeOverrides),
 			MetadataOptions:               NewMetadataOptions(nodeTemplate.Spec.MetadataOptions),
 			Context:                       nodeTemplate.Spec.Context,
 			LaunchTemplateName:            nodeTemplate.Spec.LaunchTemplateName,
 			InstanceProfile:               nodeTemplate.Spec.InstanceProfile,
 		},
 		Status: v1beta1.NodeClassStatus{
-			Subnets:        NewSubnets(nodeTemplate.Status.Subnets),
-			SecurityGroups: NewSecurityGroups(nodeTemplate.Status.SecurityGroups),
-			AMIs:           NewAMIs(nodeTemplate.Status.AMIs),
+			Subnets:               NewSubnets(nodeTemplate.Status.Subnets),
+			SecurityGroups:        NewSecurityGroups(nodeTemplate.Status.SecurityGroups),
+			AMIs:                  NewAMIs(nodeTemplate.Status.AMIs),
+			PreviousAMIs:          NewAMIs(nodeTemplate.Status.PreviousAMIs),
+			ExcludedInstanceTypes: NewExcludedInstanceTypes(nodeTemplate.Status.ExcludedInstanceTypes),
+			Conditions:            nodeTemplate.Status.Conditions,
 		},
 		IsNodeTemplate: true,
 	}
@@ -120,15 +145,39 @@ func NewAMISelectorTerms(amiSelector map[string]string) (terms []v1beta1.AMISele
 	ids := []string{""}
 	names := []string{""}
 	owners := []string{""}
+	ssmParameters := []string{""}
+	imageBuilderARNs := []string{""}
 	tags := map[string]string{}
+	// The creation date window and role apply to every term, and values that fail to parse are rejected by the validation
+	var minCreationDate, maxCreationDate *metav1.Time
+	var maxAge *metav1.Duration
+	var roleARN string
 	for k, v := range amiSelector {
 		switch k {
+		case "aws::minCreationDate":
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				minCreationDate = &metav1.Time{Time: t}
+			}
+		case "aws::maxCreationDate":
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				maxCreationDate = &metav1.Time{Time: t}
+			}
+		case "aws::maxAge":
+			if d, err := time.ParseDuration(v); err == nil {
+				maxAge = &metav1.Duration{Duration: d}
+			}
+		case "aws::roleArn":
+			roleARN = strings.TrimSpace(v)
 		case "aws-ids", "aws::ids":
 			ids = strings.Split(strings.Trim(v, " "), ",")
 		case "aws::name":
 			names = strings.Split(strings.Trim(v, " "), ",")
 		case "aws::owners":
 			owners = strings.Split(strings.Trim(v, " "), ",")
+		case "aws::ssm":
+			ssmParameters = strings.Split(strings.Trim(v, " "), ",")
+		case "aws::imageBuilderArn":
+			imageBuilderARNs = strings.Split(strings.Trim(v, " "), ",")
 		default:
 			tags[k] = v
 		}
@@ -137,18 +186,39 @@ func NewAMISelectorTerms(amiSelector map[string]string) (terms []v1beta1.AMISele
 	for _, owner := range owners {
 		for _, id := range ids {
 			for _, name := range names {
-				terms = append(terms, v1beta1.AMISelectorTerm{
-					Tags:  tags,
-					ID:    id,
-					Name:  name,
-					Owner: owner,
-				})
+				for _, ssmParameter := range ssmParameters {
+					for _, imageBuilderARN := range imageBuilderARNs {
+						terms = append(terms, v1beta1.AMISelectorTerm{
+							Tags:            tags,
+							ID:              id,
+							Name:            name,
+							Owner:           owner,
+							SSM:             ssmParameter,
+							ImageBuilderARN: imageBuilderARN,
+							MinCreationDate: minCreationDate,
+							MaxCreationDate: maxCreationDate,
+							MaxAge:          maxAge,
+							RoleARN:         roleARN,
+						})
+					}
+				}
 			}
 		}
 	}
 	return terms
 }
 
+func NewAMIRollout(rollout *v1alpha1.AMIRollout) *v1beta1.AMIRollout {
+	if rollout == nil {
+		return nil
+	}
+	return &v1beta1.AMIRollout{
+		AMISelectorTerms:    NewAMISelectorTerms(rollout.AMISelector),
+		Weight:              rollout.Weight,
+		OriginalAMISelector: rollout.AMISelector,
+	}
+}
+
 func NewBlockDeviceMappings(bdms []*v1alpha1.BlockDeviceMapping) []*v1beta1.BlockDeviceMapping {
 	if bdms == nil {
 		return nil
@@ -193,9 +263,147 @@ func NewMetadataOptions(mo *v1alpha1.MetadataOptions) *v1beta1.MetadataOptions {
 		HTTPProtocolIPv6:        mo.HTTPProtocolIPv6,
 		HTTPPutResponseHopLimit: mo.HTTPPutResponseHopLimit,
 		HTTPTokens:              mo.HTTPTokens,
+		InstanceMetadataTags:    mo.InstanceMetadataTags,
+	}
+}
+
+func NewCloudWatchAgent(cwa *v1alpha1.CloudWatchAgent) *v1beta1.CloudWatchAgent {
+	if cwa == nil {
+		return nil
+	}
+	return &v1beta1.CloudWatchAgent{
+		Type:               cwa.Type,
+		Config:             cwa.Config,
+		ConfigSSMParameter: cwa.ConfigSSMParameter,
+	}
+}
+
+func NewDomainJoin(dj *v1alpha1.DomainJoin) *v1beta1.DomainJoin {
+	if dj == nil {
+		return nil
+	}
+	return &v1beta1.DomainJoin{
+		DirectoryID:           dj.DirectoryID,
+		DirectoryName:         dj.DirectoryName,
+		DNSIPAddresses:        dj.DNSIPAddresses,
+		SSMDocument:           dj.SSMDocument,
+		SSMDocumentParameters: dj.SSMDocumentParameters,
+		GMSA:                  dj.GMSA,
+	}
+}
+
+func NewTrustedBoot(t *v1alpha1.TrustedBoot) *v1beta1.TrustedBoot {
+	if t == nil {
+		return nil
+	}
+	return &v1beta1.TrustedBoot{
+		NitroTPM:   t.NitroTPM,
+		SecureBoot: t.SecureBoot,
+	}
+}
+
+func NewNeuron(n *v1alpha1.Neuron) *v1beta1.Neuron {
+	if n == nil {
+		return nil
+	}
+	return &v1beta1.Neuron{
+		DriverVersion: n.DriverVersion,
+	}
+}
+
+func NewCPUOptions(c *v1alpha1.CPUOptions) *v1beta1.CPUOptions {
+	if c == nil {
+		return nil
+	}
+	return &v1beta1.CPUOptions{
+		CoreCount:      c.CoreCount,
+		ThreadsPerCore: c.ThreadsPerCore,
+	}
+}
+
+func NewMaintenanceWindows(windows []v1alpha1.MaintenanceWindow) []v1beta1.MaintenanceWindow {
+	if windows == nil {
+		return nil
+	}
+	return lo.Map(windows, func(w v1alpha1.MaintenanceWindow, _ int) v1beta1.MaintenanceWindow {
+		return v1beta1.MaintenanceWindow{
+			Days:     w.Days,
+			Start:    w.Start,
+			Duration: w.Duration,
+		}
+	})
+}
+
+func NewScheduledCapacityReservations(reservations []v1alpha1.ScheduledCapacityReservation) []v1beta1.ScheduledCapacityReservation {
+	if reservations == nil {
+		return nil
+	}
+	return lo.Map(reservations, func(r v1alpha1.ScheduledCapacityReservation, _ int) v1beta1.ScheduledCapacityReservation {
+		return v1beta1.ScheduledCapacityReservation{
+			InstanceType:     r.InstanceType,
+			AvailabilityZone: r.AvailabilityZone,
+			InstanceCount:    r.InstanceCount,
+			Days:             r.Days,
+			Start:            r.Start,
+			Duration:         r.Duration,
+		}
+	})
+}
+
+func NewInstanceTypeOverrides(overrides []v1alpha1.InstanceTypeOverride) []v1beta1.InstanceTypeOverride {
+	if overrides == nil {
+		return nil
+	}
+	return lo.Map(overrides, func(o v1alpha1.InstanceTypeOverride, _ int) v1beta1.InstanceTypeOverride {
+		return v1beta1.InstanceTypeOverride{
+			InstanceTypes:           o.InstanceTypes,
+			Capacity:                o.Capacity,
+			SystemReserved:          o.SystemReserved,
+			VMMemoryOverheadPercent: o.VMMemoryOverheadPercent,
+		}
+	})
+}
+
+func NewKubeReserved(kubeReserved *v1alpha1.KubeReserved) *v1beta1.KubeReserved {
+	if kubeReserved == nil {
+		return nil
+	}
+	return &v1beta1.KubeReserved{
+		Formula:          kubeReserved.Formula,
+		CPU:              NewReservedSteps(kubeReserved.CPU),
+		Memory:           NewReservedSteps(kubeReserved.Memory),
+		MemoryPerPod:     kubeReserved.MemoryPerPod,
+		BaseMemory:       kubeReserved.BaseMemory,
+		EphemeralStorage: kubeReserved.EphemeralStorage,
 	}
 }
 
+func NewReservedSteps(steps []v1alpha1.ReservedStep) []v1beta1.ReservedStep {
+	if steps == nil {
+		return nil
+	}
+	return lo.Map(steps, func(s v1alpha1.ReservedStep, _ int) v1beta1.ReservedStep {
+		return v1beta1.ReservedStep{
+			UpTo:  s.UpTo,
+			Ratio: s.Ratio,
+		}
+	})
+}
+
+func NewCapacityTypeOverrides(overrides []v1alpha1.CapacityTypeOverride) []v1beta1.CapacityTypeOverride {
+	if overrides == nil {
+		return nil
+	}
+	return lo.Map(overrides, func(o v1alpha1.CapacityTypeOverride, _ int) v1beta1.CapacityTypeOverride {
+		return v1beta1.CapacityTypeOverride{
+			CapacityType:       o.CapacityType,
+			Tags:               o.Tags,
+			UserData:           o.UserData,
+			DetailedMonitoring: o.DetailedMonitoring,
+		}
+	})
+}
+
 func NewSubnets(subnets []v1alpha1.Subnet) []v1beta1.Subnet {
 	if subnets == nil {
 		return nil
@@ -228,11 +436,25 @@ func NewAMIs(amis []v1alpha1.AMI) []v1beta1.AMI {
 		return v1beta1.AMI{
 			ID:           a.ID,
 			Name:         a.Name,
+			CreationDate: a.CreationDate,
 			Requirements: a.Requirements,
 		}
 	})
 }
 
+func NewExcludedInstanceTypes(excluded []v1alpha1.ExcludedInstanceType) []v1beta1.ExcludedInstanceType {
+	if excluded == nil {
+		return nil
+	}
+	return lo.Map(excluded, func(e v1alpha1.ExcludedInstanceType, _ int) v1beta1.ExcludedInstanceType {
+		return v1beta1.ExcludedInstanceType{
+			Name:          e.Name,
+			ExcludedUntil: e.ExcludedUntil,
+			Reason:        e.Reason,
+		}
+	})
+}
+
 func Get(ctx context.Context, c client.Client, key Key) (*v1beta1.NodeClass, error) {
 	if key.IsNodeTemplate {
 		nodeTemplate := &v1alpha1.AWSNodeTemplate{}
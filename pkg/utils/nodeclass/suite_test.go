@@ -16,14 +16,20 @@ package nodeclass_test
 
 import (
 	"context"
+	"encoding/base64"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	. "knative.dev/pkg/logging/testing"
 
 	"github.com/aws/karpenter-core/pkg/operator/scheme"
@@ -84,7 +90,8 @@ var _ = Describe("NodeClassUtils", func() {
 				},
 				LaunchTemplate: v1alpha1.LaunchTemplate{
 					MetadataOptions: &v1alpha1.MetadataOptions{
-						HTTPEndpoint: aws.String("test-metadata-1"),
+						HTTPEndpoint:         aws.String("test-metadata-1"),
+						InstanceMetadataTags: aws.String("enabled"),
 					},
 					BlockDeviceMappings: []*v1alpha1.BlockDeviceMapping{
 						{
@@ -98,6 +105,77 @@ var _ = Describe("NodeClassUtils", func() {
 			},
 			UserData:           aws.String("userdata-test-1"),
 			DetailedMonitoring: aws.Bool(false),
+			CloudWatchAgent: &v1alpha1.CloudWatchAgent{
+				ConfigSSMParameter: aws.String("AmazonCloudWatch-linux"),
+			},
+			DomainJoin: &v1alpha1.DomainJoin{
+				DirectoryID:    aws.String("d-1234567890"),
+				DirectoryName:  aws.String("corp.example.com"),
+				DNSIPAddresses: []string{"10.0.0.10"},
+				GMSA:           aws.Bool(true),
+			},
+			TrustedBoot: &v1alpha1.TrustedBoot{
+				NitroTPM:   true,
+				SecureBoot: true,
+			},
+			Neuron: &v1alpha1.Neuron{
+				DriverVersion: aws.String("2.14.5.0"),
+			},
+			CPUOptions: &v1alpha1.CPUOptions{
+				CoreCount:      aws.Int64(4),
+				ThreadsPerCore: aws.Int64(1),
+			},
+			MaintenanceWindows: []v1alpha1.MaintenanceWindow{
+				{
+					Days:     []string{"Saturday", "Sunday"},
+					Start:    "02:00",
+					Duration: metav1.Duration{Duration: 4 * time.Hour},
+				},
+			},
+			RegistrationTTL:               &metav1.Duration{Duration: 5 * time.Minute},
+			SecurityGroupDriftRemediation: lo.ToPtr(v1alpha1.SecurityGroupDriftRemediationInPlace),
+			AMIDeprecationPolicy:          lo.ToPtr(v1alpha1.AMIDeprecationPolicyExclude),
+			AMISortStrategy:               lo.ToPtr(v1alpha1.AMISortStrategyPinned),
+			AMIVariant:                    lo.ToPtr(v1alpha1.AMIVariantFIPS),
+			UbuntuStream:                  lo.ToPtr(v1alpha1.UbuntuStreamPro),
+			AMIRollout:                    &v1alpha1.AMIRollout{AMISelector: map[string]string{"aws::ids": "ami-candidate"}, Weight: 10},
+			MinimumNodeLifetime:           &metav1.Duration{Duration: time.Hour},
+			ScheduledCapacityReservations: []v1alpha1.ScheduledCapacityReservation{
+				{
+					InstanceType:     "m5.xlarge",
+					AvailabilityZone: "test-zone-1a",
+					InstanceCount:    10,
+					Days:             []string{"Monday", "Friday"},
+					Start:            "08:30",
+					Duration:         metav1.Duration{Duration: 2 * time.Hour},
+				},
+			},
+			CapacityTypeOverrides: []v1alpha1.CapacityTypeOverride{
+				{
+					CapacityType:       "spot",
+					Tags:               map[string]string{"agent": "spot"},
+					UserData:           aws.String("#!/bin/bash\necho spot"),
+					DetailedMonitoring: aws.Bool(true),
+				},
+			},
+			InstanceTypeOverrides: []v1alpha1.InstanceTypeOverride{
+				{
+					InstanceTypes:           []string{"p4d.24xlarge", "g5.*"},
+					Capacity:                v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("4")},
+					SystemReserved:          v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+					VMMemoryOverheadPercent: lo.ToPtr("0.01"),
+				},
+			},
+			KubeReserved: &v1alpha1.KubeReserved{
+				Formula:          lo.ToPtr(v1alpha1.KubeReservedFormulaCustom),
+				CPU:              []v1alpha1.ReservedStep{{UpTo: lo.ToPtr(resource.MustParse("1")), Ratio: "0.06"}, {Ratio: "0.01"}},
+				Memory:           []v1alpha1.ReservedStep{{Ratio: "0.1"}},
+				MemoryPerPod:     lo.ToPtr(resource.MustParse("11Mi")),
+				BaseMemory:       lo.ToPtr(resource.MustParse("255Mi")),
+				EphemeralStorage: lo.ToPtr(resource.MustParse("2Gi")),
+			},
+			ExcludedInstanceClasses: []string{v1alpha1.InstanceClassMetal, v1alpha1.InstanceClassOddSized},
+			InstanceSelectionMode:   lo.ToPtr(v1alpha1.InstanceSelectionModeAttributeBased),
 			AMISelector: map[string]string{
 				"test-ami-key": "test-ami-value",
 			},
@@ -125,8 +203,9 @@ var _ = Describe("NodeClassUtils", func() {
 			},
 			AMIs: []v1alpha1.AMI{
 				{
-					ID:   "test-ami-id",
-					Name: "test-ami-name",
+					ID:           "test-ami-id",
+					Name:         "test-ami-name",
+					CreationDate: "2023-01-01T12:00:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      v1.LabelArchStable,
@@ -136,8 +215,9 @@ var _ = Describe("NodeClassUtils", func() {
 					},
 				},
 				{
-					ID:   "test-ami-id2",
-					Name: "test-ami-name2",
+					ID:           "test-ami-id2",
+					Name:         "test-ami-name2",
+					CreationDate: "2023-01-02T12:00:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      v1.LabelArchStable,
@@ -147,6 +227,27 @@ var _ = Describe("NodeClassUtils", func() {
 					},
 				},
 			},
+			PreviousAMIs: []v1alpha1.AMI{
+				{
+					ID:           "test-ami-id3",
+					Name:         "test-ami-name3",
+					CreationDate: "2022-12-01T12:00:00Z",
+					Requirements: []v1.NodeSelectorRequirement{
+						{
+							Key:      v1.LabelArchStable,
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{"amd64"},
+						},
+					},
+				},
+			},
+			ExcludedInstanceTypes: []v1alpha1.ExcludedInstanceType{
+				{
+					Name:          "m5.large",
+					ExcludedUntil: metav1.NewTime(time.Date(2023, 1, 2, 13, 0, 0, 0, time.UTC)),
+					Reason:        "node didn't register within 15m0s",
+				},
+			},
 		}
 	})
 	It("should convert a AWSNodeTemplate to a NodeClass", func() {
@@ -170,6 +271,59 @@ var _ = Describe("NodeClassUtils", func() {
 		Expect(nodeClass.Spec.Tags).To(Equal(nodeTemplate.Spec.Tags))
 		ExpectBlockDeviceMappingsEqual(nodeTemplate.Spec.BlockDeviceMappings, nodeClass.Spec.BlockDeviceMappings)
 		Expect(nodeClass.Spec.DetailedMonitoring).To(Equal(nodeTemplate.Spec.DetailedMonitoring))
+		Expect(nodeClass.Spec.CloudWatchAgent.ConfigSSMParameter).To(Equal(nodeTemplate.Spec.CloudWatchAgent.ConfigSSMParameter))
+		Expect(nodeClass.Spec.DomainJoin.DirectoryID).To(Equal(nodeTemplate.Spec.DomainJoin.DirectoryID))
+		Expect(nodeClass.Spec.DomainJoin.DirectoryName).To(Equal(nodeTemplate.Spec.DomainJoin.DirectoryName))
+		Expect(nodeClass.Spec.DomainJoin.DNSIPAddresses).To(Equal(nodeTemplate.Spec.DomainJoin.DNSIPAddresses))
+		Expect(nodeClass.Spec.DomainJoin.GMSA).To(Equal(nodeTemplate.Spec.DomainJoin.GMSA))
+		Expect(nodeClass.Spec.TrustedBoot.NitroTPM).To(Equal(nodeTemplate.Spec.TrustedBoot.NitroTPM))
+		Expect(nodeClass.Spec.TrustedBoot.SecureBoot).To(Equal(nodeTemplate.Spec.TrustedBoot.SecureBoot))
+		Expect(nodeClass.Spec.Neuron.DriverVersion).To(Equal(nodeTemplate.Spec.Neuron.DriverVersion))
+		Expect(nodeClass.Spec.CPUOptions.CoreCount).To(Equal(nodeTemplate.Spec.CPUOptions.CoreCount))
+		Expect(nodeClass.Spec.CPUOptions.ThreadsPerCore).To(Equal(nodeTemplate.Spec.CPUOptions.ThreadsPerCore))
+		Expect(nodeClass.Spec.MaintenanceWindows).To(HaveLen(1))
+		Expect(nodeClass.Spec.MaintenanceWindows[0].Days).To(Equal(nodeTemplate.Spec.MaintenanceWindows[0].Days))
+		Expect(nodeClass.Spec.MaintenanceWindows[0].Start).To(Equal(nodeTemplate.Spec.MaintenanceWindows[0].Start))
+		Expect(nodeClass.Spec.MaintenanceWindows[0].Duration).To(Equal(nodeTemplate.Spec.MaintenanceWindows[0].Duration))
+		Expect(nodeClass.Spec.RegistrationTTL).To(Equal(nodeTemplate.Spec.RegistrationTTL))
+		Expect(nodeClass.Spec.SecurityGroupDriftRemediation).To(Equal(nodeTemplate.Spec.SecurityGroupDriftRemediation))
+		Expect(nodeClass.Spec.AMIDeprecationPolicy).To(Equal(nodeTemplate.Spec.AMIDeprecationPolicy))
+		Expect(nodeClass.Spec.AMISortStrategy).To(Equal(nodeTemplate.Spec.AMISortStrategy))
+		Expect(nodeClass.Spec.AMIVariant).To(Equal(nodeTemplate.Spec.AMIVariant))
+		Expect(nodeClass.Spec.UbuntuStream).To(Equal(nodeTemplate.Spec.UbuntuStream))
+		Expect(nodeClass.Spec.AMIRollout.AMISelectorTerms).To(ConsistOf(v1beta1.AMISelectorTerm{ID: "ami-candidate", Tags: map[string]string{}}))
+		Expect(nodeClass.Spec.AMIRollout.Weight).To(Equal(nodeTemplate.Spec.AMIRollout.Weight))
+		Expect(nodeClass.Spec.AMIRollout.OriginalAMISelector).To(Equal(nodeTemplate.Spec.AMIRollout.AMISelector))
+		Expect(nodeClass.Spec.MinimumNodeLifetime).To(Equal(nodeTemplate.Spec.MinimumNodeLifetime))
+		Expect(nodeClass.Spec.ScheduledCapacityReservations).To(HaveLen(1))
+		Expect(nodeClass.Spec.ScheduledCapacityReservations[0].InstanceType).To(Equal(nodeTemplate.Spec.ScheduledCapacityReservations[0].InstanceType))
+		Expect(nodeClass.Spec.ScheduledCapacityReservations[0].AvailabilityZone).To(Equal(nodeTemplate.Spec.ScheduledCapacityReservations[0].AvailabilityZone))
+		Expect(nodeClass.Spec.ScheduledCapacityReservations[0].InstanceCount).To(Equal(nodeTemplate.Spec.ScheduledCapacityReservations[0].InstanceCount))
+		Expect(nodeClass.Spec.ScheduledCapacityReservations[0].Days).To(Equal(nodeTemplate.Spec.ScheduledCapacityReservations[0].Days))
+		Expect(nodeClass.Spec.ScheduledCapacityReservations[0].Start).To(Equal(nodeTemplate.Spec.ScheduledCapacityReservations[0].Start))
+		Expect(nodeClass.Spec.ScheduledCapacityReservations[0].Duration).To(Equal(nodeTemplate.Spec.ScheduledCapacityReservations[0].Duration))
+		Expect(nodeClass.Spec.CapacityTypeOverrides).To(HaveLen(1))
+		Expect(nodeClass.Spec.CapacityTypeOverrides[0].CapacityType).To(Equal(nodeTemplate.Spec.CapacityTypeOverrides[0].CapacityType))
+		Expect(nodeClass.Spec.CapacityTypeOverrides[0].Tags).To(Equal(nodeTemplate.Spec.CapacityTypeOverrides[0].Tags))
+		Expect(nodeClass.Spec.CapacityTypeOverrides[0].UserData).To(Equal(nodeTemplate.Spec.CapacityTypeOverrides[0].UserData))
+		Expect(nodeClass.Spec.CapacityTypeOverrides[0].DetailedMonitoring).To(Equal(nodeTemplate.Spec.CapacityTypeOverrides[0].DetailedMonitoring))
+		Expect(nodeClass.Spec.InstanceTypeOverrides).To(HaveLen(1))
+		Expect(nodeClass.Spec.InstanceTypeOverrides[0].InstanceTypes).To(Equal(nodeTemplate.Spec.InstanceTypeOverrides[0].InstanceTypes))
+		Expect(nodeClass.Spec.InstanceTypeOverrides[0].Capacity).To(Equal(nodeTemplate.Spec.InstanceTypeOverrides[0].Capacity))
+		Expect(nodeClass.Spec.InstanceTypeOverrides[0].SystemReserved).To(Equal(nodeTemplate.Spec.InstanceTypeOverrides[0].SystemReserved))
+		Expect(nodeClass.Spec.InstanceTypeOverrides[0].VMMemoryOverheadPercent).To(Equal(nodeTemplate.Spec.InstanceTypeOverrides[0].VMMemoryOverheadPercent))
+		Expect(nodeClass.Spec.KubeReserved.Formula).To(Equal(nodeTemplate.Spec.KubeReserved.Formula))
+		Expect(nodeClass.Spec.KubeReserved.CPU).To(HaveLen(2))
+		Expect(nodeClass.Spec.KubeReserved.CPU[0].UpTo).To(Equal(nodeTemplate.Spec.KubeReserved.CPU[0].UpTo))
+		Expect(nodeClass.Spec.KubeReserved.CPU[0].Ratio).To(Equal(nodeTemplate.Spec.KubeReserved.CPU[0].Ratio))
+		Expect(nodeClass.Spec.KubeReserved.CPU[1].UpTo).To(BeNil())
+		Expect(nodeClass.Spec.KubeReserved.Memory).To(HaveLen(1))
+		Expect(nodeClass.Spec.KubeReserved.Memory[0].Ratio).To(Equal(nodeTemplate.Spec.KubeReserved.Memory[0].Ratio))
+		Expect(nodeClass.Spec.KubeReserved.MemoryPerPod).To(Equal(nodeTemplate.Spec.KubeReserved.MemoryPerPod))
+		Expect(nodeClass.Spec.KubeReserved.BaseMemory).To(Equal(nodeTemplate.Spec.KubeReserved.BaseMemory))
+		Expect(nodeClass.Spec.KubeReserved.EphemeralStorage).To(Equal(nodeTemplate.Spec.KubeReserved.EphemeralStorage))
+		Expect(nodeClass.Spec.ExcludedInstanceClasses).To(Equal(nodeTemplate.Spec.ExcludedInstanceClasses))
+		Expect(nodeClass.Spec.InstanceSelectionMode).To(Equal(nodeTemplate.Spec.InstanceSelectionMode))
 		ExpectMetadataOptionsEqual(nodeTemplate.Spec.MetadataOptions, nodeClass.Spec.MetadataOptions)
 		Expect(nodeClass.Spec.Context).To(Equal(nodeTemplate.Spec.Context))
 		Expect(nodeClass.Spec.LaunchTemplateName).To(Equal(nodeTemplate.Spec.LaunchTemplateName))
@@ -178,6 +332,8 @@ var _ = Describe("NodeClassUtils", func() {
 		ExpectSubnetStatusEqual(nodeTemplate.Status.Subnets, nodeClass.Status.Subnets)
 		ExpectSecurityGroupStatusEqual(nodeTemplate.Status.SecurityGroups, nodeClass.Status.SecurityGroups)
 		ExpectAMIStatusEqual(nodeTemplate.Status.AMIs, nodeClass.Status.AMIs)
+		ExpectAMIStatusEqual(nodeTemplate.Status.PreviousAMIs, nodeClass.Status.PreviousAMIs)
+		ExpectExcludedInstanceTypesStatusEqual(nodeTemplate.Status.ExcludedInstanceTypes, nodeClass.Status.ExcludedInstanceTypes)
 	})
 	It("should convert a AWSNodeTemplate to a NodeClass (with AMISelector name and owner values set)", func() {
 		nodeTemplate.Spec.AMISelector = map[string]string{
@@ -246,6 +402,99 @@ var _ = Describe("NodeClassUtils", func() {
 		ExpectSubnetStatusEqual(nodeTemplate.Status.Subnets, nodeClass.Status.Subnets)
 		ExpectSecurityGroupStatusEqual(nodeTemplate.Status.SecurityGroups, nodeClass.Status.SecurityGroups)
 		ExpectAMIStatusEqual(nodeTemplate.Status.AMIs, nodeClass.Status.AMIs)
+		ExpectAMIStatusEqual(nodeTemplate.Status.PreviousAMIs, nodeClass.Status.PreviousAMIs)
+	})
+	It("should convert a AWSNodeTemplate to a NodeClass (with AMISelector ssm and owner values set)", func() {
+		nodeTemplate.Spec.AMISelector = map[string]string{
+			"aws::ssm":    "/golden/al2/x86_64,/golden/al2/arm64",
+			"aws::owners": "123456789",
+		}
+		nodeClass := nodeclassutil.New(nodeTemplate)
+
+		Expect(nodeClass.Spec.AMISelectorTerms).To(ConsistOf(
+			v1beta1.AMISelectorTerm{
+				SSM:   "/golden/al2/x86_64",
+				Owner: "123456789",
+				Tags:  map[string]string{},
+			},
+			v1beta1.AMISelectorTerm{
+				SSM:   "/golden/al2/arm64",
+				Owner: "123456789",
+				Tags:  map[string]string{},
+			},
+		))
+		Expect(nodeClass.Spec.OriginalAMISelector).To(Equal(nodeTemplate.Spec.AMISelector))
+	})
+	It("should convert a AWSNodeTemplate to a NodeClass (with AMISelector image builder arn values set)", func() {
+		nodeTemplate.Spec.AMISelector = map[string]string{
+			"aws::imageBuilderArn": "arn:aws:imagebuilder:us-west-2:123456789012:image-pipeline/golden,arn:aws:imagebuilder:us-west-2:123456789012:image-recipe/golden/1.0.0",
+			"foo":                  "bar",
+		}
+		nodeClass := nodeclassutil.New(nodeTemplate)
+
+		Expect(nodeClass.Spec.AMISelectorTerms).To(ConsistOf(
+			v1beta1.AMISelectorTerm{
+				ImageBuilderARN: "arn:aws:imagebuilder:us-west-2:123456789012:image-pipeline/golden",
+				Tags:            map[string]string{"foo": "bar"},
+			},
+			v1beta1.AMISelectorTerm{
+				ImageBuilderARN: "arn:aws:imagebuilder:us-west-2:123456789012:image-recipe/golden/1.0.0",
+				Tags:            map[string]string{"foo": "bar"},
+			},
+		))
+		Expect(nodeClass.Spec.OriginalAMISelector).To(Equal(nodeTemplate.Spec.AMISelector))
+	})
+	It("should convert a AWSNodeTemplate to a NodeClass (with AMISelector role arn set)", func() {
+		nodeTemplate.Spec.AMISelector = map[string]string{
+			"aws::owners":  "self,111122223333",
+			"aws::roleArn": "arn:aws:iam::111122223333:role/golden-images",
+			"foo":          "bar",
+		}
+		nodeClass := nodeclassutil.New(nodeTemplate)
+
+		Expect(nodeClass.Spec.AMISelectorTerms).To(ConsistOf(
+			v1beta1.AMISelectorTerm{
+				Owner:   "self",
+				Tags:    map[string]string{"foo": "bar"},
+				RoleARN: "arn:aws:iam::111122223333:role/golden-images",
+			},
+			v1beta1.AMISelectorTerm{
+				Owner:   "111122223333",
+				Tags:    map[string]string{"foo": "bar"},
+				RoleARN: "arn:aws:iam::111122223333:role/golden-images",
+			},
+		))
+		Expect(nodeClass.Spec.OriginalAMISelector).To(Equal(nodeTemplate.Spec.AMISelector))
+	})
+	It("should convert a AWSNodeTemplate to a NodeClass (with AMISelector creation date window set)", func() {
+		nodeTemplate.Spec.AMISelector = map[string]string{
+			"aws::ids":             "ami-1234,ami-5678",
+			"aws::minCreationDate": "2023-01-01T00:00:00Z",
+			"aws::maxCreationDate": "2023-06-01T00:00:00Z",
+			"aws::maxAge":          "720h",
+		}
+		nodeClass := nodeclassutil.New(nodeTemplate)
+
+		minCreationDate := &metav1.Time{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+		maxCreationDate := &metav1.Time{Time: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)}
+		maxAge := &metav1.Duration{Duration: 720 * time.Hour}
+		Expect(nodeClass.Spec.AMISelectorTerms).To(ConsistOf(
+			v1beta1.AMISelectorTerm{
+				ID:              "ami-1234",
+				Tags:            map[string]string{},
+				MinCreationDate: minCreationDate,
+				MaxCreationDate: maxCreationDate,
+				MaxAge:          maxAge,
+			},
+			v1beta1.AMISelectorTerm{
+				ID:              "ami-5678",
+				Tags:            map[string]string{},
+				MinCreationDate: minCreationDate,
+				MaxCreationDate: maxCreationDate,
+				MaxAge:          maxAge,
+			},
+		))
+		Expect(nodeClass.Spec.OriginalAMISelector).To(Equal(nodeTemplate.Spec.AMISelector))
 	})
 	It("should convert a AWSNodeTemplate to a NodeClass (with AMISelector id set)", func() {
 		nodeTemplate.Spec.AMISelector = map[string]string{
@@ -295,6 +544,7 @@ var _ = Describe("NodeClassUtils", func() {
 		ExpectSubnetStatusEqual(nodeTemplate.Status.Subnets, nodeClass.Status.Subnets)
 		ExpectSecurityGroupStatusEqual(nodeTemplate.Status.SecurityGroups, nodeClass.Status.SecurityGroups)
 		ExpectAMIStatusEqual(nodeTemplate.Status.AMIs, nodeClass.Status.AMIs)
+		ExpectAMIStatusEqual(nodeTemplate.Status.PreviousAMIs, nodeClass.Status.PreviousAMIs)
 	})
 	It("should convert a AWSNodeTemplate to a NodeClass (with AMISelector name, owner, id, and tags set)", func() {
 		nodeTemplate.Spec.AMISelector = map[string]string{
@@ -409,6 +659,7 @@ var _ = Describe("NodeClassUtils", func() {
 		ExpectSubnetStatusEqual(nodeTemplate.Status.Subnets, nodeClass.Status.Subnets)
 		ExpectSecurityGroupStatusEqual(nodeTemplate.Status.SecurityGroups, nodeClass.Status.SecurityGroups)
 		ExpectAMIStatusEqual(nodeTemplate.Status.AMIs, nodeClass.Status.AMIs)
+		ExpectAMIStatusEqual(nodeTemplate.Status.PreviousAMIs, nodeClass.Status.PreviousAMIs)
 	})
 	It("should convert a AWSNodeTemplate to a NodeClass and back and still retain all original data", func() {
 		convertedNodeTemplate := nodetemplateutil.New(nodeclassutil.New(nodeTemplate))
@@ -433,6 +684,8 @@ var _ = Describe("NodeClassUtils", func() {
 		Expect(convertedNodeTemplate.Status.SecurityGroups).To(Equal(nodeTemplate.Status.SecurityGroups))
 		Expect(convertedNodeTemplate.Status.Subnets).To(Equal(nodeTemplate.Status.Subnets))
 		Expect(convertedNodeTemplate.Status.AMIs).To(Equal(nodeTemplate.Status.AMIs))
+		Expect(convertedNodeTemplate.Status.PreviousAMIs).To(Equal(nodeTemplate.Status.PreviousAMIs))
+		Expect(convertedNodeTemplate.Status.ExcludedInstanceTypes).To(Equal(nodeTemplate.Status.ExcludedInstanceTypes))
 	})
 	It("should retrieve a NodeClass with a get call", func() {
 		nodeClass := test.NodeClass()
@@ -450,4 +703,128 @@ var _ = Describe("NodeClassUtils", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(retrieved.Name).To(Equal(nodeTemplate.Name))
 	})
+	Context("Launch Template Conversion", func() {
+		It("should convert a launch template into a NodeClass", func() {
+			nodeClass, err := nodeclassutil.NewFromLaunchTemplate("my-node-group", &ec2.ResponseLaunchTemplateData{
+				ImageId:          aws.String("ami-0123456789abcdef0"),
+				UserData:         aws.String(base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\n/etc/eks/bootstrap.sh my-cluster"))),
+				SecurityGroupIds: aws.StringSlice([]string{"sg-1", "sg-2"}),
+				NetworkInterfaces: []*ec2.LaunchTemplateInstanceNetworkInterfaceSpecification{
+					{Groups: aws.StringSlice([]string{"sg-2", "sg-3"}), SubnetId: aws.String("subnet-1")},
+				},
+				BlockDeviceMappings: []*ec2.LaunchTemplateBlockDeviceMapping{
+					{
+						DeviceName: aws.String("/dev/xvda"),
+						Ebs: &ec2.LaunchTemplateEbsBlockDevice{
+							DeleteOnTermination: aws.Bool(true),
+							Encrypted:           aws.Bool(true),
+							Iops:                aws.Int64(3000),
+							KmsKeyId:            aws.String("arn:aws:kms:us-west-2:111122223333:key/key-1"),
+							Throughput:          aws.Int64(125),
+							VolumeSize:          aws.Int64(100),
+							VolumeType:          aws.String(ec2.VolumeTypeGp3),
+						},
+					},
+					{DeviceName: aws.String("/dev/sdb"), VirtualName: aws.String("ephemeral0")},
+				},
+				MetadataOptions: &ec2.LaunchTemplateInstanceMetadataOptions{
+					HttpEndpoint:            aws.String(ec2.LaunchTemplateInstanceMetadataEndpointStateEnabled),
+					HttpProtocolIpv6:        aws.String(ec2.LaunchTemplateInstanceMetadataProtocolIpv6Disabled),
+					HttpPutResponseHopLimit: aws.Int64(2),
+					HttpTokens:              aws.String(ec2.LaunchTemplateHttpTokensStateRequired),
+					InstanceMetadataTags:    aws.String(ec2.LaunchTemplateInstanceMetadataTagsStateEnabled),
+				},
+				Monitoring: &ec2.LaunchTemplatesMonitoring{Enabled: aws.Bool(true)},
+				TagSpecifications: []*ec2.LaunchTemplateTagSpecification{
+					{
+						ResourceType: aws.String(ec2.ResourceTypeInstance),
+						Tags: []*ec2.Tag{
+							{Key: aws.String("team"), Value: aws.String("team-1")},
+							{Key: aws.String("kubernetes.io/cluster/my-cluster"), Value: aws.String("owned")},
+						},
+					},
+					{
+						ResourceType: aws.String(ec2.ResourceTypeVolume),
+						Tags:         []*ec2.Tag{{Key: aws.String("backup"), Value: aws.String("false")}},
+					},
+					{
+						ResourceType: aws.String(ec2.ResourceTypeNetworkInterface),
+						Tags:         []*ec2.Tag{{Key: aws.String("eni"), Value: aws.String("true")}},
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nodeClass.Name).To(Equal("my-node-group"))
+			Expect(nodeClass.Kind).To(Equal("NodeClass"))
+			Expect(nodeClass.Spec.AMIFamily).To(Equal(aws.String(v1beta1.AMIFamilyCustom)))
+			Expect(nodeClass.Spec.AMISelectorTerms).To(ConsistOf(v1beta1.AMISelectorTerm{ID: "ami-0123456789abcdef0"}))
+			Expect(nodeClass.Spec.UserData).To(Equal(aws.String("#!/bin/bash\n/etc/eks/bootstrap.sh my-cluster")))
+			Expect(nodeClass.Spec.SecurityGroupSelectorTerms).To(ConsistOf(
+				v1beta1.SecurityGroupSelectorTerm{ID: "sg-1"},
+				v1beta1.SecurityGroupSelectorTerm{ID: "sg-2"},
+				v1beta1.SecurityGroupSelectorTerm{ID: "sg-3"},
+			))
+			Expect(nodeClass.Spec.SubnetSelectorTerms).To(ConsistOf(v1beta1.SubnetSelectorTerm{ID: "subnet-1"}))
+			Expect(nodeClass.Spec.BlockDeviceMappings).To(HaveLen(1))
+			Expect(nodeClass.Spec.BlockDeviceMappings[0].DeviceName).To(Equal(aws.String("/dev/xvda")))
+			Expect(nodeClass.Spec.BlockDeviceMappings[0].EBS.VolumeSize.String()).To(Equal("100Gi"))
+			Expect(nodeClass.Spec.BlockDeviceMappings[0].EBS.KMSKeyID).To(Equal(aws.String("arn:aws:kms:us-west-2:111122223333:key/key-1")))
+			Expect(nodeClass.Spec.BlockDeviceMappings[0].EBS.IOPS).To(Equal(aws.Int64(3000)))
+			Expect(nodeClass.Spec.MetadataOptions).To(Equal(&v1beta1.MetadataOptions{
+				HTTPEndpoint:            aws.String("enabled"),
+				HTTPProtocolIPv6:        aws.String("disabled"),
+				HTTPPutResponseHopLimit: aws.Int64(2),
+				HTTPTokens:              aws.String("required"),
+				InstanceMetadataTags:    aws.String("enabled"),
+			}))
+			Expect(nodeClass.Spec.DetailedMonitoring).To(Equal(aws.Bool(true)))
+			Expect(nodeClass.Spec.Tags).To(Equal(map[string]string{"team": "team-1", "backup": "false"}))
+		})
+		It("should convert an SSM parameter image into an SSM AMI selector term", func() {
+			nodeClass, err := nodeclassutil.NewFromLaunchTemplate("my-node-group", &ec2.ResponseLaunchTemplateData{
+				ImageId: aws.String("resolve:ssm:/aws/service/eks/optimized-ami/1.27/amazon-linux-2/recommended/image_id"),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nodeClass.Spec.AMISelectorTerms).To(ConsistOf(v1beta1.AMISelectorTerm{SSM: "/aws/service/eks/optimized-ami/1.27/amazon-linux-2/recommended/image_id"}))
+			Expect(nodeClass.Spec.UserData).To(BeNil())
+		})
+		It("should fail to convert a launch template with invalid user data", func() {
+			_, err := nodeclassutil.NewFromLaunchTemplate("my-node-group", &ec2.ResponseLaunchTemplateData{UserData: aws.String("not base64!")})
+			Expect(err).To(HaveOccurred())
+		})
+		It("should convert a launch configuration and its auto scaling group into a NodeClass", func() {
+			nodeClass, err := nodeclassutil.NewFromLaunchConfiguration("my-node-group", &autoscaling.LaunchConfiguration{
+				ImageId:        aws.String("ami-0123456789abcdef0"),
+				SecurityGroups: aws.StringSlice([]string{"sg-1", "default"}),
+				BlockDeviceMappings: []*autoscaling.BlockDeviceMapping{
+					{DeviceName: aws.String("/dev/xvda"), Ebs: &autoscaling.Ebs{VolumeSize: aws.Int64(20), VolumeType: aws.String("gp2")}},
+				},
+				MetadataOptions:    &autoscaling.InstanceMetadataOptions{HttpTokens: aws.String("required")},
+				InstanceMonitoring: &autoscaling.InstanceMonitoring{Enabled: aws.Bool(false)},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			nodeclassutil.MergeAutoScalingGroup(nodeClass, &autoscaling.Group{
+				VPCZoneIdentifier: aws.String("subnet-1, subnet-2"),
+				Tags: []*autoscaling.TagDescription{
+					{Key: aws.String("team"), Value: aws.String("team-1"), PropagateAtLaunch: aws.Bool(true)},
+					{Key: aws.String("asg-only"), Value: aws.String("true"), PropagateAtLaunch: aws.Bool(false)},
+					{Key: aws.String("aws:cloudformation:stack-name"), Value: aws.String("stack"), PropagateAtLaunch: aws.Bool(true)},
+				},
+			})
+			Expect(nodeClass.Spec.AMISelectorTerms).To(ConsistOf(v1beta1.AMISelectorTerm{ID: "ami-0123456789abcdef0"}))
+			Expect(nodeClass.Spec.SecurityGroupSelectorTerms).To(ConsistOf(
+				v1beta1.SecurityGroupSelectorTerm{ID: "sg-1"},
+				v1beta1.SecurityGroupSelectorTerm{Name: "default"},
+			))
+			Expect(nodeClass.Spec.SubnetSelectorTerms).To(ConsistOf(
+				v1beta1.SubnetSelectorTerm{ID: "subnet-1"},
+				v1beta1.SubnetSelectorTerm{ID: "subnet-2"},
+			))
+			Expect(nodeClass.Spec.BlockDeviceMappings).To(HaveLen(1))
+			Expect(nodeClass.Spec.BlockDeviceMappings[0].EBS.VolumeSize.String()).To(Equal("20Gi"))
+			Expect(nodeClass.Spec.MetadataOptions.HTTPTokens).To(Equal(aws.String("required")))
+			Expect(nodeClass.Spec.DetailedMonitoring).To(Equal(aws.Bool(false)))
+			Expect(nodeClass.Spec.Tags).To(Equal(map[string]string{"team": "team-1"}))
+		})
+	})
 })
@@ -40,17 +40,50 @@ func New(nodeClass *v1beta1.NodeClass) *v1alpha1.AWSNodeTemplate {
 					BlockDeviceMappings: NewBlockDeviceMappings(nodeClass.Spec.BlockDeviceMappings),
 				},
 			},
-			AMISelector:        nodeClass.Spec.OriginalAMISelector,
-			DetailedMonitoring: nodeClass.Spec.DetailedMonitoring,
+			AMISelector:                   nodeClass.Spec.OriginalAMISelector,
+			AMIDeprecationPolicy:          nodeClass.Spec.AMIDeprecationPolicy,
+			AMISortStrategy:               nodeClass.Spec.AMISortStrategy,
+			AMIVariant:                    nodeClass.Spec.AMIVariant,
+			UbuntuStream:                  nodeClass.Spec.UbuntuStream,
+			TrustedBoot:                   NewTrustedBoot(nodeClass.Spec.TrustedBoot),
+			AMIRollout:                    NewAMIRollout(nodeClass.Spec.AMIRollout),
+			DetailedMonitoring:            nodeClass.Spec.DetailedMonitoring,
+			CloudWatchAgent:               NewCloudWatchAgent(nodeClass.Spec.CloudWatchAgent),
+			DomainJoin:                    NewDomainJoin(nodeClass.Spec.DomainJoin),
+			Neuron:                        NewNeuron(nodeClass.Spec.Neuron),
+			CPUOptions:                    NewCPUOptions(nodeClass.Spec.CPUOptions),
+			MaintenanceWindows:            NewMaintenanceWindows(nodeClass.Spec.MaintenanceWindows),
+			RegistrationTTL:               nodeClass.Spec.RegistrationTTL,
+			SecurityGroupDriftRemediation: nodeClass.Spec.SecurityGroupDriftRemediation,
+			MinimumNodeLifetime:           nodeClass.Spec.MinimumNodeLifetime,
+			ScheduledCapacityReservations: NewScheduledCapacityReservations(nodeClass.Spec.ScheduledCapacityReservations),
+			InstanceTypeOverrides:         NewInstanceTypeOverrides(nodeClass.Spec.InstanceTypeOverrides),
+			KubeReserved:                  NewKubeReserved(nodeClass.Spec.KubeReserved),
+			ExcludedInstanceClasses:       nodeClass.Spec.ExcludedInstanceClasses,
+			InstanceSelectionMode:         nodeClass.Spec.InstanceSelectionMode,
+			CapacityTypeOverrides:         NewCapacityTypeOverrides(nodeClass.Spec.CapacityTypeOverrides),
 		},
 		Status: v1alpha1.AWSNodeTemplateStatus{
-			Subnets:        NewSubnets(nodeClass.Status.Subnets),
-			SecurityGroups: NewSecurityGroups(nodeClass.Status.SecurityGroups),
-			AMIs:           NewAMIs(nodeClass.Status.AMIs),
+			Subnets:               NewSubnets(nodeClass.Status.Subnets),
+			SecurityGroups:        NewSecurityGroups(nodeClass.Status.SecurityGroups),
+			AMIs:                  NewAMIs(nodeClass.Status.AMIs),
+			PreviousAMIs:          NewAMIs(nodeClass.Status.PreviousAMIs),
+			ExcludedInstanceTypes: NewExcludedInstanceTypes(nodeClass.Status.ExcludedInstanceTypes),
+			Conditions:            nodeClass.Status.Conditions,
 		},
 	}
 }
 
+func NewAMIRollout(rollout *v1beta1.AMIRollout) *v1alpha1.AMIRollout {
+	if rollout == nil {
+		return nil
+	}
+	return &v1alpha1.AMIRollout{
+		AMISelector: rollout.OriginalAMISelector,
+		Weight:      rollout.Weight,
+	}
+}
+
 func NewBlockDeviceMappings(bdms []*v1beta1.BlockDeviceMapping) []*v1alpha1.BlockDeviceMapping {
 	if bdms == nil {
 		return nil
@@ -95,9 +128,147 @@ func NewMetadataOptions(mo *v1beta1.MetadataOptions) *v1alpha1.MetadataOptions {
 		HTTPProtocolIPv6:        mo.HTTPProtocolIPv6,
 		HTTPPutResponseHopLimit: mo.HTTPPutResponseHopLimit,
 		HTTPTokens:              mo.HTTPTokens,
+		InstanceMetadataTags:    mo.InstanceMetadataTags,
+	}
+}
+
+func NewCloudWatchAgent(cwa *v1beta1.CloudWatchAgent) *v1alpha1.CloudWatchAgent {
+	if cwa == nil {
+		return nil
+	}
+	return &v1alpha1.CloudWatchAgent{
+		Type:               cwa.Type,
+		Config:             cwa.Config,
+		ConfigSSMParameter: cwa.ConfigSSMParameter,
+	}
+}
+
+func NewDomainJoin(dj *v1beta1.DomainJoin) *v1alpha1.DomainJoin {
+	if dj == nil {
+		return nil
+	}
+	return &v1alpha1.DomainJoin{
+		DirectoryID:           dj.DirectoryID,
+		DirectoryName:         dj.DirectoryName,
+		DNSIPAddresses:        dj.DNSIPAddresses,
+		SSMDocument:           dj.SSMDocument,
+		SSMDocumentParameters: dj.SSMDocumentParameters,
+		GMSA:                  dj.GMSA,
+	}
+}
+
+func NewTrustedBoot(t *v1beta1.TrustedBoot) *v1alpha1.TrustedBoot {
+	if t == nil {
+		return nil
+	}
+	return &v1alpha1.TrustedBoot{
+		NitroTPM:   t.NitroTPM,
+		SecureBoot: t.SecureBoot,
+	}
+}
+
+func NewNeuron(n *v1beta1.Neuron) *v1alpha1.Neuron {
+	if n == nil {
+		return nil
+	}
+	return &v1alpha1.Neuron{
+		DriverVersion: n.DriverVersion,
+	}
+}
+
+func NewCPUOptions(c *v1beta1.CPUOptions) *v1alpha1.CPUOptions {
+	if c == nil {
+		return nil
+	}
+	return &v1alpha1.CPUOptions{
+		CoreCount:      c.CoreCount,
+		ThreadsPerCore: c.ThreadsPerCore,
 	}
 }
 
+func NewMaintenanceWindows(windows []v1beta1.MaintenanceWindow) []v1alpha1.MaintenanceWindow {
+	if windows == nil {
+		return nil
+	}
+	return lo.Map(windows, func(w v1beta1.MaintenanceWindow, _ int) v1alpha1.MaintenanceWindow {
+		return v1alpha1.MaintenanceWindow{
+			Days:     w.Days,
+			Start:    w.Start,
+			Duration: w.Duration,
+		}
+	})
+}
+
+func NewScheduledCapacityReservations(reservations []v1beta1.ScheduledCapacityReservation) []v1alpha1.ScheduledCapacityReservation {
+	if reservations == nil {
+		return nil
+	}
+	return lo.Map(reservations, func(r v1beta1.ScheduledCapacityReservation, _ int) v1alpha1.ScheduledCapacityReservation {
+		return v1alpha1.ScheduledCapacityReservation{
+			InstanceType:     r.InstanceType,
+			AvailabilityZone: r.AvailabilityZone,
+			InstanceCount:    r.InstanceCount,
+			Days:             r.Days,
+			Start:            r.Start,
+			Duration:         r.Duration,
+		}
+	})
+}
+
+func NewInstanceTypeOverrides(overrides []v1beta1.InstanceTypeOverride) []v1alpha1.InstanceTypeOverride {
+	if overrides == nil {
+		return nil
+	}
+	return lo.Map(overrides, func(o v1beta1.InstanceTypeOverride, _ int) v1alpha1.InstanceTypeOverride {
+		return v1alpha1.InstanceTypeOverride{
+			InstanceTypes:           o.InstanceTypes,
+			Capacity:                o.Capacity,
+			SystemReserved:          o.SystemReserved,
+			VMMemoryOverheadPercent: o.VMMemoryOverheadPercent,
+		}
+	})
+}
+
+func NewKubeReserved(kubeReserved *v1beta1.KubeReserved) *v1alpha1.KubeReserved {
+	if kubeReserved == nil {
+		return nil
+	}
+	return &v1alpha1.KubeReserved{
+		Formula:          kubeReserved.Formula,
+		CPU:              NewReservedSteps(kubeReserved.CPU),
+		Memory:           NewReservedSteps(kubeReserved.Memory),
+		MemoryPerPod:     kubeReserved.MemoryPerPod,
+		BaseMemory:       kubeReserved.BaseMemory,
+		EphemeralStorage: kubeReserved.EphemeralStorage,
+	}
+}
+
+func NewReservedSteps(steps []v1beta1.ReservedStep) []v1alpha1.ReservedStep {
+	if steps == nil {
+		return nil
+	}
+	return lo.Map(steps, func(s v1beta1.ReservedStep, _ int) v1alpha1.ReservedStep {
+		return v1alpha1.ReservedStep{
+			UpTo:  s.UpTo,
+			Ratio: s.Ratio,
+		}
+	})
+}
+
+func NewCapacityTypeOverrides(overrides []v1beta1.CapacityTypeOverride) []v1alpha1.CapacityTypeOverride {
+	if overrides == nil {
+		return nil
+	}
+	return lo.Map(overrides, func(o v1beta1.CapacityTypeOverride, _ int) v1alpha1.CapacityTypeOverride {
+		return v1alpha1.CapacityTypeOverride{
+			CapacityType:       o.CapacityType,
+			Tags:               o.Tags,
+			UserData:           o.UserData,
+			DetailedMonitoring: o.DetailedMonitoring,
+		}
+	})
+}
+
 func NewSubnets(subnets []v1beta1.Subnet) []v1alpha1.Subnet {
 	if subnets == nil {
 		return nil
@@ -130,7 +301,21 @@ func NewAMIs(amis []v1beta1.AMI) []v1alpha1.AMI {
 		return v1alpha1.AMI{
 			ID:           a.ID,
 			Name:         a.Name,
+			CreationDate: a.CreationDate,
 			Requirements: a.Requirements,
 		}
 	})
 }
+
+func NewExcludedInstanceTypes(excluded []v1beta1.ExcludedInstanceType) []v1alpha1.ExcludedInstanceType {
+	if excluded == nil {
+		return nil
+	}
+	return lo.Map(excluded, func(e v1beta1.ExcludedInstanceType, _ int) v1alpha1.ExcludedInstanceType {
+		return v1alpha1.ExcludedInstanceType{
+			Name:          e.Name,
+			ExcludedUntil: e.ExcludedUntil,
+			Reason:        e.Reason,
+		}
+	})
+}
@@ -17,12 +17,15 @@ package nodetemplate_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	. "knative.dev/pkg/logging/testing"
 
 	"github.com/aws/karpenter-core/pkg/operator/scheme"
@@ -80,7 +83,8 @@ var _ = Describe("NodeTemplateUtils", func() {
 					"test-security-group-key": "test-security-group-value",
 				},
 				MetadataOptions: &v1beta1.MetadataOptions{
-					HTTPEndpoint: aws.String("test-metadata-1"),
+					HTTPEndpoint:         aws.String("test-metadata-1"),
+					InstanceMetadataTags: aws.String("enabled"),
 				},
 				BlockDeviceMappings: []*v1beta1.BlockDeviceMapping{
 					{
@@ -92,6 +96,79 @@ var _ = Describe("NodeTemplateUtils", func() {
 				},
 				UserData:           aws.String("userdata-test-1"),
 				DetailedMonitoring: aws.Bool(false),
+				CloudWatchAgent: &v1beta1.CloudWatchAgent{
+					ConfigSSMParameter: aws.String("AmazonCloudWatch-linux"),
+				},
+				DomainJoin: &v1beta1.DomainJoin{
+					SSMDocument:           aws.String("custom-domain-join"),
+					SSMDocumentParameters: map[string]string{"ouPath": "OU=Nodes"},
+				},
+				TrustedBoot: &v1beta1.TrustedBoot{
+					NitroTPM:   true,
+					SecureBoot: true,
+				},
+				Neuron: &v1beta1.Neuron{
+					DriverVersion: aws.String("2.14.5.0"),
+				},
+				CPUOptions: &v1beta1.CPUOptions{
+					CoreCount:      aws.Int64(4),
+					ThreadsPerCore: aws.Int64(1),
+				},
+				MaintenanceWindows: []v1beta1.MaintenanceWindow{
+					{
+						Days:     []string{"Saturday", "Sunday"},
+						Start:    "02:00",
+						Duration: metav1.Duration{Duration: 4 * time.Hour},
+					},
+				},
+				RegistrationTTL:               &metav1.Duration{Duration: 5 * time.Minute},
+				SecurityGroupDriftRemediation: lo.ToPtr(v1beta1.SecurityGroupDriftRemediationInPlace),
+				AMIDeprecationPolicy:          lo.ToPtr(v1beta1.AMIDeprecationPolicyExclude),
+				AMISortStrategy:               lo.ToPtr(v1beta1.AMISortStrategyPinned),
+				AMIVariant:                    lo.ToPtr(v1beta1.AMIVariantFIPS),
+				UbuntuStream:                  lo.ToPtr(v1beta1.UbuntuStreamPro),
+				AMIRollout: &v1beta1.AMIRollout{
+					AMISelectorTerms:    []v1beta1.AMISelectorTerm{{ID: "ami-candidate"}},
+					Weight:              10,
+					OriginalAMISelector: map[string]string{"aws::ids": "ami-candidate"},
+				},
+				MinimumNodeLifetime: &metav1.Duration{Duration: time.Hour},
+				ScheduledCapacityReservations: []v1beta1.ScheduledCapacityReservation{
+					{
+						InstanceType:     "m5.xlarge",
+						AvailabilityZone: "test-zone-1a",
+						InstanceCount:    10,
+						Days:             []string{"Monday", "Friday"},
+						Start:            "08:30",
+						Duration:         metav1.Duration{Duration: 2 * time.Hour},
+					},
+				},
+				CapacityTypeOverrides: []v1beta1.CapacityTypeOverride{
+					{
+						CapacityType:       "spot",
+						Tags:               map[string]string{"agent": "spot"},
+						UserData:           aws.String("#!/bin/bash\necho spot"),
+						DetailedMonitoring: aws.Bool(true),
+					},
+				},
+				InstanceTypeOverrides: []v1beta1.InstanceTypeOverride{
+					{
+						InstanceTypes:           []string{"p4d.24xlarge", "g5.*"},
+						Capacity:                v1.ResourceList{v1beta1.ResourceNVIDIAGPU: resource.MustParse("4")},
+						SystemReserved:          v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+						VMMemoryOverheadPercent: lo.ToPtr("0.01"),
+					},
+				},
+				KubeReserved: &v1beta1.KubeReserved{
+					Formula:          lo.ToPtr(v1beta1.KubeReservedFormulaCustom),
+					CPU:              []v1beta1.ReservedStep{{UpTo: lo.ToPtr(resource.MustParse("1")), Ratio: "0.06"}, {Ratio: "0.01"}},
+					Memory:           []v1beta1.ReservedStep{{Ratio: "0.1"}},
+					MemoryPerPod:     lo.ToPtr(resource.MustParse("11Mi")),
+					BaseMemory:       lo.ToPtr(resource.MustParse("255Mi")),
+					EphemeralStorage: lo.ToPtr(resource.MustParse("2Gi")),
+				},
+				ExcludedInstanceClasses: []string{v1beta1.InstanceClassMetal, v1beta1.InstanceClassOddSized},
+				InstanceSelectionMode:   lo.ToPtr(v1beta1.InstanceSelectionModeAttributeBased),
 				OriginalAMISelector: map[string]string{
 					"test-ami-key": "test-ami-value",
 				},
@@ -120,8 +197,9 @@ var _ = Describe("NodeTemplateUtils", func() {
 			},
 			AMIs: []v1beta1.AMI{
 				{
-					ID:   "test-ami-id",
-					Name: "test-ami-name",
+					ID:           "test-ami-id",
+					Name:         "test-ami-name",
+					CreationDate: "2023-01-01T12:00:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      v1.LabelArchStable,
@@ -131,8 +209,9 @@ var _ = Describe("NodeTemplateUtils", func() {
 					},
 				},
 				{
-					ID:   "test-ami-id2",
-					Name: "test-ami-name2",
+					ID:           "test-ami-id2",
+					Name:         "test-ami-name2",
+					CreationDate: "2023-01-02T12:00:00Z",
 					Requirements: []v1.NodeSelectorRequirement{
 						{
 							Key:      v1.LabelArchStable,
@@ -142,6 +221,27 @@ var _ = Describe("NodeTemplateUtils", func() {
 					},
 				},
 			},
+			PreviousAMIs: []v1beta1.AMI{
+				{
+					ID:           "test-ami-id3",
+					Name:         "test-ami-name3",
+					CreationDate: "2022-12-01T12:00:00Z",
+					Requirements: []v1.NodeSelectorRequirement{
+						{
+							Key:      v1.LabelArchStable,
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{"amd64"},
+						},
+					},
+				},
+			},
+			ExcludedInstanceTypes: []v1beta1.ExcludedInstanceType{
+				{
+					Name:          "m5.large",
+					ExcludedUntil: metav1.NewTime(time.Date(2023, 1, 2, 13, 0, 0, 0, time.UTC)),
+					Reason:        "node didn't register within 15m0s",
+				},
+			},
 		}
 	})
 	It("should convert a NodeClass to an AWSNodeTemplate", func() {
@@ -162,6 +262,58 @@ var _ = Describe("NodeTemplateUtils", func() {
 		Expect(nodeTemplate.Spec.UserData).To(Equal(nodeClass.Spec.UserData))
 		Expect(nodeTemplate.Spec.Tags).To(Equal(nodeClass.Spec.Tags))
 		Expect(nodeTemplate.Spec.DetailedMonitoring).To(Equal(nodeClass.Spec.DetailedMonitoring))
+		Expect(nodeTemplate.Spec.CloudWatchAgent.Type).To(Equal(nodeClass.Spec.CloudWatchAgent.Type))
+		Expect(nodeTemplate.Spec.CloudWatchAgent.Config).To(Equal(nodeClass.Spec.CloudWatchAgent.Config))
+		Expect(nodeTemplate.Spec.CloudWatchAgent.ConfigSSMParameter).To(Equal(nodeClass.Spec.CloudWatchAgent.ConfigSSMParameter))
+		Expect(nodeTemplate.Spec.DomainJoin.SSMDocument).To(Equal(nodeClass.Spec.DomainJoin.SSMDocument))
+		Expect(nodeTemplate.Spec.DomainJoin.SSMDocumentParameters).To(Equal(nodeClass.Spec.DomainJoin.SSMDocumentParameters))
+		Expect(nodeTemplate.Spec.TrustedBoot.NitroTPM).To(Equal(nodeClass.Spec.TrustedBoot.NitroTPM))
+		Expect(nodeTemplate.Spec.TrustedBoot.SecureBoot).To(Equal(nodeClass.Spec.TrustedBoot.SecureBoot))
+		Expect(nodeTemplate.Spec.Neuron.DriverVersion).To(Equal(nodeClass.Spec.Neuron.DriverVersion))
+		Expect(nodeTemplate.Spec.CPUOptions.CoreCount).To(Equal(nodeClass.Spec.CPUOptions.CoreCount))
+		Expect(nodeTemplate.Spec.CPUOptions.ThreadsPerCore).To(Equal(nodeClass.Spec.CPUOptions.ThreadsPerCore))
+		Expect(nodeTemplate.Spec.MaintenanceWindows).To(HaveLen(1))
+		Expect(nodeTemplate.Spec.MaintenanceWindows[0].Days).To(Equal(nodeClass.Spec.MaintenanceWindows[0].Days))
+		Expect(nodeTemplate.Spec.MaintenanceWindows[0].Start).To(Equal(nodeClass.Spec.MaintenanceWindows[0].Start))
+		Expect(nodeTemplate.Spec.MaintenanceWindows[0].Duration).To(Equal(nodeClass.Spec.MaintenanceWindows[0].Duration))
+		Expect(nodeTemplate.Spec.RegistrationTTL).To(Equal(nodeClass.Spec.RegistrationTTL))
+		Expect(nodeTemplate.Spec.SecurityGroupDriftRemediation).To(Equal(nodeClass.Spec.SecurityGroupDriftRemediation))
+		Expect(nodeTemplate.Spec.AMIDeprecationPolicy).To(Equal(nodeClass.Spec.AMIDeprecationPolicy))
+		Expect(nodeTemplate.Spec.AMISortStrategy).To(Equal(nodeClass.Spec.AMISortStrategy))
+		Expect(nodeTemplate.Spec.AMIVariant).To(Equal(nodeClass.Spec.AMIVariant))
+		Expect(nodeTemplate.Spec.UbuntuStream).To(Equal(nodeClass.Spec.UbuntuStream))
+		Expect(nodeTemplate.Spec.AMIRollout.AMISelector).To(Equal(nodeClass.Spec.AMIRollout.OriginalAMISelector))
+		Expect(nodeTemplate.Spec.AMIRollout.Weight).To(Equal(nodeClass.Spec.AMIRollout.Weight))
+		Expect(nodeTemplate.Spec.MinimumNodeLifetime).To(Equal(nodeClass.Spec.MinimumNodeLifetime))
+		Expect(nodeTemplate.Spec.ScheduledCapacityReservations).To(HaveLen(1))
+		Expect(nodeTemplate.Spec.ScheduledCapacityReservations[0].InstanceType).To(Equal(nodeClass.Spec.ScheduledCapacityReservations[0].InstanceType))
+		Expect(nodeTemplate.Spec.ScheduledCapacityReservations[0].AvailabilityZone).To(Equal(nodeClass.Spec.ScheduledCapacityReservations[0].AvailabilityZone))
+		Expect(nodeTemplate.Spec.ScheduledCapacityReservations[0].InstanceCount).To(Equal(nodeClass.Spec.ScheduledCapacityReservations[0].InstanceCount))
+		Expect(nodeTemplate.Spec.ScheduledCapacityReservations[0].Days).To(Equal(nodeClass.Spec.ScheduledCapacityReservations[0].Days))
+		Expect(nodeTemplate.Spec.ScheduledCapacityReservations[0].Start).To(Equal(nodeClass.Spec.ScheduledCapacityReservations[0].Start))
+		Expect(nodeTemplate.Spec.ScheduledCapacityReservations[0].Duration).To(Equal(nodeClass.Spec.ScheduledCapacityReservations[0].Duration))
+		Expect(nodeTemplate.Spec.CapacityTypeOverrides).To(HaveLen(1))
+		Expect(nodeTemplate.Spec.CapacityTypeOverrides[0].CapacityType).To(Equal(nodeClass.Spec.CapacityTypeOverrides[0].CapacityType))
+		Expect(nodeTemplate.Spec.CapacityTypeOverrides[0].Tags).To(Equal(nodeClass.Spec.CapacityTypeOverrides[0].Tags))
+		Expect(nodeTemplate.Spec.CapacityTypeOverrides[0].UserData).To(Equal(nodeClass.Spec.CapacityTypeOverrides[0].UserData))
+		Expect(nodeTemplate.Spec.CapacityTypeOverrides[0].DetailedMonitoring).To(Equal(nodeClass.Spec.CapacityTypeOverrides[0].DetailedMonitoring))
+		Expect(nodeTemplate.Spec.InstanceTypeOverrides).To(HaveLen(1))
+		Expect(nodeTemplate.Spec.InstanceTypeOverrides[0].InstanceTypes).To(Equal(nodeClass.Spec.InstanceTypeOverrides[0].InstanceTypes))
+		Expect(nodeTemplate.Spec.InstanceTypeOverrides[0].Capacity).To(Equal(nodeClass.Spec.InstanceTypeOverrides[0].Capacity))
+		Expect(nodeTemplate.Spec.InstanceTypeOverrides[0].SystemReserved).To(Equal(nodeClass.Spec.InstanceTypeOverrides[0].SystemReserved))
+		Expect(nodeTemplate.Spec.InstanceTypeOverrides[0].VMMemoryOverheadPercent).To(Equal(nodeClass.Spec.InstanceTypeOverrides[0].VMMemoryOverheadPercent))
+		Expect(nodeTemplate.Spec.KubeReserved.Formula).To(Equal(nodeClass.Spec.KubeReserved.Formula))
+		Expect(nodeTemplate.Spec.KubeReserved.CPU).To(HaveLen(2))
+		Expect(nodeTemplate.Spec.KubeReserved.CPU[0].UpTo).To(Equal(nodeClass.Spec.KubeReserved.CPU[0].UpTo))
+		Expect(nodeTemplate.Spec.KubeReserved.CPU[0].Ratio).To(Equal(nodeClass.Spec.KubeReserved.CPU[0].Ratio))
+		Expect(nodeTemplate.Spec.KubeReserved.CPU[1].UpTo).To(BeNil())
+		Expect(nodeTemplate.Spec.KubeReserved.Memory).To(HaveLen(1))
+		Expect(nodeTemplate.Spec.KubeReserved.Memory[0].Ratio).To(Equal(nodeClass.Spec.KubeReserved.Memory[0].Ratio))
+		Expect(nodeTemplate.Spec.KubeReserved.MemoryPerPod).To(Equal(nodeClass.Spec.KubeReserved.MemoryPerPod))
+		Expect(nodeTemplate.Spec.KubeReserved.BaseMemory).To(Equal(nodeClass.Spec.KubeReserved.BaseMemory))
+		Expect(nodeTemplate.Spec.KubeReserved.EphemeralStorage).To(Equal(nodeClass.Spec.KubeReserved.EphemeralStorage))
+		Expect(nodeTemplate.Spec.ExcludedInstanceClasses).To(Equal(nodeClass.Spec.ExcludedInstanceClasses))
+		Expect(nodeTemplate.Spec.InstanceSelectionMode).To(Equal(nodeClass.Spec.InstanceSelectionMode))
 		Expect(nodeTemplate.Spec.LaunchTemplateName).To(Equal(nodeClass.Spec.LaunchTemplateName))
 
 		ExpectBlockDeviceMappingsEqual(nodeTemplate.Spec.BlockDeviceMappings, nodeClass.Spec.BlockDeviceMappings)
@@ -169,5 +321,7 @@ var _ = Describe("NodeTemplateUtils", func() {
 		ExpectSubnetStatusEqual(nodeTemplate.Status.Subnets, nodeClass.Status.Subnets)
 		ExpectSecurityGroupStatusEqual(nodeTemplate.Status.SecurityGroups, nodeClass.Status.SecurityGroups)
 		ExpectAMIStatusEqual(nodeTemplate.Status.AMIs, nodeClass.Status.AMIs)
+		ExpectAMIStatusEqual(nodeTemplate.Status.PreviousAMIs, nodeClass.Status.PreviousAMIs)
+		ExpectExcludedInstanceTypesStatusEqual(nodeTemplate.Status.ExcludedInstanceTypes, nodeClass.Status.ExcludedInstanceTypes)
 	})
 })
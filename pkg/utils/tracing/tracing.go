@@ -0,0 +1,89 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter/pkg/utils/project"
+)
+
+const instrumentationName = "github.com/aws/karpenter"
+
+// Start creates a span for an operation on the provisioning path. Spans are dropped unless tracing has been
+// enabled with Enable.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// StartLinked creates a span for an operation that is made on behalf of the operations in each of the linked contexts,
+// such as a batched API call
+func StartLinked(ctx context.Context, name string, linked []context.Context, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	links := make([]trace.Link, 0, len(linked))
+	for _, l := range linked {
+		links = append(links, trace.LinkFromContext(l))
+	}
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attributes...), trace.WithLinks(links...))
+}
+
+// End ends the span, recording the error on it if the operation failed
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Enable exports spans over OTLP/gRPC if an OTLP endpoint is configured through the standard OpenTelemetry
+// environment variables (e.g. OTEL_EXPORTER_OTLP_ENDPOINT). The exporter is shut down when the context is done.
+func Enable(ctx context.Context) error {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil
+	}
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return fmt.Errorf("creating otlp trace exporter, %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String("karpenter"),
+			semconv.ServiceVersionKey.String(project.Version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	go func() {
+		<-ctx.Done()
+		// the context is already done, so shutdown is given a fresh one to flush any remaining spans
+		if err := provider.Shutdown(context.Background()); err != nil {
+			logging.FromContext(ctx).Errorf("shutting down tracer provider, %s", err)
+		}
+	}()
+	logging.FromContext(ctx).Debugf("enabled tracing")
+	return nil
+}
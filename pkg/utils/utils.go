@@ -17,10 +17,13 @@ package utils
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 )
 
 var (
@@ -49,3 +52,39 @@ func MergeTags(tags ...map[string]string) []*ec2.Tag {
 		return &ec2.Tag{Key: aws.String(k), Value: aws.String(v)}
 	})
 }
+
+// Excluded returns whether the EC2 tags of a resource take it out of rotation, so that it isn't selected by any of the
+// selector terms that match it
+func Excluded(tags []*ec2.Tag) bool {
+	return lo.ContainsBy(tags, func(t *ec2.Tag) bool { return aws.StringValue(t.Key) == v1beta1.TagExclude })
+}
+
+// WildcardMatch returns whether the value matches the pattern of an EC2 filter value, in which * matches any sequence
+// of characters and ? matches any single character
+func WildcardMatch(pattern, value string) bool {
+	expr := strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern))
+	return regexp.MustCompile("^" + expr + "$").MatchString(value)
+}
+
+// SelectsTagKeys returns whether any of the filter sets selects resources by one of the tag keys, so that a resource
+// whose tags with these keys changed may have started or stopped matching them
+func SelectsTagKeys(filterSets [][]*ec2.Filter, keys []string) bool {
+	for _, filters := range filterSets {
+		for _, filter := range filters {
+			name := aws.StringValue(filter.Name)
+			switch {
+			case name == "tag-key":
+				if lo.ContainsBy(filter.Values, func(v *string) bool {
+					return lo.ContainsBy(keys, func(k string) bool { return WildcardMatch(aws.StringValue(v), k) })
+				}) {
+					return true
+				}
+			case strings.HasPrefix(name, "tag:"):
+				if lo.Contains(keys, strings.TrimPrefix(name, "tag:")) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
 	"github.com/aws/aws-sdk-go/service/fis"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -45,13 +46,15 @@ type Environment struct {
 	*common.Environment
 	Region string
 
-	STSAPI        *sts.STS
-	EC2API        *ec2.EC2
-	SSMAPI        *ssm.SSM
-	IAMAPI        *iam.IAM
-	FISAPI        *fis.FIS
-	EKSAPI        *eks.EKS
-	TimeStreamAPI timestreamwriteiface.TimestreamWriteAPI
+	STSAPI         *sts.STS
+	EC2API         *ec2.EC2
+	SSMAPI         *ssm.SSM
+	IAMAPI         *iam.IAM
+	FISAPI         *fis.FIS
+	EKSAPI         *eks.EKS
+	SQSAPI         *sqs.SQS
+	EventBridgeAPI *eventbridge.EventBridge
+	TimeStreamAPI  timestreamwriteiface.TimestreamWriteAPI
 
 	SQSProvider *interruption.SQSProvider
 }
@@ -72,14 +75,16 @@ func NewEnvironment(t *testing.T) *Environment {
 		Region:      *session.Config.Region,
 		Environment: env,
 
-		STSAPI:        sts.New(session),
-		EC2API:        ec2.New(session),
-		SSMAPI:        ssm.New(session),
-		IAMAPI:        iam.New(session),
-		FISAPI:        fis.New(session),
-		EKSAPI:        eks.New(session),
-		SQSProvider:   interruption.NewSQSProvider(sqs.New(session)),
-		TimeStreamAPI: GetTimeStreamAPI(session),
+		STSAPI:         sts.New(session),
+		EC2API:         ec2.New(session),
+		SSMAPI:         ssm.New(session),
+		IAMAPI:         iam.New(session),
+		FISAPI:         fis.New(session),
+		EKSAPI:         eks.New(session),
+		SQSAPI:         sqs.New(session),
+		EventBridgeAPI: eventbridge.New(session),
+		SQSProvider:    interruption.NewSQSProvider(sqs.New(session)),
+		TimeStreamAPI:  GetTimeStreamAPI(session),
 	}
 }
 
@@ -0,0 +1,247 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"knative.dev/pkg/ptr"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/test"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/scheduledchange"
+	awstest "github.com/aws/karpenter/pkg/test"
+	"github.com/aws/karpenter/pkg/utils"
+	awsenv "github.com/aws/karpenter/test/pkg/environment/aws"
+)
+
+const (
+	troubleshootingURL = "https://karpenter.sh/docs/troubleshooting/"
+	cloudformationURL  = "https://karpenter.sh/docs/reference/cloudformation/"
+)
+
+var env *awsenv.Environment
+
+func TestConformance(t *testing.T) {
+	RegisterFailHandler(Fail)
+	BeforeSuite(func() {
+		env = awsenv.NewEnvironment(t)
+	})
+	AfterSuite(func() {
+		env.Stop()
+	})
+	RunSpecs(t, "Conformance")
+}
+
+var _ = BeforeEach(func() { env.BeforeEach() })
+var _ = AfterEach(func() { env.Cleanup() })
+var _ = AfterEach(func() { env.AfterEach() })
+
+var _ = Describe("Conformance", Label("AWS"), func() {
+	var clusterName string
+	BeforeEach(func() {
+		clusterName = settings.FromContext(env.Context).ClusterName
+	})
+	Context("IAM", func() {
+		It("should allow the controller role to call the AWS APIs that Karpenter uses", func() {
+			pod := env.ExpectActiveKarpenterPod()
+			serviceAccount := &v1.ServiceAccount{}
+			Expect(env.Client.Get(env.Context, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Spec.ServiceAccountName}, serviceAccount)).To(Succeed())
+			roleARN, ok := serviceAccount.Annotations["eks.amazonaws.com/role-arn"]
+			if !ok {
+				Skip(fmt.Sprintf("skipping IAM simulation since service account %s/%s isn't annotated with an IRSA role", serviceAccount.Namespace, serviceAccount.Name))
+			}
+			// Karpenter's recommended policy scopes mutating actions to resources that are tagged for the cluster, so
+			// we simulate the calls with the same tags that Karpenter sends
+			contextEntries := lo.MapToSlice(map[string]string{
+				"aws:RequestedRegion": env.Region,
+				fmt.Sprintf("aws:RequestTag/kubernetes.io/cluster/%s", clusterName):  "owned",
+				fmt.Sprintf("aws:ResourceTag/kubernetes.io/cluster/%s", clusterName): "owned",
+				"aws:RequestTag/karpenter.sh/provisioner-name":                       "default",
+				"aws:ResourceTag/karpenter.sh/provisioner-name":                      "default",
+				"ec2:CreateAction": "CreateFleet",
+			}, func(k, v string) *iam.ContextEntry {
+				return &iam.ContextEntry{ContextKeyName: aws.String(k), ContextKeyType: aws.String(iam.ContextKeyTypeEnumString), ContextKeyValues: aws.StringSlice([]string{v})}
+			})
+			actions := []string{
+				"ec2:CreateFleet", "ec2:CreateLaunchTemplate", "ec2:CreateTags", "ec2:DeleteLaunchTemplate", "ec2:RunInstances", "ec2:TerminateInstances",
+				"ec2:DescribeAvailabilityZones", "ec2:DescribeImages", "ec2:DescribeInstances", "ec2:DescribeInstanceTypeOfferings", "ec2:DescribeInstanceTypes",
+				"ec2:DescribeLaunchTemplates", "ec2:DescribeSecurityGroups", "ec2:DescribeSpotPriceHistory", "ec2:DescribeSubnets",
+				"pricing:GetProducts", "ssm:GetParameter", "eks:DescribeCluster",
+			}
+			if settings.FromContext(env.Context).InterruptionQueueName != "" {
+				actions = append(actions, "sqs:DeleteMessage", "sqs:GetQueueAttributes", "sqs:GetQueueUrl", "sqs:ReceiveMessage")
+			}
+			var denied []string
+			Expect(env.IAMAPI.SimulatePrincipalPolicyPagesWithContext(env.Context, &iam.SimulatePrincipalPolicyInput{
+				PolicySourceArn: aws.String(roleARN),
+				ActionNames:     aws.StringSlice(actions),
+				ContextEntries:  contextEntries,
+			}, func(out *iam.SimulatePolicyResponse, _ bool) bool {
+				for _, result := range out.EvaluationResults {
+					if aws.StringValue(result.EvalDecision) != iam.PolicyEvaluationDecisionTypeAllowed {
+						denied = append(denied, fmt.Sprintf("%s (%s)", aws.StringValue(result.EvalActionName), aws.StringValue(result.EvalDecision)))
+					}
+				}
+				return true
+			})).To(Succeed())
+			Expect(denied).To(BeEmpty(), "controller role %s is missing permissions, add them to its policy as described in %s", roleARN, cloudformationURL)
+		})
+	})
+	Context("Interruption Queue", func() {
+		var queueURL string
+		BeforeEach(func() {
+			if settings.FromContext(env.Context).InterruptionQueueName == "" {
+				Skip("skipping interruption queue checks since aws.interruptionQueueName isn't set")
+			}
+			var err error
+			queueURL, err = env.SQSProvider.DiscoverQueueURL(env.Context)
+			Expect(err).ToNot(HaveOccurred(), "interruption queue %q can't be found, create it as described in %s", settings.FromContext(env.Context).InterruptionQueueName, cloudformationURL)
+		})
+		It("should route EC2 and AWS Health events to the interruption queue", func() {
+			out, err := env.SQSAPI.GetQueueAttributesWithContext(env.Context, &sqs.GetQueueAttributesInput{
+				QueueUrl:       aws.String(queueURL),
+				AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameQueueArn, sqs.QueueAttributeNamePolicy}),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(aws.StringValue(out.Attributes[sqs.QueueAttributeNamePolicy])).To(ContainSubstring("events.amazonaws.com"),
+				"interruption queue policy doesn't allow EventBridge to send messages, see %s", cloudformationURL)
+
+			var detailTypes []string
+			rules, err := env.EventBridgeAPI.ListRuleNamesByTargetWithContext(env.Context, &eventbridge.ListRuleNamesByTargetInput{
+				TargetArn: out.Attributes[sqs.QueueAttributeNameQueueArn],
+			})
+			Expect(err).ToNot(HaveOccurred())
+			for _, name := range rules.RuleNames {
+				rule, err := env.EventBridgeAPI.DescribeRuleWithContext(env.Context, &eventbridge.DescribeRuleInput{Name: name})
+				Expect(err).ToNot(HaveOccurred())
+				pattern := struct {
+					DetailType []string `json:"detail-type"`
+				}{}
+				Expect(json.Unmarshal([]byte(aws.StringValue(rule.EventPattern)), &pattern)).To(Succeed())
+				if aws.StringValue(rule.State) == eventbridge.RuleStateEnabled {
+					detailTypes = append(detailTypes, pattern.DetailType...)
+				}
+			}
+			for _, detailType := range []string{
+				"EC2 Spot Instance Interruption Warning",
+				"EC2 Instance Rebalance Recommendation",
+				"EC2 Instance State-change Notification",
+				"AWS Health Event",
+			} {
+				Expect(detailTypes).To(ContainElement(detailType), "no enabled EventBridge rule sends %q events to the interruption queue, see %s", detailType, cloudformationURL)
+			}
+		})
+		It("should consume messages from the interruption queue", func() {
+			env.ExpectMessagesCreated(scheduledchange.Message{
+				Metadata: messages.Metadata{
+					Version:    "0",
+					Account:    env.ExpectAccountID(),
+					DetailType: "AWS Health Event",
+					ID:         string(uuid.NewUUID()),
+					Region:     env.Region,
+					Source:     "aws.health",
+					Time:       time.Now(),
+				},
+				Detail: scheduledchange.Detail{
+					Service:           "EC2",
+					EventTypeCategory: "scheduledChange",
+					AffectedEntities:  []scheduledchange.AffectedEntity{{EntityValue: "i-00000000000000000"}},
+				},
+			})
+			Eventually(func(g Gomega) {
+				out, err := env.SQSAPI.GetQueueAttributesWithContext(env.Context, &sqs.GetQueueAttributesInput{
+					QueueUrl:       aws.String(queueURL),
+					AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameApproximateNumberOfMessages, sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible}),
+				})
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(out.Attributes).To(HaveKeyWithValue(sqs.QueueAttributeNameApproximateNumberOfMessages, "0"))
+				g.Expect(out.Attributes).To(HaveKeyWithValue(sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible, "0"))
+			}).WithTimeout(time.Minute).Should(Succeed(), "Karpenter didn't consume messages from the interruption queue, check the controller's sqs permissions and logs, see %s", troubleshootingURL)
+		})
+	})
+	Context("Discovery", func() {
+		It("should discover subnets tagged for the cluster", func() {
+			subnets := env.GetSubnets(map[string]string{"karpenter.sh/discovery": clusterName})
+			Expect(subnets).ToNot(BeEmpty(), "no subnets are tagged with karpenter.sh/discovery=%s, tag the subnets that nodes should launch into", clusterName)
+			Expect(len(subnets)).To(BeNumerically(">", 1), "subnets tagged with karpenter.sh/discovery=%s are all in zone %v, tag subnets in more zones to improve availability", clusterName, lo.Keys(subnets))
+		})
+		It("should discover security groups tagged for the cluster", func() {
+			Expect(env.GetSecurityGroups(map[string]string{"karpenter.sh/discovery": clusterName})).ToNot(BeEmpty(),
+				"no security groups are tagged with karpenter.sh/discovery=%s, tag the security groups that nodes should use", clusterName)
+		})
+	})
+	Context("Launch", func() {
+		It("should launch a node that joins the cluster and terminate its instance when the node is deleted", func() {
+			nodeTemplate := awstest.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{
+				SecurityGroupSelector: map[string]string{"karpenter.sh/discovery": clusterName},
+				SubnetSelector:        map[string]string{"karpenter.sh/discovery": clusterName},
+			}})
+			provisioner := test.Provisioner(test.ProvisionerOptions{
+				Requirements: []v1.NodeSelectorRequirement{
+					{
+						Key:      v1alpha5.LabelCapacityType,
+						Operator: v1.NodeSelectorOpIn,
+						Values:   []string{v1alpha5.CapacityTypeOnDemand},
+					},
+				},
+				ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+			})
+			dep := test.Deployment(test.DeploymentOptions{
+				Replicas: 1,
+				PodOptions: test.PodOptions{
+					ObjectMeta:                    metav1.ObjectMeta{Labels: map[string]string{"app": "conformance"}},
+					TerminationGracePeriodSeconds: ptr.Int64(0),
+				},
+			})
+			selector := labels.SelectorFromSet(dep.Spec.Selector.MatchLabels)
+			env.ExpectCreated(nodeTemplate, provisioner, dep)
+
+			By("launching a node for a pending pod")
+			env.EventuallyExpectCreatedMachineCount("==", 1)
+			env.EventuallyExpectHealthyPodCountWithTimeout(10*time.Minute, selector, 1)
+			node := env.ExpectCreatedNodeCount("==", 1)[0]
+			instanceID, err := utils.ParseInstanceID(node.Spec.ProviderID)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("terminating the instance when the node is deleted")
+			env.ExpectDeleted(node)
+			env.EventuallyExpectNotFound(node)
+			Eventually(func(g Gomega) {
+				state := aws.StringValue(env.GetInstanceByID(instanceID).State.Name)
+				g.Expect(state).To(BeElementOf(ec2.InstanceStateNameShuttingDown, ec2.InstanceStateNameTerminated))
+			}).Should(Succeed(), "instance %s wasn't terminated, check the controller's ec2:TerminateInstances permission and logs, see %s", instanceID, troubleshootingURL)
+		})
+	})
+})
@@ -79,15 +79,21 @@ var _ = Describe("Scheduling", Ordered, ContinueOnFailure, func() {
 			v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
 			v1.LabelInstanceTypeStable:       "c5.large",
 			// Well Known to AWS
-			v1alpha1.LabelInstanceHypervisor:       "nitro",
-			v1alpha1.LabelInstanceCategory:         "c",
-			v1alpha1.LabelInstanceGeneration:       "5",
-			v1alpha1.LabelInstanceFamily:           "c5",
-			v1alpha1.LabelInstanceSize:             "large",
-			v1alpha1.LabelInstanceCPU:              "2",
-			v1alpha1.LabelInstanceMemory:           "4096",
-			v1alpha1.LabelInstanceNetworkBandwidth: "750",
-			v1alpha1.LabelInstancePods:             "29",
+			v1alpha1.LabelInstanceHypervisor:              "nitro",
+			v1alpha1.LabelInstanceCategory:                "c",
+			v1alpha1.LabelInstanceGeneration:              "5",
+			v1alpha1.LabelInstanceFamily:                  "c5",
+			v1alpha1.LabelInstanceSize:                    "large",
+			v1alpha1.LabelInstanceCPU:                     "2",
+			v1alpha1.LabelInstanceMemory:                  "4096",
+			v1alpha1.LabelInstanceNetworkBandwidth:        "750",
+			v1alpha1.LabelInstanceEBSBandwidth:            "650",
+			v1alpha1.LabelInstanceEBSIOPS:                 "4000",
+			v1alpha1.LabelRootVolumeIOPS:                  "3000",
+			v1alpha1.LabelInstancePods:                    "29",
+			v1alpha1.LabelInstanceEBSNVMeSupported:        "true",
+			v1alpha1.LabelInstanceEFASupported:            "false",
+			v1alpha1.LabelInstanceDedicatedHostsSupported: "true",
 		}
 		selectors.Insert(lo.Keys(nodeSelector)...) // Add node selector keys to selectors used in testing to ensure we test all labels
 		requirements := lo.MapToSlice(nodeSelector, func(key string, value string) v1.NodeSelectorRequirement {
@@ -124,6 +130,28 @@ var _ = Describe("Scheduling", Ordered, ContinueOnFailure, func() {
 		env.EventuallyExpectHealthyPodCount(labels.SelectorFromSet(deployment.Spec.Selector.MatchLabels), int(*deployment.Spec.Replicas))
 		env.ExpectCreatedNodeCount("==", 1)
 	})
+	It("should support well-known labels for instance store volumes", func() {
+		selectors.Insert(v1alpha1.LabelInstanceStoreVolumeCount, v1alpha1.LabelInstanceStoreVolumeSize) // Add node selector keys to selectors used in testing to ensure we test all labels
+		requirements := []v1.NodeSelectorRequirement{
+			{
+				Key:      v1alpha1.LabelInstanceStoreVolumeCount,
+				Operator: v1.NodeSelectorOpGt,
+				Values:   []string{"1"},
+			},
+			{
+				Key:      v1alpha1.LabelInstanceStoreVolumeSize,
+				Operator: v1.NodeSelectorOpGt,
+				Values:   []string{"100"},
+			},
+		}
+		deployment := test.Deployment(test.DeploymentOptions{Replicas: 1, PodOptions: test.PodOptions{
+			NodePreferences:  requirements,
+			NodeRequirements: requirements,
+		}})
+		env.ExpectCreated(provisioner, provider, deployment)
+		env.EventuallyExpectHealthyPodCount(labels.SelectorFromSet(deployment.Spec.Selector.MatchLabels), int(*deployment.Spec.Replicas))
+		env.ExpectCreatedNodeCount("==", 1)
+	})
 	It("should support well-known labels for encryption in transit", func() {
 		selectors.Insert(v1alpha1.LabelInstanceEncryptionInTransitSupported) // Add node selector keys to selectors used in testing to ensure we test all labels
 		deployment := test.Deployment(test.DeploymentOptions{Replicas: 1, PodOptions: test.PodOptions{
@@ -146,6 +174,21 @@ var _ = Describe("Scheduling", Ordered, ContinueOnFailure, func() {
 		env.EventuallyExpectHealthyPodCount(labels.SelectorFromSet(deployment.Spec.Selector.MatchLabels), int(*deployment.Spec.Replicas))
 		env.ExpectCreatedNodeCount("==", 1)
 	})
+	It("should launch pods that don't require a capacity reservation into shared capacity", func() {
+		selectors.Insert(v1alpha1.LabelCapacityReservationID) // Add node selector keys to selectors used in testing to ensure we test all labels
+		deployment := test.Deployment(test.DeploymentOptions{Replicas: 1, PodOptions: test.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{
+				{
+					Key:      v1alpha1.LabelCapacityReservationID,
+					Operator: v1.NodeSelectorOpDoesNotExist,
+				},
+			},
+		}})
+		env.ExpectCreated(provisioner, provider, deployment)
+		env.EventuallyExpectHealthyPodCount(labels.SelectorFromSet(deployment.Spec.Selector.MatchLabels), int(*deployment.Spec.Replicas))
+		node := env.ExpectCreatedNodeCount("==", 1)[0]
+		Expect(node.Labels).ToNot(HaveKey(v1alpha1.LabelCapacityReservationID))
+	})
 	It("should support well-known deprecated labels", func() {
 		nodeSelector := map[string]string{
 			// Deprecated Labels
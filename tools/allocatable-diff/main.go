@@ -83,7 +83,11 @@ func main() {
 		op.AMIProvider,
 		op.SecurityGroupProvider,
 		op.SubnetProvider,
+		op.WarmUp,
+		op.Clock,
 	)
+	// nothing runs the warm-up controller here, so don't wait on it
+	op.WarmUp.MarkDone()
 	raw := &runtime.RawExtension{}
 	lo.Must0(raw.UnmarshalJSON(lo.Must(json.Marshal(&v1alpha1.AWS{
 		SubnetSelector: map[string]string{
@@ -0,0 +1,178 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecloudprovider "github.com/aws/karpenter-core/pkg/cloudprovider"
+	coreoperator "github.com/aws/karpenter-core/pkg/operator"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/operator"
+	"github.com/aws/karpenter/pkg/providers/amifamily"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+var provisionerName string
+var nodePoolName string
+var namespace string
+var top int
+
+func init() {
+	flag.StringVar(&provisionerName, "provisioner", "", "name of the Provisioner to explain")
+	flag.StringVar(&nodePoolName, "nodepool", "", "name of the NodePool to explain")
+	flag.StringVar(&namespace, "namespace", "karpenter", "namespace that Karpenter's global settings ConfigMap is installed in")
+	flag.IntVar(&top, "top", 10, "number of the cheapest instance offerings to print")
+	flag.Parse()
+}
+
+// offering is an instance type offering that a NodePool could launch, along with the AMI that it would launch with
+type offering struct {
+	instanceType string
+	zone         string
+	capacityType string
+	price        float64
+	ami          string
+}
+
+func main() {
+	if (provisionerName == "") == (nodePoolName == "") {
+		log.Fatalf("exactly one of --provisioner or --nodepool is required")
+	}
+	lo.Must0(apis.AddToScheme(scheme.Scheme))
+	restConfig := config.GetConfigOrDie()
+	kubeClient := lo.Must(client.New(restConfig, client.Options{Scheme: scheme.Scheme}))
+	ctx := context.Background()
+
+	cm := &v1.ConfigMap{}
+	lo.Must0(kubeClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: (&settings.Settings{}).ConfigMap()}, cm))
+	ctx = lo.Must((&settings.Settings{}).Inject(ctx, cm))
+
+	nodePool, nodeClass := resolve(ctx, kubeClient)
+	ctx, op := operator.NewOperator(ctx, &coreoperator.Operator{
+		Manager:             lo.Must(manager.New(restConfig, manager.Options{})),
+		KubernetesInterface: kubernetes.NewForConfigOrDie(restConfig),
+	})
+	if !settings.FromContext(ctx).IsolatedVPC {
+		if err := op.PricingProvider.UpdateOnDemandPricing(ctx); err != nil {
+			log.Printf("WARNING: using static on-demand prices, %s", err)
+		}
+		if err := op.PricingProvider.UpdateSpotPricing(ctx); err != nil {
+			log.Printf("WARNING: using static spot prices, %s", err)
+		}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	amis := lo.Must(op.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{}))
+	fmt.Fprintf(w, "AMIs (%d):\n", len(amis))
+	for _, ami := range amis {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", ami.AmiID, ami.Name, ami.CreationDate, ami.Requirements)
+	}
+	subnets := lo.Must(op.SubnetProvider.List(ctx, nodeClass))
+	fmt.Fprintf(w, "Subnets (%d):\n", len(subnets))
+	for _, subnet := range subnets {
+		fmt.Fprintf(w, "  %s\t%s\t%d available IPs\n", aws.StringValue(subnet.SubnetId), aws.StringValue(subnet.AvailabilityZone), aws.Int64Value(subnet.AvailableIpAddressCount))
+	}
+	securityGroups := lo.Must(op.SecurityGroupProvider.List(ctx, nodeClass))
+	fmt.Fprintf(w, "Security Groups (%d):\n", len(securityGroups))
+	for _, securityGroup := range securityGroups {
+		fmt.Fprintf(w, "  %s\t%s\n", aws.StringValue(securityGroup.GroupId), aws.StringValue(securityGroup.GroupName))
+	}
+	fmt.Fprintf(w, "Instance Profile:\n  %s\n", lo.Ternary(nodeClass.Spec.InstanceProfile != nil, aws.StringValue(nodeClass.Spec.InstanceProfile), settings.FromContext(ctx).DefaultInstanceProfile))
+
+	instanceTypes := lo.Must(op.InstanceTypesProvider.List(ctx, nodePool.Spec.Template.Spec.KubeletConfiguration, nodeClass))
+	offerings, compatible := resolveOfferings(nodePool, instanceTypes, amis)
+	fmt.Fprintf(w, "Instance Types:\n  %d discovered, %d compatible with requirements, %d offerings available\n", len(instanceTypes), compatible, len(offerings))
+	fmt.Fprintf(w, "Cheapest Offerings (%d):\n", lo.Min([]int{top, len(offerings)}))
+	for _, o := range lo.Slice(offerings, 0, top) {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t$%.4f/hour\t%s\n", o.instanceType, o.zone, o.capacityType, o.price, o.ami)
+	}
+}
+
+// resolve retrieves the NodePool (or Provisioner) to explain and the NodeClass (or AWSNodeTemplate) that it references
+func resolve(ctx context.Context, kubeClient client.Client) (*corev1beta1.NodePool, *v1beta1.NodeClass) {
+	var nodePool *corev1beta1.NodePool
+	if provisionerName != "" {
+		provisioner := &v1alpha5.Provisioner{}
+		lo.Must0(kubeClient.Get(ctx, types.NamespacedName{Name: provisionerName}, provisioner))
+		nodePool = nodepoolutil.New(provisioner)
+	} else {
+		nodePool = &corev1beta1.NodePool{}
+		lo.Must0(kubeClient.Get(ctx, types.NamespacedName{Name: nodePoolName}, nodePool))
+	}
+	if nodePool.Spec.Template.Spec.NodeClass == nil {
+		log.Fatalf("%s does not reference a node class", lo.Ternary(nodePool.IsProvisioner, "provisioner", "nodepool"))
+	}
+	return nodePool, lo.Must(nodeclassutil.Get(ctx, kubeClient, nodeclassutil.Key{
+		Name:           nodePool.Spec.Template.Spec.NodeClass.Name,
+		IsNodeTemplate: nodePool.IsProvisioner,
+	}))
+}
+
+// resolveOfferings returns the available offerings of the instance types that are compatible with the NodePool's
+// requirements sorted by price, along with the number of compatible instance types
+func resolveOfferings(nodePool *corev1beta1.NodePool, instanceTypes []*corecloudprovider.InstanceType, amis amifamily.AMIs) ([]offering, int) {
+	reqs := scheduling.NewNodeSelectorRequirements(nodePool.Spec.Template.Spec.Requirements...)
+	var offerings []offering
+	compatible := 0
+	for _, it := range instanceTypes {
+		if reqs.Compatible(it.Requirements) != nil {
+			continue
+		}
+		compatible++
+		ami, ok := lo.Find(amis, func(ami amifamily.AMI) bool { return it.Requirements.Compatible(ami.Requirements) == nil })
+		for _, o := range it.Offerings.Requirements(reqs).Available() {
+			offerings = append(offerings, offering{
+				instanceType: it.Name,
+				zone:         o.Zone,
+				capacityType: o.CapacityType,
+				price:        o.Price,
+				ami:          lo.Ternary(ok, ami.AmiID, "no compatible AMI"),
+			})
+		}
+	}
+	sort.SliceStable(offerings, func(i, j int) bool {
+		if offerings[i].price != offerings[j].price {
+			return offerings[i].price < offerings[j].price
+		}
+		return strings.Compare(offerings[i].instanceType, offerings[j].instanceType) < 0
+	})
+	return offerings, compatible
+}
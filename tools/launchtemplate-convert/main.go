@@ -0,0 +1,150 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/samber/lo"
+	"sigs.k8s.io/yaml"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+var name string
+var launchTemplateID string
+var launchTemplateName string
+var launchTemplateVersion string
+var launchConfigurationName string
+var autoScalingGroupName string
+
+func init() {
+	flag.StringVar(&name, "name", "", "name of the generated NodeClass, defaults to the name of the converted resource")
+	flag.StringVar(&launchTemplateID, "launch-template-id", "", "id of the launch template to convert")
+	flag.StringVar(&launchTemplateName, "launch-template-name", "", "name of the launch template to convert")
+	flag.StringVar(&launchTemplateVersion, "launch-template-version", "$Default", "version of the launch template to convert")
+	flag.StringVar(&launchConfigurationName, "launch-configuration-name", "", "name of the launch configuration to convert")
+	flag.StringVar(&autoScalingGroupName, "auto-scaling-group-name", "", "name of the auto scaling group whose launch template or launch configuration should be converted")
+	flag.Parse()
+}
+
+func main() {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+	ec2api := ec2.New(sess)
+	autoscalingapi := autoscaling.New(sess)
+
+	var asg *autoscaling.Group
+	if autoScalingGroupName != "" {
+		out := lo.Must(autoscalingapi.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: aws.StringSlice([]string{autoScalingGroupName}),
+		}))
+		if len(out.AutoScalingGroups) == 0 {
+			log.Fatalf("auto scaling group %q not found", autoScalingGroupName)
+		}
+		asg = out.AutoScalingGroups[0]
+		spec := asg.LaunchTemplate
+		if spec == nil && asg.MixedInstancesPolicy != nil && asg.MixedInstancesPolicy.LaunchTemplate != nil {
+			spec = asg.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+		}
+		if spec != nil {
+			launchTemplateID, launchTemplateName = aws.StringValue(spec.LaunchTemplateId), aws.StringValue(spec.LaunchTemplateName)
+			launchTemplateVersion = lo.Ternary(aws.StringValue(spec.Version) != "", aws.StringValue(spec.Version), launchTemplateVersion)
+		}
+		launchConfigurationName = aws.StringValue(asg.LaunchConfigurationName)
+	}
+
+	var nodeClass *v1beta1.NodeClass
+	var instanceProfile string
+	switch {
+	case launchTemplateID != "" || launchTemplateName != "":
+		input := &ec2.DescribeLaunchTemplateVersionsInput{Versions: aws.StringSlice([]string{launchTemplateVersion})}
+		if launchTemplateID != "" {
+			input.LaunchTemplateId = aws.String(launchTemplateID)
+		} else {
+			input.LaunchTemplateName = aws.String(launchTemplateName)
+		}
+		out := lo.Must(ec2api.DescribeLaunchTemplateVersions(input))
+		if len(out.LaunchTemplateVersions) == 0 {
+			log.Fatalf("launch template version %q not found", launchTemplateVersion)
+		}
+		version := out.LaunchTemplateVersions[0]
+		nodeClass = lo.Must(nodeclassutil.NewFromLaunchTemplate(nodeClassName(aws.StringValue(version.LaunchTemplateName)), version.LaunchTemplateData))
+		if profile := version.LaunchTemplateData.IamInstanceProfile; profile != nil {
+			instanceProfile = lo.Ternary(aws.StringValue(profile.Name) != "", aws.StringValue(profile.Name), aws.StringValue(profile.Arn))
+		}
+	case launchConfigurationName != "":
+		out := lo.Must(autoscalingapi.DescribeLaunchConfigurations(&autoscaling.DescribeLaunchConfigurationsInput{
+			LaunchConfigurationNames: aws.StringSlice([]string{launchConfigurationName}),
+		}))
+		if len(out.LaunchConfigurations) == 0 {
+			log.Fatalf("launch configuration %q not found", launchConfigurationName)
+		}
+		nodeClass = lo.Must(nodeclassutil.NewFromLaunchConfiguration(nodeClassName(launchConfigurationName), out.LaunchConfigurations[0]))
+		instanceProfile = aws.StringValue(out.LaunchConfigurations[0].IamInstanceProfile)
+	default:
+		log.Fatalf("one of --launch-template-id, --launch-template-name, --launch-configuration-name, or --auto-scaling-group-name is required")
+	}
+	if asg != nil {
+		nodeclassutil.MergeAutoScalingGroup(nodeClass, asg)
+	}
+	if instanceProfile != "" {
+		nodeClass.Spec.Role = aws.String(roleName(iam.New(sess), instanceProfile))
+	}
+	if len(nodeClass.Spec.SubnetSelectorTerms) == 0 {
+		log.Printf("WARNING: no subnets were found, subnetSelectorTerms must be added before applying the NodeClass")
+	}
+	if nodeClass.Spec.Role == nil {
+		log.Printf("WARNING: no instance profile was found, role must be added before applying the NodeClass")
+	}
+	fmt.Fprint(os.Stdout, string(lo.Must(yaml.Marshal(nodeClass))))
+}
+
+// roleName resolves the IAM role of an instance profile, which is referenced either by name or by ARN
+func roleName(iamapi *iam.IAM, instanceProfile string) string {
+	if parsed, err := arn.Parse(instanceProfile); err == nil {
+		instanceProfile = parsed.Resource[strings.LastIndex(parsed.Resource, "/")+1:]
+	}
+	out := lo.Must(iamapi.GetInstanceProfile(&iam.GetInstanceProfileInput{InstanceProfileName: aws.String(instanceProfile)}))
+	if len(out.InstanceProfile.Roles) == 0 {
+		log.Fatalf("instance profile %q has no role", instanceProfile)
+	}
+	return aws.StringValue(out.InstanceProfile.Roles[0].RoleName)
+}
+
+var invalidNameCharacters = regexp.MustCompile("[^a-z0-9.-]+")
+
+// nodeClassName converts the name of the converted resource, or of the auto scaling group that uses it, into a valid
+// Kubernetes object name
+func nodeClassName(resourceName string) string {
+	if name != "" {
+		return name
+	}
+	if autoScalingGroupName != "" {
+		resourceName = autoScalingGroupName
+	}
+	return strings.Trim(invalidNameCharacters.ReplaceAllString(strings.ToLower(resourceName), "-"), "-.")
+}
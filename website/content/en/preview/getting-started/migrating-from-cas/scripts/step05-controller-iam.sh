@@ -29,6 +29,7 @@ cat << EOF > controller-policy.json
             "Action": [
                 "ssm:GetParameter",
                 "ec2:DescribeImages",
+                "ec2:DescribeImageAttribute",
                 "ec2:RunInstances",
                 "ec2:DescribeSubnets",
                 "ec2:DescribeSecurityGroups",
@@ -37,6 +38,7 @@ cat << EOF > controller-policy.json
                 "ec2:DescribeInstanceTypes",
                 "ec2:DescribeInstanceTypeOfferings",
                 "ec2:DescribeAvailabilityZones",
+                "ec2:DescribeCapacityReservations",
                 "ec2:DeleteLaunchTemplate",
                 "ec2:CreateTags",
                 "ec2:CreateLaunchTemplate",